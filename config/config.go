@@ -83,6 +83,11 @@ type Config struct {
 	//
 	// Set it via the UserAgent option function.
 	UserAgent string
+	// UserAgentFunc, if set, is called to obtain the user agent every time it
+	// is sent. It takes precedence over UserAgent.
+	UserAgentFunc func() string
+	// OmitUserAgent stops sending the user agent to other peers.
+	OmitUserAgent bool
 
 	// ProtocolVersion is the protocol version that identifies the family
 	// of protocols used by the peer in the Identify protocol. It is set
@@ -94,11 +99,13 @@ type Config struct {
 	QUICReuse          []fx.Option
 	Transports         []fx.Option
 	Muxers             []tptu.StreamMuxer
+	UpgraderOpts       []tptu.Option
 	SecurityTransports []Security
 	Insecure           bool
 	PSK                pnet.PSK
 
-	DialTimeout time.Duration
+	DialTimeout         time.Duration
+	DialTimeoutSchedule swarm.DialTimeoutSchedule
 
 	RelayCustom bool
 	Relay       bool // should the relay transport be used
@@ -138,8 +145,18 @@ type Config struct {
 	SwarmOpts []swarm.Option
 
 	DisableIdentifyAddressDiscovery bool
+	DisableIdentifyPush             bool
+	IdentifyPushCoalesceWindow      time.Duration
+	IdentifyPushMinInterval         time.Duration
+	RequireSignedPeerRecords        bool
+	AllowUnsignedAddrs              func(p peer.ID, agentVersion string) bool
 
-	EnableAutoNATv2 bool
+	RelayPolicy func(peer.ID, []protocol.ID) network.RelayPolicy
+
+	EnableAutoNATv2        bool
+	DisableAutoNATv2Server bool
+
+	DisableRelayStopHandler bool
 
 	UDPBlackHoleSuccessCounter        *swarm.BlackHoleSuccessCounter
 	CustomUDPBlackHoleSuccessCounter  bool
@@ -196,6 +213,9 @@ func (cfg *Config) makeSwarm(eventBus event.Bus, enableMetrics bool) (*swarm.Swa
 	if cfg.DialTimeout != 0 {
 		opts = append(opts, swarm.WithDialTimeout(cfg.DialTimeout))
 	}
+	if cfg.DialTimeoutSchedule != nil {
+		opts = append(opts, swarm.WithDialTimeoutSchedule(cfg.DialTimeoutSchedule))
+	}
 	if cfg.ResourceManager != nil {
 		opts = append(opts, swarm.WithResourceManager(cfg.ResourceManager))
 	}
@@ -288,7 +308,7 @@ func (cfg *Config) makeAutoNATV2Host() (host.Host, error) {
 func (cfg *Config) addTransports() ([]fx.Option, error) {
 	fxopts := []fx.Option{
 		fx.WithLogger(func() fxevent.Logger { return getFXLogger() }),
-		fx.Provide(fx.Annotate(tptu.New, fx.ParamTags(`name:"security"`))),
+		fx.Provide(fx.Annotate(tptu.New, fx.ParamTags(`name:"security"`, "", "", "", "", `group:"upgraderopts"`))),
 		fx.Supply(cfg.Muxers),
 		fx.Provide(func() connmgr.ConnectionGater { return cfg.ConnectionGater }),
 		fx.Provide(func() pnet.PSK { return cfg.PSK }),
@@ -297,7 +317,11 @@ func (cfg *Config) addTransports() ([]fx.Option, error) {
 			if !cfg.ShareTCPListener {
 				return nil
 			}
-			return tcpreuse.NewConnMgr(tcpreuse.EnvReuseportVal, upgrader)
+			var opts []tcpreuse.Option
+			if !cfg.DisableMetrics {
+				opts = append(opts, tcpreuse.EnableMetrics(cfg.PrometheusRegisterer))
+			}
+			return tcpreuse.NewConnMgr(tcpreuse.EnvReuseportVal, upgrader, opts...)
 		}),
 		fx.Provide(func(cm *quicreuse.ConnManager, sw *swarm.Swarm) libp2pwebrtc.ListenUDPFn {
 			hasQuicAddrPortFor := func(network string, laddr *net.UDPAddr) bool {
@@ -323,6 +347,10 @@ func (cfg *Config) addTransports() ([]fx.Option, error) {
 			}
 		}),
 	}
+	for _, opt := range cfg.UpgraderOpts {
+		fxopts = append(fxopts, fx.Supply(fx.Annotate(opt, fx.ResultTags(`group:"upgraderopts"`))))
+	}
+
 	fxopts = append(fxopts, cfg.Transports...)
 	if cfg.Insecure {
 		fxopts = append(fxopts,
@@ -429,7 +457,13 @@ func (cfg *Config) addTransports() ([]fx.Option, error) {
 		)),
 	)
 	if cfg.Relay {
-		fxopts = append(fxopts, fx.Invoke(circuitv2.AddTransport))
+		var relayOpts []circuitv2.Option
+		if cfg.DisableRelayStopHandler {
+			relayOpts = append(relayOpts, circuitv2.DisableStopHandler())
+		}
+		fxopts = append(fxopts, fx.Invoke(func(h host.Host, upgrader transport.Upgrader) error {
+			return circuitv2.AddTransport(h, upgrader, relayOpts...)
+		}))
 	}
 	return fxopts, nil
 }
@@ -442,6 +476,8 @@ func (cfg *Config) newBasicHost(swrm *swarm.Swarm, eventBus event.Bus, an *auton
 		NATManager:                      cfg.NATManager,
 		EnablePing:                      !cfg.DisablePing,
 		UserAgent:                       cfg.UserAgent,
+		UserAgentFunc:                   cfg.UserAgentFunc,
+		OmitUserAgent:                   cfg.OmitUserAgent,
 		ProtocolVersion:                 cfg.ProtocolVersion,
 		EnableHolePunching:              cfg.EnableHolePunching,
 		HolePunchingOptions:             cfg.HolePunchingOptions,
@@ -450,6 +486,12 @@ func (cfg *Config) newBasicHost(swrm *swarm.Swarm, eventBus event.Bus, an *auton
 		EnableMetrics:                   !cfg.DisableMetrics,
 		PrometheusRegisterer:            cfg.PrometheusRegisterer,
 		DisableIdentifyAddressDiscovery: cfg.DisableIdentifyAddressDiscovery,
+		DisableIdentifyPush:             cfg.DisableIdentifyPush,
+		IdentifyPushCoalesceWindow:      cfg.IdentifyPushCoalesceWindow,
+		IdentifyPushMinInterval:         cfg.IdentifyPushMinInterval,
+		RequireSignedPeerRecords:        cfg.RequireSignedPeerRecords,
+		AllowUnsignedAddrs:              cfg.AllowUnsignedAddrs,
+		RelayPolicy:                     cfg.RelayPolicy,
 		AutoNATv2:                       an,
 	})
 	if err != nil {
@@ -533,15 +575,22 @@ func (cfg *Config) NewNode() (host.Host, error) {
 			if !cfg.EnableAutoNATv2 {
 				return nil, nil
 			}
-			ah, err := cfg.makeAutoNATV2Host()
-			if err != nil {
-				return nil, err
-			}
 			var mt autonatv2.MetricsTracer
 			if !cfg.DisableMetrics {
 				mt = autonatv2.NewMetricsTracer(cfg.PrometheusRegisterer)
 			}
-			autoNATv2, err := autonatv2.New(ah, autonatv2.WithMetricsTracer(mt))
+			anOpts := []autonatv2.AutoNATOption{autonatv2.WithMetricsTracer(mt)}
+			var ah host.Host
+			if cfg.DisableAutoNATv2Server {
+				anOpts = append(anOpts, autonatv2.WithoutServer())
+			} else {
+				var err error
+				ah, err = cfg.makeAutoNATV2Host()
+				if err != nil {
+					return nil, err
+				}
+			}
+			autoNATv2, err := autonatv2.New(ah, anOpts...)
 			if err != nil {
 				return nil, fmt.Errorf("failed to create autonatv2: %w", err)
 			}
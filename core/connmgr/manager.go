@@ -82,6 +82,45 @@ type ConnManager interface {
 	Close() error
 }
 
+// ProtectionKind is what a protection pattern is matched against.
+type ProtectionKind int
+
+const (
+	// ProtectByProtocol matches the protocols of the streams open with a peer.
+	ProtectByProtocol ProtectionKind = iota
+	// ProtectByService matches the resource manager services the streams
+	// open with a peer are attached to.
+	ProtectByService
+	// ProtectByTag matches the tags of a peer.
+	ProtectByTag
+)
+
+// PatternProtector is implemented by ConnManagers that can protect peers based
+// on what their connections carry, instead of requiring every subsystem to
+// call Protect for every peer. For example, protecting the tag
+// "relay-reservation" keeps the peers with an active relay reservation, and
+// protecting the protocol "/ipfs/bitswap/*" keeps the peers with an open
+// bitswap stream.
+//
+// Patterns are evaluated when trimming connections.
+type PatternProtector interface {
+	// ProtectPattern protects the peers matching the pattern from having
+	// their connections pruned. Patterns use the syntax of path.Match.
+	//
+	// Calls to ProtectPattern() with the same kind and pattern are idempotent.
+	ProtectPattern(kind ProtectionKind, pattern string) error
+
+	// UnprotectPattern removes a protection added by ProtectPattern.
+	UnprotectPattern(kind ProtectionKind, pattern string)
+}
+
+// SupportsPatternProtection evaluates if the provided ConnManager supports
+// protecting peers by pattern, and if so, it returns the PatternProtector.
+func SupportsPatternProtection(mgr ConnManager) (PatternProtector, bool) {
+	p, ok := mgr.(PatternProtector)
+	return p, ok
+}
+
 // TagInfo stores metadata associated with a peer.
 type TagInfo struct {
 	FirstSeen time.Time
@@ -82,6 +82,25 @@ type EvtLocalAddressesUpdated struct {
 	SignedPeerRecord *record.Envelope
 }
 
+// EvtLocalPeerRecordUpdated is emitted by the Host when it signs a new peer
+// record for itself. It is useful for applications that publish the record to
+// external systems, e.g. DNS or a registry.
+type EvtLocalPeerRecordUpdated struct {
+	// SignedPeerRecord is the new peer.PeerRecord of the Host, wrapped in a
+	// record.Envelope and signed by the Host's private key.
+	SignedPeerRecord *record.Envelope
+}
+
+// EvtListenAddrsUpdated is emitted by the Host when it starts or stops
+// listening on an address. Unlike EvtLocalAddressesUpdated, it reports the
+// addresses the Host listens on, not the addresses it advertises.
+type EvtListenAddrsUpdated struct {
+	// Added are the addresses the Host started listening on.
+	Added []ma.Multiaddr
+	// Removed are the addresses the Host stopped listening on.
+	Removed []ma.Multiaddr
+}
+
 // EvtAutoRelayAddrsUpdated is sent by the autorelay when the node's relay addresses are updated
 type EvtAutoRelayAddrsUpdated struct {
 	RelayAddrs []ma.Multiaddr
@@ -9,6 +9,7 @@ import (
 )
 
 // EvtPeerIdentificationCompleted is emitted when the initial identification round for a peer is completed.
+// It is emitted again with IsPush set when the peer pushes updated information.
 type EvtPeerIdentificationCompleted struct {
 	// Peer is the ID of the peer whose identification succeeded.
 	Peer peer.ID
@@ -35,6 +36,10 @@ type EvtPeerIdentificationCompleted struct {
 	// ObservedAddr is the our side's connection address as observed by the
 	// peer. This is not verified, the peer could return anything here.
 	ObservedAddr multiaddr.Multiaddr
+
+	// IsPush is true if the information was received in an identify push,
+	// i.e. the peer updated the information sent in a previous round.
+	IsPush bool
 }
 
 // EvtPeerIdentificationFailed is emitted when the initial identification round for a peer failed.
@@ -44,3 +49,33 @@ type EvtPeerIdentificationFailed struct {
 	// Reason is the reason why identification failed.
 	Reason error
 }
+
+// ObservedAddr is one of our addresses as observed by our peers, together with
+// the confidence we have in it.
+type ObservedAddr struct {
+	// Addr is the observed address.
+	Addr multiaddr.Multiaddr
+
+	// Observers is the number of distinct observers that reported the address.
+	// Observers are told apart by their IPv4 address or IPv6 /56 prefix.
+	// Observations made using transports sharing the same IP and port, e.g. TCP
+	// and WebSocket, are counted together.
+	Observers int
+
+	// Activated is true if the address was observed by enough observers to be
+	// announced to other peers.
+	Activated bool
+
+	// Inferred is true if the address wasn't observed on this transport, but
+	// inferred from observations made on another transport sharing the same IP
+	// and port.
+	Inferred bool
+}
+
+// EvtObservedAddrsUpdated is emitted when the addresses our peers observe us
+// on, or the number of observers of these addresses, changed.
+type EvtObservedAddrsUpdated struct {
+	// Addrs is the list of observed addresses, sorted by decreasing number of
+	// observers.
+	Addrs []ObservedAddr
+}
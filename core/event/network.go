@@ -52,4 +52,32 @@ type EvtPeerConnectednessChanged struct {
 	Peer peer.ID
 	// Connectedness is the new connectedness state.
 	Connectedness network.Connectedness
+	// Reason is the reason the last connection to the peer was closed. It is
+	// only set when Connectedness is NotConnected.
+	Reason network.DisconnectReason
+	// LastConnStats are the stats of the last connection to the peer. They
+	// are only set when Connectedness is NotConnected.
+	LastConnStats network.ConnStats
+}
+
+// EvtBlackHoleStateChanged is emitted when the state of one of the swarm's
+// black hole detectors changes.
+type EvtBlackHoleStateChanged struct {
+	// Name is the name of the detector, e.g. "UDP" or "IPv6".
+	Name string
+	// State is the new state of the detector.
+	State network.BlackHoleState
+}
+
+// EvtRelayedConnCutover is emitted when a relayed connection to a peer is
+// closed because a direct connection to the peer is available.
+// This event is only emitted if relay cutover is enabled on the swarm.
+type EvtRelayedConnCutover struct {
+	// Peer is the remote peer.
+	Peer peer.ID
+	// RelayedConn is the relayed connection that was closed.
+	RelayedConn network.Conn
+	// DirectConn is the direct connection that replaces the relayed
+	// connection.
+	DirectConn network.Conn
 }
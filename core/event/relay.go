@@ -0,0 +1,61 @@
+package event
+
+import (
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// RelayReservationEventType is the type of an EvtRelayReservation.
+type RelayReservationEventType int
+
+const (
+	// RelayReservationObtained is sent when a new reservation is obtained.
+	RelayReservationObtained RelayReservationEventType = iota
+	// RelayReservationRefreshed is sent when a reservation is refreshed.
+	RelayReservationRefreshed
+	// RelayReservationExpiringSoon is sent when a reservation is about to expire, before
+	// attempting to refresh it.
+	RelayReservationExpiringSoon
+	// RelayReservationRefreshFailed is sent when refreshing a reservation failed. The
+	// reservation is not used anymore.
+	RelayReservationRefreshFailed
+	// RelayReservationEvicted is sent when the connection to the relay is closed, ending the
+	// reservation.
+	RelayReservationEvicted
+	// RelayReservationMigrated is sent when the reservation is not used anymore, because a
+	// reservation with a much faster relay was obtained.
+	RelayReservationMigrated
+)
+
+func (t RelayReservationEventType) String() string {
+	switch t {
+	case RelayReservationObtained:
+		return "obtained"
+	case RelayReservationRefreshed:
+		return "refreshed"
+	case RelayReservationExpiringSoon:
+		return "expiring soon"
+	case RelayReservationRefreshFailed:
+		return "refresh failed"
+	case RelayReservationEvicted:
+		return "evicted"
+	case RelayReservationMigrated:
+		return "migrated"
+	default:
+		return "unknown"
+	}
+}
+
+// EvtRelayReservation is sent by the autorelay when the state of a reservation with a relay
+// changes. Applications can use it to notice when their reachability via relays degrades.
+type EvtRelayReservation struct {
+	Type RelayReservationEventType
+	// Relay is the relay the reservation is with.
+	Relay peer.ID
+	// Expiration is the expiration time of the reservation. After a failed refresh or an
+	// eviction, it's the expiration time the reservation had.
+	Expiration time.Time
+	// Error is the error that made the refresh fail, for RelayReservationRefreshFailed.
+	Error error
+}
@@ -1,6 +1,10 @@
 package host
 
-import "github.com/libp2p/go-libp2p/core/peer"
+import (
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/record"
+)
 
 // InfoFromHost returns a peer.AddrInfo struct with the Host's ID and all of its Addrs.
 func InfoFromHost(h Host) *peer.AddrInfo {
@@ -9,3 +13,17 @@ func InfoFromHost(h Host) *peer.AddrInfo {
 		Addrs: h.Addrs(),
 	}
 }
+
+// SignedPeerRecordFromHost returns the latest signed peer record of the Host,
+// as stored in its peerstore. It returns nil if the Host doesn't sign peer
+// records, or if its peerstore is not a peerstore.CertifiedAddrBook.
+//
+// The signed peer records of other peers can be retrieved from the peerstore
+// using peerstore.CertifiedAddrBook.GetPeerRecord.
+func SignedPeerRecordFromHost(h Host) *record.Envelope {
+	cab, ok := peerstore.GetCertifiedAddrBook(h.Peerstore())
+	if !ok {
+		return nil
+	}
+	return cab.GetPeerRecord(h.ID())
+}
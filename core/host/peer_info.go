@@ -0,0 +1,127 @@
+package host
+
+import (
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/connmgr"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/protocol"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// PeerInfo is a snapshot of everything a host knows about a peer, aggregated
+// from the swarm, the peerstore, the connection manager and the resource
+// manager. It is intended for debugging and introspection.
+type PeerInfo struct {
+	ID            peer.ID
+	Connectedness network.Connectedness
+	// Conns lists the open connections to the peer.
+	Conns []PeerConnInfo
+
+	// Addrs are the addresses of the peer known to the peerstore.
+	Addrs []ma.Multiaddr
+	// AddrRecords describe the addresses of the peer, including the source
+	// they were learned from. They are only set if the peerstore is a
+	// peerstore.AddrBookInspector.
+	AddrRecords []peerstore.AddrRecord
+	// Protocols are the protocols the peer supports according to the peerstore.
+	Protocols    []protocol.ID
+	AgentVersion string
+	// Latency is the EWMA of the latency to the peer, or 0 if unknown.
+	Latency time.Duration
+
+	// TagInfo is the connection manager's tag information for the peer. It is
+	// nil if the connection manager doesn't track the peer.
+	TagInfo   *connmgr.TagInfo
+	Protected bool
+
+	// ResourceUsage is the resource manager usage of the peer's scope.
+	ResourceUsage network.ScopeStat
+
+	// Events are the recent events about the peer, oldest first. They are
+	// only recorded by hosts that track them, like the basic host.
+	Events []PeerEvent
+}
+
+// PeerEvent is an event about a peer recorded by the host.
+type PeerEvent struct {
+	Time time.Time
+	// Event is the event, e.g. an event.EvtPeerConnectednessChanged.
+	Event interface{}
+}
+
+// PeerConnInfo describes a single connection to a peer.
+type PeerConnInfo struct {
+	ID         string
+	LocalAddr  ma.Multiaddr
+	RemoteAddr ma.Multiaddr
+	State      network.ConnectionState
+	network.Stats
+	// Streams is the number of open streams per protocol. Streams that haven't
+	// negotiated a protocol yet are counted under the empty protocol ID.
+	Streams map[protocol.ID]int
+}
+
+// PeerInfoProvider is implemented by hosts that can report a consolidated
+// view of a peer. It's not part of the Host interface, so that adding it
+// doesn't break other Host implementations; use GetPeerInfo to get the view
+// of a peer from any Host.
+type PeerInfoProvider interface {
+	PeerInfo(p peer.ID) PeerInfo
+}
+
+// GetPeerInfo returns a consolidated view of a peer. If h is a
+// PeerInfoProvider, its PeerInfo method is used, otherwise the view is
+// assembled by CollectPeerInfo.
+func GetPeerInfo(h Host, p peer.ID) PeerInfo {
+	if pip, ok := h.(PeerInfoProvider); ok {
+		return pip.PeerInfo(p)
+	}
+	return CollectPeerInfo(h, p)
+}
+
+// CollectPeerInfo assembles the view of a peer from the network, the
+// peerstore, the connection manager and the resource manager of h. It doesn't
+// include events.
+func CollectPeerInfo(h Host, p peer.ID) PeerInfo {
+	ps := h.Peerstore()
+	info := PeerInfo{
+		ID:            p,
+		Connectedness: h.Network().Connectedness(p),
+		Addrs:         ps.Addrs(p),
+		Latency:       ps.LatencyEWMA(p),
+		TagInfo:       h.ConnManager().GetTagInfo(p),
+		Protected:     h.ConnManager().IsProtected(p, ""),
+	}
+	if inspector, ok := ps.(peerstore.AddrBookInspector); ok {
+		info.AddrRecords = inspector.InspectAddrs(p)
+	}
+	if protos, err := ps.GetProtocols(p); err == nil {
+		info.Protocols = protos
+	}
+	if av, err := ps.Get(p, "AgentVersion"); err == nil {
+		info.AgentVersion, _ = av.(string)
+	}
+	for _, c := range h.Network().ConnsToPeer(p) {
+		ci := PeerConnInfo{
+			ID:         c.ID(),
+			LocalAddr:  c.LocalMultiaddr(),
+			RemoteAddr: c.RemoteMultiaddr(),
+			State:      c.ConnState(),
+			Stats:      c.Stat().Stats,
+			Streams:    make(map[protocol.ID]int),
+		}
+		for _, s := range c.GetStreams() {
+			ci.Streams[s.Protocol()]++
+		}
+		info.Conns = append(info.Conns, ci)
+	}
+	_ = h.Network().ResourceManager().ViewPeer(p, func(scope network.PeerScope) error {
+		info.ResourceUsage = scope.Stat()
+		return nil
+	})
+	return info
+}
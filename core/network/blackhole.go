@@ -0,0 +1,33 @@
+package network
+
+import "fmt"
+
+// BlackHoleState is the state of a black hole detector. Black hole detectors
+// keep track of the success rate of dials to a class of addresses, e.g. UDP
+// or IPv6 addresses, and block dials to these addresses if they don't succeed
+// on the current network.
+type BlackHoleState int
+
+const (
+	// BlackHoleStateProbing indicates that there are not enough dial results
+	// to determine the state. Dials are allowed.
+	BlackHoleStateProbing BlackHoleState = iota
+	// BlackHoleStateAllowed indicates that dials are succeeding.
+	BlackHoleStateAllowed
+	// BlackHoleStateBlocked indicates that dials are failing. Dials are
+	// refused, except for occasional probes.
+	BlackHoleStateBlocked
+)
+
+func (st BlackHoleState) String() string {
+	switch st {
+	case BlackHoleStateProbing:
+		return "Probing"
+	case BlackHoleStateAllowed:
+		return "Allowed"
+	case BlackHoleStateBlocked:
+		return "Blocked"
+	default:
+		return fmt.Sprintf("Unknown %d", int(st))
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"time"
 
 	ic "github.com/libp2p/go-libp2p/core/crypto"
 
@@ -135,9 +136,39 @@ type ConnStat interface {
 	Stat() ConnStats
 }
 
+// ConnRTT is an interface mixin for connection types that measure the round
+// trip time to the remote peer.
+type ConnRTT interface {
+	// SmoothedRTT returns the smoothed round trip time of the connection. It
+	// returns zero if it wasn't measured yet.
+	SmoothedRTT() time.Duration
+}
+
 // ConnScoper is the interface that one can mix into a connection interface to give it a resource
 // management scope
 type ConnScoper interface {
 	// Scope returns the user view of this connection's resource scope
 	Scope() ConnScope
 }
+
+// DatagramConn is implemented by connections that can carry unreliable
+// datagrams next to streams, as specified in RFC 9221. Datagrams may be lost,
+// reordered or duplicated, and are never retransmitted.
+//
+// Datagrams are not associated with a protocol. All datagrams received on a
+// connection are delivered to the callers of ReceiveDatagram, so applications
+// sharing a connection need to agree on how to tell them apart.
+type DatagramConn interface {
+	// SupportsDatagrams returns true if both endpoints negotiated datagram
+	// support on this connection.
+	SupportsDatagrams() bool
+
+	// SendDatagram sends b as a single datagram. It returns
+	// ErrDatagramsNotSupported if the connection doesn't support datagrams,
+	// and an error if b is too large to fit into a single packet.
+	SendDatagram(b []byte) error
+
+	// ReceiveDatagram blocks until a datagram is received, the context is
+	// canceled or the connection is closed.
+	ReceiveDatagram(ctx context.Context) ([]byte, error)
+}
@@ -15,6 +15,8 @@ type dialPeerTimeoutCtxKey struct{}
 type forceDirectDialCtxKey struct{}
 type allowLimitedConnCtxKey struct{}
 type simConnectCtxKey struct{ isClient bool }
+type streamPriorityCtxKey struct{}
+type relayPolicyCtxKey struct{}
 
 var noDial = noDialCtxKey{}
 var forceDirectDial = forceDirectDialCtxKey{}
@@ -128,3 +130,37 @@ func GetUseTransient(ctx context.Context) (usetransient bool, reason string) {
 	}
 	return false, ""
 }
+
+// WithStreamPriority constructs a new context with an option that sets the
+// priority of the stream opened using the context. The priority is recorded in
+// the stream's Stats. Neither yamux nor QUIC support stream priorities, so it
+// doesn't change how the stream's data is scheduled.
+func WithStreamPriority(ctx context.Context, p StreamPriority) context.Context {
+	return context.WithValue(ctx, streamPriorityCtxKey{}, p)
+}
+
+// GetStreamPriority returns the stream priority set in the context, or
+// StreamPriorityDefault if none is set.
+func GetStreamPriority(ctx context.Context) StreamPriority {
+	if p, ok := ctx.Value(streamPriorityCtxKey{}).(StreamPriority); ok {
+		return p
+	}
+	return StreamPriorityDefault
+}
+
+// WithRelayPolicy constructs a new context with an option that controls
+// whether relayed connections may be used when dialing the peer or opening a
+// stream to it. Unless it is RelayPolicyDefault, it takes precedence over the
+// policy configured on the network.
+func WithRelayPolicy(ctx context.Context, p RelayPolicy) context.Context {
+	return context.WithValue(ctx, relayPolicyCtxKey{}, p)
+}
+
+// GetRelayPolicy returns the relay policy set in the context, or
+// RelayPolicyDefault if none is set.
+func GetRelayPolicy(ctx context.Context) RelayPolicy {
+	if p, ok := ctx.Value(relayPolicyCtxKey{}).(RelayPolicy); ok {
+		return p
+	}
+	return RelayPolicyDefault
+}
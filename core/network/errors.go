@@ -37,3 +37,7 @@ var ErrResourceLimitExceeded = temporaryError("resource limit exceeded")
 // ErrResourceScopeClosed is returned when attempting to reserve resources in a closed resource
 // scope.
 var ErrResourceScopeClosed = errors.New("resource scope closed")
+
+// ErrDatagramsNotSupported is returned when sending or receiving datagrams on
+// a connection that doesn't support them.
+var ErrDatagramsNotSupported = errors.New("connection doesn't support datagrams")
@@ -110,6 +110,33 @@ type MuxedStream interface {
 	SetWriteDeadline(time.Time) error
 }
 
+// StreamPriority is a hint about how urgently the data of a stream should be
+// sent, relative to other streams on the same connection.
+type StreamPriority int
+
+const (
+	// StreamPriorityDefault is the priority of streams that didn't set one.
+	StreamPriorityDefault StreamPriority = 0
+	// StreamPriorityLow is for bulk transfers that can yield to other streams.
+	StreamPriorityLow StreamPriority = -1
+	// StreamPriorityHigh is for latency sensitive control traffic, like
+	// identify, ping and hole punching.
+	StreamPriorityHigh StreamPriority = 1
+)
+
+func (p StreamPriority) String() string {
+	switch p {
+	case StreamPriorityDefault:
+		return "default"
+	case StreamPriorityLow:
+		return "low"
+	case StreamPriorityHigh:
+		return "high"
+	default:
+		return fmt.Sprintf("priority(%d)", int(p))
+	}
+}
+
 // MuxedConn represents a connection to a remote peer that has been
 // extended to support stream multiplexing.
 //
@@ -78,6 +78,46 @@ func (c Connectedness) String() string {
 	return str[c]
 }
 
+// DisconnectReason is the reason a connection was closed.
+type DisconnectReason int
+
+const (
+	// DisconnectReasonUnknown indicates that the reason is unknown.
+	DisconnectReasonUnknown DisconnectReason = iota
+
+	// DisconnectReasonLocalClose indicates that the connection was closed
+	// locally, e.g. by the application or because the node shut down.
+	DisconnectReasonLocalClose
+
+	// DisconnectReasonConnManager indicates that the connection was trimmed
+	// by the connection manager.
+	DisconnectReasonConnManager
+
+	// DisconnectReasonResourceLimit indicates that the connection was closed
+	// because a resource limit was exceeded.
+	DisconnectReasonResourceLimit
+
+	// DisconnectReasonRemoteClose indicates that the remote peer closed or
+	// reset the connection.
+	DisconnectReasonRemoteClose
+
+	// DisconnectReasonIdleTimeout indicates that the connection timed out,
+	// e.g. because the remote peer stopped responding.
+	DisconnectReasonIdleTimeout
+
+	// DisconnectReasonError indicates that the connection failed with an
+	// error.
+	DisconnectReasonError
+)
+
+func (r DisconnectReason) String() string {
+	str := [...]string{"Unknown", "LocalClose", "ConnManager", "ResourceLimit", "RemoteClose", "IdleTimeout", "Error"}
+	if r < 0 || int(r) >= len(str) {
+		return unrecognized
+	}
+	return str[r]
+}
+
 // Reachability indicates how reachable a node is.
 type Reachability int
 
@@ -105,11 +145,58 @@ func (r Reachability) String() string {
 	return str[r]
 }
 
+// RelayPolicy controls whether relayed connections may be used to reach a
+// peer.
+type RelayPolicy int
+
+const (
+	// RelayPolicyDefault allows both direct and relayed connections.
+	RelayPolicyDefault RelayPolicy = iota
+
+	// RelayPolicyForbid only allows direct connections. Relay addresses are
+	// not dialed, and existing relayed connections are not used.
+	RelayPolicyForbid
+
+	// RelayPolicyRequire only allows relayed connections. Direct addresses
+	// are not dialed, and existing direct connections are not used.
+	RelayPolicyRequire
+)
+
+func (p RelayPolicy) String() string {
+	str := [...]string{"Default", "Forbid", "Require"}
+	if p < 0 || int(p) >= len(str) {
+		return unrecognized
+	}
+	return str[p]
+}
+
 // ConnStats stores metadata pertaining to a given Conn.
 type ConnStats struct {
 	Stats
 	// NumStreams is the number of streams on the connection.
 	NumStreams int
+	// Handshake is the time spent establishing the connection.
+	Handshake HandshakeTimings
+	// SmoothedRTT is the smoothed round trip time of the connection when the
+	// stats were taken. Depending on the transport, it's measured by QUIC or
+	// the kernel's TCP stack. It's zero if unknown, or if the transport wasn't
+	// configured to measure it.
+	SmoothedRTT time.Duration
+}
+
+// HandshakeTimings is the time spent in each stage of establishing a
+// connection. Stages that weren't observed are zero.
+type HandshakeTimings struct {
+	// Connect is the time spent establishing the underlying transport
+	// connection, e.g. the TCP handshake. For transports with built-in
+	// security, like QUIC, this includes the security handshake. It's only
+	// known for outbound connections.
+	Connect time.Duration
+	// Security is the time spent negotiating the security protocol and
+	// running the security handshake.
+	Security time.Duration
+	// Muxer is the time spent negotiating the stream multiplexer.
+	Muxer time.Duration
 }
 
 // Stats stores metadata pertaining to a given Stream / Conn.
@@ -124,6 +211,9 @@ type Stats struct {
 	Limited bool
 	// Extra stores additional metadata about this connection.
 	Extra map[interface{}]interface{}
+	// Priority is the priority the stream was opened with. It's only set for
+	// outbound streams.
+	Priority StreamPriority
 }
 
 // StreamHandler is the type of function used to listen for
@@ -214,5 +304,15 @@ type AddrDelay struct {
 	Delay time.Duration
 }
 
-// DialRanker provides a schedule of dialing the provided addresses
+// DialRanker provides a schedule of dialing the provided addresses.
+//
+// The delays are relative to the start of the dial. Only the returned
+// addresses are dialed, so a ranker can also be used to drop addresses.
+// Dials to the remaining addresses are canceled as soon as a connection is
+// established.
 type DialRanker func([]ma.Multiaddr) []AddrDelay
+
+// PeerDialRanker is like DialRanker, but is also passed the peer that is being
+// dialed. This allows applications to take their knowledge about the peer
+// into account, for example the measured latency of previous connections.
+type PeerDialRanker func(peer.ID, []ma.Multiaddr) []AddrDelay
@@ -13,6 +13,18 @@ type Notifiee interface {
 	Disconnected(Network, Conn)        // called when a connection closed
 }
 
+// StreamNotifiee is implemented by Notifiees that also want to be notified
+// when streams are opened and closed, for example to track the activity of
+// connections. Networks that support it check for it when Notify is called.
+//
+// The notifications are sent synchronously on the stream's hot path, so they
+// must not block. The stream's protocol is usually not negotiated yet when
+// OpenedStream is called.
+type StreamNotifiee interface {
+	OpenedStream(Network, Stream)
+	ClosedStream(Network, Stream)
+}
+
 // NotifyBundle implements Notifiee by calling any of the functions set on it,
 // and nop'ing if they are unset. This is the easy way to register for
 // notifications.
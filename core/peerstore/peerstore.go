@@ -5,6 +5,7 @@ package peerstore
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"math"
 	"time"
@@ -90,6 +91,29 @@ type PeerMetadata interface {
 	RemovePeer(peer.ID)
 }
 
+// PeerMetadataTTL is implemented by PeerMetadata stores that support values
+// with a limited lifetime. It is useful for transient facts about a peer.
+type PeerMetadataTTL interface {
+	// PutWithTTL is like Put, but the value expires after ttl. Get returns
+	// ErrNotFound for expired values. Put removes the TTL of a value.
+	PutWithTTL(p peer.ID, key string, val interface{}, ttl time.Duration) error
+}
+
+// GetMetadata returns the value stored for a peer under key, if it is of type
+// T. It returns ErrNotFound if there is no value.
+func GetMetadata[T any](pm PeerMetadata, p peer.ID, key string) (T, error) {
+	var zero T
+	v, err := pm.Get(p, key)
+	if err != nil {
+		return zero, err
+	}
+	t, ok := v.(T)
+	if !ok {
+		return zero, fmt.Errorf("metadata %q of peer %s is a %T, not a %T", key, p, v, zero)
+	}
+	return t, nil
+}
+
 // AddrBook holds the multiaddrs of peers.
 type AddrBook interface {
 	// AddAddr calls AddAddrs(p, []ma.Multiaddr{addr}, ttl)
@@ -177,6 +201,74 @@ func GetCertifiedAddrBook(ab AddrBook) (cab CertifiedAddrBook, ok bool) {
 	return cab, ok
 }
 
+// AddrSource is the source an address in the AddrBook was learned from.
+// Sources are ordered by how reliable they are. If an address was learned
+// from multiple sources, the most reliable one is recorded.
+type AddrSource int
+
+const (
+	// AddrSourceUnknown is used for addresses added without a source.
+	AddrSourceUnknown AddrSource = iota
+	// AddrSourceManual is used for addresses supplied by the application,
+	// e.g. when calling Host.Connect.
+	AddrSourceManual
+	// AddrSourceDHT is used for addresses learned from a DHT lookup, or
+	// another content routing system.
+	AddrSourceDHT
+	// AddrSourceIdentify is used for addresses the peer sent us using identify.
+	AddrSourceIdentify
+	// AddrSourceDial is used for addresses we successfully dialed.
+	AddrSourceDial
+)
+
+func (s AddrSource) String() string {
+	switch s {
+	case AddrSourceManual:
+		return "manual"
+	case AddrSourceDHT:
+		return "dht"
+	case AddrSourceIdentify:
+		return "identify"
+	case AddrSourceDial:
+		return "dial"
+	default:
+		return "unknown"
+	}
+}
+
+// AddrRecord describes an address stored in the AddrBook.
+type AddrRecord struct {
+	Addr ma.Multiaddr
+	// Source is the most reliable source the address was learned from.
+	Source AddrSource
+	// TTL is the time remaining until the address expires. For addresses of
+	// connected peers and permanent addresses, it's the TTL they were added with.
+	TTL time.Duration
+	// LastSeen is the time the address was last added or updated.
+	LastSeen time.Time
+}
+
+// AddrBookInspector is implemented by AddrBooks that keep track of the
+// source of their addresses. It is useful to debug why an address is dialed.
+type AddrBookInspector interface {
+	// AddAddrsWithSource is like AddAddrs, and records that the addresses were
+	// learned from src.
+	AddAddrsWithSource(p peer.ID, addrs []ma.Multiaddr, ttl time.Duration, src AddrSource)
+
+	// InspectAddrs returns the non-expired addresses of a peer.
+	InspectAddrs(p peer.ID) []AddrRecord
+}
+
+// AddAddrsWithSource adds addresses to ab, recording their source if ab is an
+// AddrBookInspector.
+func AddAddrsWithSource(ab AddrBook, p peer.ID, addrs []ma.Multiaddr, ttl time.Duration, src AddrSource) {
+	if abi, ok := ab.(AddrBookInspector); ok {
+		abi.AddAddrsWithSource(p, addrs, ttl, src)
+		return
+	}
+	ab.AddAddrs(p, addrs, ttl)
+}
+
 // KeyBook tracks the keys of Peers.
 type KeyBook interface {
 	// PubKey returns the public key of a peer.
@@ -213,6 +305,60 @@ type Metrics interface {
 	RemovePeer(peer.ID)
 }
 
+// LatencyStats summarizes the recent latency samples of a peer.
+type LatencyStats struct {
+	// Samples is the number of samples the stats are computed over.
+	Samples int
+	// Last is the most recent sample.
+	Last time.Duration
+	Min  time.Duration
+	Max  time.Duration
+	P50  time.Duration
+	P90  time.Duration
+	P99  time.Duration
+	// Jitter is a smoothed estimate of the variation between consecutive
+	// samples, computed as the interarrival jitter of RFC 3550.
+	Jitter time.Duration
+}
+
+// LatencyTracker is implemented by Metrics that keep the recent latency
+// samples of peers, by transport. Applications can use it to select peers
+// based on more than the latency average.
+type LatencyTracker interface {
+	// RecordLatencySample records a latency sample measured over the given
+	// transport, for example "tcp" or "quic-v1". The sample is also
+	// recorded as by RecordLatency.
+	RecordLatencySample(p peer.ID, transport string, rtt time.Duration)
+
+	// LatencyStats returns the stats of the recent samples of a peer measured
+	// over the given transport. If transport is empty, the stats are computed
+	// over the samples of all transports. ok is false if there are no samples.
+	LatencyStats(p peer.ID, transport string) (stats LatencyStats, ok bool)
+
+	// RemoveLatencySamples removes the recent samples of a peer, keeping its
+	// latency average. The samples describe the connections to the peer, so
+	// they are removed when the peer disconnects.
+	RemoveLatencySamples(p peer.ID)
+}
+
+// RecordLatencySample records a latency sample of a peer measured over the
+// given transport. If m doesn't implement LatencyTracker, it falls back to
+// RecordLatency.
+func RecordLatencySample(m Metrics, p peer.ID, transport string, rtt time.Duration) {
+	if lt, ok := m.(LatencyTracker); ok {
+		lt.RecordLatencySample(p, transport, rtt)
+		return
+	}
+	m.RecordLatency(p, rtt)
+}
+
+// GetLatencyTracker is a helper to upcast Metrics to LatencyTracker, if it
+// implements it.
+func GetLatencyTracker(m Metrics) (lt LatencyTracker, ok bool) {
+	lt, ok = m.(LatencyTracker)
+	return lt, ok
+}
+
 // ProtoBook tracks the protocols supported by peers.
 type ProtoBook interface {
 	GetProtocols(peer.ID) ([]protocol.ID, error)
@@ -232,3 +378,73 @@ type ProtoBook interface {
 	// RemovePeer removes all protocols associated with a peer.
 	RemovePeer(peer.ID)
 }
+
+// ProtoBookIndex is implemented by ProtoBooks that index peers by protocol.
+type ProtoBookIndex interface {
+	// PeersSupporting returns the peers that support the protocol.
+	PeersSupporting(protocol.ID) peer.IDSlice
+}
+
+// PeersSupporting returns the peers of ps that support the protocol. If ps is
+// a ProtoBookIndex, it uses the index. Otherwise, it checks every peer.
+func PeersSupporting(ps Peerstore, proto protocol.ID) peer.IDSlice {
+	if idx, ok := ps.(ProtoBookIndex); ok {
+		return idx.PeersSupporting(proto)
+	}
+	var peers peer.IDSlice
+	for _, p := range ps.Peers() {
+		if supported, err := ps.SupportsProtocols(p, proto); err == nil && len(supported) > 0 {
+			peers = append(peers, p)
+		}
+	}
+	return peers
+}
+
+// ProtoBookNotifier is implemented by ProtoBooks that notify about changes of
+// the protocols supported by peers. It allows services to react to a peer
+// starting or stopping to support a protocol without polling the ProtoBook.
+// If the peerstore of a host implements it, the identify service emits an
+// event.EvtPeerProtocolsUpdated on the host's event bus for every change.
+type ProtoBookNotifier interface {
+	// NotifyProtocolsChanged registers f to be called every time the set of
+	// protocols supported by a peer changes, with the added and removed
+	// protocols. f is called synchronously and must not block. The changes
+	// of a peer are notified in the order they were made. Calling the
+	// returned function unregisters f.
+	NotifyProtocolsChanged(f func(p peer.ID, added, removed []protocol.ID)) (cancel func())
+}
+
+// ScoreBook stores named numeric scores of peers. It allows subsystems like the
+// connection manager, the dial ranker, and the application to share reputation
+// data about peers.
+//
+// Scores decay towards zero over time. The decay rate is configured per score
+// name by the implementation, scores without a configured decay rate don't
+// decay.
+//
+// To test whether a given Peerstore implementation supports scores, callers
+// should use the GetScoreBook helper.
+type ScoreBook interface {
+	// Score returns the current value of a score of a peer. Unknown scores
+	// are zero.
+	Score(p peer.ID, name string) float64
+
+	// SetScore sets a score of a peer.
+	SetScore(p peer.ID, name string, value float64) error
+
+	// AddToScore adds delta to a score of a peer, and returns the new value.
+	AddToScore(p peer.ID, name string, delta float64) (float64, error)
+
+	// Scores returns the current values of all scores of a peer.
+	Scores(p peer.ID) map[string]float64
+
+	// RemovePeer removes all scores of a peer.
+	RemovePeer(peer.ID)
+}
+
+// GetScoreBook is a helper to "upcast" a Peerstore to a ScoreBook by using type
+// assertion. Returns (nil, false) if the Peerstore is not a ScoreBook.
+func GetScoreBook(ps Peerstore) (sb ScoreBook, ok bool) {
+	sb, ok = ps.(ScoreBook)
+	return sb, ok
+}
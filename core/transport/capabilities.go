@@ -0,0 +1,49 @@
+package transport
+
+import "strings"
+
+// Capabilities is a set of capabilities of a transport.
+type Capabilities uint32
+
+const (
+	// CapabilityHolePunching means that the transport can establish
+	// connections through NATs using simultaneous open, as coordinated by the
+	// DCUtR protocol.
+	CapabilityHolePunching Capabilities = 1 << iota
+	// CapabilityDatagrams means that the transport's connections can send
+	// unreliable datagrams, see network.DatagramConn.
+	CapabilityDatagrams
+	// CapabilityBrowser means that browsers can dial the transport's listen
+	// addresses.
+	CapabilityBrowser
+)
+
+// Has returns true if all capabilities in o are in c.
+func (c Capabilities) Has(o Capabilities) bool {
+	return c&o == o
+}
+
+func (c Capabilities) String() string {
+	var s []string
+	if c.Has(CapabilityHolePunching) {
+		s = append(s, "hole-punching")
+	}
+	if c.Has(CapabilityDatagrams) {
+		s = append(s, "datagrams")
+	}
+	if c.Has(CapabilityBrowser) {
+		s = append(s, "browser")
+	}
+	return "[" + strings.Join(s, " ") + "]"
+}
+
+// CapabilityProvider can be optionally implemented by transports to advertise
+// their capabilities. Libp2p services, like hole punching, query them to
+// decide which addresses to use, so that user-provided transports integrate
+// as well as the built-in ones.
+// When wrapping/embedding a transport, you should ensure that the
+// CapabilityProvider interface is handled correctly.
+type CapabilityProvider interface {
+	// Capabilities returns the capabilities of the transport.
+	Capabilities() Capabilities
+}
@@ -27,10 +27,15 @@ import (
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/peerstore"
 	"github.com/libp2p/go-libp2p/core/pnet"
+	"github.com/libp2p/go-libp2p/core/protocol"
 	"github.com/libp2p/go-libp2p/core/routing"
 	"github.com/libp2p/go-libp2p/core/transport"
+	bhost "github.com/libp2p/go-libp2p/p2p/host/basic"
 	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
+	"github.com/libp2p/go-libp2p/p2p/muxer/yamux"
 	"github.com/libp2p/go-libp2p/p2p/net/swarm"
+	tptu "github.com/libp2p/go-libp2p/p2p/net/upgrader"
+	"github.com/libp2p/go-libp2p/p2p/protocol/identify"
 	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
 	"github.com/libp2p/go-libp2p/p2p/security/noise"
 	sectls "github.com/libp2p/go-libp2p/p2p/security/tls"
@@ -257,7 +262,7 @@ func TestTransportConstructorWithWrongOpts(t *testing.T) {
 		Transport(quic.NewTransport, tcp.DisableReuseport()),
 		DisableRelay(),
 	)
-	require.EqualError(t, err, "transport constructor doesn't take any options")
+	require.EqualError(t, err, "transport option of type tcp.Option not assignable to libp2pquic.Option")
 }
 
 func TestSecurityConstructor(t *testing.T) {
@@ -413,6 +418,15 @@ func TestInsecureConstructor(t *testing.T) {
 	h.Close()
 }
 
+func TestUpgraderOpts(t *testing.T) {
+	h, err := New(
+		Transport(tcp.NewTCPTransport),
+		UpgraderOpts(tptu.WithProtocolPreferences(tptu.ProtocolPreference{Muxers: []protocol.ID{yamux.ID}})),
+	)
+	require.NoError(t, err)
+	h.Close()
+}
+
 func TestAutoNATv2Service(t *testing.T) {
 	h, err := New(EnableAutoNATv2())
 	require.NoError(t, err)
@@ -425,6 +439,33 @@ func TestDisableIdentifyAddressDiscovery(t *testing.T) {
 	h.Close()
 }
 
+func TestDisableBuiltinServices(t *testing.T) {
+	h, err := New(EnableAutoNATv2())
+	require.NoError(t, err)
+	services := bhost.RunningServices(h)
+	h.Close()
+	require.Contains(t, services, bhost.ServiceIdentifyPush)
+	require.Contains(t, services, bhost.ServiceRelayStop)
+	require.Contains(t, services, bhost.ServiceAutoNATv2Server)
+
+	h, err = New(
+		EnableAutoNATv2(),
+		DisableAutoNATv2Server(),
+		DisableIdentifyPush(),
+		DisableRelayStopHandler(),
+	)
+	require.NoError(t, err)
+	defer h.Close()
+	services = bhost.RunningServices(h)
+	require.Contains(t, services, bhost.ServiceIdentify)
+	require.Contains(t, services, bhost.ServicePing)
+	require.Contains(t, services, bhost.ServiceAutoNATv2Client)
+	require.NotContains(t, services, bhost.ServiceIdentifyPush)
+	require.NotContains(t, services, bhost.ServiceRelayStop)
+	require.NotContains(t, services, bhost.ServiceAutoNATv2Server)
+	require.NotContains(t, h.Mux().Protocols(), identify.IDDelta)
+}
+
 func TestMain(m *testing.M) {
 	goleak.VerifyTestMain(
 		m,
@@ -289,6 +289,16 @@ func DisableRelay() Option {
 	}
 }
 
+// DisableRelayStopHandler configures the relay transport to not accept
+// inbound connections through relays. Outbound connections through relays can
+// still be made. It has no effect if the relay transport is disabled.
+func DisableRelayStopHandler() Option {
+	return func(cfg *Config) error {
+		cfg.DisableRelayStopHandler = true
+		return nil
+	}
+}
+
 // EnableRelayService configures libp2p to run a circuit v2 relay,
 // if we detect that we're publicly reachable.
 func EnableRelayService(opts ...relayv2.Option) Option {
@@ -493,6 +503,25 @@ func UserAgent(userAgent string) Option {
 	}
 }
 
+// UserAgentFunc sets a function providing the libp2p user-agent sent along with
+// the identify protocol. It is called every time the user-agent is sent, which
+// allows it to change at runtime. Peers learn about changes the next time they
+// identify us, or when we push updated identify information.
+func UserAgentFunc(f func() string) Option {
+	return func(cfg *Config) error {
+		cfg.UserAgentFunc = f
+		return nil
+	}
+}
+
+// OmitUserAgent stops sending the user-agent along with the identify protocol.
+func OmitUserAgent() Option {
+	return func(cfg *Config) error {
+		cfg.OmitUserAgent = true
+		return nil
+	}
+}
+
 // MultiaddrResolver sets the libp2p dns resolver
 func MultiaddrResolver(rslv network.MultiaddrDNSResolver) Option {
 	return func(cfg *Config) error {
@@ -550,6 +579,18 @@ func WithDialTimeout(t time.Duration) Option {
 	}
 }
 
+// WithDialTimeoutSchedule configures the timeout applied to each individual
+// address dial, e.g. to use shorter timeouts for private addresses and longer
+// ones for relayed addresses. See swarm.DialTimeoutsByClass for a schedule
+// based on address classes.
+// Dials that time out are reported with a swarm.AddrDialTimeoutError.
+func WithDialTimeoutSchedule(s swarm.DialTimeoutSchedule) Option {
+	return func(cfg *Config) error {
+		cfg.DialTimeoutSchedule = s
+		return nil
+	}
+}
+
 // DisableMetrics configures libp2p to disable prometheus metrics
 func DisableMetrics() Option {
 	return func(cfg *Config) error {
@@ -598,6 +639,14 @@ func SwarmOpts(opts ...swarm.Option) Option {
 	}
 }
 
+// UpgraderOpts configures libp2p to use the transport upgrader with opts
+func UpgraderOpts(opts ...tptu.Option) Option {
+	return func(cfg *Config) error {
+		cfg.UpgraderOpts = append(cfg.UpgraderOpts, opts...)
+		return nil
+	}
+}
+
 // DisableIdentifyAddressDiscovery disables address discovery using peer provided observed addresses
 // in identify. If you know your public addresses upfront, the recommended way is to use
 // AddressFactory to provide the external adddress to the host and use this option to disable
@@ -609,6 +658,68 @@ func DisableIdentifyAddressDiscovery() Option {
 	}
 }
 
+// DisableIdentifyPush disables the identify push and identify delta protocols.
+// The host doesn't push updates of its addresses and protocols to its peers,
+// and doesn't accept pushes from them. Identify itself keeps running.
+func DisableIdentifyPush() Option {
+	return func(cfg *Config) error {
+		cfg.DisableIdentifyPush = true
+		return nil
+	}
+}
+
+// RequireSignedPeerRecords only accepts the addresses learned via identify from
+// peers that send a valid signed peer record. During a migration, allowUnsigned
+// can be used to still accept unsigned addresses from some peers, e.g. based on
+// their agent version. It may be nil.
+func RequireSignedPeerRecords(allowUnsigned func(p peer.ID, agentVersion string) bool) Option {
+	return func(cfg *Config) error {
+		cfg.RequireSignedPeerRecords = true
+		cfg.AllowUnsignedAddrs = allowUnsigned
+		return nil
+	}
+}
+
+// IdentifyPushCoalescing delays identify pushes by window after the host's
+// addresses or protocols changed, so that a burst of changes results in a
+// single push to every peer.
+func IdentifyPushCoalescing(window time.Duration) Option {
+	return func(cfg *Config) error {
+		if window < 0 {
+			return errors.New("identify push coalescing window must not be negative")
+		}
+		cfg.IdentifyPushCoalesceWindow = window
+		return nil
+	}
+}
+
+// IdentifyPushRateLimit sets the minimum interval between two identify pushes
+// sent on a connection.
+func IdentifyPushRateLimit(minInterval time.Duration) Option {
+	return func(cfg *Config) error {
+		if minInterval < 0 {
+			return errors.New("identify push rate limit must not be negative")
+		}
+		cfg.IdentifyPushMinInterval = minInterval
+		return nil
+	}
+}
+
+// RelayPolicy configures whether relayed connections may be used for the
+// streams opened by the host, depending on the peer and the requested
+// protocols. For example, it can forbid relays for bulk transfers, while
+// allowing them for control messages.
+//
+// The policy doesn't apply to streams opened with a context that sets a relay
+// policy using network.WithRelayPolicy. To apply a policy to all dials, use
+// SwarmOpts(swarm.WithRelayPolicy(...)).
+func RelayPolicy(policy func(peer.ID, []protocol.ID) network.RelayPolicy) Option {
+	return func(cfg *Config) error {
+		cfg.RelayPolicy = policy
+		return nil
+	}
+}
+
 // EnableAutoNATv2 enables autonat v2
 func EnableAutoNATv2() Option {
 	return func(cfg *Config) error {
@@ -617,6 +728,16 @@ func EnableAutoNATv2() Option {
 	}
 }
 
+// DisableAutoNATv2Server disables the autonat v2 server, while still using
+// the autonat v2 client to verify our own addresses. It has no effect unless
+// autonat v2 is enabled.
+func DisableAutoNATv2Server() Option {
+	return func(cfg *Config) error {
+		cfg.DisableAutoNATv2Server = true
+		return nil
+	}
+}
+
 // UDPBlackHoleSuccessCounter configures libp2p to use f as the black hole filter for UDP addrs
 func UDPBlackHoleSuccessCounter(f *swarm.BlackHoleSuccessCounter) Option {
 	return func(cfg *Config) error {
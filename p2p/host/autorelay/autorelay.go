@@ -99,6 +99,12 @@ func (r *AutoRelay) background() {
 	}
 }
 
+// RotateRelayAddrs advertises the addresses of the next relays, when only the addresses of some of
+// the relays are advertised. See WithRelayAddrRotation.
+func (r *AutoRelay) RotateRelayAddrs() {
+	r.relayFinder.rotateRelayAddrs()
+}
+
 func (r *AutoRelay) Close() error {
 	r.ctxCancel()
 	err := r.relayFinder.Stop()
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"slices"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -176,6 +177,86 @@ func TestSingleRelay(t *testing.T) {
 	require.Never(t, func() bool { return numRelays(h) > 1 }, 200*time.Millisecond, 50*time.Millisecond)
 }
 
+func TestScoredCandidates(t *testing.T) {
+	const numCandidates = 3
+	relays := make([]host.Host, 0, numCandidates)
+	peerChan := make(chan autorelay.ScoredCandidate, numCandidates)
+	for i, score := range []float64{1, 3, 2} {
+		r := newRelay(t)
+		t.Cleanup(func() { r.Close() })
+		relays = append(relays, r)
+		peerChan <- autorelay.ScoredCandidate{
+			AddrInfo: peer.AddrInfo{ID: r.ID(), Addrs: r.Addrs()},
+			Score:    score,
+			Labels:   map[string]string{"index": fmt.Sprint(i)},
+		}
+	}
+	close(peerChan)
+
+	h, err := libp2p.New(
+		libp2p.ForceReachabilityPrivate(),
+		libp2p.EnableAutoRelay(
+			autorelay.WithScoredPeerSource(func(context.Context, int) <-chan autorelay.ScoredCandidate { return peerChan }),
+			autorelay.WithMinCandidates(numCandidates),
+			autorelay.WithMaxCandidates(numCandidates),
+			autorelay.WithNumRelays(1),
+			autorelay.WithBootDelay(time.Hour),
+			autorelay.WithMinInterval(time.Hour),
+		),
+	)
+	require.NoError(t, err)
+	defer h.Close()
+
+	// The candidate with the highest score is selected.
+	require.Eventually(t, func() bool { return numRelays(h) > 0 }, 5*time.Second, 100*time.Millisecond)
+	require.Equal(t, []peer.ID{relays[1].ID()}, usedRelays(h))
+
+	_, err = libp2p.New(libp2p.EnableAutoRelay(
+		autorelay.WithScoredPeerSource(func(context.Context, int) <-chan autorelay.ScoredCandidate { return nil }),
+		autorelay.WithStaticRelays(nil),
+	))
+	require.Error(t, err)
+}
+
+func TestScoredCandidatesEviction(t *testing.T) {
+	peerChan := make(chan autorelay.ScoredCandidate)
+	h, err := libp2p.New(
+		libp2p.ForceReachabilityPrivate(),
+		libp2p.EnableAutoRelay(
+			autorelay.WithScoredPeerSource(func(context.Context, int) <-chan autorelay.ScoredCandidate { return peerChan }),
+			autorelay.WithMinCandidates(1),
+			autorelay.WithMaxCandidates(2),
+			autorelay.WithNumRelays(1),
+			autorelay.WithBootDelay(0),
+			autorelay.WithMinInterval(time.Hour),
+		),
+	)
+	require.NoError(t, err)
+	defer h.Close()
+
+	send := func(score float64) host.Host {
+		r := newRelay(t)
+		t.Cleanup(func() { r.Close() })
+		peerChan <- autorelay.ScoredCandidate{AddrInfo: peer.AddrInfo{ID: r.ID(), Addrs: r.Addrs()}, Score: score}
+		return r
+	}
+	r1 := send(5)
+	require.Eventually(t, func() bool { return numRelays(h) > 0 }, 5*time.Second, 100*time.Millisecond)
+
+	// The candidate scored higher than the ones we have evicts the lowest scored one.
+	send(1)
+	send(1)
+	r4 := send(3)
+	require.Eventually(t, func() bool { return h.Network().Connectedness(r4.ID()) == network.Connected }, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+
+	r1.Close()
+	require.Eventually(t, func() bool {
+		relays := usedRelays(h)
+		return len(relays) == 1 && relays[0] == r4.ID()
+	}, 10*time.Second, 100*time.Millisecond)
+}
+
 func TestWaitForCandidates(t *testing.T) {
 	peerChan := make(chan peer.AddrInfo)
 	h := newPrivateNode(t,
@@ -290,6 +371,35 @@ func TestStaticRelays(t *testing.T) {
 	require.Eventually(t, func() bool { return numRelays(h) > 0 }, 10*time.Second, 50*time.Millisecond)
 }
 
+func TestBaselineRelays(t *testing.T) {
+	baseline := newRelay(t)
+	t.Cleanup(func() { baseline.Close() })
+
+	const numCandidates = 2
+	peerChan := make(chan peer.AddrInfo, numCandidates)
+	for i := 0; i < numCandidates; i++ {
+		r := newRelay(t)
+		t.Cleanup(func() { r.Close() })
+		peerChan <- peer.AddrInfo{ID: r.ID(), Addrs: r.Addrs()}
+	}
+	close(peerChan)
+
+	h := newPrivateNode(t,
+		func(context.Context, int) <-chan peer.AddrInfo { return peerChan },
+		autorelay.WithBaselineRelays([]peer.AddrInfo{{ID: baseline.ID(), Addrs: baseline.Addrs()}}),
+		autorelay.WithMinCandidates(numCandidates),
+		autorelay.WithNumRelays(2),
+		autorelay.WithBootDelay(time.Hour),
+		autorelay.WithMinInterval(time.Hour),
+	)
+	defer h.Close()
+
+	// The peer source fills the slot left by the baseline relay.
+	require.Eventually(t, func() bool { return numRelays(h) == 2 }, 10*time.Second, 50*time.Millisecond)
+	require.Contains(t, usedRelays(h), baseline.ID())
+	require.Never(t, func() bool { return numRelays(h) > 2 }, 200*time.Millisecond, 50*time.Millisecond)
+}
+
 func TestConnectOnDisconnect(t *testing.T) {
 	const num = 3
 	peerChan := make(chan peer.AddrInfo, num)
@@ -601,3 +711,145 @@ func TestAutoRelayAddrsEvent(t *testing.T) {
 	case <-time.After(1 * time.Second):
 	}
 }
+
+func TestReservationEvents(t *testing.T) {
+	r := newRelay(t)
+	t.Cleanup(func() { r.Close() })
+
+	h := newPrivateNodeWithStaticRelays(t, []peer.AddrInfo{{ID: r.ID(), Addrs: r.Addrs()}})
+	defer h.Close()
+
+	sub, err := h.EventBus().Subscribe(new(event.EvtRelayReservation))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	next := func() event.EvtRelayReservation {
+		t.Helper()
+		select {
+		case e := <-sub.Out():
+			return e.(event.EvtRelayReservation)
+		case <-time.After(10 * time.Second):
+			t.Fatal("timeout waiting for reservation event")
+			return event.EvtRelayReservation{}
+		}
+	}
+
+	evt := next()
+	require.Equal(t, event.RelayReservationObtained, evt.Type)
+	require.Equal(t, r.ID(), evt.Relay)
+	require.True(t, evt.Expiration.After(time.Now()))
+
+	r.Network().ClosePeer(h.ID())
+	evt = next()
+	require.Equal(t, event.RelayReservationEvicted, evt.Type)
+	require.Equal(t, r.ID(), evt.Relay)
+}
+
+func TestRelayAddrRotation(t *testing.T) {
+	cl := newMockClock()
+	const numStaticRelays = 3
+	var staticRelays []peer.AddrInfo
+	for i := 0; i < numStaticRelays; i++ {
+		r := newRelay(t)
+		t.Cleanup(func() { r.Close() })
+		staticRelays = append(staticRelays, peer.AddrInfo{ID: r.ID(), Addrs: r.Addrs()})
+	}
+
+	h := newPrivateNodeWithStaticRelays(t,
+		staticRelays,
+		autorelay.WithClock(cl),
+		autorelay.WithRelayAddrRotation(1, time.Minute),
+	)
+	defer h.Close()
+
+	// All relays are advertised in turn, but only one at a time.
+	seen := make(map[peer.ID]struct{})
+	require.Eventually(t, func() bool {
+		relays := usedRelays(h)
+		require.LessOrEqual(t, len(relays), 1)
+		for _, p := range relays {
+			seen[p] = struct{}{}
+		}
+		cl.AdvanceBy(time.Minute)
+		return len(seen) == numStaticRelays
+	}, 10*time.Second, 100*time.Millisecond)
+}
+
+func TestRTTSelection(t *testing.T) {
+	cl := newMockClock()
+	const numCandidates = 3
+	relays := make([]host.Host, 0, numCandidates)
+	peerChan := make(chan peer.AddrInfo, numCandidates)
+	for range numCandidates {
+		r := newRelay(t)
+		t.Cleanup(func() { r.Close() })
+		relays = append(relays, r)
+		peerChan <- peer.AddrInfo{ID: r.ID(), Addrs: r.Addrs()}
+	}
+	close(peerChan)
+
+	var mx sync.Mutex
+	rtts := map[peer.ID]time.Duration{
+		relays[0].ID(): 10 * time.Millisecond,
+		relays[1].ID(): 50 * time.Millisecond,
+		// relays[2] doesn't answer the probes
+	}
+	probe := func(_ context.Context, _ host.Host, p peer.ID) (time.Duration, error) {
+		mx.Lock()
+		defer mx.Unlock()
+		rtt, ok := rtts[p]
+		if !ok {
+			return 0, fmt.Errorf("timeout")
+		}
+		return rtt, nil
+	}
+
+	h := newPrivateNode(t,
+		func(context.Context, int) <-chan peer.AddrInfo { return peerChan },
+		autorelay.WithClock(cl),
+		autorelay.WithMinCandidates(numCandidates-1),
+		autorelay.WithMaxCandidates(numCandidates),
+		autorelay.WithNumRelays(1),
+		autorelay.WithBootDelay(time.Hour),
+		autorelay.WithMinInterval(time.Hour),
+		autorelay.WithRTTSelection(&autorelay.RTTSelection{Probe: probe, Interval: time.Minute}),
+	)
+	defer h.Close()
+	sub, err := h.EventBus().Subscribe(new(event.EvtRelayReservation))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	// The fastest candidate is selected.
+	require.Eventually(t, func() bool { return numRelays(h) > 0 }, 5*time.Second, 100*time.Millisecond)
+	require.Equal(t, []peer.ID{relays[0].ID()}, usedRelays(h))
+
+	// A slightly faster candidate doesn't cause a migration.
+	mx.Lock()
+	rtts[relays[0].ID()] = 60 * time.Millisecond
+	mx.Unlock()
+	for range 3 {
+		cl.AdvanceBy(time.Minute)
+		time.Sleep(50 * time.Millisecond)
+	}
+	require.Equal(t, []peer.ID{relays[0].ID()}, usedRelays(h))
+
+	// The reservation is migrated to a much faster candidate.
+	mx.Lock()
+	rtts[relays[0].ID()] = 200 * time.Millisecond
+	rtts[relays[1].ID()] = 10 * time.Millisecond
+	mx.Unlock()
+	require.Eventually(t, func() bool {
+		cl.AdvanceBy(time.Minute)
+		used := usedRelays(h)
+		return len(used) == 1 && used[0] == relays[1].ID()
+	}, 5*time.Second, 100*time.Millisecond)
+	require.Eventually(t, func() bool {
+		select {
+		case e := <-sub.Out():
+			evt := e.(event.EvtRelayReservation)
+			return evt.Type == event.RelayReservationMigrated && evt.Relay == relays[0].ID()
+		default:
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -2,7 +2,9 @@ package autorelay
 
 import (
 	"errors"
+	"time"
 
+	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/p2p/metricshelper"
 	"github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/client"
 	pbv2 "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/pb"
@@ -96,6 +98,47 @@ var (
 		},
 	)
 
+	candidatesReceivedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "candidates_received_total",
+			Help:      "Candidates Received from the Peer Source",
+		},
+	)
+	candidatesRejectedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "candidates_rejected_total",
+			Help:      "Candidates Rejected",
+		},
+		[]string{"reason"},
+	)
+	reservationLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Name:      "reservation_latency_seconds",
+			Help:      "Reservation Request Latency",
+			Buckets:   []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10},
+		},
+		[]string{"request_type"},
+	)
+	reservationsCount = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Name:      "reservations",
+			Help:      "Current Reservations",
+		},
+	)
+
+	reservationEventsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "reservation_events_total",
+			Help:      "Reservation Lifecycle Events",
+		},
+		[]string{"event"},
+	)
+
 	collectors = []prometheus.Collector{
 		status,
 		reservationsOpenedTotal,
@@ -108,6 +151,11 @@ var (
 		candLoopState,
 		scheduledWorkTime,
 		desiredReservations,
+		reservationEventsTotal,
+		candidatesReceivedTotal,
+		candidatesRejectedTotal,
+		reservationLatency,
+		reservationsCount,
 	}
 )
 
@@ -120,6 +168,70 @@ const (
 	stopped
 )
 
+// RejectReason is the reason a candidate was rejected.
+type RejectReason int
+
+const (
+	// RejectBackoff is for a candidate we recently failed to obtain a reservation with.
+	RejectBackoff RejectReason = iota
+	// RejectMaxCandidates is for a candidate received while we have enough candidates.
+	RejectMaxCandidates
+	// RejectConnectionFailed is for a candidate we failed to connect to.
+	RejectConnectionFailed
+	// RejectNotPublic is for a candidate we're connected to over a relay.
+	RejectNotPublic
+	// RejectProtocolUnsupported is for a candidate that doesn't support circuit v2.
+	RejectProtocolUnsupported
+	// RejectNoReservationSlot is for a candidate that refused the reservation.
+	RejectNoReservationSlot
+	// RejectUnresponsive is for a candidate that didn't answer any RTT probe.
+	RejectUnresponsive
+	// RejectOther is for a candidate rejected for any other reason.
+	RejectOther
+)
+
+func (r RejectReason) String() string {
+	switch r {
+	case RejectBackoff:
+		return "backoff"
+	case RejectMaxCandidates:
+		return "max candidates"
+	case RejectConnectionFailed:
+		return "connection failed"
+	case RejectNotPublic:
+		return "not public"
+	case RejectProtocolUnsupported:
+		return "protocol unsupported"
+	case RejectNoReservationSlot:
+		return "no reservation slot"
+	case RejectUnresponsive:
+		return "unresponsive"
+	default:
+		return "other"
+	}
+}
+
+func getRejectReason(err error) RejectReason {
+	switch {
+	case errors.Is(err, errConnectToRelay):
+		return RejectConnectionFailed
+	case errors.Is(err, errNotPublicNode):
+		return RejectNotPublic
+	case errors.Is(err, errProtocolNotSupported):
+		return RejectProtocolUnsupported
+	}
+	var re client.ReservationError
+	if errors.As(err, &re) {
+		switch re.Status {
+		case pbv2.Status_RESERVATION_REFUSED, pbv2.Status_RESOURCE_LIMIT_EXCEEDED, pbv2.Status_PERMISSION_DENIED:
+			return RejectNoReservationSlot
+		case pbv2.Status_CONNECTION_FAILED:
+			return RejectConnectionFailed
+		}
+	}
+	return RejectOther
+}
+
 // MetricsTracer is the interface for tracking metrics for autorelay
 type MetricsTracer interface {
 	RelayFinderStatus(isActive bool)
@@ -141,9 +253,27 @@ type MetricsTracer interface {
 	DesiredReservations(int)
 }
 
+// ReservationMetricsTracer can be implemented by a MetricsTracer to track the
+// reservation lifecycle events, the reservation latency and the number of
+// reservations.
+type ReservationMetricsTracer interface {
+	ReservationEvent(t event.RelayReservationEventType)
+	ReservationLatency(isRefresh bool, d time.Duration)
+	ReservationCount(cnt int)
+}
+
+// CandidateMetricsTracer can be implemented by a MetricsTracer to count the
+// relay candidates received and the candidates rejected, by reason.
+type CandidateMetricsTracer interface {
+	CandidateReceived()
+	CandidateRejected(reason RejectReason)
+}
+
 type metricsTracer struct{}
 
 var _ MetricsTracer = &metricsTracer{}
+var _ ReservationMetricsTracer = &metricsTracer{}
+var _ CandidateMetricsTracer = &metricsTracer{}
 
 type metricsTracerSetting struct {
 	reg prometheus.Registerer
@@ -208,6 +338,30 @@ func (mt *metricsTracer) ReservationRequestFinished(isRefresh bool, err error) {
 	}
 }
 
+func (mt *metricsTracer) ReservationEvent(t event.RelayReservationEventType) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	*tags = append(*tags, t.String())
+	reservationEventsTotal.WithLabelValues(*tags...).Inc()
+}
+
+func (mt *metricsTracer) ReservationLatency(isRefresh bool, d time.Duration) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	if isRefresh {
+		*tags = append(*tags, "refresh")
+	} else {
+		*tags = append(*tags, "new")
+	}
+	reservationLatency.WithLabelValues(*tags...).Observe(d.Seconds())
+}
+
+func (mt *metricsTracer) ReservationCount(cnt int) {
+	reservationsCount.Set(float64(cnt))
+}
+
 func (mt *metricsTracer) RelayAddressUpdated() {
 	relayAddressesUpdatedTotal.Inc()
 }
@@ -245,6 +399,18 @@ func (mt *metricsTracer) CandidateLoopState(state candidateLoopState) {
 	candLoopState.Set(float64(state))
 }
 
+func (mt *metricsTracer) CandidateReceived() {
+	candidatesReceivedTotal.Inc()
+}
+
+func (mt *metricsTracer) CandidateRejected(reason RejectReason) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	*tags = append(*tags, reason.String())
+	candidatesRejectedTotal.WithLabelValues(*tags...).Inc()
+}
+
 func (mt *metricsTracer) ScheduledWorkUpdated(scheduledWork *scheduledWorkTimes) {
 	tags := metricshelper.GetStringSlice()
 	defer metricshelper.PutStringSlice(tags)
@@ -299,6 +465,8 @@ type wrappedMetricsTracer struct {
 }
 
 var _ MetricsTracer = &wrappedMetricsTracer{}
+var _ ReservationMetricsTracer = &wrappedMetricsTracer{}
+var _ CandidateMetricsTracer = &wrappedMetricsTracer{}
 
 func (mt *wrappedMetricsTracer) RelayFinderStatus(isActive bool) {
 	if mt.mt != nil {
@@ -324,6 +492,24 @@ func (mt *wrappedMetricsTracer) ReservationRequestFinished(isRefresh bool, err e
 	}
 }
 
+func (mt *wrappedMetricsTracer) ReservationEvent(t event.RelayReservationEventType) {
+	if rmt, ok := mt.mt.(ReservationMetricsTracer); ok {
+		rmt.ReservationEvent(t)
+	}
+}
+
+func (mt *wrappedMetricsTracer) ReservationLatency(isRefresh bool, d time.Duration) {
+	if rmt, ok := mt.mt.(ReservationMetricsTracer); ok {
+		rmt.ReservationLatency(isRefresh, d)
+	}
+}
+
+func (mt *wrappedMetricsTracer) ReservationCount(cnt int) {
+	if rmt, ok := mt.mt.(ReservationMetricsTracer); ok {
+		rmt.ReservationCount(cnt)
+	}
+}
+
 func (mt *wrappedMetricsTracer) RelayAddressUpdated() {
 	if mt.mt != nil {
 		mt.mt.RelayAddressUpdated()
@@ -371,3 +557,15 @@ func (mt *wrappedMetricsTracer) CandidateLoopState(state candidateLoopState) {
 		mt.mt.CandidateLoopState(state)
 	}
 }
+
+func (mt *wrappedMetricsTracer) CandidateReceived() {
+	if cmt, ok := mt.mt.(CandidateMetricsTracer); ok {
+		cmt.CandidateReceived()
+	}
+}
+
+func (mt *wrappedMetricsTracer) CandidateRejected(reason RejectReason) {
+	if cmt, ok := mt.mt.(CandidateMetricsTracer); ok {
+		cmt.CandidateRejected(reason)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/client"
 	pbv2 "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/pb"
 )
@@ -39,6 +40,7 @@ func TestMetricsNoAllocNoCover(t *testing.T) {
 		"RelayFinderStatus":          func() { tr.RelayFinderStatus(rand.Intn(2) == 1) },
 		"ReservationEnded":           func() { tr.ReservationEnded(rand.Intn(10)) },
 		"ReservationRequestFinished": func() { tr.ReservationRequestFinished(rand.Intn(2) == 1, errs[rand.Intn(len(errs))]) },
+		"ReservationEvent":           func() { tr.(ReservationMetricsTracer).ReservationEvent(event.RelayReservationEventType(rand.Intn(6))) },
 		"RelayAddressCount":          func() { tr.RelayAddressCount(rand.Intn(10)) },
 		"RelayAddressUpdated":        func() { tr.RelayAddressUpdated() },
 		"ReservationOpened":          func() { tr.ReservationOpened(rand.Intn(10)) },
@@ -48,6 +50,12 @@ func TestMetricsNoAllocNoCover(t *testing.T) {
 		"ScheduledWorkUpdated":       func() { tr.ScheduledWorkUpdated(&scheduledWork[rand.Intn(len(scheduledWork))]) },
 		"DesiredReservations":        func() { tr.DesiredReservations(rand.Intn(10)) },
 		"CandidateLoopState":         func() { tr.CandidateLoopState(candidateLoopState(rand.Intn(10))) },
+		"CandidateReceived":          func() { tr.(CandidateMetricsTracer).CandidateReceived() },
+		"CandidateRejected":          func() { tr.(CandidateMetricsTracer).CandidateRejected(RejectReason(rand.Intn(8))) },
+		"ReservationLatency": func() {
+			tr.(ReservationMetricsTracer).ReservationLatency(rand.Intn(2) == 1, time.Duration(rand.Intn(1000))*time.Millisecond)
+		},
+		"ReservationCount": func() { tr.(ReservationMetricsTracer).ReservationCount(rand.Intn(10)) },
 	}
 	for method, f := range tests {
 		allocs := testing.AllocsPerRun(1000, f)
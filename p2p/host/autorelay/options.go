@@ -21,9 +21,49 @@ import (
 // channel at some point.
 type PeerSource func(ctx context.Context, num int) <-chan peer.AddrInfo
 
+// ScoredCandidate is a relay candidate, along with the preference of the source that found it.
+type ScoredCandidate struct {
+	peer.AddrInfo
+	// Score is the preference for the candidate. AutoRelay attempts to obtain reservations with
+	// the candidates with the highest scores first. Candidates with the same score are tried in
+	// random order.
+	Score float64
+	// Labels describe the candidate, e.g. its region or why it's trusted. They are not
+	// interpreted by AutoRelay.
+	Labels map[string]string
+}
+
+// ScoredPeerSource is a PeerSource that scores the candidates it finds, e.g. by latency, region
+// or trust. It has the same contract as PeerSource.
+type ScoredPeerSource func(ctx context.Context, num int) <-chan ScoredCandidate
+
+// scored turns a PeerSource into a ScoredPeerSource giving all peers the same score.
+func (f PeerSource) scored() ScoredPeerSource {
+	return func(ctx context.Context, num int) <-chan ScoredCandidate {
+		in := f(ctx, num)
+		out := make(chan ScoredCandidate)
+		go func() {
+			defer close(out)
+			for ai := range in {
+				select {
+				case out <- ScoredCandidate{AddrInfo: ai}:
+				case <-ctx.Done():
+					// The source closes its channel once the context is canceled.
+					for range in {
+					}
+					return
+				}
+			}
+		}()
+		return out
+	}
+}
+
 type config struct {
 	clock      ClockWithInstantTimer
-	peerSource PeerSource
+	peerSource ScoredPeerSource
+	// see WithBaselineRelays
+	baselineRelays []peer.AddrInfo
 	// minimum interval used to call the peerSource callback
 	minInterval time.Duration
 	// see WithMinCandidates
@@ -42,6 +82,11 @@ type config struct {
 	setMinCandidates bool
 	// see WithMetricsTracer
 	metricsTracer MetricsTracer
+	// see WithRelayAddrRotation
+	advertisedRelays int
+	rotationInterval time.Duration
+	// see WithRTTSelection
+	rttSelection *RTTSelection
 }
 
 var defaultConfig = config{
@@ -56,7 +101,7 @@ var defaultConfig = config{
 }
 
 var (
-	errAlreadyHavePeerSource = errors.New("can only use a single WithPeerSource or WithStaticRelays")
+	errAlreadyHavePeerSource = errors.New("can only use a single WithPeerSource, WithScoredPeerSource or WithStaticRelays")
 )
 
 type Option func(*config) error
@@ -89,6 +134,19 @@ func WithStaticRelays(static []peer.AddrInfo) Option {
 
 // WithPeerSource defines a callback for AutoRelay to query for more relay candidates.
 func WithPeerSource(f PeerSource) Option {
+	return func(c *config) error {
+		if c.peerSource != nil {
+			return errAlreadyHavePeerSource
+		}
+		c.peerSource = f.scored()
+		return nil
+	}
+}
+
+// WithScoredPeerSource defines a callback for AutoRelay to query for more relay candidates,
+// along with their scores. AutoRelay prefers the candidates with the highest scores when
+// obtaining reservations.
+func WithScoredPeerSource(f ScoredPeerSource) Option {
 	return func(c *config) error {
 		if c.peerSource != nil {
 			return errAlreadyHavePeerSource
@@ -98,6 +156,17 @@ func WithPeerSource(f PeerSource) Option {
 	}
 }
 
+// WithBaselineRelays sets relays AutoRelay always maintains reservations with, in addition to the
+// relays found by the peer source (see WithPeerSource and WithScoredPeerSource). The baseline
+// relays don't wait for the boot delay, and are used even if that exceeds the number of relays set
+// by WithNumRelays. The peer source fills the remaining slots.
+func WithBaselineRelays(relays []peer.AddrInfo) Option {
+	return func(c *config) error {
+		c.baselineRelays = relays
+		return nil
+	}
+}
+
 // WithNumRelays sets the number of relays we strive to obtain reservations with.
 func WithNumRelays(n int) Option {
 	return func(c *config) error {
@@ -224,6 +293,42 @@ func WithMinInterval(interval time.Duration) Option {
 	}
 }
 
+// WithRelayAddrRotation spreads the inbound connections across the relays we have reservations
+// with. Only the addresses of n relays are advertised at a time, and every interval the next n
+// relays are advertised instead. An interval of 0 disables the periodic rotation; the addresses
+// can still be rotated using AutoRelay.RotateRelayAddrs.
+// By default, the addresses of all relays are advertised.
+func WithRelayAddrRotation(n int, interval time.Duration) Option {
+	return func(c *config) error {
+		if n <= 0 {
+			return errors.New("number of advertised relays must be positive")
+		}
+		if interval < 0 {
+			return errors.New("rotation interval must not be negative")
+		}
+		c.advertisedRelays = n
+		c.rotationInterval = interval
+		return nil
+	}
+}
+
+// WithRTTSelection makes AutoRelay prefer relays with a low round trip time, and migrate
+// reservations to much faster relays. If s is nil, DefaultRTTSelection is used. See RTTSelection.
+func WithRTTSelection(s *RTTSelection) Option {
+	return func(c *config) error {
+		if s == nil {
+			c.rttSelection = DefaultRTTSelection()
+			return nil
+		}
+		rs, err := s.withDefaults()
+		if err != nil {
+			return err
+		}
+		c.rttSelection = rs
+		return nil
+	}
+}
+
 // WithMetricsTracer configures autorelay to use mt to track metrics
 func WithMetricsTracer(mt MetricsTracer) Option {
 	return func(c *config) error {
@@ -2,6 +2,7 @@ package autorelay
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
@@ -31,8 +32,8 @@ const protoIDv2 = circuitv2_proto.ProtoIDv2Hop
 // we call it a candidate, and consider using it as a relay.
 //
 // Relay: Out of the list candidates, the ones we have a reservation with.
-// Currently, we just randomly select a candidate, but we can employ more sophisticated
-// selection strategies here (e.g. by facotring in the RTT).
+// Candidates are selected by the score the peer source gave them, then by RTT
+// if it's measured (see WithRTTSelection), and randomly otherwise.
 
 const (
 	rsvpRefreshInterval = time.Minute
@@ -46,6 +47,10 @@ type candidate struct {
 	added           time.Time
 	supportsRelayV2 bool
 	ai              peer.AddrInfo
+	score           float64
+	labels          map[string]string
+	// rtt is the RTT measured when the candidate was found, if WithRTTSelection is used.
+	rtt time.Duration
 }
 
 // relayFinder is a Host that uses relays for connectivity when a NAT is detected.
@@ -60,7 +65,7 @@ type relayFinder struct {
 	ctxCancel   context.CancelFunc
 	ctxCancelMx sync.Mutex
 
-	peerSource PeerSource
+	peerSource ScoredPeerSource
 
 	candidateFound             chan struct{} // receives every time we find a new relay candidate
 	candidateMx                sync.Mutex
@@ -76,16 +81,27 @@ type relayFinder struct {
 
 	relayReservationUpdated chan struct{}
 
+	// connectMx serializes obtaining reservations with candidates, so that maybeConnectToRelay and
+	// the migration to faster relays don't both obtain a reservation with the same candidate.
+	connectMx sync.Mutex
+
 	relayMx sync.Mutex
 	relays  map[peer.ID]*circuitv2.Reservation
+	// relayScores are the scores of the relays obtained from the candidates of the peer source.
+	// The baseline relays are not included.
+	relayScores map[peer.ID]float64
+	// rotation is the offset of the first relay whose addresses are advertised, when only the
+	// addresses of some of the relays are advertised.
+	rotation int
 
 	circuitAddrs []ma.Multiaddr
 
 	// A channel that triggers a run of `runScheduledWork`.
 	triggerRunScheduledWork chan struct{}
-	metricsTracer           MetricsTracer
+	metricsTracer           *wrappedMetricsTracer
 
-	emitter event.Emitter
+	emitter     event.Emitter
+	rsvpEmitter event.Emitter
 }
 
 var errAlreadyRunning = errors.New("relayFinder already running")
@@ -99,6 +115,10 @@ func newRelayFinder(host host.Host, conf *config) (*relayFinder, error) {
 	if err != nil {
 		return nil, err
 	}
+	rsvpEmitter, err := host.EventBus().Emitter(new(event.EvtRelayReservation))
+	if err != nil {
+		return nil, err
+	}
 
 	return &relayFinder{
 		bootTime:                   conf.clock.Now(),
@@ -112,9 +132,11 @@ func newRelayFinder(host host.Host, conf *config) (*relayFinder, error) {
 		maybeRequestNewCandidates:  make(chan struct{}, 1),
 		triggerRunScheduledWork:    make(chan struct{}, 1),
 		relays:                     make(map[peer.ID]*circuitv2.Reservation),
+		relayScores:                make(map[peer.ID]float64),
 		relayReservationUpdated:    make(chan struct{}, 1),
 		metricsTracer:              &wrappedMetricsTracer{conf.metricsTracer},
 		emitter:                    emitter,
+		rsvpEmitter:                rsvpEmitter,
 	}, nil
 }
 
@@ -145,21 +167,21 @@ func (rf *relayFinder) cleanupDisconnectedPeers(ctx context.Context) {
 			if evt.Connectedness != network.NotConnected {
 				continue
 			}
-			push := false
-
 			rf.relayMx.Lock()
-			if rf.usingRelay(evt.Peer) { // we were disconnected from a relay
+			rsvp, push := rf.relays[evt.Peer]
+			if push { // we were disconnected from a relay
 				log.Debugw("disconnected from relay", "id", evt.Peer)
 				delete(rf.relays, evt.Peer)
+				delete(rf.relayScores, evt.Peer)
 				rf.notifyMaybeConnectToRelay()
 				rf.notifyMaybeNeedNewCandidates()
-				push = true
 			}
 			rf.relayMx.Unlock()
 
 			if push {
 				rf.notifyRelayReservationUpdated()
 				rf.metricsTracer.ReservationEnded(1)
+				rf.emitReservationEvent(event.RelayReservationEvicted, evt.Peer, rsvp.Expiration, nil)
 			}
 		}
 	}
@@ -207,10 +229,32 @@ func (rf *relayFinder) background(ctx context.Context) {
 	workTimer := rf.conf.clock.InstantTimer(rf.runScheduledWork(ctx, now, scheduledWork, peerSourceRateLimiter))
 	defer workTimer.Stop()
 
+	// rotationCh stays nil if the relay addresses are not rotated periodically.
+	var (
+		rotationTimer InstantTimer
+		rotationCh    <-chan time.Time
+	)
+	if rf.conf.advertisedRelays > 0 && rf.conf.rotationInterval > 0 {
+		rotationTimer = rf.conf.clock.InstantTimer(now.Add(rf.conf.rotationInterval))
+		defer rotationTimer.Stop()
+		rotationCh = rotationTimer.Ch()
+	}
+
 	go rf.cleanupDisconnectedPeers(ctx)
 
+	if rf.conf.rttSelection != nil && rf.conf.rttSelection.Interval > 0 {
+		rf.refCount.Add(1)
+		go func() {
+			defer rf.refCount.Done()
+			rf.reevaluateRelays(ctx)
+		}()
+	}
+
 	// update addrs on starting the relay finder.
 	rf.updateAddrs()
+	if len(rf.conf.baselineRelays) > 0 {
+		rf.notifyMaybeConnectToRelay()
+	}
 	for {
 		select {
 		case <-rf.candidateFound:
@@ -219,6 +263,12 @@ func (rf *relayFinder) background(ctx context.Context) {
 			rf.notifyMaybeConnectToRelay()
 		case <-rf.relayReservationUpdated:
 			rf.updateAddrs()
+			rf.relayMx.Lock()
+			rf.metricsTracer.ReservationCount(len(rf.relays))
+			rf.relayMx.Unlock()
+		case now := <-rotationCh:
+			rf.rotateRelayAddrs()
+			rotationTimer.Reset(now.Add(rf.conf.rotationInterval))
 		case now := <-workTimer.Ch():
 			// Note: `now` is not guaranteed to be the current time. It's the time
 			// that the timer was fired. This is okay because we'll schedule
@@ -254,24 +304,63 @@ func (rf *relayFinder) getCircuitAddrs() []ma.Multiaddr {
 	rf.relayMx.Lock()
 	defer rf.relayMx.Unlock()
 
-	raddrs := make([]ma.Multiaddr, 0, 4*len(rf.relays)+4)
-	for p := range rf.relays {
+	relayAddrs := make([][]ma.Multiaddr, 0, len(rf.relays))
+	var n int
+	for _, p := range rf.advertisedRelaysUnlocked() {
 		addrs := cleanupAddressSet(rf.host.Peerstore().Addrs(p))
 		circuit := ma.StringCast(fmt.Sprintf("/p2p/%s/p2p-circuit", p))
-		for _, addr := range addrs {
-			pub := addr.Encapsulate(circuit)
-			raddrs = append(raddrs, pub)
+		for i, addr := range addrs {
+			addrs[i] = addr.Encapsulate(circuit)
+		}
+		relayAddrs = append(relayAddrs, addrs)
+		n += len(addrs)
+	}
+
+	// Take the addresses of the relays in turn, so that all relays are advertised even if we
+	// have to drop some addresses.
+	raddrs := make([]ma.Multiaddr, 0, min(n, maxRelayAddrs))
+	for i := 0; len(raddrs) < cap(raddrs); i++ {
+		for _, addrs := range relayAddrs {
+			if i < len(addrs) && len(raddrs) < cap(raddrs) {
+				raddrs = append(raddrs, addrs[i])
+			}
 		}
 	}
 
 	// Sort the addresses. We depend on this order for checking diffs to send address update events.
 	slices.SortStableFunc(raddrs, func(a, b ma.Multiaddr) int { return bytes.Compare(a.Bytes(), b.Bytes()) })
-	if len(raddrs) > maxRelayAddrs {
-		raddrs = raddrs[:maxRelayAddrs]
-	}
 	return raddrs
 }
 
+// advertisedRelaysUnlocked returns the relays whose addresses we advertise. Assumes caller holds
+// relayMx mutex.
+func (rf *relayFinder) advertisedRelaysUnlocked() []peer.ID {
+	relays := make([]peer.ID, 0, len(rf.relays))
+	for p := range rf.relays {
+		relays = append(relays, p)
+	}
+	if rf.conf.advertisedRelays <= 0 || len(relays) <= rf.conf.advertisedRelays {
+		return relays
+	}
+	slices.Sort(relays)
+	advertised := make([]peer.ID, 0, rf.conf.advertisedRelays)
+	for i := range rf.conf.advertisedRelays {
+		advertised = append(advertised, relays[(rf.rotation+i)%len(relays)])
+	}
+	return advertised
+}
+
+// rotateRelayAddrs advertises the addresses of the next relays.
+func (rf *relayFinder) rotateRelayAddrs() {
+	if rf.conf.advertisedRelays <= 0 {
+		return
+	}
+	rf.relayMx.Lock()
+	rf.rotation = (rf.rotation + rf.conf.advertisedRelays) % max(len(rf.relays), 1)
+	rf.relayMx.Unlock()
+	rf.notifyRelayReservationUpdated()
+}
+
 func (rf *relayFinder) runScheduledWork(ctx context.Context, now time.Time, scheduledWork *scheduledWorkTimes, peerSourceRateLimiter chan<- struct{}) time.Time {
 	nextTime := now.Add(scheduledWork.leastFrequentInterval)
 
@@ -359,6 +448,7 @@ func (rf *relayFinder) clearOldCandidates(now time.Time) time.Time {
 func (rf *relayFinder) clearBackoff(now time.Time) time.Time {
 	nextTime := now.Add(rf.conf.backoff)
 
+	var cleared bool
 	rf.candidateMx.Lock()
 	defer rf.candidateMx.Unlock()
 	for id, t := range rf.backoff {
@@ -370,8 +460,13 @@ func (rf *relayFinder) clearBackoff(now time.Time) time.Time {
 		} else {
 			log.Debugw("removing backoff for node", "id", id)
 			delete(rf.backoff, id)
+			cleared = true
 		}
 	}
+	// We might be able to get a reservation with a baseline relay again.
+	if cleared && len(rf.conf.baselineRelays) > 0 {
+		rf.notifyMaybeConnectToRelay()
+	}
 
 	return nextTime
 }
@@ -382,7 +477,7 @@ func (rf *relayFinder) clearBackoff(now time.Time) time.Time {
 // This makes sure that as soon as we need to find relay candidates, we have them available.
 // peerSourceRateLimiter is used to limit how often we call the peer source.
 func (rf *relayFinder) findNodes(ctx context.Context, peerSourceRateLimiter <-chan struct{}) {
-	var peerChan <-chan peer.AddrInfo
+	var peerChan <-chan ScoredCandidate
 	var wg sync.WaitGroup
 	for {
 		rf.candidateMx.Lock()
@@ -420,16 +515,22 @@ func (rf *relayFinder) findNodes(ctx context.Context, peerSourceRateLimiter <-ch
 				continue
 			}
 			log.Debugw("found node", "id", pi.ID)
+			rf.metricsTracer.CandidateReceived()
 			rf.candidateMx.Lock()
 			numCandidates := len(rf.candidates)
 			backoffStart, isOnBackoff := rf.backoff[pi.ID]
+			// When we have enough candidates, only a candidate scored higher than one we have
+			// is worth checking.
+			evictable := rf.lowestScoredCandidateLocked(pi.Score) != nil
 			rf.candidateMx.Unlock()
 			if isOnBackoff {
 				log.Debugw("skipping node that we recently failed to obtain a reservation with", "id", pi.ID, "last attempt", rf.conf.clock.Since(backoffStart))
+				rf.metricsTracer.CandidateRejected(RejectBackoff)
 				continue
 			}
-			if numCandidates >= rf.conf.maxCandidates {
+			if numCandidates >= rf.conf.maxCandidates && !evictable {
 				log.Debugw("skipping node. Already have enough candidates", "id", pi.ID, "num", numCandidates, "max", rf.conf.maxCandidates)
+				rf.metricsTracer.CandidateRejected(RejectMaxCandidates)
 				continue
 			}
 			rf.refCount.Add(1)
@@ -437,7 +538,7 @@ func (rf *relayFinder) findNodes(ctx context.Context, peerSourceRateLimiter <-ch
 			go func() {
 				defer rf.refCount.Done()
 				defer wg.Done()
-				if added := rf.handleNewNode(ctx, pi); added {
+				if added := rf.handleNewNode(ctx, pi.AddrInfo, pi.Score, pi.Labels); added {
 					rf.notifyNewCandidate()
 				}
 			}()
@@ -480,7 +581,7 @@ func (rf *relayFinder) notifyRelayReservationUpdated() {
 // This method is only run on private nodes.
 // If a peer does, it is added to the candidates map.
 // Note that just supporting the protocol doesn't guarantee that we can also obtain a reservation.
-func (rf *relayFinder) handleNewNode(ctx context.Context, pi peer.AddrInfo) (added bool) {
+func (rf *relayFinder) handleNewNode(ctx context.Context, pi peer.AddrInfo, score float64, labels map[string]string) (added bool) {
 	rf.relayMx.Lock()
 	relayInUse := rf.usingRelay(pi.ID)
 	rf.relayMx.Unlock()
@@ -496,38 +597,62 @@ func (rf *relayFinder) handleNewNode(ctx context.Context, pi peer.AddrInfo) (add
 		if err == errProtocolNotSupported {
 			rf.metricsTracer.CandidateChecked(false)
 		}
+		rf.metricsTracer.CandidateRejected(getRejectReason(err))
 		return false
 	}
 	rf.metricsTracer.CandidateChecked(true)
 
+	var rtt time.Duration
+	if rf.conf.rttSelection != nil {
+		var ok bool
+		if rtt, ok = rf.measureRTT(ctx, pi.ID); !ok {
+			log.Debugf("node %s not accepted as a candidate: no probe answered", pi.ID)
+			rf.metricsTracer.CandidateRejected(RejectUnresponsive)
+			return false
+		}
+	}
+
 	rf.candidateMx.Lock()
-	if len(rf.candidates) > rf.conf.maxCandidates {
-		rf.candidateMx.Unlock()
-		return false
+	if _, exists := rf.candidates[pi.ID]; !exists && len(rf.candidates) >= rf.conf.maxCandidates {
+		evict := rf.lowestScoredCandidateLocked(score)
+		if evict == nil {
+			rf.candidateMx.Unlock()
+			rf.metricsTracer.CandidateRejected(RejectMaxCandidates)
+			return false
+		}
+		log.Debugw("evicting lower scored candidate", "id", evict.ai.ID, "score", evict.score, "new", pi.ID, "new score", score)
+		rf.removeCandidate(evict.ai.ID)
 	}
 	log.Debugw("node supports relay protocol", "peer", pi.ID, "supports circuit v2", supportsV2)
 	rf.addCandidate(&candidate{
 		added:           rf.conf.clock.Now(),
 		ai:              pi,
 		supportsRelayV2: supportsV2,
+		score:           score,
+		labels:          labels,
+		rtt:             rtt,
 	})
 	rf.candidateMx.Unlock()
 	return true
 }
 
-var errProtocolNotSupported = errors.New("doesn't speak circuit v2")
+var (
+	errProtocolNotSupported = errors.New("doesn't speak circuit v2")
+	errConnectToRelay       = errors.New("failed to connect to relay")
+	errNotPublicNode        = errors.New("not a public node")
+)
 
 // tryNode checks if a peer actually supports either circuit v2.
 // It does not modify any internal state.
 func (rf *relayFinder) tryNode(ctx context.Context, pi peer.AddrInfo) (supportsRelayV2 bool, err error) {
 	if err := rf.host.Connect(ctx, pi); err != nil {
-		return false, fmt.Errorf("error connecting to relay %s: %w", pi.ID, err)
+		return false, fmt.Errorf("%w %s: %w", errConnectToRelay, pi.ID, err)
 	}
 
 	conns := rf.host.Network().ConnsToPeer(pi.ID)
 	for _, conn := range conns {
 		if isRelayAddr(conn.RemoteMultiaddr()) {
-			return false, errors.New("not a public node")
+			return false, errNotPublicNode
 		}
 	}
 
@@ -582,11 +707,17 @@ func (rf *relayFinder) handleNewCandidates(ctx context.Context) {
 }
 
 func (rf *relayFinder) maybeConnectToRelay(ctx context.Context) {
+	rf.connectMx.Lock()
+	defer rf.connectMx.Unlock()
+
+	rf.connectToBaselineRelays(ctx)
+
 	rf.relayMx.Lock()
 	numRelays := len(rf.relays)
 	rf.relayMx.Unlock()
 	// We're already connected to our desired number of relays. Nothing to do here.
-	if numRelays == rf.conf.desiredRelays {
+	// Note that the baseline relays might exceed it.
+	if numRelays >= rf.conf.desiredRelays {
 		return
 	}
 
@@ -624,24 +755,77 @@ func (rf *relayFinder) maybeConnectToRelay(ctx context.Context) {
 			log.Debugw("failed to connect to relay", "peer", id, "error", err)
 			rf.notifyMaybeNeedNewCandidates()
 			rf.metricsTracer.ReservationRequestFinished(false, err)
+			rf.metricsTracer.CandidateRejected(getRejectReason(err))
 			continue
 		}
-		log.Debugw("adding new relay", "id", id)
+		log.Debugw("adding new relay", "id", id, "score", cand.score, "labels", cand.labels, "rtt", cand.rtt)
 		rf.relayMx.Lock()
-		rf.relays[id] = rsvp
-		numRelays := len(rf.relays)
+		rf.relayScores[id] = cand.score
 		rf.relayMx.Unlock()
-		rf.notifyMaybeNeedNewCandidates()
+		if numRelays := rf.addRelay(id, rsvp); numRelays >= rf.conf.desiredRelays {
+			break
+		}
+	}
+}
+
+// connectToBaselineRelays attempts to get reservations with the baseline relays we're not using,
+// unless we recently failed to. The relays are dialed concurrently, so that an unreachable
+// baseline relay doesn't hold up the others.
+func (rf *relayFinder) connectToBaselineRelays(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, ai := range rf.conf.baselineRelays {
+		rf.relayMx.Lock()
+		usingRelay := rf.usingRelay(ai.ID)
+		rf.relayMx.Unlock()
+		rf.candidateMx.Lock()
+		_, isOnBackoff := rf.backoff[ai.ID]
+		rf.candidateMx.Unlock()
+		if usingRelay || isOnBackoff {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rsvp, err := rf.connectToRelay(ctx, &candidate{ai: ai, supportsRelayV2: true})
+			if err != nil {
+				log.Debugw("failed to connect to baseline relay", "peer", ai.ID, "error", err)
+				// connectToRelay only backs off after connecting. Back off on any failure,
+				// so that we don't redial an unreachable baseline relay every time we look for relays.
+				rf.candidateMx.Lock()
+				rf.backoff[ai.ID] = rf.conf.clock.Now()
+				rf.candidateMx.Unlock()
+				rf.metricsTracer.ReservationRequestFinished(false, err)
+				return
+			}
+			log.Debugw("adding baseline relay", "id", ai.ID)
+			rf.addRelay(ai.ID, rsvp)
+		}()
+	}
+	wg.Wait()
+}
 
-		rf.host.ConnManager().Protect(id, autorelayTag) // protect the connection
+// addRelay starts using a relay we obtained a reservation with. It returns the number of relays.
+func (rf *relayFinder) addRelay(id peer.ID, rsvp *circuitv2.Reservation) int {
+	rf.relayMx.Lock()
+	rf.relays[id] = rsvp
+	numRelays := len(rf.relays)
+	rf.relayMx.Unlock()
+	rf.notifyMaybeNeedNewCandidates()
 
-		rf.notifyRelayReservationUpdated()
+	rf.host.ConnManager().Protect(id, autorelayTag) // protect the connection
 
-		rf.metricsTracer.ReservationRequestFinished(false, nil)
+	rf.notifyRelayReservationUpdated()
 
-		if numRelays >= rf.conf.desiredRelays {
-			break
-		}
+	rf.metricsTracer.ReservationRequestFinished(false, nil)
+	rf.emitReservationEvent(event.RelayReservationObtained, id, rsvp.Expiration, nil)
+	return numRelays
+}
+
+func (rf *relayFinder) emitReservationEvent(t event.RelayReservationEventType, p peer.ID, expiration time.Time, err error) {
+	rf.metricsTracer.ReservationEvent(t)
+	evt := event.EvtRelayReservation{Type: t, Relay: p, Expiration: expiration, Error: err}
+	if err := rf.rsvpEmitter.Emit(evt); err != nil {
+		log.Errorw("failed to emit event.EvtRelayReservation", "type", t, "relay", p, "error", err)
 	}
 }
 
@@ -659,7 +843,7 @@ func (rf *relayFinder) connectToRelay(ctx context.Context, cand *candidate) (*ci
 			rf.candidateMx.Lock()
 			rf.removeCandidate(cand.ai.ID)
 			rf.candidateMx.Unlock()
-			return nil, fmt.Errorf("failed to connect: %w", err)
+			return nil, fmt.Errorf("%w: %w", errConnectToRelay, err)
 		}
 	}
 
@@ -668,9 +852,12 @@ func (rf *relayFinder) connectToRelay(ctx context.Context, cand *candidate) (*ci
 	rf.candidateMx.Unlock()
 	var err error
 	if cand.supportsRelayV2 {
+		start := rf.conf.clock.Now()
 		rsvp, err = circuitv2.Reserve(ctx, rf.host, cand.ai)
 		if err != nil {
 			err = fmt.Errorf("failed to reserve slot: %w", err)
+		} else {
+			rf.metricsTracer.ReservationLatency(false, rf.conf.clock.Since(start))
 		}
 	}
 	rf.candidateMx.Lock()
@@ -690,7 +877,9 @@ func (rf *relayFinder) refreshReservations(ctx context.Context, now time.Time) b
 		}
 
 		p := p
+		expiration := rsvp.Expiration
 		g.Go(func() error {
+			rf.emitReservationEvent(event.RelayReservationExpiringSoon, p, expiration, nil)
 			err := rf.refreshRelayReservation(ctx, p)
 			rf.metricsTracer.ReservationRequestFinished(true, err)
 			return err
@@ -703,18 +892,24 @@ func (rf *relayFinder) refreshReservations(ctx context.Context, now time.Time) b
 }
 
 func (rf *relayFinder) refreshRelayReservation(ctx context.Context, p peer.ID) error {
+	start := rf.conf.clock.Now()
 	rsvp, err := circuitv2.Reserve(ctx, rf.host, peer.AddrInfo{ID: p})
+	if err == nil {
+		rf.metricsTracer.ReservationLatency(true, rf.conf.clock.Since(start))
+	}
 
 	rf.relayMx.Lock()
 	if err != nil {
 		log.Debugw("failed to refresh relay slot reservation", "relay", p, "error", err)
-		_, exists := rf.relays[p]
+		old, exists := rf.relays[p]
 		delete(rf.relays, p)
+		delete(rf.relayScores, p)
 		// unprotect the connection
 		rf.host.ConnManager().Unprotect(p, autorelayTag)
 		rf.relayMx.Unlock()
 		if exists {
 			rf.metricsTracer.ReservationEnded(1)
+			rf.emitReservationEvent(event.RelayReservationRefreshFailed, p, old.Expiration, err)
 		}
 		return err
 	}
@@ -722,6 +917,7 @@ func (rf *relayFinder) refreshRelayReservation(ctx context.Context, p peer.ID) e
 	log.Debugw("refreshed relay slot reservation", "relay", p)
 	rf.relays[p] = rsvp
 	rf.relayMx.Unlock()
+	rf.emitReservationEvent(event.RelayReservationRefreshed, p, rsvp.Expiration, nil)
 	return nil
 }
 
@@ -740,6 +936,18 @@ func (rf *relayFinder) addCandidate(cand *candidate) {
 	}
 }
 
+// lowestScoredCandidateLocked returns the candidate with the lowest score, if it's lower than
+// score. It must be called with candidateMx held.
+func (rf *relayFinder) lowestScoredCandidateLocked(score float64) *candidate {
+	var lowest *candidate
+	for _, cand := range rf.candidates {
+		if cand.score < score && (lowest == nil || cand.score < lowest.score) {
+			lowest = cand
+		}
+	}
+	return lowest
+}
+
 func (rf *relayFinder) removeCandidate(id peer.ID) {
 	_, exists := rf.candidates[id]
 	if exists {
@@ -759,11 +967,13 @@ func (rf *relayFinder) selectCandidates() []*candidate {
 		}
 	}
 
-	// TODO: better relay selection strategy; this just selects random relays,
-	// but we should probably use ping latency as the selection metric
+	// Candidates with the same score and RTT are selected randomly.
 	rand.Shuffle(len(candidates), func(i, j int) {
 		candidates[i], candidates[j] = candidates[j], candidates[i]
 	})
+	slices.SortStableFunc(candidates, func(a, b *candidate) int {
+		return cmp.Or(cmp.Compare(b.score, a.score), cmp.Compare(a.rtt, b.rtt))
+	})
 	return candidates
 }
 
@@ -823,6 +1033,7 @@ func (rf *relayFinder) resetMetrics() {
 	rf.candidateMx.Unlock()
 
 	rf.metricsTracer.RelayAddressCount(0)
+	rf.metricsTracer.ReservationCount(0)
 	rf.metricsTracer.ScheduledWorkUpdated(&scheduledWorkTimes{})
 }
 
@@ -0,0 +1,221 @@
+package autorelay
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"math"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+)
+
+// RTTSelection configures the selection of relays by round trip time.
+// Candidates are probed before AutoRelay obtains reservations with them. Among candidates with the
+// same score, the ones with the lowest RTT are preferred; lost probes inflate the RTT, a peer losing
+// half the probes counts as twice as slow. Candidates that don't answer any probe are rejected.
+// The relays we have reservations with are probed again periodically, and the slowest one is
+// replaced once a candidate with at least the same score is much faster.
+type RTTSelection struct {
+	// Probes is the number of probes sent to measure the RTT of a peer; defaults to 3.
+	Probes int
+	// ProbeTimeout is the time after which a probe is considered lost; defaults to 5s.
+	ProbeTimeout time.Duration
+	// Probe measures the RTT to a peer. Defaults to a libp2p ping.
+	Probe func(ctx context.Context, h host.Host, p peer.ID) (time.Duration, error)
+	// Interval is the interval at which the relays are re-evaluated; defaults to 10 minutes.
+	// A negative interval disables the re-evaluation.
+	Interval time.Duration
+	// Threshold is the fraction by which the RTT of a candidate must be lower than the RTT of a
+	// relay for the reservation to be migrated to the candidate; defaults to 0.5.
+	Threshold float64
+	// MinDifference is the minimum difference between the RTT of the relay and the candidate for
+	// the reservation to be migrated; defaults to 20ms.
+	MinDifference time.Duration
+}
+
+// DefaultRTTSelection returns a RTTSelection object with the defaults filled in.
+func DefaultRTTSelection() *RTTSelection {
+	return &RTTSelection{
+		Probes:        3,
+		ProbeTimeout:  5 * time.Second,
+		Probe:         pingProbe,
+		Interval:      10 * time.Minute,
+		Threshold:     0.5,
+		MinDifference: 20 * time.Millisecond,
+	}
+}
+
+func (s *RTTSelection) withDefaults() (*RTTSelection, error) {
+	if s.Threshold < 0 || s.Threshold >= 1 {
+		return nil, errors.New("RTT threshold must be in [0, 1)")
+	}
+	res := *s
+	def := DefaultRTTSelection()
+	if res.Probes <= 0 {
+		res.Probes = def.Probes
+	}
+	if res.ProbeTimeout <= 0 {
+		res.ProbeTimeout = def.ProbeTimeout
+	}
+	if res.Probe == nil {
+		res.Probe = def.Probe
+	}
+	if res.Interval == 0 {
+		res.Interval = def.Interval
+	}
+	if res.Threshold == 0 {
+		res.Threshold = def.Threshold
+	}
+	if res.MinDifference <= 0 {
+		res.MinDifference = def.MinDifference
+	}
+	return &res, nil
+}
+
+func pingProbe(ctx context.Context, h host.Host, p peer.ID) (time.Duration, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	res, ok := <-ping.Ping(ctx, h, p)
+	if !ok {
+		return 0, ctx.Err()
+	}
+	return res.RTT, res.Error
+}
+
+// measureRTT probes a peer, and returns its RTT inflated by the lost probes. It returns false if
+// all probes were lost. The probes are sent concurrently, so that it takes at most ProbeTimeout.
+func (rf *relayFinder) measureRTT(ctx context.Context, p peer.ID) (time.Duration, bool) {
+	s := rf.conf.rttSelection
+	ctx, cancel := context.WithTimeout(ctx, s.ProbeTimeout)
+	defer cancel()
+
+	rtts := make(chan time.Duration, s.Probes)
+	for range s.Probes {
+		go func() {
+			rtt, err := s.Probe(ctx, rf.host, p)
+			if err != nil {
+				log.Debugw("relay probe failed", "peer", p, "error", err)
+				rtt = -1
+			}
+			rtts <- rtt
+		}()
+	}
+	var (
+		sum      time.Duration
+		received int
+	)
+	for range s.Probes {
+		if rtt := <-rtts; rtt >= 0 {
+			sum += rtt
+			received++
+		}
+	}
+	if received == 0 {
+		return 0, false
+	}
+	// The mean RTT divided by the fraction of received probes.
+	return sum * time.Duration(s.Probes) / time.Duration(received*received), true
+}
+
+// muchFaster returns whether a candidate is fast enough to migrate a reservation to it.
+func (rf *relayFinder) muchFaster(candidateRTT, relayRTT time.Duration) bool {
+	s := rf.conf.rttSelection
+	return float64(candidateRTT) <= float64(relayRTT)*(1-s.Threshold) && relayRTT-candidateRTT >= s.MinDifference
+}
+
+// reevaluateRelays periodically migrates the reservation with the slowest relay.
+func (rf *relayFinder) reevaluateRelays(ctx context.Context) {
+	interval := rf.conf.rttSelection.Interval
+	timer := rf.conf.clock.InstantTimer(rf.conf.clock.Now().Add(interval))
+	defer timer.Stop()
+	for {
+		select {
+		case now := <-timer.Ch():
+			rf.migrateSlowestRelay(ctx)
+			timer.Reset(now.Add(interval))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// migrateSlowestRelay replaces the slowest relay obtained from the peer source by a much faster
+// candidate, if there's one.
+func (rf *relayFinder) migrateSlowestRelay(ctx context.Context) {
+	rf.relayMx.Lock()
+	scores := maps.Clone(rf.relayScores)
+	rf.relayMx.Unlock()
+
+	var (
+		slowest    peer.ID
+		slowestRTT time.Duration = -1
+	)
+	for p := range scores {
+		rtt, ok := rf.measureRTT(ctx, p)
+		if !ok {
+			rtt = math.MaxInt64
+		}
+		if rtt > slowestRTT {
+			slowest, slowestRTT = p, rtt
+		}
+	}
+	if slowestRTT < 0 {
+		return
+	}
+
+	rf.candidateMx.Lock()
+	var best *candidate
+	for _, cand := range rf.selectCandidates() {
+		if cand.rtt > 0 && cand.score >= scores[slowest] && (best == nil || cand.rtt < best.rtt) {
+			best = cand
+		}
+	}
+	rf.candidateMx.Unlock()
+	if best == nil || !rf.muchFaster(best.rtt, slowestRTT) {
+		return
+	}
+	// The candidate was probed when it was found, and might have become slower since.
+	rtt, ok := rf.measureRTT(ctx, best.ai.ID)
+	if !ok || !rf.muchFaster(rtt, slowestRTT) {
+		return
+	}
+
+	rf.connectMx.Lock()
+	defer rf.connectMx.Unlock()
+	// maybeConnectToRelay might have obtained a reservation with the candidate in the meantime.
+	rf.relayMx.Lock()
+	usingRelay := rf.usingRelay(best.ai.ID)
+	rf.relayMx.Unlock()
+	if usingRelay {
+		return
+	}
+	rsvp, err := rf.connectToRelay(ctx, best)
+	if err != nil {
+		log.Debugw("failed to connect to relay", "peer", best.ai.ID, "error", err)
+		rf.notifyMaybeNeedNewCandidates()
+		rf.metricsTracer.ReservationRequestFinished(false, err)
+		rf.metricsTracer.CandidateRejected(getRejectReason(err))
+		return
+	}
+	log.Debugw("migrating reservation to a faster relay", "from", slowest, "to", best.ai.ID, "old rtt", slowestRTT, "new rtt", rtt)
+	rf.relayMx.Lock()
+	rf.relayScores[best.ai.ID] = best.score
+	rf.relayMx.Unlock()
+	rf.addRelay(best.ai.ID, rsvp)
+
+	rf.relayMx.Lock()
+	old, exists := rf.relays[slowest]
+	delete(rf.relays, slowest)
+	delete(rf.relayScores, slowest)
+	rf.relayMx.Unlock()
+	if exists {
+		rf.host.ConnManager().Unprotect(slowest, autorelayTag)
+		rf.notifyRelayReservationUpdated()
+		rf.metricsTracer.ReservationEnded(1)
+		rf.emitReservationEvent(event.RelayReservationMigrated, slowest, old.Expiration, nil)
+	}
+}
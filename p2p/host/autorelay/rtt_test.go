@@ -0,0 +1,39 @@
+package autorelay
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMeasureRTTConcurrentProbes(t *testing.T) {
+	var calls atomic.Int32
+	s, err := (&RTTSelection{
+		Probes:       3,
+		ProbeTimeout: 100 * time.Millisecond,
+		// Only the first probe is answered, the others are lost.
+		Probe: func(ctx context.Context, _ host.Host, _ peer.ID) (time.Duration, error) {
+			if calls.Add(1) == 1 {
+				return 10 * time.Millisecond, nil
+			}
+			<-ctx.Done()
+			return 0, ctx.Err()
+		},
+	}).withDefaults()
+	require.NoError(t, err)
+	rf := &relayFinder{conf: &config{rttSelection: s}}
+
+	start := time.Now()
+	rtt, ok := rf.measureRTT(context.Background(), "peer")
+	require.True(t, ok)
+	// The lost probes time out together, not one after the other.
+	require.Less(t, time.Since(start), 200*time.Millisecond)
+	// 10ms divided by the fraction of received probes.
+	require.Equal(t, 30*time.Millisecond, rtt)
+	require.Equal(t, int32(3), calls.Load())
+}
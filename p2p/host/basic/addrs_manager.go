@@ -261,7 +261,7 @@ func (a *addrsManager) updateAddrs(updateRelayAddrs bool, relayAddrs []ma.Multia
 		// Copy the callers slice
 		relayAddrs = slices.Clone(relayAddrs)
 	}
-	currAddrs := a.getAddrs(slices.Clone(localAddrs), relayAddrs)
+	currAddrs := a.getAddrs(slices.Clone(localAddrs), relayAddrs, len(currReachableAddrs) > 0)
 
 	a.currentAddrs = hostAddrs{
 		addrs:            append(a.currentAddrs.addrs[:0], currAddrs...),
@@ -326,15 +326,18 @@ func (a *addrsManager) Addrs() []ma.Multiaddr {
 	a.addrsMx.RLock()
 	directAddrs := slices.Clone(a.currentAddrs.localAddrs)
 	relayAddrs := slices.Clone(a.currentAddrs.relayAddrs)
+	confirmedReachable := len(a.currentAddrs.reachableAddrs) > 0
 	a.addrsMx.RUnlock()
-	return a.getAddrs(directAddrs, relayAddrs)
+	return a.getAddrs(directAddrs, relayAddrs, confirmedReachable)
 }
 
 // getAddrs returns the node's dialable addresses. Mutates localAddrs
-func (a *addrsManager) getAddrs(localAddrs []ma.Multiaddr, relayAddrs []ma.Multiaddr) []ma.Multiaddr {
+// The relay addresses are only used if the node's reachability is private, and
+// none of its addresses was confirmed reachable by AutoNATv2.
+func (a *addrsManager) getAddrs(localAddrs []ma.Multiaddr, relayAddrs []ma.Multiaddr, confirmedReachable bool) []ma.Multiaddr {
 	addrs := localAddrs
 	rch := a.hostReachability.Load()
-	if rch != nil && *rch == network.ReachabilityPrivate {
+	if rch != nil && *rch == network.ReachabilityPrivate && !confirmedReachable {
 		// Delete public addresses if the node's reachability is private, and we have relay addresses
 		if len(relayAddrs) > 0 {
 			addrs = slices.DeleteFunc(addrs, manet.IsPublicAddr)
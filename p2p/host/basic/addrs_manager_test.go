@@ -381,6 +381,28 @@ func TestAddrsManager(t *testing.T) {
 		}, 5*time.Second, 50*time.Millisecond)
 	})
 
+	t.Run("relay addrs withheld when reachable", func(t *testing.T) {
+		am := newAddrsManagerTestCase(t, addrsManagerArgs{
+			ListenAddrs: func() []ma.Multiaddr { return []ma.Multiaddr{publicQUIC, lhtcp} },
+			AutoNATClient: mockAutoNATClient{
+				F: func(_ context.Context, reqs []autonatv2.Request) (autonatv2.Result, error) {
+					return autonatv2.Result{Addr: reqs[0].Addr, Idx: 0, Reachability: network.ReachabilityPublic}, nil
+				},
+			},
+		})
+		am.PushReachability(network.ReachabilityPrivate)
+		relayAddr := ma.StringCast("/ip4/1.2.3.4/udp/1/quic-v1/p2p/QmdXGaeGiVA745XorV1jr11RHxB9z4fqykm6xCUPX1aTJo/p2p-circuit")
+		am.PushRelay([]ma.Multiaddr{relayAddr})
+
+		// publicQUIC is confirmed reachable by AutoNATv2.
+		expectedAddrs := []ma.Multiaddr{publicQUIC, lhtcp}
+		require.EventuallyWithT(t, func(collect *assert.CollectT) {
+			reachable, _, _ := am.ConfirmedAddrs()
+			assert.NotEmpty(collect, reachable)
+			assert.ElementsMatch(collect, am.Addrs(), expectedAddrs, "%s\n%s", am.Addrs(), expectedAddrs)
+		}, 5*time.Second, 50*time.Millisecond)
+	})
+
 	t.Run("addrs factory gets relay addrs", func(t *testing.T) {
 		relayAddr := ma.StringCast("/ip4/1.2.3.4/udp/1/quic-v1/p2p/QmdXGaeGiVA745XorV1jr11RHxB9z4fqykm6xCUPX1aTJo/p2p-circuit")
 		publicQUIC2 := ma.StringCast("/ip4/1.2.3.4/udp/2/quic-v1")
@@ -82,9 +82,13 @@ type BasicHost struct {
 
 	negtimeout time.Duration
 
+	relayPolicy func(peer.ID, []protocol.ID) network.RelayPolicy
+
 	emitters struct {
 		evtLocalProtocolsUpdated event.Emitter
 		evtLocalAddrsUpdated     event.Emitter
+		evtListenAddrsUpdated    event.Emitter
+		evtLocalPeerRecord       event.Emitter
 	}
 
 	disableSignedPeerRecord bool
@@ -97,9 +101,15 @@ type BasicHost struct {
 	autonatv2        *autonatv2.AutoNAT
 	addressManager   *addrsManager
 	addrsUpdatedChan chan struct{}
+
+	pinnedMx sync.Mutex
+	pinned   map[peer.ID][]ma.Multiaddr // the allowlist entries added for each pinned peer
+
+	peerEvents *peerEventLog
 }
 
 var _ host.Host = (*BasicHost)(nil)
+var _ host.PeerInfoProvider = (*BasicHost)(nil)
 
 // HostOpts holds options that can be passed to NewHost in order to
 // customize construction of the *BasicHost.
@@ -137,6 +147,10 @@ type HostOpts struct {
 
 	// UserAgent sets the user-agent for the host.
 	UserAgent string
+	// UserAgentFunc provides the user-agent of the host, overriding UserAgent.
+	UserAgentFunc func() string
+	// OmitUserAgent stops sending the user-agent to peers.
+	OmitUserAgent bool
 
 	// ProtocolVersion sets the protocol version for the host.
 	ProtocolVersion string
@@ -157,6 +171,27 @@ type HostOpts struct {
 	// DisableIdentifyAddressDiscovery disables address discovery using peer provided observed addresses in identify
 	DisableIdentifyAddressDiscovery bool
 
+	// DisableIdentifyPush disables the identify push protocol
+	DisableIdentifyPush bool
+	// IdentifyPushCoalesceWindow is the time identify pushes are delayed by to
+	// coalesce changes happening in quick succession.
+	IdentifyPushCoalesceWindow time.Duration
+	// IdentifyPushMinInterval is the minimum interval between two identify
+	// pushes on a connection.
+	IdentifyPushMinInterval time.Duration
+
+	// RequireSignedPeerRecords only accepts the addresses of peers that send a
+	// signed peer record in identify.
+	RequireSignedPeerRecords bool
+	// AllowUnsignedAddrs accepts unsigned addresses from some peers even if
+	// RequireSignedPeerRecords is set.
+	AllowUnsignedAddrs func(p peer.ID, agentVersion string) bool
+
+	// RelayPolicy decides whether relayed connections may be used for the
+	// streams opened by NewStream, depending on the peer and the requested
+	// protocols. It doesn't apply if the context sets a relay policy.
+	RelayPolicy func(peer.ID, []protocol.ID) network.RelayPolicy
+
 	AutoNATv2 *autonatv2.AutoNAT
 }
 
@@ -185,6 +220,8 @@ func NewHost(n network.Network, opts *HostOpts) (*BasicHost, error) {
 		ctxCancel:               cancel,
 		disableSignedPeerRecord: opts.DisableSignedPeerRecord,
 		addrsUpdatedChan:        make(chan struct{}, 1),
+		pinned:                  make(map[peer.ID][]ma.Multiaddr),
+		peerEvents:              newPeerEventLog(),
 	}
 
 	if h.emitters.evtLocalProtocolsUpdated, err = h.eventbus.Emitter(&event.EvtLocalProtocolsUpdated{}, eventbus.Stateful); err != nil {
@@ -193,6 +230,12 @@ func NewHost(n network.Network, opts *HostOpts) (*BasicHost, error) {
 	if h.emitters.evtLocalAddrsUpdated, err = h.eventbus.Emitter(&event.EvtLocalAddressesUpdated{}, eventbus.Stateful); err != nil {
 		return nil, err
 	}
+	if h.emitters.evtListenAddrsUpdated, err = h.eventbus.Emitter(&event.EvtListenAddrsUpdated{}); err != nil {
+		return nil, err
+	}
+	if h.emitters.evtLocalPeerRecord, err = h.eventbus.Emitter(&event.EvtLocalPeerRecordUpdated{}, eventbus.Stateful); err != nil {
+		return nil, err
+	}
 
 	if opts.MultistreamMuxer != nil {
 		h.mux = opts.MultistreamMuxer
@@ -203,6 +246,13 @@ func NewHost(n network.Network, opts *HostOpts) (*BasicHost, error) {
 		identify.ProtocolVersion(opts.ProtocolVersion),
 	}
 
+	if opts.UserAgentFunc != nil {
+		idOpts = append(idOpts, identify.UserAgentFunc(opts.UserAgentFunc))
+	}
+	if opts.OmitUserAgent {
+		idOpts = append(idOpts, identify.OmitUserAgent())
+	}
+
 	// we can't set this as a default above because it depends on the *BasicHost.
 	if h.disableSignedPeerRecord {
 		idOpts = append(idOpts, identify.DisableSignedPeerRecord())
@@ -215,6 +265,21 @@ func NewHost(n network.Network, opts *HostOpts) (*BasicHost, error) {
 	if opts.DisableIdentifyAddressDiscovery {
 		idOpts = append(idOpts, identify.DisableObservedAddrManager())
 	}
+	if opts.DisableIdentifyPush {
+		idOpts = append(idOpts, identify.DisablePush())
+	}
+	if opts.RequireSignedPeerRecords {
+		idOpts = append(idOpts, identify.RequireSignedPeerRecord())
+		if opts.AllowUnsignedAddrs != nil {
+			idOpts = append(idOpts, identify.AllowUnsignedAddrs(opts.AllowUnsignedAddrs))
+		}
+	}
+	if opts.IdentifyPushCoalesceWindow > 0 {
+		idOpts = append(idOpts, identify.WithPushCoalescing(opts.IdentifyPushCoalesceWindow))
+	}
+	if opts.IdentifyPushMinInterval > 0 {
+		idOpts = append(idOpts, identify.WithPushRateLimit(opts.IdentifyPushMinInterval))
+	}
 
 	h.ids, err = identify.NewIDService(h, idOpts...)
 	if err != nil {
@@ -252,6 +317,7 @@ func NewHost(n network.Network, opts *HostOpts) (*BasicHost, error) {
 	// register to be notified when the network's listen addrs change,
 	// so we can update our address set and push events if needed
 	h.Network().Notify(h.addressManager.NetNotifee())
+	h.Network().Notify(h.listenNotifee())
 
 	if opts.EnableHolePunching {
 		if opts.EnableMetrics {
@@ -269,6 +335,7 @@ func NewHost(n network.Network, opts *HostOpts) (*BasicHost, error) {
 	if uint64(opts.NegotiationTimeout) != 0 {
 		h.negtimeout = opts.NegotiationTimeout
 	}
+	h.relayPolicy = opts.RelayPolicy
 
 	if opts.ConnManager == nil {
 		h.cmgr = &connmgr.NullConnMgr{}
@@ -335,6 +402,8 @@ func (h *BasicHost) Start() {
 		}
 		if _, err := h.caBook.ConsumePeerRecord(rec, peerstore.PermanentAddrTTL); err != nil {
 			log.Errorf("failed to persist signed record to peerstore: %w", err)
+		} else {
+			h.emitters.evtLocalPeerRecord.Emit(event.EvtLocalPeerRecordUpdated{SignedPeerRecord: rec})
 		}
 	}
 
@@ -342,6 +411,10 @@ func (h *BasicHost) Start() {
 
 	h.refCount.Add(1)
 	go h.background()
+
+	if err := h.peerEvents.start(h.ctx, h.eventbus, &h.refCount); err != nil {
+		log.Errorf("failed to record peer events: %s", err)
+	}
 }
 
 // newStreamHandler is the remote-opened stream handler for network.Network
@@ -475,6 +548,7 @@ func (h *BasicHost) background() {
 				log.Errorf("failed to persist signed peer record in peer store, err=%s", err)
 				return
 			}
+			h.emitters.evtLocalPeerRecord.Emit(event.EvtLocalPeerRecordUpdated{SignedPeerRecord: changeEvt.SignedPeerRecord})
 		}
 		// update host addresses in the peer store
 		removedAddrs := make([]ma.Multiaddr, 0, len(changeEvt.Removed))
@@ -583,6 +657,10 @@ func (h *BasicHost) NewStream(ctx context.Context, p peer.ID, pids ...protocol.I
 		}
 	}
 
+	if h.relayPolicy != nil && network.GetRelayPolicy(ctx) == network.RelayPolicyDefault {
+		ctx = network.WithRelayPolicy(ctx, h.relayPolicy(p, pids))
+	}
+
 	// If the caller wants to prevent the host from dialing, it should use the NoDial option.
 	if nodial, _ := network.GetNoDial(ctx); !nodial {
 		err := h.Connect(ctx, peer.AddrInfo{ID: p})
@@ -680,11 +758,13 @@ func (h *BasicHost) preferredProtocol(p peer.ID, pids []protocol.ID) (protocol.I
 // It will also resolve any /dns4, /dns6, and /dnsaddr addresses.
 func (h *BasicHost) Connect(ctx context.Context, pi peer.AddrInfo) error {
 	// absorb addresses into peerstore
-	h.Peerstore().AddAddrs(pi.ID, pi.Addrs, peerstore.TempAddrTTL)
+	peerstore.AddAddrsWithSource(h.Peerstore(), pi.ID, pi.Addrs, peerstore.TempAddrTTL, peerstore.AddrSourceManual)
 
 	forceDirect, _ := network.GetForceDirectDial(ctx)
 	canUseLimitedConn, _ := network.GetAllowLimitedConn(ctx)
-	if !forceDirect {
+	// With a relay policy, the existing connection might not be usable. The
+	// swarm reuses it if it is.
+	if !forceDirect && network.GetRelayPolicy(ctx) == network.RelayPolicyDefault {
 		connectedness := h.Network().Connectedness(pi.ID)
 		if connectedness == network.Connected || (canUseLimitedConn && connectedness == network.Limited) {
 			return nil
@@ -722,6 +802,17 @@ func (h *BasicHost) ConnManager() connmgr.ConnManager {
 	return h.cmgr
 }
 
+// SignedPeerRecord returns the latest signed peer record of the host, listing
+// the addresses returned by Addrs. It returns nil if signed peer records are
+// disabled. Subscribe to event.EvtLocalPeerRecordUpdated to be notified when
+// the record changes.
+func (h *BasicHost) SignedPeerRecord() *record.Envelope {
+	if h.disableSignedPeerRecord {
+		return nil
+	}
+	return h.caBook.GetPeerRecord(h.ID())
+}
+
 // Addrs returns listening addresses. The output is the same as AllAddrs, but
 // processed by AddrsFactory.
 // When used with AutoRelay, and if the host is not publicly reachable,
@@ -762,6 +853,15 @@ func (h *BasicHost) ConfirmedAddrs() (reachable []ma.Multiaddr, unreachable []ma
 	return h.addressManager.ConfirmedAddrs()
 }
 
+// PeerInfo returns a consolidated view of the given peer, aggregating the
+// state of the swarm, the peerstore, the connection manager and the resource
+// manager, and the recent events about the peer.
+func (h *BasicHost) PeerInfo(p peer.ID) host.PeerInfo {
+	info := host.CollectPeerInfo(h, p)
+	info.Events = h.peerEvents.get(p)
+	return info
+}
+
 func trimHostAddrList(addrs []ma.Multiaddr, maxSize int) []ma.Multiaddr {
 	totalSize := 0
 	for _, a := range addrs {
@@ -868,6 +968,8 @@ func (h *BasicHost) Close() error {
 		if err := h.network.Close(); err != nil {
 			log.Errorf("swarm close failed: %v", err)
 		}
+		_ = h.emitters.evtListenAddrsUpdated.Close()
+		_ = h.emitters.evtLocalPeerRecord.Close()
 
 		h.psManager.Close()
 		if h.Peerstore() != nil {
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -20,8 +21,12 @@ import (
 	"github.com/libp2p/go-libp2p/core/peerstore"
 	"github.com/libp2p/go-libp2p/core/protocol"
 	"github.com/libp2p/go-libp2p/core/record"
+	"github.com/libp2p/go-libp2p/core/test"
 	"github.com/libp2p/go-libp2p/p2p/host/autonat"
 	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
+	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
+	"github.com/libp2p/go-libp2p/p2p/net/connmgr"
+	"github.com/libp2p/go-libp2p/p2p/net/swarm"
 	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
 	"github.com/libp2p/go-libp2p/p2p/protocol/identify"
 
@@ -82,6 +87,50 @@ func TestHostSimple(t *testing.T) {
 	require.Equal(t, buf1, buf3)
 }
 
+func TestHostRelayPolicy(t *testing.T) {
+	const bulk = protocol.ID("/bulk")
+	tcpOnly := []swarmt.Option{swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC}
+	h1, err := NewHost(swarmt.GenSwarm(t, tcpOnly...), &HostOpts{
+		RelayPolicy: func(_ peer.ID, pids []protocol.ID) network.RelayPolicy {
+			if slices.Contains(pids, bulk) {
+				return network.RelayPolicyRequire
+			}
+			return network.RelayPolicyDefault
+		},
+	})
+	require.NoError(t, err)
+	defer h1.Close()
+	h1.Start()
+	h2, err := NewHost(swarmt.GenSwarm(t, tcpOnly...), nil)
+	require.NoError(t, err)
+	defer h2.Close()
+	h2.Start()
+
+	handler := func(s network.Stream) { s.Close() }
+	h2.SetStreamHandler(protocol.TestingID, handler)
+	h2.SetStreamHandler(bulk, handler)
+	h1.Peerstore().AddAddrs(h2.ID(), h2.Addrs(), peerstore.PermanentAddrTTL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// h2 only has direct addresses.
+	_, err = h1.NewStream(ctx, h2.ID(), bulk)
+	require.ErrorIs(t, err, swarm.ErrNoGoodAddresses)
+
+	s, err := h1.NewStream(ctx, h2.ID(), protocol.TestingID)
+	require.NoError(t, err)
+	s.Close()
+
+	// The direct connection isn't used either.
+	_, err = h1.NewStream(ctx, h2.ID(), bulk)
+	require.ErrorIs(t, err, swarm.ErrNoGoodAddresses)
+
+	// The context's policy takes precedence.
+	s, err = h1.NewStream(network.WithRelayPolicy(ctx, network.RelayPolicyForbid), h2.ID(), bulk)
+	require.NoError(t, err)
+	s.Close()
+}
+
 func TestMultipleClose(t *testing.T) {
 	h, err := NewHost(swarmt.GenSwarm(t), nil)
 	require.NoError(t, err)
@@ -125,6 +174,38 @@ func TestSignedPeerRecordWithNoListenAddrs(t *testing.T) {
 	require.NotEmpty(t, rec.(*peer.PeerRecord).Addrs)
 }
 
+func TestSignedPeerRecordUpdates(t *testing.T) {
+	h, err := NewHost(swarmt.GenSwarm(t, swarmt.OptDialOnly), nil)
+	require.NoError(t, err)
+	defer h.Close()
+
+	sub, err := h.EventBus().Subscribe(&event.EvtLocalPeerRecordUpdated{}, eventbus.BufSize(16))
+	require.NoError(t, err)
+	defer sub.Close()
+	h.Start()
+
+	require.NoError(t, h.Network().Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0")))
+	timeout := time.After(5 * time.Second)
+	for {
+		var evt event.EvtLocalPeerRecordUpdated
+		select {
+		case e := <-sub.Out():
+			evt = e.(event.EvtLocalPeerRecordUpdated)
+		case <-timeout:
+			t.Fatal("timeout waiting for the peer record with the new address")
+		}
+		rec, err := evt.SignedPeerRecord.Record()
+		require.NoError(t, err)
+		if len(rec.(*peer.PeerRecord).Addrs) == 0 {
+			continue
+		}
+		require.Equal(t, h.Addrs(), rec.(*peer.PeerRecord).Addrs)
+		require.True(t, evt.SignedPeerRecord.Equal(h.SignedPeerRecord()))
+		require.True(t, evt.SignedPeerRecord.Equal(host.SignedPeerRecordFromHost(h)))
+		break
+	}
+}
+
 func TestProtocolHandlerEvents(t *testing.T) {
 	h, err := NewHost(swarmt.GenSwarm(t), nil)
 	require.NoError(t, err)
@@ -139,7 +220,7 @@ func TestProtocolHandlerEvents(t *testing.T) {
 	// to the test.
 	isIdentify := func(evt event.EvtLocalProtocolsUpdated) bool {
 		for _, p := range evt.Added {
-			if p == identify.ID || p == identify.IDPush {
+			if p == identify.ID || p == identify.IDPush || p == identify.IDDelta {
 				return true
 			}
 		}
@@ -307,6 +388,48 @@ func getHostPair(t *testing.T) (host.Host, host.Host) {
 	return h1, h2
 }
 
+func TestPeerInfo(t *testing.T) {
+	h1, err := NewHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport), nil)
+	require.NoError(t, err)
+	h1.Start()
+	defer h1.Close()
+	h2, err := NewHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport), nil)
+	require.NoError(t, err)
+	h2.Start()
+	defer h2.Close()
+	require.NoError(t, h1.Connect(context.Background(), h2.Peerstore().PeerInfo(h2.ID())))
+
+	const proto = "/testing/peerinfo"
+	h2.SetStreamHandler(proto, func(s network.Stream) {
+		io.Copy(io.Discard, s)
+	})
+	s, err := h1.NewStream(context.Background(), h2.ID(), proto)
+	require.NoError(t, err)
+	defer s.Close()
+
+	info := h1.PeerInfo(h2.ID())
+	require.Equal(t, h2.ID(), info.ID)
+	require.Equal(t, network.Connected, info.Connectedness)
+	require.NotEmpty(t, info.Addrs)
+	require.Len(t, info.Conns, 1)
+	require.Equal(t, network.DirOutbound, info.Conns[0].Direction)
+	require.Equal(t, 1, info.Conns[0].Streams[proto])
+	require.NotEmpty(t, info.AddrRecords)
+	require.Eventually(t, func() bool {
+		for _, e := range h1.PeerInfo(h2.ID()).Events {
+			if _, ok := e.Event.(event.EvtPeerIdentificationCompleted); ok {
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, info.Conns[0].ID, host.GetPeerInfo(h1, h2.ID()).Conns[0].ID)
+
+	info = h1.PeerInfo(test.RandPeerIDFatal(t))
+	require.Equal(t, network.NotConnected, info.Connectedness)
+	require.Empty(t, info.Conns)
+}
+
 func assertWait(t *testing.T, c chan protocol.ID, exp protocol.ID) {
 	t.Helper()
 	select {
@@ -338,6 +461,7 @@ func TestHostProtoPreference(t *testing.T) {
 
 	// Prevent pushing identify information so this test works.
 	h1.RemoveStreamHandler(identify.IDPush)
+	h1.RemoveStreamHandler(identify.IDDelta)
 
 	h2.SetStreamHandler(protoOld, handler)
 
@@ -415,6 +539,7 @@ func TestHostProtoPreknowledge(t *testing.T) {
 
 	// Prevent pushing identify information so this test actually _uses_ the super protocol.
 	h1.RemoveStreamHandler(identify.IDPush)
+	h1.RemoveStreamHandler(identify.IDDelta)
 
 	h2pi := h2.Peerstore().PeerInfo(h2.ID())
 	// Filter to only 1 address so that we don't have to think about parallel
@@ -981,3 +1106,86 @@ func TestHostTimeoutNewStream(t *testing.T) {
 	require.Error(t, err)
 	require.ErrorContains(t, err, "context deadline exceeded")
 }
+
+func TestPin(t *testing.T) {
+	mgr, err := rcmgr.NewResourceManager(rcmgr.NewFixedLimiter(rcmgr.DefaultLimits.AutoScale()))
+	require.NoError(t, err)
+	defer mgr.Close()
+	cm, err := connmgr.NewConnManager(10, 20)
+	require.NoError(t, err)
+	defer cm.Close()
+	h, err := NewHost(swarmt.GenSwarm(t, swarmt.WithSwarmOpts(swarm.WithResourceManager(mgr))), &HostOpts{ConnManager: cm})
+	require.NoError(t, err)
+	defer h.Close()
+
+	p := test.RandPeerIDFatal(t)
+	addrs := []ma.Multiaddr{ma.StringCast("/ip4/1.2.3.4/tcp/1"), ma.StringCast("/ip6/2001:db8::1/udp/1/quic-v1")}
+	h.Peerstore().AddAddrs(p, addrs, peerstore.PermanentAddrTTL)
+	al := rcmgr.GetAllowlist(mgr)
+	// an entry added by the user must survive Unpin
+	require.NoError(t, al.Add(ma.StringCast("/ip4/1.2.3.4/ipcidr/32/p2p/"+p.String())))
+	require.False(t, h.IsPinned(p))
+
+	require.NoError(t, h.Pin(p))
+	require.NoError(t, h.Pin(p))
+	require.True(t, h.IsPinned(p))
+	require.True(t, cm.IsProtected(p, pinTag))
+	for _, a := range addrs {
+		require.True(t, al.AllowedPeerAndMultiaddr(p, a))
+		require.False(t, al.AllowedPeerAndMultiaddr(test.RandPeerIDFatal(t), a))
+	}
+	// only the known IP addresses are allowlisted
+	require.False(t, al.AllowedPeerAndMultiaddr(p, ma.StringCast("/ip4/5.6.7.8/tcp/1")))
+
+	// pinning again allowlists the addresses learned since
+	newAddr := ma.StringCast("/ip4/5.6.7.8/tcp/1")
+	h.Peerstore().AddAddr(p, newAddr, peerstore.PermanentAddrTTL)
+	require.NoError(t, h.Pin(p))
+	require.True(t, al.AllowedPeerAndMultiaddr(p, newAddr))
+
+	h.Unpin(p)
+	require.False(t, h.IsPinned(p))
+	require.False(t, cm.IsProtected(p, pinTag))
+	require.True(t, al.AllowedPeerAndMultiaddr(p, addrs[0]))
+	require.False(t, al.AllowedPeerAndMultiaddr(p, addrs[1]))
+	require.False(t, al.AllowedPeerAndMultiaddr(p, newAddr))
+}
+
+func TestAddRemoveListenAddrs(t *testing.T) {
+	h, err := NewHost(swarmt.GenSwarm(t, swarmt.OptDialOnly), nil)
+	require.NoError(t, err)
+	defer h.Close()
+	h.Start()
+
+	sub, err := h.EventBus().Subscribe(&event.EvtListenAddrsUpdated{})
+	require.NoError(t, err)
+	defer sub.Close()
+
+	nextEvent := func() event.EvtListenAddrsUpdated {
+		t.Helper()
+		select {
+		case e := <-sub.Out():
+			return e.(event.EvtListenAddrsUpdated)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for event")
+			return event.EvtListenAddrsUpdated{}
+		}
+	}
+
+	require.NoError(t, h.AddListenAddrs(ma.StringCast("/ip4/127.0.0.1/tcp/0")))
+	evt := nextEvent()
+	require.Len(t, evt.Added, 1)
+	require.Empty(t, evt.Removed)
+	laddr := evt.Added[0]
+	require.Equal(t, []ma.Multiaddr{laddr}, h.Network().ListenAddresses())
+	require.Eventually(t, func() bool { return slices.ContainsFunc(h.Addrs(), laddr.Equal) }, 5*time.Second, 10*time.Millisecond)
+
+	require.Error(t, h.AddListenAddrs(ma.StringCast("/ip4/1.2.3.4/tcp/1")))
+
+	require.NoError(t, h.RemoveListenAddrs(laddr))
+	evt = nextEvent()
+	require.Empty(t, evt.Added)
+	require.Equal(t, []ma.Multiaddr{laddr}, evt.Removed)
+	require.Empty(t, h.Network().ListenAddresses())
+	require.Eventually(t, func() bool { return !slices.ContainsFunc(h.Addrs(), laddr.Equal) }, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,58 @@
+package basichost
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/p2p/net/swarm"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// AddListenAddrs starts listening on addrs on the running host. It tries
+// every address and, unlike Network().Listen, returns an error if listening on
+// any of them fails. Listeners that were started aren't closed on error.
+//
+// An EvtListenAddrsUpdated event is emitted for every new listen address, and
+// the host's advertised addresses are updated.
+func (h *BasicHost) AddListenAddrs(addrs ...ma.Multiaddr) error {
+	var errs []error
+	for _, a := range addrs {
+		if err := h.Network().Listen(a); err != nil {
+			errs = append(errs, fmt.Errorf("failed to listen on %s: %w", a, err))
+		}
+	}
+	h.addressManager.triggerAddrsUpdate()
+	return errors.Join(errs...)
+}
+
+// RemoveListenAddrs stops listening on addrs on the running host. The
+// addresses must match the ones returned by Network().ListenAddresses().
+// Existing connections accepted on these addresses aren't closed.
+//
+// An EvtListenAddrsUpdated event is emitted for every closed listen address,
+// and the host's advertised addresses are updated.
+func (h *BasicHost) RemoveListenAddrs(addrs ...ma.Multiaddr) error {
+	n, ok := h.Network().(swarm.ListenCloser)
+	if !ok {
+		return errors.New("network doesn't support closing listeners")
+	}
+	n.ListenClose(addrs...)
+	h.addressManager.triggerAddrsUpdate()
+	return nil
+}
+
+// listenNotifee emits EvtListenAddrsUpdated when the network starts or stops
+// listening on an address.
+func (h *BasicHost) listenNotifee() network.Notifiee {
+	return &network.NotifyBundle{
+		ListenF: func(_ network.Network, a ma.Multiaddr) {
+			h.emitters.evtListenAddrsUpdated.Emit(event.EvtListenAddrsUpdated{Added: []ma.Multiaddr{a}})
+		},
+		ListenCloseF: func(_ network.Network, a ma.Multiaddr) {
+			h.emitters.evtListenAddrsUpdated.Emit(event.EvtListenAddrsUpdated{Removed: []ma.Multiaddr{a}})
+		},
+	}
+}
@@ -0,0 +1,96 @@
+package basichost
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
+)
+
+const (
+	// peerEventsPerPeer is the number of recent events kept per peer.
+	peerEventsPerPeer = 16
+	// maxPeersWithEvents is the number of peers events are kept for. The
+	// events of the peer recorded first are dropped beyond it.
+	maxPeersWithEvents = 1024
+)
+
+// peerEventLog records the recent events about peers, for host.PeerInfo.
+type peerEventLog struct {
+	mx     sync.Mutex
+	events map[peer.ID][]host.PeerEvent
+	// order holds the peers in the order their first event was recorded.
+	order []peer.ID
+}
+
+func newPeerEventLog() *peerEventLog {
+	return &peerEventLog{events: make(map[peer.ID][]host.PeerEvent)}
+}
+
+func (l *peerEventLog) start(ctx context.Context, bus event.Bus, wg *sync.WaitGroup) error {
+	sub, err := bus.Subscribe([]interface{}{
+		new(event.EvtPeerConnectednessChanged),
+		new(event.EvtPeerIdentificationCompleted),
+		new(event.EvtPeerIdentificationFailed),
+		new(event.EvtPeerProtocolsUpdated),
+	}, eventbus.Name("basichost (peer events)"), eventbus.BufSize(128))
+	if err != nil {
+		return err
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer sub.Close()
+		for {
+			select {
+			case e, ok := <-sub.Out():
+				if !ok {
+					return
+				}
+				now := time.Now()
+				switch evt := e.(type) {
+				case event.EvtPeerConnectednessChanged:
+					l.record(evt.Peer, now, evt)
+				case event.EvtPeerIdentificationCompleted:
+					l.record(evt.Peer, now, evt)
+				case event.EvtPeerIdentificationFailed:
+					l.record(evt.Peer, now, evt)
+				case event.EvtPeerProtocolsUpdated:
+					l.record(evt.Peer, now, evt)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (l *peerEventLog) record(p peer.ID, t time.Time, evt interface{}) {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+
+	events, ok := l.events[p]
+	if !ok {
+		if len(l.order) >= maxPeersWithEvents {
+			delete(l.events, l.order[0])
+			l.order = l.order[1:]
+		}
+		l.order = append(l.order, p)
+	}
+	if len(events) >= peerEventsPerPeer {
+		events = slices.Delete(events, 0, 1)
+	}
+	l.events[p] = append(events, host.PeerEvent{Time: t, Event: evt})
+}
+
+func (l *peerEventLog) get(p peer.ID) []host.PeerEvent {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	return slices.Clone(l.events[p])
+}
@@ -0,0 +1,110 @@
+package basichost
+
+import (
+	"net"
+	"slices"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// pinTag is the connection manager protection tag used for pinned peers.
+const pinTag = "libp2p-pinned"
+
+// multiaddrAllowlister is implemented by resource managers that allow
+// connections from allowlisted multiaddrs, like the default resource manager.
+type multiaddrAllowlister interface {
+	AllowlistAdd(ma.Multiaddr) error
+	AllowlistRemove(ma.Multiaddr) error
+}
+
+// pinAllowlistAddrs returns the allowlist entries that allow p to connect from
+// the IP addresses in addrs.
+func pinAllowlistAddrs(p peer.ID, addrs []ma.Multiaddr) []ma.Multiaddr {
+	var ips []net.IP
+	var res []ma.Multiaddr
+	for _, a := range addrs {
+		ip, err := manet.ToIP(a)
+		if err != nil {
+			continue
+		}
+		if slices.ContainsFunc(ips, ip.Equal) {
+			continue
+		}
+		ips = append(ips, ip)
+		if ip4 := ip.To4(); ip4 != nil {
+			res = append(res, ma.StringCast("/ip4/"+ip4.String()+"/ipcidr/32/p2p/"+p.String()))
+		} else {
+			res = append(res, ma.StringCast("/ip6/"+ip.String()+"/ipcidr/128/p2p/"+p.String()))
+		}
+	}
+	return res
+}
+
+// Pin pins the connections to p. Connections to pinned peers are never
+// trimmed by the connection manager. If the resource manager supports an
+// allowlist, like the default resource manager does, the IP addresses of p
+// that are known to the peerstore are allowlisted for p, so that connections
+// to p aren't denied when the system or transient connection limits are
+// reached. Instead, the allowlisted limits apply.
+//
+// Pin is a stronger alternative to protecting the peer using the connection
+// manager. Pinning a peer that is already pinned allowlists the IP addresses
+// learned since it was pinned.
+func (h *BasicHost) Pin(p peer.ID) error {
+	h.pinnedMx.Lock()
+	defer h.pinnedMx.Unlock()
+
+	added, pinned := h.pinned[p]
+	if al, ok := h.Network().ResourceManager().(multiaddrAllowlister); ok {
+		for _, a := range pinAllowlistAddrs(p, h.Peerstore().Addrs(p)) {
+			if slices.ContainsFunc(added, a.Equal) {
+				continue
+			}
+			if err := al.AllowlistAdd(a); err != nil {
+				if !pinned {
+					for _, a := range added {
+						al.AllowlistRemove(a)
+					}
+					return err
+				}
+				h.pinned[p] = added
+				return err
+			}
+			added = append(added, a)
+		}
+	}
+	h.cmgr.Protect(p, pinTag)
+	h.pinned[p] = added
+	return nil
+}
+
+// Unpin unpins the connections to p. It only removes the allowlist entries that
+// were added by Pin.
+func (h *BasicHost) Unpin(p peer.ID) {
+	h.pinnedMx.Lock()
+	defer h.pinnedMx.Unlock()
+
+	added, ok := h.pinned[p]
+	if !ok {
+		return
+	}
+	if al, ok := h.Network().ResourceManager().(multiaddrAllowlister); ok {
+		for _, a := range added {
+			al.AllowlistRemove(a)
+		}
+	}
+	h.cmgr.Unprotect(p, pinTag)
+	delete(h.pinned, p)
+}
+
+// IsPinned returns true if p is pinned.
+func (h *BasicHost) IsPinned(p peer.ID) bool {
+	h.pinnedMx.Lock()
+	defer h.pinnedMx.Unlock()
+
+	_, ok := h.pinned[p]
+	return ok
+}
@@ -0,0 +1,60 @@
+package basichost
+
+import (
+	"slices"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/p2p/host/autonat"
+	"github.com/libp2p/go-libp2p/p2p/protocol/autonatv2"
+	"github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/proto"
+	"github.com/libp2p/go-libp2p/p2p/protocol/holepunch"
+	"github.com/libp2p/go-libp2p/p2p/protocol/identify"
+	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
+)
+
+// Service is a built-in service a host can run.
+// ServiceIdentifyPush covers both identify push and the identify delta protocol.
+type Service string
+
+const (
+	ServiceIdentify        Service = "identify"
+	ServiceIdentifyPush    Service = "identify-push"
+	ServicePing            Service = "ping"
+	ServiceHolePunching    Service = "holepunch"
+	ServiceRelay           Service = "relay"
+	ServiceRelayStop       Service = "relay-stop"
+	ServiceAutoNAT         Service = "autonat"
+	ServiceAutoNATv2Client Service = "autonatv2-client"
+	ServiceAutoNATv2Server Service = "autonatv2-server"
+)
+
+// serviceProtocols maps a service to the protocols it handles.
+var serviceProtocols = map[Service][]protocol.ID{
+	ServiceIdentify:        {identify.ID},
+	ServiceIdentifyPush:    {identify.IDPush, identify.IDDelta},
+	ServicePing:            {ping.ID},
+	ServiceHolePunching:    {holepunch.Protocol},
+	ServiceRelay:           {proto.ProtoIDv2Hop},
+	ServiceRelayStop:       {proto.ProtoIDv2Stop},
+	ServiceAutoNAT:         {autonat.AutoNATProto},
+	ServiceAutoNATv2Client: {autonatv2.DialBackProtocol},
+	ServiceAutoNATv2Server: {autonatv2.DialProtocol},
+}
+
+// RunningServices returns the built-in services currently running on the host,
+// sorted by name. A service is considered running if a stream handler for one
+// of its protocols is registered.
+// Some services are only started once the host's reachability is known, e.g.
+// the relay service and the hole punching service.
+func RunningServices(h host.Host) []Service {
+	registered := h.Mux().Protocols()
+	var running []Service
+	for svc, protos := range serviceProtocols {
+		if slices.ContainsFunc(protos, func(p protocol.ID) bool { return slices.Contains(registered, p) }) {
+			running = append(running, svc)
+		}
+	}
+	slices.Sort(running)
+	return running
+}
@@ -129,7 +129,7 @@ func (bh *BlankHost) Close() error {
 
 func (bh *BlankHost) Connect(ctx context.Context, ai peer.AddrInfo) error {
 	// absorb addresses into peerstore
-	bh.Peerstore().AddAddrs(ai.ID, ai.Addrs, peerstore.TempAddrTTL)
+	peerstore.AddAddrsWithSource(bh.Peerstore(), ai.ID, ai.Addrs, peerstore.TempAddrTTL, peerstore.AddrSourceManual)
 
 	cs := bh.n.ConnsToPeer(ai.ID)
 	if len(cs) > 0 {
@@ -0,0 +1,164 @@
+package peerstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	ic "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	pstore "github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/core/record"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// exportedPeer is the serialization of a peer used by ExportPeers.
+type exportedPeer struct {
+	ID peer.ID `json:"id"`
+	// SignedPeerRecord is the marshaled envelope of the peer's signed peer
+	// record. If present, Addrs is empty.
+	SignedPeerRecord []byte            `json:"signedPeerRecord,omitempty"`
+	Addrs            []string          `json:"addrs,omitempty"`
+	PublicKey        []byte            `json:"publicKey,omitempty"`
+	Protocols        []protocol.ID     `json:"protocols,omitempty"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+}
+
+// ExportPeers writes all peers of ps to w, as a stream of JSON objects. A
+// peer's addresses are exported as its signed peer record if ps has one, and
+// as plain addresses otherwise. The public key, the protocols, and the
+// metadata with string values are exported as well. Private keys are never
+// exported.
+//
+// The output can be read by ImportPeers, e.g. to restore a backup, to share a
+// bootstrap list, or to migrate to another peerstore implementation.
+func ExportPeers(ps pstore.Peerstore, w io.Writer) error {
+	cab, _ := pstore.GetCertifiedAddrBook(ps)
+	enc := json.NewEncoder(w)
+	for _, p := range ps.Peers() {
+		ep := exportedPeer{ID: p, Metadata: make(map[string]string)}
+		if cab != nil {
+			if env := cab.GetPeerRecord(p); env != nil {
+				b, err := env.Marshal()
+				if err != nil {
+					return fmt.Errorf("failed to marshal peer record of %s: %w", p, err)
+				}
+				ep.SignedPeerRecord = b
+			}
+		}
+		if ep.SignedPeerRecord == nil {
+			for _, a := range ps.Addrs(p) {
+				ep.Addrs = append(ep.Addrs, a.String())
+			}
+		}
+		// Public keys that can be extracted from the peer ID don't need to be exported.
+		if pk := ps.PubKey(p); pk != nil {
+			if _, err := p.ExtractPublicKey(); err != nil {
+				b, err := ic.MarshalPublicKey(pk)
+				if err != nil {
+					return fmt.Errorf("failed to marshal public key of %s: %w", p, err)
+				}
+				ep.PublicKey = b
+			}
+		}
+		protos, err := ps.GetProtocols(p)
+		if err != nil {
+			return fmt.Errorf("failed to get protocols of %s: %w", p, err)
+		}
+		ep.Protocols = protos
+		for _, key := range []string{"AgentVersion", "ProtocolVersion"} {
+			if v, err := ps.Get(p, key); err == nil {
+				if s, ok := v.(string); ok {
+					ep.Metadata[key] = s
+				}
+			}
+		}
+		if err := enc.Encode(ep); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportPeers reads peers written by ExportPeers from r, and adds them to ps.
+// Their addresses are added with the given TTL. Signed peer records are
+// verified before they are added. It returns the number of imported peers.
+func ImportPeers(ps pstore.Peerstore, r io.Reader, ttl time.Duration) (int, error) {
+	dec := json.NewDecoder(r)
+	var n int
+	for {
+		var ep exportedPeer
+		if err := dec.Decode(&ep); err != nil {
+			if errors.Is(err, io.EOF) {
+				return n, nil
+			}
+			return n, fmt.Errorf("failed to decode peer: %w", err)
+		}
+		if err := importPeer(ps, &ep, ttl); err != nil {
+			return n, fmt.Errorf("failed to import peer %s: %w", ep.ID, err)
+		}
+		n++
+	}
+}
+
+func importPeer(ps pstore.Peerstore, ep *exportedPeer, ttl time.Duration) error {
+	if err := ep.ID.Validate(); err != nil {
+		return err
+	}
+	if ep.PublicKey != nil {
+		pk, err := ic.UnmarshalPublicKey(ep.PublicKey)
+		if err != nil {
+			return err
+		}
+		if !ep.ID.MatchesPublicKey(pk) {
+			return errors.New("public key doesn't match the peer ID")
+		}
+		if err := ps.AddPubKey(ep.ID, pk); err != nil {
+			return err
+		}
+	}
+
+	if ep.SignedPeerRecord != nil {
+		env, rec, err := record.ConsumeEnvelope(ep.SignedPeerRecord, peer.PeerRecordEnvelopeDomain)
+		if err != nil {
+			return fmt.Errorf("invalid signed peer record: %w", err)
+		}
+		pr, ok := rec.(*peer.PeerRecord)
+		if !ok || pr.PeerID != ep.ID {
+			return errors.New("signed peer record doesn't belong to the peer")
+		}
+		if cab, ok := pstore.GetCertifiedAddrBook(ps); ok {
+			if _, err := cab.ConsumePeerRecord(env, ttl); err != nil {
+				return err
+			}
+		} else {
+			pstore.AddAddrsWithSource(ps, ep.ID, pr.Addrs, ttl, pstore.AddrSourceManual)
+		}
+	} else if len(ep.Addrs) > 0 {
+		addrs := make([]ma.Multiaddr, 0, len(ep.Addrs))
+		for _, s := range ep.Addrs {
+			a, err := ma.NewMultiaddr(s)
+			if err != nil {
+				return err
+			}
+			addrs = append(addrs, a)
+		}
+		pstore.AddAddrsWithSource(ps, ep.ID, addrs, ttl, pstore.AddrSourceManual)
+	}
+
+	if len(ep.Protocols) > 0 {
+		if err := ps.AddProtocols(ep.ID, ep.Protocols...); err != nil {
+			return err
+		}
+	}
+	for k, v := range ep.Metadata {
+		if err := ps.Put(ep.ID, k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
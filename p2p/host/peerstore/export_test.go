@@ -0,0 +1,97 @@
+package peerstore_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	ic "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	pstore "github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/core/record"
+	"github.com/libp2p/go-libp2p/core/test"
+	"github.com/libp2p/go-libp2p/p2p/host/peerstore"
+	"github.com/libp2p/go-libp2p/p2p/host/peerstore/pstoremem"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImportPeers(t *testing.T) {
+	ps, err := pstoremem.NewPeerstore()
+	require.NoError(t, err)
+	defer ps.Close()
+
+	// A peer with a signed peer record.
+	priv, _, err := test.RandTestKeyPair(ic.Ed25519, 256)
+	require.NoError(t, err)
+	signed, err := peer.IDFromPrivateKey(priv)
+	require.NoError(t, err)
+	signedAddr := ma.StringCast("/ip4/1.2.3.4/tcp/1")
+	env, err := record.Seal(peer.PeerRecordFromAddrInfo(peer.AddrInfo{ID: signed, Addrs: []ma.Multiaddr{signedAddr}}), priv)
+	require.NoError(t, err)
+	_, err = ps.ConsumePeerRecord(env, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, ps.AddProtocols(signed, "/proto/1", "/proto/2"))
+	require.NoError(t, ps.Put(signed, "AgentVersion", "foo"))
+
+	// A peer with plain addresses, and a public key that isn't inlined.
+	_, pub, err := test.RandTestKeyPair(ic.RSA, 2048)
+	require.NoError(t, err)
+	unsigned, err := peer.IDFromPublicKey(pub)
+	require.NoError(t, err)
+	unsignedAddr := ma.StringCast("/ip4/5.6.7.8/udp/1/quic-v1")
+	ps.AddAddr(unsigned, unsignedAddr, time.Hour)
+	require.NoError(t, ps.AddPubKey(unsigned, pub))
+
+	var buf bytes.Buffer
+	require.NoError(t, peerstore.ExportPeers(ps, &buf))
+
+	ps2, err := pstoremem.NewPeerstore()
+	require.NoError(t, err)
+	defer ps2.Close()
+	n, err := peerstore.ImportPeers(ps2, &buf, pstore.PermanentAddrTTL)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+
+	require.Equal(t, []ma.Multiaddr{signedAddr}, ps2.Addrs(signed))
+	require.NotNil(t, ps2.GetPeerRecord(signed))
+	protos, err := ps2.GetProtocols(signed)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []protocol.ID{"/proto/1", "/proto/2"}, protos)
+	av, err := ps2.Get(signed, "AgentVersion")
+	require.NoError(t, err)
+	require.Equal(t, "foo", av)
+
+	require.Equal(t, []ma.Multiaddr{unsignedAddr}, ps2.Addrs(unsigned))
+	require.True(t, pub.Equals(ps2.PubKey(unsigned)))
+}
+
+func TestImportPeersInvalidRecord(t *testing.T) {
+	ps, err := pstoremem.NewPeerstore()
+	require.NoError(t, err)
+	defer ps.Close()
+
+	priv, _, err := test.RandTestKeyPair(ic.Ed25519, 256)
+	require.NoError(t, err)
+	id, err := peer.IDFromPrivateKey(priv)
+	require.NoError(t, err)
+	other := test.RandPeerIDFatal(t)
+	env, err := record.Seal(peer.PeerRecordFromAddrInfo(peer.AddrInfo{ID: id, Addrs: []ma.Multiaddr{ma.StringCast("/ip4/1.2.3.4/tcp/1")}}), priv)
+	require.NoError(t, err)
+
+	// The record is claimed to belong to another peer.
+	ps2, err := pstoremem.NewPeerstore()
+	require.NoError(t, err)
+	defer ps2.Close()
+	_, err = ps2.ConsumePeerRecord(env, time.Hour)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, peerstore.ExportPeers(ps2, &buf))
+	input := strings.Replace(buf.String(), `"id":"`+id.String()+`"`, `"id":"`+other.String()+`"`, 1)
+	_, err = peerstore.ImportPeers(ps, strings.NewReader(input), time.Hour)
+	require.ErrorContains(t, err, "doesn't belong to the peer")
+	require.Empty(t, ps.Addrs(other))
+}
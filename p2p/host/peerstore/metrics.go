@@ -1,10 +1,13 @@
 package peerstore
 
 import (
+	"math"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/libp2p/go-libp2p/core/peer"
+	pstore "github.com/libp2p/go-libp2p/core/peerstore"
 )
 
 // LatencyEWMASmoothing governs the decay of the EWMA (the speed
@@ -12,26 +15,95 @@ import (
 // 1 is 100% change, 0 is no change.
 var LatencyEWMASmoothing = 0.1
 
+// latencySamples is the number of recent latency samples kept per peer and
+// transport.
+const latencySamples = 64
+
+// latencyRing holds the recent latency samples of a peer over a transport.
+type latencyRing struct {
+	samples [latencySamples]time.Duration
+	// next is the index the next sample is written to.
+	next int
+	n    int
+	// jitter is the RFC 3550 interarrival jitter, in nanoseconds.
+	jitter float64
+}
+
+func (r *latencyRing) add(rtt time.Duration) {
+	if r.n > 0 {
+		d := math.Abs(float64(rtt - r.last()))
+		r.jitter += (d - r.jitter) / 16
+	}
+	r.samples[r.next] = rtt
+	r.next = (r.next + 1) % latencySamples
+	if r.n < latencySamples {
+		r.n++
+	}
+}
+
+func (r *latencyRing) last() time.Duration {
+	return r.samples[(r.next+latencySamples-1)%latencySamples]
+}
+
+func (r *latencyRing) stats() pstore.LatencyStats {
+	sorted := make([]time.Duration, r.n)
+	copy(sorted, r.samples[:r.n])
+	slices.Sort(sorted)
+	// nearest-rank percentile
+	percentile := func(q float64) time.Duration {
+		return sorted[int(math.Ceil(q*float64(r.n)))-1]
+	}
+	return pstore.LatencyStats{
+		Samples: r.n,
+		Last:    r.last(),
+		Min:     sorted[0],
+		Max:     sorted[r.n-1],
+		P50:     percentile(0.5),
+		P90:     percentile(0.9),
+		P99:     percentile(0.99),
+		Jitter:  time.Duration(r.jitter),
+	}
+}
+
 type metrics struct {
 	mutex  sync.RWMutex
 	latmap map[peer.ID]time.Duration
+	// samples holds the recent samples of peers by transport. The samples of
+	// all transports are also kept under the empty transport.
+	samples map[peer.ID]map[string]*latencyRing
 }
 
+var _ pstore.LatencyTracker = (*metrics)(nil)
+
 func NewMetrics() *metrics {
 	return &metrics{
-		latmap: make(map[peer.ID]time.Duration),
+		latmap:  make(map[peer.ID]time.Duration),
+		samples: make(map[peer.ID]map[string]*latencyRing),
 	}
 }
 
 // RecordLatency records a new latency measurement
 func (m *metrics) RecordLatency(p peer.ID, next time.Duration) {
+	m.mutex.Lock()
+	m.recordUnlocked(p, "", next)
+	m.mutex.Unlock()
+}
+
+// RecordLatencySample records a new latency measurement made over the given
+// transport.
+func (m *metrics) RecordLatencySample(p peer.ID, transport string, rtt time.Duration) {
+	m.mutex.Lock()
+	m.recordUnlocked(p, transport, rtt)
+	m.mutex.Unlock()
+}
+
+func (m *metrics) recordUnlocked(p peer.ID, transport string, next time.Duration) {
 	nextf := float64(next)
 	s := LatencyEWMASmoothing
 	if s > 1 || s < 0 {
 		s = 0.1 // ignore the knob. it's broken. look, it jiggles.
 	}
 
-	m.mutex.Lock()
 	ewma, found := m.latmap[p]
 	ewmaf := float64(ewma)
 	if !found {
@@ -40,7 +112,25 @@ func (m *metrics) RecordLatency(p peer.ID, next time.Duration) {
 		nextf = ((1.0 - s) * ewmaf) + (s * nextf)
 		m.latmap[p] = time.Duration(nextf)
 	}
-	m.mutex.Unlock()
+
+	rings, ok := m.samples[p]
+	if !ok {
+		rings = make(map[string]*latencyRing, 2)
+		m.samples[p] = rings
+	}
+	m.ringUnlocked(rings, "").add(next)
+	if transport != "" {
+		m.ringUnlocked(rings, transport).add(next)
+	}
+}
+
+func (m *metrics) ringUnlocked(rings map[string]*latencyRing, transport string) *latencyRing {
+	r, ok := rings[transport]
+	if !ok {
+		r = &latencyRing{}
+		rings[transport] = r
+	}
+	return r
 }
 
 // LatencyEWMA returns an exponentially-weighted moving avg.
@@ -51,8 +141,28 @@ func (m *metrics) LatencyEWMA(p peer.ID) time.Duration {
 	return m.latmap[p]
 }
 
+// LatencyStats returns the stats of the recent latency samples of a peer
+// measured over the given transport, or over all transports if it's empty.
+func (m *metrics) LatencyStats(p peer.ID, transport string) (pstore.LatencyStats, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	r, ok := m.samples[p][transport]
+	if !ok {
+		return pstore.LatencyStats{}, false
+	}
+	return r.stats(), true
+}
+
+// RemoveLatencySamples removes the recent latency samples of a peer.
+func (m *metrics) RemoveLatencySamples(p peer.ID) {
+	m.mutex.Lock()
+	delete(m.samples, p)
+	m.mutex.Unlock()
+}
+
 func (m *metrics) RemovePeer(p peer.ID) {
 	m.mutex.Lock()
 	delete(m.latmap, p)
+	delete(m.samples, p)
 	m.mutex.Unlock()
 }
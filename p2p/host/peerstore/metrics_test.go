@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/libp2p/go-libp2p/core/test"
+
+	"github.com/stretchr/testify/require"
 )
 
 func TestLatencyEWMAFun(t *testing.T) {
@@ -61,3 +63,62 @@ func TestLatencyEWMA(t *testing.T) {
 		t.Fatalf("latency outside of expected range. expected %d ± %d, got %d", exp, sig, lat)
 	}
 }
+
+func TestLatencyStats(t *testing.T) {
+	m := NewMetrics()
+	id, err := test.RandPeerID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, ok := m.LatencyStats(id, "")
+	require.False(t, ok)
+
+	for i := 1; i <= 100; i++ {
+		m.RecordLatencySample(id, "tcp", time.Duration(i)*time.Millisecond)
+	}
+	m.RecordLatencySample(id, "quic-v1", 5*time.Millisecond)
+	m.RecordLatencySample(id, "quic-v1", 7*time.Millisecond)
+
+	// Only the most recent samples are kept.
+	stats, ok := m.LatencyStats(id, "tcp")
+	require.True(t, ok)
+	require.Equal(t, latencySamples, stats.Samples)
+	require.Equal(t, 100*time.Millisecond, stats.Last)
+	require.Equal(t, (100-latencySamples+1)*time.Millisecond, stats.Min)
+	require.Equal(t, 100*time.Millisecond, stats.Max)
+	require.Equal(t, (100-latencySamples/2)*time.Millisecond, stats.P50)
+	require.Equal(t, 100*time.Millisecond, stats.P99)
+	// The jitter converges to the constant difference between samples.
+	require.InDelta(t, float64(time.Millisecond), float64(stats.Jitter), float64(100*time.Microsecond))
+
+	stats, ok = m.LatencyStats(id, "quic-v1")
+	require.True(t, ok)
+	require.Equal(t, 2, stats.Samples)
+	require.Equal(t, 5*time.Millisecond, stats.Min)
+	require.Equal(t, 5*time.Millisecond, stats.P50)
+	require.Equal(t, 7*time.Millisecond, stats.P90)
+	require.Equal(t, 2*time.Millisecond/16, stats.Jitter)
+
+	// Samples recorded without a transport only count towards the aggregate.
+	m.RecordLatency(id, time.Second)
+	stats, ok = m.LatencyStats(id, "")
+	require.True(t, ok)
+	require.Equal(t, latencySamples, stats.Samples)
+	require.Equal(t, time.Second, stats.Last)
+	require.Equal(t, time.Second, stats.Max)
+	_, ok = m.LatencyStats(id, "websocket")
+	require.False(t, ok)
+	require.NotZero(t, m.LatencyEWMA(id))
+
+	m.RemoveLatencySamples(id)
+	_, ok = m.LatencyStats(id, "tcp")
+	require.False(t, ok)
+	require.NotZero(t, m.LatencyEWMA(id))
+
+	m.RecordLatencySample(id, "tcp", time.Millisecond)
+	m.RemovePeer(id)
+	_, ok = m.LatencyStats(id, "tcp")
+	require.False(t, ok)
+	require.Zero(t, m.LatencyEWMA(id))
+}
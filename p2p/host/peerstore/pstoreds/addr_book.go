@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"maps"
+	"slices"
 	"sort"
 	"sync"
 	"time"
@@ -142,6 +144,13 @@ type dsAddrBook struct {
 	gc          *dsAddrBookGc
 	subsManager *pstoremem.AddrSubManager
 
+	// pending holds the records that still need to be written to the
+	// datastore, if write-behind is enabled.
+	pendingMx sync.Mutex
+	pending   map[peer.ID]*addrsRecord
+	// flushMx serializes flushes of the pending records.
+	flushMx sync.Mutex
+
 	// controls children goroutine lifetime.
 	childrenDone sync.WaitGroup
 	cancelFn     func()
@@ -186,7 +195,14 @@ var _ pstore.CertifiedAddrBook = (*dsAddrBook)(nil)
 //     the range of possible TTL values is small and the values themselves are also extreme, e.g. 10 minutes or
 //     permanent, popular values used in other libp2p modules. In this cited case, optimizing with lookahead windows
 //     makes little sense.
+//
+// By default, every modification is written to the datastore immediately. Setting Options.WriteBehindInterval batches
+// the writes instead, see Options for details.
 func NewAddrBook(ctx context.Context, store ds.Batching, opts Options) (ab *dsAddrBook, err error) {
+	if opts.WriteBehindInterval < 0 {
+		return nil, fmt.Errorf("negative write-behind interval provided: %s", opts.WriteBehindInterval)
+	}
+
 	ctx, cancelFn := context.WithCancel(ctx)
 	ab = &dsAddrBook{
 		ctx:         ctx,
@@ -194,6 +210,7 @@ func NewAddrBook(ctx context.Context, store ds.Batching, opts Options) (ab *dsAd
 		opts:        opts,
 		cancelFn:    cancelFn,
 		subsManager: pstoremem.NewAddrSubManager(),
+		pending:     make(map[peer.ID]*addrsRecord),
 		clock:       realclock{},
 	}
 
@@ -213,9 +230,15 @@ func NewAddrBook(ctx context.Context, store ds.Batching, opts Options) (ab *dsAd
 		return nil, err
 	}
 
+	if opts.WriteBehindInterval > 0 {
+		ab.childrenDone.Add(1)
+		go ab.writeBehind()
+	}
+
 	return ab, nil
 }
 
+// Close stops the background processes, and flushes the pending writes.
 func (ab *dsAddrBook) Close() error {
 	ab.cancelFn()
 	ab.childrenDone.Wait()
@@ -235,11 +258,22 @@ func (ab *dsAddrBook) loadRecord(id peer.ID, cache bool, update bool) (pr *addrs
 		defer pr.Unlock()
 
 		if pr.clean(ab.clock.Now()) && update {
-			err = pr.flush(ab.ds)
+			err = ab.persist(pr)
 		}
 		return pr, err
 	}
 
+	// a record that wasn't written yet is more recent than the datastore.
+	if pr, ok := ab.loadPending(id); ok {
+		pr.Lock()
+		pr.clean(ab.clock.Now())
+		pr.Unlock()
+		if cache {
+			ab.cache.Add(id, pr)
+		}
+		return pr, nil
+	}
+
 	pr = &addrsRecord{AddrBookRecord: &pb.AddrBookRecord{}}
 	key := addrBookBase.ChildString(b32.RawStdEncoding.EncodeToString([]byte(id)))
 	data, err := ab.ds.Get(context.TODO(), key)
@@ -254,7 +288,7 @@ func (ab *dsAddrBook) loadRecord(id peer.ID, cache bool, update bool) (pr *addrs
 		}
 		// this record is new and local for now (not in cache), so we don't need to lock.
 		if pr.clean(ab.clock.Now()) && update {
-			err = pr.flush(ab.ds)
+			err = ab.persist(pr)
 		}
 	default:
 		return nil, err
@@ -352,8 +386,7 @@ func (ab *dsAddrBook) storeSignedPeerRecord(p peer.ID, envelope *record.Envelope
 		Raw: envelopeBytes,
 	}
 	pr.dirty = true
-	err = pr.flush(ab.ds)
-	return err
+	return ab.persist(pr)
 }
 
 // GetPeerRecord returns a record.Envelope containing a peer.PeerRecord for the
@@ -415,7 +448,7 @@ func (ab *dsAddrBook) UpdateAddrs(p peer.ID, oldTTL time.Duration, newTTL time.D
 	}
 
 	if pr.clean(ab.clock.Now()) {
-		pr.flush(ab.ds)
+		ab.persist(pr)
 	}
 }
 
@@ -450,6 +483,26 @@ func (ab *dsAddrBook) PeersWithAddrs() peer.IDSlice {
 	if err != nil {
 		log.Errorf("error while retrieving peers with addresses: %v", err)
 	}
+
+	// persist takes pendingMx while holding a record's lock. Snapshot the
+	// pending records, so that we don't lock them while holding pendingMx.
+	ab.pendingMx.Lock()
+	pending := maps.Clone(ab.pending)
+	ab.pendingMx.Unlock()
+	if len(pending) == 0 {
+		return ids
+	}
+	ids = slices.DeleteFunc(ids, func(p peer.ID) bool {
+		_, ok := pending[p]
+		return ok
+	})
+	for p, pr := range pending {
+		pr.RLock()
+		if len(pr.Addrs) > 0 {
+			ids = append(ids, p)
+		}
+		pr.RUnlock()
+	}
 	return ids
 }
 
@@ -462,7 +515,14 @@ func (ab *dsAddrBook) AddrStream(ctx context.Context, p peer.ID) <-chan ma.Multi
 
 // ClearAddrs will delete all known addresses for a peer ID.
 func (ab *dsAddrBook) ClearAddrs(p peer.ID) {
+	// make sure that a concurrent flush doesn't write the record after we deleted it.
+	ab.flushMx.Lock()
+	defer ab.flushMx.Unlock()
+
 	ab.cache.Remove(p)
+	ab.pendingMx.Lock()
+	delete(ab.pending, p)
+	ab.pendingMx.Unlock()
 
 	key := addrBookBase.ChildString(b32.RawStdEncoding.EncodeToString([]byte(p)))
 	if err := ab.ds.Delete(context.TODO(), key); err != nil {
@@ -549,7 +609,7 @@ func (ab *dsAddrBook) setAddrs(p peer.ID, addrs []ma.Multiaddr, ttl time.Duratio
 
 	pr.dirty = true
 	pr.clean(ab.clock.Now())
-	return pr.flush(ab.ds)
+	return ab.persist(pr)
 }
 
 // deletes addresses in place, avoiding copies until we encounter the first deletion.
@@ -595,7 +655,7 @@ func (ab *dsAddrBook) deleteAddrs(p peer.ID, addrs []ma.Multiaddr) (err error) {
 
 	pr.dirty = true
 	pr.clean(ab.clock.Now())
-	return pr.flush(ab.ds)
+	return ab.persist(pr)
 }
 
 func cleanAddrs(addrs []ma.Multiaddr, pid peer.ID) []ma.Multiaddr {
@@ -5,12 +5,18 @@ import (
 	"testing"
 	"time"
 
+	ic "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
 	pstore "github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/core/test"
 	pt "github.com/libp2p/go-libp2p/p2p/host/peerstore/test"
 
 	mockclock "github.com/benbjohnson/clock"
 	ds "github.com/ipfs/go-datastore"
 	"github.com/ipfs/go-datastore/sync"
+	b32 "github.com/multiformats/go-base32"
+	ma "github.com/multiformats/go-multiaddr"
 	"github.com/stretchr/testify/require"
 )
 
@@ -48,6 +54,40 @@ func TestDsPeerstore(t *testing.T) {
 	}
 }
 
+func TestDsNotifyProtocolsChanged(t *testing.T) {
+	store, closeFn := mapDBStore(t)
+	defer closeFn()
+	ps, err := NewPeerstore(context.Background(), store, DefaultOpts())
+	require.NoError(t, err)
+	defer ps.Close()
+
+	type change struct {
+		added, removed []protocol.ID
+	}
+	var changes []change
+	cancel := ps.NotifyProtocolsChanged(func(_ peer.ID, added, removed []protocol.ID) {
+		changes = append(changes, change{added: added, removed: removed})
+	})
+
+	p := test.RandPeerIDFatal(t)
+	require.NoError(t, ps.AddProtocols(p, "/a", "/b"))
+	// Nothing changes, so we're not notified.
+	require.NoError(t, ps.AddProtocols(p, "/a"))
+	require.NoError(t, ps.RemoveProtocols(p, "/c"))
+	require.NoError(t, ps.SetProtocols(p, "/b", "/c"))
+	require.NoError(t, ps.RemoveProtocols(p, "/b"))
+	ps.RemovePeer(p)
+	require.Len(t, changes, 4)
+	require.ElementsMatch(t, []protocol.ID{"/a", "/b"}, changes[0].added)
+	require.Equal(t, change{added: []protocol.ID{"/c"}, removed: []protocol.ID{"/a"}}, changes[1])
+	require.Equal(t, change{removed: []protocol.ID{"/b"}}, changes[2])
+	require.Equal(t, []protocol.ID{"/c"}, changes[3].removed)
+
+	cancel()
+	require.NoError(t, ps.AddProtocols(p, "/a"))
+	require.Len(t, changes, 4)
+}
+
 func TestDsAddrBook(t *testing.T) {
 	for name, dsFactory := range dstores {
 		t.Run(name+" Cacheful", func(t *testing.T) {
@@ -69,17 +109,190 @@ func TestDsAddrBook(t *testing.T) {
 
 			pt.TestAddrBook(t, addressBookFactory(t, dsFactory, opts), clk)
 		})
+
+		t.Run(name+" WriteBehind", func(t *testing.T) {
+			opts := DefaultOpts()
+			opts.GCPurgeInterval = 1 * time.Second
+			opts.CacheSize = 0
+			opts.WriteBehindInterval = 10 * time.Millisecond
+			clk := mockclock.NewMock()
+			opts.Clock = clk
+
+			pt.TestAddrBook(t, addressBookFactory(t, dsFactory, opts), clk)
+		})
 	}
 }
 
+func TestDsAddrBookWriteBehind(t *testing.T) {
+	store, closeFn := mapDBStore(t)
+	defer closeFn()
+
+	opts := DefaultOpts()
+	opts.CacheSize = 0
+	opts.WriteBehindInterval = time.Hour
+	ab, err := NewAddrBook(context.Background(), store, opts)
+	require.NoError(t, err)
+
+	p := test.RandPeerIDFatal(t)
+	addr := ma.StringCast("/ip4/1.2.3.4/tcp/1")
+	ab.AddAddr(p, addr, time.Hour)
+
+	// The address isn't written yet, but is already visible.
+	has, err := store.Has(context.Background(), addrBookBase.ChildString(b32.RawStdEncoding.EncodeToString([]byte(p))))
+	require.NoError(t, err)
+	require.False(t, has)
+	require.Equal(t, []ma.Multiaddr{addr}, ab.Addrs(p))
+	require.Equal(t, peer.IDSlice{p}, ab.PeersWithAddrs())
+
+	// Closing the address book flushes the pending writes.
+	require.NoError(t, ab.Close())
+	ab, err = NewAddrBook(context.Background(), store, opts)
+	require.NoError(t, err)
+	defer ab.Close()
+	require.Equal(t, []ma.Multiaddr{addr}, ab.Addrs(p))
+
+	ab.ClearAddrs(p)
+	require.NoError(t, ab.Flush())
+	require.Empty(t, ab.PeersWithAddrs())
+}
+
 func TestDsKeyBook(t *testing.T) {
 	for name, dsFactory := range dstores {
 		t.Run(name, func(t *testing.T) {
 			pt.TestKeyBook(t, keyBookFactory(t, dsFactory, DefaultOpts()))
 		})
+
+		t.Run(name+" Encrypted", func(t *testing.T) {
+			opts := DefaultOpts()
+			c, err := NewPassphraseCipher([]byte("foobar"))
+			require.NoError(t, err)
+			opts.PrivKeyCipher = c
+			pt.TestKeyBook(t, keyBookFactory(t, dsFactory, opts))
+		})
 	}
 }
 
+func TestDsScoreBook(t *testing.T) {
+	for name, dsFactory := range dstores {
+		t.Run(name, func(t *testing.T) {
+			opts := DefaultOpts()
+			clk := mockclock.NewMock()
+			opts.Clock = clk
+			opts.ScoreDecay = map[string]time.Duration{"decaying": time.Hour}
+			pt.TestScoreBook(t, scoreBookFactory(t, dsFactory, opts), clk)
+		})
+	}
+}
+
+func TestDsMetadataTTL(t *testing.T) {
+	store, closeFn := mapDBStore(t)
+	defer closeFn()
+
+	opts := DefaultOpts()
+	clk := mockclock.NewMock()
+	opts.Clock = clk
+	pm, err := NewPeerMetadata(context.Background(), store, opts)
+	require.NoError(t, err)
+
+	p := test.RandPeerIDFatal(t)
+	require.NoError(t, pm.PutWithTTL(p, "transient", "a", time.Minute))
+	require.NoError(t, pm.Put(p, "permanent", "b"))
+	v, err := pm.Get(p, "transient")
+	require.NoError(t, err)
+	require.Equal(t, "a", v)
+
+	clk.Add(time.Minute)
+	_, err = pm.Get(p, "transient")
+	require.ErrorIs(t, err, pstore.ErrNotFound)
+	v, err = pm.Get(p, "permanent")
+	require.NoError(t, err)
+	require.Equal(t, "b", v)
+
+	// Writing a value with a TTL removes the expired values.
+	other := test.RandPeerIDFatal(t)
+	require.NoError(t, pm.PutWithTTL(other, "transient", "c", time.Hour))
+	has, err := store.Has(context.Background(), pmBase.ChildString(b32.RawStdEncoding.EncodeToString([]byte(p))).ChildString("transient"))
+	require.NoError(t, err)
+	require.False(t, has)
+
+	// Put removes the TTL, and the sweep keeps the value.
+	require.NoError(t, pm.Put(other, "transient", "d"))
+	clk.Add(2 * time.Hour)
+	require.NoError(t, pm.PutWithTTL(p, "transient", "e", time.Hour))
+	v, err = pm.Get(other, "transient")
+	require.NoError(t, err)
+	require.Equal(t, "d", v)
+}
+
+func TestDsKeyBookEncryption(t *testing.T) {
+	store, closeFn := mapDBStore(t)
+	defer closeFn()
+
+	newKeyBook := func(passphrase string) *dsKeyBook {
+		opts := DefaultOpts()
+		c, err := NewPassphraseCipher([]byte(passphrase))
+		require.NoError(t, err)
+		opts.PrivKeyCipher = c
+		kb, err := NewKeyBook(context.Background(), store, opts)
+		require.NoError(t, err)
+		return kb
+	}
+
+	priv, _, err := test.RandTestKeyPair(ic.Ed25519, 256)
+	require.NoError(t, err)
+	p, err := peer.IDFromPrivateKey(priv)
+	require.NoError(t, err)
+	require.NoError(t, newKeyBook("foobar").AddPrivKey(p, priv))
+
+	// The key isn't stored in plain text.
+	raw, err := ic.MarshalPrivateKey(priv)
+	require.NoError(t, err)
+	stored, err := store.Get(context.Background(), peerToKey(p, privSuffix))
+	require.NoError(t, err)
+	require.NotContains(t, string(stored), string(raw))
+
+	// The key can be decrypted with the same passphrase only.
+	require.True(t, priv.Equals(newKeyBook("foobar").PrivKey(p)))
+	require.Nil(t, newKeyBook("raboof").PrivKey(p))
+
+	// The key can't be moved to another peer.
+	other := test.RandPeerIDFatal(t)
+	require.NoError(t, store.Put(context.Background(), peerToKey(other, privSuffix), stored))
+	require.Nil(t, newKeyBook("foobar").PrivKey(other))
+}
+
+func TestDsKeyBookEncryptionMigration(t *testing.T) {
+	store, closeFn := mapDBStore(t)
+	defer closeFn()
+
+	priv, _, err := test.RandTestKeyPair(ic.Ed25519, 256)
+	require.NoError(t, err)
+	p, err := peer.IDFromPrivateKey(priv)
+	require.NoError(t, err)
+	plain, err := NewKeyBook(context.Background(), store, DefaultOpts())
+	require.NoError(t, err)
+	require.NoError(t, plain.AddPrivKey(p, priv))
+
+	// Keys stored before encryption was enabled are encrypted on first read.
+	opts := DefaultOpts()
+	opts.PrivKeyCipher, err = NewPassphraseCipher([]byte("foobar"))
+	require.NoError(t, err)
+	kb, err := NewKeyBook(context.Background(), store, opts)
+	require.NoError(t, err)
+	require.True(t, priv.Equals(kb.PrivKey(p)))
+	raw, err := ic.MarshalPrivateKey(priv)
+	require.NoError(t, err)
+	stored, err := store.Get(context.Background(), peerToKey(p, privSuffix))
+	require.NoError(t, err)
+	require.NotContains(t, string(stored), string(raw))
+	require.True(t, priv.Equals(kb.PrivKey(p)))
+
+	// A plaintext key of another peer is rejected.
+	other := test.RandPeerIDFatal(t)
+	require.NoError(t, store.Put(context.Background(), peerToKey(other, privSuffix), raw))
+	require.Nil(t, kb.PrivKey(other))
+}
+
 func BenchmarkDsKeyBook(b *testing.B) {
 	for name, dsFactory := range dstores {
 		b.Run(name, func(b *testing.B) {
@@ -135,6 +348,17 @@ func addressBookFactory(tb testing.TB, storeFactory datastoreFactory, opts Optio
 	}
 }
 
+func scoreBookFactory(tb testing.TB, storeFactory datastoreFactory, opts Options) pt.ScoreBookFactory {
+	return func() (pstore.ScoreBook, func()) {
+		store, storeCloseFn := storeFactory(tb)
+		sb, err := NewScoreBook(context.Background(), store, opts)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		return sb, storeCloseFn
+	}
+}
+
 func keyBookFactory(tb testing.TB, storeFactory datastoreFactory, opts Options) pt.KeyBookFactory {
 	return func() (pstore.KeyBook, func()) {
 		store, storeCloseFn := storeFactory(tb)
@@ -0,0 +1,125 @@
+package pstoreds
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"sync"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// PrivKeyCipher encrypts private keys before they are written to the
+// datastore, and decrypts them when they are read. Implementations can keep
+// the encryption key in a KMS.
+type PrivKeyCipher interface {
+	// Encrypt encrypts the marshaled private key of peer p.
+	Encrypt(p peer.ID, key []byte) ([]byte, error)
+	// Decrypt decrypts a private key of peer p encrypted by Encrypt.
+	Decrypt(p peer.ID, ciphertext []byte) ([]byte, error)
+}
+
+const (
+	passphraseCipherVersion = 1
+	passphraseSaltLen       = 16
+
+	// scrypt parameters, as recommended for interactive logins in 2017.
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// passphraseCipher encrypts keys with AES-256-GCM, using a key derived from a
+// passphrase. The encrypted keys are formatted as
+// <version><salt><nonce><ciphertext>.
+type passphraseCipher struct {
+	passphrase []byte
+
+	mx sync.Mutex
+	// salt is the salt used for encryption.
+	salt []byte
+	// aeads are the derived ciphers, by salt.
+	aeads map[string]cipher.AEAD
+}
+
+var _ PrivKeyCipher = (*passphraseCipher)(nil)
+
+// NewPassphraseCipher returns a PrivKeyCipher that encrypts keys with
+// AES-256-GCM, using a key derived from the passphrase with scrypt. The peer
+// ID is authenticated along with the key, so that an encrypted key can't be
+// passed off as the key of another peer.
+func NewPassphraseCipher(passphrase []byte) (PrivKeyCipher, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("empty passphrase")
+	}
+	salt := make([]byte, passphraseSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return &passphraseCipher{
+		passphrase: append([]byte(nil), passphrase...),
+		salt:       salt,
+		aeads:      make(map[string]cipher.AEAD),
+	}, nil
+}
+
+// aead returns the cipher for the given salt. Deriving the key is expensive,
+// so the cipher is cached.
+func (c *passphraseCipher) aead(salt []byte) (cipher.AEAD, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+
+	if aead, ok := c.aeads[string(salt)]; ok {
+		return aead, nil
+	}
+	key, err := scrypt.Key(c.passphrase, salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	c.aeads[string(salt)] = aead
+	return aead, nil
+}
+
+func (c *passphraseCipher) Encrypt(p peer.ID, key []byte) ([]byte, error) {
+	aead, err := c.aead(c.salt)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, 1+passphraseSaltLen+aead.NonceSize()+len(key)+aead.Overhead())
+	out = append(out, passphraseCipherVersion)
+	out = append(out, c.salt...)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, key, []byte(p)), nil
+}
+
+func (c *passphraseCipher) Decrypt(p peer.ID, ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < 1+passphraseSaltLen || ciphertext[0] != passphraseCipherVersion {
+		return nil, errors.New("invalid encrypted key")
+	}
+	salt := ciphertext[1 : 1+passphraseSaltLen]
+	aead, err := c.aead(salt)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext = ciphertext[1+passphraseSaltLen:]
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("invalid encrypted key")
+	}
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, []byte(p))
+}
@@ -22,13 +22,14 @@ var (
 )
 
 type dsKeyBook struct {
-	ds ds.Datastore
+	ds     ds.Datastore
+	cipher PrivKeyCipher
 }
 
 var _ pstore.KeyBook = (*dsKeyBook)(nil)
 
-func NewKeyBook(_ context.Context, store ds.Datastore, _ Options) (*dsKeyBook, error) {
-	return &dsKeyBook{store}, nil
+func NewKeyBook(_ context.Context, store ds.Datastore, opts Options) (*dsKeyBook, error) {
+	return &dsKeyBook{ds: store, cipher: opts.PrivKeyCipher}, nil
 }
 
 func (kb *dsKeyBook) PubKey(p peer.ID) ic.PubKey {
@@ -89,6 +90,13 @@ func (kb *dsKeyBook) PrivKey(p peer.ID) ic.PrivKey {
 	if err != nil {
 		return nil
 	}
+	if kb.cipher != nil {
+		decrypted, err := kb.cipher.Decrypt(p, value)
+		if err != nil {
+			return kb.migratePlaintextPrivKey(p, value, err)
+		}
+		value = decrypted
+	}
 	sk, err := ic.UnmarshalPrivateKey(value)
 	if err != nil {
 		return nil
@@ -96,6 +104,22 @@ func (kb *dsKeyBook) PrivKey(p peer.ID) ic.PrivKey {
 	return sk
 }
 
+// migratePlaintextPrivKey handles private keys that were stored before a
+// PrivKeyCipher was configured. If value is the unencrypted key of peer p, the
+// key is encrypted and written back to the datastore.
+func (kb *dsKeyBook) migratePlaintextPrivKey(p peer.ID, value []byte, decryptErr error) ic.PrivKey {
+	sk, err := ic.UnmarshalPrivateKey(value)
+	if err != nil || !p.MatchesPrivateKey(sk) {
+		log.Errorf("error while decrypting privkey for peer %s: %s\n", p, decryptErr)
+		return nil
+	}
+	log.Infof("encrypting plaintext privkey for peer %s", p)
+	if err := kb.AddPrivKey(p, sk); err != nil {
+		log.Warnf("failed to encrypt plaintext privkey for peer %s: %s", p, err)
+	}
+	return sk
+}
+
 func (kb *dsKeyBook) AddPrivKey(p peer.ID, sk ic.PrivKey) error {
 	if sk == nil {
 		return errors.New("private key is nil")
@@ -110,6 +134,12 @@ func (kb *dsKeyBook) AddPrivKey(p peer.ID, sk ic.PrivKey) error {
 		log.Errorf("error while converting privkey byte string for peer %s: %s\n", p, err)
 		return err
 	}
+	if kb.cipher != nil {
+		if val, err = kb.cipher.Encrypt(p, val); err != nil {
+			log.Errorf("error while encrypting privkey for peer %s: %s\n", p, err)
+			return err
+		}
+	}
 	if err := kb.ds.Put(context.TODO(), peerToKey(p, privSuffix), val); err != nil {
 		log.Errorf("error while updating privkey in datastore for peer %s: %s\n", p, err)
 	}
@@ -3,7 +3,10 @@ package pstoreds
 import (
 	"bytes"
 	"context"
+	"encoding/binary"
 	"encoding/gob"
+	"sync"
+	"time"
 
 	pool "github.com/libp2p/go-buffer-pool"
 	"github.com/libp2p/go-libp2p/core/peer"
@@ -19,17 +22,41 @@ import (
 // /peers/metadata/<b32 peer id no padding>/<key>
 var pmBase = ds.NewKey("/peers/metadata")
 
+// The expiry of metadata stored with a TTL is indexed under the following db
+// key pattern, so that expired metadata can be found without decoding all
+// values:
+// /peers/ttl/metadata/<b32 peer id no padding>/<key>
+var pmExpiryBase = ds.NewKey("/peers/ttl/metadata")
+
+// metadataSweepInterval is the minimum interval between sweeps of expired
+// metadata.
+const metadataSweepInterval = time.Minute
+
+// expiringMetadata wraps values stored with a TTL.
+type expiringMetadata struct {
+	Val    interface{}
+	Expiry int64 // unix nanoseconds
+}
+
 type dsPeerMetadata struct {
-	ds ds.Datastore
+	ds    ds.Datastore
+	clock clock
+
+	sweepMx   sync.Mutex
+	lastSweep time.Time
 }
 
-var _ pstore.PeerMetadata = (*dsPeerMetadata)(nil)
+var (
+	_ pstore.PeerMetadata    = (*dsPeerMetadata)(nil)
+	_ pstore.PeerMetadataTTL = (*dsPeerMetadata)(nil)
+)
 
 func init() {
 	// Gob registers basic types by default.
 	//
 	// Register complex types used by the peerstore itself.
 	gob.Register(make(map[protocol.ID]struct{}))
+	gob.Register(expiringMetadata{})
 }
 
 // NewPeerMetadata creates a metadata store backed by a persistent db. It uses gob for serialisation.
@@ -37,12 +64,31 @@ func init() {
 // See `init()` to learn which types are registered by default. Modules wishing to store
 // values of other types will need to `gob.Register()` them explicitly, or else callers
 // will receive runtime errors.
-func NewPeerMetadata(_ context.Context, store ds.Datastore, _ Options) (*dsPeerMetadata, error) {
-	return &dsPeerMetadata{store}, nil
+func NewPeerMetadata(_ context.Context, store ds.Datastore, opts Options) (*dsPeerMetadata, error) {
+	pm := &dsPeerMetadata{ds: store, clock: realclock{}}
+	if opts.Clock != nil {
+		pm.clock = opts.Clock
+	}
+	return pm, nil
 }
 
 func (pm *dsPeerMetadata) Get(p peer.ID, key string) (interface{}, error) {
-	k := pmBase.ChildString(base32.RawStdEncoding.EncodeToString([]byte(p))).ChildString(key)
+	res, err := pm.get(pmBase.ChildString(base32.RawStdEncoding.EncodeToString([]byte(p))).ChildString(key))
+	if err != nil {
+		return nil, err
+	}
+	if em, ok := res.(expiringMetadata); ok {
+		if !pm.clock.Now().Before(time.Unix(0, em.Expiry)) {
+			return nil, pstore.ErrNotFound
+		}
+		return em.Val, nil
+	}
+	return res, nil
+}
+
+// get returns the decoded value stored under k, without unwrapping values
+// stored with a TTL.
+func (pm *dsPeerMetadata) get(k ds.Key) (interface{}, error) {
 	value, err := pm.ds.Get(context.TODO(), k)
 	if err != nil {
 		if err == ds.ErrNotFound {
@@ -58,7 +104,24 @@ func (pm *dsPeerMetadata) Get(p peer.ID, key string) (interface{}, error) {
 	return res, nil
 }
 
+// Put stores val without a TTL. An expiry index entry left behind by
+// PutWithTTL is removed by the next sweep, which keeps the new value.
 func (pm *dsPeerMetadata) Put(p peer.ID, key string, val interface{}) error {
+	return pm.put(p, key, val)
+}
+
+func (pm *dsPeerMetadata) PutWithTTL(p peer.ID, key string, val interface{}, ttl time.Duration) error {
+	pm.maybeSweep()
+	expiry := pm.clock.Now().Add(ttl).UnixNano()
+	if err := pm.put(p, key, expiringMetadata{Val: val, Expiry: expiry}); err != nil {
+		return err
+	}
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(expiry))
+	return pm.ds.Put(context.TODO(), pmExpiryBase.ChildString(base32.RawStdEncoding.EncodeToString([]byte(p))).ChildString(key), b)
+}
+
+func (pm *dsPeerMetadata) put(p peer.ID, key string, val interface{}) error {
 	k := pmBase.ChildString(base32.RawStdEncoding.EncodeToString([]byte(p))).ChildString(key)
 	var buf pool.Buffer
 	if err := gob.NewEncoder(&buf).Encode(&val); err != nil {
@@ -67,16 +130,55 @@ func (pm *dsPeerMetadata) Put(p peer.ID, key string, val interface{}) error {
 	return pm.ds.Put(context.TODO(), k, buf.Bytes())
 }
 
-func (pm *dsPeerMetadata) RemovePeer(p peer.ID) {
-	result, err := pm.ds.Query(context.TODO(), query.Query{
-		Prefix:   pmBase.ChildString(base32.RawStdEncoding.EncodeToString([]byte(p))).String(),
-		KeysOnly: true,
-	})
+// maybeSweep removes the expired values, unless we did so recently.
+func (pm *dsPeerMetadata) maybeSweep() {
+	pm.sweepMx.Lock()
+	defer pm.sweepMx.Unlock()
+	now := pm.clock.Now()
+	if now.Sub(pm.lastSweep) < metadataSweepInterval {
+		return
+	}
+	pm.lastSweep = now
+
+	result, err := pm.ds.Query(context.TODO(), query.Query{Prefix: pmExpiryBase.String()})
 	if err != nil {
-		log.Warnw("querying datastore when removing peer failed", "peer", p, "error", err)
+		log.Warnw("querying datastore when sweeping expired metadata failed", "error", err)
 		return
 	}
+	defer result.Close()
 	for entry := range result.Next() {
-		pm.ds.Delete(context.TODO(), ds.NewKey(entry.Key))
+		if entry.Error != nil {
+			log.Warnw("querying datastore when sweeping expired metadata failed", "error", entry.Error)
+			return
+		}
+		if len(entry.Value) != 8 || now.Before(time.Unix(0, int64(binary.BigEndian.Uint64(entry.Value)))) {
+			continue
+		}
+		k := ds.NewKey(entry.Key)
+		// /peers/ttl/metadata/<peer>/<key> -> /peers/metadata/<peer>/<key>
+		vk := pmBase.Child(ds.KeyWithNamespaces(k.Namespaces()[3:]))
+		// The value might have been overwritten by Put since.
+		if v, err := pm.get(vk); err == nil {
+			if em, ok := v.(expiringMetadata); ok && !now.Before(time.Unix(0, em.Expiry)) {
+				pm.ds.Delete(context.TODO(), vk)
+			}
+		}
+		pm.ds.Delete(context.TODO(), k)
+	}
+}
+
+func (pm *dsPeerMetadata) RemovePeer(p peer.ID) {
+	for _, base := range []ds.Key{pmBase, pmExpiryBase} {
+		result, err := pm.ds.Query(context.TODO(), query.Query{
+			Prefix:   base.ChildString(base32.RawStdEncoding.EncodeToString([]byte(p))).String(),
+			KeysOnly: true,
+		})
+		if err != nil {
+			log.Warnw("querying datastore when removing peer failed", "peer", p, "error", err)
+			return
+		}
+		for entry := range result.Next() {
+			pm.ds.Delete(context.TODO(), ds.NewKey(entry.Key))
+		}
 	}
 }
@@ -35,6 +35,22 @@ type Options struct {
 	// before starting GC.
 	GCInitialDelay time.Duration
 
+	// WriteBehindInterval enables write-behind batching of the address book. If this is a zero value, every change
+	// is written to the datastore immediately. Otherwise, changes are queued, and written in a single batch at this
+	// interval, followed by a sync of the datastore. A batch is either written completely or not at all, so a crash
+	// loses at most the changes of the last interval, but never leaves a partially written record. Pending changes
+	// are flushed when the peerstore is closed, and can be flushed on demand by calling Flush.
+	WriteBehindInterval time.Duration
+
+	// PrivKeyCipher encrypts private keys before they are written to the datastore. Keys are decrypted every time
+	// they are read. If this is nil, private keys are stored unencrypted. See NewPassphraseCipher.
+	// Unencrypted keys stored before the cipher was configured can still be read, and are encrypted on first read.
+	PrivKeyCipher PrivKeyCipher
+
+	// ScoreDecay configures the half-lives of the scores stored in the ScoreBook, by score name. Scores decay
+	// towards zero, halving every half-life. Scores without a half-life don't decay.
+	ScoreDecay map[string]time.Duration
+
 	Clock clock
 }
 
@@ -63,9 +79,15 @@ type pstoreds struct {
 	*dsAddrBook
 	*dsProtoBook
 	*dsPeerMetadata
+	*dsScoreBook
 }
 
-var _ peerstore.Peerstore = &pstoreds{}
+var (
+	_ peerstore.Peerstore         = &pstoreds{}
+	_ peerstore.LatencyTracker    = &pstoreds{}
+	_ peerstore.ProtoBookNotifier = &pstoreds{}
+	_ peerstore.PeerMetadataTTL   = &pstoreds{}
+)
 
 // NewPeerstore creates a peerstore backed by the provided persistent datastore.
 // It's the caller's responsibility to call RemovePeer to ensure
@@ -91,12 +113,18 @@ func NewPeerstore(ctx context.Context, store ds.Batching, opts Options) (*pstore
 		return nil, err
 	}
 
+	scoreBook, err := NewScoreBook(ctx, store, opts)
+	if err != nil {
+		return nil, err
+	}
+
 	return &pstoreds{
 		Metrics:        pstore.NewMetrics(),
 		dsKeyBook:      keyBook,
 		dsAddrBook:     addrBook,
 		dsPeerMetadata: peerMetadata,
 		dsProtoBook:    protoBook,
+		dsScoreBook:    scoreBook,
 	}, nil
 }
 
@@ -181,11 +209,37 @@ func (ps *pstoreds) PeerInfo(p peer.ID) peer.AddrInfo {
 // * the KeyBook
 // * the ProtoBook
 // * the PeerMetadata
+// * the ScoreBook
 // * the Metrics
 // It DOES NOT remove the peer from the AddrBook.
 func (ps *pstoreds) RemovePeer(p peer.ID) {
 	ps.dsKeyBook.RemovePeer(p)
 	ps.dsProtoBook.RemovePeer(p)
 	ps.dsPeerMetadata.RemovePeer(p)
+	ps.dsScoreBook.RemovePeer(p)
 	ps.Metrics.RemovePeer(p)
 }
+
+// RecordLatencySample records a latency sample measured over a transport, if
+// the Metrics support it. Otherwise, it's recorded as by RecordLatency.
+func (ps *pstoreds) RecordLatencySample(p peer.ID, transport string, rtt time.Duration) {
+	peerstore.RecordLatencySample(ps.Metrics, p, transport, rtt)
+}
+
+// LatencyStats returns the stats of the recent latency samples of a peer, if
+// the Metrics keep them.
+func (ps *pstoreds) LatencyStats(p peer.ID, transport string) (peerstore.LatencyStats, bool) {
+	lt, ok := peerstore.GetLatencyTracker(ps.Metrics)
+	if !ok {
+		return peerstore.LatencyStats{}, false
+	}
+	return lt.LatencyStats(p, transport)
+}
+
+// RemoveLatencySamples removes the recent latency samples of a peer, if the
+// Metrics keep them.
+func (ps *pstoreds) RemoveLatencySamples(p peer.ID) {
+	if lt, ok := peerstore.GetLatencyTracker(ps.Metrics); ok {
+		lt.RemoveLatencySamples(p)
+	}
+}
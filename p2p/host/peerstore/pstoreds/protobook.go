@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 
 	"github.com/libp2p/go-libp2p/core/peer"
 	pstore "github.com/libp2p/go-libp2p/core/peerstore"
@@ -12,6 +13,9 @@ import (
 
 type protoSegment struct {
 	sync.RWMutex
+	// notifyMx is held while notifying about the changes of the segment's
+	// peers, so that the notifications of a peer are delivered in order.
+	notifyMx sync.Mutex
 }
 
 type protoSegments [256]*protoSegment
@@ -35,9 +39,22 @@ type dsProtoBook struct {
 	segments  protoSegments
 	meta      pstore.PeerMetadata
 	maxProtos int
+
+	notifyMx  sync.RWMutex
+	notifiees map[*protoNotifiee]struct{}
+	// hasNotifiees allows us to skip computing the changes if nobody is
+	// interested in them.
+	hasNotifiees atomic.Bool
 }
 
-var _ pstore.ProtoBook = (*dsProtoBook)(nil)
+type protoNotifiee struct {
+	f func(p peer.ID, added, removed []protocol.ID)
+}
+
+var (
+	_ pstore.ProtoBook         = (*dsProtoBook)(nil)
+	_ pstore.ProtoBookNotifier = (*dsProtoBook)(nil)
+)
 
 func NewProtoBook(meta pstore.PeerMetadata, opts ...ProtoBookOption) (*dsProtoBook, error) {
 	pb := &dsProtoBook{
@@ -49,6 +66,7 @@ func NewProtoBook(meta pstore.PeerMetadata, opts ...ProtoBookOption) (*dsProtoBo
 			return ret
 		}(),
 		maxProtos: 128,
+		notifiees: make(map[*protoNotifiee]struct{}),
 	}
 
 	for _, opt := range opts {
@@ -71,29 +89,51 @@ func (pb *dsProtoBook) SetProtocols(p peer.ID, protos ...protocol.ID) error {
 
 	s := pb.segments.get(p)
 	s.Lock()
-	defer s.Unlock()
-
-	return pb.meta.Put(p, "protocols", protomap)
+	var added, removed []protocol.ID
+	if pb.hasNotifiees.Load() {
+		old, err := pb.getProtocolMap(p)
+		if err != nil {
+			s.Unlock()
+			return err
+		}
+		added, removed = diffProtocolMaps(old, protomap)
+	}
+	if err := pb.meta.Put(p, "protocols", protomap); err != nil {
+		s.Unlock()
+		return err
+	}
+	pb.unlockAndNotify(s, p, added, removed)
+	return nil
 }
 
 func (pb *dsProtoBook) AddProtocols(p peer.ID, protos ...protocol.ID) error {
 	s := pb.segments.get(p)
 	s.Lock()
-	defer s.Unlock()
 
 	pmap, err := pb.getProtocolMap(p)
 	if err != nil {
+		s.Unlock()
 		return err
 	}
 	if len(pmap)+len(protos) > pb.maxProtos {
+		s.Unlock()
 		return errTooManyProtocols
 	}
 
+	var added []protocol.ID
 	for _, proto := range protos {
+		if _, ok := pmap[proto]; !ok {
+			added = append(added, proto)
+		}
 		pmap[proto] = struct{}{}
 	}
 
-	return pb.meta.Put(p, "protocols", pmap)
+	if err := pb.meta.Put(p, "protocols", pmap); err != nil {
+		s.Unlock()
+		return err
+	}
+	pb.unlockAndNotify(s, p, added, nil)
+	return nil
 }
 
 func (pb *dsProtoBook) GetProtocols(p peer.ID) ([]protocol.ID, error) {
@@ -155,23 +195,33 @@ func (pb *dsProtoBook) FirstSupportedProtocol(p peer.ID, protos ...protocol.ID)
 func (pb *dsProtoBook) RemoveProtocols(p peer.ID, protos ...protocol.ID) error {
 	s := pb.segments.get(p)
 	s.Lock()
-	defer s.Unlock()
 
 	pmap, err := pb.getProtocolMap(p)
 	if err != nil {
+		s.Unlock()
 		return err
 	}
 
 	if len(pmap) == 0 {
 		// nothing to do.
+		s.Unlock()
 		return nil
 	}
 
+	var removed []protocol.ID
 	for _, proto := range protos {
+		if _, ok := pmap[proto]; ok {
+			removed = append(removed, proto)
+		}
 		delete(pmap, proto)
 	}
 
-	return pb.meta.Put(p, "protocols", pmap)
+	if err := pb.meta.Put(p, "protocols", pmap); err != nil {
+		s.Unlock()
+		return err
+	}
+	pb.unlockAndNotify(s, p, nil, removed)
+	return nil
 }
 
 func (pb *dsProtoBook) getProtocolMap(p peer.ID) (map[protocol.ID]struct{}, error) {
@@ -192,5 +242,83 @@ func (pb *dsProtoBook) getProtocolMap(p peer.ID) (map[protocol.ID]struct{}, erro
 }
 
 func (pb *dsProtoBook) RemovePeer(p peer.ID) {
+	if !pb.hasNotifiees.Load() {
+		pb.meta.RemovePeer(p)
+		return
+	}
+
+	s := pb.segments.get(p)
+	s.Lock()
+	pmap, err := pb.getProtocolMap(p)
+	if err != nil {
+		log.Errorf("error while reading protocols of peer %s: %s", p, err)
+	}
 	pb.meta.RemovePeer(p)
+	removed := make([]protocol.ID, 0, len(pmap))
+	for proto := range pmap {
+		removed = append(removed, proto)
+	}
+	pb.unlockAndNotify(s, p, nil, removed)
+}
+
+// NotifyProtocolsChanged registers f to be called every time the protocols
+// supported by a peer change. Removing a peer from the peerstore removes all
+// its protocols. f is called synchronously, in the order the changes of a peer
+// were made. It is called without holding the locks protecting the protocols,
+// so it may read from the ProtoBook, but it must not block.
+func (pb *dsProtoBook) NotifyProtocolsChanged(f func(p peer.ID, added, removed []protocol.ID)) (cancel func()) {
+	n := &protoNotifiee{f: f}
+	pb.notifyMx.Lock()
+	pb.notifiees[n] = struct{}{}
+	pb.hasNotifiees.Store(true)
+	pb.notifyMx.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			pb.notifyMx.Lock()
+			delete(pb.notifiees, n)
+			pb.hasNotifiees.Store(len(pb.notifiees) > 0)
+			pb.notifyMx.Unlock()
+		})
+	}
+}
+
+// unlockAndNotify unlocks the segment s, and calls the registered notifiees if
+// there are any changes. The segment's notifyMx is taken before unlocking s, so
+// that concurrent changes of the same peer are notified in order.
+func (pb *dsProtoBook) unlockAndNotify(s *protoSegment, p peer.ID, added, removed []protocol.ID) {
+	if len(added) == 0 && len(removed) == 0 {
+		s.Unlock()
+		return
+	}
+	s.notifyMx.Lock()
+	defer s.notifyMx.Unlock()
+	s.Unlock()
+
+	pb.notifyMx.RLock()
+	notifiees := make([]*protoNotifiee, 0, len(pb.notifiees))
+	for n := range pb.notifiees {
+		notifiees = append(notifiees, n)
+	}
+	pb.notifyMx.RUnlock()
+
+	for _, n := range notifiees {
+		n.f(p, added, removed)
+	}
+}
+
+// diffProtocolMaps returns the protocols added and removed in b compared to a.
+func diffProtocolMaps(a, b map[protocol.ID]struct{}) (added, removed []protocol.ID) {
+	for proto := range b {
+		if _, ok := a[proto]; !ok {
+			added = append(added, proto)
+		}
+	}
+	for proto := range a {
+		if _, ok := b[proto]; !ok {
+			removed = append(removed, proto)
+		}
+	}
+	return added, removed
 }
@@ -0,0 +1,176 @@
+package pstoreds
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	pstore "github.com/libp2p/go-libp2p/core/peerstore"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/multiformats/go-base32"
+)
+
+// Scores are stored under the following db key pattern:
+// /peers/scores/<b32 peer id no padding>/<b32 score name no padding>
+//
+// The value is the big-endian encoding of the float64 score, followed by the
+// time it was last updated, in unix nanoseconds.
+var scoreBase = ds.NewKey("/peers/scores")
+
+const scoreRecordLen = 16
+
+type dsScoreBook struct {
+	ds ds.Datastore
+
+	// mx serializes updates, so that concurrent calls to AddToScore don't
+	// lose updates.
+	mx sync.Mutex
+
+	halfLives map[string]time.Duration
+	clock     clock
+}
+
+var _ pstore.ScoreBook = (*dsScoreBook)(nil)
+
+// NewScoreBook creates a score book backed by a persistent db. Scores decay
+// according to Options.ScoreDecay.
+func NewScoreBook(_ context.Context, store ds.Datastore, opts Options) (*dsScoreBook, error) {
+	sb := &dsScoreBook{
+		ds:        store,
+		halfLives: opts.ScoreDecay,
+		clock:     realclock{},
+	}
+	if opts.Clock != nil {
+		sb.clock = opts.Clock
+	}
+	return sb, nil
+}
+
+func scorePeerKey(p peer.ID) ds.Key {
+	return scoreBase.ChildString(base32.RawStdEncoding.EncodeToString([]byte(p)))
+}
+
+func scoreKey(p peer.ID, name string) ds.Key {
+	return scorePeerKey(p).ChildString(base32.RawStdEncoding.EncodeToString([]byte(name)))
+}
+
+// decayed returns the value of a score, after decaying it until now.
+func (sb *dsScoreBook) decayed(name string, value float64, updated time.Time) float64 {
+	halfLife := sb.halfLives[name]
+	if halfLife <= 0 {
+		return value
+	}
+	return value * math.Exp2(-float64(sb.clock.Now().Sub(updated))/float64(halfLife))
+}
+
+func (sb *dsScoreBook) decode(name string, b []byte) (float64, error) {
+	if len(b) != scoreRecordLen {
+		return 0, errors.New("invalid score record")
+	}
+	value := math.Float64frombits(binary.BigEndian.Uint64(b))
+	updated := time.Unix(0, int64(binary.BigEndian.Uint64(b[8:])))
+	return sb.decayed(name, value, updated), nil
+}
+
+func (sb *dsScoreBook) get(p peer.ID, name string) (float64, error) {
+	b, err := sb.ds.Get(context.TODO(), scoreKey(p, name))
+	if err != nil {
+		if err == ds.ErrNotFound {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return sb.decode(name, b)
+}
+
+func (sb *dsScoreBook) put(p peer.ID, name string, value float64) error {
+	b := make([]byte, scoreRecordLen)
+	binary.BigEndian.PutUint64(b, math.Float64bits(value))
+	binary.BigEndian.PutUint64(b[8:], uint64(sb.clock.Now().UnixNano()))
+	return sb.ds.Put(context.TODO(), scoreKey(p, name), b)
+}
+
+func (sb *dsScoreBook) Score(p peer.ID, name string) float64 {
+	value, err := sb.get(p, name)
+	if err != nil {
+		log.Errorw("error while fetching score from datastore", "peer", p, "score", name, "error", err)
+		return 0
+	}
+	return value
+}
+
+func (sb *dsScoreBook) SetScore(p peer.ID, name string, value float64) error {
+	sb.mx.Lock()
+	defer sb.mx.Unlock()
+	return sb.put(p, name, value)
+}
+
+func (sb *dsScoreBook) AddToScore(p peer.ID, name string, delta float64) (float64, error) {
+	sb.mx.Lock()
+	defer sb.mx.Unlock()
+
+	value, err := sb.get(p, name)
+	if err != nil {
+		return 0, err
+	}
+	value += delta
+	if err := sb.put(p, name, value); err != nil {
+		return 0, err
+	}
+	return value, nil
+}
+
+func (sb *dsScoreBook) Scores(p peer.ID) map[string]float64 {
+	prefix := scorePeerKey(p)
+	result, err := sb.ds.Query(context.TODO(), query.Query{Prefix: prefix.String()})
+	if err != nil {
+		log.Errorw("error while querying scores from datastore", "peer", p, "error", err)
+		return nil
+	}
+	defer result.Close()
+
+	out := make(map[string]float64)
+	for entry := range result.Next() {
+		if entry.Error != nil {
+			log.Errorw("error while querying scores from datastore", "peer", p, "error", entry.Error)
+			return nil
+		}
+		name, err := base32.RawStdEncoding.DecodeString(strings.TrimPrefix(entry.Key, prefix.String()+"/"))
+		if err != nil {
+			log.Warnw("invalid score name in datastore", "peer", p, "key", entry.Key, "error", err)
+			continue
+		}
+		value, err := sb.decode(string(name), entry.Value)
+		if err != nil {
+			log.Warnw("invalid score in datastore", "peer", p, "key", entry.Key, "error", err)
+			continue
+		}
+		out[string(name)] = value
+	}
+	return out
+}
+
+func (sb *dsScoreBook) RemovePeer(p peer.ID) {
+	sb.mx.Lock()
+	defer sb.mx.Unlock()
+
+	result, err := sb.ds.Query(context.TODO(), query.Query{
+		Prefix:   scorePeerKey(p).String(),
+		KeysOnly: true,
+	})
+	if err != nil {
+		log.Warnw("querying datastore when removing peer failed", "peer", p, "error", err)
+		return
+	}
+	defer result.Close()
+	for entry := range result.Next() {
+		sb.ds.Delete(context.TODO(), ds.NewKey(entry.Key))
+	}
+}
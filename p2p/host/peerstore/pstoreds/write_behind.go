@@ -0,0 +1,115 @@
+package pstoreds
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// persist writes a modified record to the datastore. If write-behind is
+// enabled, the record is queued, and written with the next batch instead. To be
+// called within the record's lock.
+func (ab *dsAddrBook) persist(pr *addrsRecord) error {
+	if ab.opts.WriteBehindInterval <= 0 {
+		return pr.flush(ab.ds)
+	}
+	ab.pendingMx.Lock()
+	ab.pending[peer.ID(pr.Id)] = pr
+	ab.pendingMx.Unlock()
+	return nil
+}
+
+// loadPending returns the queued record of a peer, if there is one.
+func (ab *dsAddrBook) loadPending(p peer.ID) (*addrsRecord, bool) {
+	ab.pendingMx.Lock()
+	defer ab.pendingMx.Unlock()
+	pr, ok := ab.pending[p]
+	return pr, ok
+}
+
+// Flush writes all queued records to the datastore in a single batch, and
+// syncs the address book to disk afterwards. It is a no-op unless
+// Options.WriteBehindInterval is set.
+//
+// If committing the batch fails, the records are queued again, and the
+// datastore still contains their previous version.
+func (ab *dsAddrBook) Flush() error {
+	ab.flushMx.Lock()
+	defer ab.flushMx.Unlock()
+
+	ab.pendingMx.Lock()
+	pending := ab.pending
+	ab.pending = make(map[peer.ID]*addrsRecord, len(pending))
+	ab.pendingMx.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	requeue := func() {
+		// persist takes pendingMx while holding a record's lock, so the
+		// records must not be locked while holding pendingMx.
+		for _, pr := range pending {
+			pr.Lock()
+			pr.dirty = true
+			pr.Unlock()
+		}
+		ab.pendingMx.Lock()
+		defer ab.pendingMx.Unlock()
+		for p, pr := range pending {
+			// The record might have been modified and queued again in the meantime.
+			if _, ok := ab.pending[p]; !ok {
+				ab.pending[p] = pr
+			}
+		}
+	}
+
+	batch, err := ab.ds.Batch(context.TODO())
+	if err != nil {
+		requeue()
+		return fmt.Errorf("failed to create batch: %w", err)
+	}
+	for _, pr := range pending {
+		pr.Lock()
+		pr.dirty = true
+		err := pr.flush(batch)
+		pr.Unlock()
+		if err != nil {
+			requeue()
+			return fmt.Errorf("failed to write record: %w", err)
+		}
+	}
+	if err := batch.Commit(context.TODO()); err != nil {
+		requeue()
+		return fmt.Errorf("failed to commit batch: %w", err)
+	}
+	if err := ab.ds.Sync(context.TODO(), addrBookBase); err != nil {
+		return fmt.Errorf("failed to sync datastore: %w", err)
+	}
+	return nil
+}
+
+// writeBehind periodically flushes the queued records. The remaining records
+// are flushed when the address book is closed. It should be spawned as a
+// goroutine.
+func (ab *dsAddrBook) writeBehind() {
+	defer ab.childrenDone.Done()
+
+	ticker := time.NewTicker(ab.opts.WriteBehindInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := ab.Flush(); err != nil {
+				log.Warnf("failed to flush address book: %v", err)
+			}
+		case <-ab.ctx.Done():
+			if err := ab.Flush(); err != nil {
+				log.Errorf("failed to flush address book on close: %v", err)
+			}
+			return
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/libp2p/go-libp2p/core/peer"
@@ -24,6 +25,10 @@ type expiringAddr struct {
 	TTL    time.Duration
 	Expiry time.Time
 	Peer   peer.ID
+	// Source is the most reliable source the address was learned from.
+	Source peerstore.AddrSource
+	// LastSeen is the time the address was last added or updated.
+	LastSeen time.Time
 	// to sort by expiry time, -1 means it's not in the heap
 	heapIndex int
 }
@@ -45,6 +50,24 @@ func ttlIsConnected(ttl time.Duration) bool {
 type peerRecordState struct {
 	Envelope *record.Envelope
 	Seq      uint64
+	// size is the estimated memory used by the record.
+	size int64
+}
+
+// Estimated memory used by the entries of the address book, in addition to the
+// bytes of the addresses and records, used to enforce the memory budget.
+const (
+	addrOverhead   = 128
+	peerOverhead   = 256
+	recordOverhead = 512
+)
+
+func addrSize(a *expiringAddr) int64 {
+	return int64(addrOverhead + len(a.Addr.Bytes()))
+}
+
+func recordSize(env *record.Envelope) int64 {
+	return int64(recordOverhead + len(env.RawPayload) + len(env.PayloadType))
 }
 
 // Essentially Go stdlib's Priority Queue example
@@ -55,11 +78,28 @@ type peerAddrs struct {
 	// expiringHeap only stores non-connected addresses. Since connected address
 	// basically have an infinite TTL
 	expiringHeap []*expiringAddr
+	// counters may be shared between multiple peerAddrs.
+	counters *addrCounters
+}
+
+// addrCounters count the peers, the addresses and the estimated memory used by
+// all shards of the address book. They are used to enforce the limits of the
+// address book and to report metrics without locking all shards.
+type addrCounters struct {
+	peers       atomic.Int64
+	addrs       atomic.Int64
+	unconnected atomic.Int64
+	bytes       atomic.Int64
 }
 
 func newPeerAddrs() peerAddrs {
+	return newPeerAddrsWithCounters(&addrCounters{})
+}
+
+func newPeerAddrsWithCounters(c *addrCounters) peerAddrs {
 	return peerAddrs{
-		Addrs: make(map[peer.ID]map[string]*expiringAddr),
+		Addrs:    make(map[peer.ID]map[string]*expiringAddr),
+		counters: c,
 	}
 }
 
@@ -76,11 +116,13 @@ func (pa *peerAddrs) Push(x any) {
 	a := x.(*expiringAddr)
 	a.heapIndex = len(pa.expiringHeap)
 	pa.expiringHeap = append(pa.expiringHeap, a)
+	pa.counters.unconnected.Add(1)
 }
 func (pa *peerAddrs) Pop() any {
 	a := pa.expiringHeap[len(pa.expiringHeap)-1]
 	a.heapIndex = -1
 	pa.expiringHeap = pa.expiringHeap[0 : len(pa.expiringHeap)-1]
+	pa.counters.unconnected.Add(-1)
 	return a
 }
 
@@ -90,9 +132,17 @@ func (pa *peerAddrs) Delete(a *expiringAddr) {
 			heap.Remove(pa, a.heapIndex)
 		}
 		delete(pa.Addrs[a.Peer], string(a.Addr.Bytes()))
-		if len(pa.Addrs[a.Peer]) == 0 {
-			delete(pa.Addrs, a.Peer)
-		}
+		pa.counters.addrs.Add(-1)
+		pa.counters.bytes.Add(-addrSize(ea))
+		pa.maybeDeletePeer(a.Peer)
+	}
+}
+
+func (pa *peerAddrs) maybeDeletePeer(p peer.ID) {
+	if m, ok := pa.Addrs[p]; ok && len(m) == 0 {
+		delete(pa.Addrs, p)
+		pa.counters.peers.Add(-1)
+		pa.counters.bytes.Add(-peerOverhead)
 	}
 }
 
@@ -116,9 +166,9 @@ func (pa *peerAddrs) PopIfExpired(now time.Time) (*expiringAddr, bool) {
 	if len(pa.expiringHeap) > 0 && !now.Before(pa.NextExpiry()) {
 		ea := heap.Pop(pa).(*expiringAddr)
 		delete(pa.Addrs[ea.Peer], string(ea.Addr.Bytes()))
-		if len(pa.Addrs[ea.Peer]) == 0 {
-			delete(pa.Addrs, ea.Peer)
-		}
+		pa.counters.addrs.Add(-1)
+		pa.counters.bytes.Add(-addrSize(ea))
+		pa.maybeDeletePeer(ea.Peer)
 		return ea, true
 	}
 	return nil, false
@@ -139,8 +189,12 @@ func (pa *peerAddrs) Insert(a *expiringAddr) {
 	a.heapIndex = -1
 	if _, ok := pa.Addrs[a.Peer]; !ok {
 		pa.Addrs[a.Peer] = make(map[string]*expiringAddr)
+		pa.counters.peers.Add(1)
+		pa.counters.bytes.Add(peerOverhead)
 	}
 	pa.Addrs[a.Peer][string(a.Addr.Bytes())] = a
+	pa.counters.addrs.Add(1)
+	pa.counters.bytes.Add(addrSize(a))
 	// don't add connected addr to heap.
 	if a.IsConnected() {
 		return
@@ -167,14 +221,57 @@ const (
 	defaultMaxUnconnectedAddrs  = 1_000_000
 )
 
+// addrBookShard stores the addresses of the peers whose IDs end with the same
+// byte. Sharding the address book by peer ID reduces lock contention when many
+// peers connect and disconnect concurrently.
+type addrBookShard struct {
+	mu                sync.RWMutex
+	addrs             peerAddrs
+	signedPeerRecords map[peer.ID]*peerRecordState
+}
+
+type addrBookShards [256]*addrBookShard
+
+func (s *addrBookShards) get(p peer.ID) *addrBookShard {
+	if len(p) == 0 {
+		return s[0]
+	}
+	return s[p[len(p)-1]]
+}
+
 // memoryAddrBook manages addresses.
 type memoryAddrBook struct {
-	mu                   sync.RWMutex
-	addrs                peerAddrs
-	signedPeerRecords    map[peer.ID]*peerRecordState
+	shards   addrBookShards
+	counters addrCounters
+	// numSignedPeerRecords is the number of signed peer records of all shards.
+	numSignedPeerRecords atomic.Int64
 	maxUnconnectedAddrs  int
 	maxSignedPeerRecords int
 
+	maxPeers         int
+	memoryBudget     int
+	maxAddrsPerPeer  int
+	isProtected      func(peer.ID) bool
+	evictionCallback func(peer.ID)
+	// lruMu protects lru. It may be acquired while holding the lock of a
+	// shard, but not the other way around.
+	lruMu sync.Mutex
+	lru   peerLRU
+
+	// mu protects evicted.
+	mu sync.Mutex
+	// removePeer removes an evicted peer from the rest of the peerstore.
+	removePeer func(peer.ID)
+	// evicted are the peers evicted while holding the lock, see notifyEvicted.
+	evicted []peer.ID
+
+	metricsTracer MetricsTracer
+	// numKeys returns the number of peers in the key book, for metrics.
+	numKeys func() int
+	// onGC is called on every gc, so that the rest of the peerstore can remove
+	// its expired entries.
+	onGC func(now time.Time)
+
 	refCount sync.WaitGroup
 	cancel   func()
 
@@ -184,18 +281,24 @@ type memoryAddrBook struct {
 
 var _ peerstore.AddrBook = (*memoryAddrBook)(nil)
 var _ peerstore.CertifiedAddrBook = (*memoryAddrBook)(nil)
+var _ peerstore.AddrBookInspector = (*memoryAddrBook)(nil)
 
 func NewAddrBook(opts ...AddrBookOption) *memoryAddrBook {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	ab := &memoryAddrBook{
-		addrs:                newPeerAddrs(),
-		signedPeerRecords:    make(map[peer.ID]*peerRecordState),
 		subManager:           NewAddrSubManager(),
 		cancel:               cancel,
 		clock:                realclock{},
 		maxUnconnectedAddrs:  defaultMaxUnconnectedAddrs,
 		maxSignedPeerRecords: defaultMaxSignedPeerRecords,
+		lru:                  newPeerLRU(),
+	}
+	for i := range ab.shards {
+		ab.shards[i] = &addrBookShard{
+			addrs:             newPeerAddrsWithCounters(&ab.counters),
+			signedPeerRecords: make(map[peer.ID]*peerRecordState),
+		}
 	}
 	for _, opt := range opts {
 		opt(ab)
@@ -208,6 +311,33 @@ func NewAddrBook(opts ...AddrBookOption) *memoryAddrBook {
 
 type AddrBookOption func(book *memoryAddrBook) error
 
+// withRemovePeer sets the function removing evicted peers from the rest of the
+// peerstore. It must be set before the background goroutine is started.
+func withRemovePeer(f func(peer.ID)) AddrBookOption {
+	return func(b *memoryAddrBook) error {
+		b.removePeer = f
+		return nil
+	}
+}
+
+// withNumKeys sets the function returning the number of peers in the key book,
+// for metrics. It must be set before the background goroutine is started.
+func withNumKeys(f func() int) AddrBookOption {
+	return func(b *memoryAddrBook) error {
+		b.numKeys = f
+		return nil
+	}
+}
+
+// withGC sets the function called on every gc. It must be set before the
+// background goroutine is started.
+func withGC(f func(now time.Time)) AddrBookOption {
+	return func(b *memoryAddrBook) error {
+		b.onGC = f
+		return nil
+	}
+}
+
 func WithClock(clock clock) AddrBookOption {
 	return func(book *memoryAddrBook) error {
 		book.clock = clock
@@ -257,23 +387,42 @@ func (mab *memoryAddrBook) Close() error {
 // gc garbage collects the in-memory address book.
 func (mab *memoryAddrBook) gc() {
 	now := mab.clock.Now()
-	mab.mu.Lock()
-	defer mab.mu.Unlock()
-	for {
-		ea, ok := mab.addrs.PopIfExpired(now)
-		if !ok {
-			return
+	var expired int
+	for _, s := range mab.shards {
+		s.mu.Lock()
+		for {
+			ea, ok := s.addrs.PopIfExpired(now)
+			if !ok {
+				break
+			}
+			expired++
+			mab.maybeDeletePeerUnlocked(s, ea.Peer)
 		}
-		mab.maybeDeleteSignedPeerRecordUnlocked(ea.Peer)
+		s.mu.Unlock()
+	}
+	if mab.onGC != nil {
+		mab.onGC(now)
+	}
+	if mab.metricsTracer == nil {
+		return
+	}
+
+	var keys int
+	if mab.numKeys != nil {
+		keys = mab.numKeys()
 	}
+	mab.metricsTracer.AddrsExpired(expired)
+	mab.metricsTracer.PeerstoreSize(int(mab.counters.peers.Load()), int(mab.counters.addrs.Load()), keys)
 }
 
 func (mab *memoryAddrBook) PeersWithAddrs() peer.IDSlice {
-	mab.mu.RLock()
-	defer mab.mu.RUnlock()
-	peers := make(peer.IDSlice, 0, len(mab.addrs.Addrs))
-	for pid := range mab.addrs.Addrs {
-		peers = append(peers, pid)
+	peers := make(peer.IDSlice, 0, mab.counters.peers.Load())
+	for _, s := range mab.shards {
+		s.mu.RLock()
+		for pid := range s.addrs.Addrs {
+			peers = append(peers, pid)
+		}
+		s.mu.RUnlock()
 	}
 	return peers
 }
@@ -286,7 +435,13 @@ func (mab *memoryAddrBook) AddAddr(p peer.ID, addr ma.Multiaddr, ttl time.Durati
 // AddAddrs adds `addrs` for peer `p`, which will expire after the given `ttl`.
 // This function never reduces the TTL or expiration of an address.
 func (mab *memoryAddrBook) AddAddrs(p peer.ID, addrs []ma.Multiaddr, ttl time.Duration) {
-	mab.addAddrs(p, addrs, ttl)
+	mab.addAddrs(p, addrs, ttl, peerstore.AddrSourceUnknown)
+}
+
+// AddAddrsWithSource is like AddAddrs, and records that the addresses were
+// learned from src.
+func (mab *memoryAddrBook) AddAddrsWithSource(p peer.ID, addrs []ma.Multiaddr, ttl time.Duration, src peerstore.AddrSource) {
+	mab.addAddrs(p, addrs, ttl, src)
 }
 
 // ConsumePeerRecord adds addresses from a signed peer.PeerRecord, which will expire after the given TTL.
@@ -304,41 +459,92 @@ func (mab *memoryAddrBook) ConsumePeerRecord(recordEnvelope *record.Envelope, tt
 		return false, fmt.Errorf("signing key does not match PeerID in PeerRecord")
 	}
 
-	mab.mu.Lock()
-	defer mab.mu.Unlock()
+	defer mab.notifyEvicted()
+	defer mab.enforceMemoryBudget()
+	mab.makeRoom(rec.PeerID, ttl)
+	s := mab.shards.get(rec.PeerID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	// ensure seq is greater than or equal to the last received
-	lastState, found := mab.signedPeerRecords[rec.PeerID]
+	lastState, found := s.signedPeerRecords[rec.PeerID]
 	if found && lastState.Seq > rec.Seq {
 		return false, nil
 	}
 	// check if we are over the max signed peer record limit
-	if !found && len(mab.signedPeerRecords) >= mab.maxSignedPeerRecords {
+	if !found && !mab.reserveSignedPeerRecord() {
 		return false, errors.New("too many signed peer records")
 	}
-	mab.signedPeerRecords[rec.PeerID] = &peerRecordState{
+	state := &peerRecordState{
 		Envelope: recordEnvelope,
 		Seq:      rec.Seq,
+		size:     recordSize(recordEnvelope),
+	}
+	s.signedPeerRecords[rec.PeerID] = state
+	if found {
+		mab.counters.bytes.Add(state.size - lastState.size)
+	} else {
+		mab.counters.bytes.Add(state.size)
 	}
-	mab.addAddrsUnlocked(rec.PeerID, rec.Addrs, ttl)
+	mab.addAddrsUnlocked(s, rec.PeerID, rec.Addrs, ttl, peerstore.AddrSourceUnknown)
 	return true, nil
 }
 
-func (mab *memoryAddrBook) maybeDeleteSignedPeerRecordUnlocked(p peer.ID) {
-	if len(mab.addrs.Addrs[p]) == 0 {
-		delete(mab.signedPeerRecords, p)
+// reserveSignedPeerRecord reserves room for a new signed peer record. It
+// returns false if we already store the maximum number of records. Records of
+// different shards are added concurrently, so the check and the increment need
+// to be a single atomic operation.
+func (mab *memoryAddrBook) reserveSignedPeerRecord() bool {
+	for {
+		n := mab.numSignedPeerRecords.Load()
+		if n >= int64(mab.maxSignedPeerRecords) {
+			return false
+		}
+		if mab.numSignedPeerRecords.CompareAndSwap(n, n+1) {
+			return true
+		}
 	}
 }
 
-func (mab *memoryAddrBook) addAddrs(p peer.ID, addrs []ma.Multiaddr, ttl time.Duration) {
-	mab.mu.Lock()
-	defer mab.mu.Unlock()
+// maybeDeletePeerUnlocked deletes the state we only keep for peers we know
+// addresses of.
+func (mab *memoryAddrBook) maybeDeletePeerUnlocked(s *addrBookShard, p peer.ID) {
+	if len(s.addrs.Addrs[p]) == 0 {
+		mab.deletePeerRecordUnlocked(s, p)
+		mab.lruRemove(p)
+	}
+}
 
-	mab.addAddrsUnlocked(p, addrs, ttl)
+func (mab *memoryAddrBook) deletePeerRecordUnlocked(s *addrBookShard, p peer.ID) {
+	if state, ok := s.signedPeerRecords[p]; ok {
+		delete(s.signedPeerRecords, p)
+		mab.numSignedPeerRecords.Add(-1)
+		mab.counters.bytes.Add(-state.size)
+	}
 }
 
-func (mab *memoryAddrBook) addAddrsUnlocked(p peer.ID, addrs []ma.Multiaddr, ttl time.Duration) {
-	defer mab.maybeDeleteSignedPeerRecordUnlocked(p)
+// atUnconnectedAddrLimit says if we store the maximum number of unconnected
+// addresses.
+func (mab *memoryAddrBook) atUnconnectedAddrLimit() bool {
+	return mab.counters.unconnected.Load() >= int64(mab.maxUnconnectedAddrs)
+}
+
+func (mab *memoryAddrBook) addAddrs(p peer.ID, addrs []ma.Multiaddr, ttl time.Duration, src peerstore.AddrSource) {
+	defer mab.notifyEvicted()
+	defer mab.enforceMemoryBudget()
+	// Don't evict a peer to make room for addresses we're going to drop.
+	if ttlIsConnected(ttl) || !mab.atUnconnectedAddrLimit() {
+		mab.makeRoom(p, ttl)
+	}
+	s := mab.shards.get(p)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mab.addAddrsUnlocked(s, p, addrs, ttl, src)
+}
+
+func (mab *memoryAddrBook) addAddrsUnlocked(s *addrBookShard, p peer.ID, addrs []ma.Multiaddr, ttl time.Duration, src peerstore.AddrSource) {
+	defer mab.maybeDeletePeerUnlocked(s, p)
 
 	// if ttl is zero, exit. nothing to do.
 	if ttl <= 0 {
@@ -346,11 +552,20 @@ func (mab *memoryAddrBook) addAddrsUnlocked(p peer.ID, addrs []ma.Multiaddr, ttl
 	}
 
 	// we are over limit, drop these addrs.
-	if !ttlIsConnected(ttl) && mab.addrs.NumUnconnectedAddrs() >= mab.maxUnconnectedAddrs {
+	if !ttlIsConnected(ttl) && mab.atUnconnectedAddrLimit() {
+		return
+	}
+
+	_, known := s.addrs.Addrs[p]
+	if !known && mab.atPeerLimit() {
 		return
 	}
+	if !known || ttlIsConnected(ttl) {
+		defer mab.touchUnlocked(s, p)
+	}
 
-	exp := mab.clock.Now().Add(ttl)
+	now := mab.clock.Now()
+	exp := now.Add(ttl)
 	for _, addr := range addrs {
 		// Remove suffix of /p2p/peer-id from address
 		addr, addrPid := peer.SplitAddr(addr)
@@ -362,13 +577,18 @@ func (mab *memoryAddrBook) addAddrsUnlocked(p peer.ID, addrs []ma.Multiaddr, ttl
 			log.Warnf("Was passed p2p address with a different peerId. found: %s, expected: %s", addrPid, p)
 			continue
 		}
-		a, found := mab.addrs.FindAddr(p, addr)
+		a, found := s.addrs.FindAddr(p, addr)
 		if !found {
+			if !ttlIsConnected(ttl) && !mab.canAddUnconnectedAddrUnlocked(s, p) {
+				continue
+			}
 			// not found, announce it.
-			entry := &expiringAddr{Addr: addr, Expiry: exp, TTL: ttl, Peer: p}
-			mab.addrs.Insert(entry)
+			entry := &expiringAddr{Addr: addr, Expiry: exp, TTL: ttl, Peer: p, Source: src, LastSeen: now}
+			s.addrs.Insert(entry)
 			mab.subManager.BroadcastAddr(p, addr)
 		} else {
+			a.LastSeen = now
+			a.Source = max(a.Source, src)
 			// update ttl & exp to whichever is greater between new and existing entry
 			var changed bool
 			if ttl > a.TTL {
@@ -380,7 +600,7 @@ func (mab *memoryAddrBook) addAddrsUnlocked(p peer.ID, addrs []ma.Multiaddr, ttl
 				a.Expiry = exp
 			}
 			if changed {
-				mab.addrs.Update(a)
+				s.addrs.Update(a)
 			}
 		}
 	}
@@ -394,12 +614,29 @@ func (mab *memoryAddrBook) SetAddr(p peer.ID, addr ma.Multiaddr, ttl time.Durati
 // SetAddrs sets the ttl on addresses. This clears any TTL there previously.
 // This is used when we receive the best estimate of the validity of an address.
 func (mab *memoryAddrBook) SetAddrs(p peer.ID, addrs []ma.Multiaddr, ttl time.Duration) {
-	mab.mu.Lock()
-	defer mab.mu.Unlock()
+	defer mab.notifyEvicted()
+	defer mab.enforceMemoryBudget()
+	mab.makeRoom(p, ttl)
+	s := mab.shards.get(p)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	defer mab.maybeDeletePeerUnlocked(s, p)
 
-	defer mab.maybeDeleteSignedPeerRecordUnlocked(p)
+	_, known := s.addrs.Addrs[p]
+	if !known && ttl > 0 && mab.atPeerLimit() {
+		return
+	}
+	// the peer is used if it's new, or if it connected or disconnected.
+	touch := !known || ttlIsConnected(ttl)
+	defer func() {
+		if touch {
+			mab.touchUnlocked(s, p)
+		}
+	}()
 
-	exp := mab.clock.Now().Add(ttl)
+	now := mab.clock.Now()
+	exp := now.Add(ttl)
 	for _, addr := range addrs {
 		addr, addrPid := peer.SplitAddr(addr)
 		if addr == nil {
@@ -411,27 +648,29 @@ func (mab *memoryAddrBook) SetAddrs(p peer.ID, addrs []ma.Multiaddr, ttl time.Du
 			continue
 		}
 
-		if a, found := mab.addrs.FindAddr(p, addr); found {
+		if a, found := s.addrs.FindAddr(p, addr); found {
+			touch = touch || a.IsConnected()
 			if ttl > 0 {
-				if a.IsConnected() && !ttlIsConnected(ttl) && mab.addrs.NumUnconnectedAddrs() >= mab.maxUnconnectedAddrs {
-					mab.addrs.Delete(a)
+				if a.IsConnected() && !ttlIsConnected(ttl) && mab.atUnconnectedAddrLimit() {
+					s.addrs.Delete(a)
 				} else {
 					a.Addr = addr
 					a.Expiry = exp
 					a.TTL = ttl
-					mab.addrs.Update(a)
+					a.LastSeen = now
+					s.addrs.Update(a)
 					mab.subManager.BroadcastAddr(p, addr)
 				}
 			} else {
-				mab.addrs.Delete(a)
+				s.addrs.Delete(a)
 			}
 		} else {
 			if ttl > 0 {
-				if !ttlIsConnected(ttl) && mab.addrs.NumUnconnectedAddrs() >= mab.maxUnconnectedAddrs {
+				if !ttlIsConnected(ttl) && (mab.atUnconnectedAddrLimit() || !mab.canAddUnconnectedAddrUnlocked(s, p)) {
 					continue
 				}
-				entry := &expiringAddr{Addr: addr, Expiry: exp, TTL: ttl, Peer: p}
-				mab.addrs.Insert(entry)
+				entry := &expiringAddr{Addr: addr, Expiry: exp, TTL: ttl, Peer: p, LastSeen: now}
+				s.addrs.Insert(entry)
 				mab.subManager.BroadcastAddr(p, addr)
 			}
 		}
@@ -441,24 +680,32 @@ func (mab *memoryAddrBook) SetAddrs(p peer.ID, addrs []ma.Multiaddr, ttl time.Du
 // UpdateAddrs updates the addresses associated with the given peer that have
 // the given oldTTL to have the given newTTL.
 func (mab *memoryAddrBook) UpdateAddrs(p peer.ID, oldTTL time.Duration, newTTL time.Duration) {
-	mab.mu.Lock()
-	defer mab.mu.Unlock()
+	// Disconnected peers can be evicted, if we're over the memory budget.
+	defer mab.notifyEvicted()
+	defer mab.enforceMemoryBudget()
+	s := mab.shards.get(p)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	defer mab.maybeDeleteSignedPeerRecordUnlocked(p)
+	defer mab.maybeDeletePeerUnlocked(s, p)
+	// if the peer disconnected, this was its last connection.
+	if ttlIsConnected(oldTTL) {
+		defer mab.touchUnlocked(s, p)
+	}
 
 	exp := mab.clock.Now().Add(newTTL)
-	for _, a := range mab.addrs.Addrs[p] {
+	for _, a := range s.addrs.Addrs[p] {
 		if oldTTL == a.TTL {
 			if newTTL == 0 {
-				mab.addrs.Delete(a)
+				s.addrs.Delete(a)
 			} else {
 				// We are over limit, drop these addresses.
-				if ttlIsConnected(oldTTL) && !ttlIsConnected(newTTL) && mab.addrs.NumUnconnectedAddrs() >= mab.maxUnconnectedAddrs {
-					mab.addrs.Delete(a)
+				if ttlIsConnected(oldTTL) && !ttlIsConnected(newTTL) && mab.atUnconnectedAddrLimit() {
+					s.addrs.Delete(a)
 				} else {
 					a.TTL = newTTL
 					a.Expiry = exp
-					mab.addrs.Update(a)
+					s.addrs.Update(a)
 				}
 			}
 		}
@@ -467,12 +714,40 @@ func (mab *memoryAddrBook) UpdateAddrs(p peer.ID, oldTTL time.Duration, newTTL t
 
 // Addrs returns all known (and valid) addresses for a given peer
 func (mab *memoryAddrBook) Addrs(p peer.ID) []ma.Multiaddr {
-	mab.mu.RLock()
-	defer mab.mu.RUnlock()
-	if _, ok := mab.addrs.Addrs[p]; !ok {
+	s := mab.shards.get(p)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, ok := s.addrs.Addrs[p]; !ok {
 		return nil
 	}
-	return validAddrs(mab.clock.Now(), mab.addrs.Addrs[p])
+	return validAddrs(mab.clock.Now(), s.addrs.Addrs[p])
+}
+
+// InspectAddrs returns the non-expired addresses of a peer, with their
+// source, remaining TTL and the time they were last seen.
+func (mab *memoryAddrBook) InspectAddrs(p peer.ID) []peerstore.AddrRecord {
+	s := mab.shards.get(p)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := mab.clock.Now()
+	recs := make([]peerstore.AddrRecord, 0, len(s.addrs.Addrs[p]))
+	for _, a := range s.addrs.Addrs[p] {
+		if a.ExpiredBy(now) {
+			continue
+		}
+		ttl := a.TTL
+		if !a.IsConnected() {
+			ttl = a.Expiry.Sub(now)
+		}
+		recs = append(recs, peerstore.AddrRecord{
+			Addr:     a.Addr,
+			Source:   a.Source,
+			TTL:      ttl,
+			LastSeen: a.LastSeen,
+		})
+	}
+	return recs
 }
 
 func validAddrs(now time.Time, amap map[string]*expiringAddr) []ma.Multiaddr {
@@ -492,18 +767,19 @@ func validAddrs(now time.Time, amap map[string]*expiringAddr) []ma.Multiaddr {
 // given peer id, if one exists.
 // Returns nil if no signed PeerRecord exists for the peer.
 func (mab *memoryAddrBook) GetPeerRecord(p peer.ID) *record.Envelope {
-	mab.mu.RLock()
-	defer mab.mu.RUnlock()
+	s := mab.shards.get(p)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	if _, ok := mab.addrs.Addrs[p]; !ok {
+	if _, ok := s.addrs.Addrs[p]; !ok {
 		return nil
 	}
 	// The record may have expired, but not gargage collected.
-	if len(validAddrs(mab.clock.Now(), mab.addrs.Addrs[p])) == 0 {
+	if len(validAddrs(mab.clock.Now(), s.addrs.Addrs[p])) == 0 {
 		return nil
 	}
 
-	state := mab.signedPeerRecords[p]
+	state := s.signedPeerRecords[p]
 	if state == nil {
 		return nil
 	}
@@ -512,12 +788,14 @@ func (mab *memoryAddrBook) GetPeerRecord(p peer.ID) *record.Envelope {
 
 // ClearAddrs removes all previously stored addresses
 func (mab *memoryAddrBook) ClearAddrs(p peer.ID) {
-	mab.mu.Lock()
-	defer mab.mu.Unlock()
+	s := mab.shards.get(p)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	delete(mab.signedPeerRecords, p)
-	for _, a := range mab.addrs.Addrs[p] {
-		mab.addrs.Delete(a)
+	mab.deletePeerRecordUnlocked(s, p)
+	mab.lruRemove(p)
+	for _, a := range s.addrs.Addrs[p] {
+		s.addrs.Delete(a)
 	}
 }
 
@@ -526,14 +804,15 @@ func (mab *memoryAddrBook) ClearAddrs(p peer.ID) {
 func (mab *memoryAddrBook) AddrStream(ctx context.Context, p peer.ID) <-chan ma.Multiaddr {
 	var initial []ma.Multiaddr
 
-	mab.mu.RLock()
-	if m, ok := mab.addrs.Addrs[p]; ok {
+	s := mab.shards.get(p)
+	s.mu.RLock()
+	if m, ok := s.addrs.Addrs[p]; ok {
 		initial = make([]ma.Multiaddr, 0, len(m))
 		for _, a := range m {
 			initial = append(initial, a.Addr)
 		}
 	}
-	mab.mu.RUnlock()
+	s.mu.RUnlock()
 
 	return mab.subManager.AddrStream(ctx, p, initial)
 }
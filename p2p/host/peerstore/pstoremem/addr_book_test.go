@@ -9,6 +9,9 @@ import (
 	"time"
 
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/test"
+
 	ma "github.com/multiformats/go-multiaddr"
 	"github.com/stretchr/testify/require"
 )
@@ -183,7 +186,7 @@ func TestPeerLimits(t *testing.T) {
 	for _, p := range peers {
 		ab.AddAddr(p.Peer, p.Addr, p.TTL)
 	}
-	require.Equal(t, 1024, ab.addrs.NumUnconnectedAddrs())
+	require.Equal(t, int64(1024), ab.counters.unconnected.Load())
 }
 
 func BenchmarkPeerAddrs(b *testing.B) {
@@ -210,3 +213,33 @@ func BenchmarkPeerAddrs(b *testing.B) {
 	}
 
 }
+
+// BenchmarkAddrBookChurn simulates the connection churn of a DHT server: peers
+// connect, their addresses are looked up, and they disconnect again.
+func BenchmarkAddrBookChurn(b *testing.B) {
+	for _, sz := range [...]int{10_000, 100_000} {
+		b.Run(fmt.Sprintf("%d", sz), func(b *testing.B) {
+			ab := NewAddrBook()
+			defer ab.Close()
+
+			peers := make([]peer.ID, sz)
+			for i := range peers {
+				peers[i] = test.RandPeerIDFatal(b)
+				ab.AddAddr(peers[i], ma.StringCast(fmt.Sprintf("/ip4/1.2.3.4/tcp/%d", i%65535)), time.Hour)
+			}
+			addr := ma.StringCast("/ip4/1.2.3.4/udp/1/quic-v1")
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := rand.Intn(sz)
+				for pb.Next() {
+					p := peers[i%sz]
+					ab.AddAddr(p, addr, peerstore.ConnectedAddrTTL)
+					ab.Addrs(p)
+					ab.UpdateAddrs(p, peerstore.ConnectedAddrTTL, peerstore.RecentlyConnectedAddrTTL)
+					i++
+				}
+			})
+		})
+	}
+}
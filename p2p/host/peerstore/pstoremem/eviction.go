@@ -0,0 +1,278 @@
+package pstoremem
+
+import (
+	"container/list"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// WithMaxPeers sets the maximum number of peers we store addresses for. Once
+// the limit is reached, adding addresses of a new peer evicts the peer that
+// least recently connected to us. Peers that are currently connected, and
+// peers protected by WithProtectedPeers, are never evicted. If all peers are
+// protected, the addresses of the new peer are dropped.
+//
+// Evicted peers are removed from the entire peerstore. When many new peers are
+// added concurrently, the limit may be exceeded by a few peers. Zero, the
+// default, means no limit.
+func WithMaxPeers(n int) AddrBookOption {
+	return func(b *memoryAddrBook) error {
+		b.maxPeers = n
+		return nil
+	}
+}
+
+// WithAddrBookMemoryBudget sets the maximum memory, in bytes, used by the
+// address book, for deployments with little memory. The memory use is
+// estimated from the number of peers, their addresses and their signed peer
+// records. Keys, protocols, metadata and scores are not accounted for. Once the
+// budget is exceeded, the peers that least recently connected to us are
+// evicted from the whole peerstore, like with WithMaxPeers, until the address
+// book fits into the budget again. Zero, the default, means no budget.
+func WithAddrBookMemoryBudget(bytes int) AddrBookOption {
+	return func(b *memoryAddrBook) error {
+		b.memoryBudget = bytes
+		return nil
+	}
+}
+
+// WithMaxAddrsPerPeer sets the maximum number of unconnected addresses we
+// store per peer. Addresses exceeding the limit are dropped. Zero, the default,
+// means no limit.
+func WithMaxAddrsPerPeer(n int) AddrBookOption {
+	return func(b *memoryAddrBook) error {
+		b.maxAddrsPerPeer = n
+		return nil
+	}
+}
+
+// WithProtectedPeers protects the peers for which isProtected returns true
+// from being evicted when the limit set by WithMaxPeers or
+// WithAddrBookMemoryBudget is reached. It is called with address book locks
+// held, and must not call back into the peerstore.
+func WithProtectedPeers(isProtected func(peer.ID) bool) AddrBookOption {
+	return func(b *memoryAddrBook) error {
+		b.isProtected = isProtected
+		return nil
+	}
+}
+
+// WithEvictionCallback sets a function that is called for every peer evicted
+// because the limit set by WithMaxPeers or WithAddrBookMemoryBudget was
+// reached. It is called after the peer was removed from the peerstore.
+func WithEvictionCallback(f func(peer.ID)) AddrBookOption {
+	return func(b *memoryAddrBook) error {
+		b.evictionCallback = f
+		return nil
+	}
+}
+
+// peerLRU orders peers by the time they last connected to us. Peers that
+// never connected are ordered by the time we learned about them.
+type peerLRU struct {
+	l     *list.List
+	elems map[peer.ID]*list.Element
+}
+
+func newPeerLRU() peerLRU {
+	return peerLRU{l: list.New(), elems: make(map[peer.ID]*list.Element)}
+}
+
+// Touch marks p as the most recently used peer.
+func (l *peerLRU) Touch(p peer.ID) {
+	if e, ok := l.elems[p]; ok {
+		l.l.MoveToFront(e)
+		return
+	}
+	l.elems[p] = l.l.PushFront(p)
+}
+
+func (l *peerLRU) Remove(p peer.ID) {
+	if e, ok := l.elems[p]; ok {
+		l.l.Remove(e)
+		delete(l.elems, p)
+	}
+}
+
+// canAddUnconnectedAddrUnlocked says if the per peer address limit allows us
+// to add another unconnected address of p.
+func (mab *memoryAddrBook) canAddUnconnectedAddrUnlocked(s *addrBookShard, p peer.ID) bool {
+	if mab.maxAddrsPerPeer <= 0 {
+		return true
+	}
+	var n int
+	for _, a := range s.addrs.Addrs[p] {
+		if !a.IsConnected() {
+			n++
+		}
+	}
+	return n < mab.maxAddrsPerPeer
+}
+
+// isConnectedUnlocked says if we have a connected address of p.
+func (mab *memoryAddrBook) isConnectedUnlocked(s *addrBookShard, p peer.ID) bool {
+	for _, a := range s.addrs.Addrs[p] {
+		if a.IsConnected() {
+			return true
+		}
+	}
+	return false
+}
+
+// atPeerLimit says if we store addresses of the maximum number of peers.
+func (mab *memoryAddrBook) atPeerLimit() bool {
+	return mab.maxPeers > 0 && mab.counters.peers.Load() >= int64(mab.maxPeers)
+}
+
+// lruEnabled says if we need to keep track of the least recently used peers.
+func (mab *memoryAddrBook) lruEnabled() bool {
+	return mab.maxPeers > 0 || mab.memoryBudget > 0
+}
+
+// makeRoom makes room for the addresses of p if it's a new peer, by evicting
+// the least recently connected peer if we're at the peer limit. It must be
+// called without holding the lock of any shard.
+//
+// Other peers might take the room before we add the addresses of p. The peer
+// limit is enforced when adding the addresses.
+func (mab *memoryAddrBook) makeRoom(p peer.ID, ttl time.Duration) {
+	if ttl <= 0 || mab.maxPeers <= 0 {
+		return
+	}
+	s := mab.shards.get(p)
+	s.mu.RLock()
+	_, known := s.addrs.Addrs[p]
+	s.mu.RUnlock()
+	if known || !mab.atPeerLimit() {
+		return
+	}
+
+	mab.evict()
+}
+
+// enforceMemoryBudget evicts the least recently connected peers until the
+// address book fits into the memory budget. It must be called without holding
+// the lock of any shard.
+func (mab *memoryAddrBook) enforceMemoryBudget() {
+	if mab.memoryBudget <= 0 || mab.counters.bytes.Load() <= int64(mab.memoryBudget) {
+		return
+	}
+	for mab.counters.bytes.Load() > int64(mab.memoryBudget) && mab.evict() {
+	}
+}
+
+// evictBatchSize is the number of eviction candidates taken from the LRU at a
+// time.
+const evictBatchSize = 16
+
+// evict evicts the least recently connected peer that is neither connected
+// nor protected. It returns false if there is no such peer. It must be called
+// without holding any lock. Since lruMu is acquired while holding the lock of
+// a shard, the candidates are taken from the LRU first, and their shards are
+// locked afterwards.
+func (mab *memoryAddrBook) evict() bool {
+	mab.lruMu.Lock()
+	remaining := mab.lru.l.Len()
+	mab.lruMu.Unlock()
+
+	var after peer.ID
+	for remaining > 0 {
+		candidates := mab.evictionCandidates(after, evictBatchSize)
+		if len(candidates) == 0 {
+			return false
+		}
+		remaining -= len(candidates)
+		for _, victim := range candidates {
+			if mab.tryEvict(victim) {
+				return true
+			}
+		}
+		after = candidates[len(candidates)-1]
+	}
+	return false
+}
+
+// evictionCandidates returns up to n peers, in least recently used order,
+// starting after the peer after. If after is empty or was removed from the LRU
+// in the meantime, it starts with the least recently used peer.
+func (mab *memoryAddrBook) evictionCandidates(after peer.ID, n int) []peer.ID {
+	mab.lruMu.Lock()
+	defer mab.lruMu.Unlock()
+
+	e := mab.lru.l.Back()
+	if ae, ok := mab.lru.elems[after]; ok {
+		e = ae.Prev()
+	}
+	candidates := make([]peer.ID, 0, n)
+	for ; e != nil && len(candidates) < n; e = e.Prev() {
+		candidates = append(candidates, e.Value.(peer.ID))
+	}
+	return candidates
+}
+
+// tryEvict evicts victim, unless it's connected or protected.
+func (mab *memoryAddrBook) tryEvict(victim peer.ID) bool {
+	vs := mab.shards.get(victim)
+	vs.mu.Lock()
+	if len(vs.addrs.Addrs[victim]) == 0 || mab.isConnectedUnlocked(vs, victim) || (mab.isProtected != nil && mab.isProtected(victim)) {
+		vs.mu.Unlock()
+		return false
+	}
+	for _, a := range vs.addrs.Addrs[victim] {
+		vs.addrs.Delete(a)
+	}
+	mab.deletePeerRecordUnlocked(vs, victim)
+	mab.lruRemove(victim)
+	vs.mu.Unlock()
+
+	mab.mu.Lock()
+	mab.evicted = append(mab.evicted, victim)
+	mab.mu.Unlock()
+	if mab.metricsTracer != nil {
+		mab.metricsTracer.PeerEvicted()
+	}
+	return true
+}
+
+// notifyEvicted removes the evicted peers from the peerstore, and calls the
+// eviction callback. It must be called without holding any lock.
+func (mab *memoryAddrBook) notifyEvicted() {
+	mab.mu.Lock()
+	evicted := mab.evicted
+	mab.evicted = nil
+	mab.mu.Unlock()
+
+	for _, p := range evicted {
+		if mab.removePeer != nil {
+			mab.removePeer(p)
+		}
+		if mab.evictionCallback != nil {
+			mab.evictionCallback(p)
+		}
+	}
+}
+
+// touchUnlocked marks p as the most recently used peer, if we know addresses
+// of p. The LRU is only maintained if the number of peers or the memory is
+// limited.
+func (mab *memoryAddrBook) touchUnlocked(s *addrBookShard, p peer.ID) {
+	if !mab.lruEnabled() {
+		return
+	}
+	if _, ok := s.addrs.Addrs[p]; ok {
+		mab.lruMu.Lock()
+		mab.lru.Touch(p)
+		mab.lruMu.Unlock()
+	}
+}
+
+// lruRemove removes p from the LRU.
+func (mab *memoryAddrBook) lruRemove(p peer.ID) {
+	if !mab.lruEnabled() {
+		return
+	}
+	mab.lruMu.Lock()
+	mab.lru.Remove(p)
+	mab.lruMu.Unlock()
+}
@@ -5,8 +5,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/peer"
 	pstore "github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/core/test"
 	pt "github.com/libp2p/go-libp2p/p2p/host/peerstore/test"
 	"github.com/multiformats/go-multiaddr"
 
@@ -55,6 +58,15 @@ func TestInMemoryAddrBook(t *testing.T) {
 	}, clk)
 }
 
+func TestInMemoryScoreBook(t *testing.T) {
+	clk := mockClock.NewMock()
+	pt.TestScoreBook(t, func() (pstore.ScoreBook, func()) {
+		ps, err := NewPeerstore(WithClock(clk), WithScoreDecay("decaying", time.Hour))
+		require.NoError(t, err)
+		return ps, func() { ps.Close() }
+	}, clk)
+}
+
 func TestInMemoryKeyBook(t *testing.T) {
 	pt.TestKeyBook(t, func() (pstore.KeyBook, func()) {
 		ps, err := NewPeerstore()
@@ -87,6 +99,209 @@ func TestMain(m *testing.M) {
 	)
 }
 
+func TestInspectAddrs(t *testing.T) {
+	clk := mockClock.NewMock()
+	ps, err := NewPeerstore(WithClock(clk))
+	require.NoError(t, err)
+	defer ps.Close()
+
+	p := peer.ID("p")
+	addr1 := multiaddr.StringCast("/ip4/1.2.3.4/tcp/1")
+	addr2 := multiaddr.StringCast("/ip4/1.2.3.4/tcp/2")
+	ps.AddAddrsWithSource(p, []multiaddr.Multiaddr{addr1}, time.Hour, pstore.AddrSourceDHT)
+	ps.AddAddr(p, addr2, pstore.ConnectedAddrTTL)
+	start := clk.Now()
+
+	clk.Add(time.Minute)
+	// A less reliable source doesn't replace the recorded one.
+	ps.AddAddrsWithSource(p, []multiaddr.Multiaddr{addr1}, time.Minute, pstore.AddrSourceManual)
+	recs := ps.InspectAddrs(p)
+	require.Len(t, recs, 2)
+	for _, rec := range recs {
+		switch {
+		case rec.Addr.Equal(addr1):
+			require.Equal(t, pstore.AddrSourceDHT, rec.Source)
+			require.Equal(t, 59*time.Minute, rec.TTL)
+			require.Equal(t, clk.Now(), rec.LastSeen)
+		case rec.Addr.Equal(addr2):
+			require.Equal(t, pstore.AddrSourceUnknown, rec.Source)
+			require.Equal(t, time.Duration(pstore.ConnectedAddrTTL), rec.TTL)
+			require.Equal(t, start, rec.LastSeen)
+		}
+	}
+
+	ps.AddAddrsWithSource(p, []multiaddr.Multiaddr{addr1}, time.Minute, pstore.AddrSourceDial)
+	clk.Add(time.Hour)
+	recs = ps.InspectAddrs(p)
+	require.Len(t, recs, 1)
+	require.True(t, recs[0].Addr.Equal(addr2))
+}
+
+type mockMetricsTracer struct {
+	peers, addrs, keys int
+	expired, evicted   int
+}
+
+func (m *mockMetricsTracer) PeerstoreSize(peers, addrs, keys int) {
+	m.peers, m.addrs, m.keys = peers, addrs, keys
+}
+func (m *mockMetricsTracer) AddrsExpired(n int) { m.expired += n }
+func (m *mockMetricsTracer) PeerEvicted()       { m.evicted++ }
+
+func TestMetrics(t *testing.T) {
+	clk := mockClock.NewMock()
+	mt := &mockMetricsTracer{}
+	ps, err := NewPeerstore(WithClock(clk), WithMetricsTracer(mt), WithMaxPeers(2))
+	require.NoError(t, err)
+	defer ps.Close()
+
+	addr1 := multiaddr.StringCast("/ip4/1.2.3.4/tcp/1")
+	addr2 := multiaddr.StringCast("/ip4/1.2.3.4/tcp/2")
+	ps.AddAddrs("p1", []multiaddr.Multiaddr{addr1, addr2}, time.Hour)
+	ps.AddAddr("p2", addr1, time.Minute)
+	_, pub, err := test.RandTestKeyPair(crypto.Ed25519, 256)
+	require.NoError(t, err)
+	p3, err := peer.IDFromPublicKey(pub)
+	require.NoError(t, err)
+	ps.AddAddr(p3, addr1, time.Hour)
+	require.Equal(t, 1, mt.evicted)
+	require.NoError(t, ps.AddPubKey(p3, pub))
+
+	clk.Add(time.Minute)
+	ps.gc()
+	require.Equal(t, 1, mt.expired)
+	require.Equal(t, 1, mt.peers)
+	require.Equal(t, 1, mt.addrs)
+	require.Equal(t, 1, mt.keys)
+}
+
+func TestNotifyProtocolsChanged(t *testing.T) {
+	ps, err := NewPeerstore()
+	require.NoError(t, err)
+	defer ps.Close()
+
+	type change struct {
+		p              peer.ID
+		added, removed []protocol.ID
+	}
+	var changes []change
+	cancel := ps.NotifyProtocolsChanged(func(p peer.ID, added, removed []protocol.ID) {
+		changes = append(changes, change{p: p, added: added, removed: removed})
+	})
+	last := func() change {
+		t.Helper()
+		require.NotEmpty(t, changes)
+		c := changes[len(changes)-1]
+		changes = changes[:len(changes)-1]
+		return c
+	}
+
+	p := peer.ID("p")
+	require.NoError(t, ps.AddProtocols(p, "/a", "/b"))
+	c := last()
+	require.Equal(t, p, c.p)
+	require.ElementsMatch(t, []protocol.ID{"/a", "/b"}, c.added)
+	require.Empty(t, c.removed)
+
+	// Nothing changes, so we're not notified.
+	require.NoError(t, ps.AddProtocols(p, "/a"))
+	require.NoError(t, ps.RemoveProtocols(p, "/c"))
+	require.Empty(t, changes)
+
+	require.NoError(t, ps.SetProtocols(p, "/b", "/c"))
+	c = last()
+	require.Equal(t, []protocol.ID{"/c"}, c.added)
+	require.Equal(t, []protocol.ID{"/a"}, c.removed)
+
+	require.NoError(t, ps.RemoveProtocols(p, "/b"))
+	c = last()
+	require.Empty(t, c.added)
+	require.Equal(t, []protocol.ID{"/b"}, c.removed)
+
+	ps.RemovePeer(p)
+	c = last()
+	require.Equal(t, []protocol.ID{"/c"}, c.removed)
+
+	cancel()
+	require.NoError(t, ps.AddProtocols(p, "/a"))
+	require.Empty(t, changes)
+}
+
+func TestPeersSupporting(t *testing.T) {
+	ps, err := NewPeerstore()
+	require.NoError(t, err)
+	defer ps.Close()
+
+	require.NoError(t, ps.AddProtocols("p1", "/a", "/b"))
+	require.NoError(t, ps.SetProtocols("p2", "/a"))
+	require.NoError(t, ps.AddProtocols("p3", "/b"))
+	require.ElementsMatch(t, peer.IDSlice{"p1", "p2"}, ps.PeersSupporting("/a"))
+	require.ElementsMatch(t, peer.IDSlice{"p1", "p3"}, ps.PeersSupporting("/b"))
+	require.Empty(t, ps.PeersSupporting("/c"))
+
+	require.NoError(t, ps.SetProtocols("p2", "/b", "/c"))
+	require.NoError(t, ps.RemoveProtocols("p1", "/a"))
+	ps.RemovePeer("p3")
+	require.Empty(t, ps.PeersSupporting("/a"))
+	require.ElementsMatch(t, peer.IDSlice{"p1", "p2"}, ps.PeersSupporting("/b"))
+	require.Equal(t, peer.IDSlice{"p2"}, pstore.PeersSupporting(ps, "/c"))
+}
+
+func TestMetadataTTL(t *testing.T) {
+	clk := mockClock.NewMock()
+	ps, err := NewPeerstore(WithClock(clk))
+	require.NoError(t, err)
+	defer ps.Close()
+
+	p := peer.ID("p")
+	require.NoError(t, ps.PutWithTTL(p, "transient", "a", time.Minute))
+	require.NoError(t, ps.Put(p, "permanent", 42))
+	v, err := pstore.GetMetadata[string](ps, p, "transient")
+	require.NoError(t, err)
+	require.Equal(t, "a", v)
+	_, err = pstore.GetMetadata[string](ps, p, "permanent")
+	require.Error(t, err)
+	n, err := pstore.GetMetadata[int](ps, p, "permanent")
+	require.NoError(t, err)
+	require.Equal(t, 42, n)
+
+	clk.Add(time.Minute)
+	_, err = ps.Get(p, "transient")
+	require.ErrorIs(t, err, pstore.ErrNotFound)
+
+	// Reading removes the expired values.
+	require.NotContains(t, ps.memoryPeerMetadata.ds[p], "transient")
+	require.NoError(t, ps.PutWithTTL("other", "transient", "b", time.Hour))
+
+	// Put removes the TTL.
+	require.NoError(t, ps.Put("other", "transient", "c"))
+	clk.Add(2 * time.Hour)
+	v, err = pstore.GetMetadata[string](ps, "other", "transient")
+	require.NoError(t, err)
+	require.Equal(t, "c", v)
+}
+
+func TestMetadataSweep(t *testing.T) {
+	clk := mockClock.NewMock()
+	ps, err := NewPeerstore(WithClock(clk))
+	require.NoError(t, err)
+	defer ps.Close()
+
+	require.NoError(t, ps.PutWithTTL("p1", "transient", "a", time.Minute))
+	require.NoError(t, ps.PutWithTTL("p2", "transient", "b", time.Hour))
+	require.NoError(t, ps.Put("p2", "permanent", "c"))
+
+	// The gc removes the expired values, without them being read.
+	clk.Add(time.Minute)
+	ps.gc()
+	require.NotContains(t, ps.memoryPeerMetadata.ds, peer.ID("p1"))
+	require.Contains(t, ps.memoryPeerMetadata.ds["p2"], "transient")
+
+	clk.Add(time.Hour)
+	ps.gc()
+	require.Equal(t, map[string]metadataValue{"permanent": {val: "c"}}, ps.memoryPeerMetadata.ds["p2"])
+}
+
 func BenchmarkGC(b *testing.B) {
 	clock := mockClock.NewMock()
 	ps, err := NewPeerstore(WithClock(clock))
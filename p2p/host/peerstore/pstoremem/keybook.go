@@ -39,6 +39,19 @@ func (mkb *memoryKeyBook) PeersWithKeys() peer.IDSlice {
 	return ps
 }
 
+// numPeers returns the number of peers with keys.
+func (mkb *memoryKeyBook) numPeers() int {
+	mkb.RLock()
+	defer mkb.RUnlock()
+	n := len(mkb.pks)
+	for p := range mkb.sks {
+		if _, found := mkb.pks[p]; !found {
+			n++
+		}
+	}
+	return n
+}
+
 func (mkb *memoryKeyBook) PubKey(p peer.ID) ic.PubKey {
 	mkb.RLock()
 	pk := mkb.pks[p]
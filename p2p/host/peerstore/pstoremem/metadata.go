@@ -2,49 +2,101 @@ package pstoremem
 
 import (
 	"sync"
+	"time"
 
 	"github.com/libp2p/go-libp2p/core/peer"
 	pstore "github.com/libp2p/go-libp2p/core/peerstore"
 )
 
+type metadataValue struct {
+	val interface{}
+	// expiry is zero if the value doesn't expire.
+	expiry time.Time
+}
+
+func (v metadataValue) expiredBy(now time.Time) bool {
+	return !v.expiry.IsZero() && !now.Before(v.expiry)
+}
+
 type memoryPeerMetadata struct {
 	// store other data, like versions
-	ds     map[peer.ID]map[string]interface{}
+	ds     map[peer.ID]map[string]metadataValue
 	dslock sync.RWMutex
+	clock  clock
 }
 
-var _ pstore.PeerMetadata = (*memoryPeerMetadata)(nil)
+var (
+	_ pstore.PeerMetadata    = (*memoryPeerMetadata)(nil)
+	_ pstore.PeerMetadataTTL = (*memoryPeerMetadata)(nil)
+)
 
 func NewPeerMetadata() *memoryPeerMetadata {
 	return &memoryPeerMetadata{
-		ds: make(map[peer.ID]map[string]interface{}),
+		ds:    make(map[peer.ID]map[string]metadataValue),
+		clock: realclock{},
 	}
 }
 
 func (ps *memoryPeerMetadata) Put(p peer.ID, key string, val interface{}) error {
+	ps.put(p, key, metadataValue{val: val})
+	return nil
+}
+
+func (ps *memoryPeerMetadata) PutWithTTL(p peer.ID, key string, val interface{}, ttl time.Duration) error {
+	ps.put(p, key, metadataValue{val: val, expiry: ps.clock.Now().Add(ttl)})
+	return nil
+}
+
+func (ps *memoryPeerMetadata) put(p peer.ID, key string, v metadataValue) {
 	ps.dslock.Lock()
 	defer ps.dslock.Unlock()
 	m, ok := ps.ds[p]
 	if !ok {
-		m = make(map[string]interface{})
+		m = make(map[string]metadataValue)
 		ps.ds[p] = m
 	}
-	m[key] = val
-	return nil
+	m[key] = v
 }
 
+// Get returns the value stored for p under key. Expired values are removed
+// when they're read, or by the next sweep.
 func (ps *memoryPeerMetadata) Get(p peer.ID, key string) (interface{}, error) {
 	ps.dslock.RLock()
-	defer ps.dslock.RUnlock()
-	m, ok := ps.ds[p]
+	v, ok := ps.ds[p][key]
+	ps.dslock.RUnlock()
 	if !ok {
 		return nil, pstore.ErrNotFound
 	}
-	val, ok := m[key]
-	if !ok {
+	if now := ps.clock.Now(); v.expiredBy(now) {
+		ps.dslock.Lock()
+		// The value might have been overwritten in the meantime.
+		if m, ok := ps.ds[p]; ok && m[key].expiredBy(now) {
+			delete(m, key)
+			if len(m) == 0 {
+				delete(ps.ds, p)
+			}
+		}
+		ps.dslock.Unlock()
 		return nil, pstore.ErrNotFound
 	}
-	return val, nil
+	return v.val, nil
+}
+
+// sweep removes the values that expired by now, including the ones that are
+// never read. The peerstore calls it periodically.
+func (ps *memoryPeerMetadata) sweep(now time.Time) {
+	ps.dslock.Lock()
+	defer ps.dslock.Unlock()
+	for p, m := range ps.ds {
+		for key, v := range m {
+			if v.expiredBy(now) {
+				delete(m, key)
+			}
+		}
+		if len(m) == 0 {
+			delete(ps.ds, p)
+		}
+	}
 }
 
 func (ps *memoryPeerMetadata) RemovePeer(p peer.ID) {
@@ -0,0 +1,118 @@
+package pstoremem
+
+import (
+	"github.com/libp2p/go-libp2p/p2p/metricshelper"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricNamespace = "libp2p_peerstore"
+
+var (
+	peersCount = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Name:      "peers",
+			Help:      "Number of peers with addresses",
+		},
+	)
+	addrsCount = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Name:      "addrs",
+			Help:      "Number of addresses",
+		},
+	)
+	keysCount = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Name:      "keys",
+			Help:      "Number of peers with keys",
+		},
+	)
+	addrsExpiredTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "addrs_expired_total",
+			Help:      "Addresses removed because they expired",
+		},
+	)
+	peersEvictedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "peers_evicted_total",
+			Help:      "Peers evicted because the peer limit was reached",
+		},
+	)
+	collectors = []prometheus.Collector{
+		peersCount,
+		addrsCount,
+		keysCount,
+		addrsExpiredTotal,
+		peersEvictedTotal,
+	}
+)
+
+// MetricsTracer is the interface for tracking metrics for the peerstore
+type MetricsTracer interface {
+	// PeerstoreSize is called periodically with the number of peers with
+	// addresses, the number of addresses, and the number of peers with keys.
+	PeerstoreSize(peers, addrs, keys int)
+	// AddrsExpired is called when expired addresses are garbage collected.
+	AddrsExpired(n int)
+	// PeerEvicted is called when a peer is evicted because the peer limit
+	// was reached.
+	PeerEvicted()
+}
+
+type metricsTracer struct{}
+
+var _ MetricsTracer = &metricsTracer{}
+
+type metricsTracerSetting struct {
+	reg prometheus.Registerer
+}
+
+type MetricsTracerOption func(*metricsTracerSetting)
+
+func WithRegisterer(reg prometheus.Registerer) MetricsTracerOption {
+	return func(s *metricsTracerSetting) {
+		if reg != nil {
+			s.reg = reg
+		}
+	}
+}
+
+func NewMetricsTracer(opts ...MetricsTracerOption) MetricsTracer {
+	setting := &metricsTracerSetting{reg: prometheus.DefaultRegisterer}
+	for _, opt := range opts {
+		opt(setting)
+	}
+	metricshelper.RegisterCollectors(setting.reg, collectors...)
+	return &metricsTracer{}
+}
+
+func (m *metricsTracer) PeerstoreSize(peers, addrs, keys int) {
+	peersCount.Set(float64(peers))
+	addrsCount.Set(float64(addrs))
+	keysCount.Set(float64(keys))
+}
+
+func (m *metricsTracer) AddrsExpired(n int) {
+	addrsExpiredTotal.Add(float64(n))
+}
+
+func (m *metricsTracer) PeerEvicted() {
+	peersEvictedTotal.Inc()
+}
+
+// WithMetricsTracer configures the peerstore to report metrics. The size of
+// the peerstore is reported every time the address book is garbage collected.
+// Metrics are disabled by default. To enable them for a libp2p host, construct
+// the peerstore with this option and pass it using the libp2p.Peerstore option.
+func WithMetricsTracer(mt MetricsTracer) AddrBookOption {
+	return func(b *memoryAddrBook) error {
+		b.metricsTracer = mt
+		return nil
+	}
+}
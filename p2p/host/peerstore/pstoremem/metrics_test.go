@@ -0,0 +1,23 @@
+//go:build nocover
+
+package pstoremem
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestMetricsNoAllocNoCover(t *testing.T) {
+	tr := NewMetricsTracer()
+	tests := map[string]func(){
+		"PeerstoreSize": func() { tr.PeerstoreSize(rand.Intn(100), rand.Intn(1000), rand.Intn(100)) },
+		"AddrsExpired":  func() { tr.AddrsExpired(rand.Intn(100)) },
+		"PeerEvicted":   func() { tr.PeerEvicted() },
+	}
+	for method, f := range tests {
+		allocs := testing.AllocsPerRun(1000, f)
+		if allocs > 0 {
+			t.Fatalf("Alloc Test: %s, got: %0.2f, expected: 0 allocs", method, allocs)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package pstoremem
 import (
 	"fmt"
 	"io"
+	"time"
 
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/peerstore"
@@ -16,29 +17,49 @@ type pstoremem struct {
 	*memoryAddrBook
 	*memoryProtoBook
 	*memoryPeerMetadata
+	*memoryScoreBook
 }
 
-var _ peerstore.Peerstore = &pstoremem{}
+var (
+	_ peerstore.Peerstore       = &pstoremem{}
+	_ peerstore.LatencyTracker  = &pstoremem{}
+	_ peerstore.PeerMetadataTTL = &pstoremem{}
+)
 
 type Option interface{}
 
 // NewPeerstore creates an in-memory thread-safe collection of peers.
 // It's the caller's responsibility to call RemovePeer to ensure
-// that memory consumption of the peerstore doesn't grow unboundedly.
+// that memory consumption of the peerstore doesn't grow unboundedly,
+// unless the number of peers is limited using WithMaxPeers.
 func NewPeerstore(opts ...Option) (ps *pstoremem, err error) {
 	var protoBookOpts []ProtoBookOption
 	var addrBookOpts []AddrBookOption
+	var scoreBookOpts []ScoreBookOption
 	for _, opt := range opts {
 		switch o := opt.(type) {
 		case ProtoBookOption:
 			protoBookOpts = append(protoBookOpts, o)
 		case AddrBookOption:
 			addrBookOpts = append(addrBookOpts, o)
+		case ScoreBookOption:
+			scoreBookOpts = append(scoreBookOpts, o)
 		default:
 			return nil, fmt.Errorf("unexpected peer store option: %v", o)
 		}
 	}
-	ab := NewAddrBook(addrBookOpts...)
+	ps = &pstoremem{
+		Metrics:            pstore.NewMetrics(),
+		memoryKeyBook:      NewKeyBook(),
+		memoryPeerMetadata: NewPeerMetadata(),
+	}
+	// The address book removes the peers it evicts from the rest of the peerstore,
+	// and its gc removes the expired metadata.
+	ab := NewAddrBook(append(addrBookOpts,
+		withRemovePeer(ps.RemovePeer),
+		withNumKeys(ps.memoryKeyBook.numPeers),
+		withGC(ps.memoryPeerMetadata.sweep),
+	)...)
 
 	pb, err := NewProtoBook(protoBookOpts...)
 	if err != nil {
@@ -46,13 +67,18 @@ func NewPeerstore(opts ...Option) (ps *pstoremem, err error) {
 		return nil, err
 	}
 
-	return &pstoremem{
-		Metrics:            pstore.NewMetrics(),
-		memoryKeyBook:      NewKeyBook(),
-		memoryAddrBook:     ab,
-		memoryProtoBook:    pb,
-		memoryPeerMetadata: NewPeerMetadata(),
-	}, nil
+	sb, err := NewScoreBook(scoreBookOpts...)
+	if err != nil {
+		ab.Close()
+		return nil, err
+	}
+	sb.clock = ab.clock
+	ps.memoryPeerMetadata.clock = ab.clock
+
+	ps.memoryAddrBook = ab
+	ps.memoryProtoBook = pb
+	ps.memoryScoreBook = sb
+	return ps, nil
 }
 
 func (ps *pstoremem) Close() (err error) {
@@ -102,11 +128,37 @@ func (ps *pstoremem) PeerInfo(p peer.ID) peer.AddrInfo {
 // * the KeyBook
 // * the ProtoBook
 // * the PeerMetadata
+// * the ScoreBook
 // * the Metrics
 // It DOES NOT remove the peer from the AddrBook.
 func (ps *pstoremem) RemovePeer(p peer.ID) {
 	ps.memoryKeyBook.RemovePeer(p)
 	ps.memoryProtoBook.RemovePeer(p)
 	ps.memoryPeerMetadata.RemovePeer(p)
+	ps.memoryScoreBook.RemovePeer(p)
 	ps.Metrics.RemovePeer(p)
 }
+
+// RecordLatencySample records a latency sample measured over a transport, if
+// the Metrics support it. Otherwise, it's recorded as by RecordLatency.
+func (ps *pstoremem) RecordLatencySample(p peer.ID, transport string, rtt time.Duration) {
+	peerstore.RecordLatencySample(ps.Metrics, p, transport, rtt)
+}
+
+// LatencyStats returns the stats of the recent latency samples of a peer, if
+// the Metrics keep them.
+func (ps *pstoremem) LatencyStats(p peer.ID, transport string) (peerstore.LatencyStats, bool) {
+	lt, ok := peerstore.GetLatencyTracker(ps.Metrics)
+	if !ok {
+		return peerstore.LatencyStats{}, false
+	}
+	return lt.LatencyStats(p, transport)
+}
+
+// RemoveLatencySamples removes the recent latency samples of a peer, if the
+// Metrics keep them.
+func (ps *pstoremem) RemoveLatencySamples(p peer.ID) {
+	if lt, ok := peerstore.GetLatencyTracker(ps.Metrics); ok {
+		lt.RemoveLatencySamples(p)
+	}
+}
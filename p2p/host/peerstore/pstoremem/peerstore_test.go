@@ -1,8 +1,12 @@
 package pstoremem
 
 import (
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/peerstore"
 	ma "github.com/multiformats/go-multiaddr"
 	"github.com/stretchr/testify/require"
@@ -21,3 +25,127 @@ func TestPeerStoreAddrBookOpts(t *testing.T) {
 	res = ps.Addrs("p2")
 	require.Empty(t, res)
 }
+
+func TestPeerStoreEviction(t *testing.T) {
+	var evicted []peer.ID
+	ps, err := NewPeerstore(
+		WithMaxPeers(3),
+		WithMaxAddrsPerPeer(1),
+		WithProtectedPeers(func(p peer.ID) bool { return p == "protected" }),
+		WithEvictionCallback(func(p peer.ID) { evicted = append(evicted, p) }),
+	)
+	require.NoError(t, err)
+	defer ps.Close()
+
+	addr1 := ma.StringCast("/ip4/1.2.3.4/udp/1/quic-v1")
+	addr2 := ma.StringCast("/ip4/1.2.3.4/tcp/1")
+
+	// Only one unconnected address is stored per peer.
+	ps.AddAddrs("protected", []ma.Multiaddr{addr1, addr2}, peerstore.TempAddrTTL)
+	require.Len(t, ps.Addrs("protected"), 1)
+	ps.AddAddr("connected", addr1, peerstore.ConnectedAddrTTL)
+	ps.AddAddr("connected", addr2, peerstore.ConnectedAddrTTL)
+	require.Len(t, ps.Addrs("connected"), 2)
+	ps.AddAddr("p1", addr1, peerstore.TempAddrTTL)
+	require.NoError(t, ps.AddProtocols("p1", "/proto"))
+
+	// p1 is the only peer that's neither connected nor protected.
+	ps.AddAddr("p2", addr1, peerstore.TempAddrTTL)
+	require.Equal(t, []peer.ID{"p1"}, evicted)
+	require.Empty(t, ps.Addrs("p1"))
+	protos, err := ps.GetProtocols("p1")
+	require.NoError(t, err)
+	require.Empty(t, protos)
+	require.Len(t, ps.PeersWithAddrs(), 3)
+
+	// After disconnecting, the connected peer is the least recently used one.
+	ps.UpdateAddrs("connected", peerstore.ConnectedAddrTTL, peerstore.RecentlyConnectedAddrTTL)
+	ps.AddAddr("p3", addr1, peerstore.TempAddrTTL)
+	require.Equal(t, []peer.ID{"p1", "p2"}, evicted)
+	ps.AddAddr("p4", addr1, peerstore.TempAddrTTL)
+	require.Equal(t, []peer.ID{"p1", "p2", "connected"}, evicted)
+	require.ElementsMatch(t, peer.IDSlice{"protected", "p3", "p4"}, ps.PeersWithAddrs())
+}
+
+func TestPeerStoreMemoryBudget(t *testing.T) {
+	addr := ma.StringCast("/ip4/1.2.3.4/tcp/1")
+	peerSize := peerOverhead + addrOverhead + len(addr.Bytes())
+
+	var evicted []peer.ID
+	ps, err := NewPeerstore(
+		WithAddrBookMemoryBudget(3*peerSize),
+		WithEvictionCallback(func(p peer.ID) { evicted = append(evicted, p) }),
+	)
+	require.NoError(t, err)
+	defer ps.Close()
+
+	ps.AddAddr("connected", addr, peerstore.ConnectedAddrTTL)
+	ps.AddAddr("p1", addr, peerstore.TempAddrTTL)
+	ps.AddAddr("p2", addr, peerstore.TempAddrTTL)
+	require.Empty(t, evicted)
+
+	// The connected peer is not evicted.
+	ps.AddAddr("p3", addr, peerstore.TempAddrTTL)
+	require.Equal(t, []peer.ID{"p1"}, evicted)
+
+	// Adding an address to a known peer can exceed the budget as well.
+	ps.AddAddr("p3", ma.StringCast("/ip4/1.2.3.4/tcp/2"), peerstore.TempAddrTTL)
+	require.Equal(t, []peer.ID{"p1", "p2"}, evicted)
+	require.ElementsMatch(t, peer.IDSlice{"connected", "p3"}, ps.PeersWithAddrs())
+	require.LessOrEqual(t, ps.counters.bytes.Load(), int64(3*peerSize))
+
+	ps.ClearAddrs("p3")
+	ps.ClearAddrs("connected")
+	require.Zero(t, ps.counters.bytes.Load())
+}
+
+func TestPeerStoreEvictionBusyShard(t *testing.T) {
+	ps, err := NewPeerstore(WithMaxPeers(1))
+	require.NoError(t, err)
+	defer ps.Close()
+
+	addr := ma.StringCast("/ip4/1.2.3.4/tcp/1")
+	ps.AddAddr("p1", addr, peerstore.TempAddrTTL)
+
+	// A peer whose shard is in use is still evicted once the shard is free.
+	s := ps.shards.get("p1")
+	s.mu.RLock()
+	started := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		close(started)
+		ps.AddAddr("p2", addr, peerstore.TempAddrTTL)
+	}()
+	<-started
+	// New readers are blocked once the eviction waits for the shard.
+	require.Eventually(t, func() bool {
+		if s.mu.TryRLock() {
+			s.mu.RUnlock()
+			return false
+		}
+		return true
+	}, time.Second, time.Millisecond)
+	s.mu.RUnlock()
+	<-done
+	require.Equal(t, peer.IDSlice{"p2"}, ps.PeersWithAddrs())
+}
+
+func TestSignedPeerRecordLimitConcurrent(t *testing.T) {
+	ab := NewAddrBook(WithMaxSignedPeerRecords(10))
+	defer ab.Close()
+
+	var wg sync.WaitGroup
+	var reserved atomic.Int32
+	for range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ab.reserveSignedPeerRecord() {
+				reserved.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int32(10), reserved.Load())
+}
@@ -3,6 +3,7 @@ package pstoremem
 import (
 	"errors"
 	"sync"
+	"sync/atomic"
 
 	"github.com/libp2p/go-libp2p/core/peer"
 	pstore "github.com/libp2p/go-libp2p/core/peerstore"
@@ -11,7 +12,28 @@ import (
 
 type protoSegment struct {
 	sync.RWMutex
+	// notifyMx is held while notifying about the changes of the segment's
+	// peers, so that the notifications of a peer are delivered in order.
+	notifyMx  sync.Mutex
 	protocols map[peer.ID]map[protocol.ID]struct{}
+	// peers indexes the peers of the segment by protocol.
+	peers map[protocol.ID]map[peer.ID]struct{}
+}
+
+func (s *protoSegment) indexAdd(p peer.ID, proto protocol.ID) {
+	peers, ok := s.peers[proto]
+	if !ok {
+		peers = make(map[peer.ID]struct{})
+		s.peers[proto] = peers
+	}
+	peers[p] = struct{}{}
+}
+
+func (s *protoSegment) indexRemove(p peer.ID, proto protocol.ID) {
+	delete(s.peers[proto], p)
+	if len(s.peers[proto]) == 0 {
+		delete(s.peers, proto)
+	}
 }
 
 type protoSegments [256]*protoSegment
@@ -26,9 +48,23 @@ type memoryProtoBook struct {
 	segments protoSegments
 
 	maxProtos int
+
+	notifyMx  sync.RWMutex
+	notifiees map[*protoNotifiee]struct{}
+	// hasNotifiees allows us to skip computing the changes if nobody is
+	// interested in them.
+	hasNotifiees atomic.Bool
+}
+
+type protoNotifiee struct {
+	f func(p peer.ID, added, removed []protocol.ID)
 }
 
-var _ pstore.ProtoBook = (*memoryProtoBook)(nil)
+var (
+	_ pstore.ProtoBook         = (*memoryProtoBook)(nil)
+	_ pstore.ProtoBookNotifier = (*memoryProtoBook)(nil)
+	_ pstore.ProtoBookIndex    = (*memoryProtoBook)(nil)
+)
 
 type ProtoBookOption func(book *memoryProtoBook) error
 
@@ -45,11 +81,13 @@ func NewProtoBook(opts ...ProtoBookOption) (*memoryProtoBook, error) {
 			for i := range ret {
 				ret[i] = &protoSegment{
 					protocols: make(map[peer.ID]map[protocol.ID]struct{}),
+					peers:     make(map[protocol.ID]map[peer.ID]struct{}),
 				}
 			}
 			return ret
 		}(),
 		maxProtos: 128,
+		notifiees: make(map[*protoNotifiee]struct{}),
 	}
 
 	for _, opt := range opts {
@@ -70,32 +108,65 @@ func (pb *memoryProtoBook) SetProtocols(p peer.ID, protos ...protocol.ID) error
 		newprotos[proto] = struct{}{}
 	}
 
+	var added, removed []protocol.ID
+	notify := pb.hasNotifiees.Load()
 	s := pb.segments.get(p)
 	s.Lock()
+	oldprotos := s.protocols[p]
+	for proto := range newprotos {
+		if _, ok := oldprotos[proto]; !ok {
+			s.indexAdd(p, proto)
+			if notify {
+				added = append(added, proto)
+			}
+		}
+	}
+	for proto := range oldprotos {
+		if _, ok := newprotos[proto]; !ok {
+			s.indexRemove(p, proto)
+			if notify {
+				removed = append(removed, proto)
+			}
+		}
+	}
 	s.protocols[p] = newprotos
-	s.Unlock()
-
+	pb.unlockAndNotify(s, p, added, removed)
 	return nil
 }
 
 func (pb *memoryProtoBook) AddProtocols(p peer.ID, protos ...protocol.ID) error {
 	s := pb.segments.get(p)
 	s.Lock()
-	defer s.Unlock()
+	added, err := pb.addProtocolsLocked(s, p, protos)
+	if err != nil {
+		s.Unlock()
+		return err
+	}
+	pb.unlockAndNotify(s, p, added, nil)
+	return nil
+}
 
+func (pb *memoryProtoBook) addProtocolsLocked(s *protoSegment, p peer.ID, protos []protocol.ID) (added []protocol.ID, err error) {
 	protomap, ok := s.protocols[p]
 	if !ok {
 		protomap = make(map[protocol.ID]struct{})
 		s.protocols[p] = protomap
 	}
 	if len(protomap)+len(protos) > pb.maxProtos {
-		return errTooManyProtocols
+		return nil, errTooManyProtocols
 	}
 
+	notify := pb.hasNotifiees.Load()
 	for _, proto := range protos {
+		if _, ok := protomap[proto]; !ok {
+			s.indexAdd(p, proto)
+			if notify {
+				added = append(added, proto)
+			}
+		}
 		protomap[proto] = struct{}{}
 	}
-	return nil
+	return added, nil
 }
 
 func (pb *memoryProtoBook) GetProtocols(p peer.ID) ([]protocol.ID, error) {
@@ -114,21 +185,31 @@ func (pb *memoryProtoBook) GetProtocols(p peer.ID) ([]protocol.ID, error) {
 func (pb *memoryProtoBook) RemoveProtocols(p peer.ID, protos ...protocol.ID) error {
 	s := pb.segments.get(p)
 	s.Lock()
-	defer s.Unlock()
+	pb.unlockAndNotify(s, p, nil, pb.removeProtocolsLocked(s, p, protos))
+	return nil
+}
 
+func (pb *memoryProtoBook) removeProtocolsLocked(s *protoSegment, p peer.ID, protos []protocol.ID) (removed []protocol.ID) {
 	protomap, ok := s.protocols[p]
 	if !ok {
 		// nothing to remove.
 		return nil
 	}
 
+	notify := pb.hasNotifiees.Load()
 	for _, proto := range protos {
+		if _, ok := protomap[proto]; ok {
+			s.indexRemove(p, proto)
+			if notify {
+				removed = append(removed, proto)
+			}
+		}
 		delete(protomap, proto)
 	}
 	if len(protomap) == 0 {
 		delete(s.protocols, p)
 	}
-	return nil
+	return removed
 }
 
 func (pb *memoryProtoBook) SupportsProtocols(p peer.ID, protos ...protocol.ID) ([]protocol.ID, error) {
@@ -160,8 +241,77 @@ func (pb *memoryProtoBook) FirstSupportedProtocol(p peer.ID, protos ...protocol.
 }
 
 func (pb *memoryProtoBook) RemovePeer(p peer.ID) {
+	var removed []protocol.ID
 	s := pb.segments.get(p)
+	notify := pb.hasNotifiees.Load()
 	s.Lock()
+	for proto := range s.protocols[p] {
+		s.indexRemove(p, proto)
+		if notify {
+			removed = append(removed, proto)
+		}
+	}
 	delete(s.protocols, p)
+	pb.unlockAndNotify(s, p, nil, removed)
+}
+
+// PeersSupporting returns the peers that support proto. It uses an index, so
+// it doesn't need to iterate over all peers.
+func (pb *memoryProtoBook) PeersSupporting(proto protocol.ID) peer.IDSlice {
+	var peers peer.IDSlice
+	for _, s := range pb.segments {
+		s.RLock()
+		for p := range s.peers[proto] {
+			peers = append(peers, p)
+		}
+		s.RUnlock()
+	}
+	return peers
+}
+
+// NotifyProtocolsChanged registers f to be called every time the protocols
+// supported by a peer change. Removing a peer from the peerstore removes all
+// its protocols. f is called synchronously, in the order the changes of a peer
+// were made. It is called without holding the locks protecting the protocols,
+// so it may read from the ProtoBook, but it must not block.
+func (pb *memoryProtoBook) NotifyProtocolsChanged(f func(p peer.ID, added, removed []protocol.ID)) (cancel func()) {
+	n := &protoNotifiee{f: f}
+	pb.notifyMx.Lock()
+	pb.notifiees[n] = struct{}{}
+	pb.hasNotifiees.Store(true)
+	pb.notifyMx.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			pb.notifyMx.Lock()
+			delete(pb.notifiees, n)
+			pb.hasNotifiees.Store(len(pb.notifiees) > 0)
+			pb.notifyMx.Unlock()
+		})
+	}
+}
+
+// unlockAndNotify unlocks the segment s, and calls the registered notifiees if
+// there are any changes. The segment's notifyMx is taken before unlocking s, so
+// that concurrent changes of the same peer are notified in order.
+func (pb *memoryProtoBook) unlockAndNotify(s *protoSegment, p peer.ID, added, removed []protocol.ID) {
+	if len(added) == 0 && len(removed) == 0 {
+		s.Unlock()
+		return
+	}
+	s.notifyMx.Lock()
+	defer s.notifyMx.Unlock()
 	s.Unlock()
+
+	pb.notifyMx.RLock()
+	notifiees := make([]*protoNotifiee, 0, len(pb.notifiees))
+	for n := range pb.notifiees {
+		notifiees = append(notifiees, n)
+	}
+	pb.notifyMx.RUnlock()
+
+	for _, n := range notifiees {
+		n.f(p, added, removed)
+	}
 }
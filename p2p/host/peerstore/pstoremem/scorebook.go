@@ -0,0 +1,118 @@
+package pstoremem
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	pstore "github.com/libp2p/go-libp2p/core/peerstore"
+)
+
+type score struct {
+	value   float64
+	updated time.Time
+}
+
+type memoryScoreBook struct {
+	mu     sync.RWMutex
+	scores map[peer.ID]map[string]score
+
+	// halfLives are the half-lives of the decaying scores, by name.
+	halfLives map[string]time.Duration
+	clock     clock
+}
+
+var _ pstore.ScoreBook = (*memoryScoreBook)(nil)
+
+type ScoreBookOption func(book *memoryScoreBook) error
+
+// WithScoreDecay makes the scores with the given name decay towards zero,
+// halving every halfLife.
+func WithScoreDecay(name string, halfLife time.Duration) ScoreBookOption {
+	return func(sb *memoryScoreBook) error {
+		sb.halfLives[name] = halfLife
+		return nil
+	}
+}
+
+func NewScoreBook(opts ...ScoreBookOption) (*memoryScoreBook, error) {
+	sb := &memoryScoreBook{
+		scores:    make(map[peer.ID]map[string]score),
+		halfLives: make(map[string]time.Duration),
+		clock:     realclock{},
+	}
+	for _, opt := range opts {
+		if err := opt(sb); err != nil {
+			return nil, err
+		}
+	}
+	return sb, nil
+}
+
+// decayedUnlocked returns the value of s, after decaying it until now.
+func (sb *memoryScoreBook) decayedUnlocked(name string, s score, now time.Time) float64 {
+	halfLife := sb.halfLives[name]
+	if halfLife <= 0 {
+		return s.value
+	}
+	return s.value * math.Exp2(-float64(now.Sub(s.updated))/float64(halfLife))
+}
+
+func (sb *memoryScoreBook) Score(p peer.ID, name string) float64 {
+	sb.mu.RLock()
+	defer sb.mu.RUnlock()
+
+	s, ok := sb.scores[p][name]
+	if !ok {
+		return 0
+	}
+	return sb.decayedUnlocked(name, s, sb.clock.Now())
+}
+
+func (sb *memoryScoreBook) SetScore(p peer.ID, name string, value float64) error {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	sb.setUnlocked(p, name, value)
+	return nil
+}
+
+func (sb *memoryScoreBook) AddToScore(p peer.ID, name string, delta float64) (float64, error) {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	value := delta
+	if s, ok := sb.scores[p][name]; ok {
+		value += sb.decayedUnlocked(name, s, sb.clock.Now())
+	}
+	sb.setUnlocked(p, name, value)
+	return value, nil
+}
+
+func (sb *memoryScoreBook) setUnlocked(p peer.ID, name string, value float64) {
+	scores, ok := sb.scores[p]
+	if !ok {
+		scores = make(map[string]score)
+		sb.scores[p] = scores
+	}
+	scores[name] = score{value: value, updated: sb.clock.Now()}
+}
+
+func (sb *memoryScoreBook) Scores(p peer.ID) map[string]float64 {
+	sb.mu.RLock()
+	defer sb.mu.RUnlock()
+
+	now := sb.clock.Now()
+	out := make(map[string]float64, len(sb.scores[p]))
+	for name, s := range sb.scores[p] {
+		out[name] = sb.decayedUnlocked(name, s, now)
+	}
+	return out
+}
+
+func (sb *memoryScoreBook) RemovePeer(p peer.ID) {
+	sb.mu.Lock()
+	delete(sb.scores, p)
+	sb.mu.Unlock()
+}
@@ -0,0 +1,104 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	pstore "github.com/libp2p/go-libp2p/core/peerstore"
+
+	mockClock "github.com/benbjohnson/clock"
+	"github.com/stretchr/testify/require"
+)
+
+var scoreBookSuite = map[string]func(sb pstore.ScoreBook, clk *mockClock.Mock) func(*testing.T){
+	"SetGet":     testScoreBookSetGet,
+	"AddToScore": testScoreBookAddToScore,
+	"Decay":      testScoreBookDecay,
+	"Delete":     testScoreBookDelete,
+}
+
+// ScoreBookFactory creates a ScoreBook using the mock clock passed to
+// TestScoreBook, in which the score named "decaying" has a half-life of one
+// hour.
+type ScoreBookFactory func() (pstore.ScoreBook, func())
+
+func TestScoreBook(t *testing.T, factory ScoreBookFactory, clk *mockClock.Mock) {
+	for name, test := range scoreBookSuite {
+		// Create a new score book.
+		sb, closeFunc := factory()
+
+		// Run the test.
+		t.Run(name, test(sb, clk))
+
+		// Cleanup.
+		if closeFunc != nil {
+			closeFunc()
+		}
+	}
+}
+
+func testScoreBookSetGet(sb pstore.ScoreBook, _ *mockClock.Mock) func(t *testing.T) {
+	return func(t *testing.T) {
+		p := peer.ID("peer")
+		require.Zero(t, sb.Score(p, "a"))
+		require.Empty(t, sb.Scores(p))
+
+		require.NoError(t, sb.SetScore(p, "a", 1.5))
+		require.NoError(t, sb.SetScore(p, "b/c", -2))
+		require.NoError(t, sb.SetScore("other", "a", 3))
+		require.Equal(t, 1.5, sb.Score(p, "a"))
+		require.Equal(t, -2.0, sb.Score(p, "b/c"))
+		require.Equal(t, map[string]float64{"a": 1.5, "b/c": -2}, sb.Scores(p))
+
+		require.NoError(t, sb.SetScore(p, "a", 4))
+		require.Equal(t, 4.0, sb.Score(p, "a"))
+	}
+}
+
+func testScoreBookAddToScore(sb pstore.ScoreBook, _ *mockClock.Mock) func(t *testing.T) {
+	return func(t *testing.T) {
+		p := peer.ID("peer")
+		v, err := sb.AddToScore(p, "a", 2)
+		require.NoError(t, err)
+		require.Equal(t, 2.0, v)
+		v, err = sb.AddToScore(p, "a", -3)
+		require.NoError(t, err)
+		require.Equal(t, -1.0, v)
+		require.Equal(t, -1.0, sb.Score(p, "a"))
+	}
+}
+
+func testScoreBookDecay(sb pstore.ScoreBook, clk *mockClock.Mock) func(t *testing.T) {
+	return func(t *testing.T) {
+		p := peer.ID("peer")
+		require.NoError(t, sb.SetScore(p, "decaying", 8))
+		require.NoError(t, sb.SetScore(p, "constant", 8))
+
+		clk.Add(time.Hour)
+		require.InDelta(t, 4, sb.Score(p, "decaying"), 1e-9)
+		require.Equal(t, 8.0, sb.Score(p, "constant"))
+
+		// Adding to a score adds to the decayed value.
+		v, err := sb.AddToScore(p, "decaying", 4)
+		require.NoError(t, err)
+		require.InDelta(t, 8, v, 1e-9)
+
+		clk.Add(2 * time.Hour)
+		scores := sb.Scores(p)
+		require.InDelta(t, 2, scores["decaying"], 1e-9)
+		require.Equal(t, 8.0, scores["constant"])
+	}
+}
+
+func testScoreBookDelete(sb pstore.ScoreBook, _ *mockClock.Mock) func(t *testing.T) {
+	return func(t *testing.T) {
+		p := peer.ID("peer")
+		require.NoError(t, sb.SetScore(p, "a", 1))
+		require.NoError(t, sb.SetScore("other", "a", 1))
+		sb.RemovePeer(p)
+		require.Zero(t, sb.Score(p, "a"))
+		require.Empty(t, sb.Scores(p))
+		require.Equal(t, 1.0, sb.Score("other", "a"))
+	}
+}
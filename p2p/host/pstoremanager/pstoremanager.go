@@ -113,6 +113,11 @@ func (m *PeerstoreManager) background(ctx context.Context, sub event.Subscriptio
 				if _, ok := disconnected[p]; !ok {
 					disconnected[p] = time.Now()
 				}
+				// The recent latency samples describe the connections to the
+				// peer, drop them right away.
+				if lt, ok := peerstore.GetLatencyTracker(m.pstore); ok {
+					lt.RemoveLatencySamples(p)
+				}
 			}
 		case <-ticker.C:
 			now := time.Now()
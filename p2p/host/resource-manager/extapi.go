@@ -61,6 +61,24 @@ func (s *peerScope) SetLimit(limit Limit) {
 	s.resourceScope.SetLimit(limit)
 }
 
+// SystemUtilization returns how close the system scope is to its limits: the highest of its
+// connection and memory utilization, where 1 means the limit is reached.
+func (r *resourceManager) SystemUtilization() float64 {
+	limit := r.system.Limit()
+	stat := r.system.Stat()
+	return max(
+		utilization(int64(stat.NumConnsInbound+stat.NumConnsOutbound), int64(limit.GetConnTotalLimit())),
+		utilization(stat.Memory, limit.GetMemoryLimit()),
+	)
+}
+
+func utilization(n, limit int64) float64 {
+	if limit <= 0 {
+		return 0
+	}
+	return float64(n) / float64(limit)
+}
+
 func (r *resourceManager) ListServices() []string {
 	r.mx.Lock()
 	defer r.mx.Unlock()
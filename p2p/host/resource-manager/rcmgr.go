@@ -225,6 +225,16 @@ func (r *resourceManager) GetAllowlist() *Allowlist {
 	return r.allowlist
 }
 
+// AllowlistAdd adds a to the allowlist, see Allowlist.Add.
+func (r *resourceManager) AllowlistAdd(a multiaddr.Multiaddr) error {
+	return r.allowlist.Add(a)
+}
+
+// AllowlistRemove removes a from the allowlist, see Allowlist.Remove.
+func (r *resourceManager) AllowlistRemove(a multiaddr.Multiaddr) error {
+	return r.allowlist.Remove(a)
+}
+
 // GetAllowlist tries to get the allowlist from the given resourcemanager
 // interface by checking to see if its concrete type is a resourceManager.
 // Returns nil if it fails to get the allowlist.
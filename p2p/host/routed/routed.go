@@ -160,6 +160,7 @@ func (rh *RoutedHost) findPeerAddrs(ctx context.Context, id peer.ID) ([]ma.Multi
 		return nil, err
 	}
 
+	peerstore.AddAddrsWithSource(rh.Peerstore(), id, pi.Addrs, peerstore.TempAddrTTL, peerstore.AddrSourceDHT)
 	return pi.Addrs, nil
 }
 
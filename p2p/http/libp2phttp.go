@@ -51,6 +51,7 @@ var DefaultNewStreamTimeout = 10 * time.Second
 
 type clientPeerIDContextKey struct{}
 type serverPeerIDContextKey struct{}
+type streamContextKey struct{}
 
 func ClientPeerID(r *http.Request) peer.ID {
 	if id, ok := r.Context().Value(clientPeerIDContextKey{}).(peer.ID); ok {
@@ -66,6 +67,38 @@ func ServerPeerID(r *http.Response) peer.ID {
 	return ""
 }
 
+// ErrNotStreamTransport is returned by HijackStream if the request wasn't
+// served over a libp2p stream.
+var ErrNotStreamTransport = errors.New("request was not served over a libp2p stream")
+
+// HijackStream lets a handler take over the libp2p stream a request was
+// served on. It is the equivalent of http.Hijacker for the stream transport,
+// and is useful for upgrade-style protocols (e.g. tunnels) that start with an
+// HTTP exchange.
+//
+// After a call to HijackStream, the HTTP server will not do anything else
+// with the stream, and the caller is responsible for closing it. The returned
+// bufio.ReadWriter may contain data already read from the stream by the
+// server; it should be drained before reading from the stream directly.
+//
+// Returns ErrNotStreamTransport if the request was received over a regular
+// HTTP transport.
+func HijackStream(w http.ResponseWriter, r *http.Request) (network.Stream, *bufio.ReadWriter, error) {
+	s, ok := r.Context().Value(streamContextKey{}).(network.Stream)
+	if !ok {
+		return nil, nil, ErrNotStreamTransport
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+	_, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	return s, brw, nil
+}
+
 // ProtocolMeta is metadata about a protocol.
 type ProtocolMeta struct {
 	// Path defines the HTTP Path prefix used for this protocol
@@ -368,6 +401,9 @@ func (h *Host) Serve() error {
 			srv := &http.Server{
 				Handler: connectionCloseHeaderMiddleware(h.ServeMux),
 				ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+					if s, ok := gostream.StreamFromConn(c); ok {
+						ctx = context.WithValue(ctx, streamContextKey{}, s)
+					}
 					remote := c.RemoteAddr()
 					if remote.Network() == gostream.Network {
 						remoteID, err := peer.Decode(remote.String())
@@ -1,6 +1,7 @@
 package libp2phttp_test
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"crypto/ecdsa"
@@ -15,6 +16,7 @@ import (
 	"math/big"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/netip"
 	"net/url"
 	"os"
@@ -115,6 +117,62 @@ func TestHTTPOverStreamsSendsConnectionClose(t *testing.T) {
 	}
 }
 
+func TestHijackStream(t *testing.T) {
+	serverHost, err := libp2p.New(
+		libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { serverHost.Close() })
+
+	httpHost := libp2phttp.Host{StreamHost: serverHost}
+	httpHost.SetHTTPHandler("/echo", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, brw, err := libp2phttp.HijackStream(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer s.Close()
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\n\r\n")
+		brw.Flush()
+		io.Copy(s, brw)
+	}))
+
+	go httpHost.Serve()
+	defer httpHost.Close()
+
+	clientHost, err := libp2p.New(libp2p.NoListenAddrs)
+	require.NoError(t, err)
+	t.Cleanup(func() { clientHost.Close() })
+	require.NoError(t, clientHost.Connect(context.Background(), peer.AddrInfo{
+		ID:    serverHost.ID(),
+		Addrs: serverHost.Addrs(),
+	}))
+
+	s, err := clientHost.NewStream(context.Background(), serverHost.ID(), libp2phttp.ProtocolIDForMultistreamSelect)
+	require.NoError(t, err)
+	defer s.Close()
+
+	req, err := http.NewRequest(http.MethodGet, "/echo/", nil)
+	require.NoError(t, err)
+	require.NoError(t, req.Write(s))
+	br := bufio.NewReader(s)
+	resp, err := http.ReadResponse(br, req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+
+	_, err = s.Write([]byte("ping"))
+	require.NoError(t, err)
+	buf := make([]byte, 4)
+	_, err = io.ReadFull(br, buf)
+	require.NoError(t, err)
+	require.Equal(t, "ping", string(buf))
+}
+
+func TestHijackStreamNotStreamTransport(t *testing.T) {
+	_, _, err := libp2phttp.HijackStream(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	require.ErrorIs(t, err, libp2phttp.ErrNotStreamTransport)
+}
+
 func TestHTTPOverStreamsContextAndClientTimeout(t *testing.T) {
 	const clientTimeout = 200 * time.Millisecond
 
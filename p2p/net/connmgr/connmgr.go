@@ -3,6 +3,8 @@ package connmgr
 import (
 	"context"
 	"fmt"
+	"path"
+	"slices"
 	"sort"
 	"sync"
 	"sync/atomic"
@@ -12,6 +14,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/connmgr"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
 
 	logging "github.com/ipfs/go-log/v2"
 	ma "github.com/multiformats/go-multiaddr"
@@ -36,6 +39,8 @@ type BasicConnMgr struct {
 
 	plk       sync.RWMutex
 	protected map[peer.ID]map[string]struct{}
+	// patterns are the protection patterns, by kind.
+	patterns map[connmgr.ProtectionKind]map[string]struct{}
 
 	// channel-based semaphore that enforces only a single trim is in progress
 	trimMutex sync.Mutex
@@ -54,8 +59,9 @@ type BasicConnMgr struct {
 }
 
 var (
-	_ connmgr.ConnManager = (*BasicConnMgr)(nil)
-	_ connmgr.Decayer     = (*BasicConnMgr)(nil)
+	_ connmgr.ConnManager      = (*BasicConnMgr)(nil)
+	_ connmgr.Decayer          = (*BasicConnMgr)(nil)
+	_ connmgr.PatternProtector = (*BasicConnMgr)(nil)
 )
 
 type segment struct {
@@ -114,6 +120,10 @@ func NewConnManager(low, hi int, opts ...Option) (*BasicConnMgr, error) {
 		gracePeriod:   time.Minute,
 		silencePeriod: 10 * time.Second,
 		clock:         clock.New(),
+
+		emergencyWater: low,
+
+		keepAliveProtocols: []protocol.ID{pingID},
 	}
 	for _, o := range opts {
 		if err := o(cfg); err != nil {
@@ -130,6 +140,7 @@ func NewConnManager(low, hi int, opts ...Option) (*BasicConnMgr, error) {
 		cfg:       cfg,
 		clock:     cfg.clock,
 		protected: make(map[peer.ID]map[string]struct{}, 16),
+		patterns:  make(map[connmgr.ProtectionKind]map[string]struct{}),
 		segments:  segments{},
 	}
 
@@ -146,18 +157,27 @@ func NewConnManager(low, hi int, opts ...Option) (*BasicConnMgr, error) {
 
 	cm.refCount.Add(1)
 	go cm.background()
+	if cfg.idleTimeout > 0 {
+		cm.refCount.Add(1)
+		go cm.idleLoop()
+	}
+	if cfg.memoryWatcher != nil {
+		cm.unregisterMemoryWatcher = cfg.memoryWatcher(cm.ForceTrim)
+	}
 	return cm, nil
 }
 
-// ForceTrim trims connections down to the low watermark ignoring silence period, grace period,
+// ForceTrim trims connections down to the emergency watermark ignoring silence period, grace period,
 // or protected status. It prioritizes closing Unprotected connections. If after closing all
-// unprotected connections, we still have more than lowWaterMark connections, it'll close
+// unprotected connections, we still have more than emergency watermark connections, it'll close
 // protected connections.
+//
+// The emergency watermark defaults to the low watermark, see WithEmergencyWatermark.
 func (cm *BasicConnMgr) ForceTrim() {
 	connCount := int(cm.connCount.Load())
-	target := connCount - cm.cfg.lowWater
-	if target < 0 {
-		log.Warnw("Low on memory, but we only have a few connections", "num", connCount, "low watermark", cm.cfg.lowWater)
+	target := connCount - cm.cfg.emergencyWater
+	if target <= 0 {
+		log.Warnw("Low on memory, but we only have a few connections", "num", connCount, "emergency watermark", cm.cfg.emergencyWater)
 		return
 	} else {
 		log.Warnf("Low on memory. Closing %d connections.", target)
@@ -168,11 +188,15 @@ func (cm *BasicConnMgr) ForceTrim() {
 	defer cm.trimMutex.Unlock()
 
 	// Trim connections without paying attention to the silence period.
-	for _, c := range cm.getConnsToCloseEmergency(target) {
+	conns := cm.getConnsToCloseEmergency(target)
+	for _, c := range conns {
 		log.Infow("low on memory. closing conn", "peer", c.RemotePeer())
 
 		c.CloseWithError(network.ConnGarbageCollected)
 	}
+	if cm.cfg.metricsTracer != nil {
+		cm.cfg.metricsTracer.TrimPerformed(TrimEmergency, len(conns))
+	}
 
 	// finally, update the last trim time.
 	cm.lastTrimMu.Lock()
@@ -236,6 +260,103 @@ func (cm *BasicConnMgr) IsProtected(id peer.ID, tag string) (protected bool) {
 	return protected
 }
 
+// ProtectPattern protects the peers matching the pattern from having their
+// connections pruned, until UnprotectPattern is called.
+func (cm *BasicConnMgr) ProtectPattern(kind connmgr.ProtectionKind, pattern string) error {
+	switch kind {
+	case connmgr.ProtectByProtocol, connmgr.ProtectByService, connmgr.ProtectByTag:
+	default:
+		return fmt.Errorf("unknown protection kind: %d", kind)
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid protection pattern %q: %w", pattern, err)
+	}
+
+	cm.plk.Lock()
+	defer cm.plk.Unlock()
+
+	patterns, ok := cm.patterns[kind]
+	if !ok {
+		patterns = make(map[string]struct{}, 2)
+		cm.patterns[kind] = patterns
+	}
+	patterns[pattern] = struct{}{}
+	return nil
+}
+
+func (cm *BasicConnMgr) UnprotectPattern(kind connmgr.ProtectionKind, pattern string) {
+	cm.plk.Lock()
+	defer cm.plk.Unlock()
+
+	patterns, ok := cm.patterns[kind]
+	if !ok {
+		return
+	}
+	if delete(patterns, pattern); len(patterns) == 0 {
+		delete(cm.patterns, kind)
+	}
+}
+
+// matchesPatternUnlocked reports whether a peer matches a protection pattern.
+// It must be called with plk and the segment lock of the peer held.
+func (cm *BasicConnMgr) matchesPatternUnlocked(inf *peerInfo) bool {
+	if len(cm.patterns) == 0 {
+		return false
+	}
+	if tags := cm.patterns[connmgr.ProtectByTag]; len(tags) > 0 {
+		for t := range inf.tags {
+			if matchesAny(tags, t) {
+				return true
+			}
+		}
+		for t := range inf.decaying {
+			if matchesAny(tags, t.name) {
+				return true
+			}
+		}
+	}
+	protos, services := cm.patterns[connmgr.ProtectByProtocol], cm.patterns[connmgr.ProtectByService]
+	if len(protos) == 0 && len(services) == 0 {
+		return false
+	}
+	for c := range inf.conns {
+		for _, s := range c.GetStreams() {
+			if len(protos) > 0 && matchesAny(protos, string(s.Protocol())) {
+				return true
+			}
+			if len(services) > 0 {
+				if svc := streamService(s); svc != "" && matchesAny(services, svc) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// streamService returns the name of the service owning the stream, if any.
+func streamService(s network.Stream) string {
+	scope, ok := s.Scope().(network.StreamManagementScope)
+	if !ok {
+		return ""
+	}
+	svc := scope.ServiceScope()
+	if svc == nil {
+		return ""
+	}
+	return svc.Name()
+}
+
+func matchesAny(patterns map[string]struct{}, name string) bool {
+	for pattern := range patterns {
+		// patterns are validated in ProtectPattern
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
 func (cm *BasicConnMgr) CheckLimit(systemLimit connmgr.GetConnLimiter) error {
 	if cm.cfg.highWater > systemLimit.GetConnLimit() {
 		return fmt.Errorf(
@@ -257,6 +378,9 @@ type peerInfo struct {
 	temp  bool // this is a temporary entry holding early tags, and awaiting connections
 
 	conns map[network.Conn]time.Time // start time of each connection
+	// activity tracks the streams of each connection. Only tracked when the
+	// idle timeout is enabled.
+	activity map[network.Conn]*connActivity
 
 	firstSeen time.Time // timestamp when we began tracking this peer.
 }
@@ -365,6 +489,107 @@ func (cm *BasicConnMgr) background() {
 	}
 }
 
+func (cm *BasicConnMgr) idleLoop() {
+	defer cm.refCount.Done()
+
+	ticker := cm.clock.Ticker(cm.cfg.idleTimeout / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			conns := cm.getIdleConns()
+			for _, c := range conns {
+				log.Debugw("closing idle conn", "peer", c.RemotePeer())
+				c.CloseWithError(network.ConnGarbageCollected)
+			}
+			if len(conns) > 0 && cm.cfg.metricsTracer != nil {
+				cm.cfg.metricsTracer.TrimPerformed(TrimIdle, len(conns))
+			}
+		case <-cm.ctx.Done():
+			return
+		}
+	}
+}
+
+// connActivity tracks the stream activity of a connection.
+type connActivity struct {
+	// streams are the open streams of the connection.
+	streams map[network.Stream]struct{}
+	// lastActive is the last time a stream that isn't a keepalive was seen
+	// open, or was closed.
+	lastActive time.Time
+}
+
+// activityFor returns the activity of a connection of the peer, or nil if we
+// don't track the connection. It must be called with the segment lock held.
+func (pi *peerInfo) activityFor(c network.Conn) *connActivity {
+	if _, ok := pi.conns[c]; !ok {
+		return nil
+	}
+	a, ok := pi.activity[c]
+	if !ok {
+		if pi.activity == nil {
+			pi.activity = make(map[network.Conn]*connActivity, len(pi.conns))
+		}
+		a = &connActivity{streams: make(map[network.Stream]struct{})}
+		pi.activity[c] = a
+	}
+	return a
+}
+
+// getIdleConns returns the connections that have been idle for longer than
+// the idle timeout. The protocol of a stream is only known once it has been
+// negotiated, so open streams are checked here, rather than when they're
+// opened.
+func (cm *BasicConnMgr) getIdleConns() []network.Conn {
+	now := cm.clock.Now()
+	idleSince := now.Add(-cm.cfg.idleTimeout)
+
+	var idle []network.Conn
+	cm.plk.RLock()
+	for _, s := range cm.segments.buckets {
+		s.Lock()
+		for id, inf := range s.peers {
+			_, protected := cm.protected[id]
+			protected = protected || cm.matchesPatternUnlocked(inf)
+			for c, opened := range inf.conns {
+				lastActive := opened
+				if a, ok := inf.activity[c]; ok {
+					if cm.hasActiveStream(a) {
+						a.lastActive = now
+						continue
+					}
+					if a.lastActive.After(lastActive) {
+						lastActive = a.lastActive
+					}
+				}
+				if !protected && lastActive.Before(idleSince) {
+					idle = append(idle, c)
+				}
+			}
+		}
+		s.Unlock()
+	}
+	cm.plk.RUnlock()
+	return idle
+}
+
+// hasActiveStream returns true if the connection has an open stream that
+// isn't a keepalive.
+func (cm *BasicConnMgr) hasActiveStream(a *connActivity) bool {
+	for str := range a.streams {
+		if !cm.isKeepAlive(str) {
+			return true
+		}
+	}
+	return false
+}
+
+func (cm *BasicConnMgr) isKeepAlive(s network.Stream) bool {
+	return slices.Contains(cm.cfg.keepAliveProtocols, s.Protocol())
+}
+
 func (cm *BasicConnMgr) doTrim() {
 	// This logic is mimicking the implementation of sync.Once in the standard library.
 	count := atomic.LoadUint64(&cm.trimCount)
@@ -382,10 +607,69 @@ func (cm *BasicConnMgr) doTrim() {
 // trim starts the trim, if the last trim happened before the configured silence period.
 func (cm *BasicConnMgr) trim() {
 	// do the actual trim.
-	for _, c := range cm.getConnsToClose() {
+	conns := cm.getConnsToClose()
+	if cm.cfg.trimVeto != nil && len(conns) > 0 {
+		conns = cm.filterVetoed(conns)
+	}
+	for _, c := range conns {
 		log.Debugw("closing conn", "peer", c.RemotePeer())
 		c.CloseWithError(network.ConnGarbageCollected)
 	}
+	if cm.cfg.metricsTracer != nil {
+		cm.cfg.metricsTracer.TrimPerformed(TrimWatermark, len(conns))
+	}
+}
+
+// filterVetoed consults the trim veto, and returns the connections that
+// weren't spared. Connections that couldn't be consulted within the budget
+// aren't spared.
+func (cm *BasicConnMgr) filterVetoed(conns []network.Conn) []network.Conn {
+	type result struct {
+		i      int
+		vetoed bool
+	}
+	results := make(chan result, len(conns))
+	var stop atomic.Bool
+	go func() {
+		for i, c := range conns {
+			if stop.Load() {
+				return
+			}
+			results <- result{i: i, vetoed: cm.cfg.trimVeto(c)}
+		}
+	}()
+
+	timer := cm.clock.Timer(cm.cfg.trimVetoBudget)
+	defer timer.Stop()
+
+	vetoed := make([]bool, len(conns))
+	var nvetoed int
+loop:
+	for range conns {
+		select {
+		case r := <-results:
+			if r.vetoed {
+				vetoed[r.i] = true
+				nvetoed++
+			}
+		case <-timer.C:
+			stop.Store(true)
+			log.Warnw("trim veto exceeded its budget", "budget", cm.cfg.trimVetoBudget)
+			break loop
+		}
+	}
+	if nvetoed == 0 {
+		return conns
+	}
+
+	selected := make([]network.Conn, 0, len(conns)-nvetoed)
+	for i, c := range conns {
+		if !vetoed[i] {
+			selected = append(selected, c)
+		}
+	}
+	log.Debugw("trim vetoed", "spared", nvetoed)
+	return selected
 }
 
 func (cm *BasicConnMgr) getConnsToCloseEmergency(target int) []network.Conn {
@@ -399,6 +683,10 @@ func (cm *BasicConnMgr) getConnsToCloseEmergency(target int) []network.Conn {
 				// skip over protected peer.
 				continue
 			}
+			if cm.matchesPatternUnlocked(inf) {
+				// skip over peers protected by a pattern.
+				continue
+			}
 			candidates = append(candidates, inf)
 		}
 		s.Unlock()
@@ -473,16 +761,30 @@ func (cm *BasicConnMgr) getConnsToClose() []network.Conn {
 	var ncandidates int
 	gracePeriodStart := cm.clock.Now().Add(-cm.cfg.gracePeriod)
 
+	var (
+		nprotected, ngrace int
+		values             PeerValueDistribution
+	)
 	cm.plk.RLock()
 	for _, s := range cm.segments.buckets {
 		s.Lock()
 		for id, inf := range s.peers {
+			if !inf.temp {
+				values.add(inf.value)
+			}
 			if _, ok := cm.protected[id]; ok {
 				// skip over protected peer.
+				nprotected += len(inf.conns)
+				continue
+			}
+			if cm.matchesPatternUnlocked(inf) {
+				// skip over peers protected by a pattern.
+				nprotected += len(inf.conns)
 				continue
 			}
 			if inf.firstSeen.After(gracePeriodStart) {
 				// skip peers in the grace period.
+				ngrace++
 				continue
 			}
 			// note that we're copying the entry here,
@@ -494,6 +796,12 @@ func (cm *BasicConnMgr) getConnsToClose() []network.Conn {
 	}
 	cm.plk.RUnlock()
 
+	if mt := cm.cfg.metricsTracer; mt != nil {
+		mt.ProtectedConns(nprotected)
+		mt.GracePeriodSkipped(ngrace)
+		mt.PeerValues(&values)
+	}
+
 	if ncandidates < cm.cfg.lowWater {
 		log.Info("open connection count above limit but too many are in the grace period")
 		// We have too many connections but fewer than lowWater
@@ -503,11 +811,14 @@ func (cm *BasicConnMgr) getConnsToClose() []network.Conn {
 		return nil
 	}
 
+	target := ncandidates - cm.cfg.lowWater
+	if cm.cfg.trimStrategy != nil {
+		return cm.selectWithStrategy(cm.trimCandidates(candidates), target)
+	}
+
 	// Sort peers according to their value.
 	candidates.SortByValueAndStreams(&cm.segments, false)
 
-	target := ncandidates - cm.cfg.lowWater
-
 	// slightly overallocate because we may have more than one conns per peer
 	selected := make([]network.Conn, 0, target+10)
 
@@ -535,6 +846,70 @@ func (cm *BasicConnMgr) getConnsToClose() []network.Conn {
 	return selected
 }
 
+// trimCandidates converts the peers that may be trimmed to TrimCandidates.
+// Temporary entries holding no connections are pruned.
+func (cm *BasicConnMgr) trimCandidates(infos peerInfos) []TrimCandidate {
+	candidates := make([]TrimCandidate, 0, len(infos))
+	for _, inf := range infos {
+		s := cm.segments.get(inf.id)
+		s.Lock()
+		if len(inf.conns) == 0 {
+			if inf.temp {
+				// handle temporary entries for early tags -- this entry has gone past the grace period
+				// and still holds no connections, so prune it.
+				delete(s.peers, inf.id)
+			}
+			s.Unlock()
+			continue
+		}
+		c := TrimCandidate{
+			Peer:      inf.id,
+			Value:     inf.value,
+			FirstSeen: inf.firstSeen,
+			Tags:      make(map[string]int, len(inf.tags)+len(inf.decaying)),
+			Conns:     make([]network.Conn, 0, len(inf.conns)),
+		}
+		for t, v := range inf.tags {
+			c.Tags[t] = v
+		}
+		for t, v := range inf.decaying {
+			c.Tags[t.name] = v.Value
+		}
+		for conn := range inf.conns {
+			c.Conns = append(c.Conns, conn)
+		}
+		s.Unlock()
+		candidates = append(candidates, c)
+	}
+	return candidates
+}
+
+// selectWithStrategy asks the trim strategy for the connections to close. The
+// connections it returns that aren't connections of a candidate are dropped,
+// and so are duplicates: the strategy must not make us close a protected
+// connection, or one in its grace period.
+func (cm *BasicConnMgr) selectWithStrategy(candidates []TrimCandidate, target int) []network.Conn {
+	allowed := make(map[network.Conn]struct{})
+	for _, c := range candidates {
+		for _, conn := range c.Conns {
+			allowed[conn] = struct{}{}
+		}
+	}
+	selected := cm.cfg.trimStrategy.SelectConnsToClose(candidates, target)
+	filtered := selected[:0]
+	for _, conn := range selected {
+		if _, ok := allowed[conn]; !ok {
+			continue
+		}
+		delete(allowed, conn)
+		filtered = append(filtered, conn)
+	}
+	if len(filtered) < len(selected) {
+		log.Warnw("trim strategy selected unknown or duplicate connections", "selected", len(selected), "valid", len(filtered))
+	}
+	return filtered
+}
+
 // GetTagInfo is called to fetch the tag information associated with a given
 // peer, nil is returned if p refers to an unknown peer.
 func (cm *BasicConnMgr) GetTagInfo(p peer.ID) *connmgr.TagInfo {
@@ -653,6 +1028,8 @@ func (cm *BasicConnMgr) Notifee() network.Notifiee {
 
 type cmNotifee BasicConnMgr
 
+var _ network.StreamNotifiee = (*cmNotifee)(nil)
+
 func (nn *cmNotifee) cm() *BasicConnMgr {
 	return (*BasicConnMgr)(nn)
 }
@@ -679,12 +1056,14 @@ func (nn *cmNotifee) Connected(_ network.Network, c network.Conn) {
 			conns:     make(map[network.Conn]time.Time),
 		}
 		s.peers[id] = pinfo
+		cm.decayer.applyRestored(pinfo)
 	} else if pinfo.temp {
 		// we had created a temporary entry for this peer to buffer early tags before the
 		// Connected notification arrived: flip the temporary flag, and update the firstSeen
 		// timestamp to the real one.
 		pinfo.temp = false
 		pinfo.firstSeen = cm.clock.Now()
+		cm.decayer.applyRestored(pinfo)
 	}
 
 	_, ok = pinfo.conns[c]
@@ -720,12 +1099,58 @@ func (nn *cmNotifee) Disconnected(_ network.Network, c network.Conn) {
 	}
 
 	delete(cinf.conns, c)
+	delete(cinf.activity, c)
 	if len(cinf.conns) == 0 {
 		delete(s.peers, p)
 	}
 	cm.connCount.Add(-1)
 }
 
+// OpenedStream is called by notifiers to inform that a stream has been
+// opened. It's used to track the activity of connections, when the idle
+// timeout is enabled.
+func (nn *cmNotifee) OpenedStream(_ network.Network, str network.Stream) {
+	cm := nn.cm()
+	if cm.cfg.idleTimeout <= 0 {
+		return
+	}
+	c := str.Conn()
+	s := cm.segments.get(c.RemotePeer())
+	s.Lock()
+	defer s.Unlock()
+	pinfo, ok := s.peers[c.RemotePeer()]
+	if !ok {
+		return
+	}
+	if a := pinfo.activityFor(c); a != nil {
+		a.streams[str] = struct{}{}
+	}
+}
+
+// ClosedStream is called by notifiers to inform that a stream has been
+// closed. Closing a stream that isn't a keepalive counts as activity on its
+// connection, so that short-lived streams keep the connection from being idle.
+func (nn *cmNotifee) ClosedStream(_ network.Network, str network.Stream) {
+	cm := nn.cm()
+	if cm.cfg.idleTimeout <= 0 {
+		return
+	}
+	c := str.Conn()
+	s := cm.segments.get(c.RemotePeer())
+	s.Lock()
+	defer s.Unlock()
+	pinfo, ok := s.peers[c.RemotePeer()]
+	if !ok {
+		return
+	}
+	if a := pinfo.activityFor(c); a != nil {
+		delete(a.streams, str)
+		if !cm.isKeepAlive(str) {
+			a.lastActive = cm.clock.Now()
+		}
+	}
+}
+
 // Listen is no-op in this implementation.
 func (nn *cmNotifee) Listen(_ network.Network, _ ma.Multiaddr) {}
 
@@ -2,16 +2,19 @@ package connmgr
 
 import (
 	"context"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/benbjohnson/clock"
+	"github.com/libp2p/go-libp2p/core/connmgr"
 	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/protocol"
 	tu "github.com/libp2p/go-libp2p/core/test"
 
 	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
@@ -24,10 +27,16 @@ type tconn struct {
 	network.Conn
 
 	peer             peer.ID
+	addr             ma.Multiaddr
+	streams          []network.Stream
 	closed           uint32 // to be used atomically. Closed if 1
 	disconnectNotify func(net network.Network, conn network.Conn)
 }
 
+func (c *tconn) GetStreams() []network.Stream {
+	return c.streams
+}
+
 func (c *tconn) Close() error {
 	atomic.StoreUint32(&c.closed, 1)
 	if c.disconnectNotify != nil {
@@ -62,6 +71,9 @@ func (c *tconn) Stat() network.ConnStats {
 }
 
 func (c *tconn) RemoteMultiaddr() ma.Multiaddr {
+	if c.addr != nil {
+		return c.addr
+	}
 	addr, err := ma.NewMultiaddr("/ip4/127.0.0.1/udp/1234")
 	if err != nil {
 		panic("cannot create multiaddr")
@@ -594,6 +606,346 @@ func TestPeerProtectionSingleTag(t *testing.T) {
 	}
 }
 
+type tstream struct {
+	network.Stream
+	conn    network.Conn
+	proto   protocol.ID
+	service string
+}
+
+func (s *tstream) Protocol() protocol.ID { return s.proto }
+
+func (s *tstream) Conn() network.Conn { return s.conn }
+
+func (s *tstream) Scope() network.StreamScope { return &tstreamScope{service: s.service} }
+
+type tstreamScope struct {
+	network.StreamManagementScope
+	service string
+}
+
+func (s *tstreamScope) ServiceScope() network.ServiceScope {
+	if s.service == "" {
+		return nil
+	}
+	return &tserviceScope{name: s.service}
+}
+
+type tserviceScope struct {
+	network.ServiceScope
+	name string
+}
+
+func (s *tserviceScope) Name() string { return s.name }
+
+func TestPeerProtectionPatterns(t *testing.T) {
+	cm, err := NewConnManager(1, 1, WithGracePeriod(0), WithSilencePeriod(time.Hour))
+	require.NoError(t, err)
+	defer cm.Close()
+	not := cm.Notifee()
+
+	addConn := func(streams ...network.Stream) *tconn {
+		rc := randConn(t, not.Disconnected).(*tconn)
+		rc.streams = streams
+		not.Connected(nil, rc)
+		return rc
+	}
+
+	bitswap := addConn(&tstream{proto: "/ipfs/bitswap/1.2.0"})
+	service := addConn(&tstream{proto: "/foo/1.0.0", service: "libp2p.relay/v2"})
+	tagged := addConn()
+	cm.TagPeer(tagged.RemotePeer(), "relay-reservation", 0)
+	other := addConn(&tstream{proto: "/foo/1.0.0"})
+	unprotected := addConn()
+
+	require.Error(t, cm.ProtectPattern(connmgr.ProtectByProtocol, "["))
+	require.Error(t, cm.ProtectPattern(42, "*"))
+	pp, ok := connmgr.SupportsPatternProtection(cm)
+	require.True(t, ok)
+	require.NoError(t, pp.ProtectPattern(connmgr.ProtectByProtocol, "/ipfs/bitswap/*"))
+	require.NoError(t, pp.ProtectPattern(connmgr.ProtectByService, "libp2p.relay/*"))
+	require.NoError(t, pp.ProtectPattern(connmgr.ProtectByTag, "relay-*"))
+
+	countClosed := func(conns ...*tconn) (n int) {
+		for _, c := range conns {
+			if c.isClosed() {
+				n++
+			}
+		}
+		return n
+	}
+
+	// Protected peers don't count, so only one of the two other peers is
+	// trimmed.
+	cm.TrimOpenConns(context.Background())
+	require.False(t, bitswap.isClosed())
+	require.False(t, service.isClosed())
+	require.False(t, tagged.isClosed())
+	require.Equal(t, 1, countClosed(other, unprotected))
+
+	// Patterns are evaluated at trim time.
+	cm.UntagPeer(tagged.RemotePeer(), "relay-reservation")
+	pp.UnprotectPattern(connmgr.ProtectByProtocol, "/ipfs/bitswap/*")
+	cm.TrimOpenConns(context.Background())
+	require.False(t, service.isClosed())
+	require.Equal(t, 3, countClosed(bitswap, tagged, other, unprotected))
+}
+
+func TestTrimStrategy(t *testing.T) {
+	var candidates []TrimCandidate
+	var target int
+	var protected network.Conn
+	// close the peers with the highest value first
+	strategy := TrimStrategyFunc(func(c []TrimCandidate, n int) []network.Conn {
+		candidates, target = c, n
+		sort.Slice(c, func(i, j int) bool { return c[i].Value > c[j].Value })
+		var conns []network.Conn
+		for _, cand := range c[:n] {
+			conns = append(conns, cand.Conns...)
+		}
+		// unknown and duplicate connections are ignored
+		conns = append(conns, protected, conns[0])
+		return conns
+	})
+	cm, err := NewConnManager(2, 3, WithGracePeriod(0), WithTrimStrategy(strategy))
+	require.NoError(t, err)
+	defer cm.Close()
+	not := cm.Notifee()
+
+	var conns []*tconn
+	for i := 0; i < 5; i++ {
+		rc := randConn(t, not.Disconnected).(*tconn)
+		not.Connected(nil, rc)
+		cm.TagPeer(rc.RemotePeer(), "test", i)
+		conns = append(conns, rc)
+	}
+	cm.Protect(conns[4].RemotePeer(), "test")
+	protected = conns[4]
+
+	selected := cm.getConnsToClose()
+	require.Len(t, selected, 2)
+	require.ElementsMatch(t, []network.Conn{conns[2], conns[3]}, selected)
+
+	cm.TrimOpenConns(context.Background())
+	require.Len(t, candidates, 4)
+	require.Equal(t, 2, target)
+	for _, c := range candidates {
+		require.Equal(t, c.Value, c.Tags["test"])
+		require.Len(t, c.Conns, 1)
+	}
+	require.False(t, conns[0].isClosed())
+	require.False(t, conns[1].isClosed())
+	require.True(t, conns[2].isClosed())
+	require.True(t, conns[3].isClosed())
+	require.False(t, conns[4].isClosed())
+}
+
+func TestIdleTimeout(t *testing.T) {
+	mockClock := clock.NewMock()
+	cm, err := NewConnManager(100, 200, WithClock(mockClock), WithIdleTimeout(time.Minute))
+	require.NoError(t, err)
+	defer cm.Close()
+	not := cm.Notifee().(network.StreamNotifiee)
+
+	addConn := func(protos ...protocol.ID) *tconn {
+		rc := randConn(t, cm.Notifee().Disconnected).(*tconn)
+		cm.Notifee().Connected(nil, rc)
+		for _, proto := range protos {
+			not.OpenedStream(nil, &tstream{conn: rc, proto: proto})
+		}
+		return rc
+	}
+	idle := addConn()
+	keepAlive := addConn(pingID)
+	active := addConn("/foo/1.0.0", pingID)
+	protected := addConn()
+	cm.Protect(protected.RemotePeer(), "test")
+	shortLived := addConn()
+
+	// Connections are not idle before the timeout.
+	mockClock.Add(45 * time.Second)
+	time.Sleep(50 * time.Millisecond)
+	require.False(t, idle.isClosed())
+
+	// A stream opened and closed between two checks counts as activity.
+	str := &tstream{conn: shortLived, proto: "/foo/1.0.0"}
+	not.OpenedStream(nil, str)
+	not.ClosedStream(nil, str)
+
+	for i := 0; i < 2; i++ {
+		mockClock.Add(15 * time.Second)
+	}
+	require.Eventually(t, func() bool { return idle.isClosed() && keepAlive.isClosed() }, time.Second, 10*time.Millisecond)
+	require.False(t, active.isClosed())
+	require.False(t, protected.isClosed())
+	require.False(t, shortLived.isClosed())
+
+	cm.Unprotect(protected.RemotePeer(), "test")
+	mockClock.Add(15 * time.Second)
+	require.Eventually(t, protected.isClosed, time.Second, 10*time.Millisecond)
+	require.False(t, active.isClosed())
+
+	mockClock.Add(45 * time.Second)
+	require.Eventually(t, shortLived.isClosed, time.Second, 10*time.Millisecond)
+	require.False(t, active.isClosed())
+}
+
+func TestEmergencyTrim(t *testing.T) {
+	var onPressure func()
+	unregistered := make(chan struct{})
+	watcher := func(f func()) func() {
+		onPressure = f
+		return func() { close(unregistered) }
+	}
+	cm, err := NewConnManager(8, 10, WithGracePeriod(time.Hour), WithEmergencyWatermark(3), WithEmergencyTrim(watcher))
+	require.NoError(t, err)
+	not := cm.Notifee()
+
+	var conns []*tconn
+	for i := 0; i < 10; i++ {
+		rc := randConn(t, not.Disconnected).(*tconn)
+		not.Connected(nil, rc)
+		cm.TagPeer(rc.RemotePeer(), "test", i)
+		conns = append(conns, rc)
+	}
+	cm.Protect(conns[0].RemotePeer(), "test")
+
+	// Emergency trims ignore the grace period, and close the lowest valued
+	// unprotected connections first.
+	require.NotNil(t, onPressure)
+	onPressure()
+	require.Equal(t, 3, cm.GetInfo().ConnCount)
+	require.False(t, conns[0].isClosed())
+	for _, c := range conns[1:8] {
+		require.True(t, c.isClosed())
+	}
+	require.False(t, conns[8].isClosed())
+	require.False(t, conns[9].isClosed())
+
+	require.NoError(t, cm.Close())
+	select {
+	case <-unregistered:
+	default:
+		t.Fatal("memory watcher wasn't unregistered")
+	}
+}
+
+func TestHeapWatcher(t *testing.T) {
+	signaled := make(chan struct{}, 10)
+	unregister := HeapWatcher(1, 10*time.Millisecond)(func() { signaled <- struct{}{} })
+	defer unregister()
+
+	select {
+	case <-signaled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected memory pressure to be signaled")
+	}
+	// We don't signal again while we're above the limit.
+	time.Sleep(50 * time.Millisecond)
+	require.Empty(t, signaled)
+}
+
+func TestTrimVeto(t *testing.T) {
+	var spared peer.ID
+	veto := func(c network.Conn) bool { return c.RemotePeer() == spared }
+	cm, err := NewConnManager(1, 3, WithGracePeriod(0), WithTrimVeto(veto, time.Second))
+	require.NoError(t, err)
+	defer cm.Close()
+	not := cm.Notifee()
+
+	var conns []*tconn
+	for i := 0; i < 4; i++ {
+		rc := randConn(t, not.Disconnected).(*tconn)
+		not.Connected(nil, rc)
+		cm.TagPeer(rc.RemotePeer(), "test", i)
+		conns = append(conns, rc)
+	}
+	spared = conns[0].RemotePeer()
+
+	cm.TrimOpenConns(context.Background())
+	require.False(t, conns[0].isClosed())
+	require.True(t, conns[1].isClosed())
+	require.True(t, conns[2].isClosed())
+	require.False(t, conns[3].isClosed())
+}
+
+func TestTrimVetoBudget(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	veto := func(network.Conn) bool {
+		<-block
+		return true
+	}
+	mockClock := clock.NewMock()
+	cm, err := NewConnManager(1, 3, WithGracePeriod(0), WithClock(mockClock), WithTrimVeto(veto, 50*time.Millisecond))
+	require.NoError(t, err)
+	defer cm.Close()
+	not := cm.Notifee()
+
+	var conns []*tconn
+	for i := 0; i < 4; i++ {
+		rc := randConn(t, not.Disconnected).(*tconn)
+		not.Connected(nil, rc)
+		conns = append(conns, rc)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cm.TrimOpenConns(context.Background())
+	}()
+	// The budget is measured with the connection manager's clock.
+	require.Eventually(t, func() bool {
+		mockClock.Add(50 * time.Millisecond)
+		select {
+		case <-done:
+			return true
+		default:
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+	var closed int
+	for _, c := range conns {
+		if c.isClosed() {
+			closed++
+		}
+	}
+	require.Equal(t, 3, closed)
+}
+
+func TestSubnetDiversity(t *testing.T) {
+	cm, err := NewConnManager(4, 5, WithGracePeriod(0), WithTrimStrategy(SubnetDiversity{}))
+	require.NoError(t, err)
+	defer cm.Close()
+	not := cm.Notifee()
+
+	addConn := func(addr string, value int) *tconn {
+		rc := randConn(t, not.Disconnected).(*tconn)
+		rc.addr = ma.StringCast(addr)
+		not.Connected(nil, rc)
+		cm.TagPeer(rc.RemotePeer(), "test", value)
+		return rc
+	}
+	// Three peers from 1.2.0.0/16, and the lowest valued peers from other
+	// subnets.
+	crowded := []*tconn{
+		addConn("/ip4/1.2.3.4/tcp/1", 30),
+		addConn("/ip4/1.2.5.6/tcp/1", 10),
+		addConn("/ip4/1.2.7.8/udp/1/quic-v1", 20),
+	}
+	v4 := addConn("/ip4/5.6.7.8/tcp/1", 0)
+	v6 := addConn("/ip6/2001:db8::1/tcp/1", 0)
+	v6Other := addConn("/ip6/2001:db9::1/tcp/1", 0)
+
+	cm.TrimOpenConns(context.Background())
+	require.False(t, v4.isClosed())
+	require.False(t, v6.isClosed())
+	require.False(t, v6Other.isClosed())
+	require.False(t, crowded[0].isClosed())
+	require.True(t, crowded[1].isClosed())
+	require.True(t, crowded[2].isClosed())
+}
+
 func TestPeerProtectionMultipleTags(t *testing.T) {
 	cm, err := NewConnManager(19, 20, WithGracePeriod(0), WithSilencePeriod(time.Hour))
 	require.NoError(t, err)
@@ -1,7 +1,11 @@
 package connmgr
 
 import (
+	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -10,6 +14,9 @@ import (
 	"github.com/libp2p/go-libp2p/core/peer"
 
 	"github.com/benbjohnson/clock"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/multiformats/go-base32"
 )
 
 // DefaultResolution is the default resolution of the decay tracker.
@@ -37,6 +44,11 @@ type decayer struct {
 	tagsMu    sync.Mutex
 	knownTags map[string]*decayingTag
 
+	// restored holds the persisted values of peers that weren't connected
+	// when their tag was registered. They're applied when the peer connects.
+	restoredMu sync.Mutex
+	restored   map[peer.ID][]restoredValue
+
 	// lastTick stores the last time the decayer ticked. Guarded by atomic.
 	lastTick atomic.Pointer[time.Time]
 
@@ -57,6 +69,12 @@ var _ connmgr.Decayer = (*decayer)(nil)
 type DecayerCfg struct {
 	Resolution time.Duration
 	Clock      clock.Clock
+
+	// Datastore, if set, persists the values of decaying tags across
+	// restarts. The values are snapshotted to it when the Decayer is closed,
+	// and restored when a tag with the same name is registered again and the
+	// peer connects, after applying the decay for the time elapsed in between.
+	Datastore ds.Datastore
 }
 
 // WithDefaults writes the default values on this DecayerConfig instance,
@@ -82,6 +100,7 @@ func NewDecayer(cfg *DecayerCfg, mgr *BasicConnMgr) (*decayer, error) {
 		mgr:         mgr,
 		clock:       cfg.Clock,
 		knownTags:   make(map[string]*decayingTag),
+		restored:    make(map[peer.ID][]restoredValue),
 		bumpTagCh:   make(chan bumpCmd, 128),
 		removeTagCh: make(chan removeCmd, 128),
 		closeTagCh:  make(chan *decayingTag, 128),
@@ -99,6 +118,20 @@ func NewDecayer(cfg *DecayerCfg, mgr *BasicConnMgr) (*decayer, error) {
 }
 
 func (d *decayer) RegisterDecayingTag(name string, interval time.Duration, decayFn connmgr.DecayFn, bumpFn connmgr.BumpFn) (connmgr.DecayingTag, error) {
+	tag, err := d.registerDecayingTag(name, interval, decayFn, bumpFn)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.cfg.Datastore != nil {
+		if err := d.restore(tag); err != nil {
+			log.Warnw("failed to restore decaying tag values", "tag", name, "error", err)
+		}
+	}
+	return tag, nil
+}
+
+func (d *decayer) registerDecayingTag(name string, interval time.Duration, decayFn connmgr.DecayFn, bumpFn connmgr.BumpFn) (*decayingTag, error) {
 	d.tagsMu.Lock()
 	defer d.tagsMu.Unlock()
 
@@ -141,9 +174,226 @@ func (d *decayer) Close() error {
 
 	close(d.closeCh)
 	<-d.doneCh
+	if d.cfg.Datastore != nil {
+		d.err = d.snapshot()
+	}
 	return d.err
 }
 
+// Decaying tag values are stored under the following db key pattern:
+// /connmgr/decaying/<b32 tag name no padding>/<b32 peer id no padding>
+//
+// The value is the varint encoding of the tag value, followed by the time it
+// was added, last visited, and snapshotted, in unix nanoseconds.
+var decayingBase = ds.NewKey("/connmgr/decaying")
+
+func decayingTagKey(name string) ds.Key {
+	return decayingBase.ChildString(base32.RawStdEncoding.EncodeToString([]byte(name)))
+}
+
+func encodeDecayingValue(v *connmgr.DecayingValue, now time.Time) []byte {
+	b := make([]byte, 0, 4*binary.MaxVarintLen64)
+	b = binary.AppendVarint(b, int64(v.Value))
+	b = binary.AppendVarint(b, v.Added.UnixNano())
+	b = binary.AppendVarint(b, v.LastVisit.UnixNano())
+	return binary.AppendVarint(b, now.UnixNano())
+}
+
+func decodeDecayingValue(b []byte) (v connmgr.DecayingValue, saved time.Time, err error) {
+	var fields [4]int64
+	for i := range fields {
+		n := 0
+		fields[i], n = binary.Varint(b)
+		if n <= 0 {
+			return v, saved, errors.New("invalid decaying tag value")
+		}
+		b = b[n:]
+	}
+	v.Value = int(fields[0])
+	v.Added = time.Unix(0, fields[1])
+	v.LastVisit = time.Unix(0, fields[2])
+	return v, time.Unix(0, fields[3]), nil
+}
+
+// restoredValue is a persisted value waiting for its peer to connect.
+type restoredValue struct {
+	v connmgr.DecayingValue
+	// saved is the time the value was snapshotted.
+	saved time.Time
+}
+
+// decayed returns the value, decayed for every tick of the tag since it was
+// snapshotted. rm is true if the decay function removed it.
+func (r restoredValue) decayed(now time.Time) (v connmgr.DecayingValue, rm bool) {
+	v = r.v
+	tag := v.Tag.(*decayingTag)
+	for ticks := now.Sub(r.saved) / tag.interval; ticks > 0; ticks-- {
+		var after int
+		if after, rm = tag.decayFn(v); rm {
+			return v, true
+		}
+		v.Value = after
+	}
+	return v, false
+}
+
+// snapshot persists the values of the registered decaying tags. It must only
+// be called once the processing loop has stopped.
+func (d *decayer) snapshot() error {
+	ctx := context.Background()
+	store := d.cfg.Datastore
+
+	d.tagsMu.Lock()
+	tags := make(map[*decayingTag]struct{}, len(d.knownTags))
+	for _, tag := range d.knownTags {
+		tags[tag] = struct{}{}
+	}
+	d.tagsMu.Unlock()
+
+	now := d.clock.Now()
+	values := make(map[ds.Key][]byte)
+	add := func(v *connmgr.DecayingValue, saved time.Time) {
+		tag := v.Tag.(*decayingTag)
+		if _, ok := tags[tag]; !ok {
+			return
+		}
+		key := decayingTagKey(tag.name).ChildString(base32.RawStdEncoding.EncodeToString([]byte(v.Peer)))
+		values[key] = encodeDecayingValue(v, saved)
+	}
+	for _, s := range d.mgr.segments.buckets {
+		s.Lock()
+		for _, p := range s.peers {
+			for _, v := range p.decaying {
+				add(v, now)
+			}
+		}
+		s.Unlock()
+	}
+	// Values that weren't applied yet are kept, with the time they were
+	// originally snapshotted.
+	d.restoredMu.Lock()
+	for _, rvs := range d.restored {
+		for _, rv := range rvs {
+			add(&rv.v, rv.saved)
+		}
+	}
+	d.restoredMu.Unlock()
+
+	// Values of tags that weren't registered in this run are kept, so that
+	// they can be restored by a later run.
+	for tag := range tags {
+		if err := deletePrefix(ctx, store, decayingTagKey(tag.name)); err != nil {
+			return err
+		}
+	}
+	for key, b := range values {
+		if err := store.Put(ctx, key, b); err != nil {
+			return err
+		}
+	}
+	return store.Sync(ctx, decayingBase)
+}
+
+func deletePrefix(ctx context.Context, store ds.Datastore, prefix ds.Key) error {
+	results, err := store.Query(ctx, query.Query{Prefix: prefix.String(), KeysOnly: true})
+	if err != nil {
+		return err
+	}
+	entries, err := results.Rest()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := store.Delete(ctx, ds.NewKey(e.Key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restore loads the persisted values of a tag that was just registered. The
+// values of connected peers are applied right away, the others when the peer
+// connects.
+func (d *decayer) restore(tag *decayingTag) error {
+	prefix := decayingTagKey(tag.name)
+	results, err := d.cfg.Datastore.Query(context.Background(), query.Query{Prefix: prefix.String()})
+	if err != nil {
+		return err
+	}
+	entries, err := results.Rest()
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		id, err := base32.RawStdEncoding.DecodeString(strings.TrimPrefix(e.Key, prefix.String()+"/"))
+		if err == nil && len(id) == 0 {
+			err = errors.New("empty peer ID")
+		}
+		if err != nil {
+			log.Warnw("invalid peer ID in decaying tag key", "key", e.Key, "error", err)
+			continue
+		}
+		v, saved, err := decodeDecayingValue(e.Value)
+		if err != nil {
+			log.Warnw("invalid decaying tag value", "key", e.Key, "error", err)
+			continue
+		}
+		v.Tag, v.Peer = tag, peer.ID(id)
+		rv := restoredValue{v: v, saved: saved}
+
+		s := d.mgr.segments.get(v.Peer)
+		s.Lock()
+		if p, ok := s.peers[v.Peer]; ok && !p.temp {
+			d.applyRestoredValue(p, rv, d.clock.Now())
+		} else {
+			d.restoredMu.Lock()
+			d.restored[v.Peer] = append(d.restored[v.Peer], rv)
+			d.restoredMu.Unlock()
+		}
+		s.Unlock()
+	}
+	return nil
+}
+
+// applyRestored applies the persisted values of a peer that just connected.
+// It must be called with the lock of the peer's segment held.
+func (d *decayer) applyRestored(p *peerInfo) {
+	if d.cfg.Datastore == nil {
+		return
+	}
+	d.restoredMu.Lock()
+	rvs, ok := d.restored[p.id]
+	delete(d.restored, p.id)
+	d.restoredMu.Unlock()
+	if !ok {
+		return
+	}
+	now := d.clock.Now()
+	for _, rv := range rvs {
+		d.applyRestoredValue(p, rv, now)
+	}
+}
+
+// applyRestoredValue adds a persisted value to a peer, unless the tag was
+// closed or the peer was bumped in the meantime. It must be called with the
+// lock of the peer's segment held.
+func (d *decayer) applyRestoredValue(p *peerInfo, rv restoredValue, now time.Time) {
+	tag := rv.v.Tag.(*decayingTag)
+	if tag.closed.Load() {
+		return
+	}
+	if _, ok := p.decaying[tag]; ok {
+		return
+	}
+	v, rm := rv.decayed(now)
+	if rm {
+		return
+	}
+	p.decaying[tag] = &v
+	p.value += v.Value
+}
+
 // process is the heart of the tracker. It performs the following duties:
 //
 //  1. Manages decay.
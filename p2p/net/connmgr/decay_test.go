@@ -10,6 +10,8 @@ import (
 	tu "github.com/libp2p/go-libp2p/core/test"
 
 	"github.com/benbjohnson/clock"
+	ds "github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
 	"github.com/stretchr/testify/require"
 )
 
@@ -336,3 +338,78 @@ func testDecayTracker(tb testing.TB) (*BasicConnMgr, connmgr.Decayer, *clock.Moc
 
 	return mgr, decay, mockClock
 }
+
+func TestDecayPersistence(t *testing.T) {
+	store := dssync.MutexWrap(ds.NewMapDatastore())
+	mockClock := clock.NewMock()
+	newMgr := func() *BasicConnMgr {
+		cfg := &DecayerCfg{
+			Resolution: TestResolution,
+			Clock:      mockClock,
+			Datastore:  store,
+		}
+		mgr, err := NewConnManager(10, 10, WithGracePeriod(time.Second), DecayerConfig(cfg))
+		require.NoError(t, err)
+		return mgr
+	}
+
+	id1 := tu.RandPeerIDFatal(t)
+	id2 := tu.RandPeerIDFatal(t)
+
+	mgr := newMgr()
+	fixed, err := mgr.RegisterDecayingTag("fixed", 250*time.Millisecond, connmgr.DecayFixed(1), connmgr.BumpSumUnbounded())
+	require.NoError(t, err)
+	other, err := mgr.RegisterDecayingTag("other", 250*time.Millisecond, connmgr.DecayNone(), connmgr.BumpSumUnbounded())
+	require.NoError(t, err)
+	require.NoError(t, fixed.Bump(id1, 10))
+	require.NoError(t, fixed.Bump(id2, 2))
+	require.NoError(t, other.Bump(id1, 5))
+	eventuallyEqual(t, func() int {
+		if ti := mgr.GetTagInfo(id2); ti != nil {
+			return ti.Value
+		}
+		return 0
+	}, 2)
+	eventuallyEqual(t, func() int { return mgr.GetTagInfo(id1).Value }, 15)
+	require.NoError(t, mgr.Close())
+
+	// We're down for 4 ticks of the tag.
+	mockClock.Add(time.Second)
+
+	mgr = newMgr()
+	_, err = mgr.RegisterDecayingTag("fixed", 250*time.Millisecond, connmgr.DecayFixed(1), connmgr.BumpSumUnbounded())
+	require.NoError(t, err)
+	// The values are applied once the peers connect.
+	require.Nil(t, mgr.GetTagInfo(id1))
+	mgr.Notifee().Connected(nil, &tconn{peer: id1})
+	mgr.Notifee().Connected(nil, &tconn{peer: id2})
+	ti := mgr.GetTagInfo(id1)
+	require.NotNil(t, ti)
+	require.Equal(t, 6, ti.Tags["fixed"])
+	require.Equal(t, 6, ti.Value)
+	// The value of id2 decayed to zero, and was removed.
+	_, ok := mgr.GetTagInfo(id2).Tags["fixed"]
+	require.False(t, ok)
+	require.NoError(t, mgr.Close())
+
+	// Values that weren't applied are kept.
+	mockClock.Add(250 * time.Millisecond)
+	mgr = newMgr()
+	_, err = mgr.RegisterDecayingTag("fixed", 250*time.Millisecond, connmgr.DecayFixed(1), connmgr.BumpSumUnbounded())
+	require.NoError(t, err)
+	require.NoError(t, mgr.Close())
+	mgr = newMgr()
+	_, err = mgr.RegisterDecayingTag("fixed", 250*time.Millisecond, connmgr.DecayFixed(1), connmgr.BumpSumUnbounded())
+	require.NoError(t, err)
+	mgr.Notifee().Connected(nil, &tconn{peer: id1})
+	require.Equal(t, 5, mgr.GetTagInfo(id1).Tags["fixed"])
+	require.NoError(t, mgr.Close())
+
+	// The values of tags that weren't registered are kept.
+	mgr = newMgr()
+	defer mgr.Close()
+	_, err = mgr.RegisterDecayingTag("other", 250*time.Millisecond, connmgr.DecayNone(), connmgr.BumpSumUnbounded())
+	require.NoError(t, err)
+	mgr.Notifee().Connected(nil, &tconn{peer: id1})
+	require.Equal(t, 5, mgr.GetTagInfo(id1).Tags["other"])
+}
@@ -0,0 +1,53 @@
+package connmgr
+
+import (
+	"runtime/metrics"
+	"time"
+)
+
+// MemoryWatcher registers a function to be called when the process is under
+// memory pressure, and returns a function to unregister it.
+type MemoryWatcher func(onPressure func()) (unregister func())
+
+const heapObjectsMetric = "/memory/classes/heap/objects:bytes"
+
+// HeapWatcher returns a MemoryWatcher that checks the size of the heap every
+// interval, and signals memory pressure when it grows above limit bytes. It
+// signals again only after the heap has shrunk below the limit.
+func HeapWatcher(limit uint64, interval time.Duration) MemoryWatcher {
+	return func(onPressure func()) func() {
+		done := make(chan struct{})
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			sample := []metrics.Sample{{Name: heapObjectsMetric}}
+			var signaled bool
+			for {
+				select {
+				case <-ticker.C:
+				case <-done:
+					return
+				}
+				metrics.Read(sample)
+				if sample[0].Value.Kind() != metrics.KindUint64 {
+					log.Errorw("unsupported runtime metric", "metric", heapObjectsMetric)
+					return
+				}
+				above := sample[0].Value.Uint64() > limit
+				if above && !signaled {
+					log.Warnw("heap size exceeds limit", "limit", limit, "size", sample[0].Value.Uint64())
+					onPressure()
+				}
+				signaled = above
+			}
+		}()
+		return func() {
+			close(done)
+			<-stopped
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package connmgr
+
+import (
+	"github.com/libp2p/go-libp2p/p2p/metricshelper"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricNamespace = "libp2p_connmgr"
+
+var (
+	trimsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "trims_total",
+			Help:      "Trims performed",
+		},
+		[]string{"type"},
+	)
+	trimClosedConns = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Name:      "trim_closed_conns",
+			Help:      "Connections closed per trim",
+			Buckets:   []float64{0, 1, 2, 5, 10, 20, 50, 100, 200, 500},
+		},
+		[]string{"type"},
+	)
+	gracePeriodSkipsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "grace_period_skips_total",
+			Help:      "Peers not trimmed because they were in their grace period",
+		},
+	)
+	protectedConns = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Name:      "protected_conns",
+			Help:      "Connections to protected peers, at the last trim",
+		},
+	)
+	peerValues = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Name:      "peer_values",
+			Help:      "Number of peers by the sum of their tag values, at the last trim",
+		},
+		[]string{"value"},
+	)
+	collectors = []prometheus.Collector{
+		trimsTotal,
+		trimClosedConns,
+		gracePeriodSkipsTotal,
+		protectedConns,
+		peerValues,
+	}
+)
+
+// TrimType is the reason a trim was run.
+type TrimType int
+
+const (
+	// TrimWatermark is a trim run because we're above the high watermark.
+	TrimWatermark TrimType = iota
+	// TrimEmergency is a trim run because we're low on memory.
+	TrimEmergency
+	// TrimIdle closes the connections that stayed idle.
+	TrimIdle
+)
+
+func (t TrimType) String() string {
+	switch t {
+	case TrimWatermark:
+		return "watermark"
+	case TrimEmergency:
+		return "emergency"
+	case TrimIdle:
+		return "idle"
+	default:
+		return "unknown"
+	}
+}
+
+// valueBuckets are the upper bounds of the buckets of PeerValueDistribution.
+var valueBuckets = [...]int{0, 10, 100, 1000}
+
+var valueBucketLabels = [len(valueBuckets) + 1]string{"<=0", "1-10", "11-100", "101-1000", ">1000"}
+
+// PeerValueDistribution counts the peers by the sum of their tag values. The
+// buckets are the values <= 0, 1-10, 11-100, 101-1000 and > 1000.
+type PeerValueDistribution [len(valueBuckets) + 1]int
+
+func (d *PeerValueDistribution) add(value int) {
+	for i, b := range valueBuckets {
+		if value <= b {
+			d[i]++
+			return
+		}
+	}
+	d[len(valueBuckets)]++
+}
+
+// MetricsTracer is the interface for tracking metrics for the connection manager.
+type MetricsTracer interface {
+	// TrimPerformed is called after a trim, with the number of connections closed.
+	TrimPerformed(t TrimType, closed int)
+	// GracePeriodSkipped is called with the number of peers a trim didn't
+	// consider because they were in their grace period.
+	GracePeriodSkipped(peers int)
+	// ProtectedConns is called with the number of connections to protected peers.
+	ProtectedConns(n int)
+	// PeerValues is called with the distribution of the values of the peers.
+	PeerValues(d *PeerValueDistribution)
+}
+
+type metricsTracer struct{}
+
+var _ MetricsTracer = &metricsTracer{}
+
+type metricsTracerSetting struct {
+	reg prometheus.Registerer
+}
+
+type MetricsTracerOption func(*metricsTracerSetting)
+
+func WithRegisterer(reg prometheus.Registerer) MetricsTracerOption {
+	return func(s *metricsTracerSetting) {
+		if reg != nil {
+			s.reg = reg
+		}
+	}
+}
+
+func NewMetricsTracer(opts ...MetricsTracerOption) MetricsTracer {
+	setting := &metricsTracerSetting{reg: prometheus.DefaultRegisterer}
+	for _, opt := range opts {
+		opt(setting)
+	}
+	metricshelper.RegisterCollectors(setting.reg, collectors...)
+	return &metricsTracer{}
+}
+
+func (mt *metricsTracer) TrimPerformed(t TrimType, closed int) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	*tags = append(*tags, t.String())
+	trimsTotal.WithLabelValues(*tags...).Inc()
+	trimClosedConns.WithLabelValues(*tags...).Observe(float64(closed))
+}
+
+func (mt *metricsTracer) GracePeriodSkipped(peers int) {
+	gracePeriodSkipsTotal.Add(float64(peers))
+}
+
+func (mt *metricsTracer) ProtectedConns(n int) {
+	protectedConns.Set(float64(n))
+}
+
+func (mt *metricsTracer) PeerValues(d *PeerValueDistribution) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	for i, n := range d {
+		*tags = append((*tags)[:0], valueBucketLabels[i])
+		peerValues.WithLabelValues(*tags...).Set(float64(n))
+	}
+}
@@ -0,0 +1,29 @@
+//go:build nocover
+
+package connmgr
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestMetricsNoAllocNoCover(t *testing.T) {
+	var values PeerValueDistribution
+	for i := 0; i < 100; i++ {
+		values.add(rand.Intn(2000) - 100)
+	}
+	tr := NewMetricsTracer()
+	tests := map[string]func(){
+		"TrimPerformed":      func() { tr.TrimPerformed(TrimType(rand.Intn(3)), rand.Intn(100)) },
+		"GracePeriodSkipped": func() { tr.GracePeriodSkipped(rand.Intn(10)) },
+		"ProtectedConns":     func() { tr.ProtectedConns(rand.Intn(100)) },
+		"PeerValues":         func() { tr.PeerValues(&values) },
+	}
+	for method, f := range tests {
+		allocs := testing.AllocsPerRun(1000, f)
+
+		if allocs > 0 {
+			t.Fatalf("Alloc Test: %s, got: %0.2f, expected: 0 allocs", method, allocs)
+		}
+	}
+}
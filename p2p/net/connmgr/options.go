@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/benbjohnson/clock"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/protocol"
 )
 
 // config is the configuration struct for the basic connection manager.
@@ -15,6 +17,18 @@ type config struct {
 	silencePeriod time.Duration
 	decayer       *DecayerCfg
 	clock         clock.Clock
+	trimStrategy  TrimStrategy
+
+	idleTimeout        time.Duration
+	keepAliveProtocols []protocol.ID
+
+	metricsTracer MetricsTracer
+
+	emergencyWater int
+	memoryWatcher  MemoryWatcher
+
+	trimVeto       func(network.Conn) bool
+	trimVetoBudget time.Duration
 }
 
 // Option represents an option for the basic connection manager.
@@ -61,3 +75,99 @@ func WithSilencePeriod(p time.Duration) Option {
 		return nil
 	}
 }
+
+// WithTrimStrategy sets the strategy used to select the connections to close
+// when trimming. By default, the peers with the lowest value are trimmed
+// first.
+func WithTrimStrategy(s TrimStrategy) Option {
+	return func(cfg *config) error {
+		if s == nil {
+			return errors.New("trim strategy must not be nil")
+		}
+		cfg.trimStrategy = s
+		return nil
+	}
+}
+
+// WithIdleTimeout closes the connections that had no streams for the given
+// duration, regardless of the watermarks. Streams of keepalive protocols (see
+// WithKeepAliveProtocols) don't count, and connections to protected peers are
+// never closed.
+//
+// The streams are tracked using the stream notifications of the network (see
+// network.StreamNotifiee), so a connection only used by short-lived streams
+// isn't considered idle.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(cfg *config) error {
+		if d <= 0 {
+			return errors.New("idle timeout must be positive")
+		}
+		cfg.idleTimeout = d
+		return nil
+	}
+}
+
+// pingID is the ID of the ping protocol. The ping package isn't imported, so
+// that the connection manager doesn't depend on the host.
+const pingID protocol.ID = "/ipfs/ping/1.0.0"
+
+// WithKeepAliveProtocols sets the protocols whose streams don't keep a
+// connection from being idle. It defaults to the ping protocol.
+func WithKeepAliveProtocols(protos ...protocol.ID) Option {
+	return func(cfg *config) error {
+		cfg.keepAliveProtocols = protos
+		return nil
+	}
+}
+
+// WithMetricsTracer configures the connection manager to use mt to track
+// metrics.
+func WithMetricsTracer(mt MetricsTracer) Option {
+	return func(cfg *config) error {
+		cfg.metricsTracer = mt
+		return nil
+	}
+}
+
+// WithEmergencyWatermark sets the number of connections emergency trims
+// (see ForceTrim) get down to. It defaults to the low watermark.
+func WithEmergencyWatermark(n int) Option {
+	return func(cfg *config) error {
+		if n < 0 {
+			return errors.New("emergency watermark must be non-negative")
+		}
+		cfg.emergencyWater = n
+		return nil
+	}
+}
+
+// WithEmergencyTrim makes the connection manager perform an emergency trim
+// (see ForceTrim) whenever the watcher signals memory pressure. The watcher
+// can be backed by a runtime memory watchdog, like HeapWatcher, or by the
+// resource manager.
+func WithEmergencyTrim(watcher MemoryWatcher) Option {
+	return func(cfg *config) error {
+		cfg.memoryWatcher = watcher
+		return nil
+	}
+}
+
+// WithTrimVeto sets a function consulted before closing a connection during a
+// trim. If it returns true, the connection is spared, and the trim may end
+// above the low watermark. Emergency trims (see ForceTrim) can't be vetoed.
+//
+// All calls of a trim must complete within budget. The connections that
+// weren't consulted by then are closed.
+func WithTrimVeto(veto func(network.Conn) bool, budget time.Duration) Option {
+	return func(cfg *config) error {
+		if veto == nil {
+			return errors.New("trim veto must not be nil")
+		}
+		if budget <= 0 {
+			return errors.New("trim veto budget must be positive")
+		}
+		cfg.trimVeto = veto
+		cfg.trimVetoBudget = budget
+		return nil
+	}
+}
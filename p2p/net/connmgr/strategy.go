@@ -0,0 +1,129 @@
+package connmgr
+
+import (
+	"cmp"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// TrimCandidate is a peer whose connections may be closed when trimming.
+type TrimCandidate struct {
+	Peer peer.ID
+
+	// Value is the sum of the values of the tags of the peer.
+	Value int
+
+	// Tags maps the tags of the peer, including decaying tags, to their
+	// values.
+	Tags map[string]int
+
+	// Conns are the open connections to the peer.
+	Conns []network.Conn
+
+	// FirstSeen is when we first connected to the peer.
+	FirstSeen time.Time
+}
+
+// TrimStrategy selects the connections to close when the connection manager
+// trims connections, for example to keep peers from diverse networks.
+//
+// The strategy is not used by ForceTrim, which is called when we're low on
+// memory.
+type TrimStrategy interface {
+	// SelectConnsToClose returns the connections to close. The candidates
+	// are the peers that are neither protected nor in their grace period.
+	// target is the number of connections that should be closed to get back
+	// to the low watermark.
+	SelectConnsToClose(candidates []TrimCandidate, target int) []network.Conn
+}
+
+// TrimStrategyFunc is an adapter to use a function as a TrimStrategy.
+type TrimStrategyFunc func(candidates []TrimCandidate, target int) []network.Conn
+
+func (f TrimStrategyFunc) SelectConnsToClose(candidates []TrimCandidate, target int) []network.Conn {
+	return f(candidates, target)
+}
+
+// SubnetDiversity is a TrimStrategy keeping peers from diverse IP subnets, to
+// resist eclipse attacks narrowing our view of the network. It closes the
+// connections of the peers from the most represented subnet first, starting
+// with the lowest valued peer of the subnet. A peer's subnet is the subnet of
+// the remote address of its first connection.
+type SubnetDiversity struct {
+	// IPv4Prefix is the prefix length of IPv4 subnets. It defaults to 16.
+	IPv4Prefix int
+	// IPv6Prefix is the prefix length of IPv6 subnets. It defaults to 32.
+	IPv6Prefix int
+}
+
+var _ TrimStrategy = SubnetDiversity{}
+
+func (s SubnetDiversity) subnet(c network.Conn) string {
+	ip, err := manet.ToIP(c.RemoteMultiaddr())
+	if err != nil {
+		return ""
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		prefix := s.IPv4Prefix
+		if prefix <= 0 || prefix > 32 {
+			prefix = 16
+		}
+		return ip4.Mask(net.CIDRMask(prefix, 32)).String()
+	}
+	prefix := s.IPv6Prefix
+	if prefix <= 0 || prefix > 128 {
+		prefix = 32
+	}
+	return ip.Mask(net.CIDRMask(prefix, 128)).String()
+}
+
+func (s SubnetDiversity) SelectConnsToClose(candidates []TrimCandidate, target int) []network.Conn {
+	subnets := make(map[string][]*TrimCandidate)
+	for i := range candidates {
+		c := &candidates[i]
+		if len(c.Conns) == 0 {
+			continue
+		}
+		key := s.subnet(c.Conns[0])
+		subnets[key] = append(subnets[key], c)
+	}
+	keys := make([]string, 0, len(subnets))
+	for key, peers := range subnets {
+		slices.SortStableFunc(peers, func(a, b *TrimCandidate) int { return cmp.Compare(a.Value, b.Value) })
+		keys = append(keys, key)
+	}
+	// sort the keys, for the choice between subnets to be deterministic
+	slices.Sort(keys)
+
+	selected := make([]network.Conn, 0, target+10)
+	for target > 0 {
+		// pick the most represented subnet, preferring the one with the
+		// lowest valued peer.
+		var (
+			pick string
+			best []*TrimCandidate
+		)
+		for _, key := range keys {
+			peers := subnets[key]
+			if len(peers) == 0 {
+				continue
+			}
+			if len(peers) > len(best) || (len(peers) == len(best) && peers[0].Value < best[0].Value) {
+				pick, best = key, peers
+			}
+		}
+		if len(best) == 0 {
+			break
+		}
+		selected = append(selected, best[0].Conns...)
+		target -= len(best[0].Conns)
+		subnets[pick] = best[1:]
+	}
+	return selected
+}
@@ -31,6 +31,15 @@ func newConn(s network.Stream, ignoreEOF bool) net.Conn {
 	return &conn{s, ignoreEOF}
 }
 
+// StreamFromConn returns the libp2p stream backing a net.Conn returned by this
+// package. It returns false if c wasn't created by gostream.
+func StreamFromConn(c net.Conn) (network.Stream, bool) {
+	if gc, ok := c.(*conn); ok {
+		return gc.Stream, true
+	}
+	return nil, false
+}
+
 // LocalAddr returns the local network address.
 func (c *conn) LocalAddr() net.Addr {
 	return &addr{c.Stream.Conn().LocalPeer()}
@@ -96,20 +96,30 @@ func (c *conn) teardown() {
 
 func (c *conn) addStream(s *stream) {
 	c.Lock()
-	defer c.Unlock()
 	s.conn = c
 	c.streams.PushBack(s)
+	c.Unlock()
+	c.net.notifyStreams(func(n network.StreamNotifiee) {
+		n.OpenedStream(c.net, s)
+	})
 }
 
 func (c *conn) removeStream(s *stream) {
 	c.Lock()
-	defer c.Unlock()
+	var removed bool
 	for e := c.streams.Front(); e != nil; e = e.Next() {
 		if s == e.Value {
 			c.streams.Remove(e)
-			return
+			removed = true
+			break
 		}
 	}
+	c.Unlock()
+	if removed {
+		c.net.notifyStreams(func(n network.StreamNotifiee) {
+			n.ClosedStream(c.net, s)
+		})
+	}
 }
 
 func (c *conn) allStreams() []network.Stream {
@@ -38,6 +38,11 @@ type peernet struct {
 	notifmu sync.Mutex
 	notifs  map[network.Notifiee]struct{}
 
+	// streamNotifs are the Notifiees that implement StreamNotifiee. They have
+	// their own lock, as streams may be opened from within notifications.
+	streamNotifmu sync.RWMutex
+	streamNotifs  map[network.StreamNotifiee]struct{}
+
 	sync.RWMutex
 }
 
@@ -58,7 +63,8 @@ func newPeernet(m *mocknet, p peer.ID, opts PeerOptions, bus event.Bus) (*peerne
 		connsByPeer: map[peer.ID]map[*conn]struct{}{},
 		connsByLink: map[*link]map[*conn]struct{}{},
 
-		notifs: make(map[network.Notifiee]struct{}),
+		notifs:       make(map[network.Notifiee]struct{}),
+		streamNotifs: make(map[network.StreamNotifiee]struct{}),
 	}
 
 	return n, nil
@@ -412,6 +418,12 @@ func (pn *peernet) Notify(f network.Notifiee) {
 	pn.notifmu.Lock()
 	pn.notifs[f] = struct{}{}
 	pn.notifmu.Unlock()
+
+	if sf, ok := f.(network.StreamNotifiee); ok {
+		pn.streamNotifmu.Lock()
+		pn.streamNotifs[sf] = struct{}{}
+		pn.streamNotifmu.Unlock()
+	}
 }
 
 // StopNotify unregisters Notifiee from receiving signals
@@ -419,6 +431,22 @@ func (pn *peernet) StopNotify(f network.Notifiee) {
 	pn.notifmu.Lock()
 	delete(pn.notifs, f)
 	pn.notifmu.Unlock()
+
+	if sf, ok := f.(network.StreamNotifiee); ok {
+		pn.streamNotifmu.Lock()
+		delete(pn.streamNotifs, sf)
+		pn.streamNotifmu.Unlock()
+	}
+}
+
+// notifyStreams runs the notification function on the Notifiees that want to
+// be notified about streams.
+func (pn *peernet) notifyStreams(notification func(f network.StreamNotifiee)) {
+	pn.streamNotifmu.RLock()
+	for n := range pn.streamNotifs {
+		notification(n)
+	}
+	pn.streamNotifmu.RUnlock()
 }
 
 // notifyAll runs the notification function on all Notifiees
@@ -2,6 +2,8 @@ package reuseport
 
 import (
 	"context"
+	"net"
+	"syscall"
 
 	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr/net"
@@ -20,6 +22,14 @@ func (t *Transport) Dial(raddr ma.Multiaddr) (manet.Conn, error) {
 
 // DialContext is like Dial but takes a context.
 func (t *Transport) DialContext(ctx context.Context, raddr ma.Multiaddr) (manet.Conn, error) {
+	return t.DialContextFrom(ctx, raddr, nil, nil)
+}
+
+// DialContextFrom is like DialContext, but dials from the local IP address
+// src, and applies control to the socket before it is connected. It reuses
+// the port of a listener on src or on the unspecified address, if possible.
+// If src is nil, the source address is chosen as in DialContext.
+func (t *Transport) DialContextFrom(ctx context.Context, raddr ma.Multiaddr, src net.IP, control func(network, address string, c syscall.RawConn) error) (manet.Conn, error) {
 	network, addr, err := manet.DialArgs(raddr)
 	if err != nil {
 		return nil, err
@@ -33,7 +43,7 @@ func (t *Transport) DialContext(ctx context.Context, raddr ma.Multiaddr) (manet.
 	default:
 		return nil, ErrWrongProto
 	}
-	conn, err := d.DialContext(ctx, network, addr)
+	conn, err := d.DialContextFrom(ctx, network, addr, src, control)
 	if err != nil {
 		return nil, err
 	}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math/rand"
 	"net"
+	"syscall"
 
 	"github.com/libp2p/go-netroute"
 )
@@ -39,6 +40,10 @@ func randAddr(addrs []*net.TCPAddr) *net.TCPAddr {
 //     port from one of these listener's.
 //  3. Otherwise, we'll let the system pick the source port.
 func (d *dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return d.dialContext(ctx, network, addr, &fallbackDialer)
+}
+
+func (d *dialer) dialContext(ctx context.Context, network, addr string, fallback *net.Dialer) (net.Conn, error) {
 	// We only check this case if the user is listening on a specific address (loopback or
 	// otherwise). Generally, users will listen on the "unspecified" address (0.0.0.0 or ::) and
 	// we can skip this section.
@@ -66,7 +71,7 @@ func (d *dialer) DialContext(ctx context.Context, network, addr string) (net.Con
 				if _, _, preferredSrc, err := router.Route(ip); err == nil {
 					for _, optAddr := range d.specific {
 						if optAddr.IP.Equal(preferredSrc) {
-							return reuseDial(ctx, optAddr, network, addr)
+							return reuseDial(ctx, optAddr, network, addr, fallback)
 						}
 					}
 				}
@@ -76,19 +81,51 @@ func (d *dialer) DialContext(ctx context.Context, network, addr string) (net.Con
 		// Otherwise, if we are listening on a loopback address and the destination is also
 		// a loopback address, use the port from our loopback listener.
 		if len(d.loopback) > 0 && ip.IsLoopback() {
-			return reuseDial(ctx, randAddr(d.loopback), network, addr)
+			return reuseDial(ctx, randAddr(d.loopback), network, addr, fallback)
 		}
 	}
 
 	// If we're listening on any uspecified addresses, use a randomly chosen port from one of
 	// these listeners.
 	if len(d.unspecified) > 0 {
-		return reuseDial(ctx, randAddr(d.unspecified), network, addr)
+		return reuseDial(ctx, randAddr(d.unspecified), network, addr, fallback)
 	}
 
 	// Finally, just pick a random port.
-	var dialer net.Dialer
-	return dialer.DialContext(ctx, network, addr)
+	return fallback.DialContext(ctx, network, addr)
+}
+
+// DialContextFrom dials a target addr from the local IP address src, reusing
+// the port of a listener if possible. control is applied to the socket before
+// it is connected. If src is nil, it behaves like DialContext.
+//
+// In-order:
+//
+//  1. If we're listening on src, we'll use that listener's port as the source port.
+//  2. If we're listening on one or more _unspecified_ addresses, we'll pick a source port
+//     from one of these listener's.
+//  3. Otherwise, we'll let the system pick the source port.
+func (d *dialer) DialContextFrom(ctx context.Context, network, addr string, src net.IP, control func(network, address string, c syscall.RawConn) error) (net.Conn, error) {
+	fallback := &net.Dialer{Control: control}
+	if src == nil {
+		if control == nil {
+			return d.DialContext(ctx, network, addr)
+		}
+		return d.dialContext(ctx, network, addr, fallback)
+	}
+	fallback.LocalAddr = &net.TCPAddr{IP: src}
+
+	for _, addrs := range [][]*net.TCPAddr{d.specific, d.loopback} {
+		for _, laddr := range addrs {
+			if laddr.IP.Equal(src) {
+				return reuseDial(ctx, laddr, network, addr, fallback)
+			}
+		}
+	}
+	if laddr := randAddr(d.unspecified); laddr != nil {
+		return reuseDial(ctx, &net.TCPAddr{IP: src, Port: laddr.Port}, network, addr, fallback)
+	}
+	return fallback.DialContext(ctx, network, addr)
 }
 
 func newDialer(listeners map[*listener]struct{}) *dialer {
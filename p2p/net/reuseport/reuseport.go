@@ -3,22 +3,33 @@ package reuseport
 import (
 	"context"
 	"net"
+	"syscall"
 
 	"github.com/libp2p/go-reuseport"
 )
 
 var fallbackDialer net.Dialer
 
-// Dials using reuseport and then redials normally if that fails.
-func reuseDial(ctx context.Context, laddr *net.TCPAddr, network, raddr string) (con net.Conn, err error) {
+// Dials using reuseport and then redials using fallback if that fails.
+// The Control function of fallback is also applied when reusing the
+// port.
+func reuseDial(ctx context.Context, laddr *net.TCPAddr, network, raddr string, fallback *net.Dialer) (con net.Conn, err error) {
 	if laddr == nil {
-		return fallbackDialer.DialContext(ctx, network, raddr)
+		return fallback.DialContext(ctx, network, raddr)
 	}
 
 	d := net.Dialer{
 		LocalAddr: laddr,
 		Control:   reuseport.Control,
 	}
+	if control := fallback.Control; control != nil {
+		d.Control = func(network, address string, c syscall.RawConn) error {
+			if err := reuseport.Control(network, address, c); err != nil {
+				return err
+			}
+			return control(network, address, c)
+		}
+	}
 
 	con, err = d.DialContext(ctx, network, raddr)
 	if err == nil {
@@ -29,7 +40,7 @@ func reuseDial(ctx context.Context, laddr *net.TCPAddr, network, raddr string) (
 		// We could have an existing socket open or we could have one
 		// stuck in TIME-WAIT.
 		log.Debugf("failed to reuse port, will try again with a random port: %s", err)
-		con, err = fallbackDialer.DialContext(ctx, network, raddr)
+		con, err = fallback.DialContext(ctx, network, raddr)
 	}
 	return con, err
 }
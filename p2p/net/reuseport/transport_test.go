@@ -4,6 +4,7 @@ import (
 	"context"
 	"net"
 	"runtime"
+	"syscall"
 	"testing"
 	"time"
 
@@ -275,3 +276,46 @@ func TestDuplicateGlobal(t *testing.T) {
 		dialOne(t, &trB, listenerA, port)
 	}
 }
+
+func TestDialFrom(t *testing.T) {
+	var trA Transport
+	var trB Transport
+	listenerA, err := trA.Listen(loopbackV4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listenerA.Close()
+
+	listenerB, err := trB.Listen(unspecV4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listenerB.Close()
+
+	var controlCalled bool
+	control := func(_, _ string, _ syscall.RawConn) error {
+		controlCalled = true
+		return nil
+	}
+
+	connChan := acceptOne(t, listenerA)
+	src := net.IPv4(127, 0, 0, 1)
+	c, err := trB.DialContextFrom(context.Background(), listenerA.Multiaddr(), src, control)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setLingerZero(c)
+	defer c.Close()
+	(<-connChan).Close()
+
+	laddr := c.LocalAddr().(*net.TCPAddr)
+	if !laddr.IP.Equal(src) {
+		t.Errorf("expected to dial from %s, dialed from %s", src, laddr.IP)
+	}
+	if laddr.Port != listenerB.Addr().(*net.TCPAddr).Port {
+		t.Errorf("expected to reuse port %d, dialed from %d", listenerB.Addr().(*net.TCPAddr).Port, laddr.Port)
+	}
+	if !controlCalled {
+		t.Error("expected the control function to be called")
+	}
+}
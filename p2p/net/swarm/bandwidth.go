@@ -0,0 +1,206 @@
+package swarm
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// bandwidthBuckets is the number of buckets the bandwidth window is divided
+// into. Bytes expire from the window one bucket at a time.
+const bandwidthBuckets = 10
+
+// PeerBandwidth is the number of bytes exchanged with a peer over the
+// bandwidth accounting window.
+type PeerBandwidth struct {
+	Peer peer.ID
+	// In is the number of bytes received from the peer.
+	In uint64
+	// Out is the number of bytes sent to the peer.
+	Out uint64
+}
+
+// Total returns the number of bytes exchanged with the peer.
+func (b PeerBandwidth) Total() uint64 {
+	return b.In + b.Out
+}
+
+// WithBandwidthAccounting enables per-peer and per-connection byte counters.
+// The per-peer counters cover the last window, and are queried using
+// PeerBandwidth and TopPeersByBandwidth. The per-connection counters are
+// queried using Conn.BytesTransferred.
+//
+// Unlike the metrics.Reporter, the counters are maintained by the swarm, so
+// they can be used for abuse detection and fairness decisions.
+func WithBandwidthAccounting(window time.Duration) Option {
+	return func(s *Swarm) error {
+		if window < bandwidthBuckets*time.Millisecond {
+			return errors.New("swarm: bandwidth accounting window too small")
+		}
+		s.bwTracker = newBandwidthTracker(window)
+		return nil
+	}
+}
+
+// PeerBandwidth returns the bytes exchanged with p over the bandwidth
+// accounting window. It returns zero if bandwidth accounting is disabled.
+func (s *Swarm) PeerBandwidth(p peer.ID) PeerBandwidth {
+	if s.bwTracker == nil {
+		return PeerBandwidth{Peer: p}
+	}
+	return s.bwTracker.Peer(p)
+}
+
+// TopPeersByBandwidth returns up to n peers that exchanged the most bytes over
+// the bandwidth accounting window, in descending order. It returns nil if
+// bandwidth accounting is disabled.
+func (s *Swarm) TopPeersByBandwidth(n int) []PeerBandwidth {
+	if s.bwTracker == nil {
+		return nil
+	}
+	return s.bwTracker.Top(n)
+}
+
+type bandwidthBucket struct {
+	epoch   int64
+	in, out uint64
+}
+
+// peerBandwidth is the sliding window of the bytes exchanged with a peer.
+type peerBandwidth struct {
+	mx        sync.Mutex
+	buckets   [bandwidthBuckets]bandwidthBucket
+	lastEpoch int64
+}
+
+func (pb *peerBandwidth) add(epoch int64, in, out uint64) {
+	pb.mx.Lock()
+	defer pb.mx.Unlock()
+	b := &pb.buckets[epoch%bandwidthBuckets]
+	if b.epoch != epoch {
+		*b = bandwidthBucket{epoch: epoch}
+	}
+	b.in += in
+	b.out += out
+	pb.lastEpoch = epoch
+}
+
+func (pb *peerBandwidth) sum(epoch int64) (in, out uint64) {
+	pb.mx.Lock()
+	defer pb.mx.Unlock()
+	for _, b := range pb.buckets {
+		if b.epoch > epoch-bandwidthBuckets && b.epoch <= epoch {
+			in += b.in
+			out += b.out
+		}
+	}
+	return in, out
+}
+
+// bandwidthTracker counts the bytes exchanged with every peer over a sliding
+// window. The counters of each peer have their own lock, so that reads and
+// writes on streams to different peers don't contend.
+type bandwidthTracker struct {
+	bucketSize time.Duration
+	now        func() time.Time
+
+	mx          sync.RWMutex // protects peers and lastGCEpoch
+	peers       map[peer.ID]*peerBandwidth
+	lastGCEpoch int64
+}
+
+func newBandwidthTracker(window time.Duration) *bandwidthTracker {
+	return &bandwidthTracker{
+		bucketSize: window / bandwidthBuckets,
+		now:        time.Now,
+		peers:      make(map[peer.ID]*peerBandwidth),
+	}
+}
+
+func (t *bandwidthTracker) epoch() int64 {
+	return t.now().UnixNano() / int64(t.bucketSize)
+}
+
+func (t *bandwidthTracker) Add(p peer.ID, in, out uint64) {
+	if in == 0 && out == 0 {
+		return
+	}
+	epoch := t.epoch()
+
+	// Keep the read lock while adding, so that gc can't drop the peer in between.
+	t.mx.RLock()
+	pb, ok := t.peers[p]
+	if ok && epoch-t.lastGCEpoch < bandwidthBuckets {
+		pb.add(epoch, in, out)
+		t.mx.RUnlock()
+		return
+	}
+	t.mx.RUnlock()
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.gc(epoch)
+	pb, ok = t.peers[p]
+	if !ok {
+		pb = &peerBandwidth{}
+		t.peers[p] = pb
+	}
+	pb.add(epoch, in, out)
+}
+
+// gc removes the peers that didn't exchange any bytes in the window. It runs at
+// most once per window. t.mx must be held.
+func (t *bandwidthTracker) gc(epoch int64) {
+	if epoch-t.lastGCEpoch < bandwidthBuckets {
+		return
+	}
+	t.lastGCEpoch = epoch
+	for p, pb := range t.peers {
+		pb.mx.Lock()
+		expired := pb.lastEpoch <= epoch-bandwidthBuckets
+		pb.mx.Unlock()
+		if expired {
+			delete(t.peers, p)
+		}
+	}
+}
+
+func (t *bandwidthTracker) Peer(p peer.ID) PeerBandwidth {
+	epoch := t.epoch()
+
+	t.mx.RLock()
+	pb, ok := t.peers[p]
+	t.mx.RUnlock()
+	if !ok {
+		return PeerBandwidth{Peer: p}
+	}
+	in, out := pb.sum(epoch)
+	return PeerBandwidth{Peer: p, In: in, Out: out}
+}
+
+func (t *bandwidthTracker) Top(n int) []PeerBandwidth {
+	epoch := t.epoch()
+
+	t.mx.RLock()
+	res := make([]PeerBandwidth, 0, len(t.peers))
+	for p, pb := range t.peers {
+		in, out := pb.sum(epoch)
+		if in == 0 && out == 0 {
+			continue
+		}
+		res = append(res, PeerBandwidth{Peer: p, In: in, Out: out})
+	}
+	t.mx.RUnlock()
+
+	slices.SortFunc(res, func(a, b PeerBandwidth) int {
+		return cmp.Or(cmp.Compare(b.Total(), a.Total()), cmp.Compare(a.Peer, b.Peer))
+	})
+	if n >= 0 && len(res) > n {
+		res = res[:n]
+	}
+	return res
+}
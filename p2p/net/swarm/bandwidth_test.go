@@ -0,0 +1,42 @@
+package swarm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/test"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBandwidthTracker(t *testing.T) {
+	now := time.Now()
+	bt := newBandwidthTracker(10 * time.Second)
+	bt.now = func() time.Time { return now }
+
+	p1, p2, p3 := test.RandPeerIDFatal(t), test.RandPeerIDFatal(t), test.RandPeerIDFatal(t)
+	bt.Add(p1, 100, 0)
+	bt.Add(p2, 50, 100)
+	now = now.Add(5 * time.Second)
+	bt.Add(p1, 0, 100)
+	bt.Add(p3, 10, 0)
+
+	require.Equal(t, PeerBandwidth{Peer: p1, In: 100, Out: 100}, bt.Peer(p1))
+	require.Equal(t, []PeerBandwidth{
+		{Peer: p1, In: 100, Out: 100},
+		{Peer: p2, In: 50, Out: 100},
+	}, bt.Top(2))
+	require.Len(t, bt.Top(-1), 3)
+
+	// The bytes added 5s ago expire from the window.
+	now = now.Add(6 * time.Second)
+	require.Equal(t, PeerBandwidth{Peer: p1, Out: 100}, bt.Peer(p1))
+	require.Equal(t, PeerBandwidth{Peer: p2}, bt.Peer(p2))
+	require.Equal(t, []PeerBandwidth{{Peer: p1, Out: 100}, {Peer: p3, In: 10}}, bt.Top(10))
+
+	// Inactive peers are garbage collected.
+	now = now.Add(time.Minute)
+	bt.Add(p3, 1, 1)
+	require.Equal(t, []PeerBandwidth{{Peer: p3, In: 1, Out: 1}}, bt.Top(10))
+	require.Len(t, bt.peers, 1)
+}
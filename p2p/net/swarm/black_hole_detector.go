@@ -1,34 +1,24 @@
 package swarm
 
 import (
-	"fmt"
 	"sync"
 
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/network"
+
 	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr/net"
 )
 
-type BlackHoleState int
+// BlackHoleState is the state of a BlackHoleSuccessCounter.
+type BlackHoleState = network.BlackHoleState
 
 const (
-	blackHoleStateProbing BlackHoleState = iota
-	blackHoleStateAllowed
-	blackHoleStateBlocked
+	blackHoleStateProbing = network.BlackHoleStateProbing
+	blackHoleStateAllowed = network.BlackHoleStateAllowed
+	blackHoleStateBlocked = network.BlackHoleStateBlocked
 )
 
-func (st BlackHoleState) String() string {
-	switch st {
-	case blackHoleStateProbing:
-		return "Probing"
-	case blackHoleStateAllowed:
-		return "Allowed"
-	case blackHoleStateBlocked:
-		return "Blocked"
-	default:
-		return fmt.Sprintf("Unknown %d", st)
-	}
-}
-
 // BlackHoleSuccessCounter provides black hole filtering for dials. This filter should be used in concert
 // with a UDP or IPv6 address filter to detect UDP or IPv6 black hole. In a black holed environment,
 // dial requests are refused Requests are blocked if the number of successes in the last N dials is
@@ -64,6 +54,12 @@ type BlackHoleSuccessCounter struct {
 // state of the filter to Probing. A failed dial only blocks subsequent requests if the success
 // fraction over the last n outcomes is less than the minSuccessFraction of the filter.
 func (b *BlackHoleSuccessCounter) RecordResult(success bool) {
+	b.recordResult(success)
+}
+
+// recordResult records the outcome of a dial. It returns the new state and
+// whether the state changed.
+func (b *BlackHoleSuccessCounter) recordResult(success bool) (BlackHoleState, bool) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
@@ -71,8 +67,8 @@ func (b *BlackHoleSuccessCounter) RecordResult(success bool) {
 		// If the call succeeds in a blocked state we reset to allowed.
 		// This is better than slowly accumulating values till we cross the minSuccessFraction
 		// threshold since a black hole is a binary property.
-		b.reset()
-		return
+		changed := b.reset()
+		return b.state, changed
 	}
 
 	if success {
@@ -87,7 +83,8 @@ func (b *BlackHoleSuccessCounter) RecordResult(success bool) {
 		b.dialResults = b.dialResults[1:]
 	}
 
-	b.updateState()
+	changed := b.updateState()
+	return b.state, changed
 }
 
 // HandleRequest returns the result of applying the black hole filter for the request.
@@ -106,14 +103,23 @@ func (b *BlackHoleSuccessCounter) HandleRequest() BlackHoleState {
 	}
 }
 
-func (b *BlackHoleSuccessCounter) reset() {
+// Reset discards all recorded dial results, moving the counter to Probing state.
+// This is useful when the network changed, e.g. after switching from a network
+// that blocks UDP to one that doesn't.
+func (b *BlackHoleSuccessCounter) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.reset()
+}
+
+func (b *BlackHoleSuccessCounter) reset() (changed bool) {
 	b.successes = 0
 	b.dialResults = b.dialResults[:0]
 	b.requests = 0
-	b.updateState()
+	return b.updateState()
 }
 
-func (b *BlackHoleSuccessCounter) updateState() {
+func (b *BlackHoleSuccessCounter) updateState() (changed bool) {
 	st := b.state
 
 	if len(b.dialResults) < b.N {
@@ -126,7 +132,9 @@ func (b *BlackHoleSuccessCounter) updateState() {
 
 	if st != b.state {
 		log.Debugf("%s blackHoleDetector state changed from %s to %s", b.Name, st, b.state)
+		return true
 	}
+	return false
 }
 
 func (b *BlackHoleSuccessCounter) State() BlackHoleState {
@@ -136,14 +144,22 @@ func (b *BlackHoleSuccessCounter) State() BlackHoleState {
 	return b.state
 }
 
-type blackHoleInfo struct {
-	name            string
-	state           BlackHoleState
-	nextProbeAfter  int
-	successFraction float64
+// BlackHoleInfo describes the state of a BlackHoleSuccessCounter.
+type BlackHoleInfo struct {
+	// Name is the name of the counter.
+	Name string
+	// State is the current state.
+	State BlackHoleState
+	// NextProbeAfter is the number of dial requests after which the next probe
+	// is allowed in Blocked state.
+	NextProbeAfter int
+	// SuccessFraction is the fraction of successful dials among the recorded
+	// dial results.
+	SuccessFraction float64
 }
 
-func (b *BlackHoleSuccessCounter) info() blackHoleInfo {
+// Info returns the current state of the counter.
+func (b *BlackHoleSuccessCounter) Info() BlackHoleInfo {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
@@ -157,11 +173,11 @@ func (b *BlackHoleSuccessCounter) info() blackHoleInfo {
 		successFraction = float64(b.successes) / float64(len(b.dialResults))
 	}
 
-	return blackHoleInfo{
-		name:            b.Name,
-		state:           b.state,
-		nextProbeAfter:  nextProbeAfter,
-		successFraction: successFraction,
+	return BlackHoleInfo{
+		Name:            b.Name,
+		State:           b.state,
+		NextProbeAfter:  nextProbeAfter,
+		SuccessFraction: successFraction,
 	}
 }
 
@@ -178,6 +194,8 @@ type blackHoleDetector struct {
 	udp, ipv6 *BlackHoleSuccessCounter
 	mt        MetricsTracer
 	readOnly  bool
+	// emitter emits EvtBlackHoleStateChanged. It's nil if no events are emitted.
+	emitter event.Emitter
 }
 
 // FilterAddrs filters the peer's addresses removing black holed addresses
@@ -242,13 +260,56 @@ func (d *blackHoleDetector) RecordResult(addr ma.Multiaddr, success bool) {
 		return
 	}
 	if d.udp != nil && isProtocolAddr(addr, ma.P_UDP) {
-		d.udp.RecordResult(success)
-		d.trackMetrics(d.udp)
+		d.recordResult(d.udp, success)
 	}
 	if d.ipv6 != nil && isProtocolAddr(addr, ma.P_IP6) {
-		d.ipv6.RecordResult(success)
-		d.trackMetrics(d.ipv6)
+		d.recordResult(d.ipv6, success)
+	}
+}
+
+func (d *blackHoleDetector) recordResult(f *BlackHoleSuccessCounter, success bool) {
+	if st, changed := f.recordResult(success); changed {
+		d.emitStateChanged(f.Name, st)
+	}
+	d.trackMetrics(f)
+}
+
+// Info returns the state of the UDP and IPv6 black hole counters, if they're configured.
+func (d *blackHoleDetector) Info() []BlackHoleInfo {
+	var res []BlackHoleInfo
+	for _, f := range []*BlackHoleSuccessCounter{d.udp, d.ipv6} {
+		if f != nil {
+			res = append(res, f.Info())
+		}
+	}
+	return res
+}
+
+// Reset resets the UDP and IPv6 black hole counters. It has no effect in read only mode.
+func (d *blackHoleDetector) Reset() {
+	if d.readOnly {
+		return
+	}
+	for _, f := range []*BlackHoleSuccessCounter{d.udp, d.ipv6} {
+		if f == nil {
+			continue
+		}
+		f.mu.Lock()
+		changed := f.reset()
+		st := f.state
+		f.mu.Unlock()
+		if changed {
+			d.emitStateChanged(f.Name, st)
+		}
+		d.trackMetrics(f)
+	}
+}
+
+func (d *blackHoleDetector) emitStateChanged(name string, st BlackHoleState) {
+	if d.emitter == nil {
+		return
 	}
+	d.emitter.Emit(event.EvtBlackHoleStateChanged{Name: name, State: st})
 }
 
 func (d *blackHoleDetector) getFilterState(f *BlackHoleSuccessCounter) BlackHoleState {
@@ -266,6 +327,6 @@ func (d *blackHoleDetector) trackMetrics(f *BlackHoleSuccessCounter) {
 		return
 	}
 	// Track metrics only in non readOnly state
-	info := f.info()
-	d.mt.UpdatedBlackHoleSuccessCounter(info.name, info.state, info.nextProbeAfter, info.successFraction)
+	info := f.Info()
+	d.mt.UpdatedBlackHoleSuccessCounter(info.Name, info.State, info.NextProbeAfter, info.SuccessFraction)
 }
@@ -3,6 +3,10 @@ package swarm
 import (
 	"fmt"
 	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
 
 	ma "github.com/multiformats/go-multiaddr"
 	"github.com/stretchr/testify/require"
@@ -242,3 +246,53 @@ func TestBlackHoleDetectorReadOnlyMode(t *testing.T) {
 	require.ElementsMatch(t, wantAddrs, gotAddrs)
 	require.ElementsMatch(t, wantRemovedAddrs, gotRemovedAddrs)
 }
+
+func TestBlackHoleDetectorEventsAndReset(t *testing.T) {
+	bus := eventbus.NewBus()
+	sub, err := bus.Subscribe(new(event.EvtBlackHoleStateChanged))
+	require.NoError(t, err)
+	defer sub.Close()
+	em, err := bus.Emitter(new(event.EvtBlackHoleStateChanged))
+	require.NoError(t, err)
+	defer em.Close()
+
+	udpF := &BlackHoleSuccessCounter{N: 3, MinSuccesses: 1, Name: "UDP"}
+	bhd := &blackHoleDetector{udp: udpF, emitter: em}
+	addr := ma.StringCast("/ip4/1.2.3.4/udp/1234/quic-v1")
+
+	expectEvent := func(st BlackHoleState) {
+		t.Helper()
+		select {
+		case e := <-sub.Out():
+			require.Equal(t, event.EvtBlackHoleStateChanged{Name: "UDP", State: st}, e)
+		case <-time.After(time.Second):
+			t.Fatalf("expected a %s event", st)
+		}
+	}
+
+	for i := 0; i < 3; i++ {
+		bhd.RecordResult(addr, false)
+	}
+	expectEvent(blackHoleStateBlocked)
+	require.Equal(t, []BlackHoleInfo{{Name: "UDP", State: blackHoleStateBlocked, NextProbeAfter: 3}}, bhd.Info())
+
+	bhd.Reset()
+	expectEvent(blackHoleStateProbing)
+	require.Equal(t, blackHoleStateProbing, udpF.State())
+
+	// reset without a state change doesn't emit an event
+	bhd.Reset()
+	select {
+	case e := <-sub.Out():
+		t.Fatalf("unexpected event %v", e)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// read only detectors can't be reset
+	for i := 0; i < 3; i++ {
+		bhd.RecordResult(addr, false)
+	}
+	expectEvent(blackHoleStateBlocked)
+	(&blackHoleDetector{udp: udpF, readOnly: true}).Reset()
+	require.Equal(t, blackHoleStateBlocked, udpF.State())
+}
@@ -18,8 +18,8 @@ type connectednessEventEmitter struct {
 	// newConns is the channel that holds the peerIDs we recently connected to
 	newConns      chan peer.ID
 	removeConnsMx sync.Mutex
-	// removeConns is a slice of peerIDs we have recently closed connections to
-	removeConns []peer.ID
+	// removeConns is a slice of connections we have recently closed
+	removeConns []removedConn
 	// lastEvent is the last connectedness event sent for a particular peer.
 	lastEvent map[peer.ID]network.Connectedness
 	// connectedness is the function that gives the peers current connectedness state
@@ -58,7 +58,14 @@ func (c *connectednessEventEmitter) AddConn(p peer.ID) {
 	c.newConns <- p
 }
 
-func (c *connectednessEventEmitter) RemoveConn(p peer.ID) {
+// removedConn is a closed connection to a peer.
+type removedConn struct {
+	p      peer.ID
+	reason network.DisconnectReason
+	stat   network.ConnStats
+}
+
+func (c *connectednessEventEmitter) RemoveConn(p peer.ID, reason network.DisconnectReason, stat network.ConnStats) {
 	c.mx.RLock()
 	defer c.mx.RUnlock()
 	if c.ctx.Err() != nil {
@@ -72,7 +79,7 @@ func (c *connectednessEventEmitter) RemoveConn(p peer.ID) {
 	//
 	// We purposefully don't block/backpressure here to avoid deadlocks, since it's
 	// reasonable for a consumer of the event to want to remove a connection.
-	c.removeConns = append(c.removeConns, p)
+	c.removeConns = append(c.removeConns, removedConn{p: p, reason: reason, stat: stat})
 
 	c.removeConnsMx.Unlock()
 
@@ -92,7 +99,7 @@ func (c *connectednessEventEmitter) runEmitter() {
 	for {
 		select {
 		case p := <-c.newConns:
-			c.notifyPeer(p, true)
+			c.notifyPeer(p, true, nil)
 		case <-c.removeConnNotif:
 			c.sendConnRemovedNotifications()
 		case <-c.ctx.Done():
@@ -101,7 +108,7 @@ func (c *connectednessEventEmitter) runEmitter() {
 			for {
 				select {
 				case p := <-c.newConns:
-					c.notifyPeer(p, true)
+					c.notifyPeer(p, true, nil)
 				case <-c.removeConnNotif:
 					c.sendConnRemovedNotifications()
 				default:
@@ -118,17 +125,24 @@ func (c *connectednessEventEmitter) runEmitter() {
 // In case a peer is disconnected before we sent the Connected event, we still
 // send the Disconnected event because a connection to the peer can be observed
 // in such cases.
-func (c *connectednessEventEmitter) notifyPeer(p peer.ID, forceNotConnectedEvent bool) {
+// removed is the last connection to the peer that was closed, if any. It's used
+// to report why the peer disconnected.
+func (c *connectednessEventEmitter) notifyPeer(p peer.ID, forceNotConnectedEvent bool, removed *removedConn) {
 	oldState := c.lastEvent[p]
 	c.lastEvent[p] = c.connectedness(p)
 	if c.lastEvent[p] == network.NotConnected {
 		delete(c.lastEvent, p)
 	}
 	if (forceNotConnectedEvent && c.lastEvent[p] == network.NotConnected) || c.lastEvent[p] != oldState {
-		c.emitter.Emit(event.EvtPeerConnectednessChanged{
+		evt := event.EvtPeerConnectednessChanged{
 			Peer:          p,
 			Connectedness: c.lastEvent[p],
-		})
+		}
+		if evt.Connectedness == network.NotConnected && removed != nil {
+			evt.Reason = removed.reason
+			evt.LastConnStats = removed.stat
+		}
+		c.emitter.Emit(evt)
 	}
 }
 
@@ -137,7 +151,15 @@ func (c *connectednessEventEmitter) sendConnRemovedNotifications() {
 	removeConns := c.removeConns
 	c.removeConns = nil
 	c.removeConnsMx.Unlock()
-	for _, p := range removeConns {
-		c.notifyPeer(p, false)
+	// Report the last closed connection to each peer.
+	last := make(map[peer.ID]int, len(removeConns))
+	for i, rc := range removeConns {
+		last[rc.p] = i
+	}
+	for i, rc := range removeConns {
+		if last[rc.p] != i {
+			continue
+		}
+		c.notifyPeer(rc.p, false, &removeConns[i])
 	}
 }
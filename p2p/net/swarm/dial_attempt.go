@@ -0,0 +1,78 @@
+package swarm
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// DialAttempt describes the outcome of dialing a single address of a peer.
+type DialAttempt struct {
+	// Peer is the peer that was dialed.
+	Peer peer.ID
+	// Addr is the address that was dialed.
+	Addr ma.Multiaddr
+	// RankingDelay is the time the dial ranker delayed the dial by.
+	RankingDelay time.Duration
+	// Duration is the time from starting the dial until it completed.
+	Duration time.Duration
+	// Error is the error the dial failed with. It is nil if the dial succeeded.
+	Error error
+	// ErrorClass is a coarse classification of Error, e.g. "deadline" or
+	// "connection refused". It is empty if the dial succeeded.
+	ErrorClass string
+	// Won is true if the connection established by this dial was the first
+	// connection to the peer, i.e. this dial won the race against dials to the
+	// peer's other addresses.
+	Won bool
+}
+
+// WithDialAttemptHandler configures the swarm to call h for every completed dial
+// to an address. This allows applications to analyze why connections to some
+// peers are slow or failing.
+//
+// h is called synchronously from the peer's dial loop and must not block.
+// Dials that are canceled because another dial to the peer succeeded may not be
+// reported.
+func WithDialAttemptHandler(h func(DialAttempt)) Option {
+	return func(s *Swarm) error {
+		if h == nil {
+			return errors.New("swarm: dial attempt handler cannot be nil")
+		}
+		s.dialAttemptHandler = h
+		return nil
+	}
+}
+
+// dialErrorClass classifies the error of a failed dial. cause is the cause of
+// the cancellation of the dial's context, if any.
+func dialErrorClass(dialErr error, cause error) string {
+	// dial deadline exceeded or the the parent contexts deadline exceeded
+	if errors.Is(dialErr, context.DeadlineExceeded) || errors.Is(cause, context.DeadlineExceeded) {
+		return "deadline"
+	}
+	if errors.Is(dialErr, context.Canceled) {
+		// dial was cancelled.
+		if errors.Is(cause, context.Canceled) {
+			// parent context was canceled
+			return "application canceled"
+		} else if errors.Is(cause, errConcurrentDialSuccessful) {
+			return "canceled: concurrent dial successful"
+		}
+		// something else
+		return "canceled: other"
+	}
+	if nerr, ok := dialErr.(net.Error); ok && nerr.Timeout() {
+		return "timeout"
+	}
+	if strings.Contains(dialErr.Error(), "connect: connection refused") {
+		return "connection refused"
+	}
+	return "other"
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/libp2p/go-libp2p/core/peer"
 
@@ -80,3 +81,20 @@ func (e *TransportError) Unwrap() error {
 }
 
 var _ error = (*TransportError)(nil)
+
+// AddrDialTimeoutError is the cause of a TransportError if the dial to the
+// address exceeded the timeout applied to it.
+type AddrDialTimeoutError struct {
+	Timeout time.Duration
+	Cause   error
+}
+
+func (e *AddrDialTimeoutError) Error() string {
+	return fmt.Sprintf("dial timeout of %s exceeded: %s", e.Timeout, e.Cause)
+}
+
+func (e *AddrDialTimeoutError) Unwrap() error {
+	return e.Cause
+}
+
+var _ error = (*AddrDialTimeoutError)(nil)
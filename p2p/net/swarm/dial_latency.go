@@ -0,0 +1,218 @@
+package swarm
+
+import (
+	"encoding/gob"
+	"math"
+	"net/netip"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+const (
+	// dialLatencyKey is the peerstore metadata key under which the dial
+	// latencies of a peer's addresses are stored.
+	dialLatencyKey = "libp2p-dial-latency"
+	// dialLatencyAlpha is the weight of a new sample in the latency estimate.
+	dialLatencyAlpha = 0.3
+	// dialLatencyHalfLife is the time after which the weight of an estimate
+	// halves.
+	dialLatencyHalfLife = time.Hour
+	// dialLatencyMaxAge is the age after which an estimate is forgotten.
+	dialLatencyMaxAge = 8 * dialLatencyHalfLife
+	// maxDialLatencySubnets is the maximum number of subnets we keep
+	// estimates for.
+	maxDialLatencySubnets = 1024
+	// maxLatencyHeadStart is the maximum time the historically fastest
+	// address is dialed before the other addresses.
+	maxLatencyHeadStart = 500 * time.Millisecond
+)
+
+// registerLatencyGob registers the type of the stored latencies with gob,
+// which is needed to store them in datastore backed peerstores.
+var registerLatencyGob = sync.OnceFunc(func() { gob.Register(addrLatencies{}) })
+
+// WithLatencyAwareDialRanking makes the swarm remember the latency of
+// successful dials, per address and per subnet (/24 for IPv4, /48 for IPv6).
+// When dialing a peer, the address that was historically fastest is dialed
+// first, and the other addresses are delayed by up to twice its expected
+// latency. Addresses without history use the subnet's latency, if known.
+//
+// Per address latencies are stored in the peerstore. Older measurements
+// decay, and are forgotten after a few hours.
+func WithLatencyAwareDialRanking() Option {
+	return func(s *Swarm) error {
+		registerLatencyGob()
+		s.dialLatencies = newDialLatencyTracker(s.peers, time.Now)
+		return nil
+	}
+}
+
+// latencyEstimate is a decaying average of the dial latency.
+type latencyEstimate struct {
+	Latency time.Duration
+	Updated time.Time
+}
+
+// update returns the estimate updated with sample, observed at now.
+func (l latencyEstimate) update(sample time.Duration, now time.Time) latencyEstimate {
+	if l.Updated.IsZero() {
+		return latencyEstimate{Latency: sample, Updated: now}
+	}
+	// The weight of the old estimate decays with its age.
+	age := now.Sub(l.Updated)
+	w := (1 - dialLatencyAlpha) * math.Exp2(-float64(age)/float64(dialLatencyHalfLife))
+	lat := w*float64(l.Latency) + (1-w)*float64(sample)
+	return latencyEstimate{Latency: time.Duration(lat), Updated: now}
+}
+
+func (l latencyEstimate) expired(now time.Time) bool {
+	return now.Sub(l.Updated) > dialLatencyMaxAge
+}
+
+// addrLatencies are the latency estimates of a peer's addresses, keyed by the
+// binary representation of the address.
+type addrLatencies map[string]latencyEstimate
+
+// dialLatencyTracker learns the latency of addresses from successful dials.
+type dialLatencyTracker struct {
+	ps  peerstore.PeerMetadata
+	now func() time.Time
+
+	mx      sync.Mutex
+	subnets map[netip.Prefix]latencyEstimate
+}
+
+func newDialLatencyTracker(ps peerstore.PeerMetadata, now func() time.Time) *dialLatencyTracker {
+	return &dialLatencyTracker{
+		ps:      ps,
+		now:     now,
+		subnets: make(map[netip.Prefix]latencyEstimate),
+	}
+}
+
+// subnet returns the /24 or /48 subnet of a.
+func subnet(a ma.Multiaddr) (netip.Prefix, bool) {
+	ip, err := manet.ToIP(a)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return netip.Prefix{}, false
+	}
+	addr = addr.Unmap()
+	bits := 48
+	if addr.Is4() {
+		bits = 24
+	}
+	prefix, err := addr.Prefix(bits)
+	return prefix, err == nil
+}
+
+func (t *dialLatencyTracker) peerLatencies(p peer.ID) addrLatencies {
+	v, err := t.ps.Get(p, dialLatencyKey)
+	if err != nil {
+		return nil
+	}
+	l, _ := v.(addrLatencies)
+	return l
+}
+
+// Record records that dialing a took d.
+func (t *dialLatencyTracker) Record(p peer.ID, a ma.Multiaddr, d time.Duration) {
+	now := t.now()
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	old := t.peerLatencies(p)
+	latencies := make(addrLatencies, len(old)+1)
+	for k, l := range old {
+		if !l.expired(now) {
+			latencies[k] = l
+		}
+	}
+	key := string(a.Bytes())
+	latencies[key] = latencies[key].update(d, now)
+	if err := t.ps.Put(p, dialLatencyKey, latencies); err != nil {
+		log.Debugf("failed to store dial latencies for %s: %s", p, err)
+	}
+
+	prefix, ok := subnet(a)
+	if !ok {
+		return
+	}
+	if _, ok := t.subnets[prefix]; !ok && len(t.subnets) >= maxDialLatencySubnets {
+		for k, l := range t.subnets {
+			if l.expired(now) {
+				delete(t.subnets, k)
+			}
+		}
+		if len(t.subnets) >= maxDialLatencySubnets {
+			return
+		}
+	}
+	t.subnets[prefix] = t.subnets[prefix].update(d, now)
+}
+
+// Estimate returns the expected latency of dialing a.
+func (t *dialLatencyTracker) Estimate(p peer.ID, a ma.Multiaddr) (time.Duration, bool) {
+	now := t.now()
+
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return t.estimate(t.peerLatencies(p), a, now)
+}
+
+func (t *dialLatencyTracker) estimate(latencies addrLatencies, a ma.Multiaddr, now time.Time) (time.Duration, bool) {
+	if l, ok := latencies[string(a.Bytes())]; ok && !l.expired(now) {
+		return l.Latency, true
+	}
+	if prefix, ok := subnet(a); ok {
+		if l, ok := t.subnets[prefix]; ok && !l.expired(now) {
+			return l.Latency, true
+		}
+	}
+	return 0, false
+}
+
+// Rank adjusts the ranking of p's addresses, so that the address with the
+// lowest expected latency is dialed first. The other addresses are delayed by
+// up to twice its expected latency, giving it the chance to succeed.
+func (t *dialLatencyTracker) Rank(p peer.ID, ranking []network.AddrDelay) []network.AddrDelay {
+	now := t.now()
+
+	t.mx.Lock()
+	latencies := t.peerLatencies(p)
+	fastest := -1
+	var fastestLatency time.Duration
+	for i, ad := range ranking {
+		l, ok := t.estimate(latencies, ad.Addr, now)
+		if ok && (fastest == -1 || l < fastestLatency) {
+			fastest, fastestLatency = i, l
+		}
+	}
+	t.mx.Unlock()
+
+	if fastest == -1 {
+		return ranking
+	}
+	headStart := min(2*fastestLatency, maxLatencyHeadStart)
+	res := slices.Clone(ranking)
+	for i := range res {
+		if i == fastest {
+			res[i].Delay = 0
+		} else {
+			res[i].Delay += headStart
+		}
+	}
+	return res
+}
@@ -0,0 +1,95 @@
+package swarm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/test"
+	"github.com/libp2p/go-libp2p/p2p/host/peerstore/pstoremem"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialLatencyTracker(t *testing.T) {
+	ps, err := pstoremem.NewPeerstore()
+	require.NoError(t, err)
+	defer ps.Close()
+
+	now := time.Now()
+	dt := newDialLatencyTracker(ps, func() time.Time { return now })
+
+	p1, p2 := test.RandPeerIDFatal(t), test.RandPeerIDFatal(t)
+	a1 := ma.StringCast("/ip4/1.2.3.4/tcp/1")
+	a2 := ma.StringCast("/ip4/1.2.3.5/udp/1/quic-v1")
+	a3 := ma.StringCast("/ip4/5.6.7.8/tcp/1")
+
+	_, ok := dt.Estimate(p1, a1)
+	require.False(t, ok)
+
+	dt.Record(p1, a1, 100*time.Millisecond)
+	l, ok := dt.Estimate(p1, a1)
+	require.True(t, ok)
+	require.Equal(t, 100*time.Millisecond, l)
+
+	// Samples are averaged.
+	dt.Record(p1, a1, 200*time.Millisecond)
+	l, ok = dt.Estimate(p1, a1)
+	require.True(t, ok)
+	require.Greater(t, l, 100*time.Millisecond)
+	require.Less(t, l, 200*time.Millisecond)
+
+	// Addresses without history use the latency of their subnet.
+	l2, ok := dt.Estimate(p2, a2)
+	require.True(t, ok)
+	require.Equal(t, l, l2)
+	_, ok = dt.Estimate(p2, a3)
+	require.False(t, ok)
+
+	// Old estimates decay, new samples dominate.
+	now = now.Add(4 * dialLatencyHalfLife)
+	dt.Record(p1, a1, 10*time.Millisecond)
+	l, ok = dt.Estimate(p1, a1)
+	require.True(t, ok)
+	require.Less(t, l, 20*time.Millisecond)
+
+	// And are forgotten eventually.
+	now = now.Add(dialLatencyMaxAge + time.Second)
+	_, ok = dt.Estimate(p1, a1)
+	require.False(t, ok)
+}
+
+func TestDialLatencyTrackerRank(t *testing.T) {
+	ps, err := pstoremem.NewPeerstore()
+	require.NoError(t, err)
+	defer ps.Close()
+
+	dt := newDialLatencyTracker(ps, time.Now)
+	p := test.RandPeerIDFatal(t)
+	a1 := ma.StringCast("/ip4/1.2.3.4/udp/1/quic-v1")
+	a2 := ma.StringCast("/ip4/5.6.7.8/tcp/1")
+	a3 := ma.StringCast("/ip4/9.9.9.9/tcp/1")
+	ranking := []network.AddrDelay{
+		{Addr: a1, Delay: 0},
+		{Addr: a2, Delay: 250 * time.Millisecond},
+		{Addr: a3, Delay: 250 * time.Millisecond},
+	}
+
+	// Without history the ranking is unchanged.
+	require.Equal(t, ranking, dt.Rank(p, ranking))
+
+	dt.Record(p, a1, 300*time.Millisecond)
+	dt.Record(p, a2, 20*time.Millisecond)
+	require.Equal(t, []network.AddrDelay{
+		{Addr: a1, Delay: 40 * time.Millisecond},
+		{Addr: a2, Delay: 0},
+		{Addr: a3, Delay: 290 * time.Millisecond},
+	}, dt.Rank(p, ranking))
+
+	// The head start is capped.
+	dt.Record(p, a2, 10*time.Second)
+	dt.Record(p, a1, 10*time.Second)
+	res := dt.Rank(p, ranking)
+	require.Equal(t, maxLatencyHeadStart, res[2].Delay-ranking[2].Delay)
+}
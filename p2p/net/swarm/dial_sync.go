@@ -49,6 +49,9 @@ func (ad *activeDial) dial(ctx context.Context) (*Conn, error) {
 	if simConnect, isClient, reason := network.GetSimultaneousConnect(ctx); simConnect {
 		dialCtx = network.WithSimultaneousConnect(dialCtx, isClient, reason)
 	}
+	if policy := network.GetRelayPolicy(ctx); policy != network.RelayPolicyDefault {
+		dialCtx = network.WithRelayPolicy(dialCtx, policy)
+	}
 
 	resch := make(chan dialResponse, 1)
 	select {
@@ -0,0 +1,45 @@
+package swarm
+
+import (
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// DialTimeoutSchedule returns the timeout for a single dial to addr. The
+// timeout covers dialing the raw connection as well as the security and muxer
+// handshakes. Returning 0 falls back to the swarm's default dial timeout.
+type DialTimeoutSchedule func(addr ma.Multiaddr) time.Duration
+
+// DialTimeoutsByClass is a DialTimeoutSchedule that assigns timeouts based on
+// the class of the address. Zero values fall back to the swarm's default dial
+// timeout.
+type DialTimeoutsByClass struct {
+	// Private applies to addresses in private networks and loopback addresses.
+	Private time.Duration
+	// PublicTCP applies to public TCP addresses, including websocket addresses.
+	PublicTCP time.Duration
+	// PublicUDP applies to public UDP addresses (QUIC, WebTransport, WebRTC).
+	PublicUDP time.Duration
+	// Relay applies to relayed addresses.
+	Relay time.Duration
+}
+
+// Schedule returns the DialTimeoutSchedule for the configured classes.
+func (c DialTimeoutsByClass) Schedule() DialTimeoutSchedule {
+	return func(addr ma.Multiaddr) time.Duration {
+		switch {
+		case isRelayAddr(addr):
+			return c.Relay
+		case manet.IsPrivateAddr(addr):
+			return c.Private
+		case isProtocolAddr(addr, ma.P_TCP):
+			return c.PublicTCP
+		case isProtocolAddr(addr, ma.P_UDP):
+			return c.PublicUDP
+		default:
+			return 0
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package swarm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+func TestDialTimeoutsByClass(t *testing.T) {
+	sched := DialTimeoutsByClass{
+		Private:   2 * time.Second,
+		PublicTCP: 5 * time.Second,
+		Relay:     10 * time.Second,
+	}.Schedule()
+
+	for _, tc := range []struct {
+		addr    string
+		timeout time.Duration
+	}{
+		{"/ip4/192.168.1.1/tcp/1", 2 * time.Second},
+		{"/ip4/127.0.0.1/udp/1/quic-v1", 2 * time.Second},
+		{"/ip4/1.2.3.4/tcp/1", 5 * time.Second},
+		{"/ip4/1.2.3.4/tcp/1/ws", 5 * time.Second},
+		{"/ip4/1.2.3.4/udp/1/quic-v1", 0},
+		{"/ip4/1.2.3.4/tcp/1/p2p/QmZKPoRXNBk4BjMgeAmxr8eYbx6YUWRosLpVEyHpZodCJF/p2p-circuit", 10 * time.Second},
+	} {
+		require.Equal(t, tc.timeout, sched(ma.StringCast(tc.addr)), tc.addr)
+	}
+}
+
+func TestDialTimeoutForAddr(t *testing.T) {
+	s := &Swarm{
+		dialTimeout:      defaultDialTimeout,
+		dialTimeoutLocal: defaultDialTimeoutLocal,
+	}
+	pub := ma.StringCast("/ip4/1.2.3.4/udp/1/quic-v1")
+	private := ma.StringCast("/ip4/192.168.1.1/tcp/1")
+	require.Equal(t, defaultDialTimeout, s.dialTimeoutForAddr(pub))
+	require.Equal(t, defaultDialTimeoutLocal, s.dialTimeoutForAddr(private))
+
+	s.dialTimeoutSchedule = DialTimeoutsByClass{Private: time.Second}.Schedule()
+	require.Equal(t, defaultDialTimeout, s.dialTimeoutForAddr(pub))
+	require.Equal(t, time.Second, s.dialTimeoutForAddr(private))
+}
@@ -64,6 +64,8 @@ type addrDial struct {
 	createdAt time.Time
 	// dialRankingDelay is the delay in dialing this address introduced by the ranking logic
 	dialRankingDelay time.Duration
+	// dequeuedAt is the time the address was taken from the dial queue to be dialed
+	dequeuedAt time.Time
 	// expectedTCPUpgradeTime is the expected time by which security upgrade will complete
 	expectedTCPUpgradeTime time.Time
 }
@@ -294,6 +296,7 @@ loop:
 					continue
 				}
 				ad.dialed = true
+				ad.dequeuedAt = now
 				ad.dialRankingDelay = now.Sub(ad.createdAt)
 				err := w.s.dialNextAddr(ad.ctx, w.peer, ad.addr, w.resch)
 				if err != nil {
@@ -344,9 +347,14 @@ loop:
 				if err != nil {
 					// oops no, we failed to add it to the swarm
 					res.Conn.Close()
+					w.reportDialAttempt(ad, err, false)
 					w.dispatchError(ad, err)
 					continue loop
 				}
+				w.reportDialAttempt(ad, nil, !w.connected)
+				if w.s.dialLatencies != nil {
+					w.s.dialLatencies.Record(w.peer, ad.addr, time.Since(ad.dequeuedAt))
+				}
 
 				for pr := range w.pendingRequests {
 					if _, ok := pr.addrs[string(ad.addr.Bytes())]; ok {
@@ -366,9 +374,11 @@ loop:
 				continue loop
 			}
 
+			w.reportDialAttempt(ad, res.Err, false)
+
 			// it must be an error -- add backoff if applicable and dispatch
 			// ErrDialRefusedBlackHole shouldn't end up here, just a safety check
-			if res.Err != ErrDialRefusedBlackHole && res.Err != context.Canceled && !w.connected {
+			if res.Err != ErrDialRefusedBlackHole && res.Err != context.Canceled && !w.connected && !w.s.backf.exempt(res.Err) {
 				// we only add backoff if there has not been a successful connection
 				// for consistency with the old dialer behavior.
 				w.s.backf.AddBackoff(w.peer, res.Addr)
@@ -419,13 +429,42 @@ func (w *dialWorker) dispatchError(ad *addrDial, err error) {
 	}
 }
 
+// reportDialAttempt reports the outcome of a dial to the swarm's dial attempt
+// handler.
+func (w *dialWorker) reportDialAttempt(ad *addrDial, err error, won bool) {
+	if w.s.dialAttemptHandler == nil {
+		return
+	}
+	a := DialAttempt{
+		Peer:         w.peer,
+		Addr:         ad.addr,
+		RankingDelay: ad.dialRankingDelay,
+		Duration:     time.Since(ad.dequeuedAt),
+		Error:        err,
+		Won:          won,
+	}
+	if err != nil {
+		a.ErrorClass = dialErrorClass(err, context.Cause(ad.ctx))
+	}
+	w.s.dialAttemptHandler(a)
+}
+
 // rankAddrs ranks addresses for dialing. if it's a simConnect request we
 // dial all addresses immediately without any delay
 func (w *dialWorker) rankAddrs(addrs []ma.Multiaddr, isSimConnect bool) []network.AddrDelay {
 	if isSimConnect {
 		return NoDelayDialRanker(addrs)
 	}
-	return w.s.dialRanker(addrs)
+	var ranking []network.AddrDelay
+	if w.s.peerDialRanker != nil {
+		ranking = w.s.peerDialRanker(w.peer, addrs)
+	} else {
+		ranking = w.s.dialRanker(addrs)
+	}
+	if w.s.dialLatencies != nil {
+		ranking = w.s.dialLatencies.Rank(w.peer, ranking)
+	}
+	return ranking
 }
 
 // dialQueue is a priority queue used to schedule dials
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"math"
 	mrand "math/rand"
+	"net"
 	"reflect"
 	"sort"
 	"sync"
@@ -1194,3 +1195,80 @@ func BenchmarkDialRanker(b *testing.B) {
 		}
 	})
 }
+
+func TestPeerDialRanker(t *testing.T) {
+	var rankedPeer peer.ID
+	s1 := makeSwarmWithNoListenAddrs(t, WithPeerDialRanker(func(p peer.ID, addrs []ma.Multiaddr) []network.AddrDelay {
+		rankedPeer = p
+		// only dial TCP addresses
+		var res []network.AddrDelay
+		for _, a := range addrs {
+			if isProtocolAddr(a, ma.P_TCP) {
+				res = append(res, network.AddrDelay{Addr: a})
+			}
+		}
+		return res
+	}))
+	defer s1.Close()
+	s2 := makeSwarm(t)
+	defer s2.Close()
+	s1.Peerstore().AddAddrs(s2.LocalPeer(), s2.ListenAddresses(), peerstore.PermanentAddrTTL)
+
+	conn, err := s1.DialPeer(context.Background(), s2.LocalPeer())
+	require.NoError(t, err)
+	require.Equal(t, s2.LocalPeer(), rankedPeer)
+	require.True(t, isProtocolAddr(conn.RemoteMultiaddr(), ma.P_TCP))
+}
+
+func TestDialAttemptHandler(t *testing.T) {
+	var mx sync.Mutex
+	var attempts []DialAttempt
+	s1 := makeSwarmWithNoListenAddrs(t,
+		WithDialRanker(NoDelayDialRanker),
+		WithDialAttemptHandler(func(a DialAttempt) {
+			mx.Lock()
+			defer mx.Unlock()
+			attempts = append(attempts, a)
+		}),
+	)
+	defer s1.Close()
+	s2 := makeSwarmWithNoListenAddrs(t)
+	defer s2.Close()
+	require.NoError(t, s2.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0")))
+
+	// nothing listens on this address
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	bad, err := manet.FromNetAddr(ln.Addr())
+	require.NoError(t, err)
+	ln.Close()
+
+	good := s2.ListenAddresses()[0]
+	s1.Peerstore().AddAddrs(s2.LocalPeer(), []ma.Multiaddr{good, bad}, peerstore.PermanentAddrTTL)
+	_, err = s1.DialPeer(context.Background(), s2.LocalPeer())
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		mx.Lock()
+		defer mx.Unlock()
+		return len(attempts) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	mx.Lock()
+	defer mx.Unlock()
+	for _, a := range attempts {
+		require.Equal(t, s2.LocalPeer(), a.Peer)
+		switch {
+		case a.Addr.Equal(good):
+			require.NoError(t, a.Error)
+			require.True(t, a.Won)
+			require.Empty(t, a.ErrorClass)
+		case a.Addr.Equal(bad):
+			require.Error(t, a.Error)
+			require.False(t, a.Won)
+			require.Equal(t, "connection refused", a.ErrorClass)
+		default:
+			t.Fatalf("unexpected address %s", a.Addr)
+		}
+	}
+}
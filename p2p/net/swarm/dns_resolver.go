@@ -0,0 +1,180 @@
+package swarm
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// DNSResolutionConfig configures how /dnsaddr and /dns multiaddrs are resolved
+// when dialing a peer.
+type DNSResolutionConfig struct {
+	// MaxRecursion is the maximum depth of nested /dnsaddr records that are
+	// followed.
+	MaxRecursion int
+	// Concurrency is the maximum number of addresses resolved at the same time,
+	// across all dials. Zero means no limit.
+	Concurrency int
+	// Timeout is the time allowed for resolving a single address, including
+	// all its nested /dnsaddr records. Zero means no timeout other than the
+	// dial timeout.
+	Timeout time.Duration
+	// CacheTTL is the time resolved addresses are cached for. Zero disables
+	// caching.
+	CacheTTL time.Duration
+}
+
+// DefaultDNSResolutionConfig is the DNSResolutionConfig used unless
+// WithDNSResolutionConfig is used.
+var DefaultDNSResolutionConfig = DNSResolutionConfig{
+	MaxRecursion: maximumDNSADDRRecursion,
+}
+
+// WithDNSResolutionConfig configures the resolution of /dnsaddr and /dns
+// multiaddrs.
+func WithDNSResolutionConfig(cfg DNSResolutionConfig) Option {
+	return func(s *Swarm) error {
+		if cfg.MaxRecursion <= 0 {
+			return errors.New("swarm: dnsaddr recursion limit must be positive")
+		}
+		if cfg.Concurrency < 0 || cfg.Timeout < 0 || cfg.CacheTTL < 0 {
+			return errors.New("swarm: negative dns resolution config")
+		}
+		s.dnsConfig = cfg
+		return nil
+	}
+}
+
+type dnsCacheKey struct {
+	dnsaddr bool
+	peer    peer.ID
+	addr    string
+}
+
+type dnsCacheEntry struct {
+	addrs   []ma.Multiaddr
+	limit   int
+	expires time.Time
+}
+
+// dnsResolver applies the DNSResolutionConfig to a MultiaddrDNSResolver.
+type dnsResolver struct {
+	r   network.MultiaddrDNSResolver
+	cfg DNSResolutionConfig
+	mt  DNSMetricsTracer // nil if the metrics tracer doesn't track DNS resolutions
+	now func() time.Time
+
+	// sem is nil if the concurrency isn't limited.
+	sem chan struct{}
+
+	mx    sync.Mutex
+	cache map[dnsCacheKey]dnsCacheEntry
+}
+
+func newDNSResolver(r network.MultiaddrDNSResolver, cfg DNSResolutionConfig, mt MetricsTracer) *dnsResolver {
+	d := &dnsResolver{
+		r:     r,
+		cfg:   cfg,
+		now:   time.Now,
+		cache: make(map[dnsCacheKey]dnsCacheEntry),
+	}
+	if dmt, ok := mt.(DNSMetricsTracer); ok {
+		d.mt = dmt
+	}
+	if cfg.Concurrency > 0 {
+		d.sem = make(chan struct{}, cfg.Concurrency)
+	}
+	return d
+}
+
+func (d *dnsResolver) ResolveDNSAddr(ctx context.Context, p peer.ID, maddr ma.Multiaddr, outputLimit int) ([]ma.Multiaddr, error) {
+	return d.resolve(ctx, dnsCacheKey{dnsaddr: true, peer: p, addr: string(maddr.Bytes())}, outputLimit,
+		func(ctx context.Context) ([]ma.Multiaddr, error) {
+			return d.r.ResolveDNSAddr(ctx, p, maddr, d.cfg.MaxRecursion, outputLimit)
+		})
+}
+
+func (d *dnsResolver) ResolveDNSComponent(ctx context.Context, maddr ma.Multiaddr, outputLimit int) ([]ma.Multiaddr, error) {
+	return d.resolve(ctx, dnsCacheKey{addr: string(maddr.Bytes())}, outputLimit,
+		func(ctx context.Context) ([]ma.Multiaddr, error) {
+			return d.r.ResolveDNSComponent(ctx, maddr, outputLimit)
+		})
+}
+
+func (d *dnsResolver) resolve(ctx context.Context, key dnsCacheKey, outputLimit int, resolve func(context.Context) ([]ma.Multiaddr, error)) ([]ma.Multiaddr, error) {
+	if addrs, ok := d.cached(key, outputLimit); ok {
+		return addrs, nil
+	}
+
+	if d.sem != nil {
+		select {
+		case d.sem <- struct{}{}:
+			defer func() { <-d.sem }()
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if d.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.cfg.Timeout)
+		defer cancel()
+	}
+
+	start := d.now()
+	addrs, err := resolve(ctx)
+	if d.mt != nil {
+		d.mt.ResolvedDNS(key.dnsaddr, d.now().Sub(start), err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if d.cfg.CacheTTL > 0 {
+		d.store(key, slices.Clone(addrs), outputLimit)
+	}
+	return addrs, nil
+}
+
+func (d *dnsResolver) store(key dnsCacheKey, addrs []ma.Multiaddr, limit int) {
+	now := d.now()
+
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	for k, e := range d.cache {
+		if !now.Before(e.expires) {
+			delete(d.cache, k)
+		}
+	}
+	d.cache[key] = dnsCacheEntry{addrs: addrs, limit: limit, expires: now.Add(d.cfg.CacheTTL)}
+}
+
+// cached returns the cached resolution of key, if it is still valid and
+// contains up to outputLimit addresses.
+func (d *dnsResolver) cached(key dnsCacheKey, outputLimit int) ([]ma.Multiaddr, bool) {
+	if d.cfg.CacheTTL == 0 {
+		return nil, false
+	}
+	now := d.now()
+
+	d.mx.Lock()
+	e, ok := d.cache[key]
+	d.mx.Unlock()
+	if !ok || !now.Before(e.expires) {
+		return nil, false
+	}
+	// The cached resolution might have been truncated to a lower limit.
+	if e.limit < outputLimit && len(e.addrs) >= e.limit {
+		return nil, false
+	}
+	addrs := slices.Clone(e.addrs)
+	if len(addrs) > outputLimit {
+		addrs = addrs[:outputLimit]
+	}
+	return addrs, true
+}
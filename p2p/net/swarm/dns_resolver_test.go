@@ -0,0 +1,121 @@
+package swarm
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/test"
+
+	ma "github.com/multiformats/go-multiaddr"
+	madns "github.com/multiformats/go-multiaddr-dns"
+	"github.com/stretchr/testify/require"
+)
+
+type countingDNSResolver struct {
+	madns.BasicResolver
+	lookups atomic.Int32
+	block   chan struct{}
+}
+
+func (r *countingDNSResolver) LookupIPAddr(ctx context.Context, domain string) ([]net.IPAddr, error) {
+	r.lookups.Add(1)
+	if r.block != nil {
+		select {
+		case <-r.block:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return r.BasicResolver.LookupIPAddr(ctx, domain)
+}
+
+func (r *countingDNSResolver) LookupTXT(ctx context.Context, txt string) ([]string, error) {
+	r.lookups.Add(1)
+	return r.BasicResolver.LookupTXT(ctx, txt)
+}
+
+func TestDNSResolutionRecursionLimit(t *testing.T) {
+	p := test.RandPeerIDFatal(t)
+	backend := &madns.MockResolver{
+		TXT: map[string][]string{
+			"_dnsaddr.example.com":     {"dnsaddr=/dnsaddr/foo.example.com/p2p/" + p.String()},
+			"_dnsaddr.foo.example.com": {"dnsaddr=/ip4/192.0.2.1/tcp/123/p2p/" + p.String()},
+		},
+	}
+	resolver, err := madns.NewResolver(madns.WithDefaultResolver(backend))
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		recursion int
+		err       error
+	}{
+		{recursion: 1, err: ErrNoGoodAddresses},
+		{recursion: 2},
+	} {
+		s := newTestSwarmWithResolver(t, resolver, WithDNSResolutionConfig(DNSResolutionConfig{MaxRecursion: tc.recursion}))
+		s.Peerstore().AddAddr(p, ma.StringCast("/dnsaddr/example.com"), peerstore.TempAddrTTL)
+		_, _, err = s.addrsForDial(context.Background(), p)
+		require.Equal(t, tc.err, err)
+	}
+}
+
+func TestDNSResolutionCache(t *testing.T) {
+	backend := &countingDNSResolver{BasicResolver: &madns.MockResolver{
+		IP: map[string][]net.IPAddr{
+			"example.com": {{IP: net.IPv4(192, 0, 2, 1)}},
+		},
+	}}
+	resolver, err := madns.NewResolver(madns.WithDefaultResolver(backend))
+	require.NoError(t, err)
+
+	now := time.Now()
+	d := newDNSResolver(ResolverFromMaDNS{resolver}, DNSResolutionConfig{MaxRecursion: 1, CacheTTL: time.Minute}, nil)
+	d.now = func() time.Time { return now }
+
+	addr := ma.StringCast("/dns4/example.com/tcp/1")
+	for range 3 {
+		addrs, err := d.ResolveDNSComponent(context.Background(), addr, 10)
+		require.NoError(t, err)
+		require.Equal(t, []ma.Multiaddr{ma.StringCast("/ip4/192.0.2.1/tcp/1")}, addrs)
+	}
+	require.Equal(t, int32(1), backend.lookups.Load())
+
+	now = now.Add(time.Minute)
+	_, err = d.ResolveDNSComponent(context.Background(), addr, 10)
+	require.NoError(t, err)
+	require.Equal(t, int32(2), backend.lookups.Load())
+}
+
+func TestDNSResolutionTimeoutAndConcurrency(t *testing.T) {
+	backend := &countingDNSResolver{
+		BasicResolver: &madns.MockResolver{},
+		block:         make(chan struct{}),
+	}
+	resolver, err := madns.NewResolver(madns.WithDefaultResolver(backend))
+	require.NoError(t, err)
+
+	d := newDNSResolver(ResolverFromMaDNS{resolver}, DNSResolutionConfig{MaxRecursion: 1, Concurrency: 1, Timeout: 100 * time.Millisecond}, nil)
+	addr := ma.StringCast("/dns4/example.com/tcp/1")
+
+	errs := make(chan error, 2)
+	for range 2 {
+		go func() {
+			_, err := d.ResolveDNSComponent(context.Background(), addr, 10)
+			errs <- err
+		}()
+	}
+	for range 2 {
+		select {
+		case err := <-errs:
+			require.ErrorIs(t, err, context.DeadlineExceeded)
+		case <-time.After(5 * time.Second):
+			t.Fatal("resolution didn't time out")
+		}
+	}
+	// The resolutions weren't executed concurrently.
+	require.Equal(t, int32(2), backend.lookups.Load())
+}
@@ -2,6 +2,7 @@ package swarm
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"strconv"
 	"sync"
@@ -11,6 +12,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/transport"
 
 	ma "github.com/multiformats/go-multiaddr"
+	"golang.org/x/time/rate"
 )
 
 type dialJob struct {
@@ -19,6 +21,9 @@ type dialJob struct {
 	ctx     context.Context
 	resp    chan transport.DialUpdate
 	timeout time.Duration
+
+	// rateLimited is set once the dial got through the rate limiter
+	rateLimited bool
 }
 
 func (dj *dialJob) cancelled() bool {
@@ -37,18 +42,22 @@ type dialLimiter struct {
 	activePerPeer      map[peer.ID]int
 	perPeerLimit       int
 	waitingOnPeerLimit map[peer.ID][]*dialJob
+
+	// rateLimiter limits the rate of outbound dials. nil if dials aren't rate
+	// limited
+	rateLimiter *rate.Limiter
 }
 
 type dialfunc func(context.Context, peer.ID, ma.Multiaddr, chan<- transport.DialUpdate) (transport.CapableConn, error)
 
-func newDialLimiter(df dialfunc) *dialLimiter {
+func newDialLimiter(df dialfunc, perPeerLimit int) *dialLimiter {
 	fd := ConcurrentFdDials
 	if env := os.Getenv("LIBP2P_SWARM_FD_LIMIT"); env != "" {
 		if n, err := strconv.ParseInt(env, 10, 32); err == nil {
 			fd = int(n)
 		}
 	}
-	return newDialLimiterWithParams(df, fd, DefaultPerPeerRateLimit)
+	return newDialLimiterWithParams(df, fd, perPeerLimit)
 }
 
 func newDialLimiterWithParams(df dialfunc, fdLimit, perPeerLimit int) *dialLimiter {
@@ -117,7 +126,7 @@ func (dl *dialLimiter) freePeerToken(dj *dialJob) {
 
 		dl.activePerPeer[next.peer]++ // just kidding, we still want this token
 
-		dl.addCheckFdLimit(next)
+		dl.addCheckRateLimit(next)
 		return
 	}
 }
@@ -143,6 +152,49 @@ func (dl *dialLimiter) shouldConsumeFd(addr ma.Multiaddr) bool {
 	return !isRelay && isFdConsumingAddr(addr)
 }
 
+// addCheckRateLimit waits for the rate limiter before the dial job competes
+// for an FD token, so that rate limited dials don't hold FD tokens while they
+// wait. Like FDs, relay addresses don't consume the dial budget, the dial to the
+// relay server does.
+func (dl *dialLimiter) addCheckRateLimit(dj *dialJob) {
+	if dl.rateLimiter == nil || dj.rateLimited {
+		dl.addCheckFdLimit(dj)
+		return
+	}
+	if _, err := dj.addr.ValueForProtocol(ma.P_CIRCUIT); err == nil {
+		dl.addCheckFdLimit(dj)
+		return
+	}
+
+	log.Debugf("[limiter] dial waiting on rate limit; peer: %s; addr: %s", dj.peer, dj.addr)
+	go dl.waitForRateLimit(dl.rateLimiter, dj)
+}
+
+// waitForRateLimit blocks until the dial job is allowed by the rate limiter and
+// then queues it for an FD token. If the dial job is cancelled first, the
+// failure is reported through the response channel.
+func (dl *dialLimiter) waitForRateLimit(rl *rate.Limiter, dj *dialJob) {
+	if err := rl.Wait(dj.ctx); err != nil {
+		select {
+		case dj.resp <- transport.DialUpdate{
+			Kind: transport.UpdateKindDialFailed,
+			Addr: dj.addr,
+			Err:  fmt.Errorf("waiting for dial rate limit: %w", err),
+		}:
+		case <-dj.ctx.Done():
+		}
+		dl.lk.Lock()
+		defer dl.lk.Unlock()
+		dl.freePeerToken(dj)
+		return
+	}
+
+	dl.lk.Lock()
+	defer dl.lk.Unlock()
+	dj.rateLimited = true
+	dl.addCheckFdLimit(dj)
+}
+
 func (dl *dialLimiter) addCheckFdLimit(dj *dialJob) {
 	if dl.shouldConsumeFd(dj.addr) {
 		if dl.fdConsuming >= dl.fdLimit {
@@ -174,7 +226,7 @@ func (dl *dialLimiter) addCheckPeerLimit(dj *dialJob) {
 	}
 	dl.activePerPeer[dj.peer]++
 
-	dl.addCheckFdLimit(dj)
+	dl.addCheckRateLimit(dj)
 }
 
 // AddDialJob tries to take the needed tokens for starting the given dial job.
@@ -207,10 +259,7 @@ func (dl *dialLimiter) executeDial(j *dialJob) {
 		return
 	}
 
-	dctx, cancel := context.WithTimeout(j.ctx, j.timeout)
-	defer cancel()
-
-	con, err := dl.dialFunc(dctx, j.peer, j.addr, j.resp)
+	con, err := dl.dial(j)
 	kind := transport.UpdateKindDialSuccessful
 	if err != nil {
 		kind = transport.UpdateKindDialFailed
@@ -223,3 +272,14 @@ func (dl *dialLimiter) executeDial(j *dialJob) {
 		}
 	}
 }
+
+func (dl *dialLimiter) dial(j *dialJob) (transport.CapableConn, error) {
+	dctx, cancel := context.WithTimeout(j.ctx, j.timeout)
+	defer cancel()
+
+	con, err := dl.dialFunc(dctx, j.peer, j.addr, j.resp)
+	if err != nil && dctx.Err() == context.DeadlineExceeded && j.ctx.Err() == nil {
+		err = &AddrDialTimeoutError{Timeout: j.timeout, Cause: err}
+	}
+	return con, err
+}
@@ -13,9 +13,11 @@ import (
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/test"
 	"github.com/libp2p/go-libp2p/core/transport"
+	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
 
 	ma "github.com/multiformats/go-multiaddr"
 	mafmt "github.com/multiformats/go-multiaddr-fmt"
+	"golang.org/x/time/rate"
 )
 
 func addrWithPort(p int) ma.Multiaddr {
@@ -395,3 +397,123 @@ func TestFDLimitUnderflow(t *testing.T) {
 		t.Fatalf("l.fdConsuming < 0")
 	}
 }
+
+func TestLimiterDialTimeoutError(t *testing.T) {
+	df := func(ctx context.Context, _ peer.ID, _ ma.Multiaddr, _ chan<- transport.DialUpdate) (transport.CapableConn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	l := newDialLimiterWithParams(df, ConcurrentFdDials, 4)
+
+	res := make(chan transport.DialUpdate, 1)
+	l.AddDialJob(&dialJob{
+		ctx:     context.Background(),
+		peer:    test.RandPeerIDFatal(t),
+		addr:    addrWithPort(1),
+		resp:    res,
+		timeout: 10 * time.Millisecond,
+	})
+
+	select {
+	case r := <-res:
+		var terr *AddrDialTimeoutError
+		if !errors.As(r.Err, &terr) {
+			t.Fatalf("expected an AddrDialTimeoutError, got %v", r.Err)
+		}
+		if terr.Timeout != 10*time.Millisecond {
+			t.Fatalf("unexpected timeout: %s", terr.Timeout)
+		}
+		if !errors.Is(r.Err, context.DeadlineExceeded) {
+			t.Fatal("expected the error to wrap context.DeadlineExceeded")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("dial didn't time out")
+	}
+}
+
+func TestDialRateLimit(t *testing.T) {
+	df := func(_ context.Context, _ peer.ID, _ ma.Multiaddr, _ chan<- transport.DialUpdate) (transport.CapableConn, error) {
+		return nil, nil
+	}
+	l := newDialLimiterWithParams(df, ConcurrentFdDials, 4)
+	l.rateLimiter = rate.NewLimiter(20, 2)
+
+	const numDials = 6
+	res := make(chan transport.DialUpdate, numDials)
+	start := time.Now()
+	for i := 0; i < numDials; i++ {
+		l.AddDialJob(&dialJob{
+			ctx:     context.Background(),
+			peer:    test.RandPeerIDFatal(t),
+			addr:    addrWithPort(i + 1),
+			resp:    res,
+			timeout: time.Second,
+		})
+	}
+	for i := 0; i < numDials; i++ {
+		select {
+		case r := <-res:
+			if r.Err != nil {
+				t.Fatal(r.Err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("dial didn't complete")
+		}
+	}
+	// the burst is used up immediately, the other dials are spaced by 50ms
+	if took := time.Since(start); took < 150*time.Millisecond {
+		t.Fatalf("dials weren't rate limited, took %s", took)
+	}
+
+	// dials that can't get a token before their context expires fail
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	l.rateLimiter = rate.NewLimiter(0.1, 1)
+	l.rateLimiter.Allow()
+	l.AddDialJob(&dialJob{
+		ctx:     ctx,
+		peer:    test.RandPeerIDFatal(t),
+		addr:    addrWithPort(1),
+		resp:    res,
+		timeout: time.Second,
+	})
+	// dials waiting on the rate limit don't hold an FD token
+	l.lk.Lock()
+	fdConsuming := l.fdConsuming
+	l.lk.Unlock()
+	if fdConsuming != 0 {
+		t.Fatalf("expected no FD tokens to be taken, got %d", fdConsuming)
+	}
+	select {
+	case r := <-res:
+		if r.Err == nil {
+			t.Fatal("expected the dial to fail")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("dial didn't fail")
+	}
+}
+
+func TestPerPeerDialConcurrencyOption(t *testing.T) {
+	s, err := NewSwarm(test.RandPeerIDFatal(t), nil, eventbus.NewBus(), WithPerPeerDialConcurrency(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if s.limiter.perPeerLimit != 1 {
+		t.Fatalf("expected a per peer limit of 1, got %d", s.limiter.perPeerLimit)
+	}
+
+	s2, err := NewSwarm(test.RandPeerIDFatal(t), nil, eventbus.NewBus())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s2.Close()
+	if s2.limiter.perPeerLimit != DefaultPerPeerRateLimit {
+		t.Fatalf("expected the default per peer limit, got %d", s2.limiter.perPeerLimit)
+	}
+
+	if _, err := NewSwarm(test.RandPeerIDFatal(t), nil, eventbus.NewBus(), WithPerPeerDialConcurrency(0)); err == nil {
+		t.Fatal("expected an error")
+	}
+}
@@ -0,0 +1,82 @@
+package swarm
+
+import (
+	"errors"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// WithRelayCutover configures the swarm to close relayed connections to a peer
+// once a direct connection to the peer is established.
+//
+// New streams are always opened on the direct connection. The relayed
+// connection is closed once it has no open streams, which is checked every
+// idleTimeout, so that streams on it can finish gracefully. An
+// event.EvtRelayedConnCutover is emitted when a relayed connection is closed.
+func WithRelayCutover(idleTimeout time.Duration) Option {
+	return func(s *Swarm) error {
+		if idleTimeout <= 0 {
+			return errors.New("swarm: relay cutover idle timeout must be positive")
+		}
+		s.relayCutoverIdle = idleTimeout
+		return nil
+	}
+}
+
+// startRelayCutover starts draining the relayed connections to p.
+func (s *Swarm) startRelayCutover(p peer.ID) {
+	var relayed []*Conn
+	s.conns.RLock()
+	for _, c := range s.conns.m[p] {
+		if !isDirectConn(c) && c.draining.CompareAndSwap(false, true) {
+			relayed = append(relayed, c)
+		}
+	}
+	s.conns.RUnlock()
+
+	for _, c := range relayed {
+		s.refs.Add(1)
+		go s.drainRelayedConn(c)
+	}
+}
+
+func (s *Swarm) drainRelayedConn(c *Conn) {
+	defer s.refs.Done()
+
+	t := time.NewTimer(s.relayCutoverIdle)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-s.ctx.Done():
+			return
+		}
+		if c.IsClosed() {
+			return
+		}
+		direct := s.bestConnToPeer(c.RemotePeer())
+		if !isDirectConn(direct) {
+			// The direct connection is gone. Keep using the relayed connection.
+			c.draining.Store(false)
+			return
+		}
+		c.streams.Lock()
+		numStreams := len(c.streams.m)
+		c.streams.Unlock()
+		if numStreams > 0 {
+			t.Reset(s.relayCutoverIdle)
+			continue
+		}
+
+		log.Debugw("closing relayed connection after cutover", "peer", c.RemotePeer(), "relayed", c.RemoteMultiaddr(), "direct", direct.RemoteMultiaddr())
+		c.Close()
+		s.relayCutoverEmitter.Emit(event.EvtRelayedConnCutover{
+			Peer:        c.RemotePeer(),
+			RelayedConn: c,
+			DirectConn:  direct,
+		})
+		return
+	}
+}
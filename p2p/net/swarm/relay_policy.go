@@ -0,0 +1,57 @@
+package swarm
+
+import (
+	"context"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+// WithRelayPolicy sets the relay policy used for a peer, unless the dial
+// context sets one using network.WithRelayPolicy.
+func WithRelayPolicy(policy func(peer.ID) network.RelayPolicy) Option {
+	return func(s *Swarm) error {
+		s.relayPolicy = policy
+		return nil
+	}
+}
+
+// relayPolicyFor returns the relay policy that applies to connections to p.
+func (s *Swarm) relayPolicyFor(ctx context.Context, p peer.ID) network.RelayPolicy {
+	if policy := network.GetRelayPolicy(ctx); policy != network.RelayPolicyDefault {
+		return policy
+	}
+	if s.relayPolicy != nil {
+		return s.relayPolicy(p)
+	}
+	return network.RelayPolicyDefault
+}
+
+// relayPolicyAllowsConn reports whether c may be used under policy.
+func relayPolicyAllowsConn(policy network.RelayPolicy, c *Conn) bool {
+	switch policy {
+	case network.RelayPolicyForbid:
+		return isDirectConn(c)
+	case network.RelayPolicyRequire:
+		return !isDirectConn(c)
+	default:
+		return true
+	}
+}
+
+// filterRelayPolicy removes the addresses that may not be dialed under policy.
+func (s *Swarm) filterRelayPolicy(policy network.RelayPolicy, addrs []ma.Multiaddr) (goodAddrs []ma.Multiaddr, addrErrs []TransportError) {
+	if policy == network.RelayPolicyDefault {
+		return addrs, nil
+	}
+	goodAddrs = ma.FilterAddrs(addrs, func(a ma.Multiaddr) bool {
+		if s.nonProxyAddr(a) == (policy == network.RelayPolicyForbid) {
+			return true
+		}
+		addrErrs = append(addrErrs, TransportError{Address: a, Cause: ErrDialRefusedRelayPolicy})
+		return false
+	})
+	return goodAddrs, addrErrs
+}
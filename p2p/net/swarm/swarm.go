@@ -23,6 +23,7 @@ import (
 	logging "github.com/ipfs/go-log/v2"
 	ma "github.com/multiformats/go-multiaddr"
 	madns "github.com/multiformats/go-multiaddr-dns"
+	"golang.org/x/time/rate"
 )
 
 const (
@@ -97,6 +98,17 @@ func WithDialTimeoutLocal(t time.Duration) Option {
 	}
 }
 
+// WithDialTimeoutSchedule configures the timeout applied to each address dial.
+// It takes precedence over the timeouts set by WithDialTimeout and
+// WithDialTimeoutLocal for every address for which it returns a non-zero
+// timeout.
+func WithDialTimeoutSchedule(sched DialTimeoutSchedule) Option {
+	return func(s *Swarm) error {
+		s.dialTimeoutSchedule = sched
+		return nil
+	}
+}
+
 func WithResourceManager(m network.ResourceManager) Option {
 	return func(s *Swarm) error {
 		s.rcmgr = m
@@ -115,6 +127,71 @@ func WithDialRanker(d network.DialRanker) Option {
 	}
 }
 
+// WithPerPeerDialConcurrency sets the number of addresses of a single peer
+// that are dialed concurrently (default: DefaultPerPeerRateLimit). Setting it
+// to 1 dials the addresses one after another, which is useful on constrained
+// devices. Latency critical applications can use a higher value, usually
+// together with a dial ranker that doesn't delay dials, like
+// NoDelayDialRanker.
+func WithPerPeerDialConcurrency(n int) Option {
+	return func(s *Swarm) error {
+		if n < 1 {
+			return errors.New("swarm: per peer dial concurrency must be at least 1")
+		}
+		s.perPeerDialConcurrency = n
+		return nil
+	}
+}
+
+// WithDialRateLimit limits the rate of outbound dial attempts to dialsPerSecond,
+// allowing bursts of up to burst dials. Dials exceeding the limit wait until
+// they're allowed. This prevents bursts of dials, e.g. during DHT crawls or
+// when reconnecting to many peers, from exhausting the connection tracking
+// tables or NAT mappings of small routers.
+//
+// This is independent of the connection limits of the resource manager.
+func WithDialRateLimit(dialsPerSecond float64, burst int) Option {
+	return func(s *Swarm) error {
+		if dialsPerSecond <= 0 {
+			return errors.New("swarm: dial rate limit must be positive")
+		}
+		if burst < 1 {
+			return errors.New("swarm: dial rate limit burst must be at least 1")
+		}
+		s.dialRateLimiter = rate.NewLimiter(rate.Limit(dialsPerSecond), burst)
+		return nil
+	}
+}
+
+// WithBackoffPolicy configures the dial backoff. Zero durations are replaced
+// by their defaults.
+func WithBackoffPolicy(p BackoffPolicy) Option {
+	return func(s *Swarm) error {
+		if p.Jitter < 0 || p.Jitter > 1 {
+			return errors.New("swarm: backoff jitter must be between 0 and 1")
+		}
+		if p.Base < 0 || p.Coef < 0 || p.Max < 0 {
+			return errors.New("swarm: backoff durations cannot be negative")
+		}
+		s.backf.policy = &p
+		return nil
+	}
+}
+
+// WithPeerDialRanker configures swarm to use d to rank the addresses of the
+// peer being dialed. It takes precedence over the ranker configured using
+// WithDialRanker. DefaultDialRanker can be used by d for peers it has no
+// knowledge about.
+func WithPeerDialRanker(d network.PeerDialRanker) Option {
+	return func(s *Swarm) error {
+		if d == nil {
+			return errors.New("swarm: dial ranker cannot be nil")
+		}
+		s.peerDialRanker = d
+		return nil
+	}
+}
+
 // WithUDPBlackHoleSuccessCounter configures swarm to use the provided config for UDP black hole detection
 // n is the size of the sliding window used to evaluate black hole state
 // min is the minimum number of successes out of n required to not block requests
@@ -160,13 +237,18 @@ type Swarm struct {
 
 	emitter event.Emitter
 
+	// relayCutoverIdle is zero if relay cutover is disabled
+	relayCutoverIdle    time.Duration
+	relayCutoverEmitter event.Emitter
+
 	rcmgr network.ResourceManager
 
 	local peer.ID
 	peers peerstore.Peerstore
 
-	dialTimeout      time.Duration
-	dialTimeoutLocal time.Duration
+	dialTimeout         time.Duration
+	dialTimeoutLocal    time.Duration
+	dialTimeoutSchedule DialTimeoutSchedule
 
 	conns struct {
 		sync.RWMutex
@@ -187,6 +269,13 @@ type Swarm struct {
 		m map[network.Notifiee]struct{}
 	}
 
+	// streamNotifs are the Notifiees that implement StreamNotifiee. They have
+	// their own lock, as streams may be opened from within notifications.
+	streamNotifs struct {
+		sync.RWMutex
+		m map[network.StreamNotifiee]struct{}
+	}
+
 	directConnNotifs struct {
 		sync.Mutex
 		m map[peer.ID][]chan struct{}
@@ -198,6 +287,8 @@ type Swarm struct {
 	}
 
 	multiaddrResolver network.MultiaddrDNSResolver
+	dnsConfig         DNSResolutionConfig
+	dnsResolver       *dnsResolver
 
 	// stream handlers
 	streamh atomic.Pointer[network.StreamHandler]
@@ -215,7 +306,18 @@ type Swarm struct {
 	bwc           metrics.Reporter
 	metricsTracer MetricsTracer
 
-	dialRanker network.DialRanker
+	// bwTracker is nil if bandwidth accounting is disabled
+	bwTracker *bandwidthTracker
+	// dialLatencies is nil if latency aware dial ranking is disabled
+	dialLatencies *dialLatencyTracker
+
+	dialRateLimiter        *rate.Limiter
+	dialAttemptHandler     func(DialAttempt)
+	perPeerDialConcurrency int
+
+	dialRanker     network.DialRanker
+	peerDialRanker network.PeerDialRanker
+	relayPolicy    func(peer.ID) network.RelayPolicy
 
 	connectednessEventEmitter *connectednessEventEmitter
 	udpBHF                    *BlackHoleSuccessCounter
@@ -240,6 +342,7 @@ func NewSwarm(local peer.ID, peers peerstore.Peerstore, eventBus event.Bus, opts
 		dialTimeout:       defaultDialTimeout,
 		dialTimeoutLocal:  defaultDialTimeoutLocal,
 		multiaddrResolver: ResolverFromMaDNS{madns.DefaultResolver},
+		dnsConfig:         DefaultDNSResolutionConfig,
 		dialRanker:        DefaultDialRanker,
 
 		// A black hole is a binary property. On a network if UDP dials are blocked or there is
@@ -253,6 +356,7 @@ func NewSwarm(local peer.ID, peers peerstore.Peerstore, eventBus event.Bus, opts
 	s.listeners.m = make(map[transport.Listener]struct{})
 	s.transports.m = make(map[int]transport.Transport)
 	s.notifs.m = make(map[network.Notifiee]struct{})
+	s.streamNotifs.m = make(map[network.StreamNotifiee]struct{})
 	s.directConnNotifs.m = make(map[peer.ID][]chan struct{})
 	s.connectednessEventEmitter = newConnectednessEventEmitter(s.Connectedness, emitter)
 
@@ -264,10 +368,22 @@ func NewSwarm(local peer.ID, peers peerstore.Peerstore, eventBus event.Bus, opts
 	if s.rcmgr == nil {
 		s.rcmgr = &network.NullResourceManager{}
 	}
+	s.dnsResolver = newDNSResolver(s.multiaddrResolver, s.dnsConfig, s.metricsTracer)
+	if s.relayCutoverIdle > 0 {
+		s.relayCutoverEmitter, err = eventBus.Emitter(new(event.EvtRelayedConnCutover))
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	s.dsync = newDialSync(s.dialWorkerLoop)
 
-	s.limiter = newDialLimiter(s.dialAddr)
+	perPeerDialConcurrency := s.perPeerDialConcurrency
+	if perPeerDialConcurrency == 0 {
+		perPeerDialConcurrency = DefaultPerPeerRateLimit
+	}
+	s.limiter = newDialLimiter(s.dialAddr, perPeerDialConcurrency)
+	s.limiter.rateLimiter = s.dialRateLimiter
 	s.backf.init(s.ctx)
 
 	s.bhd = &blackHoleDetector{
@@ -276,6 +392,12 @@ func NewSwarm(local peer.ID, peers peerstore.Peerstore, eventBus event.Bus, opts
 		mt:       s.metricsTracer,
 		readOnly: s.readOnlyBHD,
 	}
+	if !s.readOnlyBHD && (s.udpBHF != nil || s.ipv6BHF != nil) {
+		s.bhd.emitter, err = eventBus.Emitter(new(event.EvtBlackHoleStateChanged))
+		if err != nil {
+			return nil, err
+		}
+	}
 	return s, nil
 }
 
@@ -329,6 +451,12 @@ func (s *Swarm) close() {
 	s.refs.Wait()
 	s.connectednessEventEmitter.Close()
 	s.emitter.Close()
+	if s.relayCutoverEmitter != nil {
+		s.relayCutoverEmitter.Close()
+	}
+	if s.bhd.emitter != nil {
+		s.bhd.emitter.Close()
+	}
 
 	// Now close out any transports (if necessary). Do this after closing
 	// all connections/listeners.
@@ -398,6 +526,12 @@ func (s *Swarm) addConn(tc transport.CapableConn, dir network.Direction) (*Conn,
 		s.peers.AddPubKey(p, pk)
 	}
 
+	// Record that we successfully dialed the address. Only address books that
+	// track sources care, and they only need to be updated once per address.
+	if abi, ok := s.peers.(peerstore.AddrBookInspector); ok && dir == network.DirOutbound && !dialedBefore(abi, p, addr) {
+		abi.AddAddrsWithSource(p, []ma.Multiaddr{addr}, peerstore.TempAddrTTL, peerstore.AddrSourceDial)
+	}
+
 	// Clear any backoffs
 	s.backf.Clear(p)
 
@@ -445,6 +579,10 @@ func (s *Swarm) addConn(tc transport.CapableConn, dir network.Direction) (*Conn,
 	c.notifyLk.Unlock()
 
 	c.start()
+
+	if s.relayCutoverIdle > 0 && isDirectConn(c) {
+		s.startRelayCutover(p)
+	}
 	return c, nil
 }
 
@@ -487,8 +625,9 @@ func (s *Swarm) NewStream(ctx context.Context, p peer.ID) (network.Stream, error
 	// TODO: Try all connections even if we get an error opening a stream on
 	// a non-closed connection.
 	numDials := 0
+	policy := s.relayPolicyFor(ctx, p)
 	for {
-		c := s.bestConnToPeer(p)
+		c := s.bestConnToPeerWithPolicy(p, policy)
 		if c == nil {
 			if nodial, _ := network.GetNoDial(ctx); !nodial {
 				numDials++
@@ -506,7 +645,9 @@ func (s *Swarm) NewStream(ctx context.Context, p peer.ID) (network.Stream, error
 		}
 
 		limitedAllowed, _ := network.GetAllowLimitedConn(ctx)
-		if !limitedAllowed && c.Stat().Limited {
+		// Relayed connections are usually limited. Requiring a relay implies
+		// accepting its limits.
+		if !limitedAllowed && policy != network.RelayPolicyRequire && c.Stat().Limited {
 			var err error
 			c, err = s.waitForDirectConn(ctx, p)
 			if err != nil {
@@ -625,6 +766,12 @@ func isBetterConn(a, b *Conn) bool {
 
 // bestConnToPeer returns the best connection to peer.
 func (s *Swarm) bestConnToPeer(p peer.ID) *Conn {
+	return s.bestConnToPeerWithPolicy(p, network.RelayPolicyDefault)
+}
+
+// bestConnToPeerWithPolicy returns the best connection to peer that may be
+// used under the relay policy.
+func (s *Swarm) bestConnToPeerWithPolicy(p peer.ID, policy network.RelayPolicy) *Conn {
 	// TODO: Prefer some transports over others.
 	// For now, prefers direct connections over Relayed connections.
 	// For tie-breaking, select the newest non-closed connection with the most streams.
@@ -637,6 +784,9 @@ func (s *Swarm) bestConnToPeer(p peer.ID) *Conn {
 			// We *will* garbage collect this soon anyways.
 			continue
 		}
+		if !relayPolicyAllowsConn(policy, c) {
+			continue
+		}
 		if best == nil || isBetterConn(c, best) {
 			best = c
 		}
@@ -646,9 +796,10 @@ func (s *Swarm) bestConnToPeer(p peer.ID) *Conn {
 
 // bestAcceptableConnToPeer returns the best acceptable connection, considering the passed in ctx.
 // If network.WithForceDirectDial is used, it only returns a direct connections, ignoring
-// any limited (relayed) connections to the peer.
+// any limited (relayed) connections to the peer. Connections that may not be
+// used under the relay policy are ignored.
 func (s *Swarm) bestAcceptableConnToPeer(ctx context.Context, p peer.ID) *Conn {
-	conn := s.bestConnToPeer(p)
+	conn := s.bestConnToPeerWithPolicy(p, s.relayPolicyFor(ctx, p))
 
 	forceDirect, _ := network.GetForceDirectDial(ctx)
 	if forceDirect && !isDirectConn(conn) {
@@ -736,6 +887,16 @@ func (s *Swarm) ClosePeer(p peer.ID) error {
 	}
 }
 
+// dialedBefore says if the address book already recorded that we dialed addr.
+func dialedBefore(abi peerstore.AddrBookInspector, p peer.ID, addr ma.Multiaddr) bool {
+	for _, r := range abi.InspectAddrs(p) {
+		if r.Addr.Equal(addr) {
+			return r.Source >= peerstore.AddrSourceDial
+		}
+	}
+	return false
+}
+
 // Peers returns a copy of the set of peers swarm is connected to.
 func (s *Swarm) Peers() []peer.ID {
 	s.conns.RLock()
@@ -758,6 +919,20 @@ func (s *Swarm) Backoff() *DialBackoff {
 	return &s.backf
 }
 
+// BlackHoleInfo returns the state of the swarm's UDP and IPv6 black hole
+// detectors. Dials to addresses that are black holed are refused with
+// ErrDialRefusedBlackHole.
+func (s *Swarm) BlackHoleInfo() []BlackHoleInfo {
+	return s.bhd.Info()
+}
+
+// ResetBlackHoleDetectors resets the swarm's black hole detectors, allowing
+// dials to all addresses until the detectors collected enough dial results
+// again. This is useful when the network changed.
+func (s *Swarm) ResetBlackHoleDetectors() {
+	s.bhd.Reset()
+}
+
 // notifyAll sends a signal to all Notifiees
 func (s *Swarm) notifyAll(notify func(network.Notifiee)) {
 	s.notifs.RLock()
@@ -767,11 +942,27 @@ func (s *Swarm) notifyAll(notify func(network.Notifiee)) {
 	s.notifs.RUnlock()
 }
 
+// notifyStreams runs the notification function on the Notifiees that want to
+// be notified about streams.
+func (s *Swarm) notifyStreams(notify func(network.StreamNotifiee)) {
+	s.streamNotifs.RLock()
+	for f := range s.streamNotifs.m {
+		notify(f)
+	}
+	s.streamNotifs.RUnlock()
+}
+
 // Notify signs up Notifiee to receive signals when events happen
 func (s *Swarm) Notify(f network.Notifiee) {
 	s.notifs.Lock()
 	s.notifs.m[f] = struct{}{}
 	s.notifs.Unlock()
+
+	if sf, ok := f.(network.StreamNotifiee); ok {
+		s.streamNotifs.Lock()
+		s.streamNotifs.m[sf] = struct{}{}
+		s.streamNotifs.Unlock()
+	}
 }
 
 // StopNotify unregisters Notifiee fromr receiving signals
@@ -779,6 +970,12 @@ func (s *Swarm) StopNotify(f network.Notifiee) {
 	s.notifs.Lock()
 	delete(s.notifs.m, f)
 	s.notifs.Unlock()
+
+	if sf, ok := f.(network.StreamNotifiee); ok {
+		s.streamNotifs.Lock()
+		delete(s.streamNotifs.m, sf)
+		s.streamNotifs.Unlock()
+	}
 }
 
 func (s *Swarm) removeConn(c *Conn) {
@@ -830,6 +1027,9 @@ type connWithMetrics struct {
 func wrapWithMetrics(capableConn transport.CapableConn, metricsTracer MetricsTracer, opened time.Time, dir network.Direction) *connWithMetrics {
 	c := &connWithMetrics{CapableConn: capableConn, opened: opened, dir: dir, metricsTracer: metricsTracer}
 	c.metricsTracer.OpenedConnection(c.dir, capableConn.RemotePublicKey(), capableConn.ConnState(), capableConn.LocalMultiaddr())
+	if ht, ok := c.metricsTracer.(HandshakeTimingsTracer); ok {
+		ht.HandshakeTimings(c.dir, c.Stat().Handshake, capableConn.ConnState(), capableConn.LocalMultiaddr())
+	}
 	return c
 }
 
@@ -862,6 +1062,36 @@ func (c *connWithMetrics) Stat() network.ConnStats {
 
 var _ network.ConnStat = &connWithMetrics{}
 
+func (c *connWithMetrics) SmoothedRTT() time.Duration {
+	if rc, ok := c.CapableConn.(network.ConnRTT); ok {
+		return rc.SmoothedRTT()
+	}
+	return 0
+}
+
+var _ network.ConnRTT = &connWithMetrics{}
+
+func (c *connWithMetrics) SupportsDatagrams() bool {
+	dc, ok := c.CapableConn.(network.DatagramConn)
+	return ok && dc.SupportsDatagrams()
+}
+
+func (c *connWithMetrics) SendDatagram(b []byte) error {
+	if dc, ok := c.CapableConn.(network.DatagramConn); ok {
+		return dc.SendDatagram(b)
+	}
+	return network.ErrDatagramsNotSupported
+}
+
+func (c *connWithMetrics) ReceiveDatagram(ctx context.Context) ([]byte, error) {
+	if dc, ok := c.CapableConn.(network.DatagramConn); ok {
+		return dc.ReceiveDatagram(ctx)
+	}
+	return nil, network.ErrDatagramsNotSupported
+}
+
+var _ network.DatagramConn = &connWithMetrics{}
+
 type ResolverFromMaDNS struct {
 	*madns.Resolver
 }
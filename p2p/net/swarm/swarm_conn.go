@@ -4,7 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	ic "github.com/libp2p/go-libp2p/core/crypto"
@@ -29,6 +33,9 @@ type Conn struct {
 
 	closeOnce sync.Once
 	err       error
+	// closeReason is the reason the connection was closed. It's set before
+	// the connection is closed.
+	closeReason network.DisconnectReason
 
 	notifyLk sync.Mutex
 
@@ -38,9 +45,18 @@ type Conn struct {
 	}
 
 	stat network.ConnStats
+
+	// draining is set while a relayed connection is being replaced by a
+	// direct connection
+	draining atomic.Bool
+
+	// bytesIn and bytesOut are only counted if bandwidth accounting is
+	// enabled.
+	bytesIn, bytesOut atomic.Uint64
 }
 
 var _ network.Conn = &Conn{}
+var _ network.DatagramConn = &Conn{}
 
 func (c *Conn) IsClosed() bool {
 	return c.conn.IsClosed()
@@ -59,6 +75,7 @@ func (c *Conn) ID() string {
 // notifications).
 func (c *Conn) Close() error {
 	c.closeOnce.Do(func() {
+		c.closeReason = network.DisconnectReasonLocalClose
 		c.doClose(0)
 	})
 	return c.err
@@ -66,11 +83,43 @@ func (c *Conn) Close() error {
 
 func (c *Conn) CloseWithError(errCode network.ConnErrorCode) error {
 	c.closeOnce.Do(func() {
+		switch errCode {
+		case network.ConnGarbageCollected:
+			c.closeReason = network.DisconnectReasonConnManager
+		case network.ConnResourceLimitExceeded:
+			c.closeReason = network.DisconnectReasonResourceLimit
+		default:
+			c.closeReason = network.DisconnectReasonLocalClose
+		}
 		c.doClose(errCode)
 	})
 	return c.err
 }
 
+// closeAfterError closes the connection after the underlying connection failed
+// with err.
+func (c *Conn) closeAfterError(err error) {
+	c.closeOnce.Do(func() {
+		c.closeReason = disconnectReasonFromError(err)
+		c.doClose(0)
+	})
+}
+
+// disconnectReasonFromError determines why the underlying connection failed.
+func disconnectReasonFromError(err error) network.DisconnectReason {
+	var connErr *network.ConnError
+	if errors.As(err, &connErr) && connErr.Remote {
+		return network.DisconnectReasonRemoteClose
+	}
+	if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+		return network.DisconnectReasonIdleTimeout
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, syscall.ECONNRESET) {
+		return network.DisconnectReasonRemoteClose
+	}
+	return network.DisconnectReasonError
+}
+
 func (c *Conn) doClose(errCode network.ConnErrorCode) {
 	c.swarm.removeConn(c)
 
@@ -89,7 +138,7 @@ func (c *Conn) doClose(errCode network.ConnErrorCode) {
 	// Send the connectedness event after closing the connection.
 	// This ensures that both remote connection close and local connection
 	// close events are sent after the underlying transport connection is closed.
-	c.swarm.connectednessEventEmitter.RemoveConn(c.RemotePeer())
+	c.swarm.connectednessEventEmitter.RemoveConn(c.RemotePeer(), c.closeReason, c.Stat())
 
 	// This is just for cleaning up state. The connection has already been closed.
 	// We *could* optimize this but it really isn't worth it.
@@ -117,6 +166,9 @@ func (c *Conn) removeStream(s *Stream) {
 	delete(c.streams.m, s)
 	c.streams.Unlock()
 	s.scope.Done()
+	c.swarm.notifyStreams(func(f network.StreamNotifiee) {
+		f.ClosedStream(c.swarm, s)
+	})
 }
 
 // listens for new streams.
@@ -126,10 +178,10 @@ func (c *Conn) removeStream(s *Stream) {
 func (c *Conn) start() {
 	go func() {
 		defer c.swarm.refs.Done()
-		defer c.Close()
 		for {
 			ts, err := c.conn.AcceptStream()
 			if err != nil {
+				c.closeAfterError(err)
 				return
 			}
 			scope, err := c.swarm.ResourceManager().OpenStream(c.RemotePeer(), network.DirInbound)
@@ -139,7 +191,7 @@ func (c *Conn) start() {
 			}
 			c.swarm.refs.Add(1)
 			go func() {
-				s, err := c.addStream(ts, network.DirInbound, scope)
+				s, err := c.addStream(ts, network.DirInbound, scope, network.StreamPriorityDefault)
 
 				// Don't defer this. We don't want to block
 				// swarm shutdown on the connection handler.
@@ -202,11 +254,42 @@ func (c *Conn) ConnState() network.ConnectionState {
 	return c.conn.ConnState()
 }
 
+// SupportsDatagrams returns true if the underlying transport connection
+// supports unreliable datagrams.
+func (c *Conn) SupportsDatagrams() bool {
+	dc, ok := c.conn.(network.DatagramConn)
+	return ok && dc.SupportsDatagrams()
+}
+
+// SendDatagram sends an unreliable datagram, if the underlying transport
+// connection supports it.
+func (c *Conn) SendDatagram(b []byte) error {
+	dc, ok := c.conn.(network.DatagramConn)
+	if !ok {
+		return network.ErrDatagramsNotSupported
+	}
+	return dc.SendDatagram(b)
+}
+
+// ReceiveDatagram receives an unreliable datagram, if the underlying
+// transport connection supports it.
+func (c *Conn) ReceiveDatagram(ctx context.Context) ([]byte, error) {
+	dc, ok := c.conn.(network.DatagramConn)
+	if !ok {
+		return nil, network.ErrDatagramsNotSupported
+	}
+	return dc.ReceiveDatagram(ctx)
+}
+
 // Stat returns metadata pertaining to this connection
 func (c *Conn) Stat() network.ConnStats {
 	c.streams.Lock()
-	defer c.streams.Unlock()
-	return c.stat
+	stat := c.stat
+	c.streams.Unlock()
+	if rc, ok := c.conn.(network.ConnRTT); ok {
+		stat.SmoothedRTT = rc.SmoothedRTT()
+	}
+	return stat
 }
 
 // NewStream returns a new Stream from this connection
@@ -244,10 +327,10 @@ func (c *Conn) openAndAddStream(ctx context.Context, scope network.StreamManagem
 	if err != nil {
 		return nil, err
 	}
-	return c.addStream(ts, network.DirOutbound, scope)
+	return c.addStream(ts, network.DirOutbound, scope, network.GetStreamPriority(ctx))
 }
 
-func (c *Conn) addStream(ts network.MuxedStream, dir network.Direction, scope network.StreamManagementScope) (*Stream, error) {
+func (c *Conn) addStream(ts network.MuxedStream, dir network.Direction, scope network.StreamManagementScope, prio network.StreamPriority) (*Stream, error) {
 	c.streams.Lock()
 	// Are we still online?
 	if c.streams.m == nil {
@@ -264,6 +347,7 @@ func (c *Conn) addStream(ts network.MuxedStream, dir network.Direction, scope ne
 		stat: network.Stats{
 			Direction: dir,
 			Opened:    time.Now(),
+			Priority:  prio,
 		},
 		id:                             c.swarm.nextStreamID.Add(1),
 		acceptStreamGoroutineCompleted: dir != network.DirInbound,
@@ -276,9 +360,19 @@ func (c *Conn) addStream(ts network.MuxedStream, dir network.Direction, scope ne
 	c.swarm.refs.Add(1)
 
 	c.streams.Unlock()
+	c.swarm.notifyStreams(func(f network.StreamNotifiee) {
+		f.OpenedStream(c.swarm, s)
+	})
 	return s, nil
 }
 
+// BytesTransferred returns the number of bytes received and sent on the
+// connection's streams. It returns zero if bandwidth accounting is disabled,
+// see WithBandwidthAccounting.
+func (c *Conn) BytesTransferred() (in, out uint64) {
+	return c.bytesIn.Load(), c.bytesOut.Load()
+}
+
 // GetStreams returns the streams associated with this connection.
 func (c *Conn) GetStreams() []network.Stream {
 	c.streams.Lock()
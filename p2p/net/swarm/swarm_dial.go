@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math/rand/v2"
 	"net/netip"
 	"strconv"
 	"sync"
@@ -66,6 +67,10 @@ var (
 	// ErrGaterDisallowedConnection is returned when the gater prevents us from
 	// forming a connection with a peer.
 	ErrGaterDisallowedConnection = errors.New("gater disallows connection to peer")
+
+	// ErrDialRefusedRelayPolicy is returned for addresses that aren't dialed
+	// because of the relay policy, see network.RelayPolicy.
+	ErrDialRefusedRelayPolicy = errors.New("dial refused because of relay policy")
 )
 
 // ErrQUICDraft29 wraps ErrNoTransport and provide a more meaningful error message
@@ -91,7 +96,7 @@ const DialAttempts = 1
 const ConcurrentFdDials = 160
 
 // DefaultPerPeerRateLimit is the number of concurrent outbound dials to make
-// per peer
+// per peer. Use WithPerPeerDialConcurrency to configure it per swarm.
 var DefaultPerPeerRateLimit = 8
 
 // DialBackoff is a type for tracking peer dial backoffs. Dialbackoff is used to
@@ -107,6 +112,8 @@ var DefaultPerPeerRateLimit = 8
 type DialBackoff struct {
 	entries map[peer.ID]map[string]*backoffAddr
 	lock    sync.RWMutex
+	// policy is nil if the default backoff parameters are used
+	policy *BackoffPolicy
 }
 
 type backoffAddr struct {
@@ -122,7 +129,8 @@ func (db *DialBackoff) init(ctx context.Context) {
 }
 
 func (db *DialBackoff) background(ctx context.Context) {
-	ticker := time.NewTicker(BackoffMax)
+	_, _, maxTime := db.params()
+	ticker := time.NewTicker(maxTime)
 	defer ticker.Stop()
 	for {
 		select {
@@ -153,6 +161,59 @@ var BackoffCoef = time.Second
 // BackoffMax is the maximum backoff time (default: 5m).
 var BackoffMax = time.Minute * 5
 
+// BackoffPolicy configures the dial backoff, see WithBackoffPolicy.
+type BackoffPolicy struct {
+	// Base is the duration of the first backoff (default: BackoffBase).
+	Base time.Duration
+	// Coef is the backoff coefficient (default: BackoffCoef).
+	Coef time.Duration
+	// Max is the maximum backoff time (default: BackoffMax).
+	Max time.Duration
+	// Jitter randomly varies the backoff time by up to the given fraction,
+	// e.g. 0.1 varies it by up to 10% in either direction. This avoids redialing
+	// peers in lockstep.
+	Jitter float64
+	// Exempt is called with the error of a failed dial. If it returns true, the
+	// address isn't added to backoff. This is useful for errors that don't tell
+	// anything about the peer, like resource manager denials.
+	Exempt func(error) bool
+}
+
+func (db *DialBackoff) params() (base, coef, maxTime time.Duration) {
+	base, coef, maxTime = BackoffBase, BackoffCoef, BackoffMax
+	if p := db.policy; p != nil {
+		if p.Base != 0 {
+			base = p.Base
+		}
+		if p.Coef != 0 {
+			coef = p.Coef
+		}
+		if p.Max != 0 {
+			maxTime = p.Max
+		}
+	}
+	return base, coef, maxTime
+}
+
+// backoffTime returns the backoff time after the given number of prior
+// backoffs, without jitter.
+func (db *DialBackoff) backoffTime(tries int) time.Duration {
+	base, coef, maxTime := db.params()
+	return min(base+coef*time.Duration(tries*tries), maxTime)
+}
+
+func (db *DialBackoff) jitter(d time.Duration) time.Duration {
+	if db.policy == nil || db.policy.Jitter == 0 {
+		return d
+	}
+	return d + time.Duration((2*rand.Float64()-1)*db.policy.Jitter*float64(d))
+}
+
+// exempt returns true if a failed dial with error err shouldn't add backoff.
+func (db *DialBackoff) exempt(err error) bool {
+	return db.policy != nil && db.policy.Exempt != nil && db.policy.Exempt(err)
+}
+
 // AddBackoff adds peer's address to backoff.
 //
 // Backoff is not exponential, it's quadratic and computed according to the
@@ -160,7 +221,8 @@ var BackoffMax = time.Minute * 5
 //
 //	BackoffBase + BakoffCoef * PriorBackoffs^2
 //
-// Where PriorBackoffs is the number of previous backoffs.
+// Where PriorBackoffs is the number of previous backoffs. The parameters can be
+// configured using WithBackoffPolicy.
 func (db *DialBackoff) AddBackoff(p peer.ID, addr ma.Multiaddr) {
 	saddr := string(addr.Bytes())
 	db.lock.Lock()
@@ -174,21 +236,18 @@ func (db *DialBackoff) AddBackoff(p peer.ID, addr ma.Multiaddr) {
 	if !ok {
 		bp[saddr] = &backoffAddr{
 			tries: 1,
-			until: time.Now().Add(BackoffBase),
+			until: time.Now().Add(db.jitter(db.backoffTime(0))),
 		}
 		return
 	}
 
-	backoffTime := BackoffBase + BackoffCoef*time.Duration(ba.tries*ba.tries)
-	if backoffTime > BackoffMax {
-		backoffTime = BackoffMax
-	}
-	ba.until = time.Now().Add(backoffTime)
+	ba.until = time.Now().Add(db.jitter(db.backoffTime(ba.tries)))
 	ba.tries++
 }
 
 // Clear removes a backoff record. Clients should call this after a
-// successful Dial.
+// successful Dial. Applications can also call it to redial a peer right away,
+// e.g. when they learn that the peer is reachable again.
 func (db *DialBackoff) Clear(p peer.ID) {
 	db.lock.Lock()
 	defer db.lock.Unlock()
@@ -202,11 +261,7 @@ func (db *DialBackoff) cleanup() {
 	for p, e := range db.entries {
 		good := false
 		for _, backoff := range e {
-			backoffTime := BackoffBase + BackoffCoef*time.Duration(backoff.tries*backoff.tries)
-			if backoffTime > BackoffMax {
-				backoffTime = BackoffMax
-			}
-			if now.Before(backoff.until.Add(backoffTime)) {
+			if now.Before(backoff.until.Add(db.backoffTime(backoff.tries))) {
 				good = true
 				break
 			}
@@ -310,6 +365,9 @@ func (s *Swarm) addrsForDial(ctx context.Context, p peer.ID) (goodAddrs []ma.Mul
 	if forceDirect, _ := network.GetForceDirectDial(ctx); forceDirect {
 		goodAddrs = ma.FilterAddrs(goodAddrs, s.nonProxyAddr)
 	}
+	var policyErrs []TransportError
+	goodAddrs, policyErrs = s.filterRelayPolicy(s.relayPolicyFor(ctx, p), goodAddrs)
+	addrErrs = append(addrErrs, policyErrs...)
 
 	if len(goodAddrs) == 0 {
 		return nil, addrErrs, ErrNoGoodAddresses
@@ -390,7 +448,7 @@ func (s *Swarm) resolveAddrs(ctx context.Context, pi peer.AddrInfo) []ma.Multiad
 	dnsAddrResolver := resolver{
 		canResolve: startsWithDNSADDR,
 		resolve: func(ctx context.Context, maddr ma.Multiaddr, outputLimit int) ([]ma.Multiaddr, error) {
-			return s.multiaddrResolver.ResolveDNSAddr(ctx, pi.ID, maddr, maximumDNSADDRRecursion, outputLimit)
+			return s.dnsResolver.ResolveDNSAddr(ctx, pi.ID, maddr, outputLimit)
 		},
 	}
 
@@ -445,11 +503,11 @@ func (s *Swarm) resolveAddrs(ctx context.Context, pi peer.AddrInfo) []ma.Multiad
 		},
 	}
 
-	dnsResolver := resolver{
+	dnsComponentResolver := resolver{
 		canResolve: startsWithDNSComponent,
-		resolve:    s.multiaddrResolver.ResolveDNSComponent,
+		resolve:    s.dnsResolver.ResolveDNSComponent,
 	}
-	addrs, errs := chainResolvers(ctx, pi.Addrs, maximumResolvedAddresses, []resolver{dnsAddrResolver, skipResolver, tptResolver, dnsResolver})
+	addrs, errs := chainResolvers(ctx, pi.Addrs, maximumResolvedAddresses, []resolver{dnsAddrResolver, skipResolver, tptResolver, dnsComponentResolver})
 	for _, err := range errs {
 		log.Warnf("Failed to resolve addr %s: %v", err.addr, err.err)
 	}
@@ -566,10 +624,7 @@ func (s *Swarm) filterKnownUndialables(p peer.ID, addrs []ma.Multiaddr) (goodAdd
 // it is able, respecting the various different types of rate
 // limiting that occur without using extra goroutines per addr
 func (s *Swarm) limitedDial(ctx context.Context, p peer.ID, a ma.Multiaddr, resp chan transport.DialUpdate) {
-	timeout := s.dialTimeout
-	if manet.IsPrivateAddr(a) && s.dialTimeoutLocal < s.dialTimeout {
-		timeout = s.dialTimeoutLocal
-	}
+	timeout := s.dialTimeoutForAddr(a)
 	s.limiter.AddDialJob(&dialJob{
 		addr:    a,
 		peer:    p,
@@ -579,6 +634,20 @@ func (s *Swarm) limitedDial(ctx context.Context, p peer.ID, a ma.Multiaddr, resp
 	})
 }
 
+// dialTimeoutForAddr returns the timeout for a single dial to a.
+func (s *Swarm) dialTimeoutForAddr(a ma.Multiaddr) time.Duration {
+	if s.dialTimeoutSchedule != nil {
+		if t := s.dialTimeoutSchedule(a); t > 0 {
+			return t
+		}
+	}
+	timeout := s.dialTimeout
+	if manet.IsPrivateAddr(a) && s.dialTimeoutLocal < s.dialTimeout {
+		timeout = s.dialTimeoutLocal
+	}
+	return timeout
+}
+
 // dialAddr is the actual dial for an addr, indirectly invoked through the limiter
 func (s *Swarm) dialAddr(ctx context.Context, p peer.ID, addr ma.Multiaddr, updCh chan<- transport.DialUpdate) (transport.CapableConn, error) {
 	// Just to double check. Costs nothing.
@@ -5,6 +5,7 @@ import (
 	"context"
 	"crypto/rand"
 	"errors"
+	"fmt"
 	"net"
 	"sort"
 	"testing"
@@ -15,6 +16,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/peerstore"
 	"github.com/libp2p/go-libp2p/core/test"
+	"github.com/libp2p/go-libp2p/core/transport"
 	"github.com/libp2p/go-libp2p/p2p/host/eventbus"
 	"github.com/libp2p/go-libp2p/p2p/host/peerstore/pstoremem"
 	libp2pquic "github.com/libp2p/go-libp2p/p2p/transport/quic"
@@ -118,7 +120,7 @@ func TestDedupAddrsForDial(t *testing.T) {
 	require.Len(t, mas, 1)
 }
 
-func newTestSwarmWithResolver(t *testing.T, resolver *madns.Resolver) *Swarm {
+func newTestSwarmWithResolver(t *testing.T, resolver *madns.Resolver, opts ...Option) *Swarm {
 	priv, _, err := crypto.GenerateEd25519Key(rand.Reader)
 	require.NoError(t, err)
 	id, err := peer.IDFromPrivateKey(priv)
@@ -128,7 +130,7 @@ func newTestSwarmWithResolver(t *testing.T, resolver *madns.Resolver) *Swarm {
 	ps.AddPubKey(id, priv.GetPublic())
 	ps.AddPrivKey(id, priv)
 	t.Cleanup(func() { ps.Close() })
-	s, err := NewSwarm(id, ps, eventbus.NewBus(), WithMultiaddrResolver(ResolverFromMaDNS{resolver}))
+	s, err := NewSwarm(id, ps, eventbus.NewBus(), append([]Option{WithMultiaddrResolver(ResolverFromMaDNS{resolver})}, opts...)...)
 	require.NoError(t, err)
 	t.Cleanup(func() {
 		s.Close()
@@ -279,6 +281,55 @@ func TestAddrResolutionRecursiveTransportSpecific(t *testing.T) {
 	require.Equal(t, "/ip4/1.2.3.4/tcp/443/tls/sni/sub.example.com/ws", addrs[0].String())
 }
 
+// relayTransport is a proxy transport that can't dial.
+type relayTransport struct{ transport.Transport }
+
+func (*relayTransport) CanDial(a ma.Multiaddr) bool { return isRelayAddr(a) }
+func (*relayTransport) Protocols() []int            { return []int{ma.P_CIRCUIT} }
+func (*relayTransport) Proxy() bool                 { return true }
+func (*relayTransport) Close() error                { return nil }
+
+func TestAddrsForDialRelayPolicy(t *testing.T) {
+	resolver, err := madns.NewResolver(madns.WithDefaultResolver(&madns.MockResolver{}))
+	require.NoError(t, err)
+
+	p := test.RandPeerIDFatal(t)
+	relayed := test.RandPeerIDFatal(t)
+	direct := ma.StringCast("/ip4/1.2.3.4/tcp/1")
+	relay := ma.StringCast("/ip4/1.2.3.5/tcp/1/p2p/" + p.String() + "/p2p-circuit")
+
+	s := newTestSwarmWithResolver(t, resolver, WithRelayPolicy(func(id peer.ID) network.RelayPolicy {
+		if id == relayed {
+			return network.RelayPolicyRequire
+		}
+		return network.RelayPolicyDefault
+	}))
+	require.NoError(t, s.AddTransport(&relayTransport{}))
+
+	for _, id := range []peer.ID{p, relayed} {
+		s.Peerstore().AddAddrs(id, []ma.Multiaddr{direct, relay}, peerstore.PermanentAddrTTL)
+	}
+
+	ctx := context.Background()
+	addrs, _, err := s.addrsForDial(ctx, p)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []ma.Multiaddr{direct, relay}, addrs)
+
+	addrs, addrErrs, err := s.addrsForDial(network.WithRelayPolicy(ctx, network.RelayPolicyForbid), p)
+	require.NoError(t, err)
+	require.Equal(t, []ma.Multiaddr{direct}, addrs)
+	require.Len(t, addrErrs, 1)
+	require.ErrorIs(t, addrErrs[0].Cause, ErrDialRefusedRelayPolicy)
+
+	// The peer's policy applies unless the context sets one.
+	addrs, _, err = s.addrsForDial(ctx, relayed)
+	require.NoError(t, err)
+	require.Equal(t, []ma.Multiaddr{relay}, addrs)
+	addrs, _, err = s.addrsForDial(network.WithRelayPolicy(ctx, network.RelayPolicyForbid), relayed)
+	require.NoError(t, err)
+	require.Equal(t, []ma.Multiaddr{direct}, addrs)
+}
+
 func TestAddrsForDialFiltering(t *testing.T) {
 	q1 := ma.StringCast("/ip4/1.2.3.4/udp/1/quic-v1")
 	q1v1 := ma.StringCast("/ip4/1.2.3.4/udp/1/quic-v1")
@@ -429,3 +480,39 @@ func TestSkipDialingManyDNS(t *testing.T) {
 	require.NoError(t, err)
 	require.Less(t, len(resolved), 3, "got: %v", resolved)
 }
+
+func TestBackoffPolicy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	db := &DialBackoff{policy: &BackoffPolicy{
+		Base:   time.Minute,
+		Coef:   time.Minute,
+		Max:    3 * time.Minute,
+		Jitter: 0.5,
+		Exempt: func(err error) bool { return errors.Is(err, network.ErrResourceLimitExceeded) },
+	}}
+	db.init(ctx)
+
+	require.Equal(t, time.Minute, db.backoffTime(0))
+	require.Equal(t, 2*time.Minute, db.backoffTime(1))
+	require.Equal(t, 3*time.Minute, db.backoffTime(2))
+
+	p := test.RandPeerIDFatal(t)
+	addr := ma.StringCast("/ip4/1.2.3.4/tcp/1234")
+	for i := 0; i < 3; i++ {
+		before := time.Now()
+		db.AddBackoff(p, addr)
+		require.True(t, db.Backoff(p, addr))
+		d := db.entries[p][string(addr.Bytes())].until.Sub(before)
+		require.GreaterOrEqual(t, d, db.backoffTime(i)/2)
+		require.LessOrEqual(t, d, db.backoffTime(i)*3/2+time.Second)
+	}
+	db.Clear(p)
+	require.False(t, db.Backoff(p, addr))
+
+	require.True(t, db.exempt(fmt.Errorf("dial failed: %w", network.ErrResourceLimitExceeded)))
+	require.False(t, db.exempt(errors.New("connection refused")))
+
+	_, err := NewSwarm(test.RandPeerIDFatal(t), nil, eventbus.NewBus(), WithBackoffPolicy(BackoffPolicy{Jitter: 2}))
+	require.Error(t, err)
+}
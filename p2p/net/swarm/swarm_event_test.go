@@ -136,7 +136,7 @@ func TestConnectednessEvents(t *testing.T) {
 				return
 			}
 			if evt.Connectedness != network.Connected {
-				t.Errorf("invalid event received: expected: Connected, got: %s", evt)
+				t.Errorf("invalid event received: expected: Connected, got: %v", evt)
 				return
 			}
 		}
@@ -164,7 +164,7 @@ func TestConnectednessEvents(t *testing.T) {
 				return
 			}
 			if evt.Connectedness != network.NotConnected {
-				t.Errorf("invalid event received: expected: NotConnected, got: %s", evt)
+				t.Errorf("invalid event received: expected: NotConnected, got: %v", evt)
 				return
 			}
 		}
@@ -191,7 +191,7 @@ func TestConnectednessEvents(t *testing.T) {
 				return
 			}
 			if evt.Connectedness != network.NotConnected {
-				t.Errorf("invalid event received: expected: NotConnected, got: %s", evt)
+				t.Errorf("invalid event received: expected: NotConnected, got: %v", evt)
 				return
 			}
 		}
@@ -308,3 +308,64 @@ func TestConnectednessEventDeadlockWithDial(t *testing.T) {
 	close(done)
 	subWG.Wait()
 }
+
+func TestConnectednessEventReasons(t *testing.T) {
+	getEvent := func(t *testing.T, sub event.Subscription) event.EvtPeerConnectednessChanged {
+		t.Helper()
+		select {
+		case ev := <-sub.Out():
+			return ev.(event.EvtPeerConnectednessChanged)
+		case <-time.After(time.Second):
+			t.Fatal("didn't get PeerConnectedness event")
+		}
+		return event.EvtPeerConnectednessChanged{}
+	}
+
+	for _, tc := range []struct {
+		name         string
+		close        func(network.Conn) error
+		localReason  network.DisconnectReason
+		remoteReason network.DisconnectReason
+	}{
+		{
+			name:         "local close",
+			close:        func(c network.Conn) error { return c.Close() },
+			localReason:  network.DisconnectReasonLocalClose,
+			remoteReason: network.DisconnectReasonRemoteClose,
+		},
+		{
+			name:         "connection manager",
+			close:        func(c network.Conn) error { return c.CloseWithError(network.ConnGarbageCollected) },
+			localReason:  network.DisconnectReasonConnManager,
+			remoteReason: network.DisconnectReasonRemoteClose,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s1, sub1 := newSwarmWithSubscription(t)
+			s2, sub2 := newSwarmWithSubscription(t)
+
+			var tcpAddrs []ma.Multiaddr
+			for _, a := range s2.ListenAddresses() {
+				if _, err := a.ValueForProtocol(ma.P_TCP); err == nil {
+					tcpAddrs = append(tcpAddrs, a)
+				}
+			}
+			s1.Peerstore().AddAddrs(s2.LocalPeer(), tcpAddrs, time.Hour)
+			c, err := s1.DialPeer(context.Background(), s2.LocalPeer())
+			require.NoError(t, err)
+			require.Equal(t, network.Connected, getEvent(t, sub1).Connectedness)
+			require.Equal(t, network.Connected, getEvent(t, sub2).Connectedness)
+
+			require.NoError(t, tc.close(c))
+			evt := getEvent(t, sub1)
+			require.Equal(t, network.NotConnected, evt.Connectedness)
+			require.Equal(t, tc.localReason, evt.Reason)
+			require.Equal(t, network.DirOutbound, evt.LastConnStats.Direction)
+
+			evt = getEvent(t, sub2)
+			require.Equal(t, network.NotConnected, evt.Connectedness)
+			require.Equal(t, tc.remoteReason, evt.Reason, "got %s", evt.Reason)
+			require.Equal(t, network.DirInbound, evt.LastConnStats.Direction)
+		})
+	}
+}
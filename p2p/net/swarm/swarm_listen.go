@@ -22,6 +22,14 @@ type OrderedListener interface {
 	ListenOrder() int
 }
 
+// ListenCloser is implemented by networks that can stop listening on
+// addresses, like the Swarm.
+type ListenCloser interface {
+	ListenClose(addrs ...ma.Multiaddr)
+}
+
+var _ ListenCloser = &Swarm{}
+
 // Listen sets up listeners for all of the given addresses.
 // It returns as long as we successfully listen on at least *one* address.
 func (s *Swarm) Listen(addrs ...ma.Multiaddr) error {
@@ -3,8 +3,6 @@ package swarm
 import (
 	"context"
 	"errors"
-	"net"
-	"strings"
 	"time"
 
 	"github.com/libp2p/go-libp2p/core/crypto"
@@ -69,6 +67,15 @@ var (
 		},
 		[]string{"transport", "security", "muxer", "early_muxer", "ip_version"},
 	)
+	handshakeStageLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Name:      "handshake_stage_latency_seconds",
+			Help:      "Duration of each stage of connection establishment",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 1.3, 35),
+		},
+		[]string{"dir", "stage", "transport", "ip_version"},
+	)
 	dialsPerPeer = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Namespace: metricNamespace,
@@ -94,6 +101,15 @@ var (
 			Buckets:   []float64{0.001, 0.01, 0.05, 0.1, 0.2, 0.3, 0.4, 0.5, 0.75, 1, 2},
 		},
 	)
+	dnsResolutionLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Name:      "dns_resolution_latency_seconds",
+			Help:      "time taken to resolve a dns or dnsaddr multiaddr",
+			Buckets:   []float64{0.001, 0.01, 0.05, 0.1, 0.2, 0.3, 0.4, 0.5, 0.75, 1, 2, 5, 10},
+		},
+		[]string{"type", "outcome"},
+	)
 	blackHoleSuccessCounterState = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Namespace: metricNamespace,
@@ -125,9 +141,11 @@ var (
 		dialError,
 		connDuration,
 		connHandshakeLatency,
+		handshakeStageLatency,
 		dialsPerPeer,
 		dialRankingDelay,
 		dialLatency,
+		dnsResolutionLatency,
 		blackHoleSuccessCounterSuccessFraction,
 		blackHoleSuccessCounterState,
 		blackHoleSuccessCounterNextRequestAllowedAfter,
@@ -144,9 +162,23 @@ type MetricsTracer interface {
 	UpdatedBlackHoleSuccessCounter(name string, state BlackHoleState, nextProbeAfter int, successFraction float64)
 }
 
+// HandshakeTimingsTracer can be implemented by a MetricsTracer to be notified
+// of the time spent in each stage of establishing a connection.
+type HandshakeTimingsTracer interface {
+	HandshakeTimings(network.Direction, network.HandshakeTimings, network.ConnectionState, ma.Multiaddr)
+}
+
+// DNSMetricsTracer can be implemented by a MetricsTracer to be notified of
+// the latency and outcome of dns and dnsaddr resolutions.
+type DNSMetricsTracer interface {
+	ResolvedDNS(dnsaddr bool, latency time.Duration, err error)
+}
+
 type metricsTracer struct{}
 
 var _ MetricsTracer = &metricsTracer{}
+var _ HandshakeTimingsTracer = &metricsTracer{}
+var _ DNSMetricsTracer = &metricsTracer{}
 
 type metricsTracerSetting struct {
 	reg prometheus.Registerer
@@ -226,31 +258,36 @@ func (m *metricsTracer) CompletedHandshake(t time.Duration, cs network.Connectio
 	connHandshakeLatency.WithLabelValues(*tags...).Observe(t.Seconds())
 }
 
-func (m *metricsTracer) FailedDialing(addr ma.Multiaddr, dialErr error, cause error) {
-	transport := metricshelper.GetTransport(addr)
-	e := "other"
-	// dial deadline exceeded or the the parent contexts deadline exceeded
-	if errors.Is(dialErr, context.DeadlineExceeded) || errors.Is(cause, context.DeadlineExceeded) {
-		e = "deadline"
-	} else if errors.Is(dialErr, context.Canceled) {
-		// dial was cancelled.
-		if errors.Is(cause, context.Canceled) {
-			// parent context was canceled
-			e = "application canceled"
-		} else if errors.Is(cause, errConcurrentDialSuccessful) {
-			e = "canceled: concurrent dial successful"
-		} else {
-			// something else
-			e = "canceled: other"
-		}
-	} else {
-		nerr, ok := dialErr.(net.Error)
-		if ok && nerr.Timeout() {
-			e = "timeout"
-		} else if strings.Contains(dialErr.Error(), "connect: connection refused") {
-			e = "connection refused"
+func (m *metricsTracer) HandshakeTimings(dir network.Direction, ht network.HandshakeTimings, cs network.ConnectionState, laddr ma.Multiaddr) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	transport := cs.Transport
+	if transport == "" {
+		transport = "unknown"
+	}
+	for _, stage := range [...]struct {
+		name string
+		d    time.Duration
+	}{
+		{"connect", ht.Connect},
+		{"security", ht.Security},
+		{"muxer", ht.Muxer},
+	} {
+		// Stages that weren't observed, e.g. the security handshake of a QUIC
+		// connection, are reported as part of another stage.
+		if stage.d == 0 {
+			continue
 		}
+		*tags = (*tags)[:0]
+		*tags = append(*tags, metricshelper.GetDirection(dir), stage.name, transport, metricshelper.GetIPVersion(laddr))
+		handshakeStageLatency.WithLabelValues(*tags...).Observe(stage.d.Seconds())
 	}
+}
+
+func (m *metricsTracer) FailedDialing(addr ma.Multiaddr, dialErr error, cause error) {
+	transport := metricshelper.GetTransport(addr)
+	e := dialErrorClass(dialErr, cause)
 
 	tags := metricshelper.GetStringSlice()
 	defer metricshelper.PutStringSlice(tags)
@@ -285,6 +322,26 @@ func (m *metricsTracer) DialRankingDelay(d time.Duration) {
 	dialRankingDelay.Observe(d.Seconds())
 }
 
+func (m *metricsTracer) ResolvedDNS(dnsaddr bool, latency time.Duration, err error) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	if dnsaddr {
+		*tags = append(*tags, "dnsaddr")
+	} else {
+		*tags = append(*tags, "dns")
+	}
+	switch {
+	case err == nil:
+		*tags = append(*tags, "success")
+	case errors.Is(err, context.DeadlineExceeded):
+		*tags = append(*tags, "timeout")
+	default:
+		*tags = append(*tags, "failed")
+	}
+	dnsResolutionLatency.WithLabelValues(*tags...).Observe(latency.Seconds())
+}
+
 func (m *metricsTracer) UpdatedBlackHoleSuccessCounter(name string, state BlackHoleState,
 	nextProbeAfter int, successFraction float64) {
 	tags := metricshelper.GetStringSlice()
@@ -91,9 +91,19 @@ func TestMetricsNoAllocNoCover(t *testing.T) {
 		"CompletedHandshake": func() {
 			mt.CompletedHandshake(time.Duration(mrand.Intn(100))*time.Second, randItem(connections), randItem(addrs))
 		},
+		"HandshakeTimings": func() {
+			mt.(HandshakeTimingsTracer).HandshakeTimings(randItem(directions), network.HandshakeTimings{
+				Connect:  time.Duration(mrand.Intn(100)) * time.Millisecond,
+				Security: time.Duration(mrand.Intn(100)) * time.Millisecond,
+				Muxer:    time.Duration(mrand.Intn(100)) * time.Millisecond,
+			}, randItem(connections), randItem(addrs))
+		},
 		"FailedDialing":    func() { mt.FailedDialing(randItem(addrs), randItem(errors), randItem(errors)) },
 		"DialCompleted":    func() { mt.DialCompleted(mrand.Intn(2) == 1, mrand.Intn(10), time.Duration(mrand.Intn(1000_000_000))) },
 		"DialRankingDelay": func() { mt.DialRankingDelay(time.Duration(mrand.Intn(1e10))) },
+		"ResolvedDNS": func() {
+			mt.(DNSMetricsTracer).ResolvedDNS(mrand.Intn(2) == 1, time.Duration(mrand.Intn(1e10)), randItem(errors))
+		},
 		"UpdatedBlackHoleSuccessCounter": func() {
 			mt.UpdatedBlackHoleSuccessCounter(
 				randItem(bhfNames),
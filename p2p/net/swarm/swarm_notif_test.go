@@ -8,6 +8,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	. "github.com/libp2p/go-libp2p/p2p/net/swarm"
+	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
 
 	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr/net"
@@ -155,6 +156,48 @@ func TestNotifications(t *testing.T) {
 	}
 }
 
+type streamNotifiee struct {
+	network.NoopNotifiee
+	opened, closed chan network.Stream
+}
+
+func (n *streamNotifiee) OpenedStream(_ network.Network, s network.Stream) { n.opened <- s }
+func (n *streamNotifiee) ClosedStream(_ network.Network, s network.Stream) { n.closed <- s }
+
+func TestStreamNotifications(t *testing.T) {
+	swarms := makeSwarms(t, 2, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC)
+	defer func() {
+		for _, s := range swarms {
+			s.Close()
+		}
+	}()
+	n := &streamNotifiee{opened: make(chan network.Stream, 10), closed: make(chan network.Stream, 10)}
+	swarms[0].Notify(n)
+	connectSwarms(t, context.Background(), swarms)
+
+	str, err := swarms[0].NewStream(context.Background(), swarms[1].LocalPeer())
+	require.NoError(t, err)
+	select {
+	case s := <-n.opened:
+		require.Equal(t, str, s)
+	case <-time.After(time.Second):
+		t.Fatal("expected an opened stream notification")
+	}
+	require.NoError(t, str.Reset())
+	select {
+	case s := <-n.closed:
+		require.Equal(t, str, s)
+	case <-time.After(time.Second):
+		t.Fatal("expected a closed stream notification")
+	}
+
+	swarms[0].StopNotify(n)
+	str, err = swarms[0].NewStream(context.Background(), swarms[1].LocalPeer())
+	require.NoError(t, err)
+	str.Reset()
+	require.Empty(t, n.opened)
+}
+
 type netNotifiee struct {
 	listen       chan ma.Multiaddr
 	listenClose  chan ma.Multiaddr
@@ -61,6 +61,10 @@ func (s *Stream) Read(p []byte) (int, error) {
 		s.conn.swarm.bwc.LogRecvMessage(int64(n))
 		s.conn.swarm.bwc.LogRecvMessageStream(int64(n), s.Protocol(), s.Conn().RemotePeer())
 	}
+	if bwt := s.conn.swarm.bwTracker; bwt != nil && n > 0 {
+		s.conn.bytesIn.Add(uint64(n))
+		bwt.Add(s.conn.RemotePeer(), uint64(n), 0)
+	}
 	return n, err
 }
 
@@ -72,6 +76,10 @@ func (s *Stream) Write(p []byte) (int, error) {
 		s.conn.swarm.bwc.LogSentMessage(int64(n))
 		s.conn.swarm.bwc.LogSentMessageStream(int64(n), s.Protocol(), s.Conn().RemotePeer())
 	}
+	if bwt := s.conn.swarm.bwTracker; bwt != nil && n > 0 {
+		s.conn.bytesOut.Add(uint64(n))
+		bwt.Add(s.conn.RemotePeer(), 0, uint64(n))
+	}
 	return n, err
 }
 
@@ -20,10 +20,12 @@ import (
 	"github.com/libp2p/go-libp2p/core/test"
 	"github.com/libp2p/go-libp2p/p2p/net/swarm"
 	. "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
+	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
 
 	logging "github.com/ipfs/go-log/v2"
 	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr/net"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 )
@@ -364,6 +366,94 @@ func TestNoDial(t *testing.T) {
 	}
 }
 
+func TestDatagramsNotSupported(t *testing.T) {
+	sw1 := GenSwarm(t, OptDisableQUIC, OptDisableWebTransport)
+	sw2 := GenSwarm(t, OptDisableQUIC, OptDisableWebTransport)
+	sw1.Peerstore().AddAddrs(sw2.LocalPeer(), sw2.ListenAddresses(), peerstore.PermanentAddrTTL)
+	c, err := sw1.DialPeer(context.Background(), sw2.LocalPeer())
+	require.NoError(t, err)
+
+	dc, ok := c.(network.DatagramConn)
+	require.True(t, ok)
+	require.False(t, dc.SupportsDatagrams())
+	require.ErrorIs(t, dc.SendDatagram([]byte("foobar")), network.ErrDatagramsNotSupported)
+	_, err = dc.ReceiveDatagram(context.Background())
+	require.ErrorIs(t, err, network.ErrDatagramsNotSupported)
+}
+
+func TestStreamPriority(t *testing.T) {
+	sw1 := GenSwarm(t, OptDisableQUIC, OptDisableWebTransport)
+	sw2 := GenSwarm(t, OptDisableQUIC, OptDisableWebTransport)
+	sw1.Peerstore().AddAddrs(sw2.LocalPeer(), sw2.ListenAddresses(), peerstore.PermanentAddrTTL)
+
+	s, err := sw1.NewStream(context.Background(), sw2.LocalPeer())
+	require.NoError(t, err)
+	defer s.Close()
+	require.Equal(t, network.StreamPriorityDefault, s.Stat().Priority)
+
+	ctx := network.WithStreamPriority(context.Background(), network.StreamPriorityHigh)
+	s, err = sw1.NewStream(ctx, sw2.LocalPeer())
+	require.NoError(t, err)
+	defer s.Close()
+	require.Equal(t, network.StreamPriorityHigh, s.Stat().Priority)
+}
+
+func TestDialRecordsAddrSource(t *testing.T) {
+	sw1 := GenSwarm(t, OptDisableQUIC, OptDisableWebTransport, OptDialOnly)
+	sw2 := GenSwarm(t, OptDisableQUIC, OptDisableWebTransport)
+	addr := sw2.ListenAddresses()[0]
+	sw1.Peerstore().AddAddrs(sw2.LocalPeer(), []ma.Multiaddr{addr}, peerstore.PermanentAddrTTL)
+
+	_, err := sw1.DialPeer(context.Background(), sw2.LocalPeer())
+	require.NoError(t, err)
+	recs := sw1.Peerstore().(peerstore.AddrBookInspector).InspectAddrs(sw2.LocalPeer())
+	require.Len(t, recs, 1)
+	require.True(t, recs[0].Addr.Equal(addr))
+	require.Equal(t, peerstore.AddrSourceDial, recs[0].Source)
+}
+
+func TestConnSmoothedRTT(t *testing.T) {
+	// The TCP transport reads the RTT from TCP_INFO when metrics are enabled.
+	sw1 := GenSwarm(t, OptDisableTCP, OptDisableQUIC, OptDisableWebTransport, OptDialOnly)
+	tpt, err := tcp.NewTCPTransport(GenUpgrader(t, sw1, nil), nil, nil, tcp.WithMetrics())
+	require.NoError(t, err)
+	require.NoError(t, sw1.AddTransport(tpt))
+	sw2 := GenSwarm(t, OptDisableQUIC, OptDisableWebTransport)
+	sw1.Peerstore().AddAddrs(sw2.LocalPeer(), sw2.ListenAddresses(), peerstore.PermanentAddrTTL)
+
+	c, err := sw1.DialPeer(context.Background(), sw2.LocalPeer())
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return c.Stat().SmoothedRTT > 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestBandwidthAccounting(t *testing.T) {
+	sw1 := GenSwarm(t, OptDisableQUIC, OptDisableWebTransport, WithSwarmOpts(swarm.WithBandwidthAccounting(time.Minute)))
+	sw2 := GenSwarm(t, OptDisableQUIC, OptDisableWebTransport, WithSwarmOpts(swarm.WithBandwidthAccounting(time.Minute)))
+	sw1.Peerstore().AddAddrs(sw2.LocalPeer(), sw2.ListenAddresses(), peerstore.PermanentAddrTTL)
+
+	received := make(chan struct{})
+	sw2.SetStreamHandler(func(s network.Stream) {
+		defer s.Close()
+		b, err := io.ReadAll(s)
+		assert.NoError(t, err)
+		assert.Len(t, b, 100)
+		close(received)
+	})
+
+	s, err := sw1.NewStream(context.Background(), sw2.LocalPeer())
+	require.NoError(t, err)
+	_, err = s.Write(make([]byte, 100))
+	require.NoError(t, err)
+	require.NoError(t, s.CloseWrite())
+	<-received
+
+	require.Equal(t, swarm.PeerBandwidth{Peer: sw2.LocalPeer(), Out: 100}, sw1.PeerBandwidth(sw2.LocalPeer()))
+	require.Equal(t, []swarm.PeerBandwidth{{Peer: sw1.LocalPeer(), In: 100}}, sw2.TopPeersByBandwidth(10))
+	in, out := s.Conn().(*swarm.Conn).BytesTransferred()
+	require.Zero(t, in)
+	require.Equal(t, uint64(100), out)
+}
+
 func TestCloseWithOpenStreams(t *testing.T) {
 	ctx := context.Background()
 	swarms := makeSwarms(t, 2)
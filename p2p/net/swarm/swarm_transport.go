@@ -76,6 +76,21 @@ func (s *Swarm) TransportForListening(a ma.Multiaddr) transport.Transport {
 	return selected
 }
 
+// TransportCapabilities returns the capabilities of the transport used to dial
+// a. ok is false if there's no such transport, or if the transport doesn't
+// advertise its capabilities (see transport.CapabilityProvider).
+func (s *Swarm) TransportCapabilities(a ma.Multiaddr) (caps transport.Capabilities, ok bool) {
+	t := s.TransportForDialing(a)
+	if t == nil {
+		return 0, false
+	}
+	cp, ok := t.(transport.CapabilityProvider)
+	if !ok {
+		return 0, false
+	}
+	return cp.Capabilities(), true
+}
+
 // AddTransport adds a transport to this swarm.
 //
 // Satisfies the Network interface from go-libp2p-transport.
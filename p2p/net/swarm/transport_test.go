@@ -67,3 +67,30 @@ func TestTransportAfterClose(t *testing.T) {
 		t.Fatal("expected swarm closed error, got: ", err)
 	}
 }
+
+func TestTransportCapabilities(t *testing.T) {
+	s := swarmt.GenSwarm(t, swarmt.OptDialOnly)
+	defer s.Close()
+
+	caps, ok := s.TransportCapabilities(ma.StringCast("/ip4/1.2.3.4/udp/1234/quic-v1"))
+	require.True(t, ok)
+	require.True(t, caps.Has(transport.CapabilityHolePunching))
+	require.True(t, caps.Has(transport.CapabilityDatagrams))
+	require.False(t, caps.Has(transport.CapabilityBrowser))
+
+	caps, ok = s.TransportCapabilities(ma.StringCast("/ip4/1.2.3.4/tcp/1234"))
+	require.True(t, ok)
+	require.Equal(t, transport.CapabilityHolePunching, caps)
+
+	for _, a := range []string{
+		"/ip4/1.2.3.4/udp/1234/quic-v1/webtransport",
+		"/ip4/1.2.3.4/udp/1234/webrtc-direct/certhash/uEiDDq4_xNyDorZBH3TlGazyJdOWSwvo4PUo5YHFMrvDE8g",
+	} {
+		caps, ok = s.TransportCapabilities(ma.StringCast(a))
+		require.True(t, ok, a)
+		require.True(t, caps.Has(transport.CapabilityHolePunching|transport.CapabilityBrowser), a)
+	}
+
+	_, ok = s.TransportCapabilities(ma.StringCast("/ip4/1.2.3.4/udp/1234"))
+	require.False(t, ok)
+}
@@ -2,6 +2,7 @@ package upgrader
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/protocol"
@@ -15,6 +16,8 @@ type transportConn struct {
 	transport transport.Transport
 	scope     network.ConnManagementScope
 	stat      network.ConnStats
+	// rawRTT is set if the underlying connection measures its RTT.
+	rawRTT network.ConnRTT
 
 	muxer                     protocol.ID
 	security                  protocol.ID
@@ -22,6 +25,7 @@ type transportConn struct {
 }
 
 var _ transport.CapableConn = &transportConn{}
+var _ network.ConnRTT = &transportConn{}
 
 func (t *transportConn) Transport() transport.Transport {
 	return t.transport
@@ -43,7 +47,24 @@ func (t *transportConn) String() string {
 }
 
 func (t *transportConn) Stat() network.ConnStats {
-	return t.stat
+	stat := t.stat
+	stat.SmoothedRTT = t.SmoothedRTT()
+	return stat
+}
+
+// SmoothedRTT returns the RTT measured by the underlying connection, e.g. using
+// TCP_INFO. Otherwise, it falls back to the RTT measured by the stream muxer,
+// if the muxer exposes one.
+func (t *transportConn) SmoothedRTT() time.Duration {
+	if t.rawRTT != nil {
+		if rtt := t.rawRTT.SmoothedRTT(); rtt > 0 {
+			return rtt
+		}
+	}
+	if mc, ok := t.MuxedConn.(network.ConnRTT); ok {
+		return mc.SmoothedRTT()
+	}
+	return 0
 }
 
 func (t *transportConn) Scope() network.ConnScope {
@@ -106,7 +106,7 @@ func (l *listener) handleIncoming() {
 		go func() {
 			defer wg.Done()
 
-			ctx, cancel := context.WithTimeout(l.ctx, l.upgrader.acceptTimeout)
+			ctx, cancel := context.WithTimeout(l.ctx, l.upgrader.acceptTimeoutFor(maconn.RemoteMultiaddr()))
 			defer cancel()
 
 			conn, err := l.upgrader.Upgrade(ctx, l.transport, maconn, network.DirInbound, "", connScope)
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/netip"
+	"slices"
 	"time"
 
 	"github.com/libp2p/go-libp2p/core/connmgr"
@@ -16,6 +18,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/transport"
 	"github.com/libp2p/go-libp2p/p2p/net/pnet"
 
+	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr/net"
 	mss "github.com/multiformats/go-multistream"
 )
@@ -41,6 +44,125 @@ func WithAcceptTimeout(t time.Duration) Option {
 	}
 }
 
+// ProtocolPreference overrides the security protocols and stream multiplexers
+// used for the connections it matches.
+//
+// A preference matches a connection if the remote peer is one of Peers (or
+// Peers is empty), and the remote address is contained in one of AddrPrefixes
+// (or AddrPrefixes is empty). The peer of an inbound connection is only known
+// after the security handshake, so preferences that set Peers don't affect the
+// security protocol used for inbound connections. For the same reason, inbound
+// connections that such a preference might apply to don't use early muxer
+// negotiation: the stream multiplexer is negotiated after the handshake.
+type ProtocolPreference struct {
+	Peers        []peer.ID
+	AddrPrefixes []netip.Prefix
+
+	// Security is the list of security protocols to use, in order of
+	// preference. Security protocols not in the list are not used.
+	// If empty, the upgrader's security protocols are used.
+	Security []protocol.ID
+	// Muxers is the list of stream multiplexers to use, in order of
+	// preference. Stream multiplexers not in the list are not used.
+	// If empty, the upgrader's stream multiplexers are used.
+	Muxers []protocol.ID
+}
+
+func (pp *ProtocolPreference) matches(p peer.ID, raddr ma.Multiaddr) bool {
+	if len(pp.Peers) > 0 && (p == "" || !slices.Contains(pp.Peers, p)) {
+		return false
+	}
+	return pp.matchesAddr(raddr)
+}
+
+func (pp *ProtocolPreference) matchesAddr(raddr ma.Multiaddr) bool {
+	if len(pp.AddrPrefixes) == 0 {
+		return true
+	}
+	ip, err := manet.ToIP(raddr)
+	if err != nil {
+		return false
+	}
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range pp.AddrPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithProtocolPreferences configures per-peer and per-address overrides of the
+// security protocol and stream multiplexer preference order. The first
+// matching preference is used. Connections that don't match any preference use
+// the order the security protocols and stream multiplexers were passed to New.
+func WithProtocolPreferences(prefs ...ProtocolPreference) Option {
+	return func(u *upgrader) error {
+		u.preferences = append(u.preferences, prefs...)
+		return nil
+	}
+}
+
+type earlyMuxersCtxKey struct{}
+
+// ContextWithEarlyMuxers returns a context that restricts the stream
+// multiplexers offered during early muxer negotiation in the security handshake
+// to muxers, in order of preference. If muxers is empty, early muxer
+// negotiation is disabled and the stream multiplexer is negotiated using
+// multistream-select after the handshake.
+func ContextWithEarlyMuxers(ctx context.Context, muxers []protocol.ID) context.Context {
+	if muxers == nil {
+		muxers = []protocol.ID{}
+	}
+	return context.WithValue(ctx, earlyMuxersCtxKey{}, muxers)
+}
+
+// GetEarlyMuxers returns the stream multiplexers to offer during early muxer
+// negotiation: the ones set with ContextWithEarlyMuxers that are in muxers, or
+// muxers if the context doesn't restrict them.
+func GetEarlyMuxers(ctx context.Context, muxers []protocol.ID) []protocol.ID {
+	v, ok := ctx.Value(earlyMuxersCtxKey{}).([]protocol.ID)
+	if !ok {
+		return muxers
+	}
+	res := make([]protocol.ID, 0, len(v))
+	for _, id := range v {
+		if slices.Contains(muxers, id) {
+			res = append(res, id)
+		}
+	}
+	return res
+}
+
+// WithUpgradeTimeout sets the timeout for upgrading connections whose remote
+// address contains the protocol with the given code, e.g. ma.P_TCP, ma.P_WS or
+// ma.P_CIRCUIT. This allows relayed and browser connections more time, while
+// direct connections fail fast.
+//
+// If the address contains multiple protocols with a timeout, the protocol that
+// appears last in the address takes precedence. For example, the timeout for
+// ma.P_CIRCUIT applies to connections relayed over TCP.
+//
+// For inbound connections, the timeout replaces the accept timeout. For
+// outbound connections, it applies in addition to the deadline of the dial
+// context.
+func WithUpgradeTimeout(code int, timeout time.Duration) Option {
+	return func(u *upgrader) error {
+		if timeout <= 0 {
+			return errors.New("upgrade timeout must be positive")
+		}
+		if u.upgradeTimeouts == nil {
+			u.upgradeTimeouts = make(map[int]time.Duration)
+		}
+		u.upgradeTimeouts[code] = timeout
+		return nil
+	}
+}
+
 type StreamMuxer struct {
 	ID    protocol.ID
 	Muxer network.Multiplexer
@@ -67,6 +189,10 @@ type upgrader struct {
 	//
 	// If unset, the default value (15s) is used.
 	acceptTimeout time.Duration
+	// upgradeTimeouts are the upgrade timeouts by protocol code.
+	upgradeTimeouts map[int]time.Duration
+
+	preferences []ProtocolPreference
 }
 
 var _ transport.Upgrader = &upgrader{}
@@ -100,6 +226,18 @@ func New(security []sec.SecureTransport, muxers []StreamMuxer, psk ipnet.PSK, rc
 		u.securityMuxer.AddHandler(s.ID(), nil)
 		u.securityIDs = append(u.securityIDs, s.ID())
 	}
+	for _, pp := range u.preferences {
+		for _, id := range pp.Security {
+			if u.getSecurityByID(id) == nil {
+				return nil, fmt.Errorf("protocol preference contains unknown security protocol: %s", id)
+			}
+		}
+		for _, id := range pp.Muxers {
+			if u.getMuxerByID(id) == nil {
+				return nil, fmt.Errorf("protocol preference contains unknown stream multiplexer: %s", id)
+			}
+		}
+	}
 	return u, nil
 }
 
@@ -143,10 +281,38 @@ func (u *upgrader) Upgrade(ctx context.Context, t transport.Transport, maconn ma
 	return c, nil
 }
 
+// upgradeTimeout returns the upgrade timeout configured for the remote
+// address, if any.
+func (u *upgrader) upgradeTimeout(raddr ma.Multiaddr) (time.Duration, bool) {
+	var timeout time.Duration
+	var found bool
+	for _, c := range raddr {
+		if t, ok := u.upgradeTimeouts[c.Protocol().Code]; ok {
+			timeout, found = t, true
+		}
+	}
+	return timeout, found
+}
+
+// acceptTimeoutFor returns the timeout for accepting a connection from raddr.
+func (u *upgrader) acceptTimeoutFor(raddr ma.Multiaddr) time.Duration {
+	if t, ok := u.upgradeTimeout(raddr); ok {
+		return t
+	}
+	return u.acceptTimeout
+}
+
 func (u *upgrader) upgrade(ctx context.Context, t transport.Transport, maconn manet.Conn, dir network.Direction, p peer.ID, connScope network.ConnManagementScope) (transport.CapableConn, error) {
 	if dir == network.DirOutbound && p == "" {
 		return nil, ErrNilPeer
 	}
+	if dir == network.DirOutbound {
+		if t, ok := u.upgradeTimeout(maconn.RemoteMultiaddr()); ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, t)
+			defer cancel()
+		}
+	}
 	var stat network.ConnStats
 	if cs, ok := maconn.(network.ConnStat); ok {
 		stat = cs.Stat()
@@ -166,11 +332,13 @@ func (u *upgrader) upgrade(ctx context.Context, t transport.Transport, maconn ma
 	}
 
 	isServer := dir == network.DirInbound
-	sconn, security, err := u.setupSecurity(ctx, conn, p, isServer)
+	start := time.Now()
+	sconn, security, err := u.setupSecurity(ctx, conn, p, maconn.RemoteMultiaddr(), isServer)
 	if err != nil {
 		conn.Close()
 		return nil, fmt.Errorf("failed to negotiate security protocol: %w", err)
 	}
+	stat.Handshake.Security = time.Since(start)
 
 	// call the connection gater, if one is registered.
 	if u.connGater != nil && !u.connGater.InterceptSecured(dir, sconn.RemotePeer(), maconn) {
@@ -193,11 +361,13 @@ func (u *upgrader) upgrade(ctx context.Context, t transport.Transport, maconn ma
 		}
 	}
 
-	muxer, smconn, err := u.setupMuxer(ctx, sconn, isServer, connScope.PeerScope())
+	start = time.Now()
+	muxer, smconn, err := u.setupMuxer(ctx, sconn, maconn.RemoteMultiaddr(), isServer, connScope.PeerScope())
 	if err != nil {
 		sconn.Close()
 		return nil, fmt.Errorf("failed to negotiate stream multiplexer: %w", err)
 	}
+	stat.Handshake.Muxer = time.Since(start)
 
 	tc := &transportConn{
 		MuxedConn:                 smconn,
@@ -210,14 +380,81 @@ func (u *upgrader) upgrade(ctx context.Context, t transport.Transport, maconn ma
 		security:                  security,
 		usedEarlyMuxerNegotiation: sconn.ConnState().UsedEarlyMuxerNegotiation,
 	}
+	if rc, ok := maconn.(network.ConnRTT); ok {
+		tc.rawRTT = rc
+	}
 	return tc, nil
 }
 
-func (u *upgrader) setupSecurity(ctx context.Context, conn net.Conn, p peer.ID, isServer bool) (sec.SecureConn, protocol.ID, error) {
-	st, err := u.negotiateSecurity(ctx, conn, isServer)
+// preference returns the first protocol preference matching the connection,
+// or nil if there's none.
+func (u *upgrader) preference(p peer.ID, raddr ma.Multiaddr) *ProtocolPreference {
+	for i := range u.preferences {
+		if u.preferences[i].matches(p, raddr) {
+			return &u.preferences[i]
+		}
+	}
+	return nil
+}
+
+// securityIDsFor returns the security protocols to use for the connection, in
+// order of preference.
+func (u *upgrader) securityIDsFor(p peer.ID, raddr ma.Multiaddr) []protocol.ID {
+	if pp := u.preference(p, raddr); pp != nil && len(pp.Security) > 0 {
+		return pp.Security
+	}
+	return u.securityIDs
+}
+
+// muxerIDsFor returns the stream multiplexers to use for the connection, in
+// order of preference.
+func (u *upgrader) muxerIDsFor(p peer.ID, raddr ma.Multiaddr) []protocol.ID {
+	if pp := u.preference(p, raddr); pp != nil && len(pp.Muxers) > 0 {
+		return pp.Muxers
+	}
+	return u.muxerIDs
+}
+
+// earlyMuxerIDsFor returns the stream multiplexers to offer during early muxer
+// negotiation. If the peer isn't known yet and a preference for specific peers
+// might apply to the connection, early muxer negotiation is disabled, so that
+// the muxer is negotiated with the preference of the peer after the handshake.
+func (u *upgrader) earlyMuxerIDsFor(p peer.ID, raddr ma.Multiaddr) []protocol.ID {
+	for i := range u.preferences {
+		pp := &u.preferences[i]
+		if p == "" && len(pp.Peers) > 0 && pp.matchesAddr(raddr) {
+			return []protocol.ID{}
+		}
+		if pp.matches(p, raddr) {
+			if len(pp.Muxers) > 0 {
+				return pp.Muxers
+			}
+			break
+		}
+	}
+	return u.muxerIDs
+}
+
+// negotiator returns the multistream muxer accepting the given protocols.
+func negotiator(ids, defaultIDs []protocol.ID, defaultMuxer *mss.MultistreamMuxer[protocol.ID]) *mss.MultistreamMuxer[protocol.ID] {
+	if slices.Equal(ids, defaultIDs) {
+		return defaultMuxer
+	}
+	m := mss.NewMultistreamMuxer[protocol.ID]()
+	for _, id := range ids {
+		m.AddHandler(id, nil)
+	}
+	return m
+}
+
+func (u *upgrader) setupSecurity(ctx context.Context, conn net.Conn, p peer.ID, raddr ma.Multiaddr, isServer bool) (sec.SecureConn, protocol.ID, error) {
+	st, err := u.negotiateSecurity(ctx, conn, u.securityIDsFor(p, raddr), isServer)
 	if err != nil {
 		return nil, "", err
 	}
+	if len(u.preferences) > 0 {
+		ctx = ContextWithEarlyMuxers(ctx, u.earlyMuxerIDsFor(p, raddr))
+	}
 	if isServer {
 		sconn, err := st.SecureInbound(ctx, conn, p)
 		return sconn, st.ID(), err
@@ -226,20 +463,20 @@ func (u *upgrader) setupSecurity(ctx context.Context, conn net.Conn, p peer.ID,
 	return sconn, st.ID(), err
 }
 
-func (u *upgrader) negotiateMuxer(nc net.Conn, isServer bool) (*StreamMuxer, error) {
+func (u *upgrader) negotiateMuxer(nc net.Conn, muxerIDs []protocol.ID, isServer bool) (*StreamMuxer, error) {
 	if err := nc.SetDeadline(time.Now().Add(defaultNegotiateTimeout)); err != nil {
 		return nil, err
 	}
 
 	var proto protocol.ID
 	if isServer {
-		selected, _, err := u.muxerMuxer.Negotiate(nc)
+		selected, _, err := negotiator(muxerIDs, u.muxerIDs, u.muxerMuxer).Negotiate(nc)
 		if err != nil {
 			return nil, err
 		}
 		proto = selected
 	} else {
-		selected, err := mss.SelectOneOf(u.muxerIDs, nc)
+		selected, err := mss.SelectOneOf(muxerIDs, nc)
 		if err != nil {
 			return nil, err
 		}
@@ -265,7 +502,8 @@ func (u *upgrader) getMuxerByID(id protocol.ID) *StreamMuxer {
 	return nil
 }
 
-func (u *upgrader) setupMuxer(ctx context.Context, conn sec.SecureConn, server bool, scope network.PeerScope) (protocol.ID, network.MuxedConn, error) {
+func (u *upgrader) setupMuxer(ctx context.Context, conn sec.SecureConn, raddr ma.Multiaddr, server bool, scope network.PeerScope) (protocol.ID, network.MuxedConn, error) {
+	muxerIDs := u.muxerIDsFor(conn.RemotePeer(), raddr)
 	muxerSelected := conn.ConnState().StreamMultiplexer
 	// Use muxer selected from security handshake if available. Otherwise fall back to multistream-selection.
 	if len(muxerSelected) > 0 {
@@ -273,6 +511,12 @@ func (u *upgrader) setupMuxer(ctx context.Context, conn sec.SecureConn, server b
 		if m == nil {
 			return "", nil, fmt.Errorf("selected a muxer we don't know: %s", muxerSelected)
 		}
+		// The security handshake only offers the muxers allowed for this
+		// connection, see earlyMuxerIDsFor. Verify that the remote didn't pick
+		// another one.
+		if !slices.Contains(muxerIDs, muxerSelected) {
+			return "", nil, fmt.Errorf("selected a muxer not allowed for this connection: %s", muxerSelected)
+		}
 		c, err := m.Muxer.NewConn(conn, server, scope)
 		if err != nil {
 			return "", nil, err
@@ -289,7 +533,7 @@ func (u *upgrader) setupMuxer(ctx context.Context, conn sec.SecureConn, server b
 	done := make(chan result, 1)
 	// TODO: The muxer should take a context.
 	go func() {
-		m, err := u.negotiateMuxer(conn, server)
+		m, err := u.negotiateMuxer(conn, muxerIDs, server)
 		if err != nil {
 			done <- result{err: err}
 			return
@@ -319,7 +563,7 @@ func (u *upgrader) getSecurityByID(id protocol.ID) sec.SecureTransport {
 	return nil
 }
 
-func (u *upgrader) negotiateSecurity(ctx context.Context, insecure net.Conn, server bool) (sec.SecureTransport, error) {
+func (u *upgrader) negotiateSecurity(ctx context.Context, insecure net.Conn, securityIDs []protocol.ID, server bool) (sec.SecureTransport, error) {
 	type result struct {
 		proto protocol.ID
 		err   error
@@ -329,12 +573,12 @@ func (u *upgrader) negotiateSecurity(ctx context.Context, insecure net.Conn, ser
 	go func() {
 		if server {
 			var r result
-			r.proto, _, r.err = u.securityMuxer.Negotiate(insecure)
+			r.proto, _, r.err = negotiator(securityIDs, u.securityIDs, u.securityMuxer).Negotiate(insecure)
 			done <- r
 			return
 		}
 		var r result
-		r.proto, r.err = mss.SelectOneOf(u.securityIDs, insecure)
+		r.proto, r.err = mss.SelectOneOf(securityIDs, insecure)
 		done <- r
 	}()
 
@@ -4,19 +4,24 @@ import (
 	"context"
 	"crypto/rand"
 	"errors"
+	"io"
 	"net"
+	"net/netip"
 	"testing"
+	"time"
 
 	"github.com/libp2p/go-libp2p/core/connmgr"
 	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/network"
 	mocknetwork "github.com/libp2p/go-libp2p/core/network/mocks"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
 	"github.com/libp2p/go-libp2p/core/sec"
 	"github.com/libp2p/go-libp2p/core/sec/insecure"
 	"github.com/libp2p/go-libp2p/core/transport"
 	"github.com/libp2p/go-libp2p/p2p/muxer/yamux"
 	"github.com/libp2p/go-libp2p/p2p/net/upgrader"
+	"github.com/libp2p/go-libp2p/p2p/security/noise"
 
 	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr/net"
@@ -202,3 +207,171 @@ func TestOutboundResourceManagement(t *testing.T) {
 		require.Error(t, err)
 	})
 }
+
+func TestProtocolPreferences(t *testing.T) {
+	muxers := []upgrader.StreamMuxer{
+		{ID: "first", Muxer: &negotiatingMuxer{}},
+		{ID: "second", Muxer: &negotiatingMuxer{}},
+	}
+
+	t.Run("outbound peer preference", func(t *testing.T) {
+		id, u := createUpgraderWithMuxers(t, muxers, nil, nil)
+		ln := createListener(t, u)
+		defer ln.Close()
+
+		_, dialUpgrader := createUpgraderWithMuxers(t, muxers, nil, nil,
+			upgrader.WithProtocolPreferences(upgrader.ProtocolPreference{
+				Peers:  []peer.ID{id},
+				Muxers: []protocol.ID{"second"},
+			}),
+		)
+		conn, err := dial(t, dialUpgrader, ln.Multiaddr(), id, &network.NullScope{})
+		require.NoError(t, err)
+		defer conn.Close()
+		require.Equal(t, protocol.ID("second"), conn.ConnState().StreamMultiplexer)
+
+		// Other peers use the default order.
+		otherID, otherUpgrader := createUpgraderWithMuxers(t, muxers, nil, nil)
+		otherLn := createListener(t, otherUpgrader)
+		defer otherLn.Close()
+		conn, err = dial(t, dialUpgrader, otherLn.Multiaddr(), otherID, &network.NullScope{})
+		require.NoError(t, err)
+		defer conn.Close()
+		require.Equal(t, protocol.ID("first"), conn.ConnState().StreamMultiplexer)
+	})
+
+	t.Run("inbound address preference", func(t *testing.T) {
+		id, u := createUpgraderWithMuxers(t, muxers, nil, nil,
+			upgrader.WithProtocolPreferences(upgrader.ProtocolPreference{
+				AddrPrefixes: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")},
+				Muxers:       []protocol.ID{"second"},
+			}),
+		)
+		ln := createListener(t, u)
+		defer ln.Close()
+
+		_, dialUpgrader := createUpgraderWithMuxers(t, muxers, nil, nil)
+		conn, err := dial(t, dialUpgrader, ln.Multiaddr(), id, &network.NullScope{})
+		require.NoError(t, err)
+		defer conn.Close()
+		require.Equal(t, protocol.ID("second"), conn.ConnState().StreamMultiplexer)
+
+		sconn, err := ln.Accept()
+		require.NoError(t, err)
+		defer sconn.Close()
+		require.Equal(t, protocol.ID("second"), sconn.ConnState().StreamMultiplexer)
+	})
+
+	t.Run("early muxer negotiation", func(t *testing.T) {
+		newNoiseUpgrader := func(t *testing.T, opts ...upgrader.Option) (peer.ID, transport.Upgrader) {
+			id, priv := newPeer(t)
+			st, err := noise.New(noise.ID, priv, muxers)
+			require.NoError(t, err)
+			u, err := upgrader.New([]sec.SecureTransport{st}, muxers, nil, nil, nil, opts...)
+			require.NoError(t, err)
+			return id, u
+		}
+
+		dialID, dialUpgrader := newNoiseUpgrader(t)
+		// The listener only learns the dialer's peer ID during the handshake,
+		// so it negotiates the muxer after the handshake instead.
+		id, u := newNoiseUpgrader(t,
+			upgrader.WithProtocolPreferences(upgrader.ProtocolPreference{
+				Peers:  []peer.ID{dialID},
+				Muxers: []protocol.ID{"second"},
+			}),
+		)
+		ln := createListener(t, u)
+		defer ln.Close()
+
+		conn, err := dial(t, dialUpgrader, ln.Multiaddr(), id, &network.NullScope{})
+		require.NoError(t, err)
+		defer conn.Close()
+		require.Equal(t, protocol.ID("second"), conn.ConnState().StreamMultiplexer)
+		require.False(t, conn.ConnState().UsedEarlyMuxerNegotiation)
+
+		sconn, err := ln.Accept()
+		require.NoError(t, err)
+		defer sconn.Close()
+		require.Equal(t, protocol.ID("second"), sconn.ConnState().StreamMultiplexer)
+
+		// Outbound connections offer the preferred muxers in the handshake.
+		otherID, otherUpgrader := newNoiseUpgrader(t)
+		otherLn := createListener(t, otherUpgrader)
+		defer otherLn.Close()
+		_, preferUpgrader := newNoiseUpgrader(t,
+			upgrader.WithProtocolPreferences(upgrader.ProtocolPreference{
+				Peers:  []peer.ID{otherID},
+				Muxers: []protocol.ID{"second"},
+			}),
+		)
+		conn, err = dial(t, preferUpgrader, otherLn.Multiaddr(), otherID, &network.NullScope{})
+		require.NoError(t, err)
+		defer conn.Close()
+		require.Equal(t, protocol.ID("second"), conn.ConnState().StreamMultiplexer)
+		require.True(t, conn.ConnState().UsedEarlyMuxerNegotiation)
+	})
+
+	t.Run("unknown protocol", func(t *testing.T) {
+		id, priv := newPeer(t)
+		_, err := upgrader.New([]sec.SecureTransport{insecure.NewWithIdentity(insecure.ID, id, priv)}, muxers, nil, nil, nil,
+			upgrader.WithProtocolPreferences(upgrader.ProtocolPreference{Muxers: []protocol.ID{"unknown"}}),
+		)
+		require.Error(t, err)
+	})
+}
+
+func TestUpgradeTimeout(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+
+	t.Run("inbound", func(t *testing.T) {
+		_, u := createUpgraderWithOpts(t, upgrader.WithUpgradeTimeout(ma.P_TCP, timeout))
+		ln := createListener(t, u)
+		defer ln.Close()
+
+		// Connect, but never start the handshake.
+		conn, err := manet.Dial(ln.Multiaddr())
+		require.NoError(t, err)
+		defer conn.Close()
+		start := time.Now()
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		_, err = io.ReadAll(conn)
+		require.NoError(t, err)
+		require.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("outbound", func(t *testing.T) {
+		ln, err := manet.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0"))
+		require.NoError(t, err)
+		defer ln.Close()
+		go func() {
+			// Accept connections, but never respond.
+			for {
+				c, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				defer c.Close()
+			}
+		}()
+
+		id, _ := newPeer(t)
+		_, u := createUpgraderWithOpts(t,
+			upgrader.WithUpgradeTimeout(ma.P_IP4, time.Hour),
+			upgrader.WithUpgradeTimeout(ma.P_TCP, timeout),
+		)
+		start := time.Now()
+		_, err = dial(t, u, ln.Multiaddr(), id, &network.NullScope{})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		// The TCP timeout takes precedence, since it appears later in the address.
+		require.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		id, priv := newPeer(t)
+		_, err := upgrader.New([]sec.SecureTransport{insecure.NewWithIdentity(insecure.ID, id, priv)}, nil, nil, nil, nil,
+			upgrader.WithUpgradeTimeout(ma.P_TCP, 0),
+		)
+		require.Error(t, err)
+	})
+}
@@ -103,11 +103,19 @@ func New(dialerHost host.Host, opts ...AutoNATOption) (*AutoNAT, error) {
 		}
 	}
 
+	var srv *server
+	if !s.disableServer {
+		if dialerHost == nil {
+			return nil, errors.New("a dialer host is required for the autonatv2 server")
+		}
+		srv = newServer(dialerHost, s)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	an := &AutoNAT{
 		ctx:                  ctx,
 		cancel:               cancel,
-		srv:                  newServer(dialerHost, s),
+		srv:                  srv,
 		cli:                  newClient(),
 		allowPrivateAddrs:    s.allowPrivateAddrs,
 		peers:                newPeersMap(),
@@ -162,7 +170,9 @@ func (an *AutoNAT) Start(h host.Host) error {
 		return fmt.Errorf("event subscription failed: %w", err)
 	}
 	an.cli.Start(h)
-	an.srv.Start(h)
+	if an.srv != nil {
+		an.srv.Start(h)
+	}
 
 	an.wg.Add(1)
 	go an.background(sub)
@@ -172,7 +182,9 @@ func (an *AutoNAT) Start(h host.Host) error {
 func (an *AutoNAT) Close() {
 	an.cancel()
 	an.wg.Wait()
-	an.srv.Close()
+	if an.srv != nil {
+		an.srv.Close()
+	}
 	an.cli.Close()
 	an.peers = nil
 }
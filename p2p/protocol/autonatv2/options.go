@@ -14,6 +14,7 @@ type autoNATSettings struct {
 	amplificatonAttackPreventionDialWait time.Duration
 	metricsTracer                        MetricsTracer
 	throttlePeerDuration                 time.Duration
+	disableServer                        bool
 }
 
 func defaultSettings() *autoNATSettings {
@@ -42,6 +43,16 @@ func WithServerRateLimit(rpm, perPeerRPM, dialDataRPM int, maxConcurrentRequests
 	}
 }
 
+// WithoutServer disables the AutoNAT v2 server. The host still asks other
+// peers to verify its reachability, but doesn't serve reachability checks
+// itself. The dialer host passed to New may be nil in this case.
+func WithoutServer() AutoNATOption {
+	return func(s *autoNATSettings) error {
+		s.disableServer = true
+		return nil
+	}
+}
+
 func WithMetricsTracer(m MetricsTracer) AutoNATOption {
 	return func(s *autoNATSettings) error {
 		s.metricsTracer = m
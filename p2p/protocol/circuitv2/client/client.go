@@ -34,6 +34,20 @@ type Client struct {
 	mx          sync.Mutex
 	activeDials map[peer.ID]*completion
 	hopCount    map[peer.ID]int
+
+	disableStopHandler bool
+}
+
+// Option is an option for the circuit v2 client.
+type Option func(*Client) error
+
+// DisableStopHandler disables the stop protocol handler. The client can still
+// dial peers through relays, but won't accept relayed connections.
+func DisableStopHandler() Option {
+	return func(c *Client) error {
+		c.disableStopHandler = true
+		return nil
+	}
 }
 
 var _ io.Closer = &Client{}
@@ -52,7 +66,7 @@ type completion struct {
 
 // New constructs a new p2p-circuit/v2 client, attached to the given host and using the given
 // upgrader to perform connection upgrades.
-func New(h host.Host, upgrader transport.Upgrader) (*Client, error) {
+func New(h host.Host, upgrader transport.Upgrader, opts ...Option) (*Client, error) {
 	cl := &Client{
 		host:        h,
 		upgrader:    upgrader,
@@ -60,12 +74,20 @@ func New(h host.Host, upgrader transport.Upgrader) (*Client, error) {
 		activeDials: make(map[peer.ID]*completion),
 		hopCount:    make(map[peer.ID]int),
 	}
+	for _, o := range opts {
+		if err := o(cl); err != nil {
+			return nil, err
+		}
+	}
 	cl.ctx, cl.ctxCancel = context.WithCancel(context.Background())
 	return cl, nil
 }
 
 // Start registers the circuit (client) protocol stream handlers
 func (c *Client) Start() {
+	if c.disableStopHandler {
+		return
+	}
 	c.host.SetStreamHandler(proto.ProtoIDv2Stop, c.handleStreamV2)
 }
 
@@ -18,13 +18,13 @@ var circuitAddr = ma.Cast(circuitProtocol.VCode)
 
 // AddTransport constructs a new p2p-circuit/v2 client and adds it as a transport to the
 // host network
-func AddTransport(h host.Host, upgrader transport.Upgrader) error {
+func AddTransport(h host.Host, upgrader transport.Upgrader, opts ...Option) error {
 	n, ok := h.Network().(transport.TransportNetwork)
 	if !ok {
 		return fmt.Errorf("%v is not a transport network", h.Network())
 	}
 
-	c, err := New(h, upgrader)
+	c, err := New(h, upgrader, opts...)
 	if err != nil {
 		return fmt.Errorf("error constructing circuit client: %w", err)
 	}
@@ -1,9 +1,12 @@
 package relay
 
 import (
+	"net/netip"
+
 	"github.com/libp2p/go-libp2p/core/peer"
 
 	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
 )
 
 // ACLFilter is an Access Control mechanism for relayed connect.
@@ -15,3 +18,84 @@ type ACLFilter interface {
 	// to a destination peer.
 	AllowConnect(src peer.ID, srcAddr ma.Multiaddr, dest peer.ID) bool
 }
+
+// ReservationRequest describes a reservation request, for a ReservationAuthorizer.
+type ReservationRequest struct {
+	Peer peer.ID
+	Addr ma.Multiaddr
+	// Usage is the usage of the reservation the peer is refreshing, or nil if it doesn't hold
+	// a reservation.
+	Usage *ReservationUsage
+}
+
+// ReservationDecision is the decision of a ReservationAuthorizer. It applies until the
+// reservation is refreshed, when the ReservationAuthorizer is called again and its new decision
+// replaces this one.
+type ReservationDecision struct {
+	// Accept is true if the reservation is accepted.
+	Accept bool
+	// Limit, if set, overrides Resources.Limit for the connections relayed to the peer. Zero
+	// fields mean no limit, so &RelayLimit{} lifts the limit. If nil, Resources.Limit applies.
+	Limit *RelayLimit
+	// Budget, if set, overrides the budget of the reservation. Zero fields mean no limit. If
+	// nil, the budget of the peer applies, see WithReservationBudget.
+	Budget *ReservationBudget
+}
+
+// ReservationAuthorizer decides whether to accept a reservation, and can set custom limits for
+// it, for example to implement paid or private relays. It's called after the ACLFilter accepted
+// the reservation.
+type ReservationAuthorizer func(ReservationRequest) ReservationDecision
+
+// reservationACL restricts the peers allowed to reserve a slot, independently of the ACLFilter.
+// See the WithReservation* options.
+type reservationACL struct {
+	allowedPeers   map[peer.ID]struct{}
+	deniedPeers    map[peer.ID]struct{}
+	allowedSubnets []netip.Prefix
+	deniedSubnets  []netip.Prefix
+	filters        []func(peer.ID, ma.Multiaddr) bool
+}
+
+// allow returns true if a peer connected from the given multiaddr may reserve a slot. Denylists
+// take precedence over allowlists. If both peers and subnets are allowlisted, a peer must match
+// either of them.
+func (acl *reservationACL) allow(p peer.ID, a ma.Multiaddr) bool {
+	if _, ok := acl.deniedPeers[p]; ok {
+		return false
+	}
+	ip, hasIP := multiaddrIP(a)
+	if hasIP && subnetsContain(acl.deniedSubnets, ip) {
+		return false
+	}
+	if len(acl.allowedPeers) > 0 || len(acl.allowedSubnets) > 0 {
+		_, ok := acl.allowedPeers[p]
+		if !ok && !(hasIP && subnetsContain(acl.allowedSubnets, ip)) {
+			return false
+		}
+	}
+	for _, f := range acl.filters {
+		if !f(p, a) {
+			return false
+		}
+	}
+	return true
+}
+
+func multiaddrIP(a ma.Multiaddr) (netip.Addr, bool) {
+	ip, err := manet.ToIP(a)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	addr, ok := netip.AddrFromSlice(ip)
+	return addr.Unmap(), ok
+}
+
+func subnetsContain(subnets []netip.Prefix, ip netip.Addr) bool {
+	for _, prefix := range subnets {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -29,6 +29,8 @@ type constraints struct {
 	rc *Resources
 
 	mutex sync.Mutex
+	// scale scales MaxReservations, see ResourceScaling.
+	scale float64
 	total []peerWithExpiry
 	ips   map[string][]peerWithExpiry
 	asns  map[uint32][]peerWithExpiry
@@ -39,9 +41,10 @@ type constraints struct {
 // is required.
 func newConstraints(rc *Resources) *constraints {
 	return &constraints{
-		rc:   rc,
-		ips:  make(map[string][]peerWithExpiry),
-		asns: make(map[uint32][]peerWithExpiry),
+		rc:    rc,
+		scale: 1,
+		ips:   make(map[string][]peerWithExpiry),
+		asns:  make(map[uint32][]peerWithExpiry),
 	}
 }
 
@@ -56,7 +59,7 @@ func (c *constraints) Reserve(p peer.ID, a ma.Multiaddr, expiry time.Time) error
 	// To handle refreshes correctly, remove the existing reservation for the peer.
 	c.cleanupPeer(p)
 
-	if len(c.total) >= c.rc.MaxReservations {
+	if len(c.total) >= max(int(float64(c.rc.MaxReservations)*c.scale), 1) {
 		return errTooManyReservations
 	}
 
@@ -94,6 +97,13 @@ func (c *constraints) Reserve(p peer.ID, a ma.Multiaddr, expiry time.Time) error
 	return nil
 }
 
+// setScale scales MaxReservations. Existing reservations are kept.
+func (c *constraints) setScale(scale float64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.scale = scale
+}
+
 func (c *constraints) cleanup(now time.Time) {
 	expireFunc := func(pe peerWithExpiry) bool {
 		return pe.Expiry.Before(now)
@@ -84,6 +84,23 @@ var (
 		},
 	)
 
+	reservationBudgetExceededTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "reservation_budget_exceeded_total",
+			Help:      "Relay Connections Refused or Reset Because the Reservation Budget Was Exceeded",
+		},
+		[]string{"budget"},
+	)
+	reservationDataRelayedBytes = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Name:      "reservation_data_relayed_bytes",
+			Help:      "Data Relayed per Reservation",
+			Buckets:   prometheus.ExponentialBuckets(1024, 4, 11),
+		},
+	)
+
 	collectors = []prometheus.Collector{
 		status,
 		reservationsTotal,
@@ -94,6 +111,8 @@ var (
 		connectionRejectionsTotal,
 		connectionDurationSeconds,
 		dataTransferredBytesTotal,
+		reservationBudgetExceededTotal,
+		reservationDataRelayedBytes,
 	}
 )
 
@@ -126,9 +145,20 @@ type MetricsTracer interface {
 	BytesTransferred(cnt int)
 }
 
+// BudgetMetricsTracer can be implemented by a MetricsTracer to track the data
+// and duration budgets of reservations.
+type BudgetMetricsTracer interface {
+	// ReservationBudgetExceeded tracks relay connections refused or reset because the data
+	// or duration budget of the reservation was exceeded
+	ReservationBudgetExceeded(isData bool)
+	// ReservationDataRelayed tracks the data relayed during a reservation, when it ends
+	ReservationDataRelayed(bytes int64)
+}
+
 type metricsTracer struct{}
 
 var _ MetricsTracer = &metricsTracer{}
+var _ BudgetMetricsTracer = &metricsTracer{}
 
 type metricsTracerSetting struct {
 	reg prometheus.Registerer
@@ -266,3 +296,19 @@ func getRejectionReason(status pbv2.Status) string {
 	}
 	return reason
 }
+
+func (mt *metricsTracer) ReservationBudgetExceeded(isData bool) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	if isData {
+		*tags = append(*tags, "data")
+	} else {
+		*tags = append(*tags, "duration")
+	}
+	reservationBudgetExceededTotal.WithLabelValues(*tags...).Inc()
+}
+
+func (mt *metricsTracer) ReservationDataRelayed(bytes int64) {
+	reservationDataRelayedBytes.Observe(float64(bytes))
+}
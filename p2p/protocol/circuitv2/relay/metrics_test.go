@@ -27,6 +27,8 @@ func TestNoCoverNoAlloc(t *testing.T) {
 		"ReservationClosed":         func() { mt.ReservationClosed(rand.Intn(10)) },
 		"ReservationRequestHandled": func() { mt.ReservationRequestHandled(statuses[rand.Intn(len(statuses))]) },
 		"BytesTransferred":          func() { mt.BytesTransferred(rand.Intn(1000)) },
+		"ReservationBudgetExceeded": func() { mt.(BudgetMetricsTracer).ReservationBudgetExceeded(rand.Intn(2) == 1) },
+		"ReservationDataRelayed":    func() { mt.(BudgetMetricsTracer).ReservationDataRelayed(rand.Int63n(1 << 20)) },
 	}
 	for method, f := range tests {
 		allocs := testing.AllocsPerRun(1000, f)
@@ -1,5 +1,13 @@
 package relay
 
+import (
+	"net/netip"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
 type Option func(*Relay) error
 
 // WithResources is a Relay option that sets specific relay resources for the relay.
@@ -26,6 +34,24 @@ func WithInfiniteLimits() Option {
 	}
 }
 
+// WithReservationBudget is a Relay option that sets the reservation budget of a specific peer,
+// overriding Resources.ReservationBudget. A nil budget means no limit.
+func WithReservationBudget(p peer.ID, budget *ReservationBudget) Option {
+	return func(r *Relay) error {
+		r.budgets[p] = budget
+		return nil
+	}
+}
+
+// WithReservationAuthorizer is a Relay option that supplies a ReservationAuthorizer to decide
+// on reservations.
+func WithReservationAuthorizer(auth ReservationAuthorizer) Option {
+	return func(r *Relay) error {
+		r.authorizer = auth
+		return nil
+	}
+}
+
 // WithACL is a Relay option that supplies an ACLFilter for access control.
 func WithACL(acl ACLFilter) Option {
 	return func(r *Relay) error {
@@ -34,6 +60,61 @@ func WithACL(acl ACLFilter) Option {
 	}
 }
 
+// WithReservationAllowedPeers is a Relay option that only allows the given peers to reserve a
+// slot, unless they are allowed by WithReservationAllowedSubnets.
+func WithReservationAllowedPeers(peers ...peer.ID) Option {
+	return func(r *Relay) error {
+		if r.rsvpACL.allowedPeers == nil {
+			r.rsvpACL.allowedPeers = make(map[peer.ID]struct{}, len(peers))
+		}
+		for _, p := range peers {
+			r.rsvpACL.allowedPeers[p] = struct{}{}
+		}
+		return nil
+	}
+}
+
+// WithReservationDeniedPeers is a Relay option that doesn't allow the given peers to reserve a
+// slot.
+func WithReservationDeniedPeers(peers ...peer.ID) Option {
+	return func(r *Relay) error {
+		if r.rsvpACL.deniedPeers == nil {
+			r.rsvpACL.deniedPeers = make(map[peer.ID]struct{}, len(peers))
+		}
+		for _, p := range peers {
+			r.rsvpACL.deniedPeers[p] = struct{}{}
+		}
+		return nil
+	}
+}
+
+// WithReservationAllowedSubnets is a Relay option that only allows the peers connected from the
+// given subnets to reserve a slot, unless they are allowed by WithReservationAllowedPeers.
+func WithReservationAllowedSubnets(subnets ...netip.Prefix) Option {
+	return func(r *Relay) error {
+		r.rsvpACL.allowedSubnets = append(r.rsvpACL.allowedSubnets, subnets...)
+		return nil
+	}
+}
+
+// WithReservationDeniedSubnets is a Relay option that doesn't allow the peers connected from the
+// given subnets to reserve a slot.
+func WithReservationDeniedSubnets(subnets ...netip.Prefix) Option {
+	return func(r *Relay) error {
+		r.rsvpACL.deniedSubnets = append(r.rsvpACL.deniedSubnets, subnets...)
+		return nil
+	}
+}
+
+// WithReservationFilter is a Relay option that only allows a peer to reserve a slot if filter
+// returns true for the peer and the multiaddr it's connected from.
+func WithReservationFilter(filter func(p peer.ID, a ma.Multiaddr) bool) Option {
+	return func(r *Relay) error {
+		r.rsvpACL.filters = append(r.rsvpACL.filters, filter)
+		return nil
+	}
+}
+
 // WithMetricsTracer is a Relay option that supplies a MetricsTracer for metrics
 func WithMetricsTracer(mt MetricsTracer) Option {
 	return func(r *Relay) error {
@@ -49,15 +49,28 @@ type Relay struct {
 	host        host.Host
 	rc          Resources
 	acl         ACLFilter
+	rsvpACL     reservationACL
+	authorizer  ReservationAuthorizer
 	constraints *constraints
 	scope       network.ResourceScopeSpan
 	notifiee    network.Notifiee
 
 	mx     sync.Mutex
 	rsvp   map[peer.ID]time.Time
+	usage  map[peer.ID]*reservationUsage
 	conns  map[peer.ID]int
 	closed bool
 
+	circuits      map[uint64]*circuit
+	nextCircuitID uint64
+
+	budgets map[peer.ID]*ReservationBudget
+
+	// scaling is the ResourceScaling in use, if any. scaled is true while the limits are
+	// shrunk.
+	scaling *ResourceScaling
+	scaled  bool
+
 	selfAddr ma.Multiaddr
 
 	metricsTracer MetricsTracer
@@ -74,7 +87,11 @@ func New(h host.Host, opts ...Option) (*Relay, error) {
 		rc:     DefaultResources(),
 		acl:    nil,
 		rsvp:   make(map[peer.ID]time.Time),
+		usage:  make(map[peer.ID]*reservationUsage),
 		conns:  make(map[peer.ID]int),
+
+		circuits: make(map[uint64]*circuit),
+		budgets:  make(map[peer.ID]*ReservationBudget),
 	}
 
 	for _, opt := range opts {
@@ -84,6 +101,14 @@ func New(h host.Host, opts ...Option) (*Relay, error) {
 		}
 	}
 
+	if r.rc.Scaling != nil {
+		var err error
+		r.scaling, err = r.rc.Scaling.withDefaults()
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// get a scope for memory reservations at service level
 	err := h.Network().ResourceManager().ViewService(ServiceName,
 		func(s network.ServiceScope) error {
@@ -130,6 +155,24 @@ func (r *Relay) Close() error {
 	return nil
 }
 
+// SetLimit changes the relayed connection limits of the running relay. A nil limit relays
+// connections without limits. The new limits apply to the connections relayed from now on,
+// and are communicated to the peers holding a reservation when they refresh it. The limits
+// set by a ReservationAuthorizer take precedence.
+func (r *Relay) SetLimit(limit *RelayLimit) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.rc.Limit = limit
+}
+
+// Limit returns the relayed connection limits, or nil if connections are relayed without
+// limits.
+func (r *Relay) Limit() *RelayLimit {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return r.rc.Limit
+}
+
 func (r *Relay) handleStream(s network.Stream) {
 	log.Infof("new relay stream from: %s", s.Conn().RemotePeer())
 
@@ -193,6 +236,30 @@ func (r *Relay) handleReserve(s network.Stream) pbv2.Status {
 		return pbv2.Status_PERMISSION_DENIED
 	}
 
+	if !r.rsvpACL.allow(p, a) {
+		log.Debugf("refusing relay reservation for %s; not allowed to reserve", p)
+		r.handleError(s, pbv2.Status_PERMISSION_DENIED)
+		return pbv2.Status_PERMISSION_DENIED
+	}
+
+	decision := ReservationDecision{Accept: true}
+	if r.authorizer != nil {
+		req := ReservationRequest{Peer: p, Addr: a}
+		r.mx.Lock()
+		if expire, ok := r.rsvp[p]; ok {
+			usage := r.usageUnlocked(p, expire, time.Now())
+			req.Usage = &usage
+		}
+		r.mx.Unlock()
+
+		decision = r.authorizer(req)
+		if !decision.Accept {
+			log.Debugf("refusing relay reservation for %s; rejected by authorizer", p)
+			r.handleError(s, pbv2.Status_RESERVATION_REFUSED)
+			return pbv2.Status_RESERVATION_REFUSED
+		}
+	}
+
 	r.mx.Lock()
 	// Check if relay is still active. Otherwise ConnManager.UnTagPeer will not be called if this block runs after
 	// Close() call
@@ -214,6 +281,18 @@ func (r *Relay) handleReserve(s network.Stream) pbv2.Status {
 	}
 
 	r.rsvp[p] = expire
+	usage, ok := r.usage[p]
+	if !ok {
+		usage = &reservationUsage{}
+		r.usage[p] = usage
+	}
+	// The decision is made again on every refresh, so it replaces the previous one.
+	budget := r.budgetFor(p)
+	if decision.Budget != nil {
+		budget = decision.Budget
+	}
+	usage.budget.Store(budget)
+	usage.limit = decision.Limit
 	r.host.ConnManager().TagPeer(p, "relay-reservation", ReservationTagWeight)
 	r.mx.Unlock()
 	if r.metricsTracer != nil {
@@ -289,6 +368,25 @@ func (r *Relay) handleConnect(s network.Stream, msg *pbv2.HopMessage) pbv2.Statu
 		return pbv2.Status_NO_RESERVATION
 	}
 
+	connStTime := time.Now()
+	usage := r.usage[dest.ID]
+	limit := r.rc.Limit
+	if usage.limit != nil {
+		limit = usage.limit
+	}
+	limit = r.scaleLimitUnlocked(limit)
+	remainingDuration, limitDuration := usage.remainingDuration(connStTime)
+	remainingData, limitData := usage.remainingData()
+	if (limitDuration && remainingDuration == 0) || (limitData && remainingData == 0) {
+		r.mx.Unlock()
+		log.Debugf("refusing connection from %s to %s; reservation budget exceeded", src, dest.ID)
+		if mt, ok := r.metricsTracer.(BudgetMetricsTracer); ok {
+			mt.ReservationBudgetExceeded(limitData && remainingData == 0)
+		}
+		fail(pbv2.Status_RESOURCE_LIMIT_EXCEEDED)
+		return pbv2.Status_RESOURCE_LIMIT_EXCEEDED
+	}
+
 	srcConns := r.conns[src]
 	if srcConns >= r.rc.MaxCircuits {
 		r.mx.Unlock()
@@ -307,18 +405,21 @@ func (r *Relay) handleConnect(s network.Stream, msg *pbv2.HopMessage) pbv2.Statu
 
 	r.addConn(src)
 	r.addConn(dest.ID)
+	usage.open(connStTime)
 	r.mx.Unlock()
 
 	if r.metricsTracer != nil {
 		r.metricsTracer.ConnectionOpened()
 	}
-	connStTime := time.Now()
 
+	circ := &circuit{src: src, dest: dest.ID, start: connStTime}
 	cleanup := func() {
 		defer span.Done()
 		r.mx.Lock()
 		r.rmConn(src)
 		r.rmConn(dest.ID)
+		usage.close(time.Now())
+		delete(r.circuits, circ.id)
 		r.mx.Unlock()
 		if r.metricsTracer != nil {
 			r.metricsTracer.ConnectionClosed(time.Since(connStTime))
@@ -417,6 +518,16 @@ func (r *Relay) handleConnect(s network.Stream, msg *pbv2.HopMessage) pbv2.Statu
 
 	log.Infof("relaying connection from %s to %s", src, dest.ID)
 
+	circ.reset = func() {
+		s.Reset()
+		bs.Reset()
+	}
+	r.mx.Lock()
+	r.nextCircuitID++
+	circ.id = r.nextCircuitID
+	r.circuits[circ.id] = circ
+	r.mx.Unlock()
+
 	var goroutines atomic.Int32
 	goroutines.Store(2)
 
@@ -428,15 +539,26 @@ func (r *Relay) handleConnect(s network.Stream, msg *pbv2.HopMessage) pbv2.Statu
 		}
 	}
 
-	if r.rc.Limit != nil {
-		deadline := time.Now().Add(r.rc.Limit.Duration)
+	var deadline time.Time
+	if limit != nil && limit.Duration > 0 {
+		deadline = time.Now().Add(limit.Duration)
+	}
+	if limitDuration {
+		if budgetDeadline := connStTime.Add(remainingDuration); deadline.IsZero() || budgetDeadline.Before(deadline) {
+			deadline = budgetDeadline
+		}
+	}
+	if !deadline.IsZero() {
 		s.SetDeadline(deadline)
 		bs.SetDeadline(deadline)
-		go r.relayLimited(s, bs, src, dest.ID, r.rc.Limit.Data, done)
-		go r.relayLimited(bs, s, dest.ID, src, r.rc.Limit.Data, done)
+	}
+
+	if limit != nil && limit.Data > 0 {
+		go r.relayLimited(s, bs, src, dest.ID, limit.Data, usage, circ, done)
+		go r.relayLimited(bs, s, dest.ID, src, limit.Data, usage, circ, done)
 	} else {
-		go r.relayUnlimited(s, bs, src, dest.ID, done)
-		go r.relayUnlimited(bs, s, dest.ID, src, done)
+		go r.relayUnlimited(s, bs, src, dest.ID, usage, circ, done)
+		go r.relayUnlimited(bs, s, dest.ID, src, usage, circ, done)
 	}
 
 	return pbv2.Status_OK
@@ -462,7 +584,7 @@ func (r *Relay) rmConn(p peer.ID) {
 	}
 }
 
-func (r *Relay) relayLimited(src, dest network.Stream, srcID, destID peer.ID, limit int64, done func()) {
+func (r *Relay) relayLimited(src, dest network.Stream, srcID, destID peer.ID, limit int64, usage *reservationUsage, circ *circuit, done func()) {
 	defer done()
 
 	buf := pool.Get(r.rc.BufferSize)
@@ -470,7 +592,7 @@ func (r *Relay) relayLimited(src, dest network.Stream, srcID, destID peer.ID, li
 
 	limitedSrc := io.LimitReader(src, limit)
 
-	count, err := r.copyWithBuffer(dest, limitedSrc, buf)
+	count, err := r.copyWithBuffer(dest, limitedSrc, buf, usage, circ)
 	if err != nil {
 		log.Debugf("relay copy error: %s", err)
 		// Reset both.
@@ -488,13 +610,13 @@ func (r *Relay) relayLimited(src, dest network.Stream, srcID, destID peer.ID, li
 	log.Debugf("relayed %d bytes from %s to %s", count, srcID, destID)
 }
 
-func (r *Relay) relayUnlimited(src, dest network.Stream, srcID, destID peer.ID, done func()) {
+func (r *Relay) relayUnlimited(src, dest network.Stream, srcID, destID peer.ID, usage *reservationUsage, circ *circuit, done func()) {
 	defer done()
 
 	buf := pool.Get(r.rc.BufferSize)
 	defer pool.Put(buf)
 
-	count, err := r.copyWithBuffer(dest, src, buf)
+	count, err := r.copyWithBuffer(dest, src, buf, usage, circ)
 	if err != nil {
 		log.Debugf("relay copy error: %s", err)
 		// Reset both.
@@ -513,13 +635,28 @@ func (r *Relay) relayUnlimited(src, dest network.Stream, srcID, destID peer.ID,
 var errInvalidWrite = errors.New("invalid write result")
 
 // copyWithBuffer copies from src to dst using the provided buf until either EOF is reached
-// on src or an error occurs. It reports the number of bytes transferred to metricsTracer.
+// on src or an error occurs. It reports the number of bytes transferred to metricsTracer,
+// and accounts them to the reservation usage and the circuit, failing once the data budget of
+// the reservation is exhausted.
 // The implementation is a modified form of io.CopyBuffer to support metrics tracking.
-func (r *Relay) copyWithBuffer(dst io.Writer, src io.Reader, buf []byte) (written int64, err error) {
+func (r *Relay) copyWithBuffer(dst io.Writer, src io.Reader, buf []byte, usage *reservationUsage, circ *circuit) (written int64, err error) {
 	for {
-		nr, er := src.Read(buf)
+		b := buf
+		if remaining, ok := usage.remainingData(); ok {
+			if remaining == 0 {
+				if mt, ok := r.metricsTracer.(BudgetMetricsTracer); ok {
+					mt.ReservationBudgetExceeded(true)
+				}
+				err = errBudgetExceeded
+				break
+			}
+			if remaining < int64(len(b)) {
+				b = b[:remaining]
+			}
+		}
+		nr, er := src.Read(b)
 		if nr > 0 {
-			nw, ew := dst.Write(buf[0:nr])
+			nw, ew := dst.Write(b[0:nr])
 			if nw < 0 || nr < nw {
 				nw = 0
 				if ew == nil {
@@ -527,6 +664,8 @@ func (r *Relay) copyWithBuffer(dst io.Writer, src io.Reader, buf []byte) (writte
 				}
 			}
 			written += int64(nw)
+			usage.data.Add(int64(nw))
+			circ.data.Add(int64(nw))
 			if ew != nil {
 				err = ew
 				break
@@ -638,28 +777,69 @@ func makeReservationMsg(
 	return rsvp
 }
 
-func (r *Relay) makeLimitMsg(_ peer.ID) *pbv2.Limit {
-	if r.rc.Limit == nil {
-		return nil
-	}
+// makeLimitMsg returns the limits of a connection relayed to p, taking the remaining budget of
+// its reservation into account.
+func (r *Relay) makeLimitMsg(p peer.ID) *pbv2.Limit {
+	var (
+		duration time.Duration
+		data     int64
+	)
 
-	duration := uint32(r.rc.Limit.Duration / time.Second)
-	data := uint64(r.rc.Limit.Data)
+	r.mx.Lock()
+	rl := r.rc.Limit
+	u, ok := r.usage[p]
+	if ok && u.limit != nil {
+		rl = u.limit
+	}
+	rl = r.scaleLimitUnlocked(rl)
+	if rl != nil {
+		duration, data = rl.Duration, rl.Data
+	}
+	if ok {
+		if remaining, ok := u.remainingDuration(time.Now()); ok && (duration == 0 || remaining < duration) {
+			duration = remaining
+		}
+		if remaining, ok := u.remainingData(); ok && (data == 0 || remaining < data) {
+			data = remaining
+		}
+	}
+	r.mx.Unlock()
 
-	return &pbv2.Limit{
-		Duration: &duration,
-		Data:     &data,
+	if duration == 0 && data == 0 {
+		return nil
+	}
+	var limit pbv2.Limit
+	if duration > 0 {
+		d := uint32(duration / time.Second)
+		limit.Duration = &d
 	}
+	if data > 0 {
+		d := uint64(data)
+		limit.Data = &d
+	}
+	return &limit
 }
 
 func (r *Relay) background() {
 	ticker := time.NewTicker(time.Minute)
 	defer ticker.Stop()
 
+	// scalingCh stays nil if the limits are not scaled.
+	var scalingCh <-chan time.Time
+	if r.scaling != nil {
+		scalingTicker := time.NewTicker(r.scaling.Interval)
+		defer scalingTicker.Stop()
+		scalingCh = scalingTicker.C
+	}
+
 	for {
 		select {
 		case <-ticker.C:
 			r.gc()
+		case <-scalingCh:
+			if utilization, ok := systemUtilization(r.host.Network().ResourceManager()); ok {
+				r.updateScaling(utilization)
+			}
 		case <-r.ctx.Done():
 			return
 		}
@@ -675,6 +855,7 @@ func (r *Relay) gc() {
 	for p, expire := range r.rsvp {
 		if r.closed || expire.Before(now) {
 			delete(r.rsvp, p)
+			r.closeUsage(p)
 			r.host.ConnManager().UntagPeer(p, "relay-reservation")
 			cnt++
 		}
@@ -690,6 +871,18 @@ func (r *Relay) gc() {
 	}
 }
 
+// closeUsage stops metering the usage of a reservation that ended.
+func (r *Relay) closeUsage(p peer.ID) {
+	u, ok := r.usage[p]
+	if !ok {
+		return
+	}
+	delete(r.usage, p)
+	if mt, ok := r.metricsTracer.(BudgetMetricsTracer); ok {
+		mt.ReservationDataRelayed(u.data.Load())
+	}
+}
+
 func (r *Relay) disconnected(n network.Network, c network.Conn) {
 	p := c.RemotePeer()
 	if n.Connectedness(p) == network.Connected {
@@ -700,6 +893,7 @@ func (r *Relay) disconnected(n network.Network, c network.Conn) {
 	_, ok := r.rsvp[p]
 	if ok {
 		delete(r.rsvp, p)
+		r.closeUsage(p)
 	}
 	r.constraints.cleanupPeer(p)
 	r.mx.Unlock()
@@ -2,11 +2,14 @@ package relay
 
 import (
 	"crypto/rand"
+	"net/netip"
 	"testing"
 	"time"
 
 	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
+	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
 	"github.com/stretchr/testify/require"
 
 	ma "github.com/multiformats/go-multiaddr"
@@ -51,3 +54,124 @@ func TestMakeReservationWithP2PAddrs(t *testing.T) {
 
 	require.Equal(t, expectedAddrs, addrsFromRsvp)
 }
+
+func TestReservationACL(t *testing.T) {
+	_, p1 := genKeyAndID(t)
+	_, p2 := genKeyAndID(t)
+	_, p3 := genKeyAndID(t)
+	inside := ma.StringCast("/ip4/10.1.2.3/tcp/1234")
+	outside := ma.StringCast("/ip4/1.2.3.4/tcp/1234")
+	v6 := ma.StringCast("/ip6/2001:db8::1/udp/1234/quic-v1")
+
+	var r Relay
+	require.True(t, r.rsvpACL.allow(p1, outside))
+
+	for _, opt := range []Option{
+		WithReservationAllowedPeers(p1),
+		WithReservationAllowedSubnets(netip.MustParsePrefix("10.0.0.0/8")),
+		WithReservationDeniedSubnets(netip.MustParsePrefix("2001:db8::/32")),
+		WithReservationDeniedPeers(p3),
+	} {
+		require.NoError(t, opt(&r))
+	}
+	require.True(t, r.rsvpACL.allow(p1, outside))
+	require.True(t, r.rsvpACL.allow(p2, inside))
+	require.False(t, r.rsvpACL.allow(p2, outside))
+	// Denylists take precedence.
+	require.False(t, r.rsvpACL.allow(p1, v6))
+	require.False(t, r.rsvpACL.allow(p3, inside))
+
+	require.NoError(t, WithReservationFilter(func(p peer.ID, _ ma.Multiaddr) bool { return p != p1 })(&r))
+	require.False(t, r.rsvpACL.allow(p1, outside))
+	require.True(t, r.rsvpACL.allow(p2, inside))
+}
+
+func TestSystemUtilization(t *testing.T) {
+	cfg := rcmgr.PartialLimitConfig{
+		System: rcmgr.ResourceLimits{Conns: 4, ConnsInbound: 4},
+	}.Build(rcmgr.InfiniteLimits)
+	rm, err := rcmgr.NewResourceManager(rcmgr.NewFixedLimiter(cfg))
+	require.NoError(t, err)
+	defer rm.Close()
+
+	for i := 0; i < 3; i++ {
+		c, err := rm.OpenConnection(network.DirInbound, false, ma.StringCast("/ip4/1.2.3.4/tcp/1"))
+		require.NoError(t, err)
+		defer c.Done()
+	}
+	u, ok := systemUtilization(rm)
+	require.True(t, ok)
+	require.Equal(t, 0.75, u)
+
+	_, ok = systemUtilization(&network.NullResourceManager{})
+	require.False(t, ok)
+}
+
+func TestResourceScaling(t *testing.T) {
+	r := &Relay{rc: DefaultResources()}
+	r.rc.MaxReservations = 4
+	r.rc.Scaling = &ResourceScaling{High: 0.8}
+	var err error
+	r.scaling, err = r.rc.Scaling.withDefaults()
+	require.NoError(t, err)
+	require.InDelta(t, 0.6, r.scaling.Low, 1e-9)
+	r.constraints = newConstraints(&r.rc)
+
+	_, p := genKeyAndID(t)
+	a := ma.StringCast("/ip4/1.2.3.4/tcp/1")
+	expiry := time.Now().Add(time.Hour)
+
+	limit := r.makeLimitMsg(p)
+	require.Equal(t, uint32(120), limit.GetDuration())
+	require.Equal(t, uint64(1<<17), limit.GetData())
+
+	r.updateScaling(0.9)
+	require.True(t, r.scaled)
+	limit = r.makeLimitMsg(p)
+	require.Equal(t, uint32(30), limit.GetDuration())
+	require.Equal(t, uint64(1<<15), limit.GetData())
+	// MaxReservations is scaled to 1.
+	require.NoError(t, r.constraints.Reserve(p, a, expiry))
+	_, p2 := genKeyAndID(t)
+	require.ErrorIs(t, r.constraints.Reserve(p2, a, expiry), errTooManyReservations)
+
+	// Limits are only restored below the low watermark.
+	r.updateScaling(0.7)
+	require.True(t, r.scaled)
+	r.updateScaling(0.5)
+	require.False(t, r.scaled)
+	require.NoError(t, r.constraints.Reserve(p2, a, expiry))
+	require.Equal(t, uint32(120), r.makeLimitMsg(p).GetDuration())
+}
+
+func TestResourceScalingLowWatermark(t *testing.T) {
+	s, err := (&ResourceScaling{High: 0.4}).withDefaults()
+	require.NoError(t, err)
+	require.InDelta(t, 0.3, s.Low, 1e-9)
+
+	_, err = (&ResourceScaling{High: 0.5, Low: 0.5}).withDefaults()
+	require.ErrorIs(t, err, errInvalidScaling)
+	_, err = (&ResourceScaling{Low: 0.9}).withDefaults()
+	require.ErrorIs(t, err, errInvalidScaling)
+}
+
+func TestReservationUsageDuration(t *testing.T) {
+	u := &reservationUsage{}
+	u.budget.Store(&ReservationBudget{Duration: time.Minute})
+	start := time.Now()
+	u.open(start)
+	u.open(start.Add(10 * time.Second))
+	// Concurrent circuits are charged once.
+	require.Equal(t, 20*time.Second, u.totalDuration(start.Add(20*time.Second)))
+	u.close(start.Add(30 * time.Second))
+	remaining, ok := u.remainingDuration(start.Add(30 * time.Second))
+	require.True(t, ok)
+	require.Equal(t, 30*time.Second, remaining)
+	u.close(start.Add(40 * time.Second))
+
+	// Time without circuits isn't charged.
+	require.Equal(t, 40*time.Second, u.totalDuration(start.Add(time.Hour)))
+	u.open(start.Add(time.Hour))
+	remaining, _ = u.remainingDuration(start.Add(time.Hour + 10*time.Second))
+	require.Equal(t, 10*time.Second, remaining)
+}
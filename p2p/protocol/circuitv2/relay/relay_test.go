@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sync"
 	"testing"
 	"time"
 
@@ -382,3 +383,240 @@ func TestRelayLimitData(t *testing.T) {
 	}
 
 }
+
+func TestRelayReservationBudget(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts, upgraders := getNetHosts(t, ctx, 3)
+	addTransport(t, hosts[0], upgraders[0])
+	addTransport(t, hosts[2], upgraders[2])
+
+	hosts[0].SetStreamHandler("test", func(s network.Stream) {
+		defer s.Close()
+		io.Copy(io.Discard, s)
+	})
+
+	const budget = 1 << 15
+	rc := relay.DefaultResources()
+	rc.ReservationBudget = &relay.ReservationBudget{Data: budget}
+	r, err := relay.New(hosts[1], relay.WithResources(rc))
+	require.NoError(t, err)
+	defer r.Close()
+
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[1], hosts[2])
+
+	rinfo := hosts[1].Peerstore().PeerInfo(hosts[1].ID())
+	_, err = client.Reserve(ctx, hosts[0], rinfo)
+	require.NoError(t, err)
+
+	rsvps := r.Reservations()
+	require.Len(t, rsvps, 1)
+	require.Equal(t, hosts[0].ID(), rsvps[0].Peer)
+	require.Equal(t, rc.ReservationBudget, rsvps[0].Budget)
+
+	raddr := ma.StringCast(fmt.Sprintf("/p2p/%s/p2p-circuit/p2p/%s", hosts[1].ID(), hosts[0].ID()))
+	require.NoError(t, hosts[2].Connect(ctx, peer.AddrInfo{ID: hosts[0].ID(), Addrs: []ma.Multiaddr{raddr}}))
+
+	s, err := hosts[2].NewStream(network.WithAllowLimitedConn(ctx, "test"), hosts[0].ID(), "test")
+	require.NoError(t, err)
+	require.Equal(t, 1, r.Reservations()[0].Circuits)
+
+	// The connection is reset once the budget is exhausted, even if the reservation is
+	// refreshed meanwhile.
+	buf := make([]byte, 4096)
+	var refreshed bool
+	require.Eventually(t, func() bool {
+		if !refreshed {
+			_, err := client.Reserve(ctx, hosts[0], rinfo)
+			require.NoError(t, err)
+			refreshed = true
+		}
+		_, err := s.Write(buf)
+		return err != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool { return r.Reservations()[0].Circuits == 0 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, int64(budget), r.Reservations()[0].Data)
+
+	// No more connections are relayed to the peer.
+	for _, c := range hosts[2].Network().ConnsToPeer(hosts[0].ID()) {
+		c.Close()
+	}
+	require.Error(t, hosts[2].Connect(ctx, peer.AddrInfo{ID: hosts[0].ID(), Addrs: []ma.Multiaddr{raddr}}))
+}
+
+func TestRelayReservationAuthorizer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts, _ := getNetHosts(t, ctx, 3)
+
+	var (
+		mx       sync.Mutex
+		requests []relay.ReservationRequest
+	)
+	auth := func(req relay.ReservationRequest) relay.ReservationDecision {
+		mx.Lock()
+		requests = append(requests, req)
+		mx.Unlock()
+		if req.Peer != hosts[0].ID() {
+			return relay.ReservationDecision{}
+		}
+		switch len(requests) {
+		case 1:
+			return relay.ReservationDecision{
+				Accept: true,
+				Limit:  &relay.RelayLimit{Duration: time.Hour, Data: 1 << 20},
+			}
+		case 3:
+			// the default limit applies
+			return relay.ReservationDecision{Accept: true}
+		default:
+			// no limit
+			return relay.ReservationDecision{Accept: true, Limit: &relay.RelayLimit{}}
+		}
+	}
+	r, err := relay.New(hosts[1], relay.WithReservationAuthorizer(auth))
+	require.NoError(t, err)
+	defer r.Close()
+
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[1], hosts[2])
+
+	rinfo := hosts[1].Peerstore().PeerInfo(hosts[1].ID())
+	rsvp, err := client.Reserve(ctx, hosts[0], rinfo)
+	require.NoError(t, err)
+	require.Equal(t, time.Hour, rsvp.LimitDuration)
+	require.Equal(t, uint64(1<<20), rsvp.LimitData)
+
+	_, err = client.Reserve(ctx, hosts[2], rinfo)
+	require.Error(t, err)
+	require.Len(t, r.Reservations(), 1)
+
+	// Refreshing the reservation reports its usage, and replaces the previous decision.
+	rsvp, err = client.Reserve(ctx, hosts[0], rinfo)
+	require.NoError(t, err)
+	require.Equal(t, relay.DefaultLimit().Duration, rsvp.LimitDuration)
+	require.Equal(t, uint64(relay.DefaultLimit().Data), rsvp.LimitData)
+
+	rsvp, err = client.Reserve(ctx, hosts[0], rinfo)
+	require.NoError(t, err)
+	require.Zero(t, rsvp.LimitDuration)
+	require.Zero(t, rsvp.LimitData)
+
+	mx.Lock()
+	defer mx.Unlock()
+	require.Len(t, requests, 4)
+	require.Nil(t, requests[0].Usage)
+	require.NotNil(t, requests[0].Addr)
+	require.NotNil(t, requests[2].Usage)
+	require.Equal(t, hosts[0].ID(), requests[2].Usage.Peer)
+}
+
+func TestRelaySetLimit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts, _ := getNetHosts(t, ctx, 2)
+
+	r, err := relay.New(hosts[1])
+	require.NoError(t, err)
+	defer r.Close()
+
+	connect(t, hosts[0], hosts[1])
+	rinfo := hosts[1].Peerstore().PeerInfo(hosts[1].ID())
+
+	rsvp, err := client.Reserve(ctx, hosts[0], rinfo)
+	require.NoError(t, err)
+	require.Equal(t, relay.DefaultLimit().Duration, rsvp.LimitDuration)
+
+	// The new limits are communicated when refreshing the reservation.
+	limit := &relay.RelayLimit{Duration: time.Hour, Data: 1 << 20}
+	r.SetLimit(limit)
+	require.Equal(t, limit, r.Limit())
+	rsvp, err = client.Reserve(ctx, hosts[0], rinfo)
+	require.NoError(t, err)
+	require.Equal(t, time.Hour, rsvp.LimitDuration)
+	require.Equal(t, uint64(1<<20), rsvp.LimitData)
+
+	r.SetLimit(nil)
+	require.Nil(t, r.Limit())
+	rsvp, err = client.Reserve(ctx, hosts[0], rinfo)
+	require.NoError(t, err)
+	require.Zero(t, rsvp.LimitDuration)
+	require.Zero(t, rsvp.LimitData)
+}
+
+func TestRelayRevoke(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	hosts, upgraders := getNetHosts(t, ctx, 3)
+	addTransport(t, hosts[0], upgraders[0])
+	addTransport(t, hosts[2], upgraders[2])
+
+	hosts[0].SetStreamHandler("test", func(s network.Stream) {
+		defer s.Close()
+		io.Copy(io.Discard, s)
+	})
+
+	r, err := relay.New(hosts[1])
+	require.NoError(t, err)
+	defer r.Close()
+
+	connect(t, hosts[0], hosts[1])
+	connect(t, hosts[1], hosts[2])
+
+	rinfo := hosts[1].Peerstore().PeerInfo(hosts[1].ID())
+	_, err = client.Reserve(ctx, hosts[0], rinfo)
+	require.NoError(t, err)
+
+	raddr := ma.StringCast(fmt.Sprintf("/p2p/%s/p2p-circuit/p2p/%s", hosts[1].ID(), hosts[0].ID()))
+	relayedStream := func() network.Stream {
+		t.Helper()
+		require.NoError(t, hosts[2].Connect(ctx, peer.AddrInfo{ID: hosts[0].ID(), Addrs: []ma.Multiaddr{raddr}}))
+		s, err := hosts[2].NewStream(network.WithAllowLimitedConn(ctx, "test"), hosts[0].ID(), "test")
+		require.NoError(t, err)
+		return s
+	}
+	closeConns := func() {
+		for _, c := range hosts[2].Network().ConnsToPeer(hosts[0].ID()) {
+			c.Close()
+		}
+	}
+	reset := func(s network.Stream) bool {
+		_, err := s.Write(make([]byte, 1024))
+		return err != nil
+	}
+
+	s := relayedStream()
+	_, err = s.Write(make([]byte, 1024))
+	require.NoError(t, err)
+
+	circuits := r.Circuits()
+	require.Len(t, circuits, 1)
+	require.Equal(t, hosts[2].ID(), circuits[0].Src)
+	require.Equal(t, hosts[0].ID(), circuits[0].Dest)
+	require.Eventually(t, func() bool { return r.Circuits()[0].Data >= 1024 }, 5*time.Second, 10*time.Millisecond)
+
+	// Revoking a circuit keeps the reservation.
+	require.True(t, r.RevokeCircuit(circuits[0].ID))
+	require.False(t, r.RevokeCircuit(circuits[0].ID+1))
+	require.Eventually(t, func() bool { return reset(s) }, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool { return len(r.Circuits()) == 0 }, 5*time.Second, 10*time.Millisecond)
+	require.Len(t, r.Reservations(), 1)
+
+	// Revoking the reservation also resets the circuits to the peer.
+	closeConns()
+	s = relayedStream()
+	require.Len(t, r.Circuits(), 1)
+	require.True(t, r.RevokeReservation(hosts[0].ID()))
+	require.False(t, r.RevokeReservation(hosts[0].ID()))
+	require.Empty(t, r.Reservations())
+	require.Eventually(t, func() bool { return reset(s) }, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool { return len(r.Circuits()) == 0 }, 5*time.Second, 10*time.Millisecond)
+
+	closeConns()
+	require.Error(t, hosts[2].Connect(ctx, peer.AddrInfo{ID: hosts[0].ID(), Addrs: []ma.Multiaddr{raddr}}))
+}
@@ -9,6 +9,15 @@ type Resources struct {
 	// Limit is the (optional) relayed connection limits.
 	Limit *RelayLimit
 
+	// ReservationBudget is the (optional) limit on the total usage of a reservation, across
+	// all the connections relayed to the peer holding it. The budget of specific peers can
+	// be set with WithReservationBudget.
+	ReservationBudget *ReservationBudget
+
+	// Scaling (optional) shrinks MaxReservations and Limit while the resource manager is running
+	// out of system resources.
+	Scaling *ResourceScaling
+
 	// ReservationTTL is the duration of a new (or refreshed reservation).
 	// Defaults to 1hr.
 	ReservationTTL time.Duration
@@ -36,9 +45,21 @@ type Resources struct {
 // RelayLimit are the per relayed connection resource limits.
 type RelayLimit struct {
 	// Duration is the time limit before resetting a relayed connection; defaults to 2min.
+	// 0 means no limit.
 	Duration time.Duration
 	// Data is the limit of data relayed (on each direction) before resetting the connection.
-	// Defaults to 128KB
+	// Defaults to 128KB. 0 means no limit.
+	Data int64
+}
+
+// ReservationBudget limits the total usage of a reservation. The usage accumulates across
+// refreshes of the reservation, until it expires or the peer disconnects.
+type ReservationBudget struct {
+	// Duration is the total wall-clock time during which connections are relayed to the
+	// peer; 0 means no limit. Concurrent connections are charged once, and all of them are
+	// closed when the budget runs out.
+	Duration time.Duration
+	// Data is the total data relayed in both directions; 0 means no limit.
 	Data int64
 }
 
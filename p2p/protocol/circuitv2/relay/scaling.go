@@ -0,0 +1,111 @@
+package relay
+
+import (
+	"errors"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+)
+
+// ResourceUtilization is implemented by resource managers that can report how close the system
+// scope is to its limits, like the one in p2p/host/resource-manager.
+type ResourceUtilization interface {
+	// SystemUtilization returns the utilization of the system scope; 1 means a limit is reached.
+	SystemUtilization() float64
+}
+
+// ResourceScaling shrinks the relay limits while the resource manager is running out of
+// system resources, and restores them once it recovers.
+// The resource manager must implement ResourceUtilization, otherwise the limits are never scaled.
+type ResourceScaling struct {
+	// High is the utilization above which the limits are shrunk; defaults to 0.8.
+	High float64
+	// Low is the utilization below which the limits are restored; it must be lower than High.
+	// Defaults to 3/4 of High.
+	Low float64
+	// Factor scales MaxReservations and the relayed connection limits while they are shrunk;
+	// defaults to 0.25.
+	Factor float64
+	// Interval is the interval at which the utilization is checked; defaults to 10s.
+	Interval time.Duration
+}
+
+// DefaultResourceScaling returns a ResourceScaling object with the defaults filled in.
+func DefaultResourceScaling() *ResourceScaling {
+	return &ResourceScaling{
+		High:     0.8,
+		Low:      0.6,
+		Factor:   0.25,
+		Interval: 10 * time.Second,
+	}
+}
+
+// lowFraction is the default ratio of Low to High.
+const lowFraction = 0.75
+
+var errInvalidScaling = errors.New("relay resource scaling: Low must be lower than High")
+
+func (s *ResourceScaling) withDefaults() (*ResourceScaling, error) {
+	res := *s
+	def := DefaultResourceScaling()
+	if res.High <= 0 {
+		res.High = def.High
+	}
+	if res.Low <= 0 {
+		res.Low = res.High * lowFraction
+	}
+	if res.Low >= res.High {
+		return nil, errInvalidScaling
+	}
+	if res.Factor <= 0 {
+		res.Factor = def.Factor
+	}
+	if res.Interval <= 0 {
+		res.Interval = def.Interval
+	}
+	return &res, nil
+}
+
+// systemUtilization returns the utilization of the system scope of the resource manager, and
+// false if it doesn't implement ResourceUtilization.
+func systemUtilization(rm network.ResourceManager) (float64, bool) {
+	u, ok := rm.(ResourceUtilization)
+	if !ok {
+		return 0, false
+	}
+	return u.SystemUtilization(), true
+}
+
+// updateScaling shrinks or restores the limits according to the utilization.
+func (r *Relay) updateScaling(utilization float64) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	switch {
+	case !r.scaled && utilization >= r.scaling.High:
+		log.Infof("shrinking relay limits; resource utilization at %.0f%%", utilization*100)
+		r.scaled = true
+		r.constraints.setScale(r.scaling.Factor)
+	case r.scaled && utilization <= r.scaling.Low:
+		log.Infof("restoring relay limits; resource utilization at %.0f%%", utilization*100)
+		r.scaled = false
+		r.constraints.setScale(1)
+	}
+}
+
+// scaleLimitUnlocked returns the relayed connection limit to apply, given the configured one.
+// Assumes the caller holds the mutex.
+func (r *Relay) scaleLimitUnlocked(limit *RelayLimit) *RelayLimit {
+	if !r.scaled || limit == nil {
+		return limit
+	}
+	scaled := &RelayLimit{}
+	// zero means no limit, and stays so
+	if limit.Duration > 0 {
+		scaled.Duration = max(time.Duration(float64(limit.Duration)*r.scaling.Factor), time.Second)
+	}
+	if limit.Data > 0 {
+		scaled.Data = max(int64(float64(limit.Data)*r.scaling.Factor), 1)
+	}
+	return scaled
+}
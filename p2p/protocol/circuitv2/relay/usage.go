@@ -0,0 +1,196 @@
+package relay
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+var errBudgetExceeded = errors.New("reservation budget exceeded")
+
+// ReservationUsage is the usage of an active reservation.
+type ReservationUsage struct {
+	Peer       peer.ID
+	Expiration time.Time
+
+	// Data is the data relayed in both directions, across all connections.
+	Data int64
+	// Duration is the wall-clock time during which at least one connection was
+	// relayed to the peer, including the current one.
+	Duration time.Duration
+	// Circuits is the number of open relayed connections.
+	Circuits int
+
+	// Budget is the budget of the reservation, or nil if it's unlimited.
+	Budget *ReservationBudget
+}
+
+// reservationUsage meters the usage of a reservation. The fields are guarded
+// by the Relay's mutex, except for data and budget, which are read by the
+// goroutines relaying the connections.
+type reservationUsage struct {
+	// budget is replaced when the reservation is refreshed; nil means unlimited.
+	budget atomic.Pointer[ReservationBudget]
+	// limit is the limit of the connections relayed to the peer set by the
+	// ReservationAuthorizer. If nil, Resources.Limit applies.
+	limit *RelayLimit
+
+	data atomic.Int64
+
+	// duration is the wall-clock time of the past periods during which
+	// connections were relayed. Concurrent connections are charged once, so
+	// that all of them end when the budget runs out.
+	duration time.Duration
+	circuits int
+	// busySince is when the current period of relayed connections started.
+	// Only valid if circuits > 0.
+	busySince time.Time
+}
+
+func (u *reservationUsage) open(now time.Time) {
+	if u.circuits == 0 {
+		u.busySince = now
+	}
+	u.circuits++
+}
+
+func (u *reservationUsage) close(now time.Time) {
+	u.circuits--
+	if u.circuits == 0 {
+		u.duration += now.Sub(u.busySince)
+	}
+}
+
+func (u *reservationUsage) totalDuration(now time.Time) time.Duration {
+	if u.circuits == 0 {
+		return u.duration
+	}
+	return u.duration + now.Sub(u.busySince)
+}
+
+// remainingDuration returns the duration left in the budget, and false if it
+// has no duration limit.
+func (u *reservationUsage) remainingDuration(now time.Time) (time.Duration, bool) {
+	b := u.budget.Load()
+	if b == nil || b.Duration <= 0 {
+		return 0, false
+	}
+	return max(b.Duration-u.totalDuration(now), 0), true
+}
+
+// remainingData returns the data left in the budget, and false if it has no
+// data limit.
+func (u *reservationUsage) remainingData() (int64, bool) {
+	b := u.budget.Load()
+	if b == nil || b.Data <= 0 {
+		return 0, false
+	}
+	return max(b.Data-u.data.Load(), 0), true
+}
+
+// budgetFor returns the reservation budget of a peer.
+func (r *Relay) budgetFor(p peer.ID) *ReservationBudget {
+	if b, ok := r.budgets[p]; ok {
+		return b
+	}
+	return r.rc.ReservationBudget
+}
+
+// Reservations returns the usage of the active reservations.
+func (r *Relay) Reservations() []ReservationUsage {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	now := time.Now()
+	res := make([]ReservationUsage, 0, len(r.rsvp))
+	for p, expire := range r.rsvp {
+		res = append(res, r.usageUnlocked(p, expire, now))
+	}
+	return res
+}
+
+func (r *Relay) usageUnlocked(p peer.ID, expire, now time.Time) ReservationUsage {
+	ru := ReservationUsage{Peer: p, Expiration: expire}
+	if u, ok := r.usage[p]; ok {
+		ru.Data = u.data.Load()
+		ru.Duration = u.totalDuration(now)
+		ru.Circuits = u.circuits
+		ru.Budget = u.budget.Load()
+	}
+	return ru
+}
+
+// RevokeReservation ends the reservation of a peer, and resets the connections relayed to it.
+// It returns false if the peer doesn't hold a reservation.
+// The peer can reserve a slot again, unless an ACL prevents it.
+func (r *Relay) RevokeReservation(p peer.ID) bool {
+	r.mx.Lock()
+	_, ok := r.rsvp[p]
+	if ok {
+		delete(r.rsvp, p)
+		r.closeUsage(p)
+		r.host.ConnManager().UntagPeer(p, "relay-reservation")
+	}
+	r.constraints.cleanupPeer(p)
+	var circuits []*circuit
+	for _, c := range r.circuits {
+		if c.dest == p {
+			circuits = append(circuits, c)
+		}
+	}
+	r.mx.Unlock()
+
+	for _, c := range circuits {
+		c.reset()
+	}
+	if ok && r.metricsTracer != nil {
+		r.metricsTracer.ReservationClosed(1)
+	}
+	return ok
+}
+
+// CircuitInfo describes a relayed connection.
+type CircuitInfo struct {
+	// ID identifies the circuit, see RevokeCircuit.
+	ID uint64
+	// Src is the peer that initiated the connection, Dest the peer holding the reservation.
+	Src, Dest peer.ID
+	Start     time.Time
+	// Data is the data relayed in both directions so far.
+	Data int64
+}
+
+// circuit is a relayed connection.
+type circuit struct {
+	id        uint64
+	src, dest peer.ID
+	start     time.Time
+	data      atomic.Int64
+	// reset resets both streams of the circuit.
+	reset func()
+}
+
+// Circuits returns the open relayed connections.
+func (r *Relay) Circuits() []CircuitInfo {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	res := make([]CircuitInfo, 0, len(r.circuits))
+	for _, c := range r.circuits {
+		res = append(res, CircuitInfo{ID: c.id, Src: c.src, Dest: c.dest, Start: c.start, Data: c.data.Load()})
+	}
+	return res
+}
+
+// RevokeCircuit resets a relayed connection. It returns false if there's no such circuit.
+func (r *Relay) RevokeCircuit(id uint64) bool {
+	r.mx.Lock()
+	c, ok := r.circuits[id]
+	r.mx.Unlock()
+	if ok {
+		c.reset()
+	}
+	return ok
+}
@@ -189,7 +189,7 @@ func (hp *holePuncher) directConnect(rp peer.ID) error {
 // exchanges the addresses and measures the RTT.
 func (hp *holePuncher) initiateHolePunch(rp peer.ID) ([]ma.Multiaddr, []ma.Multiaddr, time.Duration, error) {
 	hpCtx := network.WithAllowLimitedConn(hp.ctx, "hole-punch")
-	sCtx := network.WithNoDial(hpCtx, "hole-punch")
+	sCtx := network.WithStreamPriority(network.WithNoDial(hpCtx, "hole-punch"), network.StreamPriorityHigh)
 
 	str, err := hp.host.NewStream(sCtx, rp, Protocol)
 	if err != nil {
@@ -222,7 +222,7 @@ func (hp *holePuncher) initiateHolePunchImpl(str network.Stream) ([]ma.Multiaddr
 	str.SetDeadline(time.Now().Add(StreamTimeout))
 
 	// send a CONNECT and start RTT measurement.
-	obsAddrs := removeRelayAddrs(hp.listenAddrs())
+	obsAddrs := removeNonHolePunchableAddrs(hp.host.Network(), removeRelayAddrs(hp.listenAddrs()))
 	if hp.filter != nil {
 		obsAddrs = hp.filter.FilterLocal(str.Conn().RemotePeer(), obsAddrs)
 	}
@@ -250,7 +250,7 @@ func (hp *holePuncher) initiateHolePunchImpl(str network.Stream) ([]ma.Multiaddr
 		return nil, nil, 0, fmt.Errorf("expect CONNECT message, got %s", t)
 	}
 
-	addrs := removeRelayAddrs(addrsFromBytes(msg.ObsAddrs))
+	addrs := removeNonHolePunchableAddrs(hp.host.Network(), removeRelayAddrs(addrsFromBytes(msg.ObsAddrs)))
 	if hp.filter != nil {
 		addrs = hp.filter.FilterRemote(str.Conn().RemotePeer(), addrs)
 	}
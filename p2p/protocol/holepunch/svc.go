@@ -186,7 +186,7 @@ func (s *Service) incomingHolePunch(str network.Stream) (rtt time.Duration, remo
 	if !isRelayAddress(str.Conn().RemoteMultiaddr()) {
 		return 0, nil, nil, fmt.Errorf("received hole punch stream: %s", str.Conn().RemoteMultiaddr())
 	}
-	ownAddrs = s.listenAddrs()
+	ownAddrs = removeNonHolePunchableAddrs(s.host.Network(), s.listenAddrs())
 	if s.filter != nil {
 		ownAddrs = s.filter.FilterLocal(str.Conn().RemotePeer(), ownAddrs)
 	}
@@ -217,7 +217,7 @@ func (s *Service) incomingHolePunch(str network.Stream) (rtt time.Duration, remo
 		return 0, nil, nil, fmt.Errorf("expected CONNECT message from initiator but got %d", t)
 	}
 
-	obsDial := removeRelayAddrs(addrsFromBytes(msg.ObsAddrs))
+	obsDial := removeNonHolePunchableAddrs(s.host.Network(), removeRelayAddrs(addrsFromBytes(msg.ObsAddrs)))
 	if s.filter != nil {
 		obsDial = s.filter.FilterRemote(str.Conn().RemotePeer(), obsDial)
 	}
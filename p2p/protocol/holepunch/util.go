@@ -7,6 +7,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/transport"
 
 	ma "github.com/multiformats/go-multiaddr"
 )
@@ -15,6 +16,25 @@ func removeRelayAddrs(addrs []ma.Multiaddr) []ma.Multiaddr {
 	return slices.DeleteFunc(addrs, isRelayAddress)
 }
 
+// capabilityQuerier is implemented by the swarm.
+type capabilityQuerier interface {
+	TransportCapabilities(ma.Multiaddr) (transport.Capabilities, bool)
+}
+
+// removeNonHolePunchableAddrs removes the addresses whose transport doesn't
+// support hole punching. Addresses of transports that don't advertise their
+// capabilities are kept.
+func removeNonHolePunchableAddrs(n network.Network, addrs []ma.Multiaddr) []ma.Multiaddr {
+	q, ok := n.(capabilityQuerier)
+	if !ok {
+		return addrs
+	}
+	return slices.DeleteFunc(addrs, func(a ma.Multiaddr) bool {
+		caps, ok := q.TransportCapabilities(a)
+		return ok && !caps.Has(transport.CapabilityHolePunching)
+	})
+}
+
 func isRelayAddress(a ma.Multiaddr) bool {
 	_, err := a.ValueForProtocol(ma.P_CIRCUIT)
 	return err == nil
@@ -0,0 +1,122 @@
+package identify
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/libp2p/go-libp2p/p2p/protocol/identify/pb"
+
+	"github.com/libp2p/go-msgio/pbio"
+)
+
+// supportsDelta says if the peer supports the identify delta protocol.
+func (ids *idService) supportsDelta(p peer.ID) bool {
+	sup, err := ids.Host.Peerstore().SupportsProtocols(p, IDDelta)
+	return err == nil && len(sup) > 0
+}
+
+// sendDelta sends the protocols that changed between the last snapshot sent on c,
+// which contained the protocols sent, and snapshot. The state of the connection
+// is only updated once the delta was sent successfully.
+func (ids *idService) sendDelta(ctx context.Context, c network.Conn, sent []protocol.ID, snapshot *identifySnapshot) error {
+	added, removed := diff(sent, snapshot.protocols)
+	s, err := newStreamAndNegotiate(ctx, c, IDDelta, ids.timeout)
+	if err != nil {
+		return err
+	}
+	if err := s.Scope().SetService(ServiceName); err != nil {
+		s.Reset()
+		return err
+	}
+
+	mes := &pb.Delta{
+		AddedProtocols:   protocol.ConvertToStrings(added),
+		RemovedProtocols: protocol.ConvertToStrings(removed),
+	}
+	if err := pbio.NewDelimitedWriter(s).WriteMsg(mes); err != nil {
+		s.Reset()
+		return err
+	}
+	if err := s.Close(); err != nil {
+		return err
+	}
+
+	ids.connsMu.Lock()
+	defer ids.connsMu.Unlock()
+	if e, ok := ids.conns[c]; ok {
+		e.Sequence = snapshot.seq
+		e.Protocols = snapshot.protocols
+		e.LastPush = time.Now()
+		ids.conns[c] = e
+	}
+	return nil
+}
+
+// handleDelta handles incoming identify delta streams.
+func (ids *idService) handleDelta(s network.Stream) {
+	_ = s.SetDeadline(time.Now().Add(ids.timeout))
+	if err := s.Scope().SetService(ServiceName); err != nil {
+		log.Warnf("error attaching stream to identify service: %s", err)
+		s.Reset()
+		return
+	}
+	if err := s.Scope().ReserveMemory(ids.maxMessageSize, network.ReservationPriorityAlways); err != nil {
+		log.Warnf("error reserving memory for identify stream: %s", err)
+		s.Reset()
+		return
+	}
+	defer s.Scope().ReleaseMemory(ids.maxMessageSize)
+
+	mes := &pb.Delta{}
+	if err := pbio.NewDelimitedReader(s, ids.maxMessageSize).ReadMsg(mes); err != nil {
+		log.Debugw("error reading identify delta", "peer", s.Conn().RemotePeer(), "error", err)
+		s.Reset()
+		return
+	}
+	defer s.Close()
+
+	ids.consumeDelta(s.Conn(), mes)
+}
+
+func (ids *idService) consumeDelta(c network.Conn, mes *pb.Delta) {
+	p := c.RemotePeer()
+	supported, _ := ids.Host.Peerstore().GetProtocols(p)
+
+	rm := protocol.ConvertFromStrings(mes.RemovedProtocols)
+	protos := slices.DeleteFunc(slices.Clone(supported), func(proto protocol.ID) bool { return slices.Contains(rm, proto) })
+	for _, proto := range protocol.ConvertFromStrings(mes.AddedProtocols) {
+		if !slices.Contains(protos, proto) {
+			protos = append(protos, proto)
+		}
+	}
+	if ids.maxRemoteProtocols > 0 && len(protos) > ids.maxRemoteProtocols {
+		if mt, ok := ids.metricsTracer.(TruncationMetricsTracer); ok {
+			mt.IdentifyTruncated(network.DirInbound, 0, len(protos)-ids.maxRemoteProtocols)
+		}
+		protos = protos[:ids.maxRemoteProtocols]
+	}
+	ids.Host.Peerstore().SetProtocols(p, protos...)
+
+	if ids.cancelProtoNotify == nil {
+		added, removed := diff(supported, protos)
+		ids.emitters.evtPeerProtocolsUpdated.Emit(event.EvtPeerProtocolsUpdated{
+			Peer:    p,
+			Added:   added,
+			Removed: removed,
+		})
+	}
+
+	ids.connsMu.Lock()
+	defer ids.connsMu.Unlock()
+	if e, ok := ids.conns[c]; ok && e.Info != nil {
+		info := *e.Info
+		info.Protocols = protos
+		e.Info = &info
+		ids.conns[c] = e
+	}
+}
@@ -40,6 +40,12 @@ const (
 	// IDPush is the protocol.ID of the Identify push protocol.
 	// It sends full identify messages containing the current state of the peer.
 	IDPush = "/ipfs/id/push/1.0.0"
+	// IDDelta is the protocol.ID of the Identify delta protocol.
+	// It only sends the protocols that were added or removed since the last
+	// identify message, and is used instead of a push if our addresses didn't
+	// change. It doesn't reuse the ID of the retired /p2p/id/delta/1.0.0
+	// protocol, which used a different message format.
+	IDDelta = "/libp2p/id/delta/1.0.0"
 	// DefaultTimeout for all id interactions, incoming / outgoing, id / id-push.
 	DefaultTimeout = 5 * time.Second
 	// ServiceName is the default identify service name
@@ -77,11 +83,19 @@ type identifySnapshot struct {
 	protocols []protocol.ID
 	addrs     []ma.Multiaddr
 	record    *record.Envelope
+	// addrsSeq is the sequence number of the snapshot in which the addresses
+	// or the signed record last changed.
+	addrsSeq uint64
 }
 
 // Equal says if two snapshots are identical.
 // It does NOT compare the sequence number.
 func (s identifySnapshot) Equal(other *identifySnapshot) bool {
+	return slices.Equal(s.protocols, other.protocols) && s.equalAddrs(other)
+}
+
+// equalAddrs says if two snapshots contain the same addresses and signed record.
+func (s identifySnapshot) equalAddrs(other *identifySnapshot) bool {
 	hasRecord := s.record != nil
 	otherHasRecord := other.record != nil
 	if hasRecord != otherHasRecord {
@@ -90,9 +104,6 @@ func (s identifySnapshot) Equal(other *identifySnapshot) bool {
 	if hasRecord && !s.record.Equal(other.record) {
 		return false
 	}
-	if !slices.Equal(s.protocols, other.protocols) {
-		return false
-	}
 	if len(s.addrs) != len(other.addrs) {
 		return false
 	}
@@ -123,6 +134,46 @@ type IDService interface {
 	io.Closer
 }
 
+// ConnInfoProvider is implemented by IDServices that keep the information
+// peers sent on each connection.
+type ConnInfoProvider interface {
+	// Info returns the information the peer sent us on the connection, as of
+	// the last identify response or push. It returns false if the connection
+	// wasn't identified yet.
+	Info(network.Conn) (Info, bool)
+}
+
+// ObservedAddrConfidenceProvider is implemented by IDServices that track how
+// many peers reported each of our observed addresses.
+type ObservedAddrConfidenceProvider interface {
+	// OwnObservedAddrsWithConfidence returns all addresses peers have reported
+	// we've dialed from, along with the number of peers that reported them.
+	// Changes are announced using the EvtObservedAddrsUpdated event.
+	OwnObservedAddrsWithConfidence() []event.ObservedAddr
+}
+
+var (
+	_ ConnInfoProvider               = &idService{}
+	_ ObservedAddrConfidenceProvider = &idService{}
+)
+
+// Info is the information a peer sent us using identify.
+type Info struct {
+	// ProtocolVersion is the protocol version the peer advertised.
+	ProtocolVersion string
+	// AgentVersion is the peer's user agent.
+	AgentVersion string
+	// Protocols is the list of protocols the peer advertised on the connection.
+	Protocols []protocol.ID
+	// ListenAddrs is the list of addresses the peer is listening on.
+	ListenAddrs []ma.Multiaddr
+	// ObservedAddr is our side's address of the connection, as observed by the
+	// peer. It is not verified. May be nil.
+	ObservedAddr ma.Multiaddr
+	// SignedPeerRecord is the signed peer record the peer sent. May be nil.
+	SignedPeerRecord *record.Envelope
+}
+
 type identifyPushSupport uint8
 
 const (
@@ -141,6 +192,15 @@ type entry struct {
 	PushSupport identifyPushSupport
 	// Sequence is the sequence number of the last snapshot we sent to this peer.
 	Sequence uint64
+	// LastPush is the time we last pushed a snapshot to this peer.
+	LastPush time.Time
+	// Protocols are the protocols of the last snapshot we sent to this peer.
+	Protocols []protocol.ID
+	// AgentVersion is the agent version we last sent to this peer.
+	AgentVersion string
+	// Info is the information the peer sent us. It is nil until we receive
+	// the peer's first identify message.
+	Info *Info
 }
 
 // idService is a structure that implements ProtocolIdentify.
@@ -165,7 +225,19 @@ type idService struct {
 	refCount sync.WaitGroup
 
 	disableSignedPeerRecord bool
+	disablePush             bool
 	timeout                 time.Duration
+	addrFilter              AddrFilter
+	pushCoalesceWindow      time.Duration
+	pushMinInterval         time.Duration
+	maxMessageSize          int
+	maxOwnMessageSize       int
+	maxRemoteAddrs          int
+	maxRemoteProtocols      int
+	requireSignedPeerRecord bool
+	allowUnsignedAddrs      func(p peer.ID, agentVersion string) bool
+	userAgentFunc           func() string
+	omitUserAgent           bool
 
 	connsMu sync.RWMutex
 	// The conns map contains all connections we're currently handling.
@@ -185,11 +257,24 @@ type idService struct {
 		evtPeerIdentificationFailed    event.Emitter
 	}
 
+	// cancelProtoNotify unregisters from the peerstore's protocol change
+	// notifications. If it is set, EvtPeerProtocolsUpdated is emitted for
+	// every notification instead of after consuming identify messages.
+	cancelProtoNotify func()
+
 	currentSnapshot struct {
 		sync.Mutex
 		snapshot identifySnapshot
 	}
 
+	// filteredRecords caches the signed peer records of filtered address sets,
+	// so that we don't sign a new record on every identify response.
+	filteredRecords struct {
+		sync.Mutex
+		addrsSeq uint64
+		records  map[string]*record.Envelope
+	}
+
 	natEmitter *natEmitter
 
 	rateLimiter *rate.Limiter
@@ -203,12 +288,31 @@ type normalizer interface {
 // attaching its stream handler to the given host.Host.
 func NewIDService(h host.Host, opts ...Option) (*idService, error) {
 	cfg := config{
-		timeout: DefaultTimeout,
+		timeout:           DefaultTimeout,
+		maxMessageSize:    signedIDSize,
+		maxOwnMessageSize: maxOwnIdentifyMsgSize,
+		maxRemoteAddrs:    connectedPeerMaxAddrs,
 	}
 	for _, opt := range opts {
 		opt(&cfg)
 	}
 
+	if cfg.maxMessageSize <= 0 {
+		return nil, errors.New("identify: the maximum message size must be positive")
+	}
+	if cfg.maxOwnMessageSize <= 0 {
+		return nil, errors.New("identify: the maximum size of our own messages must be positive")
+	}
+	if cfg.maxRemoteAddrs <= 0 {
+		return nil, errors.New("identify: the maximum number of remote addresses must be positive")
+	}
+	if cfg.maxRemoteProtocols < 0 {
+		return nil, errors.New("identify: the maximum number of remote protocols must not be negative")
+	}
+	if cfg.requireSignedPeerRecord && cfg.disableSignedPeerRecord {
+		return nil, errors.New("identify: can't require signed peer records when signed peer records are disabled")
+	}
+
 	userAgent := useragent.DefaultUserAgent()
 	if cfg.userAgent != "" {
 		userAgent = cfg.userAgent
@@ -223,9 +327,21 @@ func NewIDService(h host.Host, opts ...Option) (*idService, error) {
 		ctxCancel:               cancel,
 		conns:                   make(map[network.Conn]entry),
 		disableSignedPeerRecord: cfg.disableSignedPeerRecord,
+		disablePush:             cfg.disablePush,
 		setupCompleted:          make(chan struct{}),
 		metricsTracer:           cfg.metricsTracer,
 		timeout:                 cfg.timeout,
+		addrFilter:              cfg.addrFilter,
+		pushCoalesceWindow:      cfg.pushCoalesceWindow,
+		pushMinInterval:         cfg.pushMinInterval,
+		maxMessageSize:          cfg.maxMessageSize,
+		maxOwnMessageSize:       cfg.maxOwnMessageSize,
+		maxRemoteAddrs:          cfg.maxRemoteAddrs,
+		maxRemoteProtocols:      cfg.maxRemoteProtocols,
+		requireSignedPeerRecord: cfg.requireSignedPeerRecord,
+		allowUnsignedAddrs:      cfg.allowUnsignedAddrs,
+		userAgentFunc:           cfg.userAgentFunc,
+		omitUserAgent:           cfg.omitUserAgent,
 		rateLimiter: &rate.Limiter{
 			GlobalLimit:         defaultGlobalRateLimit,
 			NetworkPrefixLimits: defaultNetworkPrefixRateLimits,
@@ -262,6 +378,16 @@ func NewIDService(h host.Host, opts ...Option) (*idService, error) {
 	s.emitters.evtPeerProtocolsUpdated, err = h.EventBus().Emitter(&event.EvtPeerProtocolsUpdated{})
 	if err != nil {
 		log.Warnf("identify service not emitting peer protocol updates; err: %s", err)
+	} else if n, ok := h.Peerstore().(peerstore.ProtoBookNotifier); ok {
+		// The peerstore tells us about all changes, in order, no matter who
+		// made them.
+		s.cancelProtoNotify = n.NotifyProtocolsChanged(func(p peer.ID, added, removed []protocol.ID) {
+			s.emitters.evtPeerProtocolsUpdated.Emit(event.EvtPeerProtocolsUpdated{
+				Peer:    p,
+				Added:   added,
+				Removed: removed,
+			})
+		})
 	}
 	s.emitters.evtPeerIdentificationCompleted, err = h.EventBus().Emitter(&event.EvtPeerIdentificationCompleted{})
 	if err != nil {
@@ -277,7 +403,10 @@ func NewIDService(h host.Host, opts ...Option) (*idService, error) {
 func (ids *idService) Start() {
 	ids.Host.Network().Notify((*netNotifiee)(ids))
 	ids.Host.SetStreamHandler(ID, ids.handleIdentifyRequest)
-	ids.Host.SetStreamHandler(IDPush, ids.rateLimiter.Limit(ids.handlePush))
+	if !ids.disablePush {
+		ids.Host.SetStreamHandler(IDPush, ids.rateLimit(ids.handlePush))
+		ids.Host.SetStreamHandler(IDDelta, ids.rateLimit(ids.handleDelta))
+	}
 	ids.updateSnapshot()
 	close(ids.setupCompleted)
 
@@ -308,12 +437,37 @@ func (ids *idService) loop(ctx context.Context) {
 	go func() {
 		defer ids.refCount.Done()
 
+		// retry fires when pushes that were held back by the rate limit are due.
+		var retry *time.Timer
+		var retryC <-chan time.Time
 		for {
 			select {
 			case <-ctx.Done():
 				return
 			case <-triggerPush:
-				ids.sendPushes(ctx)
+				if ids.pushCoalesceWindow > 0 {
+					t := time.NewTimer(ids.pushCoalesceWindow)
+					select {
+					case <-ctx.Done():
+						t.Stop()
+						return
+					case <-t.C:
+					}
+					// changes that happened during the window are part of this push
+					select {
+					case <-triggerPush:
+					default:
+					}
+				}
+			case <-retryC:
+			}
+			if retry != nil {
+				retry.Stop()
+				retry, retryC = nil, nil
+			}
+			if next := ids.sendPushes(ctx); next > 0 {
+				retry = time.NewTimer(next)
+				retryC = retry.C
 			}
 		}
 	}()
@@ -324,7 +478,7 @@ func (ids *idService) loop(ctx context.Context) {
 			if !ok {
 				return
 			}
-			if updated := ids.updateSnapshot(); !updated {
+			if updated := ids.updateSnapshot(); !updated || ids.disablePush {
 				continue
 			}
 			if ids.metricsTracer != nil {
@@ -340,7 +494,10 @@ func (ids *idService) loop(ctx context.Context) {
 	}
 }
 
-func (ids *idService) sendPushes(ctx context.Context) {
+// sendPushes pushes the current snapshot to all peers that haven't received it
+// yet. If the push to some peers was held back by the rate limit, it returns the
+// time until these pushes are due.
+func (ids *idService) sendPushes(ctx context.Context) (next time.Duration) {
 	ids.connsMu.RLock()
 	conns := make([]network.Conn, 0, len(ids.conns))
 	for c, e := range ids.conns {
@@ -370,6 +527,18 @@ func (ids *idService) sendPushes(ctx context.Context) {
 			log.Debugw("already sent this snapshot to peer", "peer", c.RemotePeer(), "seq", snapshot.seq)
 			continue
 		}
+		if ids.pushMinInterval > 0 {
+			if wait := ids.pushMinInterval - time.Since(e.LastPush); wait > 0 {
+				if next == 0 || wait < next {
+					next = wait
+				}
+				continue
+			}
+		}
+		// If the peer already knows our current addresses and agent version,
+		// it's sufficient to send the protocols that changed.
+		av, _ := ids.agentVersion()
+		sendDelta := e.Sequence > 0 && e.Sequence >= snapshot.addrsSeq && e.AgentVersion == av && ids.supportsDelta(c.RemotePeer())
 		// we haven't, send it now
 		sem <- struct{}{}
 		wg.Add(1)
@@ -379,6 +548,16 @@ func (ids *idService) sendPushes(ctx context.Context) {
 			ctx, cancel := context.WithTimeout(ctx, ids.timeout)
 			defer cancel()
 
+			if sendDelta {
+				err := ids.sendDelta(ctx, c, e.Protocols, &snapshot)
+				if err == nil {
+					return
+				}
+				// The peer might not support the delta protocol after all.
+				// Fall back to a full push.
+				log.Debugw("failed to send identify delta, falling back to push", "peer", c.RemotePeer(), "error", err)
+			}
+
 			str, err := newStreamAndNegotiate(ctx, c, IDPush, ids.timeout)
 			if err != nil { // connection might have been closed recently
 				return
@@ -391,11 +570,15 @@ func (ids *idService) sendPushes(ctx context.Context) {
 		}(c)
 	}
 	wg.Wait()
+	return next
 }
 
 // Close shuts down the idService
 func (ids *idService) Close() error {
 	ids.ctxCancel()
+	if ids.cancelProtoNotify != nil {
+		ids.cancelProtoNotify()
+	}
 	if !ids.disableObservedAddrManager {
 		ids.observedAddrMgr.Close()
 		ids.natEmitter.Close()
@@ -411,6 +594,13 @@ func (ids *idService) OwnObservedAddrs() []ma.Multiaddr {
 	return ids.observedAddrMgr.Addrs()
 }
 
+func (ids *idService) OwnObservedAddrsWithConfidence() []event.ObservedAddr {
+	if ids.disableObservedAddrManager {
+		return nil
+	}
+	return ids.observedAddrMgr.AddrsWithConfidence()
+}
+
 func (ids *idService) ObservedAddrsFor(local ma.Multiaddr) []ma.Multiaddr {
 	if ids.disableObservedAddrManager {
 		return nil
@@ -418,6 +608,16 @@ func (ids *idService) ObservedAddrsFor(local ma.Multiaddr) []ma.Multiaddr {
 	return ids.observedAddrMgr.AddrsFor(local)
 }
 
+func (ids *idService) Info(c network.Conn) (Info, bool) {
+	ids.connsMu.RLock()
+	defer ids.connsMu.RUnlock()
+	e, ok := ids.conns[c]
+	if !ok || e.Info == nil {
+		return Info{}, false
+	}
+	return *e.Info, true
+}
+
 // IdentifyConn runs the Identify protocol on a connection.
 // It returns when we've received the peer's Identify message (or the request fails).
 // If successful, the peer store will contain the peer's addresses and supported protocols.
@@ -464,14 +664,25 @@ func (ids *idService) IdentifyWait(c network.Conn) <-chan struct{} {
 			ids.emitters.evtPeerIdentificationFailed.Emit(event.EvtPeerIdentificationFailed{Peer: c.RemotePeer(), Reason: err})
 			return
 		}
+		ids.recordRTT(c)
 	}()
 
 	return e.IdentifyWaitChan
 }
 
+// recordRTT records the RTT measured by the transport of c, e.g. by QUIC's
+// loss recovery, as a latency sample of the peer. The identify exchange takes
+// at least one round trip, so the RTT is known by the time it completes.
+func (ids *idService) recordRTT(c network.Conn) {
+	if rtt := c.Stat().SmoothedRTT; rtt > 0 {
+		peerstore.RecordLatencySample(ids.Host.Peerstore(), c.RemotePeer(), c.ConnState().Transport, rtt)
+	}
+}
+
 // newStreamAndNegotiate opens a new stream on the given connection and negotiates the given protocol.
 func newStreamAndNegotiate(ctx context.Context, c network.Conn, proto protocol.ID, timeout time.Duration) (network.Stream, error) {
-	s, err := c.NewStream(network.WithAllowLimitedConn(ctx, "identify"))
+	ctx = network.WithStreamPriority(network.WithAllowLimitedConn(ctx, "identify"), network.StreamPriorityHigh)
+	s, err := c.NewStream(ctx)
 	if err != nil {
 		log.Debugw("error opening identify stream", "peer", c.RemotePeer(), "error", err)
 		return nil, fmt.Errorf("failed to open new stream: %w", err)
@@ -501,12 +712,26 @@ func (ids *idService) identifyConn(c network.Conn) error {
 	s, err := newStreamAndNegotiate(network.WithAllowLimitedConn(ctx, "identify"), c, ID, ids.timeout)
 	if err != nil {
 		log.Debugw("error opening identify stream", "peer", c.RemotePeer(), "error", err)
+		if mt, ok := ids.metricsTracer.(ExchangeMetricsTracer); ok {
+			mt.IdentifyFailed(false, network.DirOutbound, false)
+		}
 		return err
 	}
 
 	return ids.handleIdentifyResponse(s, false)
 }
 
+// rateLimit applies the rate limit to incoming identify push and delta streams.
+func (ids *idService) rateLimit(h network.StreamHandler) network.StreamHandler {
+	mt, ok := ids.metricsTracer.(ExchangeMetricsTracer)
+	if !ok {
+		return ids.rateLimiter.Limit(h)
+	}
+	return ids.rateLimiter.LimitWithCallback(h, func(network.Stream) {
+		mt.IdentifyFailed(true, network.DirInbound, true)
+	})
+}
+
 // handlePush handles incoming identify push streams
 func (ids *idService) handlePush(s network.Stream) {
 	s.SetDeadline(time.Now().Add(ids.timeout))
@@ -519,7 +744,13 @@ func (ids *idService) handleIdentifyRequest(s network.Stream) {
 	_ = ids.sendIdentifyResp(s, false)
 }
 
-func (ids *idService) sendIdentifyResp(s network.Stream, isPush bool) error {
+func (ids *idService) sendIdentifyResp(s network.Stream, isPush bool) (err error) {
+	start := time.Now()
+	var size int
+	if mt, ok := ids.metricsTracer.(ExchangeMetricsTracer); ok {
+		defer func() { trackIdentify(mt, s, isPush, start, size, err) }()
+	}
+
 	if err := s.Scope().SetService(ServiceName); err != nil {
 		s.Reset()
 		return fmt.Errorf("failed to attaching stream to identify service: %w", err)
@@ -530,12 +761,17 @@ func (ids *idService) sendIdentifyResp(s network.Stream, isPush bool) error {
 	snapshot := ids.currentSnapshot.snapshot
 	ids.currentSnapshot.Unlock()
 
+	if ids.addrFilter != nil {
+		snapshot = ids.filterSnapshot(s.Conn(), snapshot)
+	}
+
 	log.Debugw("sending snapshot", "seq", snapshot.seq, "protocols", snapshot.protocols, "addrs", snapshot.addrs)
 
 	mes := ids.createBaseIdentifyResponse(s.Conn(), &snapshot)
 	mes.SignedPeerRecord = ids.getSignedRecord(&snapshot)
 
 	log.Debugf("%s sending message to %s %s", ID, s.Conn().RemotePeer(), s.Conn().RemoteMultiaddr())
+	size = proto.Size(mes)
 	if err := ids.writeChunkedIdentifyMsg(s, mes); err != nil {
 		return err
 	}
@@ -555,27 +791,38 @@ func (ids *idService) sendIdentifyResp(s network.Stream, isPush bool) error {
 		return nil
 	}
 	e.Sequence = snapshot.seq
+	e.Protocols = snapshot.protocols
+	e.AgentVersion = mes.GetAgentVersion()
+	if isPush {
+		e.LastPush = time.Now()
+	}
 	ids.conns[s.Conn()] = e
 	return nil
 }
 
-func (ids *idService) handleIdentifyResponse(s network.Stream, isPush bool) error {
+func (ids *idService) handleIdentifyResponse(s network.Stream, isPush bool) (err error) {
+	start := time.Now()
+	var size int
+	if mt, ok := ids.metricsTracer.(ExchangeMetricsTracer); ok {
+		defer func() { trackIdentify(mt, s, isPush, start, size, err) }()
+	}
+
 	if err := s.Scope().SetService(ServiceName); err != nil {
 		log.Warnf("error attaching stream to identify service: %s", err)
 		s.Reset()
 		return err
 	}
 
-	if err := s.Scope().ReserveMemory(signedIDSize, network.ReservationPriorityAlways); err != nil {
+	if err := s.Scope().ReserveMemory(ids.maxMessageSize, network.ReservationPriorityAlways); err != nil {
 		log.Warnf("error reserving memory for identify stream: %s", err)
 		s.Reset()
 		return err
 	}
-	defer s.Scope().ReleaseMemory(signedIDSize)
+	defer s.Scope().ReleaseMemory(ids.maxMessageSize)
 
 	c := s.Conn()
 
-	r := pbio.NewDelimitedReader(s, signedIDSize)
+	r := pbio.NewDelimitedReader(s, ids.maxMessageSize)
 	mes := &pb.Identify{}
 
 	if err := readAllIDMessages(r, mes); err != nil {
@@ -587,6 +834,7 @@ func (ids *idService) handleIdentifyResponse(s network.Stream, isPush bool) erro
 	defer s.Close()
 
 	log.Debugf("%s received message from %s %s", s.Protocol(), c.RemotePeer(), c.RemoteMultiaddr())
+	size = proto.Size(mes)
 
 	ids.consumeMessage(mes, c, isPush)
 
@@ -615,6 +863,16 @@ func (ids *idService) handleIdentifyResponse(s network.Stream, isPush bool) erro
 	return nil
 }
 
+// trackIdentify records the metrics of an identify message sent or received on s.
+func trackIdentify(mt ExchangeMetricsTracer, s network.Stream, isPush bool, start time.Time, size int, err error) {
+	dir := s.Stat().Direction
+	if err != nil {
+		mt.IdentifyFailed(isPush, dir, false)
+		return
+	}
+	mt.IdentifyCompleted(isPush, dir, time.Since(start), size)
+}
+
 func readAllIDMessages(r pbio.Reader, finalMsg proto.Message) error {
 	mes := &pb.Identify{}
 	for i := 0; i < maxMessages; i++ {
@@ -638,11 +896,19 @@ func (ids *idService) updateSnapshot() (updated bool) {
 	addrs := ids.Host.Addrs()
 	slices.SortFunc(addrs, func(a, b ma.Multiaddr) int { return bytes.Compare(a.Bytes(), b.Bytes()) })
 
-	usedSpace := len(ids.ProtocolVersion) + len(ids.UserAgent)
+	agentVersion, _ := ids.agentVersion()
+	usedSpace := len(ids.ProtocolVersion) + len(agentVersion)
 	for i := 0; i < len(protos); i++ {
 		usedSpace += len(protos[i])
 	}
-	addrs = trimHostAddrList(addrs, maxOwnIdentifyMsgSize-usedSpace-256) // 256 bytes of buffer
+	numAddrs := len(addrs)
+	addrs = trimHostAddrList(addrs, ids.maxOwnMessageSize-usedSpace-256) // 256 bytes of buffer
+	if dropped := numAddrs - len(addrs); dropped > 0 {
+		log.Warnw("identify message too large, not announcing all addresses", "dropped", dropped, "announced", len(addrs))
+		if mt, ok := ids.metricsTracer.(TruncationMetricsTracer); ok {
+			mt.IdentifyTruncated(network.DirOutbound, dropped, 0)
+		}
+	}
 
 	snapshot := identifySnapshot{
 		addrs:     addrs,
@@ -663,6 +929,10 @@ func (ids *idService) updateSnapshot() (updated bool) {
 	}
 
 	snapshot.seq = ids.currentSnapshot.snapshot.seq + 1
+	snapshot.addrsSeq = snapshot.seq
+	if snapshot.equalAddrs(&ids.currentSnapshot.snapshot) {
+		snapshot.addrsSeq = ids.currentSnapshot.snapshot.addrsSeq
+	}
 	ids.currentSnapshot.snapshot = snapshot
 
 	log.Debugw("updating snapshot", "seq", snapshot.seq, "addrs", snapshot.addrs)
@@ -732,11 +1002,75 @@ func (ids *idService) createBaseIdentifyResponse(conn network.Conn, snapshot *id
 
 	// set protocol versions
 	mes.ProtocolVersion = &ids.ProtocolVersion
-	mes.AgentVersion = &ids.UserAgent
+	if av, ok := ids.agentVersion(); ok {
+		mes.AgentVersion = &av
+	}
 
 	return mes
 }
 
+// filterSnapshot applies the address filter to the addresses announced on conn.
+// If the filter changed the addresses, the signed peer record would reveal the
+// filtered addresses, and is replaced with a record of the announced addresses.
+func (ids *idService) filterSnapshot(conn network.Conn, snapshot identifySnapshot) identifySnapshot {
+	addrs := ids.addrFilter(conn, slices.Clone(snapshot.addrs))
+	if snapshot.record != nil && !slices.EqualFunc(addrs, snapshot.addrs, func(a, b ma.Multiaddr) bool { return a.Equal(b) }) {
+		snapshot.record = ids.filteredRecord(snapshot.addrsSeq, addrs)
+	}
+	snapshot.addrs = addrs
+	return snapshot
+}
+
+// maxFilteredRecords is the maximum number of signed peer records of filtered
+// address sets that are cached.
+const maxFilteredRecords = 32
+
+// filteredRecord returns a signed peer record of addrs. Records are cached
+// until the addresses of the snapshot change, as signaled by addrsSeq.
+func (ids *idService) filteredRecord(addrsSeq uint64, addrs []ma.Multiaddr) *record.Envelope {
+	// The binary representation of multiaddrs is self-delimiting, so the
+	// concatenation identifies the address set.
+	var cacheKey []byte
+	for _, a := range addrs {
+		cacheKey = append(cacheKey, a.Bytes()...)
+	}
+
+	ids.filteredRecords.Lock()
+	defer ids.filteredRecords.Unlock()
+	if ids.filteredRecords.addrsSeq != addrsSeq || len(ids.filteredRecords.records) >= maxFilteredRecords {
+		ids.filteredRecords.addrsSeq = addrsSeq
+		ids.filteredRecords.records = make(map[string]*record.Envelope)
+	}
+	if rec, ok := ids.filteredRecords.records[string(cacheKey)]; ok {
+		return rec
+	}
+
+	sk := ids.Host.Peerstore().PrivKey(ids.Host.ID())
+	if sk == nil {
+		return nil
+	}
+	rec, err := record.Seal(peer.PeerRecordFromAddrInfo(peer.AddrInfo{ID: ids.Host.ID(), Addrs: addrs}), sk)
+	if err != nil {
+		log.Errorw("failed to sign peer record", "err", err)
+		return nil
+	}
+	ids.filteredRecords.records[string(cacheKey)] = rec
+	return rec
+}
+
+// agentVersion returns the agent version we send to peers, and false if it is
+// omitted.
+func (ids *idService) agentVersion() (string, bool) {
+	switch {
+	case ids.omitUserAgent:
+		return "", false
+	case ids.userAgentFunc != nil:
+		return ids.userAgentFunc(), true
+	default:
+		return ids.UserAgent, true
+	}
+}
+
 func (ids *idService) getSignedRecord(snapshot *identifySnapshot) []byte {
 	if ids.disableSignedPeerRecord || snapshot.record == nil {
 		return nil
@@ -786,9 +1120,14 @@ func (ids *idService) consumeMessage(mes *pb.Identify, c network.Conn, isPush bo
 
 	supported, _ := ids.Host.Peerstore().GetProtocols(p)
 	mesProtocols := protocol.ConvertFromStrings(mes.Protocols)
+	var droppedProtos int
+	if ids.maxRemoteProtocols > 0 && len(mesProtocols) > ids.maxRemoteProtocols {
+		droppedProtos = len(mesProtocols) - ids.maxRemoteProtocols
+		mesProtocols = mesProtocols[:ids.maxRemoteProtocols]
+	}
 	added, removed := diff(supported, mesProtocols)
 	ids.Host.Peerstore().SetProtocols(p, mesProtocols...)
-	if isPush {
+	if isPush && ids.cancelProtoNotify == nil {
 		ids.emitters.evtPeerProtocolsUpdated.Emit(event.EvtPeerProtocolsUpdated{
 			Peer:    p,
 			Added:   added,
@@ -861,15 +1200,20 @@ func (ids *idService) consumeMessage(mes *pb.Identify, c network.Conn, isPush bo
 		} else {
 			addrs = signedAddrs
 		}
-	} else {
+	} else if ids.acceptUnsignedAddrs(p, mes.GetAgentVersion()) {
 		addrs = lmaddrs
 	}
 	addrs = filterAddrs(addrs, c.RemoteMultiaddr())
-	if len(addrs) > connectedPeerMaxAddrs {
-		addrs = addrs[:connectedPeerMaxAddrs]
+	var droppedAddrs int
+	if len(addrs) > ids.maxRemoteAddrs {
+		droppedAddrs = len(addrs) - ids.maxRemoteAddrs
+		addrs = addrs[:ids.maxRemoteAddrs]
+	}
+	if mt, ok := ids.metricsTracer.(TruncationMetricsTracer); ok && (droppedAddrs > 0 || droppedProtos > 0) {
+		mt.IdentifyTruncated(network.DirInbound, droppedAddrs, droppedProtos)
 	}
 
-	ids.Host.Peerstore().AddAddrs(p, addrs, ttl)
+	peerstore.AddAddrsWithSource(ids.Host.Peerstore(), p, addrs, ttl, peerstore.AddrSourceIdentify)
 
 	// Finally, expire all temporary addrs.
 	ids.Host.Peerstore().UpdateAddrs(p, peerstore.TempAddrTTL, 0)
@@ -887,6 +1231,20 @@ func (ids *idService) consumeMessage(mes *pb.Identify, c network.Conn, isPush bo
 	// get the key from the other side. we may not have it (no-auth transport)
 	ids.consumeReceivedPubKey(c, mes.PublicKey)
 
+	ids.connsMu.Lock()
+	if e, ok := ids.conns[c]; ok {
+		e.Info = &Info{
+			ProtocolVersion:  pv,
+			AgentVersion:     av,
+			Protocols:        mesProtocols,
+			ListenAddrs:      lmaddrs,
+			ObservedAddr:     obsAddr,
+			SignedPeerRecord: signedPeerRecord,
+		}
+		ids.conns[c] = e
+	}
+	ids.connsMu.Unlock()
+
 	ids.emitters.evtPeerIdentificationCompleted.Emit(event.EvtPeerIdentificationCompleted{
 		Peer:             c.RemotePeer(),
 		Conn:             c,
@@ -896,9 +1254,23 @@ func (ids *idService) consumeMessage(mes *pb.Identify, c network.Conn, isPush bo
 		ObservedAddr:     obsAddr,
 		ProtocolVersion:  pv,
 		AgentVersion:     av,
+		IsPush:           isPush,
 	})
 }
 
+// acceptUnsignedAddrs decides whether the unsigned addresses of a peer that
+// didn't send a signed peer record are used.
+func (ids *idService) acceptUnsignedAddrs(p peer.ID, agentVersion string) bool {
+	accept := !ids.requireSignedPeerRecord || (ids.allowUnsignedAddrs != nil && ids.allowUnsignedAddrs(p, agentVersion))
+	if !accept {
+		log.Debugw("ignoring unsigned addresses", "peer", p, "agent", agentVersion)
+	}
+	if mt, ok := ids.metricsTracer.(SignedRecordMetricsTracer); ok {
+		mt.UnsignedAddrsReceived(accept)
+	}
+	return accept
+}
+
 func (ids *idService) consumeSignedPeerRecord(p peer.ID, signedPeerRecord *record.Envelope) ([]ma.Multiaddr, error) {
 	if signedPeerRecord.PublicKey == nil {
 		return nil, errors.New("missing pubkey")
@@ -206,3 +206,20 @@ func TestIncomingAddrFilter(t *testing.T) {
 		})
 	}
 }
+
+func TestFilteredRecordCache(t *testing.T) {
+	h := blhost.NewBlankHost(swarmt.GenSwarm(t, swarmt.OptDialOnly))
+	defer h.Close()
+	ids, err := NewIDService(h)
+	require.NoError(t, err)
+	defer ids.Close()
+
+	addrs := []ma.Multiaddr{ma.StringCast("/ip4/1.2.3.4/tcp/1234")}
+	rec := ids.filteredRecord(1, addrs)
+	require.NotNil(t, rec)
+	require.Same(t, rec, ids.filteredRecord(1, addrs))
+	// A different address set gets its own record.
+	require.NotSame(t, rec, ids.filteredRecord(1, []ma.Multiaddr{ma.StringCast("/ip4/1.2.3.4/udp/1234/quic-v1")}))
+	// The cache is reset when our addresses change.
+	require.NotSame(t, rec, ids.filteredRecord(2, addrs))
+}
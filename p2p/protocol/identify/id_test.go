@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"math/rand"
 	"slices"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -971,3 +973,439 @@ func waitForDisconnectNotification(swarm *swarm.Swarm) <-chan struct{} {
 
 	return done
 }
+
+func TestAddrFilter(t *testing.T) {
+	h1 := blhost.NewBlankHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC))
+	h2 := blhost.NewBlankHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC))
+	defer h1.Close()
+	defer h2.Close()
+
+	require.Len(t, h1.Addrs(), 1)
+	announced := h1.Addrs()[0]
+
+	var filtered atomic.Bool
+	ids1, err := identify.NewIDService(h1, identify.WithAddrFilter(func(c network.Conn, addrs []ma.Multiaddr) []ma.Multiaddr {
+		if c.RemotePeer() == h2.ID() {
+			filtered.Store(true)
+		}
+		return slices.DeleteFunc(addrs, func(a ma.Multiaddr) bool { return !a.Equal(announced) })
+	}))
+	require.NoError(t, err)
+	defer ids1.Close()
+	ids1.Start()
+
+	ids2, err := identify.NewIDService(h2)
+	require.NoError(t, err)
+	defer ids2.Close()
+	ids2.Start()
+
+	require.NoError(t, h2.Connect(context.Background(), peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}))
+	ids2.IdentifyConn(h2.Network().ConnsToPeer(h1.ID())[0])
+	require.True(t, filtered.Load())
+	testKnowsAddrs(t, h2, h1.ID(), []ma.Multiaddr{announced})
+	// The signed peer record only contains the announced addresses.
+	info, ok := ids2.Info(h2.Network().ConnsToPeer(h1.ID())[0])
+	require.True(t, ok)
+	require.NotNil(t, info.SignedPeerRecord)
+
+	// The filter is also applied to pushes.
+	require.NoError(t, h1.Network().Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0")))
+	require.Len(t, h1.Addrs(), 2)
+	h1.SetStreamHandler("rand", func(network.Stream) {})
+	require.Eventually(t, func() bool {
+		sup, err := h2.Peerstore().SupportsProtocols(h1.ID(), "rand")
+		return err == nil && len(sup) == 1
+	}, time.Second, 10*time.Millisecond)
+	testKnowsAddrs(t, h2, h1.ID(), []ma.Multiaddr{announced})
+}
+
+// newPushCountingHosts connects two hosts running identify, and counts the
+// pushes and deltas the second host receives from the first one.
+func newPushCountingHosts(t *testing.T, opts ...identify.Option) (host.Host, *atomic.Int32) {
+	t.Helper()
+	h1 := blhost.NewBlankHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC))
+	h2 := blhost.NewBlankHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC))
+	t.Cleanup(func() { h1.Close(); h2.Close() })
+
+	ids1, err := identify.NewIDService(h1, opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { ids1.Close() })
+	ids1.Start()
+	ids2, err := identify.NewIDService(h2)
+	require.NoError(t, err)
+	t.Cleanup(func() { ids2.Close() })
+	ids2.Start()
+
+	var pushes atomic.Int32
+	count := func(s network.Stream) {
+		defer s.Close()
+		io.ReadAll(s)
+		pushes.Add(1)
+	}
+	h2.SetStreamHandler(identify.IDPush, count)
+	h2.SetStreamHandler(identify.IDDelta, count)
+
+	require.NoError(t, h1.Connect(context.Background(), peer.AddrInfo{ID: h2.ID(), Addrs: h2.Addrs()}))
+	<-ids1.IdentifyWait(h1.Network().ConnsToPeer(h2.ID())[0])
+	<-ids2.IdentifyWait(h2.Network().ConnsToPeer(h1.ID())[0])
+	return h1, &pushes
+}
+
+func TestPushCoalescing(t *testing.T) {
+	h1, pushes := newPushCountingHosts(t, identify.WithPushCoalescing(200*time.Millisecond))
+
+	for i := range 5 {
+		h1.SetStreamHandler(protocol.ID(fmt.Sprintf("proto%d", i)), func(network.Stream) {})
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.Eventually(t, func() bool { return pushes.Load() == 1 }, 2*time.Second, 10*time.Millisecond)
+	time.Sleep(300 * time.Millisecond)
+	require.Equal(t, int32(1), pushes.Load())
+}
+
+func TestPushRateLimit(t *testing.T) {
+	h1, pushes := newPushCountingHosts(t, identify.WithPushRateLimit(500*time.Millisecond))
+
+	h1.SetStreamHandler("proto1", func(network.Stream) {})
+	require.Eventually(t, func() bool { return pushes.Load() == 1 }, time.Second, 10*time.Millisecond)
+
+	// The next push is held back until the interval has passed.
+	start := time.Now()
+	h1.SetStreamHandler("proto2", func(network.Stream) {})
+	h1.SetStreamHandler("proto3", func(network.Stream) {})
+	require.Eventually(t, func() bool { return pushes.Load() == 2 }, 2*time.Second, 10*time.Millisecond)
+	require.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+	time.Sleep(200 * time.Millisecond)
+	require.Equal(t, int32(2), pushes.Load())
+}
+
+func TestIdentifyInfo(t *testing.T) {
+	h1 := blhost.NewBlankHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC))
+	h2 := blhost.NewBlankHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC))
+	defer h1.Close()
+	defer h2.Close()
+
+	ids1, err := identify.NewIDService(h1, identify.UserAgent("foo"))
+	require.NoError(t, err)
+	defer ids1.Close()
+	ids1.Start()
+	ids2, err := identify.NewIDService(h2)
+	require.NoError(t, err)
+	defer ids2.Close()
+	ids2.Start()
+
+	sub, err := h2.EventBus().Subscribe(new(event.EvtPeerIdentificationCompleted))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	require.NoError(t, h2.Connect(context.Background(), peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}))
+	c := h2.Network().ConnsToPeer(h1.ID())[0]
+	ids2.IdentifyConn(c)
+
+	info, ok := ids2.Info(c)
+	require.True(t, ok)
+	require.Equal(t, "foo", info.AgentVersion)
+	require.Contains(t, info.Protocols, protocol.ID(identify.ID))
+	require.True(t, matest.AssertMultiaddrsMatch(t, h1.Addrs(), info.ListenAddrs))
+	require.True(t, info.ObservedAddr.Equal(c.LocalMultiaddr()))
+	require.NotNil(t, info.SignedPeerRecord)
+
+	select {
+	case e := <-sub.Out():
+		require.False(t, e.(event.EvtPeerIdentificationCompleted).IsPush)
+	case <-time.After(time.Second):
+		t.Fatal("expected an identification completed event")
+	}
+
+	// The information is updated by pushes.
+	require.NoError(t, h1.Network().Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0")))
+	h1.SetStreamHandler("rand", func(network.Stream) {})
+	select {
+	case e := <-sub.Out():
+		evt := e.(event.EvtPeerIdentificationCompleted)
+		require.True(t, evt.IsPush)
+		require.Contains(t, evt.Protocols, protocol.ID("rand"))
+	case <-time.After(time.Second):
+		t.Fatal("expected an identification completed event")
+	}
+	info, ok = ids2.Info(c)
+	require.True(t, ok)
+	require.Contains(t, info.Protocols, protocol.ID("rand"))
+
+	// And by deltas.
+	h1.RemoveStreamHandler("rand")
+	require.Eventually(t, func() bool {
+		info, ok := ids2.Info(c)
+		return ok && !slices.Contains(info.Protocols, "rand")
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestIdentifyLimits(t *testing.T) {
+	h1 := blhost.NewBlankHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC))
+	h2 := blhost.NewBlankHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC))
+	defer h1.Close()
+	defer h2.Close()
+	for range 2 {
+		require.NoError(t, h1.Network().Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0")))
+	}
+	require.Len(t, h1.Addrs(), 3)
+	for i := range 5 {
+		h1.SetStreamHandler(protocol.ID(fmt.Sprintf("proto%d", i)), func(network.Stream) {})
+	}
+
+	ids1, err := identify.NewIDService(h1, identify.DisableSignedPeerRecord())
+	require.NoError(t, err)
+	defer ids1.Close()
+	ids1.Start()
+	ids2, err := identify.NewIDService(h2, identify.WithMaxRemoteAddrs(2), identify.WithMaxRemoteProtocols(3))
+	require.NoError(t, err)
+	defer ids2.Close()
+	ids2.Start()
+
+	require.NoError(t, h2.Connect(context.Background(), peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}))
+	c := h2.Network().ConnsToPeer(h1.ID())[0]
+	ids2.IdentifyConn(c)
+
+	info, ok := ids2.Info(c)
+	require.True(t, ok)
+	require.Len(t, info.Protocols, 3)
+	protos, err := h2.Peerstore().GetProtocols(h1.ID())
+	require.NoError(t, err)
+	require.Len(t, protos, 3)
+	require.Len(t, h2.Peerstore().Addrs(h1.ID()), 2)
+}
+
+func TestIdentifyInvalidLimits(t *testing.T) {
+	h := blhost.NewBlankHost(swarmt.GenSwarm(t, swarmt.OptDialOnly))
+	defer h.Close()
+
+	for _, opt := range []identify.Option{
+		identify.WithMaxMessageSize(0),
+		identify.WithMaxOwnMessageSize(-1),
+		identify.WithMaxRemoteAddrs(0),
+		identify.WithMaxRemoteProtocols(-1),
+	} {
+		_, err := identify.NewIDService(h, opt)
+		require.Error(t, err)
+	}
+}
+
+func TestMaxOwnMessageSize(t *testing.T) {
+	h1 := blhost.NewBlankHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC))
+	h2 := blhost.NewBlankHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC))
+	defer h1.Close()
+	defer h2.Close()
+	for range 2 {
+		require.NoError(t, h1.Network().Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0")))
+	}
+	require.Len(t, h1.Addrs(), 3)
+
+	// Only leave space for a single address.
+	ids1, err := identify.NewIDService(h1, identify.UserAgent("foo"), identify.WithMaxOwnMessageSize(256+len("foo")+len(identify.ID)+len(identify.IDPush)+len(identify.IDDelta)+10))
+	require.NoError(t, err)
+	defer ids1.Close()
+	ids1.Start()
+	ids2, err := identify.NewIDService(h2)
+	require.NoError(t, err)
+	defer ids2.Close()
+	ids2.Start()
+
+	require.NoError(t, h2.Connect(context.Background(), peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}))
+	c := h2.Network().ConnsToPeer(h1.ID())[0]
+	ids2.IdentifyConn(c)
+
+	info, ok := ids2.Info(c)
+	require.True(t, ok)
+	require.Len(t, info.ListenAddrs, 1)
+}
+
+func TestRequireSignedPeerRecord(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		signed        bool
+		allowUnsigned bool
+		expectAddrs   bool
+	}{
+		{name: "signed", signed: true, expectAddrs: true},
+		{name: "unsigned", signed: false, expectAddrs: false},
+		{name: "unsigned, allowed", signed: false, allowUnsigned: true, expectAddrs: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h1 := blhost.NewBlankHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC))
+			h2 := blhost.NewBlankHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC))
+			defer h1.Close()
+			defer h2.Close()
+
+			var opts1 []identify.Option
+			if !tc.signed {
+				opts1 = append(opts1, identify.DisableSignedPeerRecord())
+			}
+			ids1, err := identify.NewIDService(h1, append(opts1, identify.UserAgent("old"))...)
+			require.NoError(t, err)
+			defer ids1.Close()
+			ids1.Start()
+
+			opts2 := []identify.Option{identify.RequireSignedPeerRecord()}
+			if tc.allowUnsigned {
+				opts2 = append(opts2, identify.AllowUnsignedAddrs(func(p peer.ID, agentVersion string) bool {
+					return p == h1.ID() && agentVersion == "old"
+				}))
+			}
+			ids2, err := identify.NewIDService(h2, opts2...)
+			require.NoError(t, err)
+			defer ids2.Close()
+			ids2.Start()
+
+			require.NoError(t, h2.Connect(context.Background(), peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}))
+			ids2.IdentifyConn(h2.Network().ConnsToPeer(h1.ID())[0])
+			if tc.expectAddrs {
+				testKnowsAddrs(t, h2, h1.ID(), h1.Addrs())
+			} else {
+				require.Empty(t, h2.Peerstore().Addrs(h1.ID()))
+			}
+		})
+	}
+
+	_, err := identify.NewIDService(blhost.NewBlankHost(swarmt.GenSwarm(t)), identify.RequireSignedPeerRecord(), identify.DisableSignedPeerRecord())
+	require.Error(t, err)
+}
+
+func TestUserAgentFunc(t *testing.T) {
+	h1 := blhost.NewBlankHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC))
+	h2 := blhost.NewBlankHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC))
+	defer h1.Close()
+	defer h2.Close()
+
+	var version atomic.Pointer[string]
+	v1, v2 := "foo/1", "foo/2"
+	version.Store(&v1)
+	ids1, err := identify.NewIDService(h1, identify.UserAgentFunc(func() string { return *version.Load() }))
+	require.NoError(t, err)
+	defer ids1.Close()
+	ids1.Start()
+	ids2, err := identify.NewIDService(h2)
+	require.NoError(t, err)
+	defer ids2.Close()
+	ids2.Start()
+
+	require.NoError(t, h2.Connect(context.Background(), peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}))
+	ids2.IdentifyConn(h2.Network().ConnsToPeer(h1.ID())[0])
+	av, err := h2.Peerstore().Get(h1.ID(), "AgentVersion")
+	require.NoError(t, err)
+	require.Equal(t, v1, av)
+
+	// The new agent version is sent with the next push.
+	version.Store(&v2)
+	h1.SetStreamHandler("rand", func(network.Stream) {})
+	require.Eventually(t, func() bool {
+		av, err := h2.Peerstore().Get(h1.ID(), "AgentVersion")
+		return err == nil && av == v2
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestOmitUserAgent(t *testing.T) {
+	h1 := blhost.NewBlankHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC))
+	h2 := blhost.NewBlankHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC))
+	defer h1.Close()
+	defer h2.Close()
+
+	ids1, err := identify.NewIDService(h1, identify.UserAgent("foo"), identify.OmitUserAgent())
+	require.NoError(t, err)
+	defer ids1.Close()
+	ids1.Start()
+	ids2, err := identify.NewIDService(h2)
+	require.NoError(t, err)
+	defer ids2.Close()
+	ids2.Start()
+
+	require.NoError(t, h2.Connect(context.Background(), peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}))
+	c := h2.Network().ConnsToPeer(h1.ID())[0]
+	ids2.IdentifyConn(c)
+	info, ok := ids2.Info(c)
+	require.True(t, ok)
+	require.Empty(t, info.AgentVersion)
+}
+
+func TestIdentifyDelta(t *testing.T) {
+	h1 := blhost.NewBlankHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC))
+	h2 := blhost.NewBlankHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC))
+	defer h1.Close()
+	defer h2.Close()
+
+	ids1, err := identify.NewIDService(h1)
+	require.NoError(t, err)
+	defer ids1.Close()
+	ids1.Start()
+	ids2, err := identify.NewIDService(h2)
+	require.NoError(t, err)
+	defer ids2.Close()
+	ids2.Start()
+
+	// Count the full pushes h2 receives.
+	var pushes atomic.Int32
+	h2.SetStreamHandler(identify.IDPush, func(s network.Stream) {
+		defer s.Close()
+		io.ReadAll(s)
+		pushes.Add(1)
+	})
+
+	require.NoError(t, h1.Connect(context.Background(), peer.AddrInfo{ID: h2.ID(), Addrs: h2.Addrs()}))
+	<-ids1.IdentifyWait(h1.Network().ConnsToPeer(h2.ID())[0])
+	<-ids2.IdentifyWait(h2.Network().ConnsToPeer(h1.ID())[0])
+
+	supports := func(proto protocol.ID) bool {
+		sup, err := h2.Peerstore().SupportsProtocols(h1.ID(), proto)
+		return err == nil && len(sup) == 1
+	}
+
+	// If only our protocols change, a delta is sent.
+	h1.SetStreamHandler("proto1", func(network.Stream) {})
+	require.Eventually(t, func() bool { return supports("proto1") }, time.Second, 10*time.Millisecond)
+	h1.RemoveStreamHandler("proto1")
+	require.Eventually(t, func() bool { return !supports("proto1") }, time.Second, 10*time.Millisecond)
+	require.True(t, supports(identify.ID))
+	require.Zero(t, pushes.Load())
+
+	// If our addresses change, a full push is sent.
+	require.NoError(t, h1.Network().Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0")))
+	h1.SetStreamHandler("proto2", func(network.Stream) {})
+	require.Eventually(t, func() bool { return pushes.Load() == 1 }, time.Second, 10*time.Millisecond)
+
+	// If the peer doesn't accept the delta, a full push is sent instead.
+	h2.RemoveStreamHandler(identify.IDDelta)
+	require.Eventually(t, func() bool {
+		sup, err := h1.Peerstore().SupportsProtocols(h2.ID(), identify.IDDelta)
+		return err == nil && len(sup) == 0
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(t, h1.Peerstore().AddProtocols(h2.ID(), identify.IDDelta))
+	h1.SetStreamHandler("proto3", func(network.Stream) {})
+	require.Eventually(t, func() bool { return pushes.Load() == 2 }, time.Second, 10*time.Millisecond)
+}
+
+func TestProtocolsUpdatedFromPeerstore(t *testing.T) {
+	h := blhost.NewBlankHost(swarmt.GenSwarm(t, swarmt.OptDialOnly))
+	defer h.Close()
+	ids, err := identify.NewIDService(h)
+	require.NoError(t, err)
+	defer ids.Close()
+
+	sub, err := h.EventBus().Subscribe(&event.EvtPeerProtocolsUpdated{})
+	require.NoError(t, err)
+	defer sub.Close()
+
+	// Changes made to the peerstore by anybody are announced, in order.
+	p := coretest.RandPeerIDFatal(t)
+	require.NoError(t, h.Peerstore().AddProtocols(p, "/a"))
+	require.NoError(t, h.Peerstore().RemoveProtocols(p, "/a"))
+	for _, expected := range []event.EvtPeerProtocolsUpdated{
+		{Peer: p, Added: []protocol.ID{"/a"}},
+		{Peer: p, Removed: []protocol.ID{"/a"}},
+	} {
+		select {
+		case e := <-sub.Out():
+			require.Equal(t, expected, e)
+		case <-time.After(time.Second):
+			t.Fatal("timeout")
+		}
+	}
+}
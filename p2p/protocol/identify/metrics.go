@@ -1,6 +1,8 @@
 package identify
 
 import (
+	"time"
+
 	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/p2p/metricshelper"
@@ -65,6 +67,56 @@ var (
 			Buckets:   buckets,
 		},
 	)
+	truncatedAddrs = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "truncated_addrs_total",
+			Help:      "Number of addresses dropped from identify messages because of size limits",
+		},
+		[]string{"dir"},
+	)
+	truncatedProtocols = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "truncated_protocols_total",
+			Help:      "Number of protocols dropped from identify messages because of size limits",
+		},
+		[]string{"dir"},
+	)
+	unsignedAddrs = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "unsigned_addrs_total",
+			Help:      "Identify messages received without a signed peer record",
+		},
+		[]string{"outcome"},
+	)
+	identifyDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Name:      "duration_seconds",
+			Help:      "Time taken to send or receive an identify message",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+		},
+		[]string{"type", "dir"},
+	)
+	messageSize = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricNamespace,
+			Name:      "message_size_bytes",
+			Help:      "Size of identify messages",
+			Buckets:   prometheus.ExponentialBuckets(128, 2, 8),
+		},
+		[]string{"type", "dir"},
+	)
+	identifyFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "failures_total",
+			Help:      "Failed or rejected identify exchanges",
+		},
+		[]string{"type", "dir", "reason"},
+	)
 	numAddrsReceived = prometheus.NewHistogram(
 		prometheus.HistogramOpts{
 			Namespace: metricNamespace,
@@ -82,6 +134,12 @@ var (
 		addrsCount,
 		numProtocolsReceived,
 		numAddrsReceived,
+		truncatedAddrs,
+		truncatedProtocols,
+		unsignedAddrs,
+		identifyDuration,
+		messageSize,
+		identifyFailures,
 	}
 	// 1 to 20 and then up to 100 in steps of 5
 	buckets = append(
@@ -104,9 +162,42 @@ type MetricsTracer interface {
 	IdentifySent(isPush bool, numProtocols int, numAddrs int)
 }
 
+// TruncationMetricsTracer can be implemented by a MetricsTracer to count the
+// addresses and protocols dropped from identify messages.
+type TruncationMetricsTracer interface {
+	// IdentifyTruncated counts the addresses and protocols dropped from identify
+	// messages because they exceeded the limits. Inbound refers to messages
+	// received from peers, outbound to messages sent by us.
+	IdentifyTruncated(dir network.Direction, droppedAddrs int, droppedProtocols int)
+}
+
+// SignedRecordMetricsTracer can be implemented by a MetricsTracer to count the
+// identify messages received without a signed peer record.
+type SignedRecordMetricsTracer interface {
+	// UnsignedAddrsReceived counts identify messages received without a signed
+	// peer record, and whether their unsigned addresses were accepted.
+	UnsignedAddrsReceived(accepted bool)
+}
+
+// ExchangeMetricsTracer can be implemented by a MetricsTracer to track the
+// duration, size and failures of identify exchanges.
+type ExchangeMetricsTracer interface {
+	// IdentifyCompleted tracks the duration and the size of an identify message
+	// that was sent or received successfully. dir is the direction of the
+	// stream.
+	IdentifyCompleted(isPush bool, dir network.Direction, duration time.Duration, size int)
+
+	// IdentifyFailed counts identify messages that couldn't be sent or
+	// received, or that were rejected by the rate limiter.
+	IdentifyFailed(isPush bool, dir network.Direction, rateLimited bool)
+}
+
 type metricsTracer struct{}
 
 var _ MetricsTracer = &metricsTracer{}
+var _ TruncationMetricsTracer = &metricsTracer{}
+var _ SignedRecordMetricsTracer = &metricsTracer{}
+var _ ExchangeMetricsTracer = &metricsTracer{}
 
 type metricsTracerSetting struct {
 	reg prometheus.Registerer
@@ -186,6 +277,60 @@ func (t *metricsTracer) IdentifyReceived(isPush bool, numProtocols int, numAddrs
 	numAddrsReceived.Observe(float64(numAddrs))
 }
 
+func (t *metricsTracer) IdentifyTruncated(dir network.Direction, droppedAddrs int, droppedProtocols int) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	*tags = append(*tags, metricshelper.GetDirection(dir))
+	if droppedAddrs > 0 {
+		truncatedAddrs.WithLabelValues(*tags...).Add(float64(droppedAddrs))
+	}
+	if droppedProtocols > 0 {
+		truncatedProtocols.WithLabelValues(*tags...).Add(float64(droppedProtocols))
+	}
+}
+
+func (t *metricsTracer) UnsignedAddrsReceived(accepted bool) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	if accepted {
+		*tags = append(*tags, "accepted")
+	} else {
+		*tags = append(*tags, "rejected")
+	}
+	unsignedAddrs.WithLabelValues(*tags...).Inc()
+}
+
+func (t *metricsTracer) IdentifyCompleted(isPush bool, dir network.Direction, duration time.Duration, size int) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	*tags = append(*tags, getIdentifyType(isPush), metricshelper.GetDirection(dir))
+	identifyDuration.WithLabelValues(*tags...).Observe(duration.Seconds())
+	messageSize.WithLabelValues(*tags...).Observe(float64(size))
+}
+
+func (t *metricsTracer) IdentifyFailed(isPush bool, dir network.Direction, rateLimited bool) {
+	tags := metricshelper.GetStringSlice()
+	defer metricshelper.PutStringSlice(tags)
+
+	*tags = append(*tags, getIdentifyType(isPush), metricshelper.GetDirection(dir))
+	if rateLimited {
+		*tags = append(*tags, "rate_limited")
+	} else {
+		*tags = append(*tags, "error")
+	}
+	identifyFailures.WithLabelValues(*tags...).Inc()
+}
+
+func getIdentifyType(isPush bool) string {
+	if isPush {
+		return "push"
+	}
+	return "identify"
+}
+
 func (t *metricsTracer) ConnPushSupport(support identifyPushSupport) {
 	tags := metricshelper.GetStringSlice()
 	defer metricshelper.PutStringSlice(tags)
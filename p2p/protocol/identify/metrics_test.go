@@ -5,8 +5,10 @@ package identify
 import (
 	"math/rand"
 	"testing"
+	"time"
 
 	"github.com/libp2p/go-libp2p/core/event"
+	"github.com/libp2p/go-libp2p/core/network"
 )
 
 func TestMetricsNoAllocNoCover(t *testing.T) {
@@ -24,10 +26,20 @@ func TestMetricsNoAllocNoCover(t *testing.T) {
 
 	tr := NewMetricsTracer()
 	tests := map[string]func(){
-		"TriggeredPushes":  func() { tr.TriggeredPushes(events[rand.Intn(len(events))]) },
-		"ConnPushSupport":  func() { tr.ConnPushSupport(pushSupport[rand.Intn(len(pushSupport))]) },
-		"IdentifyReceived": func() { tr.IdentifyReceived(rand.Intn(2) == 0, rand.Intn(20), rand.Intn(20)) },
-		"IdentifySent":     func() { tr.IdentifySent(rand.Intn(2) == 0, rand.Intn(20), rand.Intn(20)) },
+		"TriggeredPushes":       func() { tr.TriggeredPushes(events[rand.Intn(len(events))]) },
+		"ConnPushSupport":       func() { tr.ConnPushSupport(pushSupport[rand.Intn(len(pushSupport))]) },
+		"IdentifyReceived":      func() { tr.IdentifyReceived(rand.Intn(2) == 0, rand.Intn(20), rand.Intn(20)) },
+		"IdentifySent":          func() { tr.IdentifySent(rand.Intn(2) == 0, rand.Intn(20), rand.Intn(20)) },
+		"UnsignedAddrsReceived": func() { tr.(SignedRecordMetricsTracer).UnsignedAddrsReceived(rand.Intn(2) == 0) },
+		"IdentifyCompleted": func() {
+			tr.(ExchangeMetricsTracer).IdentifyCompleted(rand.Intn(2) == 0, network.Direction(rand.Intn(3)), time.Duration(rand.Intn(1000))*time.Millisecond, rand.Intn(8192))
+		},
+		"IdentifyFailed": func() {
+			tr.(ExchangeMetricsTracer).IdentifyFailed(rand.Intn(2) == 0, network.Direction(rand.Intn(3)), rand.Intn(2) == 0)
+		},
+		"IdentifyTruncated": func() {
+			tr.(TruncationMetricsTracer).IdentifyTruncated(network.Direction(rand.Intn(3)), rand.Intn(20), rand.Intn(20))
+		},
 	}
 	for method, f := range tests {
 		allocs := testing.AllocsPerRun(1000, f)
@@ -3,6 +3,7 @@ package identify
 import (
 	"context"
 	"fmt"
+	"slices"
 	"sync"
 	"time"
 
@@ -24,6 +25,9 @@ type natEmitter struct {
 	currentTCPNATDeviceType  network.NATDeviceType
 	emitNATDeviceTypeChanged event.Emitter
 
+	currentObservedAddrs     []event.ObservedAddr
+	emitObservedAddrsUpdated event.Emitter
+
 	observedAddrMgr *ObservedAddrManager
 }
 
@@ -47,6 +51,12 @@ func newNATEmitter(h host.Host, o *ObservedAddrManager, eventInterval time.Durat
 	}
 	n.emitNATDeviceTypeChanged = emitter
 
+	emitter, err = h.EventBus().Emitter(new(event.EvtObservedAddrsUpdated), eventbus.Stateful)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create emitter for observed addresses: %s", err)
+	}
+	n.emitObservedAddrsUpdated = emitter
+
 	n.wg.Add(1)
 	go n.worker()
 	return n, nil
@@ -92,6 +102,10 @@ func (n *natEmitter) worker() {
 }
 
 func (n *natEmitter) maybeNotify() {
+	if addrs := n.observedAddrMgr.AddrsWithConfidence(); !slices.EqualFunc(addrs, n.currentObservedAddrs, observedAddrEqual) {
+		n.currentObservedAddrs = addrs
+		n.emitObservedAddrsUpdated.Emit(event.EvtObservedAddrsUpdated{Addrs: addrs})
+	}
 	if n.reachability == network.ReachabilityPrivate {
 		tcpNATType, udpNATType := n.observedAddrMgr.getNATType()
 		if tcpNATType != n.currentTCPNATDeviceType {
@@ -116,4 +130,9 @@ func (n *natEmitter) Close() {
 	n.wg.Wait()
 	n.reachabilitySub.Close()
 	n.emitNATDeviceTypeChanged.Close()
+	n.emitObservedAddrsUpdated.Close()
+}
+
+func observedAddrEqual(a, b event.ObservedAddr) bool {
+	return a.Addr.Equal(b.Addr) && a.Observers == b.Observers && a.Activated == b.Activated && a.Inferred == b.Inferred
 }
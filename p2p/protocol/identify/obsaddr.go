@@ -1,6 +1,7 @@
 package identify
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"net"
@@ -8,6 +9,7 @@ import (
 	"sort"
 	"sync"
 
+	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/network"
 
 	ma "github.com/multiformats/go-multiaddr"
@@ -95,6 +97,9 @@ const observerSetCacheSize = 5
 type observerSet struct {
 	ObservedTWAddr ma.Multiaddr
 	ObservedBy     map[string]int
+	// ObservedOn counts the connections the address was observed on, by the
+	// transport specific rest of the local address, e.g. /quic-v1
+	ObservedOn map[string]int
 
 	mu               sync.RWMutex            // protects following
 	cachedMultiaddrs map[string]ma.Multiaddr // cache of localMultiaddr rest(addr - thinwaist) => output multiaddr
@@ -275,6 +280,63 @@ func (o *ObservedAddrManager) Addrs() []ma.Multiaddr {
 	return addrs
 }
 
+// AddrsWithConfidence returns all observed addresses, including the ones that
+// weren't observed by enough peers to be activated, along with the number of
+// distinct observers that reported them. The addresses are sorted by decreasing
+// number of observers.
+func (o *ObservedAddrManager) AddrsWithConfidence() []event.ObservedAddr {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	// The local addresses observations were made on come first, so that
+	// addresses are only reported as inferred if there's no observation for them.
+	locals := make([]thinWaist, 0, len(o.localAddrs))
+	for _, t := range o.localAddrs {
+		locals = append(locals, t.thinWaist)
+	}
+	lAddrs, err := o.interfaceListenAddrs()
+	if err != nil {
+		log.Warnw("failed to get interface resolved listen addrs. Using just the listen addrs", "error", err)
+		lAddrs = nil
+	}
+	for _, a := range append(lAddrs, o.listenAddrs()...) {
+		if t, err := thinWaistForm(o.normalize(a)); err == nil {
+			locals = append(locals, t)
+		}
+	}
+
+	var res []event.ObservedAddr
+	seen := make(map[string]struct{})
+	for localTWStr, sets := range o.externalAddrs {
+		activated := o.getTopExternalAddrs(localTWStr)
+		for _, t := range locals {
+			if string(t.TW.Bytes()) != localTWStr {
+				continue
+			}
+			for _, s := range sets {
+				addr := s.cacheMultiaddr(t.Rest)
+				if _, ok := seen[string(addr.Bytes())]; ok {
+					continue
+				}
+				seen[string(addr.Bytes())] = struct{}{}
+				res = append(res, event.ObservedAddr{
+					Addr:      addr,
+					Observers: len(s.ObservedBy),
+					Activated: slices.Contains(activated, s),
+					Inferred:  s.ObservedOn[string(t.Rest.Bytes())] == 0,
+				})
+			}
+		}
+	}
+	slices.SortFunc(res, func(a, b event.ObservedAddr) int {
+		if a.Observers != b.Observers {
+			return b.Observers - a.Observers
+		}
+		return bytes.Compare(a.Addr.Bytes(), b.Addr.Bytes())
+	})
+	return res
+}
+
 func (o *ObservedAddrManager) getTopExternalAddrs(localTWStr string) []*observerSet {
 	observerSets := make([]*observerSet, 0, len(o.externalAddrs[localTWStr]))
 	for _, v := range o.externalAddrs[localTWStr] {
@@ -461,14 +523,14 @@ func (o *ObservedAddrManager) recordObservationUnlocked(conn connMultiaddrs, loc
 			return
 		}
 		// if we have a previous entry remove it from externalAddrs
-		o.removeExternalAddrsUnlocked(observer, localTWStr, string(prevObservedTWAddr.Bytes()))
+		o.removeExternalAddrsUnlocked(observer, localTWStr, string(prevObservedTWAddr.Bytes()), localTW.Rest)
 		// no need to change the localAddrs map here
 	}
 	o.connObservedTWAddrs[conn] = observedTW.TW
-	o.addExternalAddrsUnlocked(observedTW.TW, observer, localTWStr, observedTWStr)
+	o.addExternalAddrsUnlocked(observedTW.TW, observer, localTWStr, observedTWStr, localTW.Rest)
 }
 
-func (o *ObservedAddrManager) removeExternalAddrsUnlocked(observer, localTWStr, observedTWStr string) {
+func (o *ObservedAddrManager) removeExternalAddrsUnlocked(observer, localTWStr, observedTWStr string, localRest ma.Multiaddr) {
 	s, ok := o.externalAddrs[localTWStr][observedTWStr]
 	if !ok {
 		return
@@ -477,6 +539,11 @@ func (o *ObservedAddrManager) removeExternalAddrsUnlocked(observer, localTWStr,
 	if s.ObservedBy[observer] <= 0 {
 		delete(s.ObservedBy, observer)
 	}
+	restStr := string(localRest.Bytes())
+	s.ObservedOn[restStr]--
+	if s.ObservedOn[restStr] <= 0 {
+		delete(s.ObservedOn, restStr)
+	}
 	if len(s.ObservedBy) == 0 {
 		delete(o.externalAddrs[localTWStr], observedTWStr)
 	}
@@ -485,12 +552,13 @@ func (o *ObservedAddrManager) removeExternalAddrsUnlocked(observer, localTWStr,
 	}
 }
 
-func (o *ObservedAddrManager) addExternalAddrsUnlocked(observedTWAddr ma.Multiaddr, observer, localTWStr, observedTWStr string) {
+func (o *ObservedAddrManager) addExternalAddrsUnlocked(observedTWAddr ma.Multiaddr, observer, localTWStr, observedTWStr string, localRest ma.Multiaddr) {
 	s, ok := o.externalAddrs[localTWStr][observedTWStr]
 	if !ok {
 		s = &observerSet{
 			ObservedTWAddr: observedTWAddr,
 			ObservedBy:     make(map[string]int),
+			ObservedOn:     make(map[string]int),
 		}
 		if _, ok := o.externalAddrs[localTWStr]; !ok {
 			o.externalAddrs[localTWStr] = make(map[string]*observerSet)
@@ -498,6 +566,7 @@ func (o *ObservedAddrManager) addExternalAddrsUnlocked(observedTWAddr ma.Multiad
 		o.externalAddrs[localTWStr][observedTWStr] = s
 	}
 	s.ObservedBy[observer]++
+	s.ObservedOn[string(localRest.Bytes())]++
 }
 
 func (o *ObservedAddrManager) removeConn(conn connMultiaddrs) {
@@ -533,7 +602,7 @@ func (o *ObservedAddrManager) removeConn(conn connMultiaddrs) {
 		return
 	}
 
-	o.removeExternalAddrsUnlocked(observer, string(localTW.TW.Bytes()), string(observedTWAddr.Bytes()))
+	o.removeExternalAddrsUnlocked(observer, string(localTW.TW.Bytes()), string(observedTWAddr.Bytes()), localTW.Rest)
 	select {
 	case o.addrRecordedNotif <- struct{}{}:
 	default:
@@ -176,6 +176,44 @@ func TestObservedAddrManager(t *testing.T) {
 		}, 1*time.Second, 100*time.Millisecond)
 	})
 
+	t.Run("Confidence", func(t *testing.T) {
+		o := newObservedAddrMgr()
+		defer o.Close()
+		observedQuic := ma.StringCast("/ip4/2.2.2.2/udp/2/quic-v1")
+		inferredWebTransport := ma.StringCast("/ip4/2.2.2.2/udp/2/quic-v1/webtransport")
+		var conns []*mockConn
+		for i := 0; i < ActivationThresh; i++ {
+			conns = append(conns, newConn(quic4ListenAddr, ma.StringCast(fmt.Sprintf("/ip4/1.2.3.%d/udp/1/quic-v1", i))))
+		}
+		for _, c := range conns[:ActivationThresh-1] {
+			o.Record(c, observedQuic)
+		}
+		require.Eventually(t, func() bool {
+			addrs := o.AddrsWithConfidence()
+			return len(addrs) == 2 && addrs[0].Observers == ActivationThresh-1
+		}, 1*time.Second, 10*time.Millisecond)
+		require.Empty(t, o.Addrs())
+		addrs := o.AddrsWithConfidence()
+		require.True(t, addrs[0].Addr.Equal(observedQuic))
+		require.False(t, addrs[0].Activated)
+		require.False(t, addrs[0].Inferred)
+		require.True(t, addrs[1].Addr.Equal(inferredWebTransport))
+		require.False(t, addrs[1].Activated)
+		require.True(t, addrs[1].Inferred)
+
+		o.Record(conns[ActivationThresh-1], observedQuic)
+		require.Eventually(t, func() bool {
+			addrs := o.AddrsWithConfidence()
+			return len(addrs) == 2 && addrs[0].Observers == ActivationThresh && addrs[0].Activated && addrs[1].Activated
+		}, 1*time.Second, 10*time.Millisecond)
+
+		for _, c := range conns {
+			o.removeConn(c)
+		}
+		require.Empty(t, o.AddrsWithConfidence())
+		require.True(t, checkAllEntriesRemoved(o))
+	})
+
 	t.Run("SameObservers", func(t *testing.T) {
 		o := newObservedAddrMgr()
 		defer o.Close()
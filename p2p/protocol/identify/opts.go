@@ -1,14 +1,33 @@
 package identify
 
-import "time"
+import (
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
 
 type config struct {
 	protocolVersion            string
 	userAgent                  string
+	userAgentFunc              func() string
+	omitUserAgent              bool
 	disableSignedPeerRecord    bool
 	metricsTracer              MetricsTracer
 	disableObservedAddrManager bool
+	disablePush                bool
 	timeout                    time.Duration
+	addrFilter                 AddrFilter
+	pushCoalesceWindow         time.Duration
+	pushMinInterval            time.Duration
+	maxMessageSize             int
+	maxOwnMessageSize          int
+	maxRemoteAddrs             int
+	maxRemoteProtocols         int
+	requireSignedPeerRecord    bool
+	allowUnsignedAddrs         func(p peer.ID, agentVersion string) bool
 }
 
 // Option is an option function for identify.
@@ -29,6 +48,22 @@ func UserAgent(ua string) Option {
 	}
 }
 
+// UserAgentFunc sets a function providing the user agent this node identifies
+// itself with. It is called every time an identify message is sent, and takes
+// precedence over UserAgent.
+func UserAgentFunc(f func() string) Option {
+	return func(cfg *config) {
+		cfg.userAgentFunc = f
+	}
+}
+
+// OmitUserAgent stops sending the user agent in identify messages.
+func OmitUserAgent() Option {
+	return func(cfg *config) {
+		cfg.omitUserAgent = true
+	}
+}
+
 // DisableSignedPeerRecord disables populating signed peer records on the outgoing Identify response
 // and ONLY sends the unsigned addresses.
 func DisableSignedPeerRecord() Option {
@@ -51,9 +86,108 @@ func DisableObservedAddrManager() Option {
 	}
 }
 
+// DisablePush disables the identify push and identify delta protocols. The host
+// neither pushes updates of its addresses and protocols to its peers, nor
+// accepts pushes from them. Peers only learn about changes when they identify
+// the host again.
+func DisablePush() Option {
+	return func(cfg *config) {
+		cfg.disablePush = true
+	}
+}
+
+// WithPushCoalescing delays identify pushes by window after the host's
+// addresses or protocols changed. All changes happening during the window are
+// announced in a single push.
+func WithPushCoalescing(window time.Duration) Option {
+	return func(cfg *config) {
+		cfg.pushCoalesceWindow = window
+	}
+}
+
+// WithPushRateLimit sets the minimum interval between two identify pushes on a
+// connection. Changes happening in the meantime are pushed once the interval
+// has passed.
+func WithPushRateLimit(minInterval time.Duration) Option {
+	return func(cfg *config) {
+		cfg.pushMinInterval = minInterval
+	}
+}
+
 // WithTimeout sets the timeout for identify interactions.
 func WithTimeout(timeout time.Duration) Option {
 	return func(cfg *config) {
 		cfg.timeout = timeout
 	}
 }
+
+// AddrFilter selects the addresses announced to the peer on the other side of
+// conn. It is passed a copy of the host's addresses and returns the addresses
+// to announce, which may be filtered, reordered or rewritten.
+type AddrFilter func(conn network.Conn, addrs []ma.Multiaddr) []ma.Multiaddr
+
+// WithAddrFilter sets a filter applied to the addresses sent in identify
+// responses and pushes, allowing to announce different addresses to different
+// peers. For example, private addresses could only be announced to peers on the
+// same LAN.
+//
+// If the filter changes the addresses, a new signed peer record containing only
+// the announced addresses is created for the peer.
+func WithAddrFilter(f AddrFilter) Option {
+	return func(cfg *config) {
+		cfg.addrFilter = f
+	}
+}
+
+// WithMaxMessageSize sets the maximum size of the identify messages we accept
+// from peers. It must be positive. Defaults to 8 KiB.
+func WithMaxMessageSize(size int) Option {
+	return func(cfg *config) {
+		cfg.maxMessageSize = size
+	}
+}
+
+// WithMaxOwnMessageSize sets the maximum size of the identify messages we send.
+// If our addresses don't fit, the least preferred ones are not announced.
+// It must be positive. Defaults to 4 KiB, which is the limit used by other libp2p implementations.
+func WithMaxOwnMessageSize(size int) Option {
+	return func(cfg *config) {
+		cfg.maxOwnMessageSize = size
+	}
+}
+
+// WithMaxRemoteAddrs sets the maximum number of listen addresses we accept from
+// a peer. It must be positive. Defaults to 500.
+func WithMaxRemoteAddrs(n int) Option {
+	return func(cfg *config) {
+		cfg.maxRemoteAddrs = n
+	}
+}
+
+// WithMaxRemoteProtocols sets the maximum number of protocols we accept from a
+// peer. 0, the default, means that the number of protocols is only limited by
+// the message size.
+func WithMaxRemoteProtocols(n int) Option {
+	return func(cfg *config) {
+		cfg.maxRemoteProtocols = n
+	}
+}
+
+// RequireSignedPeerRecord only accepts the addresses of peers that send a valid
+// signed peer record. Unsigned addresses are ignored, unless accepted by
+// AllowUnsignedAddrs. It can't be combined with DisableSignedPeerRecord.
+func RequireSignedPeerRecord() Option {
+	return func(cfg *config) {
+		cfg.requireSignedPeerRecord = true
+	}
+}
+
+// AllowUnsignedAddrs accepts unsigned addresses from the peers for which f
+// returns true, even if RequireSignedPeerRecord is used. This allows migrating
+// to signed peer records while some peers, identified by their peer ID or
+// agent version, don't send them yet.
+func AllowUnsignedAddrs(f func(p peer.ID, agentVersion string) bool) Option {
+	return func(cfg *config) {
+		cfg.allowUnsignedAddrs = f
+	}
+}
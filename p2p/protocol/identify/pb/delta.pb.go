@@ -0,0 +1,144 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.5
+// 	protoc        v5.29.2
+// source: p2p/protocol/identify/pb/delta.proto
+
+package pb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Delta is sent using the identify delta protocol to announce changes of the
+// protocols a node is running, without sending a full identify push.
+type Delta struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// addedProtocols are the protocols the node started running
+	AddedProtocols []string `protobuf:"bytes,1,rep,name=addedProtocols" json:"addedProtocols,omitempty"`
+	// removedProtocols are the protocols the node stopped running
+	RemovedProtocols []string `protobuf:"bytes,2,rep,name=removedProtocols" json:"removedProtocols,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *Delta) Reset() {
+	*x = Delta{}
+	mi := &file_p2p_protocol_identify_pb_delta_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Delta) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Delta) ProtoMessage() {}
+
+func (x *Delta) ProtoReflect() protoreflect.Message {
+	mi := &file_p2p_protocol_identify_pb_delta_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Delta.ProtoReflect.Descriptor instead.
+func (*Delta) Descriptor() ([]byte, []int) {
+	return file_p2p_protocol_identify_pb_delta_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Delta) GetAddedProtocols() []string {
+	if x != nil {
+		return x.AddedProtocols
+	}
+	return nil
+}
+
+func (x *Delta) GetRemovedProtocols() []string {
+	if x != nil {
+		return x.RemovedProtocols
+	}
+	return nil
+}
+
+var File_p2p_protocol_identify_pb_delta_proto protoreflect.FileDescriptor
+
+var file_p2p_protocol_identify_pb_delta_proto_rawDesc = string([]byte{
+	0x0a, 0x24, 0x70, 0x32, 0x70, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x2f, 0x69,
+	0x64, 0x65, 0x6e, 0x74, 0x69, 0x66, 0x79, 0x2f, 0x70, 0x62, 0x2f, 0x64, 0x65, 0x6c, 0x74, 0x61,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0b, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x66, 0x79,
+	0x2e, 0x70, 0x62, 0x22, 0x5b, 0x0a, 0x05, 0x44, 0x65, 0x6c, 0x74, 0x61, 0x12, 0x26, 0x0a, 0x0e,
+	0x61, 0x64, 0x64, 0x65, 0x64, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x73, 0x18, 0x01,
+	0x20, 0x03, 0x28, 0x09, 0x52, 0x0e, 0x61, 0x64, 0x64, 0x65, 0x64, 0x50, 0x72, 0x6f, 0x74, 0x6f,
+	0x63, 0x6f, 0x6c, 0x73, 0x12, 0x2a, 0x0a, 0x10, 0x72, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x64, 0x50,
+	0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x10,
+	0x72, 0x65, 0x6d, 0x6f, 0x76, 0x65, 0x64, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x73,
+	0x42, 0x36, 0x5a, 0x34, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6c,
+	0x69, 0x62, 0x70, 0x32, 0x70, 0x2f, 0x67, 0x6f, 0x2d, 0x6c, 0x69, 0x62, 0x70, 0x32, 0x70, 0x2f,
+	0x70, 0x32, 0x70, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x2f, 0x69, 0x64, 0x65,
+	0x6e, 0x74, 0x69, 0x66, 0x79, 0x2f, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x32,
+})
+
+var (
+	file_p2p_protocol_identify_pb_delta_proto_rawDescOnce sync.Once
+	file_p2p_protocol_identify_pb_delta_proto_rawDescData []byte
+)
+
+func file_p2p_protocol_identify_pb_delta_proto_rawDescGZIP() []byte {
+	file_p2p_protocol_identify_pb_delta_proto_rawDescOnce.Do(func() {
+		file_p2p_protocol_identify_pb_delta_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_p2p_protocol_identify_pb_delta_proto_rawDesc), len(file_p2p_protocol_identify_pb_delta_proto_rawDesc)))
+	})
+	return file_p2p_protocol_identify_pb_delta_proto_rawDescData
+}
+
+var file_p2p_protocol_identify_pb_delta_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_p2p_protocol_identify_pb_delta_proto_goTypes = []any{
+	(*Delta)(nil), // 0: identify.pb.Delta
+}
+var file_p2p_protocol_identify_pb_delta_proto_depIdxs = []int32{
+	0, // [0:0] is the sub-list for method output_type
+	0, // [0:0] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_p2p_protocol_identify_pb_delta_proto_init() }
+func file_p2p_protocol_identify_pb_delta_proto_init() {
+	if File_p2p_protocol_identify_pb_delta_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_p2p_protocol_identify_pb_delta_proto_rawDesc), len(file_p2p_protocol_identify_pb_delta_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_p2p_protocol_identify_pb_delta_proto_goTypes,
+		DependencyIndexes: file_p2p_protocol_identify_pb_delta_proto_depIdxs,
+		MessageInfos:      file_p2p_protocol_identify_pb_delta_proto_msgTypes,
+	}.Build()
+	File_p2p_protocol_identify_pb_delta_proto = out.File
+	file_p2p_protocol_identify_pb_delta_proto_goTypes = nil
+	file_p2p_protocol_identify_pb_delta_proto_depIdxs = nil
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
 )
 
 var log = logging.Logger("ping")
@@ -114,7 +115,8 @@ func pingError(err error) chan Result {
 // Ping pings the remote peer until the context is canceled, returning a stream
 // of RTTs or errors.
 func Ping(ctx context.Context, h host.Host, p peer.ID) <-chan Result {
-	s, err := h.NewStream(network.WithAllowLimitedConn(ctx, "ping"), p, ID)
+	sCtx := network.WithStreamPriority(network.WithAllowLimitedConn(ctx, "ping"), network.StreamPriorityHigh)
+	s, err := h.NewStream(sCtx, p, ID)
 	if err != nil {
 		return pingError(err)
 	}
@@ -135,6 +137,7 @@ func Ping(ctx context.Context, h host.Host, p peer.ID) <-chan Result {
 
 	ctx, cancel := context.WithCancel(ctx)
 
+	transport := s.Conn().ConnState().Transport
 	out := make(chan Result)
 	go func() {
 		defer close(out)
@@ -151,7 +154,7 @@ func Ping(ctx context.Context, h host.Host, p peer.ID) <-chan Result {
 
 			// No error, record the RTT.
 			if res.Error == nil {
-				h.Peerstore().RecordLatency(p, res.RTT)
+				peerstore.RecordLatencySample(h.Peerstore(), p, transport, res.RTT)
 			}
 
 			select {
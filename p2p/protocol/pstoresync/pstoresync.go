@@ -0,0 +1,250 @@
+// Package pstoresync implements a protocol to replicate signed peer records
+// between nodes run by the same operator. A fleet of nodes can share the
+// addresses they learned, and a restarted node can warm up its peerstore
+// instantly by syncing with another node of the fleet.
+//
+// Only signed peer records are replicated, and every record is verified before
+// it is added to the peerstore, so a node can't inject addresses that were not
+// signed by the peer they belong to. Both sides only talk to trusted peers.
+package pstoresync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/record"
+	"github.com/libp2p/go-msgio"
+)
+
+var log = logging.Logger("pstoresync")
+
+const (
+	ID = "/libp2p/pstore-sync/1.0.0"
+
+	ServiceName = "libp2p.pstoresync"
+
+	// maxRecordSize is the maximum size of a marshaled signed peer record.
+	maxRecordSize = 8 << 10
+
+	streamTimeout = time.Minute
+)
+
+// ErrUntrustedPeer is returned when syncing with a peer that is not trusted.
+var ErrUntrustedPeer = errors.New("peer is not trusted")
+
+type config struct {
+	trusted    func(peer.ID) bool
+	ttl        time.Duration
+	maxRecords int
+}
+
+// Option is an option for NewService.
+type Option func(*config) error
+
+// WithTrustedPeers sets the peers we exchange records with. Records are
+// neither sent to nor accepted from other peers.
+func WithTrustedPeers(peers ...peer.ID) Option {
+	return func(c *config) error {
+		set := make(map[peer.ID]struct{}, len(peers))
+		for _, p := range peers {
+			set[p] = struct{}{}
+		}
+		c.trusted = func(p peer.ID) bool {
+			_, ok := set[p]
+			return ok
+		}
+		return nil
+	}
+}
+
+// WithTrustFunc sets a function deciding whether we exchange records with a
+// peer, e.g. to trust all peers of a fleet without listing them upfront.
+func WithTrustFunc(f func(peer.ID) bool) Option {
+	return func(c *config) error {
+		c.trusted = f
+		return nil
+	}
+}
+
+// WithTTL sets the TTL of the addresses learned from synced records. It
+// defaults to peerstore.AddressTTL.
+func WithTTL(ttl time.Duration) Option {
+	return func(c *config) error {
+		if ttl <= 0 {
+			return errors.New("ttl must be positive")
+		}
+		c.ttl = ttl
+		return nil
+	}
+}
+
+// WithMaxRecords sets the maximum number of records accepted in a single sync.
+// It defaults to 10000.
+func WithMaxRecords(n int) Option {
+	return func(c *config) error {
+		if n <= 0 {
+			return errors.New("max records must be positive")
+		}
+		c.maxRecords = n
+		return nil
+	}
+}
+
+// Service serves the signed peer records of the peerstore to trusted peers,
+// and syncs records from them.
+type Service struct {
+	host host.Host
+	cab  peerstore.CertifiedAddrBook
+	conf config
+}
+
+// NewService creates a new Service, and registers its stream handler. At least
+// one trusted peer must be configured, using WithTrustedPeers or WithTrustFunc.
+func NewService(h host.Host, opts ...Option) (*Service, error) {
+	conf := config{
+		ttl:        peerstore.AddressTTL,
+		maxRecords: 10_000,
+	}
+	for _, opt := range opts {
+		if err := opt(&conf); err != nil {
+			return nil, err
+		}
+	}
+	if conf.trusted == nil {
+		return nil, errors.New("no trusted peers configured")
+	}
+	cab, ok := peerstore.GetCertifiedAddrBook(h.Peerstore())
+	if !ok {
+		return nil, errors.New("peerstore should also be a certified address book")
+	}
+
+	s := &Service{host: h, cab: cab, conf: conf}
+	h.SetStreamHandler(ID, s.handleStream)
+	return s, nil
+}
+
+// Close removes the stream handler.
+func (s *Service) Close() error {
+	s.host.RemoveStreamHandler(ID)
+	return nil
+}
+
+func (s *Service) handleStream(str network.Stream) {
+	defer str.Close()
+
+	p := str.Conn().RemotePeer()
+	if !s.conf.trusted(p) {
+		log.Debugw("refusing to sync with untrusted peer", "peer", p)
+		str.Reset()
+		return
+	}
+	if err := str.Scope().SetService(ServiceName); err != nil {
+		log.Debugf("error attaching stream to pstoresync service: %s", err)
+		str.Reset()
+		return
+	}
+	str.SetDeadline(time.Now().Add(streamTimeout))
+
+	w := msgio.NewVarintWriter(str)
+	for _, id := range s.host.Peerstore().PeersWithAddrs() {
+		// The requesting peer knows its own record best.
+		if id == p {
+			continue
+		}
+		env := s.cab.GetPeerRecord(id)
+		if env == nil {
+			continue
+		}
+		b, err := env.Marshal()
+		if err != nil {
+			log.Debugw("failed to marshal peer record", "peer", id, "error", err)
+			continue
+		}
+		if err := w.WriteMsg(b); err != nil {
+			log.Debugw("failed to send peer record", "peer", p, "error", err)
+			str.Reset()
+			return
+		}
+	}
+}
+
+// Sync fetches the signed peer records known by the trusted peer p, and adds
+// the valid ones to the peerstore. It returns the number of records added.
+func (s *Service) Sync(ctx context.Context, p peer.ID) (int, error) {
+	if !s.conf.trusted(p) {
+		return 0, ErrUntrustedPeer
+	}
+	str, err := s.host.NewStream(ctx, p, ID)
+	if err != nil {
+		return 0, err
+	}
+	defer str.Close()
+	if err := str.Scope().SetService(ServiceName); err != nil {
+		str.Reset()
+		return 0, fmt.Errorf("error attaching stream to pstoresync service: %w", err)
+	}
+	if err := str.Scope().ReserveMemory(maxRecordSize, network.ReservationPriorityAlways); err != nil {
+		str.Reset()
+		return 0, fmt.Errorf("error reserving memory for pstoresync stream: %w", err)
+	}
+	defer str.Scope().ReleaseMemory(maxRecordSize)
+	if deadline, ok := ctx.Deadline(); ok {
+		str.SetDeadline(deadline)
+	} else {
+		str.SetDeadline(time.Now().Add(streamTimeout))
+	}
+	// We don't send anything.
+	str.CloseWrite()
+
+	r := msgio.NewVarintReaderSize(str, maxRecordSize)
+	var n, received int
+	for {
+		b, err := r.ReadMsg()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return n, nil
+			}
+			str.Reset()
+			return n, err
+		}
+		received++
+		if received > s.conf.maxRecords {
+			str.Reset()
+			return n, fmt.Errorf("peer sent more than %d records", s.conf.maxRecords)
+		}
+		// The message is not released to the buffer pool, as the envelope
+		// might reference it.
+		ok, err := s.consume(b)
+		if err != nil {
+			log.Debugw("invalid peer record", "from", p, "error", err)
+			continue
+		}
+		if ok {
+			n++
+		}
+	}
+}
+
+func (s *Service) consume(b []byte) (bool, error) {
+	env, rec, err := record.ConsumeEnvelope(b, peer.PeerRecordEnvelopeDomain)
+	if err != nil {
+		return false, err
+	}
+	pr, ok := rec.(*peer.PeerRecord)
+	if !ok {
+		return false, errors.New("not a peer record")
+	}
+	// We know our own addresses.
+	if pr.PeerID == s.host.ID() {
+		return false, nil
+	}
+	return s.cab.ConsumePeerRecord(env, s.conf.ttl)
+}
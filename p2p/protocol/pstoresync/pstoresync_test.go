@@ -0,0 +1,91 @@
+package pstoresync_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ic "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/peerstore"
+	"github.com/libp2p/go-libp2p/core/record"
+	"github.com/libp2p/go-libp2p/core/test"
+	bhost "github.com/libp2p/go-libp2p/p2p/host/basic"
+	swarmt "github.com/libp2p/go-libp2p/p2p/net/swarm/testing"
+	"github.com/libp2p/go-libp2p/p2p/protocol/pstoresync"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+func newHost(t *testing.T) *bhost.BasicHost {
+	t.Helper()
+	h, err := bhost.NewHost(swarmt.GenSwarm(t, swarmt.OptDisableQUIC, swarmt.OptDisableWebTransport, swarmt.OptDisableWebRTC), nil)
+	require.NoError(t, err)
+	h.Start()
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+func signedRecord(t *testing.T) (peer.ID, *record.Envelope) {
+	t.Helper()
+	priv, _, err := test.RandTestKeyPair(ic.Ed25519, 256)
+	require.NoError(t, err)
+	p, err := peer.IDFromPrivateKey(priv)
+	require.NoError(t, err)
+	rec := peer.PeerRecordFromAddrInfo(peer.AddrInfo{ID: p, Addrs: []ma.Multiaddr{ma.StringCast("/ip4/1.2.3.4/tcp/1")}})
+	env, err := record.Seal(rec, priv)
+	require.NoError(t, err)
+	return p, env
+}
+
+func TestSync(t *testing.T) {
+	h1 := newHost(t)
+	h2 := newHost(t)
+	require.NoError(t, h2.Connect(context.Background(), peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}))
+
+	p, env := signedRecord(t)
+	cab1, _ := peerstore.GetCertifiedAddrBook(h1.Peerstore())
+	_, err := cab1.ConsumePeerRecord(env, time.Hour)
+	require.NoError(t, err)
+	// Plain addresses are not replicated.
+	h1.Peerstore().AddAddr("unsigned", ma.StringCast("/ip4/1.2.3.4/tcp/2"), time.Hour)
+
+	_, err = pstoresync.NewService(h1, pstoresync.WithTrustedPeers(h2.ID()))
+	require.NoError(t, err)
+	s2, err := pstoresync.NewService(h2, pstoresync.WithTrustedPeers(h1.ID()))
+	require.NoError(t, err)
+
+	n, err := s2.Sync(context.Background(), h1.ID())
+	require.NoError(t, err)
+	// h1's own record is synced as well.
+	require.Equal(t, 2, n)
+	cab2, _ := peerstore.GetCertifiedAddrBook(h2.Peerstore())
+	require.True(t, env.Equal(cab2.GetPeerRecord(p)))
+	require.NotNil(t, cab2.GetPeerRecord(h1.ID()))
+	require.Empty(t, h2.Peerstore().Addrs("unsigned"))
+}
+
+func TestSyncUntrusted(t *testing.T) {
+	h1 := newHost(t)
+	h2 := newHost(t)
+	h3 := newHost(t)
+	require.NoError(t, h2.Connect(context.Background(), peer.AddrInfo{ID: h1.ID(), Addrs: h1.Addrs()}))
+
+	_, err := pstoresync.NewService(h1, pstoresync.WithTrustedPeers(h3.ID()))
+	require.NoError(t, err)
+	s2, err := pstoresync.NewService(h2, pstoresync.WithTrustFunc(func(peer.ID) bool { return true }))
+	require.NoError(t, err)
+
+	// h1 doesn't trust h2.
+	_, err = s2.Sync(context.Background(), h1.ID())
+	require.Error(t, err)
+
+	s3, err := pstoresync.NewService(h3, pstoresync.WithTrustedPeers(h1.ID()))
+	require.NoError(t, err)
+	_, err = s3.Sync(context.Background(), h2.ID())
+	require.ErrorIs(t, err, pstoresync.ErrUntrustedPeer)
+
+	_, err = pstoresync.NewService(h3)
+	require.Error(t, err)
+}
@@ -52,7 +52,7 @@ func New(id protocol.ID, privkey crypto.PrivKey, muxers []tptu.StreamMuxer) (*Tr
 // SecureInbound runs the Noise handshake as the responder.
 // If p is empty, connections from any peer are accepted.
 func (t *Transport) SecureInbound(ctx context.Context, insecure net.Conn, p peer.ID) (sec.SecureConn, error) {
-	responderEDH := newTransportEDH(t)
+	responderEDH := newTransportEDH(ctx, t)
 	c, err := newSecureSession(t, ctx, insecure, p, nil, nil, responderEDH, false, p != "")
 	if err != nil {
 		addr, maErr := manet.FromNetAddr(insecure.RemoteAddr())
@@ -65,7 +65,7 @@ func (t *Transport) SecureInbound(ctx context.Context, insecure net.Conn, p peer
 
 // SecureOutbound runs the Noise handshake as the initiator.
 func (t *Transport) SecureOutbound(ctx context.Context, insecure net.Conn, p peer.ID) (sec.SecureConn, error) {
-	initiatorEDH := newTransportEDH(t)
+	initiatorEDH := newTransportEDH(ctx, t)
 	c, err := newSecureSession(t, ctx, insecure, p, nil, initiatorEDH, nil, true, true)
 	if err != nil {
 		return c, err
@@ -99,19 +99,19 @@ func matchMuxers(initiatorMuxers, responderMuxers []protocol.ID) protocol.ID {
 }
 
 type transportEarlyDataHandler struct {
-	transport      *Transport
+	muxers         []protocol.ID // the muxers offered for this connection
 	receivedMuxers []protocol.ID
 }
 
 var _ EarlyDataHandler = &transportEarlyDataHandler{}
 
-func newTransportEDH(t *Transport) *transportEarlyDataHandler {
-	return &transportEarlyDataHandler{transport: t}
+func newTransportEDH(ctx context.Context, t *Transport) *transportEarlyDataHandler {
+	return &transportEarlyDataHandler{muxers: tptu.GetEarlyMuxers(ctx, t.muxers)}
 }
 
 func (i *transportEarlyDataHandler) Send(context.Context, net.Conn, peer.ID) *pb.NoiseExtensions {
 	return &pb.NoiseExtensions{
-		StreamMuxers: protocol.ConvertToStrings(i.transport.muxers),
+		StreamMuxers: protocol.ConvertToStrings(i.muxers),
 	}
 }
 
@@ -125,7 +125,7 @@ func (i *transportEarlyDataHandler) Received(_ context.Context, _ net.Conn, exte
 
 func (i *transportEarlyDataHandler) MatchMuxers(isInitiator bool) protocol.ID {
 	if isInitiator {
-		return matchMuxers(i.transport.muxers, i.receivedMuxers)
+		return matchMuxers(i.muxers, i.receivedMuxers)
 	}
-	return matchMuxers(i.receivedMuxers, i.transport.muxers)
+	return matchMuxers(i.receivedMuxers, i.muxers)
 }
@@ -64,10 +64,7 @@ func New(id protocol.ID, key ci.PrivKey, muxers []tptu.StreamMuxer) (*Transport,
 // If p is empty, connections from any peer are accepted.
 func (t *Transport) SecureInbound(ctx context.Context, insecure net.Conn, p peer.ID) (sec.SecureConn, error) {
 	config, keyCh := t.identity.ConfigForPeer(p)
-	muxers := make([]string, 0, len(t.muxers))
-	for _, muxer := range t.muxers {
-		muxers = append(muxers, string(muxer))
-	}
+	muxers := protocol.ConvertToStrings(tptu.GetEarlyMuxers(ctx, t.muxers))
 	// TLS' ALPN selection lets the server select the protocol, preferring the server's preferences.
 	// We want to prefer the client's preference though.
 	getConfigForClient := config.GetConfigForClient
@@ -109,10 +106,7 @@ func (t *Transport) SecureInbound(ctx context.Context, insecure net.Conn, p peer
 // notice this after 1 RTT when calling Read.
 func (t *Transport) SecureOutbound(ctx context.Context, insecure net.Conn, p peer.ID) (sec.SecureConn, error) {
 	config, keyCh := t.identity.ConfigForPeer(p)
-	muxers := make([]string, 0, len(t.muxers))
-	for _, muxer := range t.muxers {
-		muxers = append(muxers, (string)(muxer))
-	}
+	muxers := protocol.ConvertToStrings(tptu.GetEarlyMuxers(ctx, t.muxers))
 	// Prepend the preferred muxers list to TLS config.
 	config.NextProtos = append(muxers, config.NextProtos...)
 	cs, err := t.handshake(ctx, tls.Client(insecure, config), keyCh)
@@ -8,10 +8,12 @@ import (
 	"time"
 
 	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/peerstore"
 	rcmgr "github.com/libp2p/go-libp2p/p2p/host/resource-manager"
+	"github.com/libp2p/go-libp2p/p2p/net/swarm"
 	"github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/client"
 	"github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/relay"
 	ma "github.com/multiformats/go-multiaddr"
@@ -243,3 +245,77 @@ func TestLimitStreamsWhenHangingHandlers(t *testing.T) {
 		return false
 	}, 5*time.Second, 100*time.Millisecond)
 }
+
+func TestRelayCutover(t *testing.T) {
+	h1, err := libp2p.New(
+		libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"),
+		libp2p.EnableRelay(),
+		libp2p.SwarmOpts(swarm.WithRelayCutover(100*time.Millisecond)),
+	)
+	require.NoError(t, err)
+	defer h1.Close()
+
+	h2, err := libp2p.New(
+		libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"),
+		libp2p.EnableRelay(),
+	)
+	require.NoError(t, err)
+	defer h2.Close()
+	h2.SetStreamHandler("/test", func(s network.Stream) { io.Copy(io.Discard, s) })
+
+	relay1, err := libp2p.New(libp2p.ListenAddrStrings("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer relay1.Close()
+
+	_, err = relay.New(relay1)
+	require.NoError(t, err)
+
+	relay1info := peer.AddrInfo{
+		ID:    relay1.ID(),
+		Addrs: relay1.Addrs(),
+	}
+	require.NoError(t, h1.Connect(context.Background(), relay1info))
+	require.NoError(t, h2.Connect(context.Background(), relay1info))
+	_, err = client.Reserve(context.Background(), h2, relay1info)
+	require.NoError(t, err)
+
+	sub, err := h1.EventBus().Subscribe(new(event.EvtRelayedConnCutover))
+	require.NoError(t, err)
+	defer sub.Close()
+
+	relayaddr := ma.StringCast("/p2p/" + relay1info.ID.String() + "/p2p-circuit/p2p/" + h2.ID().String())
+	h1.Peerstore().AddAddr(h2.ID(), relayaddr, peerstore.TempAddrTTL)
+	relayed, err := h1.Network().DialPeer(context.Background(), h2.ID())
+	require.NoError(t, err)
+	require.True(t, relayed.Stat().Limited)
+	str, err := h1.NewStream(network.WithAllowLimitedConn(context.Background(), "test"), h2.ID(), "/test")
+	require.NoError(t, err)
+	require.Equal(t, relayed, str.Conn())
+
+	// establish a direct connection
+	h1.Peerstore().AddAddrs(h2.ID(), h2.Addrs(), peerstore.TempAddrTTL)
+	direct, err := h1.Network().DialPeer(network.WithForceDirectDial(context.Background(), "test"), h2.ID())
+	require.NoError(t, err)
+	require.NotEqual(t, relayed, direct)
+
+	// the relayed connection is kept as long as it has open streams
+	select {
+	case <-sub.Out():
+		t.Fatal("relayed connection with open streams shouldn't be closed")
+	case <-time.After(300 * time.Millisecond):
+	}
+	require.False(t, relayed.IsClosed())
+
+	str.Reset()
+	select {
+	case e := <-sub.Out():
+		evt := e.(event.EvtRelayedConnCutover)
+		require.Equal(t, h2.ID(), evt.Peer)
+		require.Equal(t, relayed, evt.RelayedConn)
+		require.Equal(t, direct, evt.DirectConn)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the relayed connection to be closed")
+	}
+	require.True(t, relayed.IsClosed())
+	require.Equal(t, []network.Conn{direct}, h1.Network().ConnsToPeer(h2.ID()))
+}
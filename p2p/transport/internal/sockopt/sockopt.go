@@ -0,0 +1,83 @@
+// Package sockopt sets platform specific options on the sockets used by the
+// transports.
+package sockopt
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"time"
+)
+
+// ErrUnsupported is returned when a socket option is not supported on the
+// current platform.
+var ErrUnsupported = errors.New("socket option not supported on this platform")
+
+// MarkControl returns a function that can be used as the Control function of
+// a net.Dialer or net.ListenConfig. It sets SO_MARK on the socket before it
+// is connected or bound.
+func MarkControl(mark int) func(network, address string, c syscall.RawConn) error {
+	return func(_, _ string, c syscall.RawConn) error {
+		return SetMark(c, mark)
+	}
+}
+
+// UserTimeoutControl returns a function that can be used as the Control
+// function of a net.Dialer. It sets TCP_USER_TIMEOUT on the socket before it
+// is connected.
+func UserTimeoutControl(timeout time.Duration) func(network, address string, c syscall.RawConn) error {
+	return func(_, _ string, c syscall.RawConn) error {
+		return SetUserTimeout(c, timeout)
+	}
+}
+
+// BindToDeviceControl returns a function that can be used as the Control
+// function of a net.Dialer or net.ListenConfig. It binds the socket to the
+// network interface iface before it is connected or bound.
+func BindToDeviceControl(iface string) func(network, address string, c syscall.RawConn) error {
+	return func(_, _ string, c syscall.RawConn) error {
+		return BindToDevice(c, iface)
+	}
+}
+
+// ChainControl returns a Control function that runs all non-nil functions in
+// order, stopping at the first error.
+func ChainControl(fns ...func(network, address string, c syscall.RawConn) error) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		for _, fn := range fns {
+			if fn == nil {
+				continue
+			}
+			if err := fn(network, address, c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// SetConnUserTimeout sets TCP_USER_TIMEOUT on an already connected socket.
+func SetConnUserTimeout(conn net.Conn, timeout time.Duration) error {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return ErrUnsupported
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	return SetUserTimeout(rc, timeout)
+}
+
+// SetConnMark sets SO_MARK on an already created socket.
+func SetConnMark(conn net.PacketConn, mark int) error {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return ErrUnsupported
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	return SetMark(rc, mark)
+}
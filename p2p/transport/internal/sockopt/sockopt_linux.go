@@ -0,0 +1,210 @@
+//go:build linux
+
+package sockopt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// MarkSupported is true if SO_MARK is supported on this platform.
+const MarkSupported = true
+
+// SetMark sets SO_MARK on the socket. Setting a mark requires the
+// CAP_NET_ADMIN capability.
+func SetMark(c syscall.RawConn, mark int) error {
+	var serr error
+	if err := c.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_MARK, mark)
+	}); err != nil {
+		return err
+	}
+	return serr
+}
+
+const (
+	solMPTCP  = 284 // SOL_MPTCP
+	mptcpInfo = 1   // MPTCP_INFO
+)
+
+// MPTCPSubflows returns the number of additional subflows of a Multipath TCP
+// socket, as reported by MPTCP_INFO. The initial subflow is not counted.
+func MPTCPSubflows(c syscall.RawConn) (int, error) {
+	var (
+		v    int
+		serr error
+	)
+	if err := c.Control(func(fd uintptr) {
+		// mptcpi_subflows is the first byte of struct mptcp_info. The kernel
+		// copies as much of the struct as fits into the buffer.
+		v, serr = syscall.GetsockoptInt(int(fd), solMPTCP, mptcpInfo)
+	}); err != nil {
+		return 0, err
+	}
+	if serr != nil {
+		return 0, serr
+	}
+	var b [4]byte
+	binary.NativeEndian.PutUint32(b[:], uint32(v))
+	return int(b[0]), nil
+}
+
+const tcpUserTimeout = 0x12 // TCP_USER_TIMEOUT
+
+// UserTimeoutSupported is true if TCP_USER_TIMEOUT is supported on this
+// platform.
+const UserTimeoutSupported = true
+
+// SetUserTimeout sets TCP_USER_TIMEOUT on the socket: the connection is
+// closed if transmitted data remains unacknowledged for longer than timeout.
+func SetUserTimeout(c syscall.RawConn, timeout time.Duration) error {
+	var serr error
+	if err := c.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, tcpUserTimeout, int(timeout.Milliseconds()))
+	}); err != nil {
+		return err
+	}
+	return serr
+}
+
+// BindToDeviceSupported is true if SO_BINDTODEVICE is supported on this
+// platform.
+const BindToDeviceSupported = true
+
+// BindToDevice binds the socket to the network interface iface using
+// SO_BINDTODEVICE, so that its packets are only sent and received on that
+// interface. This may require the CAP_NET_RAW capability.
+func BindToDevice(c syscall.RawConn, iface string) error {
+	var serr error
+	if err := c.Control(func(fd uintptr) {
+		serr = syscall.BindToDevice(int(fd), iface)
+	}); err != nil {
+		return err
+	}
+	return serr
+}
+
+// BufferSizes returns the receive and send buffer sizes of the socket, as
+// reported by SO_RCVBUF and SO_SNDBUF. Note that Linux reports twice the
+// size that was requested, to account for bookkeeping overhead.
+func BufferSizes(c syscall.RawConn) (receive, send int, err error) {
+	var serr error
+	if err := c.Control(func(fd uintptr) {
+		receive, serr = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_RCVBUF)
+		if serr != nil {
+			return
+		}
+		send, serr = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_SNDBUF)
+	}); err != nil {
+		return 0, 0, err
+	}
+	return receive, send, serr
+}
+
+// ForceReceiveBuffer sets the receive buffer size using SO_RCVBUFFORCE,
+// ignoring the net.core.rmem_max limit. This requires the CAP_NET_ADMIN
+// capability.
+func ForceReceiveBuffer(c syscall.RawConn, size int) error {
+	var serr error
+	if err := c.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_RCVBUFFORCE, size)
+	}); err != nil {
+		return err
+	}
+	return serr
+}
+
+// ForceSendBuffer sets the send buffer size using SO_SNDBUFFORCE, ignoring
+// the net.core.wmem_max limit. This requires the CAP_NET_ADMIN capability.
+func ForceSendBuffer(c syscall.RawConn, size int) error {
+	var serr error
+	if err := c.Control(func(fd uintptr) {
+		serr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_SNDBUFFORCE, size)
+	}); err != nil {
+		return err
+	}
+	return serr
+}
+
+// UDPReceiveDrops returns the number of packets the kernel dropped on a UDP
+// socket, most commonly because its receive buffer was full. It is read from
+// /proc/net/udp and /proc/net/udp6. To look up many sockets, use
+// SocketInode and UDPReceiveDropsByInode instead.
+func UDPReceiveDrops(c syscall.RawConn) (uint64, error) {
+	inode, err := SocketInode(c)
+	if err != nil {
+		return 0, err
+	}
+	drops, err := UDPReceiveDropsByInode()
+	if err != nil {
+		return 0, err
+	}
+	d, ok := drops[inode]
+	if !ok {
+		return 0, errors.New("socket not found in /proc/net/udp")
+	}
+	return d, nil
+}
+
+// SocketInode returns the inode number of the socket.
+func SocketInode(c syscall.RawConn) (uint64, error) {
+	var (
+		st   syscall.Stat_t
+		serr error
+	)
+	if err := c.Control(func(fd uintptr) {
+		serr = syscall.Fstat(int(fd), &st)
+	}); err != nil {
+		return 0, err
+	}
+	if serr != nil {
+		return 0, serr
+	}
+	return st.Ino, nil
+}
+
+// UDPReceiveDropsByInode returns the number of packets the kernel dropped on
+// each UDP socket of this network namespace, keyed by the socket's inode
+// number. It is read from /proc/net/udp and /proc/net/udp6.
+func UDPReceiveDropsByInode() (map[uint64]uint64, error) {
+	drops := make(map[uint64]uint64)
+	for _, f := range []string{"/proc/net/udp", "/proc/net/udp6"} {
+		if err := readUDPDrops(f, drops); err != nil {
+			return nil, err
+		}
+	}
+	return drops, nil
+}
+
+func readUDPDrops(file string, drops map[uint64]uint64) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	s.Scan() // skip the header
+	for s.Scan() {
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode ref pointer drops
+		fields := strings.Fields(s.Text())
+		if len(fields) < 13 {
+			continue
+		}
+		inode, err := strconv.ParseUint(fields[9], 10, 64)
+		if err != nil {
+			continue
+		}
+		d, err := strconv.ParseUint(fields[12], 10, 64)
+		if err != nil {
+			return err
+		}
+		drops[inode] = d
+	}
+	return s.Err()
+}
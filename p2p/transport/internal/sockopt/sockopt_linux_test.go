@@ -0,0 +1,126 @@
+package sockopt
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetConnMark(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	if err := SetConnMark(conn, 42); err != nil {
+		if errors.Is(err, syscall.EPERM) {
+			t.Skip("setting SO_MARK requires CAP_NET_ADMIN")
+		}
+		t.Fatal(err)
+	}
+
+	rc, err := conn.SyscallConn()
+	require.NoError(t, err)
+	var mark int
+	var serr error
+	require.NoError(t, rc.Control(func(fd uintptr) {
+		mark, serr = syscall.GetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_MARK)
+	}))
+	require.NoError(t, serr)
+	require.Equal(t, 42, mark)
+}
+
+func TestMPTCPSubflows(t *testing.T) {
+	var lc net.ListenConfig
+	lc.SetMultipathTCP(true)
+	ln, err := lc.Listen(context.Background(), "tcp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	var d net.Dialer
+	d.SetMultipathTCP(true)
+	conn, err := d.Dial("tcp4", ln.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	if used, err := conn.(*net.TCPConn).MultipathTCP(); err != nil || !used {
+		t.Skip("Multipath TCP not available")
+	}
+
+	rc, err := conn.(*net.TCPConn).SyscallConn()
+	require.NoError(t, err)
+	n, err := MPTCPSubflows(rc)
+	require.NoError(t, err)
+	// No additional subflows are established on loopback.
+	require.Zero(t, n)
+}
+
+func TestSetConnUserTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	conn, err := net.Dial("tcp4", ln.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, SetConnUserTimeout(conn, 1500*time.Millisecond))
+
+	rc, err := conn.(*net.TCPConn).SyscallConn()
+	require.NoError(t, err)
+	var timeout int
+	var serr error
+	require.NoError(t, rc.Control(func(fd uintptr) {
+		timeout, serr = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_TCP, tcpUserTimeout)
+	}))
+	require.NoError(t, serr)
+	require.Equal(t, 1500, timeout)
+}
+
+func TestBufferSizes(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetReadBuffer(64<<10))
+	require.NoError(t, conn.SetWriteBuffer(32<<10))
+
+	rc, err := conn.SyscallConn()
+	require.NoError(t, err)
+	receive, send, err := BufferSizes(rc)
+	require.NoError(t, err)
+	// Linux doubles the requested size.
+	require.Equal(t, 128<<10, receive)
+	require.Equal(t, 64<<10, send)
+}
+
+func TestUDPReceiveDrops(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetReadBuffer(1)) // the kernel enforces a minimum size
+
+	rc, err := conn.SyscallConn()
+	require.NoError(t, err)
+	drops, err := UDPReceiveDrops(rc)
+	require.NoError(t, err)
+	require.Zero(t, drops)
+
+	sender, err := net.DialUDP("udp4", nil, conn.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	defer sender.Close()
+	for range 100 {
+		_, err := sender.Write(make([]byte, 1000))
+		require.NoError(t, err)
+	}
+	drops, err = UDPReceiveDrops(rc)
+	require.NoError(t, err)
+	require.NotZero(t, drops)
+
+	inode, err := SocketInode(rc)
+	require.NoError(t, err)
+	all, err := UDPReceiveDropsByInode()
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, all[inode], drops)
+}
@@ -0,0 +1,79 @@
+//go:build !linux
+
+package sockopt
+
+import (
+	"syscall"
+	"time"
+)
+
+// MarkSupported is true if SO_MARK is supported on this platform.
+const MarkSupported = false
+
+// SetMark sets SO_MARK on the socket. It is only supported on Linux.
+func SetMark(_ syscall.RawConn, _ int) error {
+	return ErrUnsupported
+}
+
+// MPTCPSubflows returns the number of additional subflows of a Multipath TCP
+// socket. It is only supported on Linux.
+func MPTCPSubflows(_ syscall.RawConn) (int, error) {
+	return 0, ErrUnsupported
+}
+
+// UserTimeoutSupported is true if TCP_USER_TIMEOUT is supported on this
+// platform.
+const UserTimeoutSupported = false
+
+// SetUserTimeout sets TCP_USER_TIMEOUT on the socket. It is only supported on
+// Linux.
+func SetUserTimeout(_ syscall.RawConn, _ time.Duration) error {
+	return ErrUnsupported
+}
+
+// BindToDeviceSupported is true if SO_BINDTODEVICE is supported on this
+// platform.
+const BindToDeviceSupported = false
+
+// BindToDevice binds the socket to a network interface. It is only supported
+// on Linux.
+func BindToDevice(_ syscall.RawConn, _ string) error {
+	return ErrUnsupported
+}
+
+// BufferSizes returns the receive and send buffer sizes of the socket. It is
+// only supported on Linux.
+func BufferSizes(_ syscall.RawConn) (receive, send int, err error) {
+	return 0, 0, ErrUnsupported
+}
+
+// ForceReceiveBuffer sets the receive buffer size, ignoring the system limit.
+// It is only supported on Linux.
+func ForceReceiveBuffer(_ syscall.RawConn, _ int) error {
+	return ErrUnsupported
+}
+
+// ForceSendBuffer sets the send buffer size, ignoring the system limit. It is
+// only supported on Linux.
+func ForceSendBuffer(_ syscall.RawConn, _ int) error {
+	return ErrUnsupported
+}
+
+// UDPReceiveDrops returns the number of packets the kernel dropped on a UDP
+// socket. It is only supported on Linux.
+func UDPReceiveDrops(_ syscall.RawConn) (uint64, error) {
+	return 0, ErrUnsupported
+}
+
+// SocketInode returns the inode number of the socket. It is only supported on
+// Linux.
+func SocketInode(_ syscall.RawConn) (uint64, error) {
+	return 0, ErrUnsupported
+}
+
+// UDPReceiveDropsByInode returns the number of packets the kernel dropped on
+// each UDP socket, keyed by the socket's inode number. It is only supported
+// on Linux.
+func UDPReceiveDropsByInode() (map[uint64]uint64, error) {
+	return nil, ErrUnsupported
+}
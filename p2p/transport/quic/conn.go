@@ -2,6 +2,7 @@ package libp2pquic
 
 import (
 	"context"
+	"time"
 
 	ic "github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/network"
@@ -23,9 +24,25 @@ type conn struct {
 	remotePeerID    peer.ID
 	remotePubKey    ic.PubKey
 	remoteMultiaddr ma.Multiaddr
+
+	// connectTime is the duration of the QUIC handshake. It's only set for
+	// outbound connections.
+	connectTime time.Duration
 }
 
 var _ tpt.CapableConn = &conn{}
+var _ network.DatagramConn = &conn{}
+var _ network.ConnStat = &conn{}
+var _ network.ConnRTT = &conn{}
+
+type statECN struct{}
+
+// StatECN is the key in network.ConnStats.Extra under which the transport
+// reports the ECN counters of a connection. The value is a
+// *quicreuse.ECNCounters that is updated for the lifetime of the connection.
+// It is only set if the quicreuse.ConnManager counts ECN codepoints, see
+// quicreuse.EnableECNCounters.
+var StatECN = statECN{}
 
 // Close closes the connection.
 // It must be called even if the peer closed the connection in order for
@@ -102,3 +119,42 @@ func (c *conn) ConnState() network.ConnectionState {
 	}
 	return network.ConnectionState{Transport: t}
 }
+
+// Stat returns the transport-specific stats of the connection.
+func (c *conn) Stat() network.ConnStats {
+	var stat network.ConnStats
+	stat.Handshake.Connect = c.connectTime
+	stat.SmoothedRTT = c.SmoothedRTT()
+	if ecn := c.transport.connManager.ECNCounters(c.quicConn); ecn != nil {
+		stat.Extra = map[interface{}]interface{}{StatECN: ecn}
+	}
+	return stat
+}
+
+// SmoothedRTT returns the smoothed RTT estimated by QUIC's loss recovery. It
+// returns zero unless the ConnManager was constructed with
+// quicreuse.EnableRTTMeasurement.
+func (c *conn) SmoothedRTT() time.Duration {
+	return c.transport.connManager.SmoothedRTT(c.quicConn)
+}
+
+// SupportsDatagrams returns true if the peer supports QUIC datagrams.
+func (c *conn) SupportsDatagrams() bool {
+	return c.quicConn.ConnectionState().SupportsDatagrams
+}
+
+// SendDatagram sends an unreliable datagram.
+func (c *conn) SendDatagram(b []byte) error {
+	if !c.SupportsDatagrams() {
+		return network.ErrDatagramsNotSupported
+	}
+	return c.quicConn.SendDatagram(b)
+}
+
+// ReceiveDatagram receives an unreliable datagram.
+func (c *conn) ReceiveDatagram(ctx context.Context) ([]byte, error) {
+	if !c.SupportsDatagrams() {
+		return nil, network.ErrDatagramsNotSupported
+	}
+	return c.quicConn.ReceiveDatagram(ctx)
+}
@@ -307,6 +307,49 @@ func testStreams(t *testing.T, tc *connTestCase) {
 	require.Equal(t, data, []byte("foobar"))
 }
 
+func TestDatagrams(t *testing.T) {
+	serverID, serverKey := createPeer(t)
+	_, clientKey := createPeer(t)
+
+	serverTransport, err := NewTransport(serverKey, newConnManager(t), nil, nil, nil)
+	require.NoError(t, err)
+	defer serverTransport.(io.Closer).Close()
+	ln := runServer(t, serverTransport, "/ip4/127.0.0.1/udp/0/quic-v1")
+	defer ln.Close()
+
+	clientTransport, err := NewTransport(clientKey, newConnManager(t), nil, nil, nil)
+	require.NoError(t, err)
+	defer clientTransport.(io.Closer).Close()
+	conn, err := clientTransport.Dial(context.Background(), ln.Multiaddr(), serverID)
+	require.NoError(t, err)
+	defer conn.Close()
+	serverConn, err := ln.Accept()
+	require.NoError(t, err)
+	defer serverConn.Close()
+
+	clientDC, ok := conn.(network.DatagramConn)
+	require.True(t, ok)
+	serverDC, ok := serverConn.(network.DatagramConn)
+	require.True(t, ok)
+	require.True(t, clientDC.SupportsDatagrams())
+	require.True(t, serverDC.SupportsDatagrams())
+
+	// Datagrams are unreliable. Resend until one arrives.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go func() {
+		for ctx.Err() == nil {
+			if err := clientDC.SendDatagram([]byte("foobar")); err != nil {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	data, err := serverDC.ReceiveDatagram(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []byte("foobar"), data)
+}
+
 func testStreamsErrorCode(t *testing.T, tc *connTestCase) {
 	serverID, serverKey := createPeer(t)
 	_, clientKey := createPeer(t)
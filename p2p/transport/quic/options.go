@@ -0,0 +1,21 @@
+package libp2pquic
+
+import (
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/transport/quicreuse"
+
+	ma "github.com/multiformats/go-multiaddr"
+)
+
+type Option func(*transport) error
+
+// WithTransportParameters sets a function that overrides the QUIC transport
+// parameters (e.g. the number of streams and the flow control windows) of
+// outgoing connections, given the peer and the address dialed. This allows
+// granting trusted peers larger windows than unknown peers.
+func WithTransportParameters(f func(p peer.ID, raddr ma.Multiaddr) quicreuse.TransportParameters) Option {
+	return func(t *transport) error {
+		t.transportParameters = f
+		return nil
+	}
+}
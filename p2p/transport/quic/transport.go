@@ -43,6 +43,8 @@ type transport struct {
 	gater       connmgr.ConnectionGater
 	rcmgr       network.ResourceManager
 
+	transportParameters func(peer.ID, ma.Multiaddr) quicreuse.TransportParameters
+
 	holePunchingMx sync.Mutex
 	holePunching   map[holePunchKey]*activeHolePunch
 
@@ -58,6 +60,7 @@ type transport struct {
 }
 
 var _ tpt.Transport = &transport{}
+var _ tpt.CapabilityProvider = &transport{}
 
 type holePunchKey struct {
 	addr string
@@ -70,7 +73,7 @@ type activeHolePunch struct {
 }
 
 // NewTransport creates a new QUIC transport
-func NewTransport(key ic.PrivKey, connManager *quicreuse.ConnManager, psk pnet.PSK, gater connmgr.ConnectionGater, rcmgr network.ResourceManager) (tpt.Transport, error) {
+func NewTransport(key ic.PrivKey, connManager *quicreuse.ConnManager, psk pnet.PSK, gater connmgr.ConnectionGater, rcmgr network.ResourceManager, opts ...Option) (tpt.Transport, error) {
 	if len(psk) > 0 {
 		log.Error("QUIC doesn't support private networks yet.")
 		return nil, errors.New("QUIC doesn't support private networks yet")
@@ -88,7 +91,7 @@ func NewTransport(key ic.PrivKey, connManager *quicreuse.ConnManager, psk pnet.P
 		rcmgr = &network.NullResourceManager{}
 	}
 
-	return &transport{
+	t := &transport{
 		privKey:      key,
 		localPeer:    localPeer,
 		identity:     identity,
@@ -100,7 +103,13 @@ func NewTransport(key ic.PrivKey, connManager *quicreuse.ConnManager, psk pnet.P
 		rnd:          *rand.New(rand.NewSource(time.Now().UnixNano())),
 
 		listeners: make(map[string][]*virtualListener),
-	}, nil
+	}
+	for _, o := range opts {
+		if err := o(t); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
 }
 
 func (t *transport) ListenOrder() int {
@@ -135,10 +144,15 @@ func (t *transport) dialWithScope(ctx context.Context, raddr ma.Multiaddr, p pee
 
 	tlsConf, keyCh := t.identity.ConfigForPeer(p)
 	ctx = quicreuse.WithAssociation(ctx, t)
+	if t.transportParameters != nil {
+		ctx = quicreuse.WithTransportParameters(ctx, t.transportParameters(p, raddr))
+	}
+	start := time.Now()
 	pconn, err := t.connManager.DialQUIC(ctx, raddr, tlsConf, t.allowWindowIncrease)
 	if err != nil {
 		return nil, err
 	}
+	connectTime := time.Since(start)
 
 	// Should be ready by this point, don't block.
 	var remotePubKey ic.PubKey
@@ -165,6 +179,7 @@ func (t *transport) dialWithScope(ctx context.Context, raddr ma.Multiaddr, p pee
 		remotePubKey:    remotePubKey,
 		remotePeerID:    p,
 		remoteMultiaddr: raddr,
+		connectTime:     connectTime,
 	}
 	if t.gater != nil && !t.gater.InterceptSecured(network.DirOutbound, p, c) {
 		pconn.CloseWithError(quic.ApplicationErrorCode(network.ConnGated), "connection gated")
@@ -363,6 +378,11 @@ func (t *transport) Proxy() bool {
 	return false
 }
 
+// Capabilities returns the capabilities of the transport.
+func (t *transport) Capabilities() tpt.Capabilities {
+	return tpt.CapabilityHolePunching | tpt.CapabilityDatagrams
+}
+
 // Protocols returns the set of protocols handled by this transport.
 func (t *transport) Protocols() []int {
 	return t.connManager.Protocols()
@@ -1,14 +1,18 @@
 package libp2pquic
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"io"
 	"testing"
+	"time"
 
 	ic "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
 	tpt "github.com/libp2p/go-libp2p/core/transport"
+	"github.com/libp2p/go-libp2p/p2p/transport/quicreuse"
 
 	ma "github.com/multiformats/go-multiaddr"
 	"github.com/stretchr/testify/require"
@@ -67,3 +71,37 @@ func TestCanDial(t *testing.T) {
 		}
 	}
 }
+
+func TestTransportParametersCallback(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	key, err := ic.UnmarshalRsaPrivateKey(x509.MarshalPKCS1PrivateKey(rsaKey))
+	require.NoError(t, err)
+
+	type call struct {
+		p     peer.ID
+		raddr ma.Multiaddr
+	}
+	calls := make(chan call, 1)
+	tr, err := NewTransport(key, newConnManager(t), nil, nil, nil, WithTransportParameters(func(p peer.ID, raddr ma.Multiaddr) quicreuse.TransportParameters {
+		calls <- call{p: p, raddr: raddr}
+		return quicreuse.TransportParameters{MaxIncomingStreams: 1000}
+	}))
+	require.NoError(t, err)
+	defer tr.(io.Closer).Close()
+
+	// Nobody is listening on this address, so the dial times out.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	raddr := ma.StringCast("/ip4/127.0.0.1/udp/1/quic-v1")
+	p := peer.ID("peer")
+	_, err = tr.Dial(ctx, raddr, p)
+	require.Error(t, err)
+	select {
+	case c := <-calls:
+		require.Equal(t, p, c.p)
+		require.Equal(t, raddr, c.raddr)
+	default:
+		t.Fatal("expected the transport parameters callback to be called")
+	}
+}
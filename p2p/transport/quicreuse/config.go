@@ -16,3 +16,45 @@ var quicConfig = &quic.Config{
 	// We don't use datagrams (yet), but this is necessary for WebTransport
 	EnableDatagrams: true,
 }
+
+// TransportParameters overrides QUIC transport parameters of a connection.
+// Zero values keep the defaults.
+type TransportParameters struct {
+	// MaxIncomingStreams is the maximum number of concurrent bidirectional
+	// streams the peer may open.
+	MaxIncomingStreams int64
+	// MaxIncomingUniStreams is the maximum number of concurrent unidirectional
+	// streams the peer may open.
+	MaxIncomingUniStreams int64
+	// InitialStreamReceiveWindow and MaxStreamReceiveWindow are the initial and
+	// maximum flow control windows of a stream.
+	InitialStreamReceiveWindow uint64
+	MaxStreamReceiveWindow     uint64
+	// InitialConnectionReceiveWindow and MaxConnectionReceiveWindow are the
+	// initial and maximum flow control windows of the connection.
+	// Increases of the connection window still need to be allowed by the
+	// transport, e.g. by reserving memory with the resource manager.
+	InitialConnectionReceiveWindow uint64
+	MaxConnectionReceiveWindow     uint64
+}
+
+func (p *TransportParameters) apply(conf *quic.Config) {
+	if p.MaxIncomingStreams != 0 {
+		conf.MaxIncomingStreams = p.MaxIncomingStreams
+	}
+	if p.MaxIncomingUniStreams != 0 {
+		conf.MaxIncomingUniStreams = p.MaxIncomingUniStreams
+	}
+	if p.InitialStreamReceiveWindow != 0 {
+		conf.InitialStreamReceiveWindow = p.InitialStreamReceiveWindow
+	}
+	if p.MaxStreamReceiveWindow != 0 {
+		conf.MaxStreamReceiveWindow = p.MaxStreamReceiveWindow
+	}
+	if p.InitialConnectionReceiveWindow != 0 {
+		conf.InitialConnectionReceiveWindow = p.InitialConnectionReceiveWindow
+	}
+	if p.MaxConnectionReceiveWindow != 0 {
+		conf.MaxConnectionReceiveWindow = p.MaxConnectionReceiveWindow
+	}
+}
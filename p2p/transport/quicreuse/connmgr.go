@@ -12,7 +12,9 @@ import (
 	"io"
 	"net"
 	"sync"
+	"time"
 
+	"github.com/libp2p/go-libp2p/p2p/transport/internal/sockopt"
 	"github.com/libp2p/go-netroute"
 	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr/net"
@@ -52,10 +54,22 @@ type ConnManager struct {
 	enableReuseport bool
 
 	listenUDP          listenUDP
+	socketMark         *int
+	maxReceiveBuffer   int
+	maxSendBuffer      int
+	udpSockets         *udpSockets
+	udpCollector       *udpSocketCollector
+	adjustDone         chan struct{}
+	closeOnce          sync.Once
 	sourceIPSelectorFn func() (SourceIPSelector, error)
 
 	enableMetrics bool
 	registerer    prometheus.Registerer
+	countECN      bool
+	ecn           *ecnTracker
+	measureRTT    bool
+	rtt           *rttTracker
+	qlog          qlogConfig
 
 	serverConfig *quic.Config
 	clientConfig *quic.Config
@@ -79,6 +93,21 @@ func defaultListenUDP(network string, laddr *net.UDPAddr) (net.PacketConn, error
 	return net.ListenUDP(network, laddr)
 }
 
+// markedListenUDP wraps listen, setting SO_MARK on the sockets it creates.
+func markedListenUDP(listen listenUDP, mark int) listenUDP {
+	return func(network string, laddr *net.UDPAddr) (net.PacketConn, error) {
+		conn, err := listen(network, laddr)
+		if err != nil {
+			return nil, err
+		}
+		if err := sockopt.SetConnMark(conn, mark); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to set SO_MARK: %w", err)
+		}
+		return conn, nil
+	}
+}
+
 func defaultSourceIPSelectorFn() (SourceIPSelector, error) {
 	r, err := netroute.New()
 	return &netrouteSourceIPSelector{routes: r}, err
@@ -99,6 +128,8 @@ func NewConnManager(statelessResetKey quic.StatelessResetKey, tokenKey quic.Toke
 		registerer:         prometheus.DefaultRegisterer,
 		listenUDP:          defaultListenUDP,
 		sourceIPSelectorFn: defaultSourceIPSelectorFn,
+		qlog:               qlogConfig{sampleRate: 1},
+		udpSockets:         newUDPSockets(),
 	}
 	for _, o := range opts {
 		if err := o(cm); err != nil {
@@ -106,6 +137,27 @@ func NewConnManager(statelessResetKey quic.StatelessResetKey, tokenKey quic.Toke
 		}
 	}
 
+	if cm.socketMark != nil {
+		cm.listenUDP = markedListenUDP(cm.listenUDP, *cm.socketMark)
+	}
+	// The sockets are only tracked if something reads their state.
+	if cm.maxReceiveBuffer > 0 || cm.maxSendBuffer > 0 || cm.enableMetrics {
+		cm.listenUDP = trackedListenUDP(cm.listenUDP, cm.udpSockets, cm.maxReceiveBuffer, cm.maxSendBuffer)
+	}
+	if cm.enableMetrics {
+		cm.udpCollector = newUDPSocketCollector(cm.registerer, cm.udpSockets)
+	}
+	if cm.maxReceiveBuffer > 0 {
+		cm.adjustDone = make(chan struct{})
+		go cm.udpSockets.adjustReceiveBuffers(cm.maxReceiveBuffer, cm.adjustDone)
+	}
+
+	if cm.qlog.newWriter == nil && qlogTracerDir != "" {
+		cm.qlog.newWriter = newQlogDirWriter(qlogTracerDir)
+	}
+	cm.ecn = newECNTracker(cm.enableMetrics, cm.registerer)
+	cm.rtt = newRTTTracker()
+
 	quicConf := quicConfig.Clone()
 	quicConf.Tracer = cm.getTracer()
 	serverConfig := quicConf.Clone()
@@ -135,30 +187,63 @@ func NewConnManager(statelessResetKey quic.StatelessResetKey, tokenKey quic.Toke
 }
 
 func (c *ConnManager) getTracer() func(context.Context, quiclogging.Perspective, quic.ConnectionID) *quiclogging.ConnectionTracer {
-	return func(_ context.Context, p quiclogging.Perspective, ci quic.ConnectionID) *quiclogging.ConnectionTracer {
-		var promTracer *quiclogging.ConnectionTracer
+	return func(ctx context.Context, p quiclogging.Perspective, ci quic.ConnectionID) *quiclogging.ConnectionTracer {
+		var tracers []*quiclogging.ConnectionTracer
 		if c.enableMetrics {
 			switch p {
 			case quiclogging.PerspectiveClient:
-				promTracer = quicmetrics.NewClientConnectionTracerWithRegisterer(c.registerer)
+				tracers = append(tracers, quicmetrics.NewClientConnectionTracerWithRegisterer(c.registerer))
 			case quiclogging.PerspectiveServer:
-				promTracer = quicmetrics.NewServerConnectionTracerWithRegisterer(c.registerer)
+				tracers = append(tracers, quicmetrics.NewServerConnectionTracerWithRegisterer(c.registerer))
 			default:
 				log.Error("invalid logging perspective: %s", p)
 			}
 		}
-		var tracer *quiclogging.ConnectionTracer
-		if qlogTracerDir != "" {
-			tracer = qloggerForDir(qlogTracerDir, p, ci)
-			if promTracer != nil {
-				tracer = quiclogging.NewMultiplexedConnectionTracer(promTracer,
-					tracer)
+		if t := c.qlog.newTracer(p, ci); t != nil {
+			tracers = append(tracers, t)
+		}
+		if id, ok := ctx.Value(quic.ConnectionTracingKey).(quic.ConnectionTracingID); ok {
+			if c.enableMetrics || c.countECN {
+				tracers = append(tracers, c.ecn.NewConnectionTracer(id))
 			}
+			if c.measureRTT {
+				tracers = append(tracers, c.rtt.NewConnectionTracer(id))
+			}
+		}
+		switch len(tracers) {
+		case 0:
+			return nil
+		case 1:
+			return tracers[0]
+		default:
+			return quiclogging.NewMultiplexedConnectionTracer(tracers...)
 		}
-		return tracer
 	}
 }
 
+// ECNCounters returns the ECN counters of a connection created by this
+// ConnManager. It returns nil if the connection is unknown or already closed,
+// or if neither metrics nor ECN counting (see EnableECNCounters) are enabled.
+func (c *ConnManager) ECNCounters(conn quic.Connection) *ECNCounters {
+	id, ok := conn.Context().Value(quic.ConnectionTracingKey).(quic.ConnectionTracingID)
+	if !ok {
+		return nil
+	}
+	return c.ecn.get(id)
+}
+
+// SmoothedRTT returns the smoothed RTT of a connection created by this
+// ConnManager. It returns zero if the connection is unknown or already closed,
+// or if RTT measurement (see EnableRTTMeasurement) is disabled.
+func (c *ConnManager) SmoothedRTT(conn quic.Connection) time.Duration {
+	id, ok := conn.Context().Value(quic.ConnectionTracingKey).(quic.ConnectionTracingID)
+	if !ok {
+		return 0
+	}
+	rtt, _ := c.rtt.get(id)
+	return rtt
+}
+
 func (c *ConnManager) getReuse(network string) (*reuse, error) {
 	switch network {
 	case "udp4":
@@ -198,6 +283,9 @@ func (c *ConnManager) LendTransport(network string, tr QUICTransport, conn net.P
 
 // ListenQUIC listens for quic connections with the provided `tlsConf.NextProtos` ALPNs on `addr`. The same addr can be shared between
 // different ALPNs.
+// Applications can use this to run their own QUIC service on the same port as libp2p, by listening on one of the
+// host's QUIC listen addresses with their own ALPNs. The ConnManager used by a libp2p host can be obtained using
+// `libp2p.WithFxOption(fx.Populate(&connManager))`.
 func (c *ConnManager) ListenQUIC(addr ma.Multiaddr, tlsConf *tls.Config, allowWindowIncrease func(conn quic.Connection, delta uint64) bool) (Listener, error) {
 	return c.ListenQUICAndAssociate(nil, addr, tlsConf, allowWindowIncrease)
 }
@@ -274,7 +362,23 @@ func (c *ConnManager) onListenerClosed(key string) {
 }
 
 // SharedNonQUICPacketConn returns a `net.PacketConn` for `laddr` for non QUIC uses.
-func (c *ConnManager) SharedNonQUICPacketConn(_ string, laddr *net.UDPAddr) (net.PacketConn, error) {
+// It receives all non-QUIC packets that aren't matched by a filter of a conn
+// returned by SharedNonQUICPacketConnWithFilter.
+func (c *ConnManager) SharedNonQUICPacketConn(network string, laddr *net.UDPAddr) (net.PacketConn, error) {
+	return c.SharedNonQUICPacketConnWithFilter(network, laddr, nil)
+}
+
+// SharedNonQUICPacketConnWithFilter returns a `net.PacketConn` for `laddr` that
+// receives the non-QUIC packets for which filter returns true. This allows
+// applications to run their own UDP based protocols on the same port as
+// libp2p. Packets are passed to the first matching conn, in the order the
+// conns were created. Applications that want to run their own QUIC service on
+// the same port should use ListenQUIC with their own ALPNs instead.
+// filter is called for every non-QUIC packet, and must not block.
+// Up to 128 packets are queued for each conn; packets that don't fit are
+// dropped. The returned conn reports the number of dropped packets via a
+// DroppedPackets() uint64 method.
+func (c *ConnManager) SharedNonQUICPacketConnWithFilter(_ string, laddr *net.UDPAddr, filter func(packet []byte) bool) (net.PacketConn, error) {
 	c.quicListenersMu.Lock()
 	defer c.quicListenersMu.Unlock()
 	key := laddr.String()
@@ -285,13 +389,12 @@ func (c *ConnManager) SharedNonQUICPacketConn(_ string, laddr *net.UDPAddr) (net
 	t := entry.ln.transport
 	if t, ok := t.(*refcountedTransport); ok {
 		t.IncreaseCount()
-		ctx, cancel := context.WithCancel(context.Background())
-		return &nonQUICPacketConn{
-			ctx:             ctx,
-			ctxCancel:       cancel,
-			owningTransport: t,
-			tr:              t.QUICTransport,
-		}, nil
+		conn, err := newNonQUICPacketConn(t, filter)
+		if err != nil {
+			t.DecreaseCount()
+			return nil, err
+		}
+		return conn, nil
 	}
 	return nil, errors.New("expected to be able to share with a QUIC listener, but the QUIC listener is not using a refcountedTransport. `DisableReuseport` should not be set")
 }
@@ -325,8 +428,18 @@ func WithAssociation(ctx context.Context, association any) context.Context {
 	return context.WithValue(ctx, associationKey{}, association)
 }
 
+type transportParametersKey struct{}
+
+// WithTransportParameters returns a new context with the given transport
+// parameters. Used in DialQUIC to override the QUIC transport parameters of
+// the dialed connection.
+func WithTransportParameters(ctx context.Context, params TransportParameters) context.Context {
+	return context.WithValue(ctx, transportParametersKey{}, params)
+}
+
 // DialQUIC dials `raddr`. Use `WithAssociation` to select a specific transport that was previously used for listening.
 // see the documentation for `ListenQUICAndAssociate` for details on associate.
+// Use `WithTransportParameters` to override the QUIC transport parameters for this dial.
 // The priority order for reusing the transport is as follows:
 // - Listening transport with the same association
 // - Any other listening transport
@@ -344,6 +457,9 @@ func (c *ConnManager) DialQUIC(ctx context.Context, raddr ma.Multiaddr, tlsConf
 
 	quicConf := c.clientConfig.Clone()
 	quicConf.AllowConnectionWindowIncrease = allowWindowIncrease
+	if params, ok := ctx.Value(transportParametersKey{}).(TransportParameters); ok {
+		params.apply(quicConf)
+	}
 
 	if v == quic.Version1 {
 		// The endpoint has explicit support for QUIC v1, so we'll only use that version.
@@ -418,7 +534,20 @@ func (c *ConnManager) Protocols() []int {
 	return []int{ma.P_QUIC_V1}
 }
 
+// UDPSockets returns the buffer sizes and receive drops of the UDP sockets
+// used by the ConnManager. Sockets are only tracked if SocketBufferSizes or
+// EnableMetrics is used.
+func (c *ConnManager) UDPSockets() []UDPSocketInfo {
+	return c.udpSockets.Info()
+}
+
 func (c *ConnManager) Close() error {
+	if c.udpCollector != nil {
+		c.udpCollector.close()
+	}
+	if c.adjustDone != nil {
+		c.closeOnce.Do(func() { close(c.adjustDone) })
+	}
 	if !c.enableReuseport {
 		return nil
 	}
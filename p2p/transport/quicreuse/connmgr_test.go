@@ -17,6 +17,7 @@ import (
 
 	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr/net"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/quic-go/quic-go"
 	"github.com/stretchr/testify/require"
 )
@@ -489,3 +490,203 @@ func TestConnContext(t *testing.T) {
 		})
 	}
 }
+
+func TestSharedNonQUICPacketConnWithFilter(t *testing.T) {
+	cm, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{})
+	require.NoError(t, err)
+	defer checkClosed(t, cm)
+	defer cm.Close()
+
+	ln, err := cm.ListenQUIC(ma.StringCast("/ip4/127.0.0.1/udp/0/quic-v1"), &tls.Config{NextProtos: []string{"proto"}}, nil)
+	require.NoError(t, err)
+	defer ln.Close()
+	laddr := ln.Addr().(*net.UDPAddr)
+
+	// The first bit of non-QUIC packets is not set.
+	filtered, err := cm.SharedNonQUICPacketConnWithFilter("udp4", laddr, func(b []byte) bool { return b[0] == 0x01 })
+	require.NoError(t, err)
+	defer filtered.Close()
+	other, err := cm.SharedNonQUICPacketConn("udp4", laddr)
+	require.NoError(t, err)
+	defer other.Close()
+
+	sender, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer sender.Close()
+	// quic-go drops non-QUIC packets received before the first read, so keep
+	// sending until the packet arrives.
+	receive := func(c net.PacketConn, msg []byte) {
+		t.Helper()
+		b := make([]byte, 100)
+		require.Eventually(t, func() bool {
+			_, err := sender.WriteTo(msg, laddr)
+			require.NoError(t, err)
+			c.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+			n, addr, err := c.ReadFrom(b)
+			if err != nil {
+				return false
+			}
+			require.Equal(t, sender.LocalAddr().String(), addr.String())
+			require.Equal(t, msg, b[:n])
+			return true
+		}, 5*time.Second, 10*time.Millisecond)
+	}
+	receive(filtered, []byte{0x01, 'f', 'o', 'o'})
+	receive(other, []byte{0x02, 'b', 'a', 'r'})
+
+	// Once closed, reads fail.
+	require.NoError(t, filtered.Close())
+	_, _, err = filtered.ReadFrom(make([]byte, 100))
+	require.ErrorIs(t, err, net.ErrClosed)
+}
+
+func TestNonQUICPacketMuxDrops(t *testing.T) {
+	c := &nonQUICPacketConn{queue: make(chan nonQUICPacket, 1)}
+	m := &nonQUICPacketMux{conns: []*nonQUICPacketConn{c}}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.dispatch(ctx, nonQUICPacket{data: []byte("foo")})
+	m.dispatch(ctx, nonQUICPacket{data: []byte("bar")})
+	require.Equal(t, uint64(1), c.DroppedPackets())
+	require.Equal(t, []byte("foo"), (<-c.queue).data)
+
+	// Packets read by a run loop that was stopped are discarded.
+	cancel()
+	m.dispatch(ctx, nonQUICPacket{data: []byte("baz")})
+	require.Empty(t, c.queue)
+	require.Equal(t, uint64(1), c.DroppedPackets())
+}
+
+func TestSocketBufferSizes(t *testing.T) {
+	const size = 8 << 20
+	reg := prometheus.NewRegistry()
+	cm, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{}, SocketBufferSizes(size, size), EnableMetrics(reg))
+	require.NoError(t, err)
+	defer checkClosed(t, cm)
+
+	ln, err := cm.ListenQUIC(ma.StringCast("/ip4/127.0.0.1/udp/0/quic-v1"), &tls.Config{NextProtos: []string{"proto"}}, nil)
+	require.NoError(t, err)
+	infos := cm.UDPSockets()
+	require.Len(t, infos, 1)
+	require.Equal(t, ln.Addr().String(), infos[0].LocalAddr.String())
+	if runtime.GOOS == "linux" {
+		// Linux reports twice the configured sizes.
+		if infos[0].ReceiveBufferSize/2 < initialReceiveBuffer(size) {
+			ln.Close()
+			cm.Close()
+			t.Skip("increasing the buffers requires a large net.core.rmem_max or CAP_NET_ADMIN")
+		}
+		require.GreaterOrEqual(t, infos[0].SendBufferSize/2, size)
+	}
+
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	var found bool
+	for _, mf := range mfs {
+		if mf.GetName() == "quic_udp_receive_buffer_bytes" {
+			found = true
+			require.Len(t, mf.GetMetric(), 1)
+		}
+	}
+	require.True(t, found)
+
+	// Closed sockets are no longer reported.
+	require.NoError(t, ln.Close())
+	require.NoError(t, cm.Close())
+	require.Empty(t, cm.UDPSockets())
+}
+
+func TestUDPSocketsPruneClosed(t *testing.T) {
+	sockets := newUDPSockets()
+	for range 10 * minPruneSockets {
+		conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		require.NoError(t, err)
+		sockets.add(conn)
+		conn.Close()
+	}
+	// Closed sockets are removed without Info or growReceiveBuffers being called.
+	sockets.mx.Lock()
+	defer sockets.mx.Unlock()
+	require.LessOrEqual(t, len(sockets.conns), minPruneSockets)
+}
+
+func TestUDPSocketMetricsPerConnManager(t *testing.T) {
+	countSockets := func(reg *prometheus.Registry) int {
+		mfs, err := reg.Gather()
+		require.NoError(t, err)
+		for _, mf := range mfs {
+			if mf.GetName() == "quic_udp_receive_buffer_bytes" {
+				return len(mf.GetMetric())
+			}
+		}
+		return 0
+	}
+
+	reg1 := prometheus.NewRegistry()
+	cm1, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{}, EnableMetrics(reg1))
+	require.NoError(t, err)
+	defer checkClosed(t, cm1)
+	reg2 := prometheus.NewRegistry()
+	cm2, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{}, EnableMetrics(reg2))
+	require.NoError(t, err)
+	defer checkClosed(t, cm2)
+
+	ln, err := cm1.ListenQUIC(ma.StringCast("/ip4/127.0.0.1/udp/0/quic-v1"), &tls.Config{NextProtos: []string{"proto"}}, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, countSockets(reg1))
+	require.Zero(t, countSockets(reg2))
+
+	require.NoError(t, ln.Close())
+	require.NoError(t, cm1.Close())
+	require.NoError(t, cm2.Close())
+	require.Zero(t, countSockets(reg1))
+}
+
+func TestGrowReceiveBuffers(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("receive drops are only reported on Linux")
+	}
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetReadBuffer(64<<10))
+	sockets := newUDPSockets()
+	sockets.add(conn)
+	sockets.growReceiveBuffers(1 << 20)
+	getSize := func() int {
+		infos := sockets.Info()
+		require.Len(t, infos, 1)
+		return infos[0].ReceiveBufferSize / 2
+	}
+	require.Equal(t, 64<<10, getSize())
+
+	// No drops, no change.
+	sockets.growReceiveBuffers(1 << 20)
+	require.Equal(t, 64<<10, getSize())
+
+	sender, err := net.DialUDP("udp4", nil, conn.LocalAddr().(*net.UDPAddr))
+	require.NoError(t, err)
+	defer sender.Close()
+	for range 1000 {
+		_, err := sender.Write(make([]byte, 1000))
+		require.NoError(t, err)
+	}
+	sockets.growReceiveBuffers(1 << 20)
+	if getSize() < 128<<10 {
+		t.Skip("increasing the buffers requires a large net.core.rmem_max or CAP_NET_ADMIN")
+	}
+	require.Equal(t, 128<<10, getSize())
+}
+
+func TestTransportParameters(t *testing.T) {
+	conf := quicConfig.Clone()
+	params := TransportParameters{
+		MaxIncomingStreams:         1000,
+		MaxConnectionReceiveWindow: 100 << 20,
+	}
+	params.apply(conf)
+	require.Equal(t, int64(1000), conf.MaxIncomingStreams)
+	require.Equal(t, uint64(100<<20), conf.MaxConnectionReceiveWindow)
+	// Zero values keep the defaults.
+	require.Equal(t, quicConfig.MaxIncomingUniStreams, conf.MaxIncomingUniStreams)
+	require.Equal(t, quicConfig.MaxStreamReceiveWindow, conf.MaxStreamReceiveWindow)
+}
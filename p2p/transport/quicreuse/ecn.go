@@ -0,0 +1,144 @@
+package quicreuse
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/libp2p/go-libp2p/p2p/metricshelper"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/logging"
+)
+
+// ECNCounters counts the Explicit Congestion Notification (ECN) codepoints of
+// the packets received on a QUIC connection, and tracks the ECN validation
+// state of the path. It is safe for concurrent use.
+//
+// quic-go sends ECN-capable packets on platforms that support it, unless
+// disabled using the QUIC_GO_DISABLE_ECN environment variable.
+type ECNCounters struct {
+	ect0, ect1, ce atomic.Uint64
+	state          atomic.Uint32
+}
+
+// ECT0 returns the number of received packets marked ECT(0).
+func (c *ECNCounters) ECT0() uint64 { return c.ect0.Load() }
+
+// ECT1 returns the number of received packets marked ECT(1).
+func (c *ECNCounters) ECT1() uint64 { return c.ect1.Load() }
+
+// CE returns the number of received packets marked Congestion Experienced,
+// i.e. the number of packets a router on the path marked instead of dropping
+// them.
+func (c *ECNCounters) CE() uint64 { return c.ce.Load() }
+
+// State returns the ECN validation state of the connection's path: "testing",
+// "unknown", "failed" or "capable". It returns an empty string if ECN
+// validation hasn't started.
+func (c *ECNCounters) State() string {
+	return ecnStateString(logging.ECNState(c.state.Load()))
+}
+
+func ecnStateString(s logging.ECNState) string {
+	switch s {
+	case logging.ECNStateTesting:
+		return "testing"
+	case logging.ECNStateUnknown:
+		return "unknown"
+	case logging.ECNStateFailed:
+		return "failed"
+	case logging.ECNStateCapable:
+		return "capable"
+	default:
+		return ""
+	}
+}
+
+var (
+	ecnPacketsReceived = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "quic_ecn_packets_received_total",
+			Help: "QUIC packets received with an ECN codepoint",
+		},
+		[]string{"ecn"},
+	)
+	ecnStateTransitions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "quic_ecn_state_transitions_total",
+			Help: "ECN validation state transitions of QUIC connections",
+		},
+		[]string{"state"},
+	)
+)
+
+// ecnTracker keeps the ECN counters of all open connections.
+type ecnTracker struct {
+	enableMetrics bool
+
+	mx       sync.Mutex
+	counters map[quic.ConnectionTracingID]*ECNCounters
+}
+
+func newECNTracker(enableMetrics bool, reg prometheus.Registerer) *ecnTracker {
+	if enableMetrics {
+		metricshelper.RegisterCollectors(reg, ecnPacketsReceived, ecnStateTransitions)
+	}
+	return &ecnTracker{
+		enableMetrics: enableMetrics,
+		counters:      make(map[quic.ConnectionTracingID]*ECNCounters),
+	}
+}
+
+func (t *ecnTracker) get(id quic.ConnectionTracingID) *ECNCounters {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	return t.counters[id]
+}
+
+// NewConnectionTracer returns a tracer that counts the ECN codepoints of the
+// connection with the given tracing ID.
+func (t *ecnTracker) NewConnectionTracer(id quic.ConnectionTracingID) *logging.ConnectionTracer {
+	c := &ECNCounters{}
+	t.mx.Lock()
+	t.counters[id] = c
+	t.mx.Unlock()
+
+	received := func(ecn logging.ECN) {
+		var label string
+		switch ecn {
+		case logging.ECT0:
+			c.ect0.Add(1)
+			label = "ect0"
+		case logging.ECT1:
+			c.ect1.Add(1)
+			label = "ect1"
+		case logging.ECNCE:
+			c.ce.Add(1)
+			label = "ce"
+		default:
+			return
+		}
+		if t.enableMetrics {
+			ecnPacketsReceived.WithLabelValues(label).Inc()
+		}
+	}
+	return &logging.ConnectionTracer{
+		ReceivedLongHeaderPacket: func(_ *logging.ExtendedHeader, _ logging.ByteCount, ecn logging.ECN, _ []logging.Frame) {
+			received(ecn)
+		},
+		ReceivedShortHeaderPacket: func(_ *logging.ShortHeader, _ logging.ByteCount, ecn logging.ECN, _ []logging.Frame) {
+			received(ecn)
+		},
+		ECNStateUpdated: func(state logging.ECNState, _ logging.ECNStateTrigger) {
+			c.state.Store(uint32(state))
+			if t.enableMetrics {
+				ecnStateTransitions.WithLabelValues(ecnStateString(state)).Inc()
+			}
+		},
+		Close: func() {
+			t.mx.Lock()
+			delete(t.counters, id)
+			t.mx.Unlock()
+		},
+	}
+}
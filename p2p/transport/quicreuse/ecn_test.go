@@ -0,0 +1,50 @@
+package quicreuse
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/logging"
+	"github.com/stretchr/testify/require"
+)
+
+func TestECNCounters(t *testing.T) {
+	tracker := newECNTracker(false, prometheus.NewRegistry())
+	id := quic.ConnectionTracingID(42)
+	tr := tracker.NewConnectionTracer(id)
+	c := tracker.get(id)
+	require.NotNil(t, c)
+	require.Empty(t, c.State())
+
+	tr.ReceivedLongHeaderPacket(&logging.ExtendedHeader{}, 1200, logging.ECT0, nil)
+	tr.ReceivedShortHeaderPacket(&logging.ShortHeader{}, 1200, logging.ECT0, nil)
+	tr.ReceivedShortHeaderPacket(&logging.ShortHeader{}, 1200, logging.ECT1, nil)
+	tr.ReceivedShortHeaderPacket(&logging.ShortHeader{}, 1200, logging.ECNCE, nil)
+	tr.ReceivedShortHeaderPacket(&logging.ShortHeader{}, 1200, logging.ECTNot, nil)
+	tr.ECNStateUpdated(logging.ECNStateCapable, logging.ECNTriggerNoTrigger)
+
+	require.Equal(t, uint64(2), c.ECT0())
+	require.Equal(t, uint64(1), c.ECT1())
+	require.Equal(t, uint64(1), c.CE())
+	require.Equal(t, "capable", c.State())
+
+	tr.Close()
+	require.Nil(t, tracker.get(id))
+}
+
+func TestECNCountersOptIn(t *testing.T) {
+	ctx := context.WithValue(context.Background(), quic.ConnectionTracingKey, quic.ConnectionTracingID(1))
+	for _, enable := range []bool{false, true} {
+		var opts []Option
+		if enable {
+			opts = append(opts, EnableECNCounters())
+		}
+		cm, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{}, opts...)
+		require.NoError(t, err)
+		cm.getTracer()(ctx, logging.PerspectiveServer, quic.ConnectionID{})
+		require.Equal(t, enable, cm.ecn.get(1) != nil)
+		require.NoError(t, cm.Close())
+	}
+}
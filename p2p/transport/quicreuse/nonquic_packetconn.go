@@ -3,31 +3,177 @@ package quicreuse
 import (
 	"context"
 	"net"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// nonQUICPacketQueueLen is the number of non-QUIC packets queued for a
+// nonQUICPacketConn. Packets are dropped when the queue is full, see
+// nonQUICPacketConn.DroppedPackets.
+const nonQUICPacketQueueLen = 128
+
+type nonQUICPacket struct {
+	data []byte
+	addr net.Addr
+}
+
+// nonQUICPacketMux reads the non-QUIC packets received on a quic.Transport and
+// dispatches them to the nonQUICPacketConns sharing the transport.
+// A packet is passed to the first conn whose filter matches it. Packets
+// not matching any filter are passed to the first conn that has no filter.
+// Packets are only read from the transport while at least one conn is
+// registered.
+type nonQUICPacketMux struct {
+	tr QUICTransport
+
+	mx       sync.Mutex
+	conns    []*nonQUICPacketConn
+	cancel   context.CancelFunc
+	closeErr error
+}
+
+func (m *nonQUICPacketMux) add(c *nonQUICPacketConn) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if m.closeErr != nil {
+		return m.closeErr
+	}
+	m.conns = append(m.conns, c)
+	if m.cancel == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		m.cancel = cancel
+		go m.run(ctx)
+	}
+	return nil
+}
+
+func (m *nonQUICPacketMux) remove(c *nonQUICPacketConn) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	for i, conn := range m.conns {
+		if conn == c {
+			m.conns = append(m.conns[:i], m.conns[i+1:]...)
+			break
+		}
+	}
+	if len(m.conns) == 0 && m.cancel != nil {
+		m.cancel()
+		m.cancel = nil
+	}
+}
+
+func (m *nonQUICPacketMux) run(ctx context.Context) {
+	buf := make([]byte, 1<<16)
+	for {
+		n, addr, err := m.tr.ReadNonQUICPacket(ctx, buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			// The transport was closed.
+			m.mx.Lock()
+			m.closeErr = err
+			for _, c := range m.conns {
+				c.closeWithError(err)
+			}
+			m.mx.Unlock()
+			return
+		}
+		m.dispatch(ctx, nonQUICPacket{data: append([]byte(nil), buf[:n]...), addr: addr})
+	}
+}
+
+func (m *nonQUICPacketMux) dispatch(ctx context.Context, p nonQUICPacket) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	// All conns were removed after this packet was read. If new conns were
+	// added since, they are served by a new run loop.
+	if ctx.Err() != nil {
+		return
+	}
+	var target *nonQUICPacketConn
+	for _, c := range m.conns {
+		if c.filter == nil {
+			if target == nil {
+				target = c
+			}
+			continue
+		}
+		if c.filter(p.data) {
+			target = c
+			break
+		}
+	}
+	if target == nil {
+		return
+	}
+	select {
+	case target.queue <- p:
+	default:
+		target.dropped.Add(1)
+		log.Debugw("dropping non-QUIC packet, queue full", "remote", p.addr)
+	}
+}
+
 // nonQUICPacketConn is a net.PacketConn that can be used to read and write
 // non-QUIC packets on a quic.Transport. This lets us reuse this UDP port for
 // other transports like WebRTC.
 type nonQUICPacketConn struct {
 	owningTransport RefCountedQUICTransport
 	tr              QUICTransport
+	mux             *nonQUICPacketMux
+	filter          func(packet []byte) bool
+	queue           chan nonQUICPacket
+	dropped         atomic.Uint64
 	ctx             context.Context
-	ctxCancel       context.CancelFunc
+	ctxCancel       context.CancelCauseFunc
 	readCtx         context.Context
 	readCancel      context.CancelFunc
+	closeOnce       sync.Once
+}
+
+func newNonQUICPacketConn(t *refcountedTransport, filter func(packet []byte) bool) (*nonQUICPacketConn, error) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	c := &nonQUICPacketConn{
+		ctx:             ctx,
+		ctxCancel:       cancel,
+		owningTransport: t,
+		tr:              t.QUICTransport,
+		mux:             t.nonQUICPacketMux(),
+		filter:          filter,
+		queue:           make(chan nonQUICPacket, nonQUICPacketQueueLen),
+	}
+	if err := c.mux.add(c); err != nil {
+		cancel(err)
+		return nil, err
+	}
+	return c, nil
+}
+
+func (n *nonQUICPacketConn) closeWithError(err error) {
+	n.ctxCancel(err)
 }
 
 // Close implements net.PacketConn.
 func (n *nonQUICPacketConn) Close() error {
-	n.ctxCancel()
+	n.closeOnce.Do(func() {
+		n.ctxCancel(net.ErrClosed)
+		n.mux.remove(n)
 
-	// Don't actually close the underlying transport since someone else might be using it.
-	// reuse has it's own gc to close unused transports.
-	n.owningTransport.DecreaseCount()
+		// Don't actually close the underlying transport since someone else might be using it.
+		// reuse has it's own gc to close unused transports.
+		n.owningTransport.DecreaseCount()
+	})
 	return nil
 }
 
+// DroppedPackets returns the number of packets dropped because they weren't
+// read fast enough.
+func (n *nonQUICPacketConn) DroppedPackets() uint64 {
+	return n.dropped.Load()
+}
+
 // LocalAddr implements net.PacketConn.
 func (n *nonQUICPacketConn) LocalAddr() net.Addr {
 	return n.owningTransport.LocalAddr()
@@ -39,7 +185,15 @@ func (n *nonQUICPacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
 	if ctx == nil {
 		ctx = n.ctx
 	}
-	return n.tr.ReadNonQUICPacket(ctx, p)
+	select {
+	case pkt := <-n.queue:
+		return copy(p, pkt.data), pkt.addr, nil
+	case <-ctx.Done():
+		if err := context.Cause(n.ctx); err != nil {
+			return 0, nil, err
+		}
+		return 0, nil, ctx.Err()
+	}
 }
 
 // SetDeadline implements net.PacketConn.
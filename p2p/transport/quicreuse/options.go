@@ -3,10 +3,13 @@ package quicreuse
 import (
 	"context"
 	"errors"
+	"io"
 	"net"
 
+	"github.com/libp2p/go-libp2p/p2p/transport/internal/sockopt"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/logging"
 )
 
 type Option func(*ConnManager) error
@@ -20,6 +23,42 @@ func OverrideListenUDP(f listenUDP) Option {
 	}
 }
 
+// SocketMark sets SO_MARK on all UDP sockets used for QUIC, so that traffic
+// can be routed or policed using fwmark based rules. This requires the
+// CAP_NET_ADMIN capability, and is only supported on Linux.
+// Since QUIC uses the same socket for listening and dialing, the mark applies
+// to all packets sent by the transport.
+func SocketMark(mark int) Option {
+	return func(m *ConnManager) error {
+		if !sockopt.MarkSupported {
+			return errors.New("SO_MARK is only supported on Linux")
+		}
+		m.socketMark = &mark
+		return nil
+	}
+}
+
+// SocketBufferSizes increases the receive and send buffers of all UDP sockets
+// used for QUIC up to maxReceive and maxSend bytes. The send buffer is set to
+// maxSend right away. The receive buffer starts at a quarter of maxReceive
+// (but at least 1 MB), and is doubled, up to maxReceive, whenever the kernel
+// dropped packets on the socket. If the system limits (net.core.rmem_max and
+// net.core.wmem_max on Linux) are lower, it tries to override them, which
+// requires the CAP_NET_ADMIN capability. A value of 0 keeps the default.
+// Independently of this option, quic-go tries to increase the buffers to at
+// least 7 MB.
+// The achieved sizes are reported by ConnManager.UDPSockets.
+func SocketBufferSizes(maxReceive, maxSend int) Option {
+	return func(m *ConnManager) error {
+		if maxReceive < 0 || maxSend < 0 {
+			return errors.New("socket buffer sizes must not be negative")
+		}
+		m.maxReceiveBuffer = maxReceive
+		m.maxSendBuffer = maxSend
+		return nil
+	}
+}
+
 func OverrideSourceIPSelector(f func() (SourceIPSelector, error)) Option {
 	return func(m *ConnManager) error {
 		m.sourceIPSelectorFn = f
@@ -66,3 +105,73 @@ func EnableMetrics(reg prometheus.Registerer) Option {
 		return nil
 	}
 }
+
+// EnableECNCounters counts the ECN codepoints of the packets received on every
+// connection, see ConnManager.ECNCounters. This is implied by EnableMetrics.
+func EnableECNCounters() Option {
+	return func(m *ConnManager) error {
+		m.countECN = true
+		return nil
+	}
+}
+
+// EnableRTTMeasurement records the smoothed RTT of every connection, see
+// ConnManager.SmoothedRTT.
+func EnableRTTMeasurement() Option {
+	return func(m *ConnManager) error {
+		m.measureRTT = true
+		return nil
+	}
+}
+
+// Qlog writes a qlog trace of every QUIC connection to dir. Traces are
+// compressed using zstd when the connection is closed. This takes precedence
+// over the QLOGDIR environment variable.
+func Qlog(dir string) Option {
+	return func(m *ConnManager) error {
+		if m.qlog.newWriter != nil {
+			return errors.New("cannot set the qlog output more than once")
+		}
+		m.qlog.newWriter = newQlogDirWriter(dir)
+		return nil
+	}
+}
+
+// QlogWriter writes a qlog trace of every QUIC connection to the writer
+// returned by f. The writer is closed when the connection is closed. If f
+// returns nil, the connection isn't traced. This takes precedence over the
+// QLOGDIR environment variable.
+func QlogWriter(f func(p logging.Perspective, connID quic.ConnectionID) io.WriteCloser) Option {
+	return func(m *ConnManager) error {
+		if m.qlog.newWriter != nil {
+			return errors.New("cannot set the qlog output more than once")
+		}
+		m.qlog.newWriter = f
+		return nil
+	}
+}
+
+// QlogSampleRate sets the fraction of connections that are traced when qlog
+// is enabled. It must be in the range (0, 1]. Defaults to 1.
+func QlogSampleRate(rate float64) Option {
+	return func(m *ConnManager) error {
+		if rate <= 0 || rate > 1 {
+			return errors.New("qlog sample rate must be in the range (0, 1]")
+		}
+		m.qlog.sampleRate = rate
+		return nil
+	}
+}
+
+// QlogMaxSize limits the size of the qlog trace of a single connection to
+// maxBytes (before compression). Events logged once the limit is reached are
+// dropped. By default, the size is not limited.
+func QlogMaxSize(maxBytes int64) Option {
+	return func(m *ConnManager) error {
+		if maxBytes <= 0 {
+			return errors.New("qlog max size must be positive")
+		}
+		m.qlog.maxSize = maxBytes
+		return nil
+	}
+}
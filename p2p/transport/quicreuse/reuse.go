@@ -89,6 +89,19 @@ type refcountedTransport struct {
 	borrowDoneSignal chan struct{}
 
 	assocations map[any]struct{}
+
+	nonQUICMux *nonQUICPacketMux
+}
+
+// nonQUICPacketMux returns the mux dispatching the non-QUIC packets received
+// on this transport.
+func (c *refcountedTransport) nonQUICPacketMux() *nonQUICPacketMux {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.nonQUICMux == nil {
+		c.nonQUICMux = &nonQUICPacketMux{tr: c.QUICTransport}
+	}
+	return c.nonQUICMux
 }
 
 type connContextFunc = func(context.Context, *quic.ClientInfo) (context.Context, error)
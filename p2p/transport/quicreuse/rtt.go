@@ -0,0 +1,51 @@
+package quicreuse
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/logging"
+)
+
+// rttTracker keeps the smoothed RTT of all open connections, as estimated by
+// the QUIC stack's loss recovery.
+type rttTracker struct {
+	mx   sync.Mutex
+	rtts map[quic.ConnectionTracingID]*atomic.Int64
+}
+
+func newRTTTracker() *rttTracker {
+	return &rttTracker{rtts: make(map[quic.ConnectionTracingID]*atomic.Int64)}
+}
+
+func (t *rttTracker) get(id quic.ConnectionTracingID) (time.Duration, bool) {
+	t.mx.Lock()
+	rtt, ok := t.rtts[id]
+	t.mx.Unlock()
+	if !ok {
+		return 0, false
+	}
+	return time.Duration(rtt.Load()), true
+}
+
+// NewConnectionTracer returns a tracer that records the smoothed RTT of the
+// connection with the given tracing ID.
+func (t *rttTracker) NewConnectionTracer(id quic.ConnectionTracingID) *logging.ConnectionTracer {
+	rtt := &atomic.Int64{}
+	t.mx.Lock()
+	t.rtts[id] = rtt
+	t.mx.Unlock()
+
+	return &logging.ConnectionTracer{
+		UpdatedMetrics: func(rttStats *logging.RTTStats, _, _ logging.ByteCount, _ int) {
+			rtt.Store(int64(rttStats.SmoothedRTT()))
+		},
+		Close: func() {
+			t.mx.Lock()
+			delete(t.rtts, id)
+			t.mx.Unlock()
+		},
+	}
+}
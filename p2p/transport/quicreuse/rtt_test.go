@@ -0,0 +1,30 @@
+package quicreuse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/logging"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRTTTracker(t *testing.T) {
+	tracker := newRTTTracker()
+	id := quic.ConnectionTracingID(42)
+	tr := tracker.NewConnectionTracer(id)
+	rtt, ok := tracker.get(id)
+	require.True(t, ok)
+	require.Zero(t, rtt)
+
+	var stats logging.RTTStats
+	stats.UpdateRTT(50*time.Millisecond, 0)
+	tr.UpdatedMetrics(&stats, 0, 0, 0)
+	rtt, ok = tracker.get(id)
+	require.True(t, ok)
+	require.Equal(t, 50*time.Millisecond, rtt)
+
+	tr.Close()
+	_, ok = tracker.get(id)
+	require.False(t, ok)
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"math/rand/v2"
 	"os"
 	"time"
 
@@ -17,20 +18,70 @@ import (
 var log = golog.Logger("quic-utils")
 
 // QLOGTracer holds a qlog tracer dir, if qlogging is enabled (enabled using the QLOGDIR environment variable).
-// Otherwise it is an empty string.
+// Otherwise it is an empty string. The Qlog and QlogWriter options take precedence over the environment variable.
 var qlogTracerDir string
 
 func init() {
 	qlogTracerDir = os.Getenv("QLOGDIR")
 }
 
-func qloggerForDir(qlogDir string, p logging.Perspective, ci quic.ConnectionID) *logging.ConnectionTracer {
-	// create the QLOGDIR, if it doesn't exist
-	if err := os.MkdirAll(qlogDir, 0777); err != nil {
-		log.Errorf("creating the QLOGDIR failed: %s", err)
+// qlogConfig configures qlog tracing of QUIC connections.
+type qlogConfig struct {
+	// newWriter returns the writer the qlog trace of a connection is written to.
+	// If nil, qlogging is disabled.
+	newWriter func(logging.Perspective, quic.ConnectionID) io.WriteCloser
+	// sampleRate is the fraction of connections that are traced.
+	sampleRate float64
+	// maxSize is the maximum number of bytes written per connection. 0 means no limit.
+	maxSize int64
+}
+
+func (c *qlogConfig) newTracer(p logging.Perspective, ci quic.ConnectionID) *logging.ConnectionTracer {
+	if c.newWriter == nil {
+		return nil
+	}
+	if c.sampleRate < 1 && rand.Float64() >= c.sampleRate {
 		return nil
 	}
-	return qlog.NewConnectionTracer(newQlogger(qlogDir, p, ci), p, ci)
+	w := c.newWriter(p, ci)
+	if w == nil {
+		return nil
+	}
+	if c.maxSize > 0 {
+		w = &limitedWriter{WriteCloser: w, remaining: c.maxSize}
+	}
+	return qlog.NewConnectionTracer(w, p, ci)
+}
+
+func newQlogDirWriter(qlogDir string) func(logging.Perspective, quic.ConnectionID) io.WriteCloser {
+	return func(p logging.Perspective, ci quic.ConnectionID) io.WriteCloser {
+		// create the qlog dir, if it doesn't exist
+		if err := os.MkdirAll(qlogDir, 0777); err != nil {
+			log.Errorf("creating the qlog dir failed: %s", err)
+			return nil
+		}
+		return newQlogger(qlogDir, p, ci)
+	}
+}
+
+// limitedWriter drops all writes once the size limit is reached.
+// Writes are never split, so that the trace doesn't end in a partial record.
+type limitedWriter struct {
+	io.WriteCloser
+	remaining int64
+	full      bool
+}
+
+func (w *limitedWriter) Write(b []byte) (int, error) {
+	if w.full {
+		return len(b), nil
+	}
+	if int64(len(b)) > w.remaining {
+		w.full = true
+		return len(b), nil
+	}
+	w.remaining -= int64(len(b))
+	return w.WriteCloser.Write(b)
 }
 
 // The qlogger logs qlog events to a temporary file: .<name>.qlog.swp.
@@ -2,6 +2,7 @@ package quicreuse
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"os"
 	"strings"
@@ -66,3 +67,63 @@ func TestQlogCompression(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, []byte("foobar"), data)
 }
+
+type nopWriteCloser struct{ *bytes.Buffer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestQlogMaxSize(t *testing.T) {
+	var buf bytes.Buffer
+	w := &limitedWriter{WriteCloser: nopWriteCloser{&buf}, remaining: 10}
+	n, err := w.Write([]byte("foo"))
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	// This write would exceed the limit. It is dropped as a whole.
+	n, err = w.Write([]byte("foobarfoo"))
+	require.NoError(t, err)
+	require.Equal(t, 9, n)
+	// Everything after the limit was hit is dropped, even if it would fit.
+	_, err = w.Write([]byte("b"))
+	require.NoError(t, err)
+	require.Equal(t, "foo", buf.String())
+}
+
+func TestQlogSampling(t *testing.T) {
+	var traced int
+	cfg := qlogConfig{
+		newWriter: func(logging.Perspective, quic.ConnectionID) io.WriteCloser {
+			traced++
+			return nopWriteCloser{&bytes.Buffer{}}
+		},
+		sampleRate: 0.25,
+	}
+	const n = 1000
+	for range n {
+		cfg.newTracer(logging.PerspectiveClient, quic.ConnectionIDFromBytes([]byte("connid")))
+	}
+	require.Greater(t, traced, n/10)
+	require.Less(t, traced, n/2)
+
+	traced = 0
+	cfg.sampleRate = 1
+	for range n {
+		require.NotNil(t, cfg.newTracer(logging.PerspectiveClient, quic.ConnectionIDFromBytes([]byte("connid"))))
+	}
+	require.Equal(t, n, traced)
+}
+
+func TestQlogOptions(t *testing.T) {
+	_, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{}, Qlog(t.TempDir()), QlogWriter(nil))
+	require.Error(t, err)
+	_, err = NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{}, QlogSampleRate(0))
+	require.Error(t, err)
+
+	dir := t.TempDir()
+	cm, err := NewConnManager(quic.StatelessResetKey{}, quic.TokenGeneratorKey{}, Qlog(dir))
+	require.NoError(t, err)
+	defer cm.Close()
+	tr := cm.getTracer()(context.Background(), logging.PerspectiveServer, quic.ConnectionIDFromBytes([]byte{0xde, 0xad, 0xbe, 0xef}))
+	require.NotNil(t, tr)
+	tr.Close()
+	require.Contains(t, getFile(t, dir).Name(), "deadbeef")
+}
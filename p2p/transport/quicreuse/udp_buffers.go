@@ -0,0 +1,304 @@
+package quicreuse
+
+import (
+	"net"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/libp2p/go-libp2p/p2p/metricshelper"
+	"github.com/libp2p/go-libp2p/p2p/transport/internal/sockopt"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// UDPSocketInfo describes a UDP socket used by the ConnManager.
+type UDPSocketInfo struct {
+	LocalAddr net.Addr
+	// ReceiveBufferSize and SendBufferSize are the buffer sizes as reported by
+	// the kernel. Linux reports twice the size that was configured, to
+	// account for bookkeeping overhead. They are only available on Linux.
+	ReceiveBufferSize int
+	SendBufferSize    int
+	// ReceiveDrops is the number of packets the kernel dropped on the socket,
+	// most commonly because the receive buffer was full. It is only available
+	// on Linux.
+	ReceiveDrops uint64
+}
+
+const (
+	// udpDropsRefreshInterval is how long the receive drops read from
+	// /proc/net/udp are cached, so that frequent scrapes don't rescan the
+	// socket table of the whole system.
+	udpDropsRefreshInterval = 5 * time.Second
+	// receiveBufferAdjustInterval is how often the receive buffers are
+	// grown if the kernel dropped packets.
+	receiveBufferAdjustInterval = 10 * time.Second
+	// minInitialReceiveBuffer is the smallest receive buffer a socket starts
+	// with when SocketBufferSizes is used.
+	minInitialReceiveBuffer = 1 << 20
+	// minPruneSockets is the number of tracked sockets above which closed
+	// sockets are removed when a socket is added.
+	minPruneSockets = 16
+)
+
+// udpSocket is the state kept for a socket created by a ConnManager.
+type udpSocket struct {
+	// lastDrops is the number of receive drops at the last adjustment.
+	lastDrops uint64
+}
+
+// udpSockets keeps track of the UDP sockets created by a ConnManager.
+type udpSockets struct {
+	mx    sync.Mutex
+	conns map[*net.UDPConn]*udpSocket
+
+	// pruneAt is the number of tracked sockets at which closed sockets are
+	// removed by add. It is doubled after pruning, so that sockets that are
+	// closed without Info or growReceiveBuffers ever running, like the ones
+	// used for a single dial, don't accumulate.
+	pruneAt int
+
+	drops        map[uint64]uint64 // receive drops by socket inode
+	dropsUpdated time.Time
+}
+
+func newUDPSockets() *udpSockets {
+	return &udpSockets{conns: make(map[*net.UDPConn]*udpSocket), pruneAt: minPruneSockets}
+}
+
+func (s *udpSockets) add(c *net.UDPConn) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if len(s.conns) >= s.pruneAt {
+		s.forEach(func(*net.UDPConn, syscall.RawConn, *udpSocket) {})
+		s.pruneAt = max(2*len(s.conns), minPruneSockets)
+	}
+	s.conns[c] = &udpSocket{}
+}
+
+// receiveDrops returns the receive drops of the socket, using the cached
+// contents of /proc/net/udp if they are recent enough.
+// s.mx must be held.
+func (s *udpSockets) receiveDrops(rc syscall.RawConn) (uint64, bool) {
+	inode, err := sockopt.SocketInode(rc)
+	if err != nil {
+		return 0, false
+	}
+	if s.drops == nil || time.Since(s.dropsUpdated) > udpDropsRefreshInterval {
+		drops, err := sockopt.UDPReceiveDropsByInode()
+		if err != nil {
+			return 0, false
+		}
+		s.drops = drops
+		s.dropsUpdated = time.Now()
+	}
+	d, ok := s.drops[inode]
+	return d, ok
+}
+
+// forEach calls f for all open sockets. Closed sockets are removed.
+// s.mx must be held.
+func (s *udpSockets) forEach(f func(c *net.UDPConn, rc syscall.RawConn, sock *udpSocket)) {
+	for c, sock := range s.conns {
+		rc, err := c.SyscallConn()
+		if err != nil {
+			delete(s.conns, c)
+			continue
+		}
+		if err := rc.Control(func(uintptr) {}); err != nil {
+			// The socket was closed.
+			delete(s.conns, c)
+			continue
+		}
+		f(c, rc, sock)
+	}
+}
+
+// Info returns the info of all open sockets. Closed sockets are removed.
+func (s *udpSockets) Info() []UDPSocketInfo {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	infos := make([]UDPSocketInfo, 0, len(s.conns))
+	s.forEach(func(c *net.UDPConn, rc syscall.RawConn, _ *udpSocket) {
+		info := UDPSocketInfo{LocalAddr: c.LocalAddr()}
+		info.ReceiveBufferSize, info.SendBufferSize, _ = sockopt.BufferSizes(rc)
+		info.ReceiveDrops, _ = s.receiveDrops(rc)
+		infos = append(infos, info)
+	})
+	return infos
+}
+
+// growReceiveBuffers doubles the receive buffer of the sockets on which the
+// kernel dropped packets since the last call, up to maxReceive.
+func (s *udpSockets) growReceiveBuffers(maxReceive int) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	// Always use fresh drop counts.
+	s.drops = nil
+	s.forEach(func(c *net.UDPConn, rc syscall.RawConn, sock *udpSocket) {
+		drops, ok := s.receiveDrops(rc)
+		if !ok {
+			return
+		}
+		dropped := drops > sock.lastDrops
+		sock.lastDrops = drops
+		if !dropped {
+			return
+		}
+		receive, _, err := sockopt.BufferSizes(rc)
+		if err != nil {
+			return
+		}
+		// Linux reports twice the configured size.
+		current := receive / 2
+		if current >= maxReceive {
+			return
+		}
+		size := min(2*current, maxReceive)
+		log.Debugw("growing UDP receive buffer after packet drops", "addr", c.LocalAddr(), "size", size, "drops", drops)
+		setReceiveBuffer(c, rc, size)
+	})
+}
+
+// adjustReceiveBuffers periodically grows the receive buffers of the sockets
+// that dropped packets, until done is closed.
+func (s *udpSockets) adjustReceiveBuffers(maxReceive int, done <-chan struct{}) {
+	t := time.NewTicker(receiveBufferAdjustInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			s.growReceiveBuffers(maxReceive)
+		case <-done:
+			return
+		}
+	}
+}
+
+// initialReceiveBuffer is the size of the receive buffer a socket starts with.
+// It is grown up to maxReceive if the kernel drops packets.
+func initialReceiveBuffer(maxReceive int) int {
+	return min(max(maxReceive/4, minInitialReceiveBuffer), maxReceive)
+}
+
+// setReceiveBuffer sets the receive buffer of the socket. If the system limit
+// (net.core.rmem_max on Linux) doesn't allow this, it tries to override it,
+// which requires the CAP_NET_ADMIN capability.
+func setReceiveBuffer(conn *net.UDPConn, rc syscall.RawConn, size int) {
+	// Errors are ignored. We check if we succeeded by querying the buffer size afterward.
+	_ = conn.SetReadBuffer(size)
+	// Linux reports twice the configured size.
+	if receive, _, err := sockopt.BufferSizes(rc); err == nil && receive/2 < size {
+		_ = sockopt.ForceReceiveBuffer(rc, size)
+	}
+}
+
+// tuneBuffers increases the receive buffer of the socket to its initial size,
+// and the send buffer to maxSend.
+// If the system limits (net.core.rmem_max and net.core.wmem_max on Linux)
+// don't allow this, it tries to override them, which requires the
+// CAP_NET_ADMIN capability.
+func tuneBuffers(conn *net.UDPConn, maxReceive, maxSend int) {
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		return
+	}
+	var wantReceive int
+	if maxReceive > 0 {
+		wantReceive = initialReceiveBuffer(maxReceive)
+		setReceiveBuffer(conn, rc, wantReceive)
+	}
+	if maxSend > 0 {
+		_ = conn.SetWriteBuffer(maxSend)
+		if _, send, err := sockopt.BufferSizes(rc); err == nil && send/2 < maxSend {
+			_ = sockopt.ForceSendBuffer(rc, maxSend)
+		}
+	}
+	receive, send, err := sockopt.BufferSizes(rc)
+	if err != nil {
+		return
+	}
+	// Linux reports twice the configured sizes.
+	if receive/2 < wantReceive || send/2 < maxSend {
+		log.Warnw("failed to increase UDP socket buffers to the configured size. See https://github.com/quic-go/quic-go/wiki/UDP-Buffer-Sizes for details",
+			"addr", conn.LocalAddr(), "receive", receive/2, "send", send/2, "want_receive", wantReceive, "max_send", maxSend)
+		return
+	}
+	log.Debugw("increased UDP socket buffers", "addr", conn.LocalAddr(), "receive", receive/2, "send", send/2)
+}
+
+// trackedListenUDP wraps listen, tuning the buffers of the sockets it creates
+// and adding them to sockets.
+func trackedListenUDP(listen listenUDP, sockets *udpSockets, maxReceive, maxSend int) listenUDP {
+	return func(network string, laddr *net.UDPAddr) (net.PacketConn, error) {
+		conn, err := listen(network, laddr)
+		if err != nil {
+			return nil, err
+		}
+		if c, ok := conn.(*net.UDPConn); ok {
+			if maxReceive > 0 || maxSend > 0 {
+				tuneBuffers(c, maxReceive, maxSend)
+			}
+			sockets.add(c)
+		}
+		return conn, nil
+	}
+}
+
+var (
+	udpReceiveBufferDesc = prometheus.NewDesc(
+		"quic_udp_receive_buffer_bytes",
+		"Receive buffer size of the UDP sockets, as reported by the kernel",
+		[]string{"addr"}, nil,
+	)
+	udpSendBufferDesc = prometheus.NewDesc(
+		"quic_udp_send_buffer_bytes",
+		"Send buffer size of the UDP sockets, as reported by the kernel",
+		[]string{"addr"}, nil,
+	)
+	udpReceiveDropsDesc = prometheus.NewDesc(
+		"quic_udp_receive_drops_total",
+		"Packets dropped by the kernel on the UDP sockets, most commonly because the receive buffer was full",
+		[]string{"addr"}, nil,
+	)
+)
+
+// udpSocketCollector reports the buffer sizes and drops of the UDP sockets of
+// a ConnManager.
+// It is an unchecked collector: it doesn't describe its metrics, so that the
+// collectors of several ConnManagers can be registered with the same registry.
+type udpSocketCollector struct {
+	mx      sync.Mutex
+	sockets *udpSockets // nil once the ConnManager is closed
+}
+
+var _ prometheus.Collector = (*udpSocketCollector)(nil)
+
+func newUDPSocketCollector(reg prometheus.Registerer, s *udpSockets) *udpSocketCollector {
+	c := &udpSocketCollector{sockets: s}
+	metricshelper.RegisterCollectors(reg, c)
+	return c
+}
+
+// close stops reporting the sockets. Unchecked collectors can't be unregistered.
+func (c *udpSocketCollector) close() {
+	c.mx.Lock()
+	c.sockets = nil
+	c.mx.Unlock()
+}
+
+func (c *udpSocketCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (c *udpSocketCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	if c.sockets == nil {
+		return
+	}
+	for _, info := range c.sockets.Info() {
+		addr := info.LocalAddr.String()
+		ch <- prometheus.MustNewConstMetric(udpReceiveBufferDesc, prometheus.GaugeValue, float64(info.ReceiveBufferSize), addr)
+		ch <- prometheus.MustNewConstMetric(udpSendBufferDesc, prometheus.GaugeValue, float64(info.SendBufferSize), addr)
+		ch <- prometheus.MustNewConstMetric(udpReceiveDropsDesc, prometheus.CounterValue, float64(info.ReceiveDrops), addr)
+	}
+}
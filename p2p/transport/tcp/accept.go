@@ -0,0 +1,132 @@
+package tcp
+
+import (
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/transport"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// allowAccept returns true if accepting a connection from c's remote
+// address is within the limits of the accept rate limiter.
+func (ll *tcpGatedMaListener) allowAccept(c manet.Conn) bool {
+	if ll.acceptLimiter == nil {
+		return true
+	}
+	ip, err := manet.ToIP(c.RemoteMultiaddr())
+	if err != nil {
+		return true
+	}
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return true
+	}
+	return ll.acceptLimiter.Allow(addr.Unmap())
+}
+
+// handshakeTracker closes inbound connections that aren't upgraded and
+// accepted within the handshake timeout.
+type handshakeTracker struct {
+	timeout time.Duration
+
+	mx sync.Mutex
+	// pending maps the 4-tuple of a connection to the handshakes started for
+	// it, oldest first. A 4-tuple can only be reused once the previous
+	// connection is closed, so the last entry belongs to the live connection;
+	// the others are left over from connections that failed to upgrade.
+	pending map[string][]*handshake
+}
+
+type handshake struct {
+	timer *time.Timer
+}
+
+func newHandshakeTracker(timeout time.Duration) *handshakeTracker {
+	return &handshakeTracker{
+		timeout: timeout,
+		pending: make(map[string][]*handshake),
+	}
+}
+
+func handshakeKey(c interface {
+	LocalMultiaddr() ma.Multiaddr
+	RemoteMultiaddr() ma.Multiaddr
+}) string {
+	return c.LocalMultiaddr().String() + " " + c.RemoteMultiaddr().String()
+}
+
+// Start starts the handshake timer for a newly accepted connection.
+func (h *handshakeTracker) Start(c manet.Conn) {
+	key := handshakeKey(c)
+	hs := &handshake{}
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	hs.timer = time.AfterFunc(h.timeout, func() {
+		h.remove(key, hs)
+		log.Debugw("closing connection that didn't complete the handshake in time", "remote", c.RemoteMultiaddr())
+		tryLinger(c, 0)
+		c.Close()
+	})
+	h.pending[key] = append(h.pending[key], hs)
+}
+
+// remove removes hs, and only hs, from the pending handshakes.
+func (h *handshakeTracker) remove(key string, hs *handshake) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	entries := h.pending[key]
+	for i, e := range entries {
+		if e == hs {
+			entries = append(entries[:i:i], entries[i+1:]...)
+			break
+		}
+	}
+	if len(entries) == 0 {
+		delete(h.pending, key)
+	} else {
+		h.pending[key] = entries
+	}
+}
+
+// Done stops the handshake timer of an upgraded connection. It returns false
+// if the timer already fired. Timers left over from earlier connections with
+// the same 4-tuple are stopped as well, as those connections are closed.
+func (h *handshakeTracker) Done(c transport.CapableConn) bool {
+	key := handshakeKey(c)
+	h.mx.Lock()
+	entries := h.pending[key]
+	delete(h.pending, key)
+	h.mx.Unlock()
+	if len(entries) == 0 {
+		return false
+	}
+	for _, e := range entries[:len(entries)-1] {
+		e.timer.Stop()
+	}
+	return entries[len(entries)-1].timer.Stop()
+}
+
+// handshakeTimeoutListener stops the handshake timers of the connections
+// returned by the upgrader.
+type handshakeTimeoutListener struct {
+	transport.Listener
+	tracker *handshakeTracker
+}
+
+func (l *handshakeTimeoutListener) Accept() (transport.CapableConn, error) {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if !l.tracker.Done(c) {
+			c.Close()
+			continue
+		}
+		return c, nil
+	}
+}
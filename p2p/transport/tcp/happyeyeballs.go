@@ -0,0 +1,142 @@
+package tcp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+// happyEyeballsDial resolves the DNS address and races the connection attempts
+// to the resulting IPv6 and IPv4 addresses (RFC 8305). The attempts go through
+// dialResolved, so that they reuse the listening port when possible.
+func (t *TcpTransport) happyEyeballsDial(ctx context.Context, raddr ma.Multiaddr) (manet.Conn, error) {
+	addrs, err := t.resolveTCP(ctx, raddr)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		conn manet.Conn
+		err  error
+	}
+	results := make(chan result, len(addrs))
+	var next, pending int
+	startNext := func() {
+		addr := addrs[next]
+		next++
+		pending++
+		go func() {
+			c, err := t.dialResolved(ctx, addr)
+			results <- result{conn: c, err: err}
+		}()
+	}
+
+	startNext()
+	delay := time.NewTimer(t.happyEyeballsDelay)
+	defer delay.Stop()
+	var firstErr error
+	for {
+		select {
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				cancel()
+				// Close the connections of the attempts that succeed too late.
+				go func(n int) {
+					for range n {
+						if res := <-results; res.err == nil {
+							res.conn.Close()
+						}
+					}
+				}(pending)
+				return res.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = res.err
+			}
+			// Start the next attempt right away when an attempt fails.
+			if next < len(addrs) {
+				startNext()
+				delay.Reset(t.happyEyeballsDelay)
+			} else if pending == 0 {
+				return nil, firstErr
+			}
+		case <-delay.C:
+			if next < len(addrs) {
+				startNext()
+				delay.Reset(t.happyEyeballsDelay)
+			}
+		}
+	}
+}
+
+// maxResolvedAddrs limits the number of addresses a DNS address resolves to.
+const maxResolvedAddrs = 100
+
+// resolveTCP resolves a /dns, /dns4 or /dns6 TCP address, using the configured
+// resolver if there is one. The resulting addresses alternate between IPv6 and
+// IPv4, starting with IPv6.
+func (t *TcpTransport) resolveTCP(ctx context.Context, raddr ma.Multiaddr) ([]ma.Multiaddr, error) {
+	dnsComp, rest := ma.SplitFirst(raddr)
+	if dnsComp == nil || rest == nil {
+		return nil, errors.New("invalid DNS address")
+	}
+	var resolved []ma.Multiaddr
+	if t.resolver != nil {
+		var err error
+		resolved, err = t.resolver.ResolveDNSComponent(ctx, raddr, maxResolvedAddrs)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		ips, err := net.DefaultResolver.LookupIPAddr(ctx, dnsComp.Value())
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			if ip.IP.To4() != nil {
+				resolved = append(resolved, ma.Join(ma.StringCast("/ip4/"+ip.IP.String()), rest))
+			} else {
+				resolved = append(resolved, ma.Join(ma.StringCast("/ip6/"+ip.IP.String()), rest))
+			}
+		}
+	}
+
+	var v6, v4 []ma.Multiaddr
+	for _, addr := range resolved {
+		first, _ := ma.SplitFirst(addr)
+		if first == nil {
+			continue
+		}
+		switch first.Protocol().Code {
+		case ma.P_IP4:
+			if dnsComp.Protocol().Code != ma.P_DNS6 {
+				v4 = append(v4, addr)
+			}
+		case ma.P_IP6:
+			if dnsComp.Protocol().Code != ma.P_DNS4 {
+				v6 = append(v6, addr)
+			}
+		}
+	}
+	addrs := make([]ma.Multiaddr, 0, len(v6)+len(v4))
+	for i := 0; i < max(len(v6), len(v4)); i++ {
+		if i < len(v6) {
+			addrs = append(addrs, v6[i])
+		}
+		if i < len(v4) {
+			addrs = append(addrs, v4[i])
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, errors.New("no addresses found for " + dnsComp.Value())
+	}
+	return addrs, nil
+}
@@ -3,14 +3,18 @@
 package tcp
 
 import (
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/transport"
+	"github.com/libp2p/go-libp2p/p2p/transport/internal/sockopt"
 	"github.com/marten-seemann/tcp"
 	"github.com/mikioh/tcpinfo"
+	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr/net"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -18,10 +22,15 @@ import (
 var (
 	newConns      *prometheus.CounterVec
 	closedConns   *prometheus.CounterVec
+	mptcpConns    *prometheus.CounterVec
+	mptcpSubflows prometheus.Counter
+	dialDurations *prometheus.HistogramVec
 	segsSentDesc  *prometheus.Desc
 	segsRcvdDesc  *prometheus.Desc
 	bytesSentDesc *prometheus.Desc
 	bytesRcvdDesc *prometheus.Desc
+
+	acceptQueueLengthDesc *prometheus.Desc
 )
 
 const collectFrequency = 10 * time.Second
@@ -35,6 +44,7 @@ func initMetrics() {
 	segsRcvdDesc = prometheus.NewDesc("tcp_rcvd_segments_total", "TCP segments received", nil, nil)
 	bytesSentDesc = prometheus.NewDesc("tcp_sent_bytes", "TCP bytes sent", nil, nil)
 	bytesRcvdDesc = prometheus.NewDesc("tcp_rcvd_bytes", "TCP bytes received", nil, nil)
+	acceptQueueLengthDesc = prometheus.NewDesc("tcp_accept_queue_length", "Inbound TCP connections that are being upgraded or wait to be accepted", nil, nil)
 
 	defaultCollector = newAggregatingCollector()
 	prometheus.MustRegister(defaultCollector)
@@ -57,16 +67,58 @@ func initMetrics() {
 		[]string{direction},
 	)
 	prometheus.MustRegister(closedConns)
+	mptcpConns = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tcp_mptcp_connections_total",
+			Help: "TCP new connections that negotiated Multipath TCP",
+		},
+		[]string{direction},
+	)
+	prometheus.MustRegister(mptcpConns)
+	mptcpSubflows = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "tcp_mptcp_subflows_established_total",
+			Help: "Additional Multipath TCP subflows established",
+		},
+	)
+	prometheus.MustRegister(mptcpSubflows)
+	dialDurations = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "tcp_dial_duration",
+			Help:    "Time to establish outbound TCP connections",
+			Buckets: prometheus.ExponentialBuckets(0.001, 1.5, 25), // 1ms to ~17s
+		},
+		[]string{"ip_version", "reuseport"},
+	)
+	prometheus.MustRegister(dialDurations)
+}
+
+// observeDial records the time it took to dial the connection c.
+func observeDial(c manet.Conn, reuseport bool, d time.Duration) {
+	initMetricsOnce.Do(func() { initMetrics() })
+	ipVersion := "other"
+	if protos := c.RemoteMultiaddr().Protocols(); len(protos) > 0 {
+		switch protos[0].Code {
+		case ma.P_IP4:
+			ipVersion = "ip4"
+		case ma.P_IP6:
+			ipVersion = "ip6"
+		}
+	}
+	dialDurations.WithLabelValues(ipVersion, strconv.FormatBool(reuseport)).Observe(d.Seconds())
 }
 
 type aggregatingCollector struct {
 	cronOnce sync.Once
 
-	mutex                sync.Mutex
-	highestID            uint64
-	conns                map[uint64] /* id */ *tracingConn
-	rtts                 prometheus.Histogram
-	connDurations        prometheus.Histogram
+	mutex         sync.Mutex
+	highestID     uint64
+	conns         map[uint64] /* id */ *tracingConn
+	rtts          prometheus.Histogram
+	connDurations prometheus.Histogram
+	// inbound connections that haven't been returned by the listener yet
+	acceptQueue          map[string]*tracingConn
+	acceptQueueDurations prometheus.Histogram
 	segsSent, segsRcvd   uint64
 	bytesSent, bytesRcvd uint64
 }
@@ -75,7 +127,8 @@ var _ prometheus.Collector = &aggregatingCollector{}
 
 func newAggregatingCollector() *aggregatingCollector {
 	c := &aggregatingCollector{
-		conns: make(map[uint64]*tracingConn),
+		conns:       make(map[uint64]*tracingConn),
+		acceptQueue: make(map[string]*tracingConn),
 		rtts: prometheus.NewHistogram(prometheus.HistogramOpts{
 			Name:    "tcp_rtt",
 			Help:    "TCP round trip time",
@@ -86,6 +139,11 @@ func newAggregatingCollector() *aggregatingCollector {
 			Help:    "TCP Connection Duration",
 			Buckets: prometheus.ExponentialBuckets(1, 1.5, 40), // 1s to ~12 weeks
 		}),
+		acceptQueueDurations: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "tcp_accept_queue_duration",
+			Help:    "Time from accepting an inbound TCP connection until it is upgraded and returned by the listener",
+			Buckets: prometheus.ExponentialBuckets(0.001, 1.5, 25), // 1ms to ~17s
+		}),
 	}
 	return c
 }
@@ -102,9 +160,31 @@ func (c *aggregatingCollector) removeConn(id uint64) {
 	delete(c.conns, id)
 }
 
+func (c *aggregatingCollector) addToAcceptQueue(t *tracingConn) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.acceptQueue[handshakeKey(t)] = t
+}
+
+// AcceptedConn records that the inbound connection c was returned by the
+// listener.
+func (c *aggregatingCollector) AcceptedConn(conn transport.CapableConn) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	key := handshakeKey(conn)
+	t, ok := c.acceptQueue[key]
+	if !ok {
+		return
+	}
+	delete(c.acceptQueue, key)
+	c.acceptQueueDurations.Observe(time.Since(t.startTime).Seconds())
+}
+
 func (c *aggregatingCollector) Describe(descs chan<- *prometheus.Desc) {
 	descs <- c.rtts.Desc()
 	descs <- c.connDurations.Desc()
+	descs <- c.acceptQueueDurations.Desc()
+	descs <- acceptQueueLengthDesc
 	if hasSegmentCounter {
 		descs <- segsSentDesc
 		descs <- segsRcvdDesc
@@ -151,7 +231,25 @@ func (c *aggregatingCollector) gatherMetrics(now time.Time) {
 		}
 		c.rtts.Observe(info.RTT.Seconds())
 		c.connDurations.Observe(now.Sub(conn.startTime).Seconds())
+		if conn.mptcp {
+			c.gatherSubflows(conn)
+		}
+	}
+}
+
+// gatherSubflows counts the subflows that were added to a Multipath TCP
+// connection since the last time its metrics were gathered. Subflows that are
+// both established and closed between two collections are not counted.
+func (c *aggregatingCollector) gatherSubflows(conn *tracingConn) {
+	n, err := conn.getMPTCPSubflows()
+	if err != nil {
+		log.Debugw("failed to get MPTCP info", "error", err)
+		return
 	}
+	if n > conn.subflows {
+		mptcpSubflows.Add(float64(n - conn.subflows))
+	}
+	conn.subflows = n
 }
 
 func (c *aggregatingCollector) Collect(metrics chan<- prometheus.Metric) {
@@ -166,6 +264,13 @@ func (c *aggregatingCollector) Collect(metrics chan<- prometheus.Metric) {
 
 	metrics <- c.rtts
 	metrics <- c.connDurations
+	metrics <- c.acceptQueueDurations
+	acceptQueueLengthMetric, err := prometheus.NewConstMetric(acceptQueueLengthDesc, prometheus.GaugeValue, float64(len(c.acceptQueue)))
+	if err != nil {
+		log.Errorf("creating tcp_accept_queue_length metric failed: %v", err)
+		return
+	}
+	metrics <- acceptQueueLengthMetric
 	if hasSegmentCounter {
 		segsSentMetric, err := prometheus.NewConstMetric(segsSentDesc, prometheus.CounterValue, float64(c.segsSent))
 		if err != nil {
@@ -199,6 +304,9 @@ func (c *aggregatingCollector) Collect(metrics chan<- prometheus.Metric) {
 func (c *aggregatingCollector) ClosedConn(conn *tracingConn, direction string) {
 	c.mutex.Lock()
 	c.removeConn(conn.id)
+	if !conn.isClient {
+		delete(c.acceptQueue, handshakeKey(conn))
+	}
 	c.mutex.Unlock()
 	closedConns.WithLabelValues(direction).Inc()
 }
@@ -210,6 +318,10 @@ type tracingConn struct {
 
 	startTime time.Time
 	isClient  bool
+	mptcp     bool
+	// number of additional MPTCP subflows seen when metrics were last
+	// gathered. Protected by the collector's mutex.
+	subflows int
 
 	manet.Conn
 	tcpConn   *tcp.Conn
@@ -227,6 +339,7 @@ func newTracingConn(c manet.Conn, collector *aggregatingCollector, isClient bool
 	tc := &tracingConn{
 		startTime: time.Now(),
 		isClient:  isClient,
+		mptcp:     isMultipathTCP(c),
 		Conn:      c,
 		tcpConn:   conn,
 		collector: collector,
@@ -235,7 +348,13 @@ func newTracingConn(c manet.Conn, collector *aggregatingCollector, isClient bool
 		tc.collector = defaultCollector
 	}
 	tc.id = tc.collector.AddConn(tc)
+	if !isClient {
+		tc.collector.addToAcceptQueue(tc)
+	}
 	newConns.WithLabelValues(tc.getDirection()).Inc()
+	if tc.mptcp {
+		mptcpConns.WithLabelValues(tc.getDirection()).Inc()
+	}
 	return tc, nil
 }
 
@@ -265,6 +384,35 @@ func (c *tracingConn) getTCPInfo() (*tcpinfo.Info, error) {
 	return info, nil
 }
 
+// SmoothedRTT returns the smoothed RTT estimated by the kernel's TCP stack.
+func (c *tracingConn) SmoothedRTT() time.Duration {
+	info, err := c.getTCPInfo()
+	if err != nil {
+		return 0
+	}
+	return info.RTT
+}
+
+func (c *tracingConn) getMPTCPSubflows() (int, error) {
+	sc, ok := c.Conn.(syscall.Conn)
+	if !ok {
+		return 0, sockopt.ErrUnsupported
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	return sockopt.MPTCPSubflows(rc)
+}
+
+// MultipathTCP reports whether the underlying connection uses Multipath TCP.
+func (c *tracingConn) MultipathTCP() (bool, error) {
+	if mc, ok := c.Conn.(canMultipathTCP); ok {
+		return mc.MultipathTCP()
+	}
+	return false, nil
+}
+
 type tracingListener struct {
 	transport.GatedMaListener
 	collector *aggregatingCollector
@@ -275,6 +423,31 @@ func newTracingListener(l transport.GatedMaListener, collector *aggregatingColle
 	return &tracingListener{GatedMaListener: l, collector: collector}
 }
 
+// tracingUpgradedListener records when inbound connections are returned by
+// the upgrader's listener.
+type tracingUpgradedListener struct {
+	transport.Listener
+	collector *aggregatingCollector
+}
+
+// newTracingUpgradedListener wraps an upgraded listener. A nil collector will use the default collector.
+func newTracingUpgradedListener(l transport.Listener, collector *aggregatingCollector) *tracingUpgradedListener {
+	initMetricsOnce.Do(func() { initMetrics() })
+	if collector == nil {
+		collector = defaultCollector
+	}
+	return &tracingUpgradedListener{Listener: l, collector: collector}
+}
+
+func (l *tracingUpgradedListener) Accept() (transport.CapableConn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	l.collector.AcceptedConn(c)
+	return c, nil
+}
+
 func (l *tracingListener) Accept() (manet.Conn, network.ConnManagementScope, error) {
 	conn, scope, err := l.GatedMaListener.Accept()
 	if err != nil {
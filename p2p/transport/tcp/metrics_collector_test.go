@@ -0,0 +1,53 @@
+//go:build !windows && !riscv64 && !loong64
+
+package tcp
+
+import (
+	"context"
+	"testing"
+
+	tptu "github.com/libp2p/go-libp2p/p2p/net/upgrader"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialAndAcceptQueueMetrics(t *testing.T) {
+	peerA, ia := makeInsecureMuxer(t)
+	ua, err := tptu.New(ia, muxers, nil, nil, nil)
+	require.NoError(t, err)
+	ta, err := NewTCPTransport(ua, nil, nil, WithMetrics())
+	require.NoError(t, err)
+	ln, err := ta.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer ln.Close()
+
+	_, ib := makeInsecureMuxer(t)
+	ub, err := tptu.New(ib, muxers, nil, nil, nil)
+	require.NoError(t, err)
+	tb, err := NewTCPTransport(ub, nil, nil, WithMetrics(), DisableReuseport())
+	require.NoError(t, err)
+
+	conn, err := tb.Dial(context.Background(), ln.Multiaddr(), peerA)
+	require.NoError(t, err)
+	defer conn.Close()
+	c, err := ln.Accept()
+	require.NoError(t, err)
+	defer c.Close()
+
+	dialCount := func() uint64 {
+		m := &dto.Metric{}
+		require.NoError(t, dialDurations.WithLabelValues("ip4", "false").(prometheus.Histogram).Write(m))
+		return m.GetHistogram().GetSampleCount()
+	}
+	require.NotZero(t, dialCount())
+
+	m := &dto.Metric{}
+	require.NoError(t, defaultCollector.acceptQueueDurations.Write(m))
+	require.NotZero(t, m.GetHistogram().GetSampleCount())
+	defaultCollector.mutex.Lock()
+	require.NotContains(t, defaultCollector.acceptQueue, handshakeKey(c))
+	defaultCollector.mutex.Unlock()
+}
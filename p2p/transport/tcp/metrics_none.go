@@ -5,6 +5,8 @@
 package tcp
 
 import (
+	"time"
+
 	"github.com/libp2p/go-libp2p/core/transport"
 	manet "github.com/multiformats/go-multiaddr/net"
 )
@@ -17,3 +19,7 @@ func newTracingConn(c manet.Conn, collector *aggregatingCollector, isClient bool
 func newTracingListener(l transport.GatedMaListener, collector *aggregatingCollector) transport.GatedMaListener {
 	return l
 }
+func newTracingUpgradedListener(l transport.Listener, collector *aggregatingCollector) transport.Listener {
+	return l
+}
+func observeDial(c manet.Conn, reuseport bool, d time.Duration) {}
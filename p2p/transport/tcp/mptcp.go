@@ -0,0 +1,85 @@
+package tcp
+
+import (
+	"net"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/transport"
+
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+type statMultipathTCP struct{}
+
+// StatMultipathTCP is the key in network.ConnStats.Extra under which the
+// transport reports whether a connection negotiated Multipath TCP. The value
+// is a bool. It is only set if Multipath TCP was configured using
+// WithMultipathTCP.
+var StatMultipathTCP = statMultipathTCP{}
+
+type mptcpConfig struct {
+	listen, dial bool
+}
+
+type canMultipathTCP interface {
+	MultipathTCP() (bool, error)
+}
+
+var _ canMultipathTCP = &net.TCPConn{}
+
+// isMultipathTCP returns true if the connection negotiated Multipath TCP.
+func isMultipathTCP(c net.Conn) bool {
+	mc, ok := c.(canMultipathTCP)
+	if !ok {
+		return false
+	}
+	used, err := mc.MultipathTCP()
+	return err == nil && used
+}
+
+// mptcpConn reports in its stats whether the connection negotiated Multipath
+// TCP.
+type mptcpConn struct {
+	manet.Conn
+	stat network.ConnStats
+}
+
+var _ network.ConnStat = &mptcpConn{}
+var _ network.ConnRTT = &mptcpConn{}
+
+func newMPTCPConn(c manet.Conn) *mptcpConn {
+	return &mptcpConn{
+		Conn: c,
+		stat: network.ConnStats{
+			Stats: network.Stats{
+				Extra: map[interface{}]interface{}{StatMultipathTCP: isMultipathTCP(c)},
+			},
+		},
+	}
+}
+
+func (c *mptcpConn) Stat() network.ConnStats {
+	return c.stat
+}
+
+// SmoothedRTT returns the RTT of the wrapped connection, which is only
+// available if metrics are enabled.
+func (c *mptcpConn) SmoothedRTT() time.Duration {
+	if rc, ok := c.Conn.(network.ConnRTT); ok {
+		return rc.SmoothedRTT()
+	}
+	return 0
+}
+
+type mptcpListener struct {
+	transport.GatedMaListener
+}
+
+func (l *mptcpListener) Accept() (manet.Conn, network.ConnManagementScope, error) {
+	c, scope, err := l.GatedMaListener.Accept()
+	if err != nil {
+		return nil, nil, err
+	}
+	return newMPTCPConn(c), scope, nil
+}
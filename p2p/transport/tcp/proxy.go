@@ -0,0 +1,23 @@
+package tcp
+
+import (
+	"errors"
+	"net"
+	"net/url"
+
+	"golang.org/x/net/proxy"
+)
+
+// newProxyDialer returns a ContextDialer that dials through the SOCKS5 proxy
+// at u, using forward to connect to the proxy.
+func newProxyDialer(u *url.URL, forward *net.Dialer) (ContextDialer, error) {
+	d, err := proxy.FromURL(u, forward)
+	if err != nil {
+		return nil, err
+	}
+	cd, ok := d.(proxy.ContextDialer)
+	if !ok {
+		return nil, errors.New("proxy dialer doesn't support dialing with a context")
+	}
+	return cd, nil
+}
@@ -0,0 +1,145 @@
+package tcp
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+
+	tptu "github.com/libp2p/go-libp2p/p2p/net/upgrader"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/stretchr/testify/require"
+)
+
+// startSOCKS5Server starts a minimal SOCKS5 server supporting the CONNECT
+// command without authentication. It reports the requested destinations on
+// the returned channel.
+func startSOCKS5Server(t *testing.T) (net.Listener, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	dests := make(chan string, 10)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				dest, err := socks5Handshake(c)
+				if err != nil {
+					return
+				}
+				dests <- dest
+				target, err := net.Dial("tcp", dest)
+				if err != nil {
+					c.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+					return
+				}
+				defer target.Close()
+				c.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 0})
+				go io.Copy(target, c)
+				io.Copy(c, target)
+			}()
+		}
+	}()
+	return ln, dests
+}
+
+func socks5Handshake(c net.Conn) (string, error) {
+	hdr := make([]byte, 2)
+	if _, err := io.ReadFull(c, hdr); err != nil {
+		return "", err
+	}
+	if _, err := io.ReadFull(c, make([]byte, hdr[1])); err != nil {
+		return "", err
+	}
+	if _, err := c.Write([]byte{5, 0}); err != nil {
+		return "", err
+	}
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(c, req); err != nil {
+		return "", err
+	}
+	var host string
+	switch req[3] {
+	case 1:
+		ip := make([]byte, 4)
+		if _, err := io.ReadFull(c, ip); err != nil {
+			return "", err
+		}
+		host = net.IP(ip).String()
+	case 3:
+		l := make([]byte, 1)
+		if _, err := io.ReadFull(c, l); err != nil {
+			return "", err
+		}
+		name := make([]byte, l[0])
+		if _, err := io.ReadFull(c, name); err != nil {
+			return "", err
+		}
+		host = string(name)
+	case 4:
+		ip := make([]byte, 16)
+		if _, err := io.ReadFull(c, ip); err != nil {
+			return "", err
+		}
+		host = net.IP(ip).String()
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(c, port); err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+func TestDialThroughProxy(t *testing.T) {
+	proxyLn, dests := startSOCKS5Server(t)
+
+	peerA, ia := makeInsecureMuxer(t)
+	ua, err := tptu.New(ia, muxers, nil, nil, nil)
+	require.NoError(t, err)
+	ta, err := NewTCPTransport(ua, nil, nil)
+	require.NoError(t, err)
+	ln, err := ta.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer ln.Close()
+	port, err := ln.Multiaddr().ValueForProtocol(ma.P_TCP)
+	require.NoError(t, err)
+
+	_, ib := makeInsecureMuxer(t)
+	ub, err := tptu.New(ib, muxers, nil, nil, nil)
+	require.NoError(t, err)
+	tb, err := NewTCPTransport(ub, nil, nil, WithProxy("socks5://"+proxyLn.Addr().String()))
+	require.NoError(t, err)
+
+	t.Run("ip address", func(t *testing.T) {
+		conn, err := tb.Dial(context.Background(), ln.Multiaddr(), peerA)
+		require.NoError(t, err)
+		defer conn.Close()
+		require.Equal(t, "127.0.0.1:"+port, <-dests)
+		require.True(t, conn.RemoteMultiaddr().Equal(ln.Multiaddr()))
+	})
+
+	t.Run("dns address", func(t *testing.T) {
+		dnsa := ma.StringCast("/dns4/localhost/tcp/" + port)
+		require.True(t, tb.CanDial(dnsa))
+		require.True(t, tb.SkipResolve(context.Background(), dnsa))
+		conn, err := tb.Dial(context.Background(), dnsa, peerA)
+		require.NoError(t, err)
+		defer conn.Close()
+		// the name must be resolved by the proxy
+		require.Equal(t, "localhost:"+port, <-dests)
+	})
+}
+
+func TestInvalidProxy(t *testing.T) {
+	_, err := NewTCPTransport(nil, nil, nil, WithProxy("http://127.0.0.1:8080"))
+	require.Error(t, err)
+}
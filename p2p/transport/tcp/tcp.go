@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/url"
 	"os"
 	"runtime"
 	"syscall"
@@ -14,7 +15,9 @@ import (
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/libp2p/go-libp2p/core/transport"
 	"github.com/libp2p/go-libp2p/p2p/net/reuseport"
+	"github.com/libp2p/go-libp2p/p2p/transport/internal/sockopt"
 	"github.com/libp2p/go-libp2p/p2p/transport/tcpreuse"
+	"github.com/libp2p/go-libp2p/x/rate"
 
 	logging "github.com/ipfs/go-log/v2"
 	ma "github.com/multiformats/go-multiaddr"
@@ -24,6 +27,10 @@ import (
 
 const defaultConnectTimeout = 5 * time.Second
 
+// defaultHappyEyeballsDelay is the "Connection Attempt Delay" recommended by
+// RFC 8305.
+const defaultHappyEyeballsDelay = 250 * time.Millisecond
+
 var log = logging.Logger("tcp-tpt")
 
 const keepAlivePeriod = 30 * time.Second
@@ -35,10 +42,30 @@ type canKeepAlive interface {
 
 var _ canKeepAlive = &net.TCPConn{}
 
+type canKeepAliveConfig interface {
+	SetKeepAliveConfig(net.KeepAliveConfig) error
+}
+
+var _ canKeepAliveConfig = &net.TCPConn{}
+
 // Deprecated: Use tcpreuse.ReuseportIsAvailable
 var ReuseportIsAvailable = tcpreuse.ReuseportIsAvailable
 
-func tryKeepAlive(conn net.Conn, keepAlive bool) {
+func tryKeepAlive(conn net.Conn, keepAlive bool, cfg *net.KeepAliveConfig) {
+	if cfg != nil && keepAlive {
+		if cfgConn, ok := conn.(canKeepAliveConfig); ok {
+			c := *cfg
+			c.Enable = true
+			if err := cfgConn.SetKeepAliveConfig(c); err != nil {
+				if errors.Is(err, os.ErrInvalid) || errors.Is(err, syscall.EINVAL) {
+					log.Debugw("failed to configure TCP keepalive", "error", err)
+				} else {
+					log.Errorw("failed to configure TCP keepalive", "error", err)
+				}
+			}
+			return
+		}
+	}
 	keepAliveConn, ok := conn.(canKeepAlive)
 	if !ok {
 		log.Errorf("can't set TCP keepalives. net.Conn of type %T doesn't support SetKeepAlive", conn)
@@ -76,23 +103,50 @@ func tryLinger(conn net.Conn, sec int) {
 	}
 }
 
+// tryUserTimeout sets TCP_USER_TIMEOUT on the connection, if configured.
+func tryUserTimeout(conn net.Conn, timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+	if err := sockopt.SetConnUserTimeout(conn, timeout); err != nil {
+		log.Debugw("failed to set TCP user timeout", "error", err)
+	}
+}
+
 type tcpGatedMaListener struct {
 	transport.GatedMaListener
-	sec int
+	sec           int
+	keepAlive     *net.KeepAliveConfig
+	userTimeout   time.Duration
+	acceptLimiter *rate.Limiter
+	handshakes    *handshakeTracker
 }
 
 func (ll *tcpGatedMaListener) Accept() (manet.Conn, network.ConnManagementScope, error) {
-	c, scope, err := ll.GatedMaListener.Accept()
-	if err != nil {
-		if scope != nil {
-			log.Errorf("BUG: got non-nil scope but also an error: %s", err)
+	for {
+		c, scope, err := ll.GatedMaListener.Accept()
+		if err != nil {
+			if scope != nil {
+				log.Errorf("BUG: got non-nil scope but also an error: %s", err)
+				scope.Done()
+			}
+			return nil, nil, err
+		}
+		if !ll.allowAccept(c) {
+			log.Debugw("accept rate limit exceeded", "remote", c.RemoteMultiaddr())
+			tryLinger(c, 0)
+			c.Close()
 			scope.Done()
+			continue
+		}
+		tryLinger(c, ll.sec)
+		tryKeepAlive(c, true, ll.keepAlive)
+		tryUserTimeout(c, ll.userTimeout)
+		if ll.handshakes != nil {
+			ll.handshakes.Start(c)
 		}
-		return nil, nil, err
+		return c, scope, nil
 	}
-	tryLinger(c, ll.sec)
-	tryKeepAlive(c, true)
-	return c, scope, nil
 }
 
 type Option func(*TcpTransport) error
@@ -118,6 +172,180 @@ func WithMetrics() Option {
 	}
 }
 
+// WithKeepAlive configures the TCP keepalive parameters of all connections
+// created by the transport. idle is the time a connection needs to be idle
+// before the first probe is sent, interval is the time between probes, and
+// count is the number of unanswered probes after which the connection is
+// considered dead.
+// Zero and negative values are interpreted as described in
+// net.KeepAliveConfig.
+// Without this option, the transport only sets the keepalive period to 30s.
+func WithKeepAlive(idle, interval time.Duration, count int) Option {
+	return func(tr *TcpTransport) error {
+		tr.keepAlive = &net.KeepAliveConfig{
+			Enable:   true,
+			Idle:     idle,
+			Interval: interval,
+			Count:    count,
+		}
+		return nil
+	}
+}
+
+// WithUserTimeout sets TCP_USER_TIMEOUT on all connections created by the
+// transport: a connection is closed if transmitted data remains
+// unacknowledged for longer than timeout, instead of waiting for the
+// retransmission timeouts to expire, which can take many minutes.
+// Note that keepalive probes are subject to the user timeout as well. This is
+// only supported on Linux.
+func WithUserTimeout(timeout time.Duration) Option {
+	return func(tr *TcpTransport) error {
+		if !sockopt.UserTimeoutSupported {
+			return errors.New("TCP_USER_TIMEOUT is only supported on Linux")
+		}
+		if timeout <= 0 {
+			return errors.New("TCP user timeout must be positive")
+		}
+		tr.userTimeout = timeout
+		return nil
+	}
+}
+
+// WithAcceptRateLimiter rate limits the connections accepted by the
+// transport's listeners, for example per remote subnet. Connections exceeding
+// the limits are reset right after they are accepted, before the upgrader
+// starts the security handshake.
+func WithAcceptRateLimiter(l *rate.Limiter) Option {
+	return func(tr *TcpTransport) error {
+		tr.acceptLimiter = l
+		return nil
+	}
+}
+
+// WithHandshakeTimeout sets the time an inbound connection has to complete the
+// security and muxer handshakes, and to be accepted from the listener.
+// Connections that take longer are reset. This bounds the resources that
+// slow or malicious peers can hold before they are authenticated.
+func WithHandshakeTimeout(timeout time.Duration) Option {
+	return func(tr *TcpTransport) error {
+		if timeout <= 0 {
+			return errors.New("handshake timeout must be positive")
+		}
+		tr.handshakeTimeout = timeout
+		return nil
+	}
+}
+
+// WithProxy makes the transport tunnel all outbound connections through the
+// proxy at the given URL. Supported schemes are socks5 and socks5h, user
+// credentials can be passed in the URL.
+// DNS addresses are not resolved locally, but passed to the proxy, so that
+// name resolution happens on the proxy's side.
+// The proxy is not used if a dialer is set using WithDialerForAddr.
+func WithProxy(proxyURL string) Option {
+	return func(tr *TcpTransport) error {
+		u, err := url.Parse(proxyURL)
+		if err != nil {
+			return fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		if u.Scheme != "socks5" && u.Scheme != "socks5h" {
+			return fmt.Errorf("unsupported proxy scheme: %s", u.Scheme)
+		}
+		tr.proxyURL = u
+		return nil
+	}
+}
+
+// WithSocketMark sets SO_MARK on all outbound TCP sockets, so that traffic can
+// be routed or policed using fwmark based rules. This requires the
+// CAP_NET_ADMIN capability, and is only supported on Linux.
+// Since the mark needs to be set before the socket is connected, outbound
+// connections won't reuse the listening port.
+func WithSocketMark(mark int) Option {
+	return func(tr *TcpTransport) error {
+		if !sockopt.MarkSupported {
+			return errors.New("SO_MARK is only supported on Linux")
+		}
+		tr.socketMark = &mark
+		return nil
+	}
+}
+
+// WithBindToInterface binds all outbound sockets to the network interface with
+// the given name, using SO_BINDTODEVICE. This may require the CAP_NET_RAW
+// capability, and is only supported on Linux.
+// A SourceAddressSelector can override the interface for specific
+// destinations.
+func WithBindToInterface(name string) Option {
+	return func(tr *TcpTransport) error {
+		if !sockopt.BindToDeviceSupported {
+			return errors.New("binding to an interface is only supported on Linux")
+		}
+		tr.bindInterface = name
+		return nil
+	}
+}
+
+// WithSourceAddressSelector sets a function that selects the local address and
+// interface outbound connections are dialed from, depending on the
+// destination. If listening ports are reused, the port of a listener on the
+// selected address is used.
+// The selector is not used for DNS addresses dialed using Happy Eyeballs, or
+// for connections through a proxy.
+func WithSourceAddressSelector(sel SourceAddressSelector) Option {
+	return func(tr *TcpTransport) error {
+		tr.sourceAddressSelector = sel
+		return nil
+	}
+}
+
+// WithMultipathTCP enables or disables Multipath TCP (RFC 8684) on listeners
+// and on outbound connections respectively. Connections fall back to regular
+// TCP if the peer or the local kernel doesn't support Multipath TCP.
+// Whether a connection negotiated Multipath TCP is reported in its stats
+// under StatMultipathTCP.
+// Listeners shared with other transports are not affected. Since the option
+// needs to be set when the socket is created, listeners don't use reuseport
+// and outbound connections don't reuse the listening port.
+func WithMultipathTCP(listen, dial bool) Option {
+	return func(tr *TcpTransport) error {
+		tr.mptcp = &mptcpConfig{listen: listen, dial: dial}
+		return nil
+	}
+}
+
+// WithHappyEyeballs makes the transport dial /dns, /dns4 and /dns6 TCP
+// addresses itself, instead of relying on the swarm to resolve them.
+// If a name resolves to both IPv6 and IPv4 addresses, the dials are raced as
+// described in RFC 8305: the addresses are tried alternating between IPv6 and
+// IPv4, starting with IPv6, a new attempt is started every delay or as soon as
+// an attempt fails, the first connection to succeed is used and the other
+// attempts are canceled. Like other dials, the attempts reuse the listening
+// port if reuseport is enabled.
+// If delay is zero or negative, the 250ms delay recommended by the RFC is used.
+// Names are resolved with the resolver set by WithResolver, or with the
+// system resolver if none is set.
+func WithHappyEyeballs(delay time.Duration) Option {
+	return func(tr *TcpTransport) error {
+		if delay <= 0 {
+			delay = defaultHappyEyeballsDelay
+		}
+		tr.happyEyeballs = true
+		tr.happyEyeballsDelay = delay
+		return nil
+	}
+}
+
+// WithResolver sets the resolver used to resolve DNS addresses when dialing
+// with Happy Eyeballs. Pass the resolver the host was configured with, so that
+// these dials resolve names the same way as the swarm does.
+func WithResolver(r network.MultiaddrDNSResolver) Option {
+	return func(tr *TcpTransport) error {
+		tr.resolver = r
+		return nil
+	}
+}
+
 // WithDialerForAddr sets a custom dialer for the given address.
 // If set, it will be the *ONLY* dialer used.
 func WithDialerForAddr(d DialerForAddr) Option {
@@ -127,6 +355,26 @@ func WithDialerForAddr(d DialerForAddr) Option {
 	}
 }
 
+// DialSource is the local endpoint an outbound connection is dialed from.
+type DialSource struct {
+	// IP is the local IP address to dial from. It must be of the same address
+	// family as the destination. If nil, the operating system picks the
+	// address.
+	IP net.IP
+	// Interface is the name of the network interface the socket is bound to.
+	// If empty, the interface set using WithBindToInterface is used, if any.
+	// Binding to an interface is only supported on Linux.
+	Interface string
+}
+
+func (s DialSource) isSet() bool {
+	return s.IP != nil || s.Interface != ""
+}
+
+// SourceAddressSelector returns the local endpoint used to dial raddr.
+// Returning the zero DialSource leaves the choice to the operating system.
+type SourceAddressSelector func(raddr ma.Multiaddr) DialSource
+
 type ContextDialer interface {
 	DialContext(ctx context.Context, network, address string) (net.Conn, error)
 }
@@ -156,6 +404,38 @@ type TcpTransport struct {
 	// TCP connect timeout
 	connectTimeout time.Duration
 
+	// TCP keepalive parameters. If nil, only the keepalive period is set.
+	keepAlive *net.KeepAliveConfig
+
+	// TCP_USER_TIMEOUT set on all connections, if positive
+	userTimeout time.Duration
+
+	// rate limits accepted connections, if set
+	acceptLimiter *rate.Limiter
+	// time inbound connections have to be upgraded, if positive
+	handshakeTimeout time.Duration
+
+	// proxy used for all outbound connections, if any
+	proxyURL    *url.URL
+	proxyDialer ContextDialer
+
+	// SO_MARK set on outbound sockets, if any
+	socketMark *int
+
+	// network interface outbound sockets are bound to, if any
+	bindInterface string
+	// selects the local address and interface to dial from, if set
+	sourceAddressSelector SourceAddressSelector
+
+	// Multipath TCP settings. If nil, the Go defaults are used.
+	mptcp *mptcpConfig
+
+	// dial DNS addresses by racing IPv6 and IPv4 connection attempts
+	happyEyeballs      bool
+	happyEyeballsDelay time.Duration
+	// resolves DNS addresses for Happy Eyeballs, if set
+	resolver network.MultiaddrDNSResolver
+
 	rcmgr network.ResourceManager
 
 	reuse reuseport.Transport
@@ -165,6 +445,8 @@ type TcpTransport struct {
 
 var _ transport.Transport = &TcpTransport{}
 var _ transport.DialUpdater = &TcpTransport{}
+var _ transport.SkipResolver = &TcpTransport{}
+var _ transport.CapabilityProvider = &TcpTransport{}
 
 // NewTCPTransport creates a tcp transport object that tracks dialers and listeners
 // created.
@@ -183,23 +465,70 @@ func NewTCPTransport(upgrader transport.Upgrader, rcmgr network.ResourceManager,
 			return nil, err
 		}
 	}
+	if tr.proxyURL != nil {
+		d, err := newProxyDialer(tr.proxyURL, tr.proxyForwardDialer())
+		if err != nil {
+			return nil, err
+		}
+		tr.proxyDialer = d
+	}
 	return tr, nil
 }
 
 var dialMatcher = mafmt.And(mafmt.IP, mafmt.Base(ma.P_TCP))
+var dnsDialMatcher = mafmt.And(mafmt.DNS, mafmt.Base(ma.P_TCP))
 
 // CanDial returns true if this transport believes it can dial the given
 // multiaddr.
 func (t *TcpTransport) CanDial(addr ma.Multiaddr) bool {
+	if t.dialsDNS() && dnsDialMatcher.Matches(addr) {
+		return true
+	}
 	return dialMatcher.Matches(addr)
 }
 
-func (t *TcpTransport) customDial(ctx context.Context, raddr ma.Multiaddr) (manet.Conn, error) {
-	// get the net.Dial friendly arguments from the remote addr
+// SkipResolve implements transport.SkipResolver. If Happy Eyeballs dialing or
+// a proxy is enabled, DNS addresses are resolved at dial time by the
+// transport or the proxy respectively.
+func (t *TcpTransport) SkipResolve(_ context.Context, maddr ma.Multiaddr) bool {
+	return t.dialsDNS() && dnsDialMatcher.Matches(maddr)
+}
+
+// dialsDNS returns true if DNS addresses are dialed without resolving them
+// first.
+func (t *TcpTransport) dialsDNS() bool {
+	return t.happyEyeballs || t.proxyDialer != nil
+}
+
+// proxiedConn is a connection dialed through a proxy. It reports the dialed
+// address as the remote address, instead of the address of the proxy.
+type proxiedConn struct {
+	manet.Conn
+	raddr ma.Multiaddr
+}
+
+func (c *proxiedConn) RemoteMultiaddr() ma.Multiaddr {
+	return c.raddr
+}
+
+func (t *TcpTransport) proxyDial(ctx context.Context, raddr ma.Multiaddr) (manet.Conn, error) {
 	rnet, rnaddr, err := manet.DialArgs(raddr)
 	if err != nil {
 		return nil, err
 	}
+	nconn, err := t.proxyDialer.DialContext(ctx, rnet, rnaddr)
+	if err != nil {
+		return nil, err
+	}
+	c, err := manet.WrapNetConn(nconn)
+	if err != nil {
+		nconn.Close()
+		return nil, err
+	}
+	return &proxiedConn{Conn: c, raddr: raddr}, nil
+}
+
+func (t *TcpTransport) customDial(ctx context.Context, raddr ma.Multiaddr) (manet.Conn, error) {
 	dialer, err := t.overrideDialerForAddr(raddr)
 	if err != nil {
 		return nil, err
@@ -207,6 +536,15 @@ func (t *TcpTransport) customDial(ctx context.Context, raddr ma.Multiaddr) (mane
 	if dialer == nil {
 		return nil, fmt.Errorf("dialer for address %s is nil", raddr)
 	}
+	return dialWithDialer(ctx, dialer, raddr)
+}
+
+func dialWithDialer(ctx context.Context, dialer ContextDialer, raddr ma.Multiaddr) (manet.Conn, error) {
+	// get the net.Dial friendly arguments from the remote addr
+	rnet, rnaddr, err := manet.DialArgs(raddr)
+	if err != nil {
+		return nil, err
+	}
 
 	// ok, Dial!
 	var nconn net.Conn
@@ -223,6 +561,63 @@ func (t *TcpTransport) customDial(ctx context.Context, raddr ma.Multiaddr) (mane
 	return manet.WrapNetConn(nconn)
 }
 
+// netDialer returns a net.Dialer that applies the socket options configured
+// on the transport.
+func (t *TcpTransport) netDialer() *net.Dialer {
+	return t.netDialerFrom(DialSource{Interface: t.bindInterface})
+}
+
+// netDialerFrom returns a net.Dialer that applies the socket options
+// configured on the transport, and dials from src.
+func (t *TcpTransport) netDialerFrom(src DialSource) *net.Dialer {
+	d := &net.Dialer{}
+	if t.socketMark != nil {
+		d.Control = sockopt.MarkControl(*t.socketMark)
+	}
+	if src.Interface != "" {
+		d.Control = sockopt.ChainControl(d.Control, sockopt.BindToDeviceControl(src.Interface))
+	}
+	if src.IP != nil {
+		d.LocalAddr = &net.TCPAddr{IP: src.IP}
+	}
+	if t.mptcp != nil {
+		d.SetMultipathTCP(t.mptcp.dial)
+	}
+	return d
+}
+
+// proxyForwardDialer returns the dialer used to connect to the proxy.
+func (t *TcpTransport) proxyForwardDialer() *net.Dialer {
+	d := t.netDialer()
+	if t.keepAlive != nil {
+		d.KeepAliveConfig = *t.keepAlive
+	} else {
+		d.KeepAlive = keepAlivePeriod
+	}
+	if t.userTimeout > 0 {
+		d.Control = sockopt.ChainControl(d.Control, sockopt.UserTimeoutControl(t.userTimeout))
+	}
+	return d
+}
+
+// dialSource returns the local endpoint to dial raddr from.
+func (t *TcpTransport) dialSource(raddr ma.Multiaddr) DialSource {
+	var src DialSource
+	if t.sourceAddressSelector != nil {
+		src = t.sourceAddressSelector(raddr)
+	}
+	if src.Interface == "" {
+		src.Interface = t.bindInterface
+	}
+	return src
+}
+
+// needsCustomSocket returns true if outbound sockets need options that can't
+// be applied when dialing from the listening port.
+func (t *TcpTransport) needsCustomSocket() bool {
+	return t.socketMark != nil || t.mptcp != nil
+}
+
 func (t *TcpTransport) maDial(ctx context.Context, raddr ma.Multiaddr) (manet.Conn, error) {
 	// Apply the deadline iff applicable
 	if t.connectTimeout > 0 {
@@ -235,6 +630,37 @@ func (t *TcpTransport) maDial(ctx context.Context, raddr ma.Multiaddr) (manet.Co
 		return t.customDial(ctx, raddr)
 	}
 
+	if t.proxyDialer != nil {
+		return t.proxyDial(ctx, raddr)
+	}
+
+	if t.happyEyeballs && dnsDialMatcher.Matches(raddr) {
+		return t.happyEyeballsDial(ctx, raddr)
+	}
+	return t.dialResolved(ctx, raddr)
+}
+
+// dialResolved dials an IP address, from the listening port if possible.
+func (t *TcpTransport) dialResolved(ctx context.Context, raddr ma.Multiaddr) (manet.Conn, error) {
+	src := t.dialSource(raddr)
+	if src.isSet() && !t.needsCustomSocket() {
+		var control func(network, address string, c syscall.RawConn) error
+		if src.Interface != "" {
+			control = sockopt.BindToDeviceControl(src.Interface)
+		}
+		if t.sharedTcp != nil {
+			return t.sharedTcp.DialContextFrom(ctx, raddr, src.IP, control)
+		}
+		if t.UseReuseport() {
+			return t.reuse.DialContextFrom(ctx, raddr, src.IP, control)
+		}
+	}
+
+	if src.isSet() || t.needsCustomSocket() {
+		d := manet.Dialer{Dialer: *t.netDialerFrom(src)}
+		return d.DialContext(ctx, raddr)
+	}
+
 	if t.sharedTcp != nil {
 		return t.sharedTcp.DialContext(ctx, raddr)
 	}
@@ -271,15 +697,25 @@ func (t *TcpTransport) dialWithScope(ctx context.Context, raddr ma.Multiaddr, p
 		log.Debugw("resource manager blocked outgoing connection for peer", "peer", p, "addr", raddr, "error", err)
 		return nil, err
 	}
+	start := time.Now()
 	conn, err := t.maDial(ctx, raddr)
 	if err != nil {
 		return nil, err
 	}
+	connectTime := time.Since(start)
+	if t.enableMetrics {
+		observeDial(conn, t.dialUsesReuseport(raddr), connectTime)
+	}
 	// Set linger to 0 so we never get stuck in the TIME-WAIT state. When
 	// linger is 0, connections are _reset_ instead of closed with a FIN.
 	// This means we can immediately reuse the 5-tuple and reconnect.
-	tryLinger(conn, 0)
-	tryKeepAlive(conn, true)
+	// Keepalives and the user timeout on proxied connections are configured by
+	// the proxy dialer.
+	if _, ok := conn.(*proxiedConn); !ok {
+		tryLinger(conn, 0)
+		tryKeepAlive(conn, true, t.keepAlive)
+		tryUserTimeout(conn, t.userTimeout)
+	}
 	c := conn
 	if t.enableMetrics {
 		var err error
@@ -288,6 +724,10 @@ func (t *TcpTransport) dialWithScope(ctx context.Context, raddr ma.Multiaddr, p
 			return nil, err
 		}
 	}
+	if t.mptcp != nil {
+		c = newMPTCPConn(c)
+	}
+	c = &dialedConn{Conn: c, connectTime: connectTime}
 	if updateChan != nil {
 		select {
 		case updateChan <- transport.DialUpdate{Kind: transport.UpdateKindHandshakeProgressed, Addr: raddr}:
@@ -302,12 +742,63 @@ func (t *TcpTransport) dialWithScope(ctx context.Context, raddr ma.Multiaddr, p
 	return t.upgrader.Upgrade(ctx, t, c, direction, p, connScope)
 }
 
+// dialedConn reports the time it took to establish an outbound connection in
+// its stats.
+type dialedConn struct {
+	manet.Conn
+	connectTime time.Duration
+}
+
+var _ network.ConnStat = &dialedConn{}
+var _ network.ConnRTT = &dialedConn{}
+
+func (c *dialedConn) Stat() network.ConnStats {
+	var stat network.ConnStats
+	if cs, ok := c.Conn.(network.ConnStat); ok {
+		stat = cs.Stat()
+	}
+	stat.Handshake.Connect = c.connectTime
+	return stat
+}
+
+func (c *dialedConn) SmoothedRTT() time.Duration {
+	if rc, ok := c.Conn.(network.ConnRTT); ok {
+		return rc.SmoothedRTT()
+	}
+	return 0
+}
+
+// dialUsesReuseport returns true if dialing raddr tries to reuse the port of
+// a listener.
+func (t *TcpTransport) dialUsesReuseport(raddr ma.Multiaddr) bool {
+	if t.overrideDialerForAddr != nil || t.proxyDialer != nil || t.needsCustomSocket() {
+		return false
+	}
+	if t.sharedTcp != nil {
+		return t.sharedTcp.UseReuseport()
+	}
+	return t.UseReuseport()
+}
+
 // UseReuseport returns true if reuseport is enabled and available.
 func (t *TcpTransport) UseReuseport() bool {
 	return !t.disableReuseport && tcpreuse.ReuseportIsAvailable()
 }
 
 func (t *TcpTransport) unsharedMAListen(laddr ma.Multiaddr) (manet.Listener, error) {
+	if t.mptcp != nil {
+		lnet, lnaddr, err := manet.DialArgs(laddr)
+		if err != nil {
+			return nil, err
+		}
+		var lc net.ListenConfig
+		lc.SetMultipathTCP(t.mptcp.listen)
+		nl, err := lc.Listen(context.Background(), lnet, lnaddr)
+		if err != nil {
+			return nil, err
+		}
+		return manet.WrapNetListener(nl)
+	}
 	if t.UseReuseport() {
 		return t.reuse.Listen(laddr)
 	}
@@ -332,15 +823,33 @@ func (t *TcpTransport) Listen(laddr ma.Multiaddr) (transport.Listener, error) {
 	}
 
 	// Always wrap the listener with tcpGatedMaListener to apply TCP-specific configurations
-	tcpList := &tcpGatedMaListener{list, 0}
+	tcpList := &tcpGatedMaListener{
+		GatedMaListener: list,
+		sec:             0,
+		keepAlive:       t.keepAlive,
+		userTimeout:     t.userTimeout,
+		acceptLimiter:   t.acceptLimiter,
+	}
+	if t.handshakeTimeout > 0 {
+		tcpList.handshakes = newHandshakeTracker(t.handshakeTimeout)
+	}
+	list = tcpList
 
 	if t.enableMetrics {
 		// Wrap with tracing listener if metrics are enabled
-		return t.upgrader.UpgradeGatedMaListener(t, newTracingListener(tcpList, t.metricsCollector)), nil
+		list = newTracingListener(list, t.metricsCollector)
 	}
-
-	// Regular path without metrics
-	return t.upgrader.UpgradeGatedMaListener(t, tcpList), nil
+	if t.mptcp != nil && t.sharedTcp == nil {
+		list = &mptcpListener{list}
+	}
+	l := t.upgrader.UpgradeGatedMaListener(t, list)
+	if t.enableMetrics {
+		l = newTracingUpgradedListener(l, t.metricsCollector)
+	}
+	if tcpList.handshakes != nil {
+		l = &handshakeTimeoutListener{Listener: l, tracker: tcpList.handshakes}
+	}
+	return l, nil
 }
 
 // Protocols returns the list of terminal protocols this transport can dial.
@@ -353,6 +862,11 @@ func (t *TcpTransport) Proxy() bool {
 	return false
 }
 
+// Capabilities returns the capabilities of the transport.
+func (t *TcpTransport) Capabilities() transport.Capabilities {
+	return transport.CapabilityHolePunching
+}
+
 func (t *TcpTransport) String() string {
 	return "TCP"
 }
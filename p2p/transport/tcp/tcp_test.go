@@ -3,8 +3,15 @@ package tcp
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"net"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/network"
@@ -15,11 +22,14 @@ import (
 	"github.com/libp2p/go-libp2p/core/transport"
 	"github.com/libp2p/go-libp2p/p2p/muxer/yamux"
 	tptu "github.com/libp2p/go-libp2p/p2p/net/upgrader"
+	"github.com/libp2p/go-libp2p/p2p/transport/internal/sockopt"
 	"github.com/libp2p/go-libp2p/p2p/transport/tcpreuse"
 	ttransport "github.com/libp2p/go-libp2p/p2p/transport/testsuite"
+	"github.com/libp2p/go-libp2p/x/rate"
 
 	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr/net"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 )
@@ -65,6 +75,406 @@ func TestTcpTransportWithMetrics(t *testing.T) {
 	ttransport.SubtestTransport(t, ta, tb, zero, peerA)
 }
 
+func TestTcpTransportWithKeepAlive(t *testing.T) {
+	peerA, ia := makeInsecureMuxer(t)
+	_, ib := makeInsecureMuxer(t)
+
+	ua, err := tptu.New(ia, muxers, nil, nil, nil)
+	require.NoError(t, err)
+	ta, err := NewTCPTransport(ua, nil, nil, WithKeepAlive(5*time.Second, time.Second, 3))
+	require.NoError(t, err)
+	ub, err := tptu.New(ib, muxers, nil, nil, nil)
+	require.NoError(t, err)
+	tb, err := NewTCPTransport(ub, nil, nil, WithKeepAlive(5*time.Second, time.Second, 3))
+	require.NoError(t, err)
+
+	zero := "/ip4/127.0.0.1/tcp/0"
+	ttransport.SubtestTransport(t, ta, tb, zero, peerA)
+}
+
+func TestTcpTransportWithUserTimeout(t *testing.T) {
+	var u transport.Upgrader
+	if !sockopt.UserTimeoutSupported {
+		_, err := NewTCPTransport(u, nil, nil, WithUserTimeout(time.Second))
+		require.Error(t, err)
+		return
+	}
+	_, err := NewTCPTransport(u, nil, nil, WithUserTimeout(0))
+	require.Error(t, err)
+
+	peerA, ia := makeInsecureMuxer(t)
+	_, ib := makeInsecureMuxer(t)
+
+	ua, err := tptu.New(ia, muxers, nil, nil, nil)
+	require.NoError(t, err)
+	ta, err := NewTCPTransport(ua, nil, nil, WithUserTimeout(10*time.Second))
+	require.NoError(t, err)
+	ub, err := tptu.New(ib, muxers, nil, nil, nil)
+	require.NoError(t, err)
+	tb, err := NewTCPTransport(ub, nil, nil, WithUserTimeout(10*time.Second))
+	require.NoError(t, err)
+
+	zero := "/ip4/127.0.0.1/tcp/0"
+	ttransport.SubtestTransport(t, ta, tb, zero, peerA)
+}
+
+func TestTcpTransportWithSocketMark(t *testing.T) {
+	var u transport.Upgrader
+	if !sockopt.MarkSupported {
+		_, err := NewTCPTransport(u, nil, nil, WithSocketMark(42))
+		require.Error(t, err)
+		return
+	}
+
+	peerA, ia := makeInsecureMuxer(t)
+	ua, err := tptu.New(ia, muxers, nil, nil, nil)
+	require.NoError(t, err)
+	ta, err := NewTCPTransport(ua, nil, nil)
+	require.NoError(t, err)
+	ln, err := ta.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer ln.Close()
+
+	_, ib := makeInsecureMuxer(t)
+	ub, err := tptu.New(ib, muxers, nil, nil, nil)
+	require.NoError(t, err)
+	tb, err := NewTCPTransport(ub, nil, nil, WithSocketMark(42))
+	require.NoError(t, err)
+
+	conn, err := tb.Dial(context.Background(), ln.Multiaddr(), peerA)
+	if errors.Is(err, syscall.EPERM) {
+		t.Skip("setting SO_MARK requires CAP_NET_ADMIN")
+	}
+	require.NoError(t, err)
+	conn.Close()
+}
+
+func TestTcpTransportWithMultipathTCP(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		t.Run(fmt.Sprintf("enabled=%t", enabled), func(t *testing.T) {
+			peerA, ia := makeInsecureMuxer(t)
+			ua, err := tptu.New(ia, muxers, nil, nil, nil)
+			require.NoError(t, err)
+			ta, err := NewTCPTransport(ua, nil, nil, WithMultipathTCP(enabled, enabled), WithMetrics())
+			require.NoError(t, err)
+			ln, err := ta.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0"))
+			require.NoError(t, err)
+			defer ln.Close()
+
+			_, ib := makeInsecureMuxer(t)
+			ub, err := tptu.New(ib, muxers, nil, nil, nil)
+			require.NoError(t, err)
+			tb, err := NewTCPTransport(ub, nil, nil, WithMultipathTCP(enabled, enabled), WithMetrics())
+			require.NoError(t, err)
+
+			// The RTT is measured by the metrics, using TCP_INFO. The connections are
+			// wrapped for Multipath TCP even if it's disabled. The kernel doesn't always
+			// report the RTT of a subflow of a Multipath TCP connection.
+			hasRTT := (runtime.GOOS == "linux" || runtime.GOOS == "darwin") && !(enabled && mptcpAvailable())
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				c, err := ln.Accept()
+				if !assert.NoError(t, err) {
+					return
+				}
+				defer c.Close()
+				used, ok := c.(network.ConnStat).Stat().Extra[StatMultipathTCP].(bool)
+				assert.True(t, ok)
+				assert.Equal(t, enabled && mptcpAvailable(), used)
+				if hasRTT {
+					assert.Positive(t, c.(network.ConnRTT).SmoothedRTT())
+				}
+			}()
+
+			conn, err := tb.Dial(context.Background(), ln.Multiaddr(), peerA)
+			require.NoError(t, err)
+			defer conn.Close()
+			used, ok := conn.(network.ConnStat).Stat().Extra[StatMultipathTCP].(bool)
+			require.True(t, ok)
+			require.Equal(t, enabled && mptcpAvailable(), used)
+			if hasRTT {
+				require.Positive(t, conn.(network.ConnRTT).SmoothedRTT())
+			}
+			<-done
+		})
+	}
+}
+
+func TestTcpTransportHandshakeTimings(t *testing.T) {
+	peerA, ia := makeInsecureMuxer(t)
+	ua, err := tptu.New(ia, muxers, nil, nil, nil)
+	require.NoError(t, err)
+	ta, err := NewTCPTransport(ua, nil, nil)
+	require.NoError(t, err)
+	ln, err := ta.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer ln.Close()
+
+	_, ib := makeInsecureMuxer(t)
+	ub, err := tptu.New(ib, muxers, nil, nil, nil)
+	require.NoError(t, err)
+	tb, err := NewTCPTransport(ub, nil, nil)
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c, err := ln.Accept()
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer c.Close()
+		ht := c.(network.ConnStat).Stat().Handshake
+		assert.Zero(t, ht.Connect)
+		assert.NotZero(t, ht.Security)
+		assert.NotZero(t, ht.Muxer)
+	}()
+
+	conn, err := tb.Dial(context.Background(), ln.Multiaddr(), peerA)
+	require.NoError(t, err)
+	defer conn.Close()
+	ht := conn.(network.ConnStat).Stat().Handshake
+	require.NotZero(t, ht.Connect)
+	require.NotZero(t, ht.Security)
+	require.NotZero(t, ht.Muxer)
+	<-done
+}
+
+// mptcpAvailable returns true if the kernel supports Multipath TCP.
+func mptcpAvailable() bool {
+	b, err := os.ReadFile("/proc/sys/net/mptcp/enabled")
+	return err == nil && strings.TrimSpace(string(b)) == "1"
+}
+
+func TestTcpTransportWithSourceAddressSelector(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("only Linux routes the whole 127.0.0.0/8 range to the loopback interface")
+	}
+	src := net.IPv4(127, 0, 0, 2)
+	for _, reuseport := range []bool{true, false} {
+		t.Run(fmt.Sprintf("reuseport=%t", reuseport), func(t *testing.T) {
+			if reuseport && !tcpreuse.ReuseportIsAvailable() {
+				t.Skip("reuseport not available")
+			}
+			peerA, ia := makeInsecureMuxer(t)
+			ua, err := tptu.New(ia, muxers, nil, nil, nil)
+			require.NoError(t, err)
+			ta, err := NewTCPTransport(ua, nil, nil)
+			require.NoError(t, err)
+			lnA, err := ta.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0"))
+			require.NoError(t, err)
+			defer lnA.Close()
+
+			_, ib := makeInsecureMuxer(t)
+			ub, err := tptu.New(ib, muxers, nil, nil, nil)
+			require.NoError(t, err)
+			opts := []Option{WithSourceAddressSelector(func(raddr ma.Multiaddr) DialSource {
+				require.Equal(t, lnA.Multiaddr(), raddr)
+				return DialSource{IP: src}
+			})}
+			if !reuseport {
+				opts = append(opts, DisableReuseport())
+			}
+			tb, err := NewTCPTransport(ub, nil, nil, opts...)
+			require.NoError(t, err)
+			lnB, err := tb.Listen(ma.StringCast("/ip4/0.0.0.0/tcp/0"))
+			require.NoError(t, err)
+			defer lnB.Close()
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				c, err := lnA.Accept()
+				if assert.NoError(t, err) {
+					c.Close()
+				}
+			}()
+			conn, err := tb.Dial(context.Background(), lnA.Multiaddr(), peerA)
+			require.NoError(t, err)
+			defer conn.Close()
+			<-done
+
+			ip, err := manet.ToIP(conn.LocalMultiaddr())
+			require.NoError(t, err)
+			require.True(t, ip.Equal(src), "dialed from %s", conn.LocalMultiaddr())
+			if reuseport {
+				port, err := lnB.Multiaddr().ValueForProtocol(ma.P_TCP)
+				require.NoError(t, err)
+				localPort, err := conn.LocalMultiaddr().ValueForProtocol(ma.P_TCP)
+				require.NoError(t, err)
+				require.Equal(t, port, localPort)
+			}
+		})
+	}
+}
+
+func TestTcpTransportWithBindToInterface(t *testing.T) {
+	var u transport.Upgrader
+	if !sockopt.BindToDeviceSupported {
+		_, err := NewTCPTransport(u, nil, nil, WithBindToInterface("lo"))
+		require.Error(t, err)
+		return
+	}
+
+	peerA, ia := makeInsecureMuxer(t)
+	ua, err := tptu.New(ia, muxers, nil, nil, nil)
+	require.NoError(t, err)
+	ta, err := NewTCPTransport(ua, nil, nil)
+	require.NoError(t, err)
+	ln, err := ta.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer ln.Close()
+
+	_, ib := makeInsecureMuxer(t)
+	ub, err := tptu.New(ib, muxers, nil, nil, nil)
+	require.NoError(t, err)
+	tb, err := NewTCPTransport(ub, nil, nil, WithBindToInterface("lo"))
+	require.NoError(t, err)
+
+	go func() {
+		if c, err := ln.Accept(); err == nil {
+			c.Close()
+		}
+	}()
+	conn, err := tb.Dial(context.Background(), ln.Multiaddr(), peerA)
+	if errors.Is(err, syscall.EPERM) {
+		t.Skip("binding to an interface requires CAP_NET_RAW")
+	}
+	require.NoError(t, err)
+	conn.Close()
+
+	// Binding to a nonexistent interface fails.
+	tc, err := NewTCPTransport(ub, nil, nil, WithBindToInterface("libp2p-nonexistent"))
+	require.NoError(t, err)
+	_, err = tc.Dial(context.Background(), ln.Multiaddr(), peerA)
+	require.Error(t, err)
+}
+
+func TestTcpTransportWithAcceptRateLimiter(t *testing.T) {
+	peerA, ia := makeInsecureMuxer(t)
+	ua, err := tptu.New(ia, muxers, nil, nil, nil)
+	require.NoError(t, err)
+	limiter := &rate.Limiter{GlobalLimit: rate.Limit{RPS: 0.001, Burst: 1}}
+	ta, err := NewTCPTransport(ua, nil, nil, WithAcceptRateLimiter(limiter))
+	require.NoError(t, err)
+	ln, err := ta.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+		}
+	}()
+
+	_, ib := makeInsecureMuxer(t)
+	ub, err := tptu.New(ib, muxers, nil, nil, nil)
+	require.NoError(t, err)
+	tb, err := NewTCPTransport(ub, nil, nil)
+	require.NoError(t, err)
+
+	conn, err := tb.Dial(context.Background(), ln.Multiaddr(), peerA)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = tb.Dial(context.Background(), ln.Multiaddr(), peerA)
+	require.Error(t, err)
+}
+
+func TestTcpTransportWithHandshakeTimeout(t *testing.T) {
+	var u transport.Upgrader
+	_, err := NewTCPTransport(u, nil, nil, WithHandshakeTimeout(0))
+	require.Error(t, err)
+
+	peerA, ia := makeInsecureMuxer(t)
+	ua, err := tptu.New(ia, muxers, nil, nil, nil)
+	require.NoError(t, err)
+	ta, err := NewTCPTransport(ua, nil, nil, WithHandshakeTimeout(200*time.Millisecond))
+	require.NoError(t, err)
+	ln, err := ta.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer ln.Close()
+	accepted := make(chan transport.CapableConn, 1)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- c
+		}
+	}()
+
+	// A connection that never starts the handshake is reset.
+	raw, err := manet.Dial(ln.Multiaddr())
+	require.NoError(t, err)
+	defer raw.Close()
+	require.NoError(t, raw.SetReadDeadline(time.Now().Add(5*time.Second)))
+	// The server starts the handshake, but doesn't get a response.
+	_, err = io.Copy(io.Discard, raw)
+	var nerr net.Error
+	require.False(t, errors.As(err, &nerr) && nerr.Timeout(), "expected the connection to be closed")
+
+	// Connections that complete the handshake in time are not affected.
+	_, ib := makeInsecureMuxer(t)
+	ub, err := tptu.New(ib, muxers, nil, nil, nil)
+	require.NoError(t, err)
+	tb, err := NewTCPTransport(ub, nil, nil)
+	require.NoError(t, err)
+	conn, err := tb.Dial(context.Background(), ln.Multiaddr(), peerA)
+	require.NoError(t, err)
+	defer conn.Close()
+	c := <-accepted
+	defer c.Close()
+	time.Sleep(300 * time.Millisecond)
+	require.False(t, c.IsClosed())
+	require.False(t, conn.IsClosed())
+}
+
+type handshakeTestConn struct {
+	manet.Conn
+	transport.CapableConn
+	closed chan struct{}
+}
+
+func (c *handshakeTestConn) LocalMultiaddr() ma.Multiaddr {
+	return ma.StringCast("/ip4/127.0.0.1/tcp/1234")
+}
+
+func (c *handshakeTestConn) RemoteMultiaddr() ma.Multiaddr {
+	return ma.StringCast("/ip4/127.0.0.1/tcp/5678")
+}
+
+func (c *handshakeTestConn) Close() error {
+	close(c.closed)
+	return nil
+}
+
+func TestHandshakeTrackerSameTuple(t *testing.T) {
+	h := newHandshakeTracker(100 * time.Millisecond)
+	// A connection that fails to upgrade, followed by a new connection with
+	// the same 4-tuple.
+	stale := &handshakeTestConn{closed: make(chan struct{})}
+	h.Start(stale)
+	time.Sleep(50 * time.Millisecond)
+	live := &handshakeTestConn{closed: make(chan struct{})}
+	h.Start(live)
+
+	// The stale connection's timer only removes its own entry.
+	<-stale.closed
+	require.True(t, h.Done(live))
+	require.Empty(t, h.pending)
+	time.Sleep(150 * time.Millisecond)
+	select {
+	case <-live.closed:
+		t.Fatal("connection closed after the handshake completed")
+	default:
+	}
+}
+
 func TestResourceManager(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -133,6 +543,94 @@ func TestTcpTransportCantDialDNS(t *testing.T) {
 	tcpreuse.EnvReuseportVal = true
 }
 
+func TestTcpTransportHappyEyeballs(t *testing.T) {
+	peerA, ia := makeInsecureMuxer(t)
+	ua, err := tptu.New(ia, muxers, nil, nil, nil)
+	require.NoError(t, err)
+	ta, err := NewTCPTransport(ua, nil, nil)
+	require.NoError(t, err)
+	ln, err := ta.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer ln.Close()
+
+	port, err := ln.Multiaddr().ValueForProtocol(ma.P_TCP)
+	require.NoError(t, err)
+	dnsa := ma.StringCast("/dns/localhost/tcp/" + port)
+
+	_, ib := makeInsecureMuxer(t)
+	ub, err := tptu.New(ib, muxers, nil, nil, nil)
+	require.NoError(t, err)
+	tb, err := NewTCPTransport(ub, nil, nil, WithHappyEyeballs(0))
+	require.NoError(t, err)
+	require.True(t, tb.CanDial(dnsa))
+	require.True(t, tb.SkipResolve(context.Background(), dnsa))
+	require.False(t, tb.SkipResolve(context.Background(), ln.Multiaddr()))
+
+	// localhost may also resolve to ::1, which we're not listening on.
+	// The IPv4 attempt must still succeed.
+	conn, err := tb.Dial(context.Background(), dnsa, peerA)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.True(t, manet.IsIPLoopback(conn.RemoteMultiaddr()))
+
+	// The attempts reuse the listening port, which hole punching relies on.
+	if !tb.UseReuseport() {
+		return
+	}
+	lnb, err := tb.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer lnb.Close()
+	conn, err = tb.Dial(context.Background(), dnsa, peerA)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Equal(t, lnb.Multiaddr(), conn.LocalMultiaddr())
+}
+
+type mockResolver struct {
+	addrs map[string][]ma.Multiaddr
+}
+
+var _ network.MultiaddrDNSResolver = &mockResolver{}
+
+func (r *mockResolver) ResolveDNSAddr(context.Context, peer.ID, ma.Multiaddr, int, int) ([]ma.Multiaddr, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (r *mockResolver) ResolveDNSComponent(_ context.Context, maddr ma.Multiaddr, _ int) ([]ma.Multiaddr, error) {
+	addrs, ok := r.addrs[maddr.String()]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return addrs, nil
+}
+
+func TestTcpTransportHappyEyeballsResolver(t *testing.T) {
+	peerA, ia := makeInsecureMuxer(t)
+	ua, err := tptu.New(ia, muxers, nil, nil, nil)
+	require.NoError(t, err)
+	ta, err := NewTCPTransport(ua, nil, nil)
+	require.NoError(t, err)
+	ln, err := ta.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0"))
+	require.NoError(t, err)
+	defer ln.Close()
+
+	// The name doesn't exist, only the configured resolver knows it.
+	dnsa := ma.StringCast("/dns/example.invalid/tcp/1234")
+	r := &mockResolver{addrs: map[string][]ma.Multiaddr{
+		dnsa.String(): {ln.Multiaddr()},
+	}}
+	_, ib := makeInsecureMuxer(t)
+	ub, err := tptu.New(ib, muxers, nil, nil, nil)
+	require.NoError(t, err)
+	tb, err := NewTCPTransport(ub, nil, nil, WithHappyEyeballs(0), WithResolver(r))
+	require.NoError(t, err)
+
+	conn, err := tb.Dial(context.Background(), dnsa, peerA)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Equal(t, ln.Multiaddr(), conn.RemoteMultiaddr())
+}
+
 func TestTcpTransportCantListenUtp(t *testing.T) {
 	for i := 0; i < 2; i++ {
 		utpa, err := ma.NewMultiaddr("/ip4/127.0.0.1/udp/0/utp")
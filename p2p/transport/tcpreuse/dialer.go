@@ -2,6 +2,8 @@ package tcpreuse
 
 import (
 	"context"
+	"net"
+	"syscall"
 
 	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr/net"
@@ -15,3 +17,17 @@ func (t *ConnMgr) DialContext(ctx context.Context, raddr ma.Multiaddr) (manet.Co
 	var d manet.Dialer
 	return d.DialContext(ctx, raddr)
 }
+
+// DialContextFrom is like DialContext, but dials from the local IP address
+// src, and applies control to the socket before it is connected. If src is
+// nil, the operating system picks the source address.
+func (t *ConnMgr) DialContextFrom(ctx context.Context, raddr ma.Multiaddr, src net.IP, control func(network, address string, c syscall.RawConn) error) (manet.Conn, error) {
+	if t.useReuseport() {
+		return t.reuse.DialContextFrom(ctx, raddr, src, control)
+	}
+	d := manet.Dialer{Dialer: net.Dialer{Control: control}}
+	if src != nil {
+		d.Dialer.LocalAddr = &net.TCPAddr{IP: src}
+	}
+	return d.DialContext(ctx, raddr)
+}
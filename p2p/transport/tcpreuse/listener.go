@@ -28,18 +28,23 @@ type ConnMgr struct {
 	enableReuseport bool
 	reuse           reuseport.Transport
 	upgrader        transport.Upgrader
+	enableMetrics   bool
 
 	mx        sync.Mutex
 	listeners map[string]*multiplexedListener
 }
 
-func NewConnMgr(enableReuseport bool, upgrader transport.Upgrader) *ConnMgr {
-	return &ConnMgr{
+func NewConnMgr(enableReuseport bool, upgrader transport.Upgrader, opts ...Option) *ConnMgr {
+	t := &ConnMgr{
 		enableReuseport: enableReuseport,
 		reuse:           reuseport.Transport{},
 		upgrader:        upgrader,
 		listeners:       make(map[string]*multiplexedListener),
 	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
 }
 
 func (t *ConnMgr) gatedMaListen(listenAddr ma.Multiaddr) (transport.GatedMaListener, error) {
@@ -63,6 +68,11 @@ func (t *ConnMgr) useReuseport() bool {
 	return t.enableReuseport && ReuseportIsAvailable()
 }
 
+// UseReuseport returns true if reuseport is enabled and available.
+func (t *ConnMgr) UseReuseport() bool {
+	return t.useReuseport()
+}
+
 func getTCPAddr(listenAddr ma.Multiaddr) (ma.Multiaddr, error) {
 	haveTCP := false
 	addr, _ := ma.SplitFunc(listenAddr, func(c ma.Component) bool {
@@ -122,6 +132,7 @@ func (t *ConnMgr) DemultiplexedListen(laddr ma.Multiaddr, connType Demultiplexed
 		listeners:       make(map[DemultiplexedConnType]*demultiplexedListener),
 		ctx:             ctx,
 		closeFn:         cancelFunc,
+		stats:           &demultiplexStats{enableMetrics: t.enableMetrics},
 	}
 	t.listeners[laddr.String()] = ml
 	t.listeners[gmal.Multiaddr().String()] = ml
@@ -148,6 +159,7 @@ type multiplexedListener struct {
 	ctx     context.Context
 	closeFn func() error
 	wg      sync.WaitGroup
+	stats   *demultiplexStats
 }
 
 var ErrListenerExists = errors.New("listener already exists for this conn type on this address")
@@ -193,6 +205,9 @@ func (m *multiplexedListener) run() error {
 			cancelCtx()
 			connScope.Done()
 			c.Close()
+			if m.ctx.Err() == nil {
+				m.stats.dropped(dropReasonAcceptQueueFull)
+			}
 			log.Debugf("accept queue full, dropping connection: %s", c.RemoteMultiaddr())
 			continue
 		case <-m.ctx.Done():
@@ -212,9 +227,11 @@ func (m *multiplexedListener) run() error {
 			if err != nil {
 				// conn closed by identifyConnType
 				connScope.Done()
+				m.stats.sniffFailed(err)
 				log.Debugf("error demultiplexing connection: %s", err.Error())
 				return
 			}
+			m.stats.classified(t)
 
 			connWithScope, err := manetConnWithScope(c, connScope)
 			if err != nil {
@@ -229,6 +246,7 @@ func (m *multiplexedListener) run() error {
 			demux, ok := m.listeners[t]
 			m.mx.RUnlock()
 			if !ok {
+				m.stats.dropped(dropReasonNoListener)
 				closeErr := connWithScope.Close()
 				if closeErr != nil {
 					log.Debugf("no registered listener for demultiplex connection %s. Error closing the connection %s", t, closeErr.Error())
@@ -243,6 +261,9 @@ func (m *multiplexedListener) run() error {
 			case <-ctx.Done():
 				log.Debug("accept timeout; dropping connection from: %v", connWithScope.RemoteMultiaddr())
 				connWithScope.Close()
+				if m.ctx.Err() == nil {
+					m.stats.dropped(dropReasonAcceptTimeout)
+				}
 			}
 		}()
 	}
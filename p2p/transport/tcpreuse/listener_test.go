@@ -23,6 +23,7 @@ import (
 	ma "github.com/multiformats/go-multiaddr"
 	manet "github.com/multiformats/go-multiaddr/net"
 	"github.com/multiformats/go-multistream"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -495,3 +496,53 @@ func TestHitTimeout(t *testing.T) {
 
 	tcpConn.Close()
 }
+
+func TestDemultiplexStats(t *testing.T) {
+	setDeferReset(t, &identifyConnTimeout, 100*time.Millisecond)
+	cm := NewConnMgr(false, upgrader(t), EnableMetrics(prometheus.NewRegistry()))
+
+	ml, err := cm.DemultiplexedListen(ma.StringCast("/ip4/127.0.0.1/tcp/0"), DemultiplexedConnType_MultistreamSelect)
+	require.NoError(t, err)
+	defer ml.Close()
+
+	send := func(b []byte) {
+		t.Helper()
+		c, err := net.Dial(ml.Addr().Network(), ml.Addr().String())
+		require.NoError(t, err)
+		t.Cleanup(func() { c.Close() })
+		if b != nil {
+			_, err = c.Write(b)
+			require.NoError(t, err)
+		}
+	}
+
+	go func() {
+		for {
+			c, _, err := ml.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+
+	send([]byte("\x13/multistream/1.0.0\n"))
+	send([]byte("GET / HTTP/1.1\r\n"))
+	send([]byte("foobar"))
+	send(nil)
+
+	require.EventuallyWithT(t, func(t *assert.CollectT) {
+		stats := cm.Stats()
+		if !assert.Len(t, stats, 1) {
+			return
+		}
+		s := stats[0]
+		assert.Equal(t, ml.Multiaddr(), s.Addr)
+		assert.Equal(t, 1, s.Conns[DemultiplexedConnType_MultistreamSelect])
+		assert.Equal(t, 1, s.Conns[DemultiplexedConnType_HTTP])
+		assert.Equal(t, 1, s.Conns[DemultiplexedConnType_Unknown])
+		assert.Equal(t, 0, s.Conns[DemultiplexedConnType_TLS])
+		assert.Equal(t, 2, s.NoListener)
+		assert.Equal(t, 1, s.SniffTimeouts)
+	}, 5*time.Second, 50*time.Millisecond)
+}
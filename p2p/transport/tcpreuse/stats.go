@@ -0,0 +1,169 @@
+package tcpreuse
+
+import (
+	"errors"
+	"os"
+	"sync/atomic"
+
+	"github.com/libp2p/go-libp2p/p2p/metricshelper"
+
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricNamespace = "libp2p_tcpreuse"
+
+var (
+	demultiplexedConns = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "demultiplexed_connections_total",
+			Help:      "Connections accepted on a shared listener, by detected connection type",
+		},
+		[]string{"type"},
+	)
+	demultiplexErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Name:      "demultiplex_errors_total",
+			Help:      "Connections dropped by a shared listener, by reason",
+		},
+		[]string{"reason"},
+	)
+	collectors = []prometheus.Collector{
+		demultiplexedConns,
+		demultiplexErrors,
+	}
+)
+
+// Reasons a shared listener drops a connection, as used in metrics.
+const (
+	dropReasonSniffError      = "sniff_error"
+	dropReasonSniffTimeout    = "sniff_timeout"
+	dropReasonNoListener      = "no_listener"
+	dropReasonAcceptQueueFull = "accept_queue_full"
+	dropReasonAcceptTimeout   = "accept_timeout"
+)
+
+type Option func(*ConnMgr)
+
+// EnableMetrics enables Prometheus metrics collection. If reg is nil,
+// prometheus.DefaultRegisterer will be used as the registerer.
+func EnableMetrics(reg prometheus.Registerer) Option {
+	return func(t *ConnMgr) {
+		if reg == nil {
+			reg = prometheus.DefaultRegisterer
+		}
+		metricshelper.RegisterCollectors(reg, collectors...)
+		t.enableMetrics = true
+	}
+}
+
+// DemultiplexStats are the statistics of a listener shared by multiple
+// connection types. Counters start at zero when the listener is created.
+type DemultiplexStats struct {
+	// Addr is the address of the shared listener.
+	Addr ma.Multiaddr
+	// Conns is the number of connections classified as each connection type,
+	// including DemultiplexedConnType_Unknown.
+	Conns map[DemultiplexedConnType]int
+	// SniffErrors is the number of connections that were closed because
+	// reading their first bytes failed.
+	SniffErrors int
+	// SniffTimeouts is the number of connections that were closed because
+	// they didn't send their first bytes in time.
+	SniffTimeouts int
+	// NoListener is the number of connections that were closed because no
+	// listener was registered for their connection type.
+	NoListener int
+	// AcceptQueueFull is the number of connections that were closed because
+	// too many connections were being classified.
+	AcceptQueueFull int
+	// AcceptTimeouts is the number of connections that were closed because
+	// the listener for their connection type didn't accept them in time.
+	AcceptTimeouts int
+}
+
+// Stats returns the statistics of all shared listeners.
+func (t *ConnMgr) Stats() []DemultiplexStats {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	stats := make([]DemultiplexStats, 0, len(t.listeners))
+	seen := make(map[*multiplexedListener]struct{}, len(t.listeners))
+	for _, ml := range t.listeners {
+		// Listeners are registered both under the requested and the actual
+		// address.
+		if _, ok := seen[ml]; ok {
+			continue
+		}
+		seen[ml] = struct{}{}
+		stats = append(stats, ml.stats.snapshot(ml.Multiaddr()))
+	}
+	return stats
+}
+
+// demultiplexStats counts the connections accepted on a shared listener.
+type demultiplexStats struct {
+	enableMetrics bool
+
+	conns           [DemultiplexedConnType_TLS + 1]atomic.Int64
+	sniffErrors     atomic.Int64
+	sniffTimeouts   atomic.Int64
+	noListener      atomic.Int64
+	acceptQueueFull atomic.Int64
+	acceptTimeouts  atomic.Int64
+}
+
+func (s *demultiplexStats) snapshot(addr ma.Multiaddr) DemultiplexStats {
+	conns := make(map[DemultiplexedConnType]int, len(s.conns))
+	for i := range s.conns {
+		conns[DemultiplexedConnType(i)] = int(s.conns[i].Load())
+	}
+	return DemultiplexStats{
+		Addr:            addr,
+		Conns:           conns,
+		SniffErrors:     int(s.sniffErrors.Load()),
+		SniffTimeouts:   int(s.sniffTimeouts.Load()),
+		NoListener:      int(s.noListener.Load()),
+		AcceptQueueFull: int(s.acceptQueueFull.Load()),
+		AcceptTimeouts:  int(s.acceptTimeouts.Load()),
+	}
+}
+
+func (s *demultiplexStats) classified(t DemultiplexedConnType) {
+	if t < 0 || int(t) >= len(s.conns) {
+		t = DemultiplexedConnType_Unknown
+	}
+	s.conns[t].Add(1)
+	if s.enableMetrics {
+		demultiplexedConns.WithLabelValues(t.String()).Inc()
+	}
+}
+
+func (s *demultiplexStats) sniffFailed(err error) {
+	reason := dropReasonSniffError
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		reason = dropReasonSniffTimeout
+		s.sniffTimeouts.Add(1)
+	} else {
+		s.sniffErrors.Add(1)
+	}
+	if s.enableMetrics {
+		demultiplexErrors.WithLabelValues(reason).Inc()
+	}
+}
+
+func (s *demultiplexStats) dropped(reason string) {
+	switch reason {
+	case dropReasonNoListener:
+		s.noListener.Add(1)
+	case dropReasonAcceptQueueFull:
+		s.acceptQueueFull.Add(1)
+	case dropReasonAcceptTimeout:
+		s.acceptTimeouts.Add(1)
+	}
+	if s.enableMetrics {
+		demultiplexErrors.WithLabelValues(reason).Inc()
+	}
+}
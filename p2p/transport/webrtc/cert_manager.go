@@ -0,0 +1,249 @@
+package libp2pwebrtc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	ma "github.com/multiformats/go-multiaddr"
+	"github.com/pion/webrtc/v4"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// certRotationPeriod is the time a derived certificate is used for.
+	// Certificates are rotated at multiples of the rotation period since the
+	// Unix epoch, so that all nodes using the same seed use the same
+	// certificate.
+	certRotationPeriod = 7 * 24 * time.Hour
+	// certOverlap is how long before a rotation the certhash of the next
+	// certificate is advertised in addition to the current one.
+	certOverlap = 24 * time.Hour
+	// Allow for a bit of clock skew in the validity of certificates.
+	clockSkewAllowance = time.Hour
+
+	deterministicCertInfo = "libp2p webrtc-direct certificate"
+)
+
+// certificate is a DTLS certificate used by the listener.
+type certificate struct {
+	cert webrtc.Certificate
+	// certhash is the /certhash component advertised for the certificate.
+	certhash ma.Multiaddr
+}
+
+func newCertificate(cert webrtc.Certificate) (*certificate, error) {
+	fps, err := cert.GetFingerprints()
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := encodeDTLSFingerprint(fps[0])
+	if err != nil {
+		return nil, err
+	}
+	certhash, err := ma.NewComponent(ma.ProtocolWithCode(ma.P_CERTHASH).Name, encoded)
+	if err != nil {
+		return nil, err
+	}
+	return &certificate{cert: cert, certhash: certhash.Multiaddr()}, nil
+}
+
+// certManager manages the DTLS certificates of the transport.
+//
+// By default, a random certificate is used for the lifetime of the transport.
+// If a seed is configured, the certificates are derived from it and rotated.
+// The certhash of the next certificate is advertised in addition to the
+// current one during the last certOverlap of a rotation period, so that peers
+// that learned the address shortly before a rotation can still dial after it.
+// Since derived certificates are deterministic, their certhashes are stable
+// across restarts.
+type certManager struct {
+	seed  []byte // nil if the certificate is static
+	clock clock.Clock
+
+	mx            sync.Mutex
+	period        int64
+	current, next *certificate
+}
+
+// newStaticCertManager returns a certManager that uses a single, random
+// certificate.
+func newStaticCertManager() (*certManager, error) {
+	// We use elliptic P-256 since it is widely supported by browsers.
+	//
+	// Implementation note: Testing with the browser,
+	// it seems like Chromium only supports ECDSA P-256 or RSA key signatures in the webrtc TLS certificate.
+	// We tried using P-228 and P-384 which caused the DTLS handshake to fail with Illegal Parameter
+	//
+	// Please refer to this is a list of suggested algorithms for the WebCrypto API.
+	// The algorithm for generating a certificate for an RTCPeerConnection
+	// must adhere to the WebCrpyto API. From my observation,
+	// RSA and ECDSA P-256 is supported on almost all browsers.
+	// Ed25519 is not present on the list.
+	pk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := webrtc.GenerateCertificate(pk)
+	if err != nil {
+		return nil, err
+	}
+	c, err := newCertificate(*cert)
+	if err != nil {
+		return nil, err
+	}
+	return &certManager{current: c}, nil
+}
+
+// newCertManager returns a certManager that derives the certificates from
+// seed.
+func newCertManager(seed []byte, cl clock.Clock) (*certManager, error) {
+	m := &certManager{seed: seed, clock: cl}
+	if err := m.rotate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// rotate updates the certificates if a new rotation period started.
+// It must be called with mx held, or during initialization.
+func (m *certManager) rotate() error {
+	if m.seed == nil {
+		return nil
+	}
+	period := m.clock.Now().UnixNano() / int64(certRotationPeriod)
+	if m.current != nil && period == m.period {
+		return nil
+	}
+	var current *certificate
+	if m.next != nil && period == m.period+1 {
+		current = m.next
+	} else {
+		var err error
+		current, err = deriveCertificate(m.seed, period)
+		if err != nil {
+			return err
+		}
+	}
+	next, err := deriveCertificate(m.seed, period+1)
+	if err != nil {
+		return err
+	}
+	if m.current != nil {
+		log.Debugw("rotated certificate", "certhash", current.certhash)
+	}
+	m.period = period
+	m.current = current
+	m.next = next
+	return nil
+}
+
+// Current returns the certificate that is used for new connections.
+func (m *certManager) Current() (*certificate, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if err := m.rotate(); err != nil {
+		return nil, err
+	}
+	return m.current, nil
+}
+
+// Certhashes returns the /certhash component of the current certificate,
+// followed by the one of the next certificate if the next rotation is less
+// than certOverlap away.
+func (m *certManager) Certhashes() (ma.Multiaddr, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if err := m.rotate(); err != nil {
+		return nil, err
+	}
+	if m.next == nil {
+		return m.current.certhash, nil
+	}
+	nextRotation := time.Unix(0, (m.period+1)*int64(certRotationPeriod))
+	if m.clock.Until(nextRotation) > certOverlap {
+		return m.current.certhash, nil
+	}
+	return m.current.certhash.Encapsulate(m.next.certhash), nil
+}
+
+// deriveCertificate deterministically derives the certificate of the given
+// rotation period from the seed. The certificate is valid from the time it's
+// first advertised, certOverlap before the period starts, until the end of
+// the period.
+func deriveCertificate(seed []byte, period int64) (*certificate, error) {
+	salt := make([]byte, 8)
+	binary.BigEndian.PutUint64(salt, uint64(period))
+	r := hkdf.New(sha256.New, seed, salt, []byte(deterministicCertInfo))
+
+	b := make([]byte, 8)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	serial := new(big.Int).SetUint64(binary.BigEndian.Uint64(b) >> 1)
+	start := time.Unix(0, period*int64(certRotationPeriod)).Add(-certOverlap - clockSkewAllowance)
+	end := time.Unix(0, (period+1)*int64(certRotationPeriod)).Add(clockSkewAllowance)
+	tmpl := &x509.Certificate{
+		SerialNumber:       serial,
+		Issuer:             pkix.Name{CommonName: "libp2p"},
+		Subject:            pkix.Name{CommonName: "libp2p"},
+		NotBefore:          start,
+		NotAfter:           end,
+		SignatureAlgorithm: x509.ECDSAWithSHA256,
+	}
+	// See newStaticCertManager for why P-256 is used.
+	priv, err := deriveP256Key(r)
+	if err != nil {
+		return nil, err
+	}
+	certDER, err := x509.CreateCertificate(deterministicSignatureRand(seed, salt), tmpl, tmpl, priv.Public(), priv)
+	if err != nil {
+		return nil, err
+	}
+	x509Cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return nil, err
+	}
+	return newCertificate(webrtc.CertificateFromX509(priv, x509Cert))
+}
+
+var oidNamedCurveP256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+
+// ecPrivateKey is the SEC 1 ASN.1 structure of an EC private key.
+type ecPrivateKey struct {
+	Version       int
+	PrivateKey    []byte
+	NamedCurveOID asn1.ObjectIdentifier `asn1:"optional,explicit,tag:0"`
+}
+
+// deriveP256Key derives a P-256 private key from r. ecdsa.GenerateKey can't
+// be used for this, since it ignores the reader passed to it.
+func deriveP256Key(r io.Reader) (*ecdsa.PrivateKey, error) {
+	n := elliptic.P256().Params().N
+	d := make([]byte, 32)
+	for {
+		if _, err := io.ReadFull(r, d); err != nil {
+			return nil, err
+		}
+		// Rejection sampling, to get a uniformly distributed scalar in [1, n-1].
+		if k := new(big.Int).SetBytes(d); k.Sign() > 0 && k.Cmp(n) < 0 {
+			break
+		}
+	}
+	der, err := asn1.Marshal(ecPrivateKey{Version: 1, PrivateKey: d, NamedCurveOID: oidNamedCurveP256})
+	if err != nil {
+		return nil, err
+	}
+	// This computes the public key.
+	return x509.ParseECPrivateKey(der)
+}
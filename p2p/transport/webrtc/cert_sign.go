@@ -0,0 +1,12 @@
+//go:build go1.24
+
+package libp2pwebrtc
+
+import "io"
+
+// deterministicSignatureRand returns the random source used to sign derived
+// certificates. Since Go 1.24, ECDSA signatures are deterministic (RFC 6979)
+// if no random source is used.
+func deterministicSignatureRand(_, _ []byte) io.Reader {
+	return nil
+}
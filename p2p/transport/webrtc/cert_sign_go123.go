@@ -0,0 +1,36 @@
+//go:build !go1.24
+
+package libp2pwebrtc
+
+import (
+	"crypto/sha256"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// deterministicSignatureRand returns the random source used to sign derived
+// certificates. Before Go 1.24, ECDSA signatures can only be made
+// deterministic by using a deterministic random source.
+func deterministicSignatureRand(seed, salt []byte) io.Reader {
+	return &deterministicReader{
+		reader:           hkdf.New(sha256.New, seed, salt, []byte(deterministicCertInfo+" signature")),
+		singleByteReader: hkdf.New(sha256.New, seed, salt, []byte(deterministicCertInfo+" signature single byte")),
+	}
+}
+
+// deterministicReader counteracts the Go library's attempt at making ECDSA
+// signatures non-deterministic, which is done by randomly dropping a single
+// byte from the reader stream. Single byte reads are served from a separate
+// stream.
+type deterministicReader struct {
+	reader           io.Reader
+	singleByteReader io.Reader
+}
+
+func (r *deterministicReader) Read(p []byte) (n int, err error) {
+	if len(p) == 1 {
+		return r.singleByteReader.Read(p)
+	}
+	return r.reader.Read(p)
+}
@@ -21,6 +21,7 @@ import (
 )
 
 var _ tpt.CapableConn = &connection{}
+var _ network.ConnStat = &connection{}
 
 const maxAcceptQueueLen = 256
 
@@ -60,6 +61,8 @@ type connection struct {
 
 	acceptQueue chan dataChannel
 
+	stats *StatsReporter
+
 	ctx    context.Context
 	cancel context.CancelFunc
 }
@@ -96,6 +99,7 @@ func newConnection(
 		streams:         make(map[uint16]*stream),
 
 		acceptQueue: incomingDataChannels,
+		stats:       &StatsReporter{pc: pc},
 	}
 	switch direction {
 	case network.DirInbound:
@@ -126,6 +130,13 @@ func (c *connection) ConnState() network.ConnectionState {
 	return network.ConnectionState{Transport: "webrtc-direct"}
 }
 
+// Stat returns the transport-specific stats of the connection.
+func (c *connection) Stat() network.ConnStats {
+	var stat network.ConnStats
+	stat.Extra = map[interface{}]interface{}{StatWebRTC: c.stats}
+	return stat
+}
+
 // Close closes the underlying peerconnection.
 func (c *connection) Close() error {
 	c.closeWithError(errConnClosed)
@@ -145,6 +156,7 @@ func (c *connection) closeWithError(err error) {
 		// cancel must be called after closeErr is set. This ensures interested goroutines waiting on
 		// ctx.Done can read closeErr without holding the conn lock.
 		c.cancel()
+		c.transport.trackClosedConn(c)
 		// closing peerconnection will close the datachannels associated with the streams
 		c.pc.Close()
 
@@ -189,6 +201,7 @@ func (c *connection) OpenStream(ctx context.Context) (network.MuxedStream, error
 		return nil, fmt.Errorf("detach channel failed for stream(%d): %w", streamID, err)
 	}
 	str := newStream(dc, rwc, maxSendMessageSize, func() { c.removeStream(streamID) })
+	str.applyConfig(c.transport.streamConfig())
 	if err := c.addStream(str); err != nil {
 		str.Reset()
 		return nil, fmt.Errorf("failed to add stream(%d) to connection: %w", streamID, err)
@@ -202,6 +215,7 @@ func (c *connection) AcceptStream() (network.MuxedStream, error) {
 		return nil, c.closeErr
 	case dc := <-c.acceptQueue:
 		str := newStream(dc.channel, dc.stream, maxSendMessageSize, func() { c.removeStream(*dc.channel.ID()) })
+		str.applyConfig(c.transport.streamConfig())
 		if err := c.addStream(str); err != nil {
 			str.Reset()
 			return nil, err
@@ -1,6 +1,7 @@
 package libp2pwebrtc
 
 import (
+	"bytes"
 	"crypto"
 	"crypto/x509"
 	"errors"
@@ -28,16 +29,51 @@ func parseFingerprint(cert *x509.Certificate, algo crypto.Hash) ([]byte, error)
 	return h.Sum(nil), nil
 }
 
-func decodeRemoteFingerprint(maddr ma.Multiaddr) (*mh.DecodedMultihash, error) {
-	remoteFingerprintMultibase, err := maddr.ValueForProtocol(ma.P_CERTHASH)
-	if err != nil {
-		return nil, err
+// decodeRemoteFingerprints decodes the certhashes of maddr. A listener
+// advertises multiple certhashes while it rotates its certificate.
+func decodeRemoteFingerprints(maddr ma.Multiaddr) ([]mh.DecodedMultihash, error) {
+	var fingerprints []mh.DecodedMultihash
+	for _, c := range maddr {
+		if c.Protocol().Code != ma.P_CERTHASH {
+			continue
+		}
+		_, data, err := multibase.Decode(c.Value())
+		if err != nil {
+			return nil, err
+		}
+		dh, err := mh.Decode(data)
+		if err != nil {
+			return nil, err
+		}
+		fingerprints = append(fingerprints, *dh)
+	}
+	if len(fingerprints) == 0 {
+		return nil, errors.New("no certhash")
 	}
-	_, data, err := multibase.Decode(remoteFingerprintMultibase)
+	return fingerprints, nil
+}
+
+// verifyRemoteCertificate checks that the remote certificate matches one of
+// the certhashes, and returns the hash function of the matching certhash.
+func verifyRemoteCertificate(raw []byte, fingerprints []mh.DecodedMultihash) (crypto.Hash, error) {
+	cert, err := x509.ParseCertificate(raw)
 	if err != nil {
-		return nil, err
+		return 0, err
+	}
+	for _, fp := range fingerprints {
+		hash, ok := getSupportedSDPHash(fp.Code)
+		if !ok {
+			continue
+		}
+		digest, err := parseFingerprint(cert, hash)
+		if err != nil {
+			return 0, err
+		}
+		if bytes.Equal(digest, fp.Digest) {
+			return hash, nil
+		}
 	}
-	return mh.Decode(data)
+	return 0, errors.New("certificate verification failed: remote certificate doesn't match any certhash")
 }
 
 func encodeDTLSFingerprint(fp webrtc.DTLSFingerprint) (string, error) {
@@ -0,0 +1,66 @@
+package libp2pwebrtc
+
+import (
+	"fmt"
+	"time"
+)
+
+// streamStallTimeout is the time a write can wait for space in the send
+// buffer before the stream is considered stalled.
+var streamStallTimeout = 10 * time.Second
+
+// streamConfig configures the flow control of the streams of a connection.
+type streamConfig struct {
+	// sendBufferSize is the maximum number of bytes enqueued on the data
+	// channel. Writes block once it is reached.
+	sendBufferSize int
+	// sendBufferLowThreshold is the number of buffered bytes below which
+	// blocked writes are resumed.
+	sendBufferLowThreshold int
+	// onStall is called when a write starts and stops being stalled.
+	onStall func(stalled bool)
+}
+
+// WithStreamSendBuffer sets the maximum number of bytes that are buffered per
+// stream for sending, and the threshold below which writes resume once the
+// buffer is full. The buffer is filled faster than the remote reads, for
+// example when sending to a slow browser; a larger buffer increases the
+// throughput on high latency links, at the cost of memory.
+// By default, the send buffer holds two messages of 16 KiB, and writes resume
+// as soon as a full message fits.
+func WithStreamSendBuffer(size, lowThreshold int) Option {
+	return func(t *WebRTCTransport) error {
+		if size < maxSendMessageSize {
+			return fmt.Errorf("send buffer size must be at least %d bytes", maxSendMessageSize)
+		}
+		if lowThreshold < 0 || lowThreshold >= size {
+			return fmt.Errorf("invalid send buffer low threshold: %d", lowThreshold)
+		}
+		t.streamSendBufferSize = size
+		t.streamSendBufferLowThreshold = lowThreshold
+		return nil
+	}
+}
+
+// WithReceiveBufferSize sets the size of the SCTP receive buffer of a
+// connection, i.e. the receive window shared by all its streams. The memory is
+// reserved with the resource manager for every connection. Once the buffer is
+// full, the remote can't send on any stream until the application reads.
+// The default is 10 messages of the maximum size, about 2.5 MiB.
+func WithReceiveBufferSize(size int) Option {
+	return func(t *WebRTCTransport) error {
+		if size < maxReceiveMessageSize {
+			return fmt.Errorf("receive buffer size must be at least %d bytes", maxReceiveMessageSize)
+		}
+		t.receiveBufferSize = size
+		return nil
+	}
+}
+
+func (t *WebRTCTransport) streamConfig() streamConfig {
+	return streamConfig{
+		sendBufferSize:         t.streamSendBufferSize,
+		sendBufferLowThreshold: t.streamSendBufferLowThreshold,
+		onStall:                t.trackStalledStream,
+	}
+}
@@ -0,0 +1,148 @@
+package libp2pwebrtc
+
+import (
+	"errors"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/p2p/metricshelper"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricNamespace = "libp2p_webrtc"
+
+// metrics are the metrics of a transport. Transports registering with the
+// same registry share the collectors.
+type metrics struct {
+	outboundConns    *prometheus.CounterVec
+	stalledStreams   prometheus.Gauge
+	conns            *prometheus.CounterVec
+	transferredBytes *prometheus.CounterVec
+	streamStalls     prometheus.Counter
+}
+
+func newMetrics(reg prometheus.Registerer) *metrics {
+	return &metrics{
+		outboundConns: register(reg, prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "outbound_connections_total",
+				Help:      "Established outgoing connections, by path (direct or turn)",
+			},
+			[]string{"path"},
+		)),
+		stalledStreams: register(reg, prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: metricNamespace,
+				Name:      "stalled_streams",
+				Help:      "Streams with a write blocked on a full send buffer for more than 10s",
+			},
+		)),
+		conns: register(reg, prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "connections_total",
+				Help:      "Established connections, by direction and the candidate types of the selected candidate pair",
+			},
+			[]string{"dir", "local_candidate", "remote_candidate"},
+		)),
+		transferredBytes: register(reg, prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "transferred_bytes_total",
+				Help:      "SCTP bytes transferred on closed connections, by direction (sent or received) and path (direct or turn)",
+			},
+			[]string{"direction", "path"},
+		)),
+		streamStalls: register(reg, prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Namespace: metricNamespace,
+				Name:      "stream_stalls_total",
+				Help:      "Writes blocked on a full send buffer for more than 10s",
+			},
+		)),
+	}
+}
+
+// register registers c with reg. If an identical collector is already
+// registered, that collector is returned instead.
+func register[C prometheus.Collector](reg prometheus.Registerer, c C) C {
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(C); ok {
+				return existing
+			}
+		}
+		panic(err)
+	}
+	return c
+}
+
+// WithMetrics enables the transport's metrics, registering them with reg. If
+// reg is nil, prometheus.DefaultRegisterer is used.
+//
+// A stream is reported as stalled while a write waits for space in its send
+// buffer for longer than 10s, usually because the remote doesn't read from it.
+//
+// Established connections are reported with the candidate types of the
+// selected candidate pair, which tells host-to-host from NAT traversed (srflx)
+// and relayed (relay) connections. Note that the listener only learns the
+// remote candidate from the dialer's connectivity checks, so it reports the
+// remote candidate as peer reflexive (prflx).
+// Only the path of outgoing connections is reported: TURN relays are
+// configured on the dialing side, and the listener can't distinguish relayed
+// from direct connections. The bytes transferred are reported when a
+// connection is closed. Use the connection's StatWebRTC stats for live data.
+func WithMetrics(reg prometheus.Registerer) Option {
+	return func(t *WebRTCTransport) error {
+		if reg == nil {
+			reg = prometheus.DefaultRegisterer
+		}
+		t.metrics = newMetrics(reg)
+		return nil
+	}
+}
+
+func (t *WebRTCTransport) trackOutboundConn(relayed bool) {
+	if t.metrics == nil {
+		return
+	}
+	path := "direct"
+	if relayed {
+		path = "turn"
+	}
+	t.metrics.outboundConns.WithLabelValues(path).Inc()
+}
+
+func (t *WebRTCTransport) trackConn(dir network.Direction, c *connection) {
+	if t.metrics == nil {
+		return
+	}
+	stats := c.stats.Stats()
+	t.metrics.conns.WithLabelValues(metricshelper.GetDirection(dir), stats.LocalCandidateType.String(), stats.RemoteCandidateType.String()).Inc()
+}
+
+func (t *WebRTCTransport) trackClosedConn(c *connection) {
+	if t.metrics == nil {
+		return
+	}
+	stats := c.stats.Stats()
+	path := "direct"
+	if stats.Relayed() {
+		path = "turn"
+	}
+	t.metrics.transferredBytes.WithLabelValues("sent", path).Add(float64(stats.BytesSent))
+	t.metrics.transferredBytes.WithLabelValues("received", path).Add(float64(stats.BytesReceived))
+}
+
+func (t *WebRTCTransport) trackStalledStream(stalled bool) {
+	if t.metrics == nil {
+		return
+	}
+	if stalled {
+		t.metrics.streamStalls.Inc()
+		t.metrics.stalledStreams.Inc()
+	} else {
+		t.metrics.stalledStreams.Dec()
+	}
+}
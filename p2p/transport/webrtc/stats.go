@@ -0,0 +1,80 @@
+package libp2pwebrtc
+
+import (
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+type statWebRTC struct{}
+
+// StatWebRTC is the key in network.ConnStats.Extra under which the transport
+// reports the statistics of a connection. The value is a *StatsReporter that
+// can be queried for the lifetime of the connection.
+var StatWebRTC = statWebRTC{}
+
+// ConnectionStats are the ICE and SCTP statistics of a WebRTC connection.
+type ConnectionStats struct {
+	// LocalCandidateType and RemoteCandidateType are the types of the
+	// candidates of the selected candidate pair. On the listener, which only
+	// learns the remote candidate from the peer's connectivity checks, the
+	// remote candidate is peer reflexive.
+	LocalCandidateType  webrtc.ICECandidateType
+	RemoteCandidateType webrtc.ICECandidateType
+	// RTT is the latest round trip time of the selected candidate pair,
+	// measured using STUN.
+	RTT time.Duration
+	// SmoothedRTT is the round trip time estimated by SCTP.
+	SmoothedRTT time.Duration
+	// BytesSent and BytesReceived are the SCTP bytes sent and received on
+	// the connection.
+	BytesSent     uint64
+	BytesReceived uint64
+	// CongestionWindow is the SCTP congestion window in bytes.
+	CongestionWindow uint32
+	// STUNRequestsSent and STUNResponsesReceived count the connectivity
+	// checks sent on the selected candidate pair and the responses received.
+	// Checks without a response were lost or timed out.
+	STUNRequestsSent      uint64
+	STUNResponsesReceived uint64
+}
+
+// Relayed returns true if the connection is relayed through a TURN server.
+func (s ConnectionStats) Relayed() bool {
+	return s.LocalCandidateType == webrtc.ICECandidateTypeRelay || s.RemoteCandidateType == webrtc.ICECandidateTypeRelay
+}
+
+// StatsReporter reports the statistics of a WebRTC connection. The
+// statistics are collected from the peer connection on every call.
+type StatsReporter struct {
+	pc *webrtc.PeerConnection
+}
+
+// Stats returns the current statistics of the connection. Statistics that
+// aren't available, for example after the connection was closed, are zero.
+func (r *StatsReporter) Stats() ConnectionStats {
+	var s ConnectionStats
+	ice := r.pc.SCTP().Transport().ICETransport()
+	if cp, err := ice.GetSelectedCandidatePair(); err == nil && cp != nil {
+		s.LocalCandidateType = cp.Local.Typ
+		s.RemoteCandidateType = cp.Remote.Typ
+	}
+	if cps, ok := ice.GetSelectedCandidatePairStats(); ok {
+		s.RTT = secondsToDuration(cps.CurrentRoundTripTime)
+		s.STUNRequestsSent = cps.RequestsSent
+		s.STUNResponsesReceived = cps.ResponsesReceived
+	}
+	for _, st := range r.pc.GetStats() {
+		if sctp, ok := st.(webrtc.SCTPTransportStats); ok {
+			s.SmoothedRTT = secondsToDuration(sctp.SmoothedRoundTripTime)
+			s.BytesSent = sctp.BytesSent
+			s.BytesReceived = sctp.BytesReceived
+			s.CongestionWindow = sctp.CongestionWindow
+		}
+	}
+	return s
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -77,12 +77,15 @@ type stream struct {
 	nextMessage  *pb.Message
 	receiveState receiveState
 
-	writer             pbio.Writer // concurrent writes prevented by mx
-	writeStateChanged  chan struct{}
-	sendState          sendState
-	writeDeadline      time.Time
-	writeError         error
-	maxSendMessageSize int
+	writer              pbio.Writer // concurrent writes prevented by mx
+	writeStateChanged   chan struct{}
+	sendState           sendState
+	writeDeadline       time.Time
+	writeError          error
+	maxSendMessageSize  int
+	sendBufSize         int
+	sendBufLowThreshold int
+	onStall             func(stalled bool)
 
 	controlMessageReaderOnce sync.Once
 	// controlMessageReaderEndTime is the end time for reading FIN_ACK from the control
@@ -115,6 +118,10 @@ func newStream(
 		dataChannel:        rwc.(*datachannel.DataChannel),
 		onDone:             onDone,
 		maxSendMessageSize: maxSendMessageSize,
+		// By default, we buffer 2 messages, and want a notification as soon
+		// as we can write 1 full sized message.
+		sendBufSize:         2 * maxSendMessageSize,
+		sendBufLowThreshold: maxSendMessageSize,
 	}
 	s.dataChannel.SetBufferedAmountLowThreshold(uint64(s.sendBufferLowThreshold()))
 	s.dataChannel.OnBufferedAmountLow(func() {
@@ -123,6 +130,19 @@ func newStream(
 	return s
 }
 
+// applyConfig applies the flow control configuration. It must be called
+// before the stream is used.
+func (s *stream) applyConfig(cfg streamConfig) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if cfg.sendBufferSize > 0 {
+		s.sendBufSize = cfg.sendBufferSize
+		s.sendBufLowThreshold = cfg.sendBufferLowThreshold
+		s.dataChannel.SetBufferedAmountLowThreshold(uint64(s.sendBufLowThreshold))
+	}
+	s.onStall = cfg.onStall
+}
+
 func (s *stream) Close() error {
 	s.mx.Lock()
 	isClosed := s.closeForShutdownErr != nil
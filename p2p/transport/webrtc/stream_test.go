@@ -573,3 +573,46 @@ func TestStreamChunking(t *testing.T) {
 		})
 	}
 }
+
+func TestStreamStall(t *testing.T) {
+	timeout := streamStallTimeout
+	streamStallTimeout = 100 * time.Millisecond
+	defer func() { streamStallTimeout = timeout }()
+
+	client, server := getDetachedDataChannels(t)
+	defer client.dc.Close()
+	defer server.dc.Close()
+
+	stalls := make(chan bool, 10)
+	clientStr := newStream(client.dc, client.rwc, maxSendMessageSize, nil)
+	clientStr.applyConfig(streamConfig{
+		sendBufferSize:         4 * maxSendMessageSize,
+		sendBufferLowThreshold: 2 * maxSendMessageSize,
+		onStall:                func(stalled bool) { stalls <- stalled },
+	})
+	require.Equal(t, uint64(2*maxSendMessageSize), client.dc.BufferedAmountLowThreshold())
+	serverStr := newStream(server.dc, server.rwc, maxSendMessageSize, nil)
+
+	// The server doesn't read, so the SCTP receive window fills up, and the
+	// client's write stalls.
+	data := make([]byte, 4<<20)
+	go func() {
+		_, err := clientStr.Write(data)
+		assert.NoError(t, err)
+	}()
+	select {
+	case stalled := <-stalls:
+		require.True(t, stalled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the write to stall")
+	}
+
+	_, err := io.ReadFull(serverStr, make([]byte, len(data)))
+	require.NoError(t, err)
+	select {
+	case stalled := <-stalls:
+		require.False(t, stalled)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the write to resume")
+	}
+}
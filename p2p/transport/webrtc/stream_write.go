@@ -39,6 +39,17 @@ func (s *stream) Write(b []byte) (int, error) {
 			writeDeadlineTimer.Stop()
 		}
 	}()
+	// stallTimer fires if we wait for space in the send buffer for too long.
+	var stallTimer *time.Timer
+	var stalled bool
+	defer func() {
+		if stallTimer != nil {
+			stallTimer.Stop()
+		}
+		if stalled {
+			s.setStalled(false)
+		}
+	}()
 
 	var n int
 	var msg pb.Message
@@ -74,16 +85,37 @@ func (s *stream) Write(b []byte) (int, error) {
 
 		availableSpace := s.availableSendSpace()
 		if availableSpace < minMessageSize {
+			if stallTimer == nil && !stalled {
+				stallTimer = time.NewTimer(streamStallTimeout)
+			}
+			var stallChan <-chan time.Time
+			if stallTimer != nil {
+				stallChan = stallTimer.C
+			}
 			s.mx.Unlock()
 			select {
 			case <-writeDeadlineChan:
 				s.mx.Lock()
 				return n, os.ErrDeadlineExceeded
+			case <-stallChan:
+				s.mx.Lock()
+				stallTimer = nil
+				stalled = true
+				s.setStalled(true)
+				continue
 			case <-s.writeStateChanged:
 			}
 			s.mx.Lock()
 			continue
 		}
+		if stallTimer != nil {
+			stallTimer.Stop()
+			stallTimer = nil
+		}
+		if stalled {
+			stalled = false
+			s.setStalled(false)
+		}
 		end := s.maxSendMessageSize
 		if end > availableSpace {
 			end = availableSpace
@@ -114,13 +146,25 @@ func (s *stream) SetWriteDeadline(t time.Time) error {
 // The underlying SCTP layer has an unbounded buffer for writes. We limit the amount enqueued
 // per stream is limited to avoid a single stream monopolizing the entire connection.
 func (s *stream) sendBufferSize() int {
-	return 2 * s.maxSendMessageSize
+	return s.sendBufSize
 }
 
 // sendBufferLowThreshold() is the threshold below which we write more data on the underlying
-// data channel. We want a notification as soon as we can write 1 full sized message.
+// data channel.
 func (s *stream) sendBufferLowThreshold() int {
-	return s.sendBufferSize() - s.maxSendMessageSize
+	return s.sendBufLowThreshold
+}
+
+// setStalled reports that a write started or stopped waiting for space in the
+// send buffer for longer than streamStallTimeout, usually because the remote
+// doesn't read from the stream.
+func (s *stream) setStalled(stalled bool) {
+	if stalled {
+		log.Debugw("write stalled: send buffer is full", "stream", s.id, "buffered", s.dataChannel.BufferedAmount())
+	}
+	if s.onStall != nil {
+		s.onStall(stalled)
+	}
 }
 
 func (s *stream) availableSendSpace() int {
@@ -5,8 +5,6 @@ package libp2pwebrtc
 import (
 	"context"
 	"crypto"
-	"crypto/ecdsa"
-	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/x509"
 	"errors"
@@ -18,6 +16,7 @@ import (
 
 	"google.golang.org/protobuf/proto"
 
+	"github.com/benbjohnson/clock"
 	"github.com/libp2p/go-libp2p/core/connmgr"
 	ic "github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/network"
@@ -78,12 +77,11 @@ const (
 )
 
 type WebRTCTransport struct {
-	webrtcConfig webrtc.Configuration
-	rcmgr        network.ResourceManager
-	gater        connmgr.ConnectionGater
-	privKey      ic.PrivKey
-	noiseTpt     *noise.Transport
-	localPeerId  peer.ID
+	rcmgr       network.ResourceManager
+	gater       connmgr.ConnectionGater
+	privKey     ic.PrivKey
+	noiseTpt    *noise.Transport
+	localPeerId peer.ID
 
 	listenUDP func(network string, laddr *net.UDPAddr) (net.PacketConn, error)
 
@@ -92,9 +90,24 @@ type WebRTCTransport struct {
 
 	// in-flight connections
 	maxInFlightConnections uint32
+
+	// TURN servers used when dialing
+	iceServers []webrtc.ICEServer
+
+	metrics *metrics // nil if metrics are disabled
+
+	clock       clock.Clock
+	certSeed    []byte
+	certManager *certManager
+
+	// flow control
+	receiveBufferSize            int
+	streamSendBufferSize         int
+	streamSendBufferLowThreshold int
 }
 
 var _ tpt.Transport = &WebRTCTransport{}
+var _ tpt.CapabilityProvider = &WebRTCTransport{}
 
 type Option func(*WebRTCTransport) error
 
@@ -118,39 +131,16 @@ func New(privKey ic.PrivKey, psk pnet.PSK, gater connmgr.ConnectionGater, rcmgr
 	if err != nil {
 		return nil, fmt.Errorf("get local peer ID: %w", err)
 	}
-	// We use elliptic P-256 since it is widely supported by browsers.
-	//
-	// Implementation note: Testing with the browser,
-	// it seems like Chromium only supports ECDSA P-256 or RSA key signatures in the webrtc TLS certificate.
-	// We tried using P-228 and P-384 which caused the DTLS handshake to fail with Illegal Parameter
-	//
-	// Please refer to this is a list of suggested algorithms for the WebCrypto API.
-	// The algorithm for generating a certificate for an RTCPeerConnection
-	// must adhere to the WebCrpyto API. From my observation,
-	// RSA and ECDSA P-256 is supported on almost all browsers.
-	// Ed25519 is not present on the list.
-	pk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
-	if err != nil {
-		return nil, fmt.Errorf("generate key for cert: %w", err)
-	}
-	cert, err := webrtc.GenerateCertificate(pk)
-	if err != nil {
-		return nil, fmt.Errorf("generate certificate: %w", err)
-	}
-	config := webrtc.Configuration{
-		Certificates: []webrtc.Certificate{*cert},
-	}
 	noiseTpt, err := noise.New(noise.ID, privKey, nil)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create noise transport: %w", err)
 	}
 	transport := &WebRTCTransport{
-		rcmgr:        rcmgr,
-		gater:        gater,
-		webrtcConfig: config,
-		privKey:      privKey,
-		noiseTpt:     noiseTpt,
-		localPeerId:  localPeerID,
+		rcmgr:       rcmgr,
+		gater:       gater,
+		privKey:     privKey,
+		noiseTpt:    noiseTpt,
+		localPeerId: localPeerID,
 
 		listenUDP: listenUDP,
 		peerConnectionTimeouts: iceTimeouts{
@@ -160,15 +150,63 @@ func New(privKey ic.PrivKey, psk pnet.PSK, gater connmgr.ConnectionGater, rcmgr
 		},
 
 		maxInFlightConnections: DefaultMaxInFlightConnections,
+
+		clock: clock.New(),
+
+		receiveBufferSize: sctpReceiveBufferSize,
 	}
 	for _, opt := range opts {
 		if err := opt(transport); err != nil {
 			return nil, err
 		}
 	}
+	if transport.certSeed != nil {
+		transport.certManager, err = newCertManager(transport.certSeed, transport.clock)
+	} else {
+		transport.certManager, err = newStaticCertManager()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("generate certificate: %w", err)
+	}
 	return transport, nil
 }
 
+// WithCertSeed derives the DTLS certificates from seed, and rotates them
+// weekly. By default, a random certificate is generated when the transport is
+// created. Derived certificates are deterministic, so persisting the seed
+// keeps the certhashes of the listen addresses stable across restarts. During
+// the last day before a rotation, the listen addresses contain the certhash
+// of the next certificate as well. Use GenerateCertSeed to create a seed.
+func WithCertSeed(seed []byte) Option {
+	return func(t *WebRTCTransport) error {
+		if len(seed) < certSeedLen {
+			return fmt.Errorf("cert seed too short: expected at least %d bytes", certSeedLen)
+		}
+		t.certSeed = seed
+		return nil
+	}
+}
+
+const certSeedLen = 32
+
+// GenerateCertSeed generates a random seed that can be used with WithCertSeed.
+func GenerateCertSeed() ([]byte, error) {
+	seed := make([]byte, certSeedLen)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+	return seed, nil
+}
+
+// WithClock sets the clock used to rotate the certificates derived from the
+// seed set by WithCertSeed.
+func WithClock(cl clock.Clock) Option {
+	return func(t *WebRTCTransport) error {
+		t.clock = cl
+		return nil
+	}
+}
+
 func (t *WebRTCTransport) ListenOrder() int {
 	return libp2pquic.ListenOrder + 1 // We want to listen after QUIC listens so we can possibly reuse the same port.
 }
@@ -181,6 +219,13 @@ func (t *WebRTCTransport) Proxy() bool {
 	return false
 }
 
+// Capabilities returns the capabilities of the transport. The ICE connectivity
+// checks of both sides open the NAT mappings when they dial each other at the
+// same time, so WebRTC addresses take part in hole punching.
+func (t *WebRTCTransport) Capabilities() tpt.Capabilities {
+	return tpt.CapabilityHolePunching | tpt.CapabilityBrowser
+}
+
 func (t *WebRTCTransport) CanDial(addr ma.Multiaddr) bool {
 	isValid, n := IsWebRTCDirectMultiaddr(addr)
 	return isValid && n > 0
@@ -224,31 +269,25 @@ func (t *WebRTCTransport) listenSocket(socket net.PacketConn) (tpt.Listener, err
 	if err != nil {
 		return nil, err
 	}
-
-	listenerFingerprint, err := t.getCertificateFingerprint()
-	if err != nil {
-		return nil, err
-	}
-
-	encodedLocalFingerprint, err := encodeDTLSFingerprint(listenerFingerprint)
-	if err != nil {
-		return nil, err
-	}
-
-	certComp, err := ma.NewComponent(ma.ProtocolWithCode(ma.P_CERTHASH).Name, encodedLocalFingerprint)
-	if err != nil {
-		return nil, err
-	}
-	listenerMultiaddr = listenerMultiaddr.AppendComponent(webrtcComponent, certComp)
+	listenerMultiaddr = listenerMultiaddr.AppendComponent(webrtcComponent)
 
 	return newListener(
 		t,
 		listenerMultiaddr,
 		socket,
-		t.webrtcConfig,
 	)
 }
 
+// connectionConfig returns the configuration for a new peer connection, using
+// the current certificate.
+func (t *WebRTCTransport) connectionConfig() (webrtc.Configuration, error) {
+	cert, err := t.certManager.Current()
+	if err != nil {
+		return webrtc.Configuration{}, err
+	}
+	return webrtc.Configuration{Certificates: []webrtc.Certificate{cert.cert}}, nil
+}
+
 func (t *WebRTCTransport) Dial(ctx context.Context, remoteMultiaddr ma.Multiaddr, p peer.ID) (tpt.CapableConn, error) {
 	scope, err := t.rcmgr.OpenConnection(network.DirOutbound, false, remoteMultiaddr)
 	if err != nil {
@@ -280,12 +319,20 @@ func (t *WebRTCTransport) dial(ctx context.Context, scope network.ConnManagement
 		}
 	}()
 
-	remoteMultihash, err := decodeRemoteFingerprint(remoteMultiaddr)
+	remoteMultihashes, err := decodeRemoteFingerprints(remoteMultiaddr)
 	if err != nil {
 		return nil, fmt.Errorf("decode fingerprint: %w", err)
 	}
-	remoteHashFunction, ok := getSupportedSDPHash(remoteMultihash.Code)
-	if !ok {
+	// The SDP only contains a single fingerprint. Since the listener might
+	// use any of the certificates, we verify the certificate ourselves.
+	var remoteMultihash *multihash.DecodedMultihash
+	for i, h := range remoteMultihashes {
+		if _, ok := getSupportedSDPHash(h.Code); ok {
+			remoteMultihash = &remoteMultihashes[i]
+			break
+		}
+	}
+	if remoteMultihash == nil {
 		return nil, fmt.Errorf("unsupported hash function: %w", nil)
 	}
 
@@ -310,6 +357,7 @@ func (t *WebRTCTransport) dial(ctx context.Context, scope network.ConnManagement
 		LoggerFactory: pionLoggerFactory,
 	}
 	settingEngine.SetICECredentials(ufrag, ufrag)
+	settingEngine.DisableCertificateFingerprintVerification(true)
 	settingEngine.DetachDataChannels()
 	// use the first best address candidate
 	settingEngine.SetPrflxAcceptanceMinWait(0)
@@ -324,12 +372,17 @@ func (t *WebRTCTransport) dial(ctx context.Context, scope network.ConnManagement
 	// If you run pion on a system with only the loopback interface UP,
 	// it will not connect to anything.
 	settingEngine.SetIncludeLoopbackCandidate(true)
-	settingEngine.SetSCTPMaxReceiveBufferSize(sctpReceiveBufferSize)
-	if err := scope.ReserveMemory(sctpReceiveBufferSize, network.ReservationPriorityMedium); err != nil {
+	settingEngine.SetSCTPMaxReceiveBufferSize(uint32(t.receiveBufferSize))
+	if err := scope.ReserveMemory(t.receiveBufferSize, network.ReservationPriorityMedium); err != nil {
 		return nil, err
 	}
 
-	w, err = newWebRTCConnection(settingEngine, t.webrtcConfig)
+	config, err := t.connectionConfig()
+	if err != nil {
+		return nil, err
+	}
+	config.ICEServers = t.iceServers
+	w, err = newWebRTCConnection(settingEngine, config)
 	if err != nil {
 		return nil, fmt.Errorf("instantiating peer connection failed: %w", err)
 	}
@@ -368,6 +421,11 @@ func (t *WebRTCTransport) dial(ctx context.Context, scope network.ConnManagement
 		return nil, errors.New("peerconnection opening timed out")
 	}
 
+	remoteHashFunction, err := verifyRemoteCertificate(w.PeerConnection.SCTP().Transport().GetRemoteCertificate(), remoteMultihashes)
+	if err != nil {
+		return nil, err
+	}
+
 	// We are connected, run the noise handshake
 	detached, err := detachHandshakeDataChannel(ctx, w.HandshakeDataChannel)
 	if err != nil {
@@ -415,6 +473,8 @@ func (t *WebRTCTransport) dial(ctx context.Context, scope network.ConnManagement
 	if t.gater != nil && !t.gater.InterceptSecured(network.DirOutbound, p, conn) {
 		return nil, fmt.Errorf("secured connection gated")
 	}
+	t.trackOutboundConn(isRelayed(cp))
+	t.trackConn(network.DirOutbound, conn)
 	return conn, nil
 }
 
@@ -439,14 +499,6 @@ func genUfrag() string {
 	return string(b)
 }
 
-func (t *WebRTCTransport) getCertificateFingerprint() (webrtc.DTLSFingerprint, error) {
-	fps, err := t.webrtcConfig.Certificates[0].GetFingerprints()
-	if err != nil {
-		return webrtc.DTLSFingerprint{}, err
-	}
-	return fps[0], nil
-}
-
 func (t *WebRTCTransport) generateNoisePrologue(pc *webrtc.PeerConnection, hash crypto.Hash, inbound bool) ([]byte, error) {
 	raw := pc.SCTP().Transport().GetRemoteCertificate()
 	cert, err := x509.ParseCertificate(raw)
@@ -456,10 +508,14 @@ func (t *WebRTCTransport) generateNoisePrologue(pc *webrtc.PeerConnection, hash
 
 	// NOTE: should we want we can fork the cert code as well to avoid
 	// all the extra allocations due to unneeded string interspersing (hex)
-	localFp, err := t.getCertificateFingerprint()
+	localParams, err := pc.SCTP().Transport().GetLocalParameters()
 	if err != nil {
 		return nil, err
 	}
+	if len(localParams.Fingerprints) == 0 {
+		return nil, errors.New("no local certificate fingerprint")
+	}
+	localFp := localParams.Fingerprints[0]
 
 	remoteFpBytes, err := parseFingerprint(cert, hash)
 	if err != nil {
@@ -522,22 +578,14 @@ func (t *WebRTCTransport) noiseHandshake(ctx context.Context, pc *webrtc.PeerCon
 	return secureConn.RemotePublicKey(), nil
 }
 
+// AddCertHashes adds the certhashes of the certificates to addr, see
+// certManager.Certhashes.
 func (t *WebRTCTransport) AddCertHashes(addr ma.Multiaddr) (ma.Multiaddr, bool) {
-	listenerFingerprint, err := t.getCertificateFingerprint()
-	if err != nil {
-		return nil, false
-	}
-
-	encodedLocalFingerprint, err := encodeDTLSFingerprint(listenerFingerprint)
-	if err != nil {
-		return nil, false
-	}
-
-	certComp, err := ma.NewComponent(ma.ProtocolWithCode(ma.P_CERTHASH).Name, encodedLocalFingerprint)
+	certhashes, err := t.certManager.Certhashes()
 	if err != nil {
 		return nil, false
 	}
-	return addr.AppendComponent(certComp), true
+	return addr.Encapsulate(certhashes), true
 }
 
 type netConnWrapper struct {
@@ -15,6 +15,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/benbjohnson/clock"
 	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
@@ -23,6 +24,9 @@ import (
 	manet "github.com/multiformats/go-multiaddr/net"
 	"github.com/multiformats/go-multibase"
 	"github.com/multiformats/go-multihash"
+	"github.com/pion/webrtc/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	quicproxy "github.com/quic-go/quic-go/integrationtests/tools/proxy"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -1047,3 +1051,228 @@ func TestConnectionClosedWhenRemoteCloses(t *testing.T) {
 	c.Close()
 	wg.Wait()
 }
+
+func TestTURNServersOption(t *testing.T) {
+	privKey, _, err := crypto.GenerateKeyPair(crypto.Ed25519, -1)
+	require.NoError(t, err)
+	_, err = New(privKey, nil, nil, nil, netListenUDP, WithTURNServers(TURNServer{URL: "stun:stun.example.com"}))
+	require.Error(t, err)
+	tr, err := New(privKey, nil, nil, nil, netListenUDP, WithTURNServers(
+		TURNServer{URL: "turn:turn.example.com:3478?transport=udp", Username: "user", Credential: "pass"},
+		TURNServer{URL: "turns:turn.example.com:5349"},
+	))
+	require.NoError(t, err)
+	require.Len(t, tr.iceServers, 2)
+}
+
+func TestMetricsDirectPath(t *testing.T) {
+	tr, listeningPeer := getTransport(t)
+	reg := prometheus.NewRegistry()
+	// The TURN server isn't reachable, the connection is established directly.
+	tr1, _ := getTransport(t, WithMetrics(reg), WithTURNServers(TURNServer{URL: "turn:127.0.0.1:1?transport=tcp", Username: "user", Credential: "pass"}))
+	direct := testutil.ToFloat64(tr1.metrics.outboundConns.WithLabelValues("direct"))
+	relayed := testutil.ToFloat64(tr1.metrics.outboundConns.WithLabelValues("turn"))
+
+	listener, err := tr.Listen(ma.StringCast("/ip4/127.0.0.1/udp/0/webrtc-direct"))
+	require.NoError(t, err)
+	defer listener.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		<-done
+	}()
+
+	conn, err := tr1.Dial(context.Background(), listener.Multiaddr(), listeningPeer)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Equal(t, direct+1, testutil.ToFloat64(tr1.metrics.outboundConns.WithLabelValues("direct")))
+	require.Equal(t, relayed, testutil.ToFloat64(tr1.metrics.outboundConns.WithLabelValues("turn")))
+}
+
+func TestConnectionStats(t *testing.T) {
+	tr, listeningPeer := getTransport(t)
+	tr1, _ := getTransport(t, WithMetrics(prometheus.NewRegistry()))
+	hostConns := testutil.ToFloat64(tr1.metrics.conns.WithLabelValues("outbound", "host", "host"))
+
+	listener, err := tr.Listen(ma.StringCast("/ip4/127.0.0.1/udp/0/webrtc-direct"))
+	require.NoError(t, err)
+	defer listener.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		str, err := conn.AcceptStream()
+		if err != nil {
+			return
+		}
+		io.Copy(io.Discard, str)
+		str.Close()
+		<-done
+	}()
+
+	conn, err := tr1.Dial(context.Background(), listener.Multiaddr(), listeningPeer)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Equal(t, hostConns+1, testutil.ToFloat64(tr1.metrics.conns.WithLabelValues("outbound", "host", "host")))
+
+	str, err := conn.OpenStream(context.Background())
+	require.NoError(t, err)
+	_, err = str.Write(make([]byte, 10000))
+	require.NoError(t, err)
+	require.NoError(t, str.CloseWrite())
+
+	reporter, ok := conn.(network.ConnStat).Stat().Extra[StatWebRTC].(*StatsReporter)
+	require.True(t, ok)
+	require.Eventually(t, func() bool {
+		return reporter.Stats().BytesSent >= 10000
+	}, 5*time.Second, 10*time.Millisecond)
+	stats := reporter.Stats()
+	require.Equal(t, webrtc.ICECandidateTypeHost, stats.LocalCandidateType)
+	require.Equal(t, webrtc.ICECandidateTypeHost, stats.RemoteCandidateType)
+	require.False(t, stats.Relayed())
+	require.NotZero(t, stats.BytesReceived)
+}
+
+func getCerthashes(t *testing.T, addr ma.Multiaddr) []string {
+	t.Helper()
+	var certhashes []string
+	for _, c := range addr {
+		if c.Protocol().Code == ma.P_CERTHASH {
+			certhashes = append(certhashes, c.Value())
+		}
+	}
+	return certhashes
+}
+
+func TestCertSeed(t *testing.T) {
+	seed, err := GenerateCertSeed()
+	require.NoError(t, err)
+	cl := clock.NewMock()
+	// Two days into a rotation period.
+	cl.Set(time.Unix(0, 0).Add(1000*certRotationPeriod + 48*time.Hour))
+	tr1, _ := getTransport(t, WithCertSeed(seed), WithClock(cl))
+	tr2, _ := getTransport(t, WithCertSeed(seed), WithClock(cl))
+	tr3, _ := getTransport(t)
+
+	addr := ma.StringCast("/ip4/1.2.3.4/udp/1/webrtc-direct")
+	addr1, ok := tr1.AddCertHashes(addr)
+	require.True(t, ok)
+	require.Len(t, getCerthashes(t, addr1), 1)
+	addr2, ok := tr2.AddCertHashes(addr)
+	require.True(t, ok)
+	require.Equal(t, addr1, addr2)
+	addr3, ok := tr3.AddCertHashes(addr)
+	require.True(t, ok)
+	require.Len(t, getCerthashes(t, addr3), 1)
+	require.NotEqual(t, addr1, addr3)
+
+	privKey, _, err := crypto.GenerateKeyPair(crypto.Ed25519, -1)
+	require.NoError(t, err)
+	_, err = New(privKey, nil, nil, nil, netListenUDP, WithCertSeed(seed[:16]))
+	require.Error(t, err)
+}
+
+func TestDeriveCertificate(t *testing.T) {
+	seed := make([]byte, certSeedLen)
+	c1, err := deriveCertificate(seed, 1000)
+	require.NoError(t, err)
+	c2, err := deriveCertificate(seed, 1000)
+	require.NoError(t, err)
+	require.Equal(t, c1.certhash, c2.certhash)
+	c3, err := deriveCertificate(seed, 1001)
+	require.NoError(t, err)
+	require.NotEqual(t, c1.certhash, c3.certhash)
+}
+
+func TestCertRotation(t *testing.T) {
+	seed, err := GenerateCertSeed()
+	require.NoError(t, err)
+	cl := clock.NewMock()
+	// 12 hours before the next rotation, within the overlap window. pion
+	// refuses to use expired certificates, so this can't be in the past.
+	nextRotation := (time.Now().UnixNano()/int64(certRotationPeriod) + 1) * int64(certRotationPeriod)
+	cl.Set(time.Unix(0, nextRotation).Add(-12 * time.Hour))
+	tr, listeningPeer := getTransport(t, WithCertSeed(seed), WithClock(cl))
+	ln, err := tr.Listen(ma.StringCast("/ip4/127.0.0.1/udp/0/webrtc-direct"))
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	addr := ln.Multiaddr()
+	certhashes := getCerthashes(t, addr)
+	require.Len(t, certhashes, 2)
+	withCerthash := func(certhash string) ma.Multiaddr {
+		a, _ := ma.SplitFunc(addr, func(c ma.Component) bool { return c.Protocol().Code == ma.P_CERTHASH })
+		return a.Encapsulate(ma.StringCast("/certhash/" + certhash))
+	}
+
+	dial := func(addr ma.Multiaddr) error {
+		tr1, _ := getTransport(t)
+		conn, err := tr1.Dial(context.Background(), addr, listeningPeer)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	require.NoError(t, dial(addr))
+	require.NoError(t, dial(withCerthash(certhashes[0])))
+	require.Error(t, dial(withCerthash(certhashes[1])))
+
+	cl.Add(24 * time.Hour)
+	newCerthashes := getCerthashes(t, ln.Multiaddr())
+	// The next certificate is only advertised shortly before the rotation.
+	require.Equal(t, certhashes[1:], newCerthashes)
+	// The address learned before the rotation is still valid.
+	require.NoError(t, dial(addr))
+	require.Error(t, dial(withCerthash(certhashes[0])))
+	require.NoError(t, dial(withCerthash(certhashes[1])))
+}
+
+func TestFlowControlOptions(t *testing.T) {
+	privKey, _, err := crypto.GenerateKeyPair(crypto.Ed25519, -1)
+	require.NoError(t, err)
+	_, err = New(privKey, nil, nil, nil, netListenUDP, WithStreamSendBuffer(1<<10, 0))
+	require.Error(t, err)
+	_, err = New(privKey, nil, nil, nil, netListenUDP, WithStreamSendBuffer(64<<10, 64<<10))
+	require.Error(t, err)
+	_, err = New(privKey, nil, nil, nil, netListenUDP, WithReceiveBufferSize(1<<10))
+	require.Error(t, err)
+
+	tr, err := New(privKey, nil, nil, nil, netListenUDP, WithStreamSendBuffer(64<<10, 32<<10), WithReceiveBufferSize(1<<20))
+	require.NoError(t, err)
+	cfg := tr.streamConfig()
+	require.Equal(t, 64<<10, cfg.sendBufferSize)
+	require.Equal(t, 32<<10, cfg.sendBufferLowThreshold)
+	require.Equal(t, 1<<20, tr.receiveBufferSize)
+}
+
+func TestMetricsPerRegistry(t *testing.T) {
+	reg1 := prometheus.NewRegistry()
+	reg2 := prometheus.NewRegistry()
+	tr1, _ := getTransport(t, WithMetrics(reg1))
+	tr2, _ := getTransport(t, WithMetrics(reg1))
+	tr3, _ := getTransport(t, WithMetrics(reg2))
+	require.Same(t, tr1.metrics.outboundConns, tr2.metrics.outboundConns)
+	require.NotSame(t, tr1.metrics.outboundConns, tr3.metrics.outboundConns)
+
+	tr1.trackOutboundConn(false)
+	require.Equal(t, 1.0, testutil.ToFloat64(tr2.metrics.outboundConns.WithLabelValues("direct")))
+	require.Zero(t, testutil.ToFloat64(tr3.metrics.outboundConns.WithLabelValues("direct")))
+}
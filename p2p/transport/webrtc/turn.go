@@ -0,0 +1,49 @@
+package libp2pwebrtc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pion/webrtc/v4"
+)
+
+// TURNServer is a TURN server that is used to relay outgoing connections.
+type TURNServer struct {
+	// URL is the URL of the TURN server, for example
+	// turn:turn.example.com:3478?transport=udp or turns:turn.example.com:5349.
+	URL string
+	// Username and Credential are the long-term credentials used to
+	// authenticate with the TURN server.
+	Username   string
+	Credential string
+}
+
+// WithTURNServers configures TURN servers that are used when dialing. If a
+// direct connection to the remote peer can't be established, for example
+// because the local network blocks outgoing UDP traffic, the connection is
+// relayed through one of the TURN servers instead.
+//
+// Relayed connections are only used as a fallback: a direct path is always
+// preferred, and a relayed path is only selected if no direct path succeeded
+// within two seconds.
+func WithTURNServers(servers ...TURNServer) Option {
+	return func(t *WebRTCTransport) error {
+		for _, s := range servers {
+			if !strings.HasPrefix(s.URL, "turn:") && !strings.HasPrefix(s.URL, "turns:") {
+				return fmt.Errorf("invalid TURN server URL: %s", s.URL)
+			}
+			t.iceServers = append(t.iceServers, webrtc.ICEServer{
+				URLs:           []string{s.URL},
+				Username:       s.Username,
+				Credential:     s.Credential,
+				CredentialType: webrtc.ICECredentialTypePassword,
+			})
+		}
+		return nil
+	}
+}
+
+// isRelayed returns true if the selected candidate pair uses a TURN server.
+func isRelayed(cp *webrtc.ICECandidatePair) bool {
+	return cp.Local.Typ == webrtc.ICECandidateTypeRelay
+}
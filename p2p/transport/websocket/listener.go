@@ -43,6 +43,14 @@ type listener struct {
 	closeErr  error
 	closed    chan struct{}
 	wsurl     *url.URL
+
+	// external is set if connections are accepted by an http.Server owned by
+	// the application, see WithExternalServer. Upgraded connections are then
+	// passed to the gated listener through the queue.
+	external bool
+	queue    *handlerListener
+	gated    transport.GatedMaListener
+	onClose  func()
 }
 
 var _ transport.GatedMaListener = &listener{}
@@ -61,7 +69,8 @@ func (pwma *parsedWebsocketMultiaddr) toMultiaddr() ma.Multiaddr {
 
 // newListener creates a new listener from a raw net.Listener.
 // tlsConf may be nil (for unencrypted websockets).
-func newListener(a ma.Multiaddr, tlsConf *tls.Config, sharedTcp *tcpreuse.ConnMgr, upgrader transport.Upgrader, handshakeTimeout time.Duration) (*listener, error) {
+// If netListener is non-nil, it is used instead of opening a new socket.
+func newListener(a ma.Multiaddr, tlsConf *tls.Config, sharedTcp *tcpreuse.ConnMgr, netListener net.Listener, upgrader transport.Upgrader, handshakeTimeout time.Duration) (*listener, error) {
 	parsed, err := parseWebsocketMultiaddr(a)
 	if err != nil {
 		return nil, err
@@ -72,7 +81,13 @@ func newListener(a ma.Multiaddr, tlsConf *tls.Config, sharedTcp *tcpreuse.ConnMg
 	}
 
 	var gmal transport.GatedMaListener
-	if sharedTcp == nil {
+	if netListener != nil {
+		mal, err := manet.WrapNetListener(netListener)
+		if err != nil {
+			return nil, err
+		}
+		gmal = upgrader.GateMaListener(mal)
+	} else if sharedTcp == nil {
 		mal, err := manet.Listen(parsed.restMultiaddr)
 		if err != nil {
 			return nil, err
@@ -131,6 +146,42 @@ func newListener(a ma.Multiaddr, tlsConf *tls.Config, sharedTcp *tcpreuse.ConnMg
 	return ln, nil
 }
 
+// newExternalListener creates a listener for connections accepted by an
+// http.Server owned by the application, see WithExternalServer. It doesn't
+// open a socket.
+func newExternalListener(a ma.Multiaddr, upgrader transport.Upgrader, handshakeTimeout time.Duration) (*listener, error) {
+	parsed, err := parseWebsocketMultiaddr(a)
+	if err != nil {
+		return nil, err
+	}
+	laddr := parsed.toMultiaddr()
+	wsurl, err := parseMultiaddr(laddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse multiaddr to URL: %v: %w", laddr, err)
+	}
+	queue := &handlerListener{
+		laddr:  parsed.restMultiaddr,
+		conns:  make(chan manet.Conn),
+		closed: make(chan struct{}),
+	}
+	return &listener{
+		laddr:    laddr,
+		closed:   make(chan struct{}),
+		isWss:    parsed.isWSS,
+		wsurl:    wsurl,
+		external: true,
+		queue:    queue,
+		gated:    upgrader.GateMaListener(queue),
+		wsUpgrader: ws.Upgrader{
+			// Allow requests from *all* origins.
+			CheckOrigin: func(_ *http.Request) bool {
+				return true
+			},
+			HandshakeTimeout: handshakeTimeout,
+		},
+	}, nil
+}
+
 func (l *listener) serve() {
 	defer close(l.closed)
 	if !l.isWss {
@@ -175,6 +226,10 @@ func (l *listener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		// The upgrader writes a response for us.
 		return
 	}
+	if l.external {
+		l.serveExternal(c)
+		return
+	}
 	nc, err := l.extractConnFromContext(r.Context())
 	if err != nil {
 		c.Close()
@@ -206,7 +261,33 @@ func (l *listener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// The connection has been hijacked, it's safe to return.
 }
 
+// serveExternal passes a connection upgraded by the application's server to
+// the gated listener, which applies the connection gater and opens the
+// resource scope.
+func (l *listener) serveExternal(c *ws.Conn) {
+	conn := newConn(c, l.isWss, nil)
+	if conn == nil {
+		c.Close()
+		return
+	}
+	select {
+	case l.queue.conns <- conn:
+	case <-l.closed:
+		conn.Close()
+	}
+}
+
 func (l *listener) Accept() (manet.Conn, network.ConnManagementScope, error) {
+	if l.external {
+		c, scope, err := l.gated.Accept()
+		if err != nil {
+			return nil, nil, transport.ErrListenerClosed
+		}
+		if wc, ok := c.(*Conn); ok {
+			wc.Scope = scope
+		}
+		return c, scope, nil
+	}
 	select {
 	case c, ok := <-l.incoming:
 		if !ok {
@@ -224,6 +305,12 @@ func (l *listener) Addr() net.Addr {
 
 func (l *listener) Close() error {
 	l.closeOnce.Do(func() {
+		if l.external {
+			close(l.closed)
+			l.closeErr = l.gated.Close()
+			l.onClose()
+			return
+		}
 		err1 := l.netListener.Close()
 		err2 := l.server.Close()
 		<-l.closed
@@ -236,6 +323,40 @@ func (l *listener) Multiaddr() ma.Multiaddr {
 	return l.laddr
 }
 
+// handlerListener is a manet.Listener returning the connections upgraded by
+// the application's server.
+type handlerListener struct {
+	laddr     ma.Multiaddr
+	conns     chan manet.Conn
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+var _ manet.Listener = &handlerListener{}
+
+func (l *handlerListener) Accept() (manet.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.closed:
+		return nil, transport.ErrListenerClosed
+	}
+}
+
+func (l *handlerListener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *handlerListener) Addr() net.Addr {
+	a, _ := manet.ToNetAddr(l.laddr)
+	return a
+}
+
+func (l *handlerListener) Multiaddr() ma.Multiaddr {
+	return l.laddr
+}
+
 // httpNetListener is a net.Listener that adapts a transport.GatedMaListener to a net.Listener.
 // It wraps the manet.Conn, and the Scope from the underlying gated listener in a connWithScope.
 type httpNetListener struct {
@@ -4,7 +4,12 @@ package websocket
 import (
 	"context"
 	"crypto/tls"
+	"errors"
+	"fmt"
 	"net"
+	"net/http"
+	"net/url"
+	"sync"
 	"time"
 
 	"github.com/libp2p/go-libp2p/core/network"
@@ -82,6 +87,105 @@ func WithHandshakeTimeout(timeout time.Duration) Option {
 	}
 }
 
+// WithDialSNI overrides the TLS server name (SNI) sent when dialing secure
+// websocket addresses. By default, the /sni component of the multiaddr is
+// used, falling back to the host of the dialed address.
+//
+// Together with WithDialHost, this can be used to traverse fronting
+// infrastructure, where the SNI names the front and the Host header names the
+// actual destination.
+func WithDialSNI(sni string) Option {
+	return func(t *WebsocketTransport) error {
+		t.dialSNI = sni
+		return nil
+	}
+}
+
+// WithDialHost overrides the Host header of the HTTP upgrade request sent when
+// dialing.
+func WithDialHost(host string) Option {
+	return func(t *WebsocketTransport) error {
+		t.dialHost = host
+		return nil
+	}
+}
+
+// headers set by the websocket dialer itself, which can't be overridden.
+var reservedDialHeaders = []string{
+	"Upgrade",
+	"Connection",
+	"Sec-Websocket-Key",
+	"Sec-Websocket-Version",
+	"Sec-Websocket-Extensions",
+}
+
+// WithDialHeaders adds HTTP headers to the upgrade request sent when dialing,
+// for example to pass an authentication token or routing information to a
+// proxy or CDN in front of the peer. Headers controlling the websocket
+// handshake itself can't be set. Use WithDialHost to set the Host header.
+func WithDialHeaders(h http.Header) Option {
+	return func(t *WebsocketTransport) error {
+		for _, k := range reservedDialHeaders {
+			if _, ok := h[k]; ok {
+				return fmt.Errorf("websocket: header %s can't be set", k)
+			}
+		}
+		if _, ok := h["Host"]; ok {
+			return fmt.Errorf("websocket: use WithDialHost to set the Host header")
+		}
+		t.dialHeaders = h.Clone()
+		return nil
+	}
+}
+
+// WithProxy sets the function that selects the proxy used to dial an address.
+// By default, the proxy is taken from the environment: HTTPS_PROXY for secure
+// websockets and HTTP_PROXY for plain websockets, unless the address matches
+// NO_PROXY (see http.ProxyFromEnvironment). Use http.ProxyURL to always use
+// the same proxy.
+//
+// HTTP proxies are dialed using the CONNECT method, and SOCKS5 proxies are
+// supported as well. Credentials in the proxy URL are used to authenticate
+// with the proxy.
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) Option {
+	return func(t *WebsocketTransport) error {
+		t.proxy = proxy
+		return nil
+	}
+}
+
+// WithNetListener makes the transport use ln, a listener created by the
+// application, when listening on the address ln is bound to, instead of
+// opening a new socket. This allows the application to control how the socket
+// is created, for example when it's inherited from a process supervisor.
+// Closing the transport's listener closes ln.
+func WithNetListener(ln net.Listener) Option {
+	return func(t *WebsocketTransport) error {
+		if _, err := manet.FromNetAddr(ln.Addr()); err != nil {
+			return fmt.Errorf("websocket: unsupported listener address %s: %w", ln.Addr(), err)
+		}
+		t.netListener = ln
+		return nil
+	}
+}
+
+// WithExternalServer makes the transport accept websocket connections through
+// an http.Server owned by the application, instead of listening on its own
+// socket. This allows a single port, e.g. 443, to serve both a website and
+// libp2p. Peers send websocket upgrade requests to the root path, which the
+// application must route to the transport's Handler.
+// The application is responsible for TLS, so WithTLSConfig isn't needed to
+// listen on secure websocket addresses.
+// Listen doesn't open a socket in this mode. It only accepts a single listen
+// address, which should be the address the application's server is reachable
+// at.
+func WithExternalServer() Option {
+	return func(t *WebsocketTransport) error {
+		t.externalServer = true
+		return nil
+	}
+}
+
 // WebsocketTransport is the actual go-libp2p transport
 type WebsocketTransport struct {
 	upgrader         transport.Upgrader
@@ -90,9 +194,24 @@ type WebsocketTransport struct {
 	tlsConf          *tls.Config
 	sharedTcp        *tcpreuse.ConnMgr
 	handshakeTimeout time.Duration
+
+	dialSNI     string
+	dialHost    string
+	dialHeaders http.Header
+
+	proxy func(*http.Request) (*url.URL, error)
+
+	netListenerMx   sync.Mutex
+	netListener     net.Listener
+	netListenerUsed bool
+
+	externalServer     bool
+	externalListenerMx sync.Mutex
+	externalListener   *listener
 }
 
 var _ transport.Transport = (*WebsocketTransport)(nil)
+var _ transport.CapabilityProvider = (*WebsocketTransport)(nil)
 
 func New(u transport.Upgrader, rcmgr network.ResourceManager, sharedTCP *tcpreuse.ConnMgr, opts ...Option) (*WebsocketTransport, error) {
 	if rcmgr == nil {
@@ -125,6 +244,12 @@ func (t *WebsocketTransport) Proxy() bool {
 	return false
 }
 
+// Capabilities returns the capabilities of the transport. Note that browsers
+// only dial secure websocket addresses from secure contexts.
+func (t *WebsocketTransport) Capabilities() transport.Capabilities {
+	return transport.CapabilityBrowser
+}
+
 func (t *WebsocketTransport) Resolve(_ context.Context, maddr ma.Multiaddr) ([]ma.Multiaddr, error) {
 	parsed, err := parseWebsocketMultiaddr(maddr)
 	if err != nil {
@@ -177,10 +302,12 @@ func (t *WebsocketTransport) Dial(ctx context.Context, raddr ma.Multiaddr, p pee
 }
 
 func (t *WebsocketTransport) dialWithScope(ctx context.Context, raddr ma.Multiaddr, p peer.ID, connScope network.ConnManagementScope) (transport.CapableConn, error) {
+	start := time.Now()
 	macon, err := t.maDial(ctx, raddr, connScope)
 	if err != nil {
 		return nil, err
 	}
+	macon = &dialedConn{Conn: macon, connectTime: time.Since(start)}
 	conn, err := t.upgrader.Upgrade(ctx, t, macon, network.DirOutbound, p, connScope)
 	if err != nil {
 		return nil, err
@@ -188,6 +315,21 @@ func (t *WebsocketTransport) dialWithScope(ctx context.Context, raddr ma.Multiad
 	return &capableConn{CapableConn: conn}, nil
 }
 
+// dialedConn reports the time it took to establish an outbound connection,
+// including the WebSocket handshake, in its stats.
+type dialedConn struct {
+	manet.Conn
+	connectTime time.Duration
+}
+
+var _ network.ConnStat = &dialedConn{}
+
+func (c *dialedConn) Stat() network.ConnStats {
+	var stat network.ConnStats
+	stat.Handshake.Connect = c.connectTime
+	return stat
+}
+
 func (t *WebsocketTransport) maDial(ctx context.Context, raddr ma.Multiaddr, scope network.ConnManagementScope) (manet.Conn, error) {
 	wsurl, err := parseMultiaddr(raddr)
 	if err != nil {
@@ -196,8 +338,11 @@ func (t *WebsocketTransport) maDial(ctx context.Context, raddr ma.Multiaddr, sco
 	isWss := wsurl.Scheme == "wss"
 	dialer := ws.Dialer{
 		HandshakeTimeout: t.handshakeTimeout,
+		Proxy:            t.proxy,
+	}
+	if dialer.Proxy == nil {
 		// Inherit the default proxy behavior
-		Proxy: ws.DefaultDialer.Proxy,
+		dialer.Proxy = ws.DefaultDialer.Proxy
 	}
 	if isWss {
 		sni := ""
@@ -230,9 +375,13 @@ func (t *WebsocketTransport) maDial(ctx context.Context, raddr ma.Multiaddr, sco
 		} else {
 			dialer.TLSClientConfig = t.tlsClientConf
 		}
+		if t.dialSNI != "" {
+			dialer.TLSClientConfig = dialer.TLSClientConfig.Clone()
+			dialer.TLSClientConfig.ServerName = t.dialSNI
+		}
 	}
 
-	wscon, _, err := dialer.DialContext(ctx, wsurl.String(), nil)
+	wscon, _, err := dialer.DialContext(ctx, wsurl.String(), t.dialRequestHeader())
 	if err != nil {
 		return nil, err
 	}
@@ -245,12 +394,33 @@ func (t *WebsocketTransport) maDial(ctx context.Context, raddr ma.Multiaddr, sco
 	return mnc, nil
 }
 
+// dialRequestHeader returns the extra headers of the upgrade request, or nil
+// if there are none.
+func (t *WebsocketTransport) dialRequestHeader() http.Header {
+	if t.dialHost == "" {
+		return t.dialHeaders
+	}
+	h := t.dialHeaders.Clone()
+	if h == nil {
+		h = make(http.Header)
+	}
+	h.Set("Host", t.dialHost)
+	return h
+}
+
 func (t *WebsocketTransport) gatedMaListen(a ma.Multiaddr) (transport.GatedMaListener, error) {
+	if t.externalServer {
+		return t.listenExternal(a)
+	}
 	var tlsConf *tls.Config
 	if t.tlsConf != nil {
 		tlsConf = t.tlsConf.Clone()
 	}
-	l, err := newListener(a, tlsConf, t.sharedTcp, t.upgrader, t.handshakeTimeout)
+	netListener, err := t.netListenerFor(a)
+	if err != nil {
+		return nil, err
+	}
+	l, err := newListener(a, tlsConf, t.sharedTcp, netListener, t.upgrader, t.handshakeTimeout)
 	if err != nil {
 		return nil, err
 	}
@@ -258,6 +428,71 @@ func (t *WebsocketTransport) gatedMaListen(a ma.Multiaddr) (transport.GatedMaLis
 	return l, nil
 }
 
+// netListenerFor returns the listener configured using WithNetListener if it
+// is bound to the address a listens on. It can only be used once.
+func (t *WebsocketTransport) netListenerFor(a ma.Multiaddr) (net.Listener, error) {
+	if t.netListener == nil {
+		return nil, nil
+	}
+	parsed, err := parseWebsocketMultiaddr(a)
+	if err != nil {
+		return nil, err
+	}
+	laddr, err := manet.FromNetAddr(t.netListener.Addr())
+	if err != nil {
+		return nil, err
+	}
+	if !parsed.restMultiaddr.Equal(laddr) {
+		return nil, nil
+	}
+	t.netListenerMx.Lock()
+	defer t.netListenerMx.Unlock()
+	if t.netListenerUsed {
+		return nil, nil
+	}
+	t.netListenerUsed = true
+	return t.netListener, nil
+}
+
+func (t *WebsocketTransport) listenExternal(a ma.Multiaddr) (transport.GatedMaListener, error) {
+	t.externalListenerMx.Lock()
+	defer t.externalListenerMx.Unlock()
+	if t.externalListener != nil {
+		return nil, errors.New("websocket: already listening on the external server")
+	}
+	l, err := newExternalListener(a, t.upgrader, t.handshakeTimeout)
+	if err != nil {
+		return nil, err
+	}
+	l.onClose = func() {
+		t.externalListenerMx.Lock()
+		defer t.externalListenerMx.Unlock()
+		if t.externalListener == l {
+			t.externalListener = nil
+		}
+	}
+	t.externalListener = l
+	return l, nil
+}
+
+// Handler returns the http.Handler accepting websocket connections on the
+// server configured using WithExternalServer. Requests are rejected while the
+// transport isn't listening.
+// The connection gater and the resource manager are consulted after the
+// websocket upgrade.
+func (t *WebsocketTransport) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.externalListenerMx.Lock()
+		l := t.externalListener
+		t.externalListenerMx.Unlock()
+		if l == nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		l.ServeHTTP(w, r)
+	})
+}
+
 func (t *WebsocketTransport) Listen(a ma.Multiaddr) (transport.Listener, error) {
 	gmal, err := t.gatedMaListen(a)
 	if err != nil {
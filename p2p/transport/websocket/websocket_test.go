@@ -1,6 +1,7 @@
 package websocket
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"crypto/ecdsa"
@@ -10,12 +11,14 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
 	"math/big"
 	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
@@ -35,6 +38,7 @@ import (
 	ttransport "github.com/libp2p/go-libp2p/p2p/transport/testsuite"
 
 	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -254,6 +258,53 @@ func TestHostHeaderWss(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestDialSNIAndHeaders(t *testing.T) {
+	server := &http.Server{}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer server.Close()
+
+	type request struct {
+		sni, host, auth string
+	}
+	reqChan := make(chan request, 1)
+	tlsConf := getTLSConf(t, net.ParseIP("127.0.0.1"), time.Now(), time.Now().Add(time.Hour))
+	server.TLSConfig = tlsConf
+	server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqChan <- request{sni: r.TLS.ServerName, host: r.Host, auth: r.Header.Get("Authorization")}
+		w.WriteHeader(http.StatusNotFound)
+	})
+	go server.ServeTLS(l, "", "")
+
+	_, port, err := net.SplitHostPort(l.Addr().String())
+	require.NoError(t, err)
+	serverMA := ma.StringCast("/ip4/127.0.0.1/tcp/" + port + "/tls/sni/example.com/ws")
+
+	_, u := newSecureUpgrader(t)
+	tpt, err := New(u, &network.NullResourceManager{}, nil,
+		WithTLSClientConfig(&tls.Config{InsecureSkipVerify: true}), // Our test server doesn't have a cert signed by a CA
+		WithDialSNI("front.example.com"),
+		WithDialHost("backend.example.com"),
+		WithDialHeaders(http.Header{"Authorization": []string{"Bearer token"}}),
+	)
+	require.NoError(t, err)
+
+	_, err = tpt.Dial(context.Background(), serverMA, test.RandPeerIDFatal(t))
+	require.Error(t, err)
+	req := <-reqChan
+	require.Equal(t, "front.example.com", req.sni)
+	require.Equal(t, "backend.example.com", req.host)
+	require.Equal(t, "Bearer token", req.auth)
+}
+
+func TestDialHeadersReserved(t *testing.T) {
+	_, u := newUpgrader(t)
+	_, err := New(u, &network.NullResourceManager{}, nil, WithDialHeaders(http.Header{"Sec-Websocket-Key": []string{"foo"}}))
+	require.Error(t, err)
+	_, err = New(u, &network.NullResourceManager{}, nil, WithDialHeaders(http.Header{"Host": []string{"example.com"}}))
+	require.Error(t, err)
+}
+
 func TestDialWss(t *testing.T) {
 	serverMA, rid, errChan := testWSSServer(t, ma.StringCast("/ip4/127.0.0.1/tcp/0/tls/sni/example.com/ws"))
 	require.Contains(t, serverMA.String(), "tls")
@@ -405,6 +456,131 @@ func TestWebsocketConnection(t *testing.T) {
 	})
 }
 
+func TestNetListener(t *testing.T) {
+	nl, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	laddr, err := manet.FromNetAddr(nl.Addr())
+	require.NoError(t, err)
+
+	server, u := newUpgrader(t)
+	tpt, err := New(u, &network.NullResourceManager{}, nil, WithNetListener(nl))
+	require.NoError(t, err)
+	l, err := tpt.Listen(laddr.Encapsulate(ma.StringCast("/ws")))
+	require.NoError(t, err)
+	require.Equal(t, laddr.Encapsulate(ma.StringCast("/ws")), l.Multiaddr())
+
+	// Other addresses don't use the listener.
+	other, err := tpt.Listen(ma.StringCast("/ip4/127.0.0.1/tcp/0/ws"))
+	require.NoError(t, err)
+	require.NotEqual(t, l.Multiaddr(), other.Multiaddr())
+	other.Close()
+
+	go func() {
+		_, u := newUpgrader(t)
+		tpt, err := New(u, &network.NullResourceManager{}, nil)
+		require.NoError(t, err)
+		c, err := tpt.Dial(context.Background(), l.Multiaddr(), server)
+		require.NoError(t, err)
+		c.Close()
+	}()
+	c, err := l.Accept()
+	require.NoError(t, err)
+	c.Close()
+
+	// Closing the transport's listener closes the application's listener.
+	require.NoError(t, l.Close())
+	_, err = nl.Accept()
+	require.ErrorIs(t, err, net.ErrClosed)
+}
+
+func TestExternalServer(t *testing.T) {
+	for _, secure := range []bool{false, true} {
+		t.Run(fmt.Sprintf("secure=%t", secure), func(t *testing.T) {
+			server, u := newUpgrader(t)
+			tpt, err := New(u, &network.NullResourceManager{}, nil, WithExternalServer())
+			require.NoError(t, err)
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+				if gws.IsWebSocketUpgrade(r) {
+					tpt.Handler().ServeHTTP(w, r)
+					return
+				}
+				w.Write([]byte("website"))
+			})
+			var s *httptest.Server
+			if secure {
+				s = httptest.NewTLSServer(mux)
+			} else {
+				s = httptest.NewServer(mux)
+			}
+			defer s.Close()
+			laddr, err := manet.FromNetAddr(s.Listener.Addr())
+			require.NoError(t, err)
+			if secure {
+				laddr = laddr.Encapsulate(ma.StringCast("/tls/ws"))
+			} else {
+				laddr = laddr.Encapsulate(ma.StringCast("/ws"))
+			}
+
+			client := s.Client()
+			// Without a listener, websocket requests are rejected.
+			req, err := http.NewRequest(http.MethodGet, s.URL, nil)
+			require.NoError(t, err)
+			req.Header.Set("Connection", "Upgrade")
+			req.Header.Set("Upgrade", "websocket")
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+			l, err := tpt.Listen(laddr)
+			require.NoError(t, err)
+			defer l.Close()
+			require.Equal(t, laddr, l.Multiaddr())
+			_, err = tpt.Listen(laddr)
+			require.Error(t, err)
+
+			// The website is still served.
+			resp, err = client.Get(s.URL)
+			require.NoError(t, err)
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			require.NoError(t, err)
+			require.Equal(t, "website", string(body))
+
+			msg := []byte("HELLO WORLD")
+			go func() {
+				_, u := newUpgrader(t)
+				tpt, err := New(u, &network.NullResourceManager{}, nil, WithTLSClientConfig(&tls.Config{InsecureSkipVerify: true}))
+				require.NoError(t, err)
+				c, err := tpt.Dial(context.Background(), l.Multiaddr(), server)
+				require.NoError(t, err)
+				str, err := c.OpenStream(context.Background())
+				require.NoError(t, err)
+				defer str.Close()
+				_, err = str.Write(msg)
+				require.NoError(t, err)
+			}()
+			c, err := l.Accept()
+			require.NoError(t, err)
+			defer c.Close()
+			require.Equal(t, secure, isWSS(c.RemoteMultiaddr()))
+			str, err := c.AcceptStream()
+			require.NoError(t, err)
+			out, err := io.ReadAll(str)
+			require.NoError(t, err)
+			require.Equal(t, msg, out)
+
+			// After closing the listener, we can listen again.
+			require.NoError(t, l.Close())
+			l, err = tpt.Listen(laddr)
+			require.NoError(t, err)
+			require.NoError(t, l.Close())
+		})
+	}
+}
+
 func TestWebsocketListenSecureFailWithoutTLSConfig(t *testing.T) {
 	_, u := newUpgrader(t)
 	tpt, err := New(u, &network.NullResourceManager{}, nil)
@@ -646,6 +822,83 @@ func TestSocksProxy(t *testing.T) {
 	}
 }
 
+func TestHTTPProxy(t *testing.T) {
+	serverMA, rid, errChan := testWSSServer(t, ma.StringCast("/ip4/127.0.0.1/tcp/0/tls/sni/example.com/ws"))
+	_, serverAddr, err := manet.DialArgs(serverMA)
+	require.NoError(t, err)
+
+	proxyServer, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer proxyServer.Close()
+	type connectRequest struct {
+		target, auth string
+	}
+	connectChan := make(chan connectRequest, 1)
+	go func() {
+		c, err := proxyServer.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		req, err := http.ReadRequest(bufio.NewReader(c))
+		if err != nil {
+			return
+		}
+		connectChan <- connectRequest{target: req.Host, auth: req.Header.Get("Proxy-Authorization")}
+		// example.com doesn't resolve to our server.
+		target, err := net.Dial("tcp", serverAddr)
+		if err != nil {
+			c.Write([]byte("HTTP/1.1 502 Bad Gateway\r\n\r\n"))
+			return
+		}
+		defer target.Close()
+		c.Write([]byte("HTTP/1.1 200 OK\r\n\r\n"))
+		go io.Copy(target, c)
+		io.Copy(c, target)
+	}()
+
+	proxyURL, err := url.Parse("http://user:pass@" + proxyServer.Addr().String())
+	require.NoError(t, err)
+	_, u := newSecureUpgrader(t)
+	tpt, err := New(u, &network.NullResourceManager{}, nil,
+		WithTLSClientConfig(&tls.Config{InsecureSkipVerify: true}), // Our test server doesn't have a cert signed by a CA
+		WithProxy(http.ProxyURL(proxyURL)),
+	)
+	require.NoError(t, err)
+
+	masToDial, err := tpt.Resolve(context.Background(), serverMA)
+	require.NoError(t, err)
+	conn, err := tpt.Dial(context.Background(), masToDial[0], rid)
+	require.NoError(t, err)
+	defer conn.Close()
+	stream, err := conn.OpenStream(context.Background())
+	require.NoError(t, err)
+	defer stream.Close()
+	require.NoError(t, <-errChan)
+
+	req := <-connectChan
+	// The proxy is asked to connect to the SNI.
+	_, port, err := net.SplitHostPort(serverAddr)
+	require.NoError(t, err)
+	require.Equal(t, "example.com:"+port, req.target)
+	require.Equal(t, "Basic "+base64.StdEncoding.EncodeToString([]byte("user:pass")), req.auth)
+}
+
+func TestHTTPProxyRefused(t *testing.T) {
+	proxyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusProxyAuthRequired)
+	}))
+	defer proxyServer.Close()
+	proxyURL, err := url.Parse(proxyServer.URL)
+	require.NoError(t, err)
+
+	_, u := newUpgrader(t)
+	tpt, err := New(u, &network.NullResourceManager{}, nil, WithProxy(http.ProxyURL(proxyURL)))
+	require.NoError(t, err)
+	_, err = tpt.Dial(context.Background(), ma.StringCast("/ip4/1.2.3.4/tcp/1/ws"), test.RandPeerIDFatal(t))
+	require.ErrorContains(t, err, "Proxy Authentication Required")
+}
+
 func TestListenerAddr(t *testing.T) {
 	_, upgrader := newUpgrader(t)
 	transport, err := New(upgrader, &network.NullResourceManager{}, nil, WithTLSConfig(generateTLSConfig(t)))
@@ -21,6 +21,25 @@ import (
 const clockSkewAllowance = time.Hour
 const validityMinusTwoSkew = certValidity - (2 * clockSkewAllowance)
 
+// CertInfo describes a certificate used by the transport when listening.
+type CertInfo struct {
+	// Hash is the multihash of the certificate.
+	Hash multihash.Multihash
+	// Multiaddr is the /certhash component advertised for the certificate.
+	Multiaddr ma.Multiaddr
+	// NotBefore and NotAfter are the validity window of the certificate. The
+	// transport switches to the next certificate one hour before NotAfter,
+	// to allow for clock skew.
+	NotBefore, NotAfter time.Time
+}
+
+// CertRotation contains the certificate currently in use, and the one that
+// will be used after the next rotation. The certhashes of both are part of
+// the listen multiaddrs.
+type CertRotation struct {
+	Current, Next CertInfo
+}
+
 type certConfig struct {
 	tlsConf *tls.Config
 	sha256  [32]byte // cached from the tlsConf
@@ -29,8 +48,8 @@ type certConfig struct {
 func (c *certConfig) Start() time.Time { return c.tlsConf.Certificates[0].Leaf.NotBefore }
 func (c *certConfig) End() time.Time   { return c.tlsConf.Certificates[0].Leaf.NotAfter }
 
-func newCertConfig(key ic.PrivKey, start, end time.Time) (*certConfig, error) {
-	conf, err := getTLSConf(key, start, end)
+func newCertConfig(seed []byte, start, end time.Time) (*certConfig, error) {
+	conf, err := getTLSConf(seed, start, end)
 	if err != nil {
 		return nil, err
 	}
@@ -40,6 +59,23 @@ func newCertConfig(key ic.PrivKey, start, end time.Time) (*certConfig, error) {
 	}, nil
 }
 
+func (c *certConfig) info() (CertInfo, error) {
+	h, err := multihash.Encode(c.sha256[:], multihash.SHA2_256)
+	if err != nil {
+		return CertInfo{}, err
+	}
+	comp, err := addrComponentForCert(c.sha256[:])
+	if err != nil {
+		return CertInfo{}, err
+	}
+	return CertInfo{
+		Hash:      h,
+		Multiaddr: comp.Multiaddr(),
+		NotBefore: c.Start(),
+		NotAfter:  c.End(),
+	}, nil
+}
+
 // Certificate renewal logic:
 //  1. On startup, we generate one cert that is valid from now (-1h, to allow for clock skew), and another
 //     cert that is valid from the expiry date of the first certificate (again, with allowance for clock skew).
@@ -47,6 +83,8 @@ func newCertConfig(key ic.PrivKey, start, end time.Time) (*certConfig, error) {
 //     At the same time, we stop advertising the certhash of the first cert and generate the next cert.
 type certManager struct {
 	clock     clock.Clock
+	seed      []byte
+	onRotate  func(CertRotation)
 	ctx       context.Context
 	ctxCancel context.CancelFunc
 	refCount  sync.WaitGroup
@@ -60,14 +98,24 @@ type certManager struct {
 	serializedCertHashes [][]byte
 }
 
-func newCertManager(hostKey ic.PrivKey, clock clock.Clock) (*certManager, error) {
-	m := &certManager{clock: clock}
+// newCertManager creates a new certManager. Certificates are derived from the
+// seed, or from the host key if the seed is nil. onRotate, if set, is called
+// after every rotation.
+func newCertManager(hostKey ic.PrivKey, seed []byte, clock clock.Clock, onRotate func(CertRotation)) (*certManager, error) {
+	if seed == nil {
+		var err error
+		seed, err = hostKey.Raw()
+		if err != nil {
+			return nil, err
+		}
+	}
+	m := &certManager{clock: clock, seed: seed, onRotate: onRotate}
 	m.ctx, m.ctxCancel = context.WithCancel(context.Background())
 	if err := m.init(hostKey); err != nil {
 		return nil, err
 	}
 
-	m.background(hostKey)
+	m.background()
 	return m, nil
 }
 
@@ -100,18 +148,18 @@ func (m *certManager) init(hostKey ic.PrivKey) error {
 	// We want the certificate have been valid for at least one clockSkewAllowance
 	start = start.Add(-clockSkewAllowance)
 	startTime := getCurrentBucketStartTime(start, offset)
-	m.nextConfig, err = newCertConfig(hostKey, startTime, startTime.Add(certValidity))
+	m.nextConfig, err = newCertConfig(m.seed, startTime, startTime.Add(certValidity))
 	if err != nil {
 		return err
 	}
-	return m.rollConfig(hostKey)
+	return m.rollConfig()
 }
 
-func (m *certManager) rollConfig(hostKey ic.PrivKey) error {
+func (m *certManager) rollConfig() error {
 	// We stop using the current certificate clockSkewAllowance before its expiry time.
 	// At this point, the next certificate needs to be valid for one clockSkewAllowance.
 	nextStart := m.nextConfig.End().Add(-2 * clockSkewAllowance)
-	c, err := newCertConfig(hostKey, nextStart, nextStart.Add(certValidity))
+	c, err := newCertConfig(m.seed, nextStart, nextStart.Add(certValidity))
 	if err != nil {
 		return err
 	}
@@ -124,7 +172,7 @@ func (m *certManager) rollConfig(hostKey ic.PrivKey) error {
 	return m.cacheAddrComponent()
 }
 
-func (m *certManager) background(hostKey ic.PrivKey) {
+func (m *certManager) background() {
 	d := m.currentConfig.End().Add(-clockSkewAllowance).Sub(m.clock.Now())
 	log.Debugw("setting timer", "duration", d.String())
 	t := m.clock.Timer(d)
@@ -141,13 +189,19 @@ func (m *certManager) background(hostKey ic.PrivKey) {
 			case <-t.C:
 				now := m.clock.Now()
 				m.mx.Lock()
-				if err := m.rollConfig(hostKey); err != nil {
+				if err := m.rollConfig(); err != nil {
 					log.Errorw("rolling config failed", "error", err)
 				}
 				d := m.currentConfig.End().Add(-clockSkewAllowance).Sub(now)
 				log.Debugw("rolling certificates", "next", d.String())
 				t.Reset(d)
+				rotation, err := m.certificates()
 				m.mx.Unlock()
+				if err != nil {
+					log.Errorw("failed to get certificate info", "error", err)
+				} else if m.onRotate != nil {
+					m.onRotate(rotation)
+				}
 			}
 		}
 	}()
@@ -165,6 +219,25 @@ func (m *certManager) AddrComponent() ma.Multiaddr {
 	return m.addrComp
 }
 
+// Certificates returns the current and the next certificate.
+func (m *certManager) Certificates() (CertRotation, error) {
+	m.mx.RLock()
+	defer m.mx.RUnlock()
+	return m.certificates()
+}
+
+func (m *certManager) certificates() (CertRotation, error) {
+	current, err := m.currentConfig.info()
+	if err != nil {
+		return CertRotation{}, err
+	}
+	next, err := m.nextConfig.info()
+	if err != nil {
+		return CertRotation{}, err
+	}
+	return CertRotation{Current: current, Next: next}, nil
+}
+
 func (m *certManager) SerializedCertHashes() [][]byte {
 	return m.serializedCertHashes
 }
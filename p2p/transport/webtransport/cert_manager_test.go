@@ -46,7 +46,7 @@ func TestInitialCert(t *testing.T) {
 	cl.Add(1234567 * time.Hour)
 	priv, _, err := test.RandTestKeyPair(crypto.Ed25519, 256)
 	require.NoError(t, err)
-	m, err := newCertManager(priv, cl)
+	m, err := newCertManager(priv, nil, cl, nil)
 	require.NoError(t, err)
 	defer m.Close()
 
@@ -70,7 +70,7 @@ func TestCertRenewal(t *testing.T) {
 	cl.Add(time.Hour * 24 * 365)
 	priv, _, err := test.SeededTestKeyPair(crypto.Ed25519, 256, 0)
 	require.NoError(t, err)
-	m, err := newCertManager(priv, cl)
+	m, err := newCertManager(priv, nil, cl, nil)
 	require.NoError(t, err)
 	defer m.Close()
 
@@ -120,7 +120,7 @@ func TestDeterministicCertsAcrossReboots(t *testing.T) {
 			cl := clock.NewMock()
 			priv, _, err := test.SeededTestKeyPair(crypto.Ed25519, 256, 0)
 			require.NoError(t, err)
-			m, err := newCertManager(priv, cl)
+			m, err := newCertManager(priv, nil, cl, nil)
 			require.NoError(t, err)
 			defer m.Close()
 
@@ -132,7 +132,7 @@ func TestDeterministicCertsAcrossReboots(t *testing.T) {
 
 			cl.Add(time.Hour)
 			// reboot
-			m, err = newCertManager(priv, cl)
+			m, err = newCertManager(priv, nil, cl, nil)
 			require.NoError(t, err)
 			defer m.Close()
 
@@ -143,6 +143,59 @@ func TestDeterministicCertsAcrossReboots(t *testing.T) {
 	}
 }
 
+func TestCertSeed(t *testing.T) {
+	cl := clock.NewMock()
+	cl.Add(time.Hour * 24 * 365)
+	priv, _, err := test.SeededTestKeyPair(crypto.Ed25519, 256, 0)
+	require.NoError(t, err)
+
+	newCerts := func(seed []byte) CertRotation {
+		t.Helper()
+		m, err := newCertManager(priv, seed, cl, nil)
+		require.NoError(t, err)
+		defer m.Close()
+		certs, err := m.Certificates()
+		require.NoError(t, err)
+		return certs
+	}
+	fromKey := newCerts(nil)
+	seed, err := GenerateCertSeed()
+	require.NoError(t, err)
+	fromSeed := newCerts(seed)
+	require.NotEqual(t, fromKey.Current.Hash, fromSeed.Current.Hash)
+	// The same seed results in the same certificates.
+	require.Equal(t, fromSeed, newCerts(seed))
+	// The validity windows only depend on the host key.
+	require.Equal(t, fromKey.Current.NotBefore, fromSeed.Current.NotBefore)
+}
+
+func TestCertRotationCallback(t *testing.T) {
+	cl := clock.NewMock()
+	cl.Add(time.Hour * 24 * 365)
+	priv, _, err := test.SeededTestKeyPair(crypto.Ed25519, 256, 0)
+	require.NoError(t, err)
+	rotations := make(chan CertRotation, 1)
+	m, err := newCertManager(priv, nil, cl, func(r CertRotation) { rotations <- r })
+	require.NoError(t, err)
+	defer m.Close()
+
+	before, err := m.Certificates()
+	require.NoError(t, err)
+	require.Equal(t, before.Current.NotAfter, before.Next.NotBefore.Add(2*clockSkewAllowance))
+	require.Equal(t, m.AddrComponent(), before.Current.Multiaddr.Encapsulate(before.Next.Multiaddr))
+
+	cl.Set(before.Current.NotAfter.Add(-clockSkewAllowance + time.Second))
+	select {
+	case r := <-rotations:
+		require.Equal(t, before.Next, r.Current)
+		after, err := m.Certificates()
+		require.NoError(t, err)
+		require.Equal(t, after, r)
+	case <-time.After(time.Second):
+		t.Fatal("expected a certificate rotation")
+	}
+}
+
 func TestDeterministicTimeBuckets(t *testing.T) {
 	cl := clock.NewMock()
 	cl.Add(time.Hour * 24 * 365)
@@ -32,10 +32,17 @@ type conn struct {
 	session   *webtransport.Session
 
 	scope network.ConnManagementScope
+	// qconn is nil if the session was accepted by an external server, see
+	// WithExternalServer. The QUIC connection is then owned by the application.
 	qconn quic.Connection
+
+	// streamLimiter limits the incoming streams of accepted sessions. It is
+	// nil if there's no limit, see WithMaxStreamsPerSession.
+	streamLimiter *streamLimiter
 }
 
 var _ tpt.CapableConn = &conn{}
+var _ network.DatagramConn = &conn{}
 
 func newConn(tr *transport, sess *webtransport.Session, sconn *connSecurityMultiaddrs, scope network.ConnManagementScope, qconn quic.Connection) *conn {
 	return &conn{
@@ -56,6 +63,9 @@ func (c *conn) OpenStream(ctx context.Context) (network.MuxedStream, error) {
 }
 
 func (c *conn) AcceptStream() (network.MuxedStream, error) {
+	if c.streamLimiter != nil {
+		return c.acceptLimitedStream()
+	}
 	str, err := c.session.AcceptStream(context.Background())
 	if err != nil {
 		return nil, err
@@ -72,6 +82,9 @@ func (c *conn) allowWindowIncrease(size uint64) bool {
 // garbage collection to properly work in this package.
 func (c *conn) Close() error {
 	defer c.scope.Done()
+	if c.qconn == nil {
+		return c.session.CloseWithError(0, "")
+	}
 	c.transport.removeConn(c.qconn)
 	err := c.session.CloseWithError(0, "")
 	_ = c.qconn.CloseWithError(1, "")
@@ -89,3 +102,24 @@ func (c *conn) Transport() tpt.Transport { return c.transport }
 func (c *conn) ConnState() network.ConnectionState {
 	return network.ConnectionState{Transport: "webtransport"}
 }
+
+// SupportsDatagrams returns true if the peer supports WebTransport datagrams.
+func (c *conn) SupportsDatagrams() bool {
+	return c.session.ConnectionState().SupportsDatagrams
+}
+
+// SendDatagram sends an unreliable datagram on the WebTransport session.
+func (c *conn) SendDatagram(b []byte) error {
+	if !c.SupportsDatagrams() {
+		return network.ErrDatagramsNotSupported
+	}
+	return c.session.SendDatagram(b)
+}
+
+// ReceiveDatagram receives an unreliable datagram from the WebTransport session.
+func (c *conn) ReceiveDatagram(ctx context.Context) ([]byte, error) {
+	if !c.SupportsDatagrams() {
+		return nil, network.ErrDatagramsNotSupported
+	}
+	return c.session.ReceiveDatagram(ctx)
+}
@@ -25,8 +25,8 @@ import (
 
 const deterministicCertInfo = "determinisitic cert"
 
-func getTLSConf(key ic.PrivKey, start, end time.Time) (*tls.Config, error) {
-	cert, priv, err := generateCert(key, start, end)
+func getTLSConf(seed []byte, start, end time.Time) (*tls.Config, error) {
+	cert, priv, err := generateCertFromSeed(seed, start, end)
 	if err != nil {
 		return nil, err
 	}
@@ -47,10 +47,15 @@ func generateCert(key ic.PrivKey, start, end time.Time) (*x509.Certificate, *ecd
 	if err != nil {
 		return nil, nil, err
 	}
+	return generateCertFromSeed(keyBytes, start, end)
+}
 
+// generateCertFromSeed generates certs deterministically based on the `seed`
+// and start time passed in.
+func generateCertFromSeed(seed []byte, start, end time.Time) (*x509.Certificate, *ecdsa.PrivateKey, error) {
 	startTimeSalt := make([]byte, 8)
 	binary.LittleEndian.PutUint64(startTimeSalt, uint64(start.UnixNano()))
-	deterministicHKDFReader := newDeterministicReader(keyBytes, startTimeSalt, deterministicCertInfo)
+	deterministicHKDFReader := newDeterministicReader(seed, startTimeSalt, deterministicCertInfo)
 
 	b := make([]byte, 8)
 	if _, err := deterministicHKDFReader.Read(b); err != nil {
@@ -0,0 +1,171 @@
+package libp2pwebtransport
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"sync"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/x/rate"
+)
+
+// WithMaxSessions limits the number of concurrent sessions accepted by the
+// transport's listeners, including sessions that are still handshaking.
+// Requests exceeding the limit are rejected with 503 Service Unavailable
+// before the connection gater and the resource manager are consulted.
+// The default of 0 means no limit.
+func WithMaxSessions(n int) Option {
+	return func(t *transport) error {
+		if n < 0 {
+			return errors.New("max sessions must not be negative")
+		}
+		t.maxSessions = n
+		return nil
+	}
+}
+
+// WithMaxStreamsPerSession limits the number of concurrent streams a peer can
+// open on an accepted session. Streams exceeding the limit are reset when
+// they're accepted. A stream counts towards the limit until it is closed or
+// reset locally.
+// The default of 0 means no limit, other than the one imposed by QUIC flow
+// control.
+func WithMaxStreamsPerSession(n int) Option {
+	return func(t *transport) error {
+		if n < 0 {
+			return errors.New("max streams per session must not be negative")
+		}
+		t.maxStreamsPerSession = n
+		return nil
+	}
+}
+
+// WithHandshakeRateLimiter rate limits the session requests the transport's
+// listeners accept, for example per remote subnet. Requests exceeding the
+// limits are rejected with 429 Too Many Requests before the connection gater
+// and the resource manager are consulted.
+func WithHandshakeRateLimiter(l *rate.Limiter) Option {
+	return func(t *transport) error {
+		t.handshakeLimiter = l
+		return nil
+	}
+}
+
+// allowHandshake returns true if a session request from remoteAddr is within
+// the limits of the handshake rate limiter.
+func (t *transport) allowHandshake(remoteAddr string) bool {
+	if t.handshakeLimiter == nil {
+		return true
+	}
+	addrPort, err := netip.ParseAddrPort(remoteAddr)
+	if err != nil {
+		return true
+	}
+	return t.handshakeLimiter.Allow(addrPort.Addr().Unmap())
+}
+
+// reserveSession reserves a session if the session limit allows it. The
+// returned function releases the reservation and can be called multiple times.
+func (t *transport) reserveSession() (release func(), ok bool) {
+	if t.maxSessions == 0 {
+		return func() {}, true
+	}
+	if t.numSessions.Add(1) > int64(t.maxSessions) {
+		t.numSessions.Add(-1)
+		return nil, false
+	}
+	var once sync.Once
+	return func() { once.Do(func() { t.numSessions.Add(-1) }) }, true
+}
+
+// streamLimiter limits the number of concurrent incoming streams of a session.
+type streamLimiter struct {
+	max int
+
+	mx   sync.Mutex
+	open int
+}
+
+// reserve reserves a stream. It returns false if the limit is reached.
+func (l *streamLimiter) reserve() bool {
+	l.mx.Lock()
+	defer l.mx.Unlock()
+	if l.open >= l.max {
+		return false
+	}
+	l.open++
+	return true
+}
+
+func (l *streamLimiter) release() {
+	l.mx.Lock()
+	l.open--
+	l.mx.Unlock()
+}
+
+// limitedStream releases its reservation once it's closed or reset.
+type limitedStream struct {
+	*stream
+	limiter *streamLimiter
+
+	mx                    sync.Mutex
+	readClosed, writeDone bool
+	released              bool
+}
+
+var _ network.MuxedStream = &limitedStream{}
+
+func (s *limitedStream) done(read, write bool) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.readClosed = s.readClosed || read
+	s.writeDone = s.writeDone || write
+	if s.readClosed && s.writeDone && !s.released {
+		s.released = true
+		s.limiter.release()
+	}
+}
+
+func (s *limitedStream) Reset() error {
+	defer s.done(true, true)
+	return s.stream.Reset()
+}
+
+func (s *limitedStream) ResetWithError(code network.StreamErrorCode) error {
+	defer s.done(true, true)
+	return s.stream.ResetWithError(code)
+}
+
+func (s *limitedStream) Close() error {
+	defer s.done(true, true)
+	return s.stream.Close()
+}
+
+func (s *limitedStream) CloseRead() error {
+	defer s.done(true, false)
+	return s.stream.CloseRead()
+}
+
+func (s *limitedStream) CloseWrite() error {
+	defer s.done(false, true)
+	return s.stream.CloseWrite()
+}
+
+// acceptLimitedStream accepts the next stream within the limit, resetting
+// streams that exceed it.
+func (c *conn) acceptLimitedStream() (network.MuxedStream, error) {
+	for {
+		str, err := c.session.AcceptStream(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		if !c.streamLimiter.reserve() {
+			log.Debugw("stream limit reached, resetting stream", "peer", c.RemotePeer())
+			str.CancelRead(reset)
+			str.CancelWrite(reset)
+			continue
+		}
+		return &limitedStream{stream: &stream{str}, limiter: c.streamLimiter}, nil
+	}
+}
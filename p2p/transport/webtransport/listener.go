@@ -2,6 +2,7 @@ package libp2pwebtransport
 
 import (
 	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"net"
@@ -15,6 +16,7 @@ import (
 	"github.com/libp2p/go-libp2p/p2p/transport/quicreuse"
 
 	ma "github.com/multiformats/go-multiaddr"
+	"github.com/multiformats/go-multihash"
 	"github.com/quic-go/quic-go"
 	"github.com/quic-go/quic-go/http3"
 	"github.com/quic-go/webtransport-go"
@@ -75,7 +77,15 @@ type listener struct {
 	isStaticTLSConf bool
 	reuseListener   quicreuse.Listener
 
-	server webtransport.Server
+	server *webtransport.Server
+	// external is set if the server is owned by the application, see WithExternalServer.
+	external bool
+	// externalCertHashes are the multihashes of the certificates of the
+	// application's server, sent to clients during the handshake.
+	// externalMultiaddr is the listen address including their /certhash
+	// components.
+	externalCertHashes [][]byte
+	externalMultiaddr  ma.Multiaddr
 
 	ctx       context.Context
 	ctxCancel context.CancelFunc
@@ -104,7 +114,7 @@ func newListener(reuseListener quicreuse.Listener, t *transport, isStaticTLSConf
 		serverClosed:    make(chan struct{}),
 		addr:            reuseListener.Addr(),
 		multiaddr:       localMultiaddr,
-		server: webtransport.Server{
+		server: &webtransport.Server{
 			H3: http3.Server{
 				ConnContext: func(ctx context.Context, c quic.Connection) context.Context {
 					return context.WithValue(ctx, connKey{}, c)
@@ -132,20 +142,77 @@ func newListener(reuseListener quicreuse.Listener, t *transport, isStaticTLSConf
 	return ln, nil
 }
 
+// newExternalListener creates a listener for sessions served by a
+// webtransport.Server owned by the application. It doesn't open a socket.
+func newExternalListener(t *transport, laddr ma.Multiaddr, server *webtransport.Server) (*listener, error) {
+	quicAddr, _ := ma.SplitFunc(laddr, func(c ma.Component) bool { return c.Protocol().Code == ma.P_WEBTRANSPORT })
+	addr, _, err := quicreuse.FromQuicMultiaddr(quicAddr)
+	if err != nil {
+		return nil, err
+	}
+	// The certificates are hashed once, instead of on every handshake.
+	var certHashes [][]byte
+	externalAddr := laddr
+	if tlsConf := server.H3.TLSConfig; tlsConf != nil {
+		for _, cert := range tlsConf.Certificates {
+			if len(cert.Certificate) == 0 {
+				continue
+			}
+			h := sha256.Sum256(cert.Certificate[0])
+			mh, err := multihash.Encode(h[:], multihash.SHA2_256)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode certificate hash: %w", err)
+			}
+			c, err := addrComponentForCert(h[:])
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode certificate hash: %w", err)
+			}
+			certHashes = append(certHashes, mh)
+			externalAddr = externalAddr.AppendComponent(c)
+		}
+	}
+	ln := &listener{
+		transport:          t,
+		isStaticTLSConf:    true,
+		server:             server,
+		external:           true,
+		externalCertHashes: certHashes,
+		externalMultiaddr:  externalAddr,
+		queue:              make(chan tpt.CapableConn, queueLen),
+		addr:               addr,
+		multiaddr:          laddr,
+	}
+	ln.ctx, ln.ctxCancel = context.WithCancel(context.Background())
+	return ln, nil
+}
+
 func (l *listener) httpHandler(w http.ResponseWriter, r *http.Request) {
 	typ, ok := r.URL.Query()["type"]
 	if !ok || len(typ) != 1 || typ[0] != "noise" {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
+	if !l.transport.allowHandshake(r.RemoteAddr) {
+		log.Debugw("rate limiting session request", "addr", r.RemoteAddr)
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+	releaseSession, ok := l.transport.reserveSession()
+	if !ok {
+		log.Debugw("session limit reached, rejecting session request", "addr", r.RemoteAddr)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
 	remoteMultiaddr, err := stringToWebtransportMultiaddr(r.RemoteAddr)
 	if err != nil {
 		// This should never happen.
 		log.Errorw("converting remote address failed", "remote", r.RemoteAddr, "error", err)
+		releaseSession()
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 	if l.transport.gater != nil && !l.transport.gater.InterceptAccept(&connMultiaddrs{local: l.multiaddr, remote: remoteMultiaddr}) {
+		releaseSession()
 		w.WriteHeader(http.StatusForbidden)
 		return
 	}
@@ -161,17 +228,19 @@ func (l *listener) httpHandler(w http.ResponseWriter, r *http.Request) {
 		connScope, err = l.transport.rcmgr.OpenConnection(network.DirInbound, false, remoteMultiaddr)
 		if err != nil {
 			log.Debugw("resource manager blocked incoming connection", "addr", r.RemoteAddr, "error", err)
+			releaseSession()
 			w.WriteHeader(http.StatusServiceUnavailable)
 			return
 		}
 	}
-	err = l.httpHandlerWithConnScope(w, r, connScope)
+	err = l.httpHandlerWithConnScope(w, r, connScope, releaseSession)
 	if err != nil {
 		connScope.Done()
+		releaseSession()
 	}
 }
 
-func (l *listener) httpHandlerWithConnScope(w http.ResponseWriter, r *http.Request, connScope network.ConnManagementScope) error {
+func (l *listener) httpHandlerWithConnScope(w http.ResponseWriter, r *http.Request, connScope network.ConnManagementScope, releaseSession func()) error {
 	sess, err := l.server.Upgrade(w, r)
 	if err != nil {
 		log.Debugw("upgrade failed", "error", err)
@@ -179,6 +248,8 @@ func (l *listener) httpHandlerWithConnScope(w http.ResponseWriter, r *http.Reque
 		w.WriteHeader(500)
 		return err
 	}
+	// The session counts towards the session limit until it's closed.
+	context.AfterFunc(sess.Context(), releaseSession)
 	ctx, cancel := context.WithTimeout(l.ctx, handshakeTimeout)
 	sconn, err := l.handshake(ctx, sess)
 	if err != nil {
@@ -201,27 +272,22 @@ func (l *listener) httpHandlerWithConnScope(w http.ResponseWriter, r *http.Reque
 		return err
 	}
 
-	connVal := r.Context().Value(connKey{})
-	if connVal == nil {
-		log.Errorf("missing conn from context")
-		sess.CloseWithError(1, "")
-		return errors.New("invalid context")
-	}
-	nconn, ok := connVal.(*negotiatingConn)
-	if !ok {
-		log.Errorf("unexpected connection in context. invalid conn type: %T", nconn)
-		sess.CloseWithError(1, "")
-		return errors.New("invalid context")
-	}
-	qconn, err := nconn.Unwrap()
-	if err != nil {
-		log.Debugf("handshake timed out: %s", r.RemoteAddr)
-		sess.CloseWithError(1, "")
-		return err
+	var qconn quic.Connection
+	if !l.external {
+		qconn, err = l.quicConn(r)
+		if err != nil {
+			sess.CloseWithError(1, "")
+			return err
+		}
 	}
 
 	conn := newConn(l.transport, sess, sconn, connScope, qconn)
-	l.transport.addConn(qconn, conn)
+	if max := l.transport.maxStreamsPerSession; max > 0 {
+		conn.streamLimiter = &streamLimiter{max: max}
+	}
+	if qconn != nil {
+		l.transport.addConn(qconn, conn)
+	}
 	select {
 	case l.queue <- conn:
 	default:
@@ -233,6 +299,26 @@ func (l *listener) httpHandlerWithConnScope(w http.ResponseWriter, r *http.Reque
 	return nil
 }
 
+// quicConn returns the QUIC connection the request was received on.
+func (l *listener) quicConn(r *http.Request) (quic.Connection, error) {
+	connVal := r.Context().Value(connKey{})
+	if connVal == nil {
+		log.Errorf("missing conn from context")
+		return nil, errors.New("invalid context")
+	}
+	nconn, ok := connVal.(*negotiatingConn)
+	if !ok {
+		log.Errorf("unexpected connection in context. invalid conn type: %T", nconn)
+		return nil, errors.New("invalid context")
+	}
+	qconn, err := nconn.Unwrap()
+	if err != nil {
+		log.Debugf("handshake timed out: %s", r.RemoteAddr)
+		return nil, err
+	}
+	return qconn, nil
+}
+
 func (l *listener) Accept() (tpt.CapableConn, error) {
 	select {
 	case <-l.ctx.Done():
@@ -257,7 +343,9 @@ func (l *listener) handshake(ctx context.Context, sess *webtransport.Session) (*
 		return nil, err
 	}
 	var earlyData [][]byte
-	if !l.isStaticTLSConf {
+	if l.external {
+		earlyData = l.externalCertHashes
+	} else if !l.isStaticTLSConf {
 		earlyData = l.transport.certManager.SerializedCertHashes()
 	}
 
@@ -284,7 +372,10 @@ func (l *listener) Addr() net.Addr {
 }
 
 func (l *listener) Multiaddr() ma.Multiaddr {
-	if l.transport.certManager == nil {
+	if l.external {
+		return l.externalMultiaddr
+	}
+	if l.isStaticTLSConf || l.transport.certManager == nil {
 		return l.multiaddr
 	}
 	return l.multiaddr.Encapsulate(l.transport.certManager.AddrComponent())
@@ -292,9 +383,15 @@ func (l *listener) Multiaddr() ma.Multiaddr {
 
 func (l *listener) Close() error {
 	l.ctxCancel()
-	l.reuseListener.Close()
-	err := l.server.Close()
-	<-l.serverClosed
+	var err error
+	if l.external {
+		// The server is owned by the application.
+		l.transport.removeExternalListener(l)
+	} else {
+		l.reuseListener.Close()
+		err = l.server.Close()
+		<-l.serverClosed
+	}
 loop:
 	for {
 		select {
@@ -0,0 +1,95 @@
+package libp2pwebtransport
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+
+	"github.com/multiformats/go-multihash"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/quicvarint"
+)
+
+// webtransportStreamType is the signal value at the beginning of a
+// bidirectional WebTransport stream. It is followed by the session ID.
+const webtransportStreamType = 0x41
+
+// WithOptimisticHandshake makes the transport start the Noise handshake
+// concurrently with the establishment of the WebTransport session when
+// dialing, instead of waiting for the server to accept the session. This saves
+// a round trip.
+// The WebTransport over HTTP/3 specification allows clients to open streams
+// before the session is established: the server buffers them until it
+// accepts the session, and resets them if it rejects it.
+func WithOptimisticHandshake() Option {
+	return func(t *transport) error {
+		t.optimisticHandshake = true
+		return nil
+	}
+}
+
+// requestStreamObserver reports the ID of the first bidirectional stream
+// opened on the connection. That is the stream of the CONNECT request, and
+// its ID is the ID of the WebTransport session.
+type requestStreamObserver struct {
+	quic.EarlyConnection
+
+	once            sync.Once
+	onRequestStream func(quic.StreamID)
+}
+
+func (c *requestStreamObserver) OpenStream() (quic.Stream, error) {
+	str, err := c.EarlyConnection.OpenStream()
+	if err == nil {
+		c.once.Do(func() { c.onRequestStream(str.StreamID()) })
+	}
+	return str, err
+}
+
+func (c *requestStreamObserver) OpenStreamSync(ctx context.Context) (quic.Stream, error) {
+	str, err := c.EarlyConnection.OpenStreamSync(ctx)
+	if err == nil {
+		c.once.Do(func() { c.onRequestStream(str.StreamID()) })
+	}
+	return str, err
+}
+
+type handshakeResult struct {
+	conn *connSecurityMultiaddrs
+	err  error
+}
+
+// upgradeOptimistic runs the Noise handshake on a stream of the session with
+// the given ID, which doesn't need to be established yet.
+func (t *transport) upgradeOptimistic(ctx context.Context, qconn quic.Connection, sessionID quic.StreamID, p peer.ID, certHashes []multihash.DecodedMultihash) (*connSecurityMultiaddrs, error) {
+	str, err := qconn.OpenStreamSync(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer str.Close()
+	hdr := quicvarint.Append(nil, webtransportStreamType)
+	hdr = quicvarint.Append(hdr, uint64(sessionID))
+	if _, err := str.Write(hdr); err != nil {
+		str.CancelRead(0)
+		return nil, err
+	}
+	c, err := t.secureOutbound(ctx, &quicStream{Stream: str, conn: qconn}, p, certHashes)
+	if err != nil {
+		str.CancelRead(0)
+		return nil, err
+	}
+	return c, nil
+}
+
+// quicStream is a net.Conn wrapping a raw QUIC stream.
+type quicStream struct {
+	quic.Stream
+	conn quic.Connection
+}
+
+var _ net.Conn = &quicStream{}
+
+func (s *quicStream) LocalAddr() net.Addr  { return s.conn.LocalAddr() }
+func (s *quicStream) RemoteAddr() net.Addr { return s.conn.RemoteAddr() }
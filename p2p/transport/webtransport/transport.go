@@ -3,11 +3,14 @@ package libp2pwebtransport
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
+	"net"
+	"net/http"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -21,6 +24,7 @@ import (
 	"github.com/libp2p/go-libp2p/p2p/security/noise"
 	"github.com/libp2p/go-libp2p/p2p/security/noise/pb"
 	"github.com/libp2p/go-libp2p/p2p/transport/quicreuse"
+	"github.com/libp2p/go-libp2p/x/rate"
 
 	"github.com/benbjohnson/clock"
 	logging "github.com/ipfs/go-log/v2"
@@ -60,6 +64,55 @@ func WithTLSClientConfig(c *tls.Config) Option {
 	}
 }
 
+// WithCertSeed sets the seed the certificates are derived from. By default,
+// they are derived from the host key. Certificates are deterministic, so
+// persisting the seed keeps the certhashes stable across restarts, while
+// allowing the certificates to be replaced without changing the host key.
+// Use GenerateCertSeed to create a seed.
+func WithCertSeed(seed []byte) Option {
+	return func(t *transport) error {
+		if len(seed) < certSeedLen {
+			return fmt.Errorf("cert seed too short: expected at least %d bytes", certSeedLen)
+		}
+		t.certSeed = seed
+		return nil
+	}
+}
+
+const certSeedLen = 32
+
+// GenerateCertSeed generates a random seed that can be used with WithCertSeed.
+func GenerateCertSeed() ([]byte, error) {
+	seed := make([]byte, certSeedLen)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+	return seed, nil
+}
+
+// WithExternalServer makes the transport accept WebTransport sessions through
+// server, a webtransport.Server owned by the application, instead of listening
+// on its own socket. This allows a single UDP port to serve both a website
+// and libp2p WebTransport.
+// The application is responsible for serving HTTP/3 (for example from its own
+// quic.EarlyListener using server.ServeQUICConn), and for configuring the TLS
+// certificate, since the transport's certificates are not used. The hashes of
+// the certificates in server.H3.TLSConfig.Certificates are added to the
+// listen address as /certhash components, so that libp2p peers can dial it.
+// They are computed when Listen is called: to change the certificates, close
+// the listener and listen again.
+// Requests to the libp2p endpoint (/.well-known/libp2p-webtransport)
+// must be routed to the transport's Handler, and server.CheckOrigin must allow
+// the origins of the clients.
+// Listen doesn't open a socket in this mode. It only accepts a single listen
+// address, which should be the address the application's server listens on.
+func WithExternalServer(server *webtransport.Server) Option {
+	return func(t *transport) error {
+		t.externalServer = server
+		return nil
+	}
+}
+
 func WithHandshakeTimeout(d time.Duration) Option {
 	return func(t *transport) error {
 		t.handshakeTimeout = d
@@ -82,15 +135,56 @@ type transport struct {
 	hasCertManager atomic.Bool // set to true once the certManager is initialized
 	staticTLSConf  *tls.Config
 	tlsClientConf  *tls.Config
+	certSeed       []byte
+
+	optimisticHandshake bool
+
+	externalServer     *webtransport.Server
+	externalListenerMx sync.Mutex
+	externalListener   *listener
+
+	rotationSubsMx sync.Mutex
+	rotationSubs   map[chan CertRotation]struct{}
 
 	noise *noise.Transport
 
 	connMx           sync.Mutex
 	conns            map[quic.Connection]*conn // quic connection -> *conn
 	handshakeTimeout time.Duration
+
+	// limits of the listeners
+	maxSessions          int
+	numSessions          atomic.Int64
+	maxStreamsPerSession int
+	handshakeLimiter     *rate.Limiter
+}
+
+// CertHashProvider is implemented by the WebTransport transport. It gives
+// access to the certificates used when listening, so that deployments can
+// publish upcoming certhashes ahead of time.
+type CertHashProvider interface {
+	// Certificates returns the certificate currently in use and the one that
+	// will be used next. It returns an error if the transport isn't listening.
+	Certificates() (CertRotation, error)
+	// SubscribeCertRotation returns a channel that receives the new
+	// certificates every time the transport rotates its certificates.
+	// Rotations are dropped if the channel isn't read from.
+	// Call cancel to unsubscribe.
+	SubscribeCertRotation() (rotations <-chan CertRotation, cancel func())
+}
+
+// HandlerProvider is implemented by the WebTransport transport. See
+// WithExternalServer.
+type HandlerProvider interface {
+	// Handler returns the http.Handler serving the libp2p WebTransport
+	// endpoint on the application's server.
+	Handler() http.Handler
 }
 
 var _ tpt.Transport = &transport{}
+var _ tpt.CapabilityProvider = &transport{}
+var _ CertHashProvider = &transport{}
+var _ HandlerProvider = &transport{}
 var _ tpt.Resolver = &transport{}
 var _ io.Closer = &transport{}
 
@@ -115,6 +209,7 @@ func New(key ic.PrivKey, psk pnet.PSK, connManager *quicreuse.ConnManager, gater
 		connManager:      connManager,
 		conns:            map[quic.Connection]*conn{},
 		handshakeTimeout: handshakeTimeout,
+		rotationSubs:     make(map[chan CertRotation]struct{}),
 	}
 	for _, opt := range opts {
 		if err := opt(t); err != nil {
@@ -168,11 +263,17 @@ func (t *transport) dialWithScope(ctx context.Context, raddr ma.Multiaddr, p pee
 	}
 
 	maddr, _ := ma.SplitFunc(raddr, func(c ma.Component) bool { return c.Protocol().Code == ma.P_WEBTRANSPORT })
-	sess, qconn, err := t.dial(ctx, maddr, url, sni, certHashes)
+	sess, qconn, handshake, err := t.dial(ctx, maddr, url, sni, certHashes, p)
 	if err != nil {
 		return nil, err
 	}
-	sconn, err := t.upgrade(ctx, sess, p, certHashes)
+	var sconn *connSecurityMultiaddrs
+	if handshake != nil {
+		res := <-handshake
+		sconn, err = res.conn, res.err
+	} else {
+		sconn, err = t.upgrade(ctx, sess, p, certHashes)
+	}
 	if err != nil {
 		sess.CloseWithError(1, "")
 		qconn.CloseWithError(1, "")
@@ -188,7 +289,10 @@ func (t *transport) dialWithScope(ctx context.Context, raddr ma.Multiaddr, p pee
 	return conn, nil
 }
 
-func (t *transport) dial(ctx context.Context, addr ma.Multiaddr, url, sni string, certHashes []multihash.DecodedMultihash) (*webtransport.Session, quic.Connection, error) {
+// dial establishes the WebTransport session. If the Noise handshake is run
+// optimistically, see WithOptimisticHandshake, its result is delivered on the
+// returned channel. Otherwise, the channel is nil.
+func (t *transport) dial(ctx context.Context, addr ma.Multiaddr, url, sni string, certHashes []multihash.DecodedMultihash, p peer.ID) (*webtransport.Session, quic.Connection, <-chan handshakeResult, error) {
 	var tlsConf *tls.Config
 	if t.tlsClientConf != nil {
 		tlsConf = t.tlsClientConf.Clone()
@@ -212,41 +316,59 @@ func (t *transport) dial(ctx context.Context, addr ma.Multiaddr, url, sni string
 	ctx = quicreuse.WithAssociation(ctx, t)
 	conn, err := t.connManager.DialQUIC(ctx, addr, tlsConf, t.allowWindowIncrease)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
+	}
+	earlyConn := conn.(quic.EarlyConnection)
+	var handshake chan handshakeResult
+	if t.optimisticHandshake {
+		handshake = make(chan handshakeResult, 1)
+		earlyConn = &requestStreamObserver{
+			EarlyConnection: earlyConn,
+			onRequestStream: func(sessionID quic.StreamID) {
+				go func() {
+					c, err := t.upgradeOptimistic(ctx, conn, sessionID, p, certHashes)
+					handshake <- handshakeResult{conn: c, err: err}
+				}()
+			},
+		}
 	}
 	dialer := webtransport.Dialer{
 		DialAddr: func(_ context.Context, _ string, _ *tls.Config, _ *quic.Config) (quic.EarlyConnection, error) {
-			return conn.(quic.EarlyConnection), nil
+			return earlyConn, nil
 		},
 		QUICConfig: t.connManager.ClientConfig().Clone(),
 	}
 	rsp, sess, err := dialer.Dial(ctx, url, nil)
 	if err != nil {
 		conn.CloseWithError(1, "")
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 	if rsp.StatusCode < 200 || rsp.StatusCode > 299 {
 		conn.CloseWithError(1, "")
-		return nil, nil, fmt.Errorf("invalid response status code: %d", rsp.StatusCode)
+		return nil, nil, nil, fmt.Errorf("invalid response status code: %d", rsp.StatusCode)
 	}
-	return sess, conn, err
+	return sess, conn, handshake, nil
 }
 
 func (t *transport) upgrade(ctx context.Context, sess *webtransport.Session, p peer.ID, certHashes []multihash.DecodedMultihash) (*connSecurityMultiaddrs, error) {
-	local, err := toWebtransportMultiaddr(sess.LocalAddr())
+	str, err := sess.OpenStreamSync(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("error determining local addr: %w", err)
+		return nil, err
 	}
-	remote, err := toWebtransportMultiaddr(sess.RemoteAddr())
+	defer str.Close()
+	return t.secureOutbound(ctx, &webtransportStream{Stream: str, wsess: sess}, p, certHashes)
+}
+
+// secureOutbound runs the Noise handshake on the stream.
+func (t *transport) secureOutbound(ctx context.Context, str net.Conn, p peer.ID, certHashes []multihash.DecodedMultihash) (*connSecurityMultiaddrs, error) {
+	local, err := toWebtransportMultiaddr(str.LocalAddr())
 	if err != nil {
-		return nil, fmt.Errorf("error determining remote addr: %w", err)
+		return nil, fmt.Errorf("error determining local addr: %w", err)
 	}
-
-	str, err := sess.OpenStreamSync(ctx)
+	remote, err := toWebtransportMultiaddr(str.RemoteAddr())
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("error determining remote addr: %w", err)
 	}
-	defer str.Close()
 
 	// Now run a Noise handshake (using early data) and get all the certificate hashes from the server.
 	// We will verify that the certhashes we used to dial is a subset of the certhashes we received from the server.
@@ -274,7 +396,7 @@ func (t *transport) upgrade(ctx context.Context, sess *webtransport.Session, p p
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Noise transport: %w", err)
 	}
-	c, err := n.SecureOutbound(ctx, &webtransportStream{Stream: str, wsess: sess}, p)
+	c, err := n.SecureOutbound(ctx, str, p)
 	if err != nil {
 		return nil, err
 	}
@@ -315,9 +437,12 @@ func (t *transport) Listen(laddr ma.Multiaddr) (tpt.Listener, error) {
 	if certhashCount > 0 {
 		return nil, fmt.Errorf("cannot listen on a specific certhash non-WebTransport addr: %s", laddr)
 	}
+	if t.externalServer != nil {
+		return t.listenExternal(laddr)
+	}
 	if t.staticTLSConf == nil {
 		t.listenOnce.Do(func() {
-			t.certManager, t.listenOnceErr = newCertManager(t.privKey, t.clock)
+			t.certManager, t.listenOnceErr = newCertManager(t.privKey, t.certSeed, t.clock, t.notifyCertRotation)
 			t.hasCertManager.Store(true)
 		})
 		if t.listenOnceErr != nil {
@@ -341,6 +466,79 @@ func (t *transport) Listen(laddr ma.Multiaddr) (tpt.Listener, error) {
 	return newListener(ln, t, t.staticTLSConf != nil)
 }
 
+func (t *transport) listenExternal(laddr ma.Multiaddr) (tpt.Listener, error) {
+	t.externalListenerMx.Lock()
+	defer t.externalListenerMx.Unlock()
+	if t.externalListener != nil {
+		return nil, errors.New("already listening on the external server")
+	}
+	ln, err := newExternalListener(t, laddr, t.externalServer)
+	if err != nil {
+		return nil, err
+	}
+	t.externalListener = ln
+	return ln, nil
+}
+
+func (t *transport) removeExternalListener(ln *listener) {
+	t.externalListenerMx.Lock()
+	defer t.externalListenerMx.Unlock()
+	if t.externalListener == ln {
+		t.externalListener = nil
+	}
+}
+
+// Handler returns the http.Handler serving the libp2p WebTransport endpoint
+// on the server configured using WithExternalServer.
+func (t *transport) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.externalListenerMx.Lock()
+		ln := t.externalListener
+		t.externalListenerMx.Unlock()
+		if ln == nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		ln.httpHandler(w, r)
+	})
+}
+
+var errNotListening = errors.New("webtransport: not listening")
+
+// Certificates returns the certificate currently in use and the one that
+// will be used next.
+func (t *transport) Certificates() (CertRotation, error) {
+	if !t.hasCertManager.Load() || t.certManager == nil {
+		return CertRotation{}, errNotListening
+	}
+	return t.certManager.Certificates()
+}
+
+// SubscribeCertRotation subscribes to certificate rotations.
+func (t *transport) SubscribeCertRotation() (<-chan CertRotation, func()) {
+	ch := make(chan CertRotation, 1)
+	t.rotationSubsMx.Lock()
+	t.rotationSubs[ch] = struct{}{}
+	t.rotationSubsMx.Unlock()
+	return ch, func() {
+		t.rotationSubsMx.Lock()
+		delete(t.rotationSubs, ch)
+		t.rotationSubsMx.Unlock()
+	}
+}
+
+func (t *transport) notifyCertRotation(r CertRotation) {
+	t.rotationSubsMx.Lock()
+	defer t.rotationSubsMx.Unlock()
+	for ch := range t.rotationSubs {
+		select {
+		case ch <- r:
+		default:
+			log.Debugw("dropping certificate rotation event, subscriber not reading")
+		}
+	}
+}
+
 func (t *transport) Protocols() []int {
 	return []int{ma.P_WEBTRANSPORT}
 }
@@ -349,6 +547,13 @@ func (t *transport) Proxy() bool {
 	return false
 }
 
+// Capabilities returns the capabilities of the transport. WebTransport runs on
+// top of QUIC and shares its UDP socket, so the simultaneous dials of a hole
+// punch open the NAT mapping just like they do for QUIC.
+func (t *transport) Capabilities() tpt.Capabilities {
+	return tpt.CapabilityHolePunching | tpt.CapabilityBrowser
+}
+
 func (t *transport) Close() error {
 	t.listenOnce.Do(func() {})
 	if t.certManager != nil {
@@ -3,6 +3,8 @@ package libp2pwebtransport_test
 import (
 	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
 	"crypto/tls"
@@ -10,8 +12,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/netip"
 	"os"
 	"runtime"
 	"sync/atomic"
@@ -27,6 +32,7 @@ import (
 	tpt "github.com/libp2p/go-libp2p/core/transport"
 	"github.com/libp2p/go-libp2p/p2p/transport/quicreuse"
 	libp2pwebtransport "github.com/libp2p/go-libp2p/p2p/transport/webtransport"
+	"github.com/libp2p/go-libp2p/x/rate"
 
 	"github.com/benbjohnson/clock"
 	ma "github.com/multiformats/go-multiaddr"
@@ -36,6 +42,7 @@ import (
 	"github.com/quic-go/quic-go"
 	"github.com/quic-go/quic-go/http3"
 	quicproxy "github.com/quic-go/quic-go/integrationtests/tools/proxy"
+	"github.com/quic-go/webtransport-go"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 )
@@ -150,6 +157,48 @@ func TestTransport(t *testing.T) {
 	require.True(t, conn.IsClosed())
 }
 
+func TestOptimisticHandshake(t *testing.T) {
+	serverID, serverKey := newIdentity(t)
+	tr, err := libp2pwebtransport.New(serverKey, nil, newConnManager(t), nil, nil)
+	require.NoError(t, err)
+	defer tr.(io.Closer).Close()
+	ln, err := tr.Listen(ma.StringCast("/ip4/127.0.0.1/udp/0/quic-v1/webtransport"))
+	require.NoError(t, err)
+	defer ln.Close()
+
+	_, clientKey := newIdentity(t)
+	cl, err := libp2pwebtransport.New(clientKey, nil, newConnManager(t), nil, nil, libp2pwebtransport.WithOptimisticHandshake())
+	require.NoError(t, err)
+	defer cl.(io.Closer).Close()
+
+	for i := 0; i < 3; i++ {
+		go func() {
+			conn, err := cl.Dial(context.Background(), ln.Multiaddr(), serverID)
+			require.NoError(t, err)
+			str, err := conn.OpenStream(context.Background())
+			require.NoError(t, err)
+			_, err = str.Write([]byte("foobar"))
+			require.NoError(t, err)
+			require.NoError(t, str.Close())
+		}()
+
+		conn, err := ln.Accept()
+		require.NoError(t, err)
+		require.Equal(t, clientKey.GetPublic(), conn.RemotePublicKey())
+		str, err := conn.AcceptStream()
+		require.NoError(t, err)
+		data, err := io.ReadAll(str)
+		require.NoError(t, err)
+		require.Equal(t, "foobar", string(data))
+		require.NoError(t, conn.Close())
+	}
+
+	// The handshake fails if the peer ID doesn't match.
+	wrongID, _ := newIdentity(t)
+	_, err = cl.Dial(context.Background(), ln.Multiaddr(), wrongID)
+	require.Error(t, err)
+}
+
 func TestHashVerification(t *testing.T) {
 	serverID, serverKey := newIdentity(t)
 	tr, err := libp2pwebtransport.New(serverKey, nil, newConnManager(t), nil, &network.NullResourceManager{})
@@ -266,6 +315,171 @@ func TestListenerAddrs(t *testing.T) {
 	require.Equal(t, hashes1, hashes2)
 }
 
+func TestCertificates(t *testing.T) {
+	_, key := newIdentity(t)
+	seed, err := libp2pwebtransport.GenerateCertSeed()
+	require.NoError(t, err)
+	tr, err := libp2pwebtransport.New(key, nil, newConnManager(t), nil, &network.NullResourceManager{}, libp2pwebtransport.WithCertSeed(seed))
+	require.NoError(t, err)
+	defer tr.(io.Closer).Close()
+
+	certs := tr.(libp2pwebtransport.CertHashProvider)
+	_, err = certs.Certificates()
+	require.Error(t, err)
+
+	ln, err := tr.Listen(ma.StringCast("/ip4/127.0.0.1/udp/0/quic-v1/webtransport"))
+	require.NoError(t, err)
+	defer ln.Close()
+	rotation, err := certs.Certificates()
+	require.NoError(t, err)
+	require.Equal(t, extractCertHashes(ln.Multiaddr()), extractCertHashes(rotation.Current.Multiaddr.Encapsulate(rotation.Next.Multiaddr)))
+	require.True(t, rotation.Current.NotBefore.Before(time.Now()))
+	require.True(t, rotation.Current.NotAfter.After(time.Now()))
+}
+
+func newSelfSignedTLSConfig(t *testing.T) *tls.Config {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, priv.Public(), priv)
+	require.NoError(t, err)
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{certDER}, PrivateKey: priv}}}
+}
+
+func TestExternalServer(t *testing.T) {
+	serverID, serverKey := newIdentity(t)
+	server := &webtransport.Server{CheckOrigin: func(*http.Request) bool { return true }}
+	tr, err := libp2pwebtransport.New(serverKey, nil, newConnManager(t), nil, nil, libp2pwebtransport.WithExternalServer(server))
+	require.NoError(t, err)
+	defer tr.(io.Closer).Close()
+
+	// The application serves both a website and libp2p on the same port.
+	tlsConf := newSelfSignedTLSConfig(t)
+	mux := http.NewServeMux()
+	mux.Handle("/.well-known/libp2p-webtransport", tr.(libp2pwebtransport.HandlerProvider).Handler())
+	mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("website")) })
+	server.H3 = http3.Server{TLSConfig: tlsConf, Handler: mux}
+	udpConn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer udpConn.Close()
+	go server.Serve(udpConn)
+	defer server.Close()
+
+	laddr, err := quicreuse.ToQuicMultiaddr(udpConn.LocalAddr(), quic.Version1)
+	require.NoError(t, err)
+	laddr = laddr.Encapsulate(ma.StringCast("/webtransport"))
+	ln, err := tr.Listen(laddr)
+	require.NoError(t, err)
+	defer ln.Close()
+	certhash := getCerthashComponent(t, tlsConf.Certificates[0].Certificate[0])
+	require.Equal(t, laddr.AppendComponent(certhash), ln.Multiaddr())
+
+	_, clientKey := newIdentity(t)
+	tr2, err := libp2pwebtransport.New(clientKey, nil, newConnManager(t), nil, nil)
+	require.NoError(t, err)
+	defer tr2.(io.Closer).Close()
+	go func() {
+		conn, err := tr2.Dial(context.Background(), ln.Multiaddr(), serverID)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		str, err := conn.OpenStream(context.Background())
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		str.Write([]byte("foobar"))
+		str.Close()
+	}()
+
+	conn, err := ln.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	str, err := conn.AcceptStream()
+	require.NoError(t, err)
+	data, err := io.ReadAll(str)
+	require.NoError(t, err)
+	require.Equal(t, "foobar", string(data))
+}
+
+func TestExternalServerListen(t *testing.T) {
+	_, key := newIdentity(t)
+	tr, err := libp2pwebtransport.New(key, nil, newConnManager(t), nil, nil, libp2pwebtransport.WithExternalServer(&webtransport.Server{}))
+	require.NoError(t, err)
+	defer tr.(io.Closer).Close()
+
+	// Without a listener, the handler rejects requests.
+	rec := httptest.NewRecorder()
+	tr.(libp2pwebtransport.HandlerProvider).Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodConnect, "/.well-known/libp2p-webtransport?type=noise", nil))
+	require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	laddr := ma.StringCast("/ip4/127.0.0.1/udp/443/quic-v1/webtransport")
+	ln, err := tr.Listen(laddr)
+	require.NoError(t, err)
+	require.Equal(t, laddr, ln.Multiaddr())
+	require.Equal(t, "127.0.0.1:443", ln.Addr().String())
+	_, err = tr.Listen(laddr)
+	require.Error(t, err)
+
+	// After closing the listener, we can listen again.
+	require.NoError(t, ln.Close())
+	_, err = ln.Accept()
+	require.ErrorIs(t, err, tpt.ErrListenerClosed)
+	ln, err = tr.Listen(laddr)
+	require.NoError(t, err)
+	require.NoError(t, ln.Close())
+}
+
+func TestHandshakeLimits(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	connGater := NewMockConnectionGater(ctrl)
+
+	_, key := newIdentity(t)
+	limiter := &rate.Limiter{NetworkPrefixLimits: []rate.PrefixLimit{
+		{Prefix: netip.MustParsePrefix("192.0.2.0/24"), Limit: rate.Limit{RPS: 0.001, Burst: 1}},
+	}}
+	tr, err := libp2pwebtransport.New(key, nil, newConnManager(t), connGater, nil,
+		libp2pwebtransport.WithExternalServer(&webtransport.Server{}),
+		libp2pwebtransport.WithHandshakeRateLimiter(limiter),
+		libp2pwebtransport.WithMaxSessions(1),
+	)
+	require.NoError(t, err)
+	defer tr.(io.Closer).Close()
+	ln, err := tr.Listen(ma.StringCast("/ip4/127.0.0.1/udp/443/quic-v1/webtransport"))
+	require.NoError(t, err)
+	defer ln.Close()
+
+	handshake := func(remoteAddr string) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodConnect, "/.well-known/libp2p-webtransport?type=noise", nil)
+		req.RemoteAddr = remoteAddr
+		tr.(libp2pwebtransport.HandlerProvider).Handler().ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Rejected sessions don't count towards the session limit.
+	connGater.EXPECT().InterceptAccept(gomock.Any()).Return(false).Times(3)
+	require.Equal(t, http.StatusForbidden, handshake("192.0.2.1:1234"))
+	require.Equal(t, http.StatusTooManyRequests, handshake("192.0.2.2:1234"))
+	require.Equal(t, http.StatusForbidden, handshake("198.51.100.1:1234"))
+	require.Equal(t, http.StatusForbidden, handshake("198.51.100.1:1234"))
+}
+
+func TestLimitOptions(t *testing.T) {
+	_, key := newIdentity(t)
+	_, err := libp2pwebtransport.New(key, nil, newConnManager(t), nil, nil, libp2pwebtransport.WithMaxSessions(-1))
+	require.Error(t, err)
+	_, err = libp2pwebtransport.New(key, nil, newConnManager(t), nil, nil, libp2pwebtransport.WithMaxStreamsPerSession(-1))
+	require.Error(t, err)
+}
+
 func TestResourceManagerDialing(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -12,6 +12,7 @@ proto_array=(
   p2p/security/noise/pb/payload.proto
   p2p/transport/webrtc/pb/message.proto
   p2p/protocol/identify/pb/identify.proto
+  p2p/protocol/identify/pb/delta.proto
   p2p/protocol/circuitv2/pb/circuit.proto
   p2p/protocol/circuitv2/pb/voucher.proto
   p2p/protocol/autonatv2/pb/autonatv2.proto
@@ -78,6 +78,12 @@ func (r *Limiter) init() {
 
 // Limit rate limits a StreamHandler function.
 func (r *Limiter) Limit(f func(s network.Stream)) func(s network.Stream) {
+	return r.LimitWithCallback(f, nil)
+}
+
+// LimitWithCallback is like Limit, and additionally calls onLimited, if not
+// nil, with every stream that was reset because it exceeded the rate limit.
+func (r *Limiter) LimitWithCallback(f func(s network.Stream), onLimited func(s network.Stream)) func(s network.Stream) {
 	r.init()
 	return func(s network.Stream) {
 		addr := s.Conn().RemoteMultiaddr()
@@ -91,6 +97,9 @@ func (r *Limiter) Limit(f func(s network.Stream)) func(s network.Stream) {
 		}
 		if !r.Allow(ipAddr) {
 			_ = s.ResetWithError(network.StreamRateLimited)
+			if onLimited != nil {
+				onLimited(s)
+			}
 			return
 		}
 		f(s)